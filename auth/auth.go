@@ -4,7 +4,9 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,21 +14,60 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/dengmengmian/goBili/httpclient"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/logging"
+
 	"github.com/skip2/go-qrcode"
 )
 
 // AuthManager handles Bilibili authentication.
 //
+// An AuthManager is safe for concurrent use: parallel downloads share one
+// manager and the cookie jar updates it from response headers.
+//
 //nolint:revive // intentional: exported as AuthManager for clarity in auth package
 type AuthManager struct {
-	cookies   map[string]string
-	userAgent string
-	client    *http.Client
-	logger    *logrus.Logger
-	configDir string
+	// mu guards cookies, the request headers, refreshToken, accessKey and
+	// the WBI key cache.
+	mu      sync.RWMutex
+	cookies map[string]string
+	// saveMu serializes writes of the cookie files.
+	saveMu sync.Mutex
+
+	userAgent    string
+	referer      string
+	extraHeaders map[string]string // see SetFingerprint
+	client       HTTPDoer
+	logger       logging.Logger
+	configDir    string
+
+	// anonymous managers never touch the cookie store (see NewAnonymousAuthManager).
+	anonymous bool
+
+	// refreshToken renews the cookies before they expire (see RefreshCookies).
+	refreshToken string
+
+	// accessKey is the app-side token of a TV login (see LoginWithTV).
+	accessKey *AccessKey
+
+	// qrOutput is where QR code logins also save the code as PNG.
+	qrOutput string
+
+	// prompt receives the instructions, QR code and progress of
+	// interactive logins (see SetPromptOutput).
+	prompt io.Writer
+
+	// secrets keeps SESSDATA and bili_jct out of cookies.json when set
+	// (see SetCredentialStore).
+	secrets secretStore
+
+	// Cached WBI mixin key (see SignWbi).
+	wbiKey        string
+	wbiKeyFetched time.Time
 }
 
 // UserInfo represents user information
@@ -60,20 +101,35 @@ type QRCodeStatus struct {
 }
 
 // NewAuthManager creates a new authentication manager
-func NewAuthManager(configDir string, logger *logrus.Logger) *AuthManager {
-	return &AuthManager{
+func NewAuthManager(configDir string, logger logging.Logger) *AuthManager {
+	return NewAuthManagerWithClient(configDir, logger, nil)
+}
+
+// NewAuthManagerWithClient is NewAuthManager sending its requests through
+// client; nil uses an http.Client on the shared transport of httpclient
+// with a 30 second timeout. A nil logger
+// discards the log.
+func NewAuthManagerWithClient(configDir string, logger logging.Logger, client HTTPDoer) *AuthManager {
+	if client == nil {
+		client = httpclient.NewClient(30 * time.Second)
+	}
+	am := &AuthManager{
 		cookies:   make(map[string]string),
 		userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		logger:    logger,
+		referer:   DefaultReferer,
+		logger:    logging.OrDiscard(logger),
 		configDir: configDir,
 	}
+	am.client = am.WithCookieJar(client)
+	return am
 }
 
 // LoadCookies loads cookies from file
 func (am *AuthManager) LoadCookies() error {
+	if am.anonymous {
+		return nil
+	}
+
 	cookieFile := filepath.Join(am.configDir, "cookies.json")
 
 	if _, err := os.Stat(cookieFile); os.IsNotExist(err) {
@@ -86,9 +142,48 @@ func (am *AuthManager) LoadCookies() error {
 		return fmt.Errorf("failed to read cookie file: %w", err)
 	}
 
-	if err := json.Unmarshal(data, &am.cookies); err != nil {
+	cookies := make(map[string]string)
+	if err := json.Unmarshal(data, &cookies); err != nil {
 		return fmt.Errorf("failed to parse cookie file: %w", err)
 	}
+	if am.secrets != nil {
+		secrets, err := am.secrets.load()
+		if err != nil {
+			return err
+		}
+		if err := am.takeTokenSecrets(secrets); err != nil {
+			return err
+		}
+		for name, value := range secrets {
+			if _, ok := cookies[name]; !ok {
+				cookies[name] = value
+			}
+		}
+	}
+	am.mu.Lock()
+	for name, value := range cookies {
+		am.cookies[name] = value
+	}
+	hasKey := am.accessKey != nil
+	am.mu.Unlock()
+	// The credential store's tokens win over plaintext files left behind.
+	if am.RefreshToken() == "" {
+		if err := am.loadRefreshToken(); err != nil {
+			return err
+		}
+	}
+	if !hasKey {
+		if err := am.loadAccessKey(); err != nil {
+			return err
+		}
+	}
+	if am.secrets != nil && (hasSecrets(cookies) || am.hasTokenFiles()) {
+		// Written before the credential store was selected; move the
+		// secrets out of the plaintext files.
+		if err := am.SaveCookies(); err != nil {
+			am.logger.Warnf("Failed to move credentials to the credential store: %v", err)
+		}
+	}
 
 	am.logger.Info("Loaded cookies from file")
 	return nil
@@ -96,37 +191,84 @@ func (am *AuthManager) LoadCookies() error {
 
 // SaveCookies saves cookies to file
 func (am *AuthManager) SaveCookies() error {
+	if err := am.saveCookies(); err != nil {
+		return err
+	}
+	am.logger.Info("Saved cookies to file")
+	return nil
+}
+
+// saveCookies is SaveCookies without the log message.
+func (am *AuthManager) saveCookies() error {
+	if am.anonymous {
+		return errAnonymous
+	}
+
+	am.saveMu.Lock()
+	defer am.saveMu.Unlock()
+
 	if err := os.MkdirAll(am.configDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
+	am.mu.RLock()
+	cookies := make(map[string]string, len(am.cookies))
+	for name, value := range am.cookies {
+		cookies[name] = value
+	}
+	am.mu.RUnlock()
+
+	if am.secrets != nil {
+		public, secrets := splitSecrets(cookies)
+		if err := am.putTokenSecrets(secrets); err != nil {
+			return err
+		}
+		if err := am.secrets.save(secrets); err != nil {
+			return err
+		}
+		cookies = public
+	}
+
 	cookieFile := filepath.Join(am.configDir, "cookies.json")
-	data, err := json.MarshalIndent(am.cookies, "", "  ")
+	data, err := json.MarshalIndent(cookies, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal cookies: %w", err)
 	}
 
-	if err := os.WriteFile(cookieFile, data, 0644); err != nil {
+	if err := os.WriteFile(cookieFile, data, 0600); err != nil {
 		return fmt.Errorf("failed to write cookie file: %w", err)
 	}
-
-	am.logger.Info("Saved cookies to file")
-	return nil
+	// Files written by older versions were world-readable.
+	if err := os.Chmod(cookieFile, 0600); err != nil {
+		return fmt.Errorf("failed to restrict cookie file permissions: %w", err)
+	}
+	if am.secrets != nil {
+		return am.removeTokenFiles()
+	}
+	return am.saveRefreshToken()
 }
 
 // SetCookie sets a cookie
 func (am *AuthManager) SetCookie(name, value string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.cookies[name] = value
 }
 
 // GetCookie gets a cookie value
 func (am *AuthManager) GetCookie(name string) string {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	return am.cookies[name]
 }
 
 // ClearCookies clears all cookies from memory
 func (am *AuthManager) ClearCookies() {
+	am.mu.Lock()
+	defer am.mu.Unlock()
 	am.cookies = make(map[string]string)
+	am.refreshToken = ""
+	am.accessKey = nil
 }
 
 // SetCookiesFromString parses and sets cookies from a cookie string
@@ -143,7 +285,7 @@ func (am *AuthManager) SetCookiesFromString(cookieStr string) error {
 			continue
 		}
 
-		am.cookies[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		am.SetCookie(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
 	}
 
 	am.logger.Info("Set cookies from string")
@@ -171,16 +313,17 @@ func (am *AuthManager) GetUserInfo() (*UserInfo, error) {
 	}
 
 	var apiResp struct {
-		Code int      `json:"code"`
-		Data UserInfo `json:"data"`
+		Code    int      `json:"code"`
+		Message string   `json:"message"`
+		Data    UserInfo `json:"data"`
 	}
 
 	if err := json.Unmarshal(body, &apiResp); err != nil {
 		return nil, err
 	}
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API error: %d", apiResp.Code)
+	if err := CheckResponse("get user info", apiResp.Code, apiResp.Message); err != nil {
+		return nil, err
 	}
 
 	return &apiResp.Data, nil
@@ -224,7 +367,7 @@ func (am *AuthManager) GenerateQRCode() (*QRCodeInfo, error) {
 	am.logger.Debugf("QR Code API Response: %s", string(body))
 
 	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("failed to generate QR code: %d", apiResp.Code)
+		return nil, fmt.Errorf("failed to generate QR code: %w", &APIError{Code: apiResp.Code})
 	}
 
 	// Use qrcode_key if oauthKey is empty (new API format)
@@ -272,33 +415,57 @@ func (am *AuthManager) CheckQRCodeStatus(oauthKey string) (*QRCodeStatus, error)
 	return &status, nil
 }
 
-// LoginWithQRCode performs QR code login
-func (am *AuthManager) LoginWithQRCode() error {
-	// Generate QR code
-	qrInfo, err := am.GenerateQRCode()
-	if err != nil {
-		return fmt.Errorf("failed to generate QR code: %w", err)
-	}
+// maxQRCodeAttempts is how many QR codes a login generates before giving
+// up; each one is valid for about three minutes.
+const maxQRCodeAttempts = 5
+
+// qrPollInterval is the wait between two QR code status checks.
+const qrPollInterval = 2 * time.Second
 
-	fmt.Printf("Scan the QR code with the Bilibili mobile app to log in:\n")
-	fmt.Printf("QR code URL: %s\n", qrInfo.QRCodeURL)
-	fmt.Printf("Or visit: %s\n", qrInfo.URL)
+// errQRCodeExpired is returned by pollQRCode when the code expired unscanned.
+var errQRCodeExpired = errors.New("QR code expired")
 
-	// Display QR code in terminal
-	if qrInfo.QRCodeURL != "" {
-		fmt.Println("\n=== QR Code ===")
-		if err := displayQRCode(qrInfo.QRCodeURL); err != nil {
-			am.logger.Warnf("Failed to display QR code: %v", err)
-			fmt.Println("Unable to display QR code in terminal; please use the link above.")
+// LoginWithQRCode performs QR code login. An expired code is replaced with
+// a fresh one, up to maxQRCodeAttempts codes. Cancelling ctx stops the
+// wait for the scan.
+func (am *AuthManager) LoginWithQRCode(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		// Generate QR code
+		qrInfo, err := am.GenerateQRCode()
+		if err != nil {
+			return fmt.Errorf("failed to generate QR code: %w", err)
 		}
-		fmt.Println("=== QR Code ===")
-	}
 
-	fmt.Println("\nWaiting for scan...")
+		i18n.Fprintf(am.promptOutput(), "Scan the QR code with the Bilibili mobile app to log in:\n")
+		i18n.Fprintf(am.promptOutput(), "QR code URL: %s\n", qrInfo.QRCodeURL)
+		i18n.Fprintf(am.promptOutput(), "Or visit: %s\n", qrInfo.URL)
+
+		if qrInfo.QRCodeURL != "" {
+			am.showQRCode(qrInfo.QRCodeURL)
+		}
+
+		i18n.Fprintln(am.promptOutput(), "\nWaiting for scan...")
+
+		err = am.pollQRCode(ctx, qrInfo.OAuthKey)
+		if errors.Is(err, errQRCodeExpired) && attempt < maxQRCodeAttempts {
+			i18n.Fprintln(am.promptOutput(), "\nQR code expired; generating a new one...")
+			continue
+		}
+		if errors.Is(err, errQRCodeExpired) {
+			return fmt.Errorf("QR code expired %d times; please restart login", attempt)
+		}
+		return err
+	}
+}
 
-	// Poll for scan status
+// pollQRCode waits until the QR code identified by oauthKey is scanned and
+// confirmed, then stores the login cookies.
+func (am *AuthManager) pollQRCode(ctx context.Context, oauthKey string) error {
 	for {
-		status, err := am.CheckQRCodeStatus(qrInfo.OAuthKey)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		status, err := am.CheckQRCodeStatus(oauthKey)
 		if err != nil {
 			return fmt.Errorf("failed to check QR code status: %w", err)
 		}
@@ -306,38 +473,103 @@ func (am *AuthManager) LoginWithQRCode() error {
 		switch status.Data.Code {
 		case 0:
 			// Success
-			fmt.Println("Login successful!")
-
-			// Parse cookies from the redirect URL
-			if err := am.parseCookiesFromURL(status.Data.URL); err != nil {
-				return fmt.Errorf("failed to parse cookies: %w", err)
-			}
-
-			// Save cookies
-			if err := am.SaveCookies(); err != nil {
-				am.logger.Warnf("Failed to save cookies: %v", err)
-			}
-
-			return nil
+			i18n.Fprintln(am.promptOutput(), "Login successful!")
+			return am.CompleteQRCodeLogin(status)
 		case 86101:
 			// Not scanned
-			fmt.Print(".")
-			time.Sleep(2 * time.Second)
+			fmt.Fprint(am.promptOutput(), ".")
+			if err := sleepContext(ctx, qrPollInterval); err != nil {
+				return err
+			}
 			continue
 		case 86090:
 			// Scanned but not confirmed
-			fmt.Println("\nQR code scanned. Please confirm login on your phone.")
-			time.Sleep(2 * time.Second)
+			i18n.Fprintln(am.promptOutput(), "\nQR code scanned. Please confirm login on your phone.")
+			if err := sleepContext(ctx, qrPollInterval); err != nil {
+				return err
+			}
 			continue
 		case 86038:
 			// Expired
-			return fmt.Errorf("QR code expired; please restart login to get a new one")
+			return errQRCodeExpired
 		default:
 			return fmt.Errorf("login failed: %s", status.Data.Message)
 		}
 	}
 }
 
+// CompleteQRCodeLogin stores the login cookies and refresh token of a
+// confirmed QR code, for callers that poll CheckQRCodeStatus themselves.
+func (am *AuthManager) CompleteQRCodeLogin(status *QRCodeStatus) error {
+	if status.Data.Code != 0 {
+		return fmt.Errorf("QR code login not confirmed: %s", status.Data.Message)
+	}
+
+	// Parse cookies from the redirect URL
+	if err := am.parseCookiesFromURL(status.Data.URL); err != nil {
+		return fmt.Errorf("failed to parse cookies: %w", err)
+	}
+	am.SetRefreshToken(status.Data.RefreshToken)
+
+	// Save cookies
+	if err := am.SaveCookies(); err != nil {
+		am.logger.Warnf("Failed to save cookies: %v", err)
+	}
+
+	return nil
+}
+
+// SetQROutput makes QR code logins also write the code as a PNG image to
+// path, for terminals that cannot render the text version.
+func (am *AuthManager) SetQROutput(path string) {
+	am.qrOutput = path
+}
+
+// SetPromptOutput makes QR code logins print their instructions, the QR
+// code and their progress to w instead of standard error; io.Discard
+// silences them.
+func (am *AuthManager) SetPromptOutput(w io.Writer) {
+	am.prompt = w
+}
+
+// promptOutput returns the writer for login prompts.
+func (am *AuthManager) promptOutput() io.Writer {
+	if am.prompt == nil {
+		return os.Stderr
+	}
+	return am.prompt
+}
+
+// showQRCode prints the QR code for content to the terminal and, when set,
+// writes it to the QR output image.
+func (am *AuthManager) showQRCode(content string) {
+	i18n.Fprintln(am.promptOutput(), "\n=== QR Code ===")
+	if err := displayQRCode(am.promptOutput(), content); err != nil {
+		am.logger.Warnf("Failed to display QR code: %v", err)
+		i18n.Fprintln(am.promptOutput(), "Unable to display QR code in terminal; please use the link above.")
+	}
+	i18n.Fprintln(am.promptOutput(), "=== QR Code ===")
+
+	if am.qrOutput != "" {
+		if err := qrcode.WriteFile(content, qrcode.Medium, 256, am.qrOutput); err != nil {
+			am.logger.Warnf("Failed to write QR code image: %v", err)
+		} else {
+			i18n.Fprintf(am.promptOutput(), "QR code image saved to %s\n", am.qrOutput)
+		}
+	}
+}
+
+// sleepContext waits for d, or returns ctx's error when it is cancelled
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
 // parseCookiesFromURL parses cookies from redirect URL
 func (am *AuthManager) parseCookiesFromURL(redirectURL string) error {
 	u, err := url.Parse(redirectURL)
@@ -353,7 +585,7 @@ func (am *AuthManager) parseCookiesFromURL(redirectURL string) error {
 
 	for _, name := range cookieNames {
 		if value := params.Get(name); value != "" {
-			am.cookies[name] = value
+			am.SetCookie(name, value)
 		}
 	}
 
@@ -364,6 +596,8 @@ func (am *AuthManager) parseCookiesFromURL(redirectURL string) error {
 func (am *AuthManager) IsAuthenticated() bool {
 	// Check if we have essential cookies
 	essentialCookies := []string{"SESSDATA", "bili_jct"}
+	am.mu.RLock()
+	defer am.mu.RUnlock()
 	for _, cookie := range essentialCookies {
 		if am.cookies[cookie] == "" {
 			return false
@@ -374,9 +608,15 @@ func (am *AuthManager) IsAuthenticated() bool {
 
 // setHeaders sets common headers for requests
 func (am *AuthManager) setHeaders(req *http.Request) {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
 	req.Header.Set("User-Agent", am.userAgent)
-	req.Header.Set("Referer", "https://www.bilibili.com/")
+	req.Header.Set("Referer", am.referer)
 	req.Header.Set("Origin", "https://www.bilibili.com")
+	for name, value := range am.extraHeaders {
+		req.Header.Set(name, value)
+	}
 
 	// Add cookies
 	var cookieParts []string
@@ -388,12 +628,14 @@ func (am *AuthManager) setHeaders(req *http.Request) {
 	}
 }
 
-// GetHTTPClient returns an HTTP client with authentication headers
-func (am *AuthManager) GetHTTPClient() *http.Client {
+// GetHTTPClient returns the client the manager sends its requests with;
+// requests made with CreateAuthenticatedRequest carry the login.
+func (am *AuthManager) GetHTTPClient() HTTPDoer {
 	return am.client
 }
 
-// CreateAuthenticatedRequest creates an authenticated HTTP request
+// CreateAuthenticatedRequest creates an authenticated HTTP request. It is
+// safe to call from several goroutines.
 func (am *AuthManager) CreateAuthenticatedRequest(method, url string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
@@ -404,8 +646,8 @@ func (am *AuthManager) CreateAuthenticatedRequest(method, url string, body io.Re
 	return req, nil
 }
 
-// displayQRCode displays QR code in terminal
-func displayQRCode(url string) error {
+// displayQRCode draws the QR code for url as text to w.
+func displayQRCode(w io.Writer, url string) error {
 	// Generate QR code with low error correction for smaller size
 	qr, err := qrcode.New(url, qrcode.Low)
 	if err != nil {
@@ -414,7 +656,6 @@ func displayQRCode(url string) error {
 
 	// Get QR code as ASCII art with smaller size
 	ascii := qr.ToSmallString(false)
-	fmt.Print(ascii)
-
-	return nil
+	_, err = io.WriteString(w, ascii)
+	return err
 }
@@ -2,10 +2,12 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -181,6 +183,49 @@ func TestSaveAndLoadCookies(t *testing.T) {
 	}
 }
 
+// TestConcurrentAccess is meant for -race: downloads share one manager
+// while the cookie jar and refreshes update it.
+func TestConcurrentAccess(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "session")
+	am.SetCookie("bili_jct", "jct")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				req, err := am.CreateAuthenticatedRequest("GET", "https://api.bilibili.com/x/web-interface/nav", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if req.Header.Get("Cookie") == "" {
+					t.Error("request without cookies")
+				}
+				am.SetCookie(fmt.Sprintf("c%d", i), fmt.Sprint(j))
+				am.SetRefreshToken(fmt.Sprint(j))
+				_ = am.IsAuthenticated()
+				_ = am.AccessKey()
+				if err := am.SaveCookies(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	am2 := NewAuthManager(am.configDir, logrus.New())
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if got := am2.GetCookie("c7"); got != "49" {
+		t.Errorf("c7 = %q, want 49", got)
+	}
+}
+
 func TestLoadCookies_NoFile(t *testing.T) {
 	am := newTestAuthManager(t)
 	// No cookie file should exist; loading should succeed silently.
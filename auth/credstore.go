@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Credential stores, selectable with SetCredentialStore.
+const (
+	StoreFile      = "file"      // plaintext cookies.json (mode 0600)
+	StoreKeyring   = "keyring"   // OS keychain: macOS Keychain, Windows Credential Manager, libsecret
+	StoreEncrypted = "encrypted" // cookies.enc, encrypted with a passphrase
+)
+
+// EncryptedCookieFile is the file in the config directory used by the
+// encrypted credential store.
+const EncryptedCookieFile = "cookies.enc"
+
+// keyringService is the service name of keychain entries.
+const keyringService = "goBili"
+
+// secretCookies are the cookies that grant account access. Credential
+// stores other than StoreFile keep them out of cookies.json.
+var secretCookies = []string{"SESSDATA", "bili_jct"}
+
+// Keys of the tokens kept with the secret cookies. The colon keeps them
+// apart from cookie names.
+const (
+	secretRefreshToken = ":refresh_token"
+	secretAccessKey    = ":access_key"
+)
+
+// secretStore persists the secret cookies outside cookies.json.
+type secretStore interface {
+	load() (map[string]string, error)
+	save(secrets map[string]string) error
+	remove() error
+}
+
+// ValidateCredentialStore checks a credential_store value.
+func ValidateCredentialStore(kind string) error {
+	switch kind {
+	case "", StoreFile, StoreKeyring, StoreEncrypted:
+		return nil
+	default:
+		return fmt.Errorf("invalid credential store %q (want file, keyring or encrypted)", kind)
+	}
+}
+
+// SetCredentialStore selects where SESSDATA, bili_jct, the refresh token and
+// the TV access key are kept. The passphrase is only used by StoreEncrypted.
+// Call it before LoadCookies; plaintext secrets found in cookies.json,
+// refresh_token or access_key.json are moved to the new store.
+func (am *AuthManager) SetCredentialStore(kind, passphrase string) error {
+	if err := ValidateCredentialStore(kind); err != nil {
+		return err
+	}
+	switch kind {
+	case StoreKeyring:
+		// Each profile has its own config directory, and so its own entry.
+		am.secrets = &keyringStore{user: "cookies:" + am.configDir}
+	case StoreEncrypted:
+		if passphrase == "" {
+			return fmt.Errorf("the encrypted credential store needs a passphrase")
+		}
+		am.secrets = &encryptedStore{path: filepath.Join(am.configDir, EncryptedCookieFile), passphrase: passphrase}
+	default:
+		am.secrets = nil
+	}
+	return nil
+}
+
+// DeleteStoredCredentials removes the secrets kept by the credential store,
+// for logout. The plaintext cookie file is the caller's to remove.
+func (am *AuthManager) DeleteStoredCredentials() error {
+	if am.secrets == nil {
+		return nil
+	}
+	return am.secrets.remove()
+}
+
+// splitSecrets returns the cookies without the secret ones, and the secret
+// ones.
+func splitSecrets(cookies map[string]string) (public, secrets map[string]string) {
+	public = make(map[string]string, len(cookies))
+	secrets = make(map[string]string)
+	for name, value := range cookies {
+		public[name] = value
+	}
+	for _, name := range secretCookies {
+		if value, ok := public[name]; ok {
+			secrets[name] = value
+			delete(public, name)
+		}
+	}
+	return public, secrets
+}
+
+// hasSecrets reports whether cookies contains any secret cookie.
+func hasSecrets(cookies map[string]string) bool {
+	for _, name := range secretCookies {
+		if cookies[name] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// putTokenSecrets adds the refresh token and the access key to secrets.
+func (am *AuthManager) putTokenSecrets(secrets map[string]string) error {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	if am.refreshToken != "" {
+		secrets[secretRefreshToken] = am.refreshToken
+	}
+	if am.accessKey != nil {
+		data, err := json.Marshal(am.accessKey)
+		if err != nil {
+			return fmt.Errorf("failed to marshal access key: %w", err)
+		}
+		secrets[secretAccessKey] = string(data)
+	}
+	return nil
+}
+
+// takeTokenSecrets sets the refresh token and the access key found in
+// secrets, and removes them from it.
+func (am *AuthManager) takeTokenSecrets(secrets map[string]string) error {
+	if token, ok := secrets[secretRefreshToken]; ok {
+		am.SetRefreshToken(token)
+		delete(secrets, secretRefreshToken)
+	}
+	if data, ok := secrets[secretAccessKey]; ok {
+		var key AccessKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			return fmt.Errorf("failed to parse access key: %w", err)
+		}
+		am.mu.Lock()
+		am.accessKey = &key
+		am.mu.Unlock()
+		delete(secrets, secretAccessKey)
+	}
+	return nil
+}
+
+// hasTokenFiles reports whether the plaintext refresh token or access key
+// file exists.
+func (am *AuthManager) hasTokenFiles() bool {
+	for _, name := range []string{RefreshTokenFile, AccessKeyFile} {
+		if _, err := os.Stat(filepath.Join(am.configDir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removeTokenFiles removes the plaintext refresh token and access key files,
+// once the credential store holds the tokens.
+func (am *AuthManager) removeTokenFiles() error {
+	for _, name := range []string{RefreshTokenFile, AccessKeyFile} {
+		if err := os.Remove(filepath.Join(am.configDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// keyringStore keeps the secrets as one JSON entry in the OS keychain.
+type keyringStore struct {
+	user string
+}
+
+func (s *keyringStore) load() (map[string]string, error) {
+	data, err := keyring.Get(keyringService, s.user)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials from keyring: %w", err)
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal([]byte(data), &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring credentials: %w", err)
+	}
+	return secrets, nil
+}
+
+func (s *keyringStore) save(secrets map[string]string) error {
+	if len(secrets) == 0 {
+		return s.remove()
+	}
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(keyringService, s.user, string(data)); err != nil {
+		return fmt.Errorf("failed to write credentials to keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *keyringStore) remove() error {
+	if err := keyring.Delete(keyringService, s.user); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("failed to delete credentials from keyring: %w", err)
+	}
+	return nil
+}
+
+// encryptedStore keeps the secrets in a file encrypted with AES-256-GCM
+// under a key derived from a passphrase with scrypt.
+type encryptedStore struct {
+	path       string
+	passphrase string
+}
+
+// encryptedFile is the JSON layout of cookies.enc.
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// gcm returns the AEAD for salt.
+func (s *encryptedStore) gcm(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(s.passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *encryptedStore) load() (map[string]string, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted credentials: %w", err)
+	}
+	var file encryptedFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted credentials: %w", err)
+	}
+	aead, err := s.gcm(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: wrong passphrase or corrupted %s", filepath.Base(s.path))
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted credentials: %w", err)
+	}
+	return secrets, nil
+}
+
+func (s *encryptedStore) save(secrets map[string]string) error {
+	if len(secrets) == 0 {
+		return s.remove()
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	file := encryptedFile{Version: 1, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := s.gcm(file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted credentials: %w", err)
+	}
+	return nil
+}
+
+func (s *encryptedStore) remove() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove encrypted credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestSaveCookies_FileMode(t *testing.T) {
+	am := newTestAuthManager(t)
+	cookieFile := filepath.Join(am.configDir, "cookies.json")
+	// A file left world-readable by an older version is tightened.
+	if err := os.WriteFile(cookieFile, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	am.SetCookie("SESSDATA", "s")
+	if err := am.SaveCookies(); err != nil {
+		t.Fatalf("SaveCookies: %v", err)
+	}
+	info, err := os.Stat(cookieFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cookies.json mode = %o, want 600", perm)
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	am := newTestAuthManager(t)
+	if err := am.SetCredentialStore(StoreEncrypted, "correct horse"); err != nil {
+		t.Fatalf("SetCredentialStore: %v", err)
+	}
+	am.SetCookie("SESSDATA", "secret-session")
+	am.SetCookie("bili_jct", "secret-jct")
+	am.SetCookie("DedeUserID", "42")
+	if err := am.SaveCookies(); err != nil {
+		t.Fatalf("SaveCookies: %v", err)
+	}
+
+	plain, _ := os.ReadFile(filepath.Join(am.configDir, "cookies.json"))
+	enc, _ := os.ReadFile(filepath.Join(am.configDir, EncryptedCookieFile))
+	for _, secret := range []string{"secret-session", "secret-jct"} {
+		if strings.Contains(string(plain), secret) || strings.Contains(string(enc), secret) {
+			t.Errorf("%s stored in plaintext", secret)
+		}
+	}
+	if !strings.Contains(string(plain), "42") {
+		t.Error("non-secret cookies missing from cookies.json")
+	}
+
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.SetCredentialStore(StoreEncrypted, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if !am2.IsAuthenticated() || am2.GetCookie("SESSDATA") != "secret-session" {
+		t.Errorf("reloaded SESSDATA = %q", am2.GetCookie("SESSDATA"))
+	}
+
+	am3 := NewAuthManager(am.configDir, am.logger)
+	if err := am3.SetCredentialStore(StoreEncrypted, "wrong"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am3.LoadCookies(); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("LoadCookies with the wrong passphrase: err = %v", err)
+	}
+
+	if err := am2.DeleteStoredCredentials(); err != nil {
+		t.Fatalf("DeleteStoredCredentials: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(am.configDir, EncryptedCookieFile)); !os.IsNotExist(err) {
+		t.Errorf("%s not removed: %v", EncryptedCookieFile, err)
+	}
+}
+
+func TestKeyringStore_MigratesPlaintext(t *testing.T) {
+	keyring.MockInit()
+
+	// cookies.json written with the default file store.
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "old-plain")
+	am.SetCookie("bili_jct", "jct")
+	if err := am.SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.SetCredentialStore(StoreKeyring, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if am2.GetCookie("SESSDATA") != "old-plain" {
+		t.Errorf("SESSDATA = %q, want old-plain", am2.GetCookie("SESSDATA"))
+	}
+	plain, _ := os.ReadFile(filepath.Join(am.configDir, "cookies.json"))
+	if strings.Contains(string(plain), "old-plain") {
+		t.Error("SESSDATA left in cookies.json after switching to the keyring")
+	}
+	stored, err := keyring.Get(keyringService, "cookies:"+am.configDir)
+	if err != nil || !strings.Contains(stored, "old-plain") {
+		t.Errorf("keyring entry = %q, %v", stored, err)
+	}
+}
+
+func TestEncryptedStore_Tokens(t *testing.T) {
+	am := newTestAuthManager(t)
+	if err := am.SetCredentialStore(StoreEncrypted, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	am.SetCookie("SESSDATA", "s")
+	am.SetRefreshToken("refresh-secret")
+	am.accessKey = &AccessKey{Token: "access-secret", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	if err := am.saveAccessKey(); err != nil {
+		t.Fatalf("saveAccessKey: %v", err)
+	}
+	for _, name := range []string{RefreshTokenFile, AccessKeyFile} {
+		if _, err := os.Stat(filepath.Join(am.configDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s written with the encrypted store: %v", name, err)
+		}
+	}
+
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.SetCredentialStore(StoreEncrypted, "pass"); err != nil {
+		t.Fatal(err)
+	}
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if am2.RefreshToken() != "refresh-secret" {
+		t.Errorf("RefreshToken = %q, want refresh-secret", am2.RefreshToken())
+	}
+	if am2.AccessKey() != "access-secret" {
+		t.Errorf("AccessKey = %q, want access-secret", am2.AccessKey())
+	}
+	if am2.GetCookie(secretRefreshToken) != "" || am2.GetCookie(secretAccessKey) != "" {
+		t.Error("token entries loaded as cookies")
+	}
+}
+
+func TestKeyringStore_MigratesTokenFiles(t *testing.T) {
+	keyring.MockInit()
+
+	// Token files written with the default file store.
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "s")
+	am.SetRefreshToken("refresh-secret")
+	am.accessKey = &AccessKey{Token: "access-secret", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	if err := am.saveAccessKey(); err != nil {
+		t.Fatal(err)
+	}
+	if err := am.SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.SetCredentialStore(StoreKeyring, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if am2.RefreshToken() != "refresh-secret" || am2.AccessKey() != "access-secret" {
+		t.Errorf("tokens = %q, %q", am2.RefreshToken(), am2.AccessKey())
+	}
+	for _, name := range []string{RefreshTokenFile, AccessKeyFile} {
+		if _, err := os.Stat(filepath.Join(am.configDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s left after switching to the keyring: %v", name, err)
+		}
+	}
+	stored, err := keyring.Get(keyringService, "cookies:"+am.configDir)
+	if err != nil || !strings.Contains(stored, "refresh-secret") || !strings.Contains(stored, "access-secret") {
+		t.Errorf("keyring entry = %q, %v", stored, err)
+	}
+}
+
+func TestValidateCredentialStore(t *testing.T) {
+	for _, kind := range []string{"", "file", "keyring", "encrypted"} {
+		if err := ValidateCredentialStore(kind); err != nil {
+			t.Errorf("ValidateCredentialStore(%q) = %v", kind, err)
+		}
+	}
+	if err := ValidateCredentialStore("vault"); err == nil {
+		t.Error("expected an error for an unknown store")
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import "fmt"
+
+// APIError is a Bilibili API response with a non-zero code. errors.Is
+// matches APIErrors by code, so callers can test for one with
+//
+//	errors.Is(err, &auth.APIError{Code: -404})
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API error %d", e.Code)
+	}
+	return fmt.Sprintf("API error %d: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is an *APIError with the same code.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// AuthError is returned when a request needs a login that is missing or
+// no longer accepted. Err is the cause: ErrSessionExpired, or the
+// *APIError of the rejected request.
+type AuthError struct {
+	Op  string // what needed the login, e.g. "get video streams"
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("login required: %v", e.Err)
+	}
+	return fmt.Sprintf("%s: login required: %v", e.Op, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// loginCodes are the API codes of requests rejected for want of a valid
+// login: -101 not logged in, -111 CSRF token mismatch.
+var loginCodes = map[int]bool{-101: true, -111: true}
+
+// CheckResponse returns the error of an API response: nil for code 0, an
+// *AuthError wrapping the *APIError when the request needs a login, and
+// the *APIError otherwise. op names the request for AuthErrors.
+func CheckResponse(op string, code int, message string) error {
+	if code == 0 {
+		return nil
+	}
+	apiErr := &APIError{Code: code, Message: message}
+	if loginCodes[code] {
+		return &AuthError{Op: op, Err: apiErr}
+	}
+	return apiErr
+}
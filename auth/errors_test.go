@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckResponse(t *testing.T) {
+	if err := CheckResponse("get video info", 0, "0"); err != nil {
+		t.Errorf("code 0: %v", err)
+	}
+
+	err := fmt.Errorf("wrapped: %w", CheckResponse("get video info", -400, "请求错误"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != -400 || apiErr.Message != "请求错误" {
+		t.Errorf("code -400: %v, want an APIError", err)
+	}
+	if !errors.Is(err, &APIError{Code: -400}) || errors.Is(err, &APIError{Code: -404}) {
+		t.Error("errors.Is does not match APIErrors by code")
+	}
+	if err.Error() != "wrapped: API error -400: 请求错误" {
+		t.Errorf("Error() = %q", err)
+	}
+
+	err = CheckResponse("get video streams", -101, "账号未登录")
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Op != "get video streams" {
+		t.Fatalf("code -101: %v, want an AuthError", err)
+	}
+	if !errors.Is(err, &APIError{Code: -101}) {
+		t.Error("AuthError does not wrap the APIError")
+	}
+}
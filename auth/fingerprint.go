@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultReferer is the Referer sent when none is configured.
+const DefaultReferer = "https://www.bilibili.com/"
+
+// Fingerprint overrides the browser identity goBili presents. Matching the
+// browser the cookies were taken from makes risk-control rejections less
+// likely. Empty fields keep the defaults.
+type Fingerprint struct {
+	UserAgent string
+	Referer   string
+	// Headers are extra request headers, applied after the defaults so
+	// they can also replace e.g. Origin.
+	Headers map[string]string
+}
+
+// reservedHeaders are managed by goBili itself and cannot be overridden.
+var reservedHeaders = map[string]bool{
+	"Cookie":         true,
+	"Host":           true,
+	"Content-Length": true,
+	"Range":          true,
+}
+
+// ValidateFingerprint checks the extra header names and values of fp.
+func ValidateFingerprint(fp Fingerprint) error {
+	for name, value := range fp.Headers {
+		if name == "" || strings.ContainsAny(name, ": \t\r\n") {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value for header %s", name)
+		}
+		if reservedHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s cannot be overridden", http.CanonicalHeaderKey(name))
+		}
+	}
+	if strings.ContainsAny(fp.UserAgent+fp.Referer, "\r\n") {
+		return fmt.Errorf("user agent and referer must be single lines")
+	}
+	return nil
+}
+
+// SetFingerprint applies fp to all requests made by this manager,
+// including the media requests of the downloader.
+func (am *AuthManager) SetFingerprint(fp Fingerprint) error {
+	if err := ValidateFingerprint(fp); err != nil {
+		return err
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	if fp.UserAgent != "" {
+		am.userAgent = fp.UserAgent
+	}
+	if fp.Referer != "" {
+		am.referer = fp.Referer
+	}
+	am.extraHeaders = make(map[string]string, len(fp.Headers))
+	for name, value := range fp.Headers {
+		am.extraHeaders[http.CanonicalHeaderKey(name)] = value
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package auth
+
+import "testing"
+
+func TestSetFingerprint(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "session")
+	err := am.SetFingerprint(Fingerprint{
+		UserAgent: "Mozilla/5.0 (X11; Linux x86_64) Firefox/121.0",
+		Referer:   "https://space.bilibili.com/",
+		Headers:   map[string]string{"accept-language": "zh-CN,zh;q=0.9", "Origin": "https://space.bilibili.com"},
+	})
+	if err != nil {
+		t.Fatalf("SetFingerprint: %v", err)
+	}
+
+	req, err := am.CreateAuthenticatedRequest("GET", "https://api.bilibili.com/x/web-interface/nav", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"User-Agent":      "Mozilla/5.0 (X11; Linux x86_64) Firefox/121.0",
+		"Referer":         "https://space.bilibili.com/",
+		"Origin":          "https://space.bilibili.com",
+		"Accept-Language": "zh-CN,zh;q=0.9",
+		"Cookie":          "SESSDATA=session",
+	}
+	for name, value := range want {
+		if got := req.Header.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestSetFingerprint_Defaults(t *testing.T) {
+	am := newTestAuthManager(t)
+	ua := am.userAgent
+	if err := am.SetFingerprint(Fingerprint{}); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := am.CreateAuthenticatedRequest("GET", "https://www.bilibili.com/", nil)
+	if req.Header.Get("User-Agent") != ua || req.Header.Get("Referer") != DefaultReferer {
+		t.Errorf("defaults changed: %v", req.Header)
+	}
+}
+
+func TestValidateFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		fp      Fingerprint
+		wantErr bool
+	}{
+		{"empty", Fingerprint{}, false},
+		{"extra header", Fingerprint{Headers: map[string]string{"Accept-Language": "zh-CN"}}, false},
+		{"cookie", Fingerprint{Headers: map[string]string{"cookie": "SESSDATA=x"}}, true},
+		{"bad name", Fingerprint{Headers: map[string]string{"X Foo": "1"}}, true},
+		{"newline value", Fingerprint{Headers: map[string]string{"X-Foo": "1\r\nHost: x"}}, true},
+		{"newline ua", Fingerprint{UserAgent: "a\nb"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFingerprint(tt.fp); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFingerprint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
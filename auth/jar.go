@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cookieDomains are the sites whose Set-Cookie responses are recorded.
+// biligame.com serves the cross-domain step of the QR login.
+var cookieDomains = []string{"bilibili.com", "biligame.com"}
+
+// cookieJar records the cookies Bilibili sets in responses (buvid3,
+// bili_ticket, renewed SESSDATA, ...) in the AuthManager's cookie store,
+// and saves a logged-in store when they change.
+//
+// It does not add cookies to requests: setHeaders sends the store as the
+// Cookie header, which also covers clients without the jar and the headers
+// handed to external players.
+type cookieJar struct {
+	am *AuthManager
+}
+
+// CookieJar returns the jar recording server-set cookies into this
+// manager. The manager's own client uses it; other clients talking to
+// Bilibili APIs should too.
+func (am *AuthManager) CookieJar() http.CookieJar {
+	return &cookieJar{am: am}
+}
+
+// HTTPDoer sends HTTP requests. *http.Client implements it; other
+// implementations can trace or cache requests, use another TLS stack, or
+// answer with canned responses in tests.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// WithCookieJar returns client set up to record the cookies Bilibili sets
+// into this manager. An *http.Client is copied with CookieJar installed,
+// so the cookies of redirects are recorded too; for other clients only
+// those of the final response are.
+func (am *AuthManager) WithCookieJar(client HTTPDoer) HTTPDoer {
+	if c, ok := client.(*http.Client); ok {
+		withJar := *c
+		withJar.Jar = am.CookieJar()
+		return &withJar
+	}
+	return &jarDoer{base: client, jar: am.CookieJar()}
+}
+
+// jarDoer records the cookies of the responses of base in jar.
+type jarDoer struct {
+	base HTTPDoer
+	jar  http.CookieJar
+}
+
+func (d *jarDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.base.Do(req)
+	if err == nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			d.jar.SetCookies(req.URL, cookies)
+		}
+	}
+	return resp, err
+}
+
+// Cookies implements http.CookieJar; see cookieJar.
+func (j *cookieJar) Cookies(*url.URL) []*http.Cookie {
+	return nil
+}
+
+// SetCookies implements http.CookieJar.
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if !isCookieDomain(u.Hostname()) {
+		return
+	}
+
+	am := j.am
+	now := time.Now()
+	changed := false
+	am.mu.Lock()
+	for _, c := range cookies {
+		expired := c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(now))
+		old, had := am.cookies[c.Name]
+		switch {
+		case expired || c.Value == "":
+			if had {
+				delete(am.cookies, c.Name)
+				changed = true
+			}
+		case !had || old != c.Value:
+			am.cookies[c.Name] = c.Value
+			changed = true
+		}
+	}
+	am.mu.Unlock()
+
+	if !changed || am.anonymous || !am.IsAuthenticated() {
+		return
+	}
+	if err := am.saveCookies(); err != nil {
+		am.logger.Warnf("Failed to save cookies set by %s: %v", u.Hostname(), err)
+		return
+	}
+	am.logger.Debugf("Saved cookies set by %s", u.Hostname())
+}
+
+// isCookieDomain reports whether host is one of cookieDomains or below it.
+func isCookieDomain(host string) bool {
+	for _, domain := range cookieDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCookieJar_RecordsSetCookie(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "buvid3", Value: "new-buvid"})
+		http.SetCookie(w, &http.Cookie{Name: "SESSDATA", Value: "renewed"})
+		http.SetCookie(w, &http.Cookie{Name: "sid", MaxAge: -1})
+		w.Write([]byte(`{"code":0,"data":{}}`))
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = am.WithCookieJar(&http.Client{Transport: &rewriteTransport{base: server.URL}})
+	am.SetCookie("SESSDATA", "old")
+	am.SetCookie("bili_jct", "jct")
+	am.SetCookie("sid", "stale")
+
+	if _, err := am.GetUserInfo(); err != nil {
+		t.Fatalf("GetUserInfo: %v", err)
+	}
+	if got := am.GetCookie("buvid3"); got != "new-buvid" {
+		t.Errorf("buvid3 = %q, want new-buvid", got)
+	}
+	if got := am.GetCookie("SESSDATA"); got != "renewed" {
+		t.Errorf("SESSDATA = %q, want renewed", got)
+	}
+	if got := am.GetCookie("sid"); got != "" {
+		t.Errorf("sid = %q, want it deleted", got)
+	}
+
+	// The logged-in store was saved without an explicit SaveCookies.
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if got := am2.GetCookie("SESSDATA"); got != "renewed" {
+		t.Errorf("saved SESSDATA = %q, want renewed", got)
+	}
+}
+
+// doerFunc is an HTTPDoer answering from a function.
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestNewAuthManagerWithClient(t *testing.T) {
+	var requested string
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		rec := httptest.NewRecorder()
+		http.SetCookie(rec, &http.Cookie{Name: "buvid3", Value: "from-doer"})
+		rec.WriteString(`{"code":0,"data":{"mid":7,"name":"Up"}}`)
+		return rec.Result(), nil
+	})
+
+	am := NewAuthManagerWithClient(t.TempDir(), logrus.New(), client)
+	am.SetCookie("SESSDATA", "s")
+	am.SetCookie("bili_jct", "j")
+	info, err := am.GetUserInfo()
+	if err != nil {
+		t.Fatalf("GetUserInfo: %v", err)
+	}
+	if info.Mid != 7 || !strings.HasPrefix(requested, "https://api.bilibili.com/") {
+		t.Errorf("user %+v from %s", info, requested)
+	}
+	// Clients other than *http.Client still feed the cookie store.
+	if got := am.GetCookie("buvid3"); got != "from-doer" {
+		t.Errorf("buvid3 = %q, want from-doer", got)
+	}
+}
+
+func TestCookieJar_IgnoresOtherDomains(t *testing.T) {
+	am := newTestAuthManager(t)
+	jar := am.CookieJar()
+
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "evil.example.com"}, []*http.Cookie{{Name: "SESSDATA", Value: "x"}})
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "notbilibili.com"}, []*http.Cookie{{Name: "bili_jct", Value: "x"}})
+	if am.GetCookie("SESSDATA") != "" || am.GetCookie("bili_jct") != "" {
+		t.Error("cookies from other domains were recorded")
+	}
+
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "api.bilibili.com"}, []*http.Cookie{
+		{Name: "bili_ticket", Value: "t"},
+		{Name: "old", Value: "v", Expires: time.Now().Add(-time.Hour)},
+	})
+	if am.GetCookie("bili_ticket") != "t" || am.GetCookie("old") != "" {
+		t.Errorf("bili_ticket = %q, old = %q", am.GetCookie("bili_ticket"), am.GetCookie("old"))
+	}
+}
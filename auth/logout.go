@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Logout revokes the current session on Bilibili's side, so the cookies
+// stop working even where a copy of them survives. It does not touch the
+// saved cookies; callers clear those afterwards.
+func (am *AuthManager) Logout() error {
+	if am.anonymous {
+		return errAnonymous
+	}
+	csrf := am.GetCookie("bili_jct")
+	if csrf == "" {
+		return fmt.Errorf("bili_jct cookie not found")
+	}
+	if err := am.postPassport("https://passport.bilibili.com/login/exit/v2", url.Values{"biliCSRF": {csrf}}, nil); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
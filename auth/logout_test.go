@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogout(t *testing.T) {
+	var revoked bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Method != "POST" || r.URL.Path != "/login/exit/v2" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Form.Get("biliCSRF") != "jct" {
+			t.Errorf("biliCSRF = %q", r.Form.Get("biliCSRF"))
+		}
+		if c, err := r.Cookie("SESSDATA"); err != nil || c.Value != "session" {
+			t.Errorf("SESSDATA cookie not sent")
+		}
+		revoked = true
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]string{"redirectUrl": "https://www.bilibili.com"}})
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	am.SetCookie("SESSDATA", "session")
+	am.SetCookie("bili_jct", "jct")
+
+	if err := am.Logout(); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !revoked {
+		t.Error("logout endpoint was not called")
+	}
+}
+
+func TestLogout_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 2202, "message": "csrf 请求非法"})
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	am.SetCookie("SESSDATA", "session")
+	am.SetCookie("bili_jct", "wrong")
+
+	if err := am.Logout(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestLogout_NoCSRF(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "session")
+	if err := am.Logout(); err == nil {
+		t.Fatal("expected an error without bili_jct")
+	}
+}
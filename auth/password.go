@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// geetestSolverURL is a page that renders a geetest challenge in the
+// browser and shows the validate/seccode pair once it is solved.
+const geetestSolverURL = "https://kuresaru.github.io/geetest-validator/"
+
+// Captcha is a geetest challenge issued before password and SMS logins.
+type Captcha struct {
+	Token   string `json:"token"`
+	Geetest struct {
+		GT        string `json:"gt"`
+		Challenge string `json:"challenge"`
+	} `json:"geetest"`
+}
+
+// SolveURL returns a URL where the captcha can be solved in a browser,
+// for terminals that cannot show it.
+func (c *Captcha) SolveURL() string {
+	return geetestSolverURL + "?" + url.Values{
+		"gt":        {c.Geetest.GT},
+		"challenge": {c.Geetest.Challenge},
+	}.Encode()
+}
+
+// CaptchaResult is the outcome of a solved geetest challenge.
+type CaptchaResult struct {
+	Validate string
+	Seccode  string
+}
+
+// values returns the form fields that prove captcha was solved.
+func (c *Captcha) values(result CaptchaResult) url.Values {
+	return url.Values{
+		"token":     {c.Token},
+		"challenge": {c.Geetest.Challenge},
+		"validate":  {result.Validate},
+		"seccode":   {result.Seccode},
+		"source":    {"main_web"},
+	}
+}
+
+// webLoginResult is the data of a password or SMS login response.
+type webLoginResult struct {
+	Status       int    `json:"status"`
+	Message      string `json:"message"`
+	URL          string `json:"url"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// GetCaptcha requests the geetest challenge for a password or SMS login.
+func (am *AuthManager) GetCaptcha() (*Captcha, error) {
+	req, err := http.NewRequest("GET", "https://passport.bilibili.com/x/passport-login/captcha?source=main_web", nil)
+	if err != nil {
+		return nil, err
+	}
+	am.setHeaders(req)
+
+	var captcha Captcha
+	if err := am.doPassport(req, &captcha); err != nil {
+		return nil, fmt.Errorf("failed to get captcha: %w", err)
+	}
+	return &captcha, nil
+}
+
+// encryptPassword encrypts the password with the login public key, which
+// is issued together with a salt that is prepended to the password.
+func (am *AuthManager) encryptPassword(password string) (string, error) {
+	req, err := http.NewRequest("GET", "https://passport.bilibili.com/x/passport-login/web/key", nil)
+	if err != nil {
+		return "", err
+	}
+	am.setHeaders(req)
+
+	var key struct {
+		Hash string `json:"hash"`
+		Key  string `json:"key"`
+	}
+	if err := am.doPassport(req, &key); err != nil {
+		return "", fmt.Errorf("failed to get login key: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(key.Key))
+	if block == nil {
+		return "", fmt.Errorf("invalid login key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("invalid login key: %w", err)
+	}
+	pub, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("invalid login key: not an RSA key")
+	}
+
+	cipher, err := rsa.EncryptPKCS1v15(rand.Reader, pub, []byte(key.Hash+password))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(cipher), nil
+}
+
+// LoginWithPassword logs in with an account name (phone number or email)
+// and password. captcha must have been solved first (see Captcha.SolveURL).
+func (am *AuthManager) LoginWithPassword(username, password string, captcha *Captcha, result CaptchaResult) error {
+	if am.anonymous {
+		return errAnonymous
+	}
+
+	encrypted, err := am.encryptPassword(password)
+	if err != nil {
+		return err
+	}
+
+	form := captcha.values(result)
+	form.Set("username", username)
+	form.Set("password", encrypted)
+	form.Set("keep", "0")
+
+	var login webLoginResult
+	if err := am.postPassport("https://passport.bilibili.com/x/passport-login/web/login", form, &login); err != nil {
+		return fmt.Errorf("password login failed: %w", err)
+	}
+	return am.completeWebLogin(&login)
+}
+
+// SendSMSCode sends a login code to the phone number tel in country code
+// cid (86 for mainland China). The returned key identifies the code in
+// LoginWithSMS.
+func (am *AuthManager) SendSMSCode(cid int, tel string, captcha *Captcha, result CaptchaResult) (string, error) {
+	form := captcha.values(result)
+	form.Set("cid", strconv.Itoa(cid))
+	form.Set("tel", tel)
+
+	var sent struct {
+		CaptchaKey string `json:"captcha_key"`
+	}
+	if err := am.postPassport("https://passport.bilibili.com/x/passport-login/web/sms/send", form, &sent); err != nil {
+		return "", fmt.Errorf("failed to send SMS code: %w", err)
+	}
+	return sent.CaptchaKey, nil
+}
+
+// LoginWithSMS logs in with the code sent by SendSMSCode.
+func (am *AuthManager) LoginWithSMS(cid int, tel, code, captchaKey string) error {
+	if am.anonymous {
+		return errAnonymous
+	}
+
+	form := url.Values{
+		"cid":         {strconv.Itoa(cid)},
+		"tel":         {tel},
+		"code":        {code},
+		"captcha_key": {captchaKey},
+		"source":      {"main_web"},
+	}
+
+	var login webLoginResult
+	if err := am.postPassport("https://passport.bilibili.com/x/passport-login/web/login/sms", form, &login); err != nil {
+		return fmt.Errorf("SMS login failed: %w", err)
+	}
+	return am.completeWebLogin(&login)
+}
+
+// completeWebLogin stores the cookies and refresh_token of a password or
+// SMS login. The cookies themselves arrive as Set-Cookie, recorded by the
+// jar; the redirect URL repeats them.
+func (am *AuthManager) completeWebLogin(login *webLoginResult) error {
+	if login.Status != 0 {
+		// Risk control asks for an extra verification in the browser.
+		if login.URL != "" {
+			return fmt.Errorf("login needs verification (%s): open %s in a browser, or use the QR code login", login.Message, login.URL)
+		}
+		return fmt.Errorf("login failed: %s", login.Message)
+	}
+
+	if login.URL != "" {
+		if err := am.parseCookiesFromURL(login.URL); err != nil {
+			return fmt.Errorf("failed to parse cookies: %w", err)
+		}
+	}
+	if !am.IsAuthenticated() {
+		return fmt.Errorf("login response carried no session cookies")
+	}
+	am.SetRefreshToken(login.RefreshToken)
+
+	if err := am.SaveCookies(); err != nil {
+		am.logger.Warnf("Failed to save cookies: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// passwordServer fakes the passport endpoints of the password and SMS
+// logins.
+func passwordServer(t *testing.T, loginStatus int) *httptest.Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	writeJSON := func(w http.ResponseWriter, data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+	}
+	loggedIn := func(w http.ResponseWriter) {
+		if loginStatus == 0 {
+			http.SetCookie(w, &http.Cookie{Name: "SESSDATA", Value: "pw-session"})
+			http.SetCookie(w, &http.Cookie{Name: "bili_jct", Value: "pw-jct"})
+		}
+		writeJSON(w, map[string]interface{}{"status": loginStatus, "message": "", "url": "https://passport.bilibili.com/h5-app/verify", "refresh_token": "pw-refresh"})
+	}
+	checkCaptcha := func(r *http.Request) {
+		if r.Form.Get("token") != "tok" || r.Form.Get("challenge") != "chal" || r.Form.Get("validate") != "v" || r.Form.Get("seccode") != "v|jordan" {
+			t.Errorf("%s: captcha fields = %v", r.URL.Path, r.Form)
+		}
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/x/passport-login/captcha":
+			writeJSON(w, map[string]interface{}{"token": "tok", "geetest": map[string]interface{}{"gt": "gt1", "challenge": "chal"}})
+		case "/x/passport-login/web/key":
+			writeJSON(w, map[string]interface{}{"hash": "salt-", "key": pubPEM})
+		case "/x/passport-login/web/login":
+			checkCaptcha(r)
+			cipher, _ := base64.StdEncoding.DecodeString(r.Form.Get("password"))
+			plain, err := rsa.DecryptPKCS1v15(nil, key, cipher)
+			if err != nil || string(plain) != "salt-secret" || r.Form.Get("username") != "user@example.com" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": -629, "message": "账号或者密码错误"})
+				return
+			}
+			loggedIn(w)
+		case "/x/passport-login/web/sms/send":
+			checkCaptcha(r)
+			if r.Form.Get("cid") != "86" || r.Form.Get("tel") != "13800000000" {
+				t.Errorf("sms/send form = %v", r.Form)
+			}
+			writeJSON(w, map[string]interface{}{"captcha_key": "sms-key"})
+		case "/x/passport-login/web/login/sms":
+			if r.Form.Get("captcha_key") != "sms-key" || r.Form.Get("code") != "123456" {
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": 1006, "message": "验证码错误"})
+				return
+			}
+			loggedIn(w)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestLoginWithPassword(t *testing.T) {
+	server := passwordServer(t, 0)
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}, Jar: am.CookieJar()}
+
+	captcha, err := am.GetCaptcha()
+	if err != nil {
+		t.Fatalf("GetCaptcha: %v", err)
+	}
+	if u := captcha.SolveURL(); !strings.Contains(u, "gt=gt1") || !strings.Contains(u, "challenge=chal") {
+		t.Errorf("SolveURL = %s", u)
+	}
+
+	result := CaptchaResult{Validate: "v", Seccode: "v|jordan"}
+	if err := am.LoginWithPassword("user@example.com", "wrong", captcha, result); err == nil {
+		t.Error("expected an error for a wrong password")
+	}
+	if err := am.LoginWithPassword("user@example.com", "secret", captcha, result); err != nil {
+		t.Fatalf("LoginWithPassword: %v", err)
+	}
+	if am.GetCookie("SESSDATA") != "pw-session" || am.RefreshToken() != "pw-refresh" {
+		t.Errorf("SESSDATA = %q, refresh token = %q", am.GetCookie("SESSDATA"), am.RefreshToken())
+	}
+}
+
+func TestLoginWithPassword_NeedsVerification(t *testing.T) {
+	server := passwordServer(t, 2)
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}, Jar: am.CookieJar()}
+
+	captcha, err := am.GetCaptcha()
+	if err != nil {
+		t.Fatalf("GetCaptcha: %v", err)
+	}
+	err = am.LoginWithPassword("user@example.com", "secret", captcha, CaptchaResult{Validate: "v", Seccode: "v|jordan"})
+	if err == nil || !strings.Contains(err.Error(), "h5-app/verify") {
+		t.Errorf("err = %v, want the verification URL", err)
+	}
+	if am.IsAuthenticated() {
+		t.Error("cookies kept although verification is pending")
+	}
+}
+
+func TestLoginWithSMS(t *testing.T) {
+	server := passwordServer(t, 0)
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}, Jar: am.CookieJar()}
+
+	captcha, err := am.GetCaptcha()
+	if err != nil {
+		t.Fatalf("GetCaptcha: %v", err)
+	}
+	key, err := am.SendSMSCode(86, "13800000000", captcha, CaptchaResult{Validate: "v", Seccode: "v|jordan"})
+	if err != nil {
+		t.Fatalf("SendSMSCode: %v", err)
+	}
+	if err := am.LoginWithSMS(86, "13800000000", "123456", key); err != nil {
+		t.Fatalf("LoginWithSMS: %v", err)
+	}
+	if !am.IsAuthenticated() {
+		t.Error("not authenticated after SMS login")
+	}
+}
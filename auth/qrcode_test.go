@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginWithQRCode_RegeneratesExpiredCode(t *testing.T) {
+	var generated int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/x/passport-login/web/qrcode/generate":
+			generated++
+			key := "key1"
+			if generated > 1 {
+				key = "key2"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"url": "https://passport.bilibili.com/h5-app/passport/login/scan?qrcode_key=" + key, "qrcode_key": key},
+			})
+		case "/x/passport-login/web/qrcode/poll":
+			data := map[string]interface{}{"code": 86038, "message": "二维码已失效"}
+			if r.URL.Query().Get("qrcode_key") == "key2" {
+				data = map[string]interface{}{
+					"code":          0,
+					"url":           "https://passport.biligame.com/crossDomain?SESSDATA=qr-session&bili_jct=qr-jct&DedeUserID=1",
+					"refresh_token": "qr-refresh",
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": data})
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	png := filepath.Join(t.TempDir(), "qr.png")
+	am.SetQROutput(png)
+	var prompt bytes.Buffer
+	am.SetPromptOutput(&prompt)
+
+	if err := am.LoginWithQRCode(context.Background()); err != nil {
+		t.Fatalf("LoginWithQRCode: %v", err)
+	}
+	if generated != 2 {
+		t.Errorf("generated %d QR codes, want 2", generated)
+	}
+	if am.GetCookie("SESSDATA") != "qr-session" || am.RefreshToken() != "qr-refresh" {
+		t.Errorf("SESSDATA = %q, refresh token = %q", am.GetCookie("SESSDATA"), am.RefreshToken())
+	}
+
+	for _, want := range []string{"Waiting for scan", "=== QR Code ===\n█", "QR code expired; generating a new one"} {
+		if !strings.Contains(prompt.String(), want) {
+			t.Errorf("login prompt lacks %q:\n%s", want, prompt.String())
+		}
+	}
+
+	data, err := os.ReadFile(png)
+	if err != nil {
+		t.Fatalf("QR code image: %v", err)
+	}
+	if len(data) < 8 || string(data[1:4]) != "PNG" {
+		t.Error("QR code image is not a PNG")
+	}
+}
+
+func TestLoginWithQRCode_GivesUp(t *testing.T) {
+	var generated int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/passport-login/web/qrcode/generate" {
+			generated++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"url": "https://example.com/qr", "qrcode_key": "k"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{"code": 86038}})
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	am.SetPromptOutput(io.Discard)
+
+	if err := am.LoginWithQRCode(context.Background()); err == nil {
+		t.Fatal("expected an error after repeated expiry")
+	}
+	if generated != maxQRCodeAttempts {
+		t.Errorf("generated %d QR codes, want %d", generated, maxQRCodeAttempts)
+	}
+}
+
+func TestCompleteQRCodeLogin(t *testing.T) {
+	am := newTestAuthManager(t)
+
+	var pending QRCodeStatus
+	pending.Data.Code = 86101
+	if err := am.CompleteQRCodeLogin(&pending); err == nil {
+		t.Error("expected an error for an unconfirmed code")
+	}
+
+	var confirmed QRCodeStatus
+	confirmed.Data.URL = "https://passport.biligame.com/crossDomain?SESSDATA=s&bili_jct=j&DedeUserID=1"
+	confirmed.Data.RefreshToken = "r"
+	if err := am.CompleteQRCodeLogin(&confirmed); err != nil {
+		t.Fatalf("CompleteQRCodeLogin: %v", err)
+	}
+	if !am.IsAuthenticated() || am.RefreshToken() != "r" {
+		t.Errorf("authenticated = %v, refresh token = %q", am.IsAuthenticated(), am.RefreshToken())
+	}
+}
+
+func TestLoginWithQRCode_Cancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/passport-login/web/qrcode/generate" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"url": "https://example.com/qr", "qrcode_key": "k"},
+			})
+			return
+		}
+		// Never scanned.
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{"code": 86101}})
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	am.SetPromptOutput(io.Discard)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := am.LoginWithQRCode(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("LoginWithQRCode err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= qrPollInterval {
+		t.Errorf("LoginWithQRCode returned after %v, want before the next poll", elapsed)
+	}
+}
+
+func TestPromptOutput_DefaultsToStderr(t *testing.T) {
+	am := newTestAuthManager(t)
+	if am.promptOutput() != os.Stderr {
+		t.Error("login prompts do not default to standard error")
+	}
+}
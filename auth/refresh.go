@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RefreshTokenFile is the file in the config directory holding the
+// refresh_token of the QR login, next to cookies.json. It is kept out of
+// the cookie map so it is never sent with requests.
+const RefreshTokenFile = "refresh_token"
+
+// correspondPublicKey is the RSA key the web client encrypts the
+// correspondPath with.
+const correspondPublicKey = `-----BEGIN PUBLIC KEY-----
+MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDLgd2OAkcGVtoE3ThUREbio0Eg
+Uc/prcajMKXvkCKFCWhJYJcLkcM2DKKcSeFpD/j6Boy538YXnR6VhcuUJOhH2x71
+nzPjfdTcqMz7djHum0qSZA0AyCBDABUqCrfNgCiJ00Ra7GmRj+YCK1NJEuewlb40
+JNrRuoEUXpabUzGB8QIDAQAB
+-----END PUBLIC KEY-----`
+
+// correspondKey is the parsed correspondPublicKey; tests replace it.
+var correspondKey = mustParsePublicKey(correspondPublicKey)
+
+// refreshCSRFPattern extracts refresh_csrf from the correspond page.
+var refreshCSRFPattern = regexp.MustCompile(`<div id="1-name">([^<]+)</div>`)
+
+// ErrNoRefreshToken is returned by RefreshCookies when the login has no
+// refresh_token, e.g. cookies imported with --cookie-file.
+var ErrNoRefreshToken = errors.New("no refresh token stored: log in with the QR code to enable cookie refresh")
+
+func mustParsePublicKey(pemKey string) *rsa.PublicKey {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		panic("auth: invalid correspond public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		panic(fmt.Sprintf("auth: invalid correspond public key: %v", err))
+	}
+	return key.(*rsa.PublicKey)
+}
+
+// RefreshToken returns the refresh_token of the current login, if known.
+func (am *AuthManager) RefreshToken() string {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return am.refreshToken
+}
+
+// SetRefreshToken sets the refresh_token saved with the cookies.
+func (am *AuthManager) SetRefreshToken(token string) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	am.refreshToken = token
+}
+
+// loadRefreshToken reads the refresh token file, if any.
+func (am *AuthManager) loadRefreshToken() error {
+	data, err := os.ReadFile(filepath.Join(am.configDir, RefreshTokenFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read refresh token: %w", err)
+	}
+	am.SetRefreshToken(strings.TrimSpace(string(data)))
+	return nil
+}
+
+// saveRefreshToken writes the refresh token file, or removes it when there
+// is no token.
+func (am *AuthManager) saveRefreshToken() error {
+	path := filepath.Join(am.configDir, RefreshTokenFile)
+	token := am.RefreshToken()
+	if token == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove refresh token: %w", err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write refresh token: %w", err)
+	}
+	return nil
+}
+
+// CookieNeedsRefresh asks Bilibili whether the login cookies are due for
+// renewal. It also returns the server timestamp (ms) the refresh needs.
+func (am *AuthManager) CookieNeedsRefresh() (bool, int64, error) {
+	req, err := http.NewRequest("GET", "https://passport.bilibili.com/x/passport-login/web/cookie/info", nil)
+	if err != nil {
+		return false, 0, err
+	}
+	q := req.URL.Query()
+	q.Set("csrf", am.GetCookie("bili_jct"))
+	req.URL.RawQuery = q.Encode()
+	am.setHeaders(req)
+
+	var info struct {
+		Refresh   bool  `json:"refresh"`
+		Timestamp int64 `json:"timestamp"`
+	}
+	if err := am.doPassport(req, &info); err != nil {
+		return false, 0, fmt.Errorf("failed to check cookie status: %w", err)
+	}
+	return info.Refresh, info.Timestamp, nil
+}
+
+// correspondPath returns the RSA-OAEP encryption of "refresh_<timestamp>"
+// that names the page carrying refresh_csrf.
+func correspondPath(timestamp int64) (string, error) {
+	cipher, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, correspondKey, []byte("refresh_"+strconv.FormatInt(timestamp, 10)), nil)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(cipher), nil
+}
+
+// refreshCSRF fetches the one-time refresh_csrf for timestamp.
+func (am *AuthManager) refreshCSRF(timestamp int64) (string, error) {
+	path, err := correspondPath(timestamp)
+	if err != nil {
+		return "", fmt.Errorf("failed to build correspond path: %w", err)
+	}
+	req, err := http.NewRequest("GET", "https://www.bilibili.com/correspond/1/"+path, nil)
+	if err != nil {
+		return "", err
+	}
+	am.setHeaders(req)
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	m := refreshCSRFPattern.FindSubmatch(body)
+	if m == nil {
+		return "", fmt.Errorf("refresh_csrf not found (HTTP %d)", resp.StatusCode)
+	}
+	return strings.TrimSpace(string(m[1])), nil
+}
+
+// RefreshCookies renews the login cookies with the stored refresh_token:
+// it fetches refresh_csrf from the correspond page, exchanges the old
+// cookies for new ones, confirms the refresh so the old session is
+// retired, and saves the result. timestamp is the one CookieNeedsRefresh
+// returned.
+func (am *AuthManager) RefreshCookies(timestamp int64) error {
+	if am.anonymous {
+		return errAnonymous
+	}
+	oldToken := am.RefreshToken()
+	if oldToken == "" {
+		return ErrNoRefreshToken
+	}
+
+	csrf, err := am.refreshCSRF(timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to get refresh_csrf: %w", err)
+	}
+
+	form := url.Values{
+		"csrf":          {am.GetCookie("bili_jct")},
+		"refresh_csrf":  {csrf},
+		"source":        {"main_web"},
+		"refresh_token": {oldToken},
+	}
+	var refreshed struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The new cookies arrive as Set-Cookie and are recorded by the jar.
+	if err := am.postPassport("https://passport.bilibili.com/x/passport-login/web/cookie/refresh", form, &refreshed); err != nil {
+		return fmt.Errorf("failed to refresh cookies: %w", err)
+	}
+	am.SetRefreshToken(refreshed.RefreshToken)
+
+	// Retire the old session; the new cookies work either way.
+	confirm := url.Values{"csrf": {am.GetCookie("bili_jct")}, "refresh_token": {oldToken}}
+	if err := am.postPassport("https://passport.bilibili.com/x/passport-login/web/confirm/refresh", confirm, nil); err != nil {
+		am.logger.Warnf("Failed to confirm cookie refresh: %v", err)
+	}
+
+	return am.SaveCookies()
+}
+
+// RefreshCookiesIfNeeded renews the login cookies when Bilibili reports
+// them due, and reports whether it did. Logins without a refresh_token are
+// left alone.
+func (am *AuthManager) RefreshCookiesIfNeeded() (bool, error) {
+	if am.anonymous || am.RefreshToken() == "" || !am.IsAuthenticated() {
+		return false, nil
+	}
+	needed, timestamp, err := am.CookieNeedsRefresh()
+	if err != nil || !needed {
+		return false, err
+	}
+	if err := am.RefreshCookies(timestamp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// postPassport posts form to a passport endpoint and decodes its data into
+// out (when non-nil).
+func (am *AuthManager) postPassport(endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	am.setHeaders(req)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return am.doPassport(req, out)
+}
+
+// doPassport sends req and decodes the data of the passport response into out.
+func (am *AuthManager) doPassport(req *http.Request, out interface{}) error {
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodePassport(resp.Body, out)
+}
+
+// decodePassport decodes a {code, message, data} response.
+func decodePassport(r io.Reader, out interface{}) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var apiResp struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return err
+	}
+	if apiResp.Code != 0 {
+		return &APIError{Code: apiResp.Code, Message: apiResp.Message}
+	}
+	if out == nil || len(apiResp.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(apiResp.Data, out)
+}
@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// refreshServer fakes the passport endpoints of the cookie refresh flow.
+// confirmed records the refresh_token sent to confirm/refresh.
+func refreshServer(t *testing.T, key *rsa.PrivateKey, needRefresh bool, confirmed *string) *httptest.Server {
+	t.Helper()
+	writeJSON := func(w http.ResponseWriter, data interface{}) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "0", "data": data})
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/x/passport-login/web/cookie/info":
+			if r.URL.Query().Get("csrf") != "old-jct" {
+				t.Errorf("cookie/info csrf = %q", r.URL.Query().Get("csrf"))
+			}
+			writeJSON(w, map[string]interface{}{"refresh": needRefresh, "timestamp": 1700000000000})
+		case strings.HasPrefix(r.URL.Path, "/correspond/1/"):
+			cipher, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/correspond/1/"))
+			if err != nil {
+				t.Errorf("correspond path is not hex: %v", err)
+			}
+			plain, err := rsa.DecryptOAEP(sha256.New(), nil, key, cipher, nil)
+			if err != nil || string(plain) != "refresh_1700000000000" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, `<html><div id="1-name">refresh-csrf</div></html>`)
+		case r.URL.Path == "/x/passport-login/web/cookie/refresh":
+			r.ParseForm()
+			if r.Form.Get("refresh_csrf") != "refresh-csrf" || r.Form.Get("refresh_token") != "old-token" || r.Form.Get("csrf") != "old-jct" {
+				t.Errorf("cookie/refresh form = %v", r.Form)
+			}
+			http.SetCookie(w, &http.Cookie{Name: "SESSDATA", Value: "new-session"})
+			http.SetCookie(w, &http.Cookie{Name: "bili_jct", Value: "new-jct"})
+			writeJSON(w, map[string]interface{}{"status": 0, "refresh_token": "new-token"})
+		case r.URL.Path == "/x/passport-login/web/confirm/refresh":
+			r.ParseForm()
+			if r.Form.Get("csrf") != "new-jct" {
+				t.Errorf("confirm/refresh csrf = %q, want new-jct", r.Form.Get("csrf"))
+			}
+			*confirmed = r.Form.Get("refresh_token")
+			writeJSON(w, nil)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func withCorrespondKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := correspondKey
+	correspondKey = &key.PublicKey
+	t.Cleanup(func() { correspondKey = saved })
+	return key
+}
+
+func TestRefreshCookiesIfNeeded(t *testing.T) {
+	key := withCorrespondKey(t)
+	var confirmed string
+	server := refreshServer(t, key, true, &confirmed)
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}, Jar: am.CookieJar()}
+	am.SetCookie("SESSDATA", "old-session")
+	am.SetCookie("bili_jct", "old-jct")
+	am.SetCookie("DedeUserID", "1")
+	am.SetRefreshToken("old-token")
+
+	refreshed, err := am.RefreshCookiesIfNeeded()
+	if err != nil {
+		t.Fatalf("RefreshCookiesIfNeeded: %v", err)
+	}
+	if !refreshed {
+		t.Fatal("cookies were not refreshed")
+	}
+	if got := am.GetCookie("SESSDATA"); got != "new-session" {
+		t.Errorf("SESSDATA = %q, want new-session", got)
+	}
+	if got := am.RefreshToken(); got != "new-token" {
+		t.Errorf("RefreshToken = %q, want new-token", got)
+	}
+	if confirmed != "old-token" {
+		t.Errorf("confirmed refresh_token = %q, want old-token", confirmed)
+	}
+
+	// The new cookies and token are saved for the next run.
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if got := am2.GetCookie("bili_jct"); got != "new-jct" {
+		t.Errorf("saved bili_jct = %q, want new-jct", got)
+	}
+	if got := am2.RefreshToken(); got != "new-token" {
+		t.Errorf("saved refresh token = %q, want new-token", got)
+	}
+}
+
+func TestRefreshCookiesIfNeeded_NotDue(t *testing.T) {
+	key := withCorrespondKey(t)
+	var confirmed string
+	server := refreshServer(t, key, false, &confirmed)
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}, Jar: am.CookieJar()}
+	am.SetCookie("SESSDATA", "old-session")
+	am.SetCookie("bili_jct", "old-jct")
+	am.SetRefreshToken("old-token")
+
+	refreshed, err := am.RefreshCookiesIfNeeded()
+	if err != nil || refreshed {
+		t.Fatalf("RefreshCookiesIfNeeded = %v, %v; want false, nil", refreshed, err)
+	}
+	if got := am.GetCookie("SESSDATA"); got != "old-session" {
+		t.Errorf("SESSDATA = %q, want old-session", got)
+	}
+}
+
+func TestRefreshCookiesIfNeeded_NoToken(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: "http://127.0.0.1:0"}}
+	am.SetCookie("SESSDATA", "imported")
+
+	refreshed, err := am.RefreshCookiesIfNeeded()
+	if err != nil || refreshed {
+		t.Fatalf("RefreshCookiesIfNeeded = %v, %v; want false, nil", refreshed, err)
+	}
+	if err := am.RefreshCookies(0); err != ErrNoRefreshToken {
+		t.Errorf("RefreshCookies err = %v, want ErrNoRefreshToken", err)
+	}
+}
+
+func TestSaveCookies_RefreshTokenFile(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.SetCookie("SESSDATA", "s")
+	am.SetRefreshToken("token")
+	if err := am.SaveCookies(); err != nil {
+		t.Fatalf("SaveCookies: %v", err)
+	}
+
+	path := filepath.Join(am.configDir, RefreshTokenFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("refresh token file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("refresh token file mode = %o, want 600", perm)
+	}
+	data, _ := os.ReadFile(filepath.Join(am.configDir, "cookies.json"))
+	if strings.Contains(string(data), "token") {
+		t.Error("refresh token leaked into cookies.json")
+	}
+
+	am.SetRefreshToken("")
+	if err := am.SaveCookies(); err != nil {
+		t.Fatalf("SaveCookies: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("refresh token file not removed: %v", err)
+	}
+}
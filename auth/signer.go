@@ -0,0 +1,17 @@
+package auth
+
+import "net/http"
+
+// RequestSigner adds the headers Bilibili expects of a client to a
+// request: the login cookies, Referer and User-Agent. Its CDNs answer
+// media requests without them with 403 Forbidden. *AuthManager implements
+// it, anonymous managers with a guest identity.
+type RequestSigner interface {
+	SignRequest(req *http.Request)
+}
+
+// SignRequest sets the headers of the manager's login on req. It is safe
+// to call from several goroutines.
+func (am *AuthManager) SignRequest(req *http.Request) {
+	am.setHeaders(req)
+}
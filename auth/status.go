@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccountStatus is the login state reported by the nav endpoint.
+type AccountStatus struct {
+	IsLogin   bool    `json:"isLogin"`
+	Mid       int64   `json:"mid"`
+	Name      string  `json:"uname"`
+	Coins     float64 `json:"money"`
+	VipType   int     `json:"vipType"`   // 0 none, 1 monthly, 2 annual
+	VipStatus int     `json:"vipStatus"` // 1 active
+	VipDueMs  int64   `json:"vipDueDate"`
+	LevelInfo struct {
+		Level   int             `json:"current_level"`
+		Exp     int             `json:"current_exp"`
+		NextExp json.RawMessage `json:"next_exp"` // a number, or "--" at the top level
+	} `json:"level_info"`
+}
+
+// VipActive reports whether the account has an active VIP membership.
+func (s *AccountStatus) VipActive() bool {
+	return s.VipStatus == 1 && s.VipType > 0
+}
+
+// VipTier returns a readable name for the VIP membership.
+func (s *AccountStatus) VipTier() string {
+	if !s.VipActive() {
+		return "none"
+	}
+	switch s.VipType {
+	case 1:
+		return "VIP (monthly)"
+	case 2:
+		return "VIP (annual)"
+	default:
+		return fmt.Sprintf("VIP (type %d)", s.VipType)
+	}
+}
+
+// VipExpiry returns when the VIP membership ends, if known.
+func (s *AccountStatus) VipExpiry() (time.Time, bool) {
+	if s.VipDueMs <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(s.VipDueMs), true
+}
+
+// NextLevelExp returns the experience needed for the next level, or -1 at
+// the top level.
+func (s *AccountStatus) NextLevelExp() int {
+	var next int
+	if err := json.Unmarshal(s.LevelInfo.NextExp, &next); err != nil {
+		return -1
+	}
+	return next
+}
+
+// GetAccountStatus fetches the login state, VIP membership, level and coin
+// balance of the current cookies. Expired or missing cookies are reported
+// with IsLogin false rather than as an error.
+func (am *AuthManager) GetAccountStatus() (*AccountStatus, error) {
+	req, err := http.NewRequest("GET", "https://api.bilibili.com/x/web-interface/nav", nil)
+	if err != nil {
+		return nil, err
+	}
+	am.setHeaders(req)
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		Code    int           `json:"code"`
+		Message string        `json:"message"`
+		Data    AccountStatus `json:"data"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, err
+	}
+
+	// -101: not logged in. The data block still parses, with isLogin false.
+	if apiResp.Code != 0 && apiResp.Code != -101 {
+		return nil, &APIError{Code: apiResp.Code, Message: apiResp.Message}
+	}
+	return &apiResp.Data, nil
+}
+
+// ErrSessionExpired is the cause of the *AuthError returned by CheckSession
+// when Bilibili no longer accepts the saved cookies.
+var ErrSessionExpired = errors.New("login session has expired")
+
+// CheckSession validates the saved cookies against the nav endpoint and
+// returns an *AuthError wrapping ErrSessionExpired when they are no longer
+// accepted.
+func (am *AuthManager) CheckSession() (*AccountStatus, error) {
+	status, err := am.GetAccountStatus()
+	if err != nil {
+		return nil, err
+	}
+	if !status.IsLogin {
+		return status, &AuthError{Op: "check session", Err: ErrSessionExpired}
+	}
+	return status, nil
+}
+
+// SessionExpiry estimates when the login expires from the SESSDATA cookie,
+// whose value embeds the expiry as a Unix timestamp
+// ("<token>,<expiry>,<checksum>", usually URL-encoded).
+func (am *AuthManager) SessionExpiry() (time.Time, bool) {
+	value := am.GetCookie("SESSDATA")
+	if decoded, err := url.QueryUnescape(value); err == nil {
+		value = decoded
+	}
+	parts := strings.Split(value, ",")
+	if len(parts) < 2 {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
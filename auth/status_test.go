@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetAccountStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x/web-interface/nav" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"code":0,"data":{"isLogin":true,"mid":42,"uname":"Tester","money":12.5,
+			"vipType":2,"vipStatus":1,"vipDueDate":1893456000000,
+			"level_info":{"current_level":5,"current_exp":12000,"next_exp":28800}}}`)
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+
+	status, err := am.GetAccountStatus()
+	if err != nil {
+		t.Fatalf("GetAccountStatus: %v", err)
+	}
+	if !status.IsLogin || status.Mid != 42 || status.Name != "Tester" || status.Coins != 12.5 {
+		t.Errorf("status = %+v", status)
+	}
+	if got := status.VipTier(); got != "VIP (annual)" {
+		t.Errorf("VipTier = %q, want VIP (annual)", got)
+	}
+	if due, ok := status.VipExpiry(); !ok || due.Year() != 2030 {
+		t.Errorf("VipExpiry = %v, %v", due, ok)
+	}
+	if got := status.NextLevelExp(); got != 28800 {
+		t.Errorf("NextLevelExp = %d, want 28800", got)
+	}
+}
+
+func TestGetAccountStatus_LoggedOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"code":-101,"message":"账号未登录","data":{"isLogin":false,
+			"level_info":{"current_level":6,"current_exp":30000,"next_exp":"--"}}}`)
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+
+	status, err := am.GetAccountStatus()
+	if err != nil {
+		t.Fatalf("GetAccountStatus: %v", err)
+	}
+	if status.IsLogin {
+		t.Error("IsLogin = true for code -101")
+	}
+	if got := status.VipTier(); got != "none" {
+		t.Errorf("VipTier = %q, want none", got)
+	}
+	if got := status.NextLevelExp(); got != -1 {
+		t.Errorf("NextLevelExp = %d, want -1 at the top level", got)
+	}
+	_, err = am.CheckSession()
+	var authErr *AuthError
+	if !errors.Is(err, ErrSessionExpired) || !errors.As(err, &authErr) {
+		t.Errorf("CheckSession err = %v, want an AuthError for ErrSessionExpired", err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessdata string
+		want     int64
+		ok       bool
+	}{
+		{"encoded", "5a1b2c3d%2C1735689600%2Cab12c%2A41", 1735689600, true},
+		{"plain", "5a1b2c3d,1735689600,ab12c*41", 1735689600, true},
+		{"opaque", "abcdef", 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestAuthManager(t)
+			am.SetCookie("SESSDATA", tt.sessdata)
+			got, ok := am.SessionExpiry()
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Equal(time.Unix(tt.want, 0)) {
+				t.Errorf("expiry = %v, want %v", got, time.Unix(tt.want, 0))
+			}
+		})
+	}
+}
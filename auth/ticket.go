@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// biliTicketKey is the HMAC key the web client signs GenWebTicket with.
+const biliTicketKey = "XgwSnGZ1p"
+
+// biliTicketRenewBefore renews bili_ticket this long before it expires.
+const biliTicketRenewBefore = time.Hour
+
+// biliTicketSign returns the hexsign of GenWebTicket for ts.
+func biliTicketSign(ts int64) string {
+	mac := hmac.New(sha256.New, []byte(biliTicketKey))
+	mac.Write([]byte("ts" + strconv.FormatInt(ts, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// biliTicketValid reports whether the bili_ticket cookie is set and not
+// about to expire.
+func (am *AuthManager) biliTicketValid(now time.Time) bool {
+	if am.GetCookie("bili_ticket") == "" {
+		return false
+	}
+	expires, err := strconv.ParseInt(am.GetCookie("bili_ticket_expires"), 10, 64)
+	return err == nil && now.Add(biliTicketRenewBefore).Before(time.Unix(expires, 0))
+}
+
+// EnsureBiliTicket makes sure the bili_ticket cookie is present and fresh,
+// requesting a new one when needed. Some APIs answer -352 (risk control)
+// to requests without it. The ticket lasts three days, so one call before
+// a run is enough.
+func (am *AuthManager) EnsureBiliTicket() error {
+	now := time.Now()
+	if am.biliTicketValid(now) {
+		return nil
+	}
+	return am.genBiliTicket(now)
+}
+
+// genBiliTicket requests a bili_ticket and stores it with its expiry.
+func (am *AuthManager) genBiliTicket(now time.Time) error {
+	ts := now.Unix()
+	params := url.Values{
+		"key_id":      {"ec02"},
+		"hexsign":     {biliTicketSign(ts)},
+		"context[ts]": {strconv.FormatInt(ts, 10)},
+		"csrf":        {am.GetCookie("bili_jct")},
+	}
+	req, err := http.NewRequest("POST", "https://api.bilibili.com/bapis/bilibili.api.ticket.v1.Ticket/GenWebTicket?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	am.setHeaders(req)
+
+	var ticket struct {
+		Ticket    string `json:"ticket"`
+		CreatedAt int64  `json:"created_at"`
+		TTL       int64  `json:"ttl"`
+	}
+	if err := am.doPassport(req, &ticket); err != nil {
+		return fmt.Errorf("failed to get bili_ticket: %w", err)
+	}
+	if ticket.Ticket == "" {
+		return fmt.Errorf("failed to get bili_ticket: empty ticket")
+	}
+
+	am.SetCookie("bili_ticket", ticket.Ticket)
+	am.SetCookie("bili_ticket_expires", strconv.FormatInt(ticket.CreatedAt+ticket.TTL, 10))
+	am.logger.Debugf("Obtained bili_ticket, valid until %s", time.Unix(ticket.CreatedAt+ticket.TTL, 0).Format(time.RFC3339))
+
+	// Keep it for the next run; anonymous sessions have nothing to save.
+	if !am.anonymous && am.IsAuthenticated() {
+		if err := am.saveCookies(); err != nil {
+			am.logger.Debugf("Failed to save bili_ticket: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBiliTicketSign(t *testing.T) {
+	want := "bb79f0d980ffbb51597aa1a3e8b55603025cc1322ac766f4c1a98852e6182514"
+	if got := biliTicketSign(1700000000); got != want {
+		t.Errorf("biliTicketSign = %s, want %s", got, want)
+	}
+}
+
+func TestEnsureBiliTicket(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/bapis/bilibili.api.ticket.v1.Ticket/GenWebTicket" {
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		ts, _ := strconv.ParseInt(q.Get("context[ts]"), 10, 64)
+		if q.Get("key_id") != "ec02" || q.Get("hexsign") != biliTicketSign(ts) || q.Get("csrf") != "jct" {
+			t.Errorf("query = %v", q)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"ticket": "eyJ.ticket", "created_at": time.Now().Unix(), "ttl": 259200},
+		})
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	am.SetCookie("SESSDATA", "s")
+	am.SetCookie("bili_jct", "jct")
+
+	if err := am.EnsureBiliTicket(); err != nil {
+		t.Fatalf("EnsureBiliTicket: %v", err)
+	}
+	if got := am.GetCookie("bili_ticket"); got != "eyJ.ticket" {
+		t.Errorf("bili_ticket = %q", got)
+	}
+
+	// A fresh ticket is reused, also by the next run.
+	if err := am.EnsureBiliTicket(); err != nil {
+		t.Fatalf("EnsureBiliTicket: %v", err)
+	}
+	am2 := NewAuthManager(am.configDir, am.logger)
+	am2.client = am.client
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatal(err)
+	}
+	if err := am2.EnsureBiliTicket(); err != nil {
+		t.Fatalf("EnsureBiliTicket: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("GenWebTicket called %d times, want 1", calls)
+	}
+
+	// One about to expire is renewed.
+	am2.SetCookie("bili_ticket_expires", strconv.FormatInt(time.Now().Add(10*time.Minute).Unix(), 10))
+	if err := am2.EnsureBiliTicket(); err != nil {
+		t.Fatalf("EnsureBiliTicket: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("GenWebTicket called %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/i18n"
+)
+
+// AccessKeyFile is the file in the config directory holding the access_key
+// of a TV login.
+const AccessKeyFile = "access_key.json"
+
+// TV client credentials. App API requests are signed with the secret
+// instead of carrying cookies.
+const (
+	tvAppKey = "4409e2ce8ffd12b8"
+	tvAppSec = "59b43e04ad6965f34319062b478f83dd"
+)
+
+// AccessKey is the app-side login token of a TV login.
+type AccessKey struct {
+	Token        string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Mid          int64  `json:"mid"`
+	ExpiresAt    int64  `json:"expires_at"` // Unix seconds
+}
+
+// Valid reports whether the key is set and not expired.
+func (k *AccessKey) Valid() bool {
+	return k != nil && k.Token != "" && time.Now().Unix() < k.ExpiresAt
+}
+
+// AccessKey returns the access_key of a TV login, or "" when there is none
+// or it has expired.
+func (am *AuthManager) AccessKey() string {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	if !am.accessKey.Valid() {
+		return ""
+	}
+	return am.accessKey.Token
+}
+
+// SignAppParams adds appkey, ts and the app signature to params, as the
+// TV client does. The access_key, if any, must already be in params.
+func (am *AuthManager) SignAppParams(params url.Values) url.Values {
+	return signAppParams(params, tvAppKey, tvAppSec, time.Now())
+}
+
+// signAppParams returns a copy of params with appkey, ts and sign, where
+// sign is the MD5 of the sorted query string followed by the app secret.
+func signAppParams(params url.Values, appKey, appSec string, now time.Time) url.Values {
+	signed := url.Values{}
+	for k, v := range params {
+		signed[k] = append([]string(nil), v...)
+	}
+	signed.Set("appkey", appKey)
+	signed.Set("ts", strconv.FormatInt(now.Unix(), 10))
+	signed.Del("sign")
+	sum := md5.Sum([]byte(signed.Encode() + appSec))
+	signed.Set("sign", hex.EncodeToString(sum[:]))
+	return signed
+}
+
+// loadAccessKey reads the access key file, if any.
+func (am *AuthManager) loadAccessKey() error {
+	data, err := os.ReadFile(filepath.Join(am.configDir, AccessKeyFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read access key: %w", err)
+	}
+	var key AccessKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return fmt.Errorf("failed to parse access key: %w", err)
+	}
+	am.mu.Lock()
+	am.accessKey = &key
+	am.mu.Unlock()
+	return nil
+}
+
+// saveAccessKey writes the access key file, or saves it to the credential
+// store when one is selected.
+func (am *AuthManager) saveAccessKey() error {
+	if am.secrets != nil {
+		return am.saveCookies()
+	}
+	if err := os.MkdirAll(am.configDir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	am.mu.RLock()
+	data, err := json.MarshalIndent(am.accessKey, "", "  ")
+	am.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal access key: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(am.configDir, AccessKeyFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write access key: %w", err)
+	}
+	return nil
+}
+
+// tvPollResult is the data of a successful TV QR code poll.
+type tvPollResult struct {
+	Mid          int64  `json:"mid"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	CookieInfo   struct {
+		Cookies []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"cookies"`
+	} `json:"cookie_info"`
+}
+
+// postTV posts signed params to a TV passport endpoint and returns the API
+// code, so polling can tell pending states from failures.
+func (am *AuthManager) postTV(endpoint string, params url.Values, out interface{}) (int, error) {
+	form := am.SignAppParams(params)
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	am.mu.RLock()
+	req.Header.Set("User-Agent", am.userAgent)
+	am.mu.RUnlock()
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return 0, err
+	}
+	if apiResp.Code != 0 {
+		return apiResp.Code, &APIError{Code: apiResp.Code, Message: apiResp.Message}
+	}
+	if out != nil && len(apiResp.Data) > 0 {
+		if err := json.Unmarshal(apiResp.Data, out); err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+// LoginWithTV performs the TV client QR code login. Besides the web
+// cookies it yields an access_key, which the app playurl API accepts and
+// which unlocks some qualities the web API withholds. Cancelling ctx stops
+// the wait for the scan.
+func (am *AuthManager) LoginWithTV(ctx context.Context) error {
+	if am.anonymous {
+		return errAnonymous
+	}
+
+	for attempt := 1; ; attempt++ {
+		var authCode struct {
+			URL      string `json:"url"`
+			AuthCode string `json:"auth_code"`
+		}
+		if _, err := am.postTV("https://passport.bilibili.com/x/passport-tv-login/qrcode/auth_code", url.Values{"local_id": {"0"}}, &authCode); err != nil {
+			return fmt.Errorf("failed to generate QR code: %w", err)
+		}
+
+		i18n.Fprintf(am.promptOutput(), "Scan the QR code with the Bilibili mobile app to log in:\n")
+		i18n.Fprintf(am.promptOutput(), "Or visit: %s\n", authCode.URL)
+		am.showQRCode(authCode.URL)
+		i18n.Fprintln(am.promptOutput(), "\nWaiting for scan...")
+
+		err := am.pollTVQRCode(ctx, authCode.AuthCode)
+		if errors.Is(err, errQRCodeExpired) && attempt < maxQRCodeAttempts {
+			i18n.Fprintln(am.promptOutput(), "\nQR code expired; generating a new one...")
+			continue
+		}
+		if errors.Is(err, errQRCodeExpired) {
+			return fmt.Errorf("QR code expired %d times; please restart login", attempt)
+		}
+		return err
+	}
+}
+
+// pollTVQRCode waits until the TV QR code identified by authCode is
+// scanned and confirmed, then stores the login.
+func (am *AuthManager) pollTVQRCode(ctx context.Context, authCode string) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var result tvPollResult
+		code, err := am.postTV("https://passport.bilibili.com/x/passport-tv-login/qrcode/poll",
+			url.Values{"auth_code": {authCode}, "local_id": {"0"}}, &result)
+		switch code {
+		case 0:
+			if err != nil {
+				return fmt.Errorf("failed to check QR code status: %w", err)
+			}
+			i18n.Fprintln(am.promptOutput(), "Login successful!")
+			return am.completeTVLogin(&result)
+		case 86039:
+			// Not scanned
+			fmt.Fprint(am.promptOutput(), ".")
+			if err := sleepContext(ctx, qrPollInterval); err != nil {
+				return err
+			}
+		case 86090:
+			// Scanned but not confirmed
+			i18n.Fprintln(am.promptOutput(), "\nQR code scanned. Please confirm login on your phone.")
+			if err := sleepContext(ctx, qrPollInterval); err != nil {
+				return err
+			}
+		case 86038:
+			return errQRCodeExpired
+		default:
+			return fmt.Errorf("login failed: %w", err)
+		}
+	}
+}
+
+// completeTVLogin stores the cookies and access_key of a TV login.
+func (am *AuthManager) completeTVLogin(result *tvPollResult) error {
+	am.mu.Lock()
+	for _, c := range result.CookieInfo.Cookies {
+		am.cookies[c.Name] = c.Value
+	}
+	// The web refresh_token is a different token; TV logins have none.
+	am.refreshToken = ""
+	am.accessKey = &AccessKey{
+		Token:        result.AccessToken,
+		RefreshToken: result.RefreshToken,
+		Mid:          result.Mid,
+		ExpiresAt:    time.Now().Unix() + result.ExpiresIn,
+	}
+	am.mu.Unlock()
+
+	if err := am.saveAccessKey(); err != nil {
+		return err
+	}
+	if err := am.SaveCookies(); err != nil {
+		am.logger.Warnf("Failed to save cookies: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSignAppParams(t *testing.T) {
+	params := url.Values{"access_key": {"key"}, "cid": {"1"}}
+	signed := signAppParams(params, "appkey1", "secret", time.Unix(1700000000, 0))
+
+	if signed.Get("appkey") != "appkey1" || signed.Get("ts") != "1700000000" {
+		t.Errorf("signed = %v", signed)
+	}
+	// md5("access_key=key&appkey=appkey1&cid=1&ts=1700000000secret")
+	if got, want := signed.Get("sign"), "bb68fe088bd2a33f036945df77f55ef4"; got != want {
+		t.Errorf("sign = %q, want %q", got, want)
+	}
+	if params.Get("appkey") != "" {
+		t.Error("signAppParams modified its input")
+	}
+}
+
+func TestLoginWithTV(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("appkey") != tvAppKey || r.Form.Get("sign") == "" {
+			t.Errorf("%s: unsigned request %v", r.URL.Path, r.Form)
+		}
+		switch r.URL.Path {
+		case "/x/passport-tv-login/qrcode/auth_code":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{"url": "https://passport.bilibili.com/x/passport-tv-login/h5/qrcode/auth?auth_code=abc", "auth_code": "abc"},
+			})
+		case "/x/passport-tv-login/qrcode/poll":
+			if r.Form.Get("auth_code") != "abc" {
+				t.Errorf("poll auth_code = %q", r.Form.Get("auth_code"))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{
+					"mid": 42, "access_token": "tv-token", "refresh_token": "tv-refresh", "expires_in": 3600,
+					"cookie_info": map[string]interface{}{"cookies": []map[string]interface{}{
+						{"name": "SESSDATA", "value": "tv-session"},
+						{"name": "bili_jct", "value": "tv-jct"},
+					}},
+				},
+			})
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	am := newTestAuthManager(t)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+	if err := am.LoginWithTV(context.Background()); err != nil {
+		t.Fatalf("LoginWithTV: %v", err)
+	}
+	if !am.IsAuthenticated() || am.AccessKey() != "tv-token" {
+		t.Fatalf("after login: authenticated=%v access key=%q", am.IsAuthenticated(), am.AccessKey())
+	}
+
+	info, err := os.Stat(filepath.Join(am.configDir, AccessKeyFile))
+	if err != nil {
+		t.Fatalf("access key file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("access key file mode = %o, want 600", perm)
+	}
+
+	am2 := NewAuthManager(am.configDir, am.logger)
+	if err := am2.LoadCookies(); err != nil {
+		t.Fatalf("LoadCookies: %v", err)
+	}
+	if am2.AccessKey() != "tv-token" || am2.GetCookie("SESSDATA") != "tv-session" {
+		t.Errorf("reloaded access key = %q, SESSDATA = %q", am2.AccessKey(), am2.GetCookie("SESSDATA"))
+	}
+}
+
+func TestAccessKey_Expired(t *testing.T) {
+	am := newTestAuthManager(t)
+	am.accessKey = &AccessKey{Token: "old", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+	if got := am.AccessKey(); got != "" {
+		t.Errorf("AccessKey = %q for an expired key, want empty", got)
+	}
+}
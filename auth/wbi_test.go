@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMixinKey(t *testing.T) {
+	// Reference values from the public WBI signing documentation.
+	got := getMixinKey("7cd084941338484aae1ad9425b84077c", "4932caff0ff746eab6f01bf08b70ac45")
+	if want := "ea1db124af3c7062474693fa704f4ff8"; got != want {
+		t.Errorf("getMixinKey = %q, want %q", got, want)
+	}
+}
+
+func TestSignWbiParams(t *testing.T) {
+	params := url.Values{}
+	params.Set("foo", "114")
+	params.Set("bar", "514")
+	params.Set("zab", "1919810")
+
+	signed := signWbiParams(params, "ea1db124af3c7062474693fa704f4ff8", time.Unix(1702204169, 0))
+	if got := signed.Get("wts"); got != "1702204169" {
+		t.Errorf("wts = %q, want 1702204169", got)
+	}
+	if got, want := signed.Get("w_rid"), "8f6f2b5b3d485fe1886cec6a0be8c5d4"; got != want {
+		t.Errorf("w_rid = %q, want %q", got, want)
+	}
+	if params.Get("wts") != "" {
+		t.Error("signWbiParams must not modify its input")
+	}
+}
+
+func TestSignWbiParams_FiltersCharacters(t *testing.T) {
+	params := url.Values{}
+	params.Set("keyword", "a!b'c(d)e*f")
+	signed := signWbiParams(params, "k", time.Unix(0, 0))
+	if got := signed.Get("keyword"); got != "abcdef" {
+		t.Errorf("keyword = %q, want abcdef", got)
+	}
+}
+
+func TestSignWbi_FetchesAndCachesKey(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Cookie") == "" || !strings.Contains(r.Header.Get("Cookie"), "buvid3=") {
+			t.Errorf("anonymous request should carry buvid3, got Cookie %q", r.Header.Get("Cookie"))
+		}
+		w.Write([]byte(`{"code":-101,"data":{"wbi_img":{
+			"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+			"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+	}))
+	defer server.Close()
+
+	am := NewAnonymousAuthManager(newTestAuthManager(t).logger)
+	am.client = &http.Client{Transport: &rewriteTransport{base: server.URL}}
+
+	for i := 0; i < 2; i++ {
+		signed, err := am.SignWbi(url.Values{"bvid": {"BV1qt4y1X7TW"}})
+		if err != nil {
+			t.Fatalf("SignWbi: %v", err)
+		}
+		if signed.Get("w_rid") == "" {
+			t.Error("w_rid missing from signed params")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("nav endpoint called %d times, want 1 (cached)", calls)
+	}
+}
+
+func TestAnonymousAuthManager(t *testing.T) {
+	am := NewAnonymousAuthManager(newTestAuthManager(t).logger)
+	if !am.IsAnonymous() {
+		t.Error("IsAnonymous() = false, want true")
+	}
+	if am.IsAuthenticated() {
+		t.Error("anonymous manager must not report as authenticated")
+	}
+	if buvid := am.GetCookie("buvid3"); !strings.HasSuffix(buvid, "infoc") || len(buvid) != 36+5 {
+		t.Errorf("buvid3 = %q, want UUID followed by infoc", buvid)
+	}
+	if err := am.LoadCookies(); err != nil {
+		t.Errorf("LoadCookies: %v", err)
+	}
+	if err := am.SaveCookies(); err == nil {
+		t.Error("SaveCookies should fail for anonymous managers")
+	}
+}
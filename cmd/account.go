@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dengmengmian/goBili/i18n"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// accountCmd represents the account command
+var accountCmd = &cobra.Command{
+	Use:   "account",
+	Short: "Manage and switch between login profiles",
+	Long: `Manage multiple Bilibili accounts stored as separate profiles.
+
+Each profile keeps its own cookies. Log in to a new profile with
+'goBili login --profile <name>', then switch between them with
+'goBili account use <name>'. The --profile flag overrides the active
+profile for a single command.`,
+	RunE: runAccountList,
+}
+
+// accountListCmd lists all known profiles
+var accountListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles and show which one is active",
+	Args:  cobra.NoArgs,
+	RunE:  runAccountList,
+}
+
+// accountUseCmd switches the active profile
+var accountUseCmd = &cobra.Command{
+	Use:   "use <profile>",
+	Short: "Switch the active profile for subsequent commands",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAccountUse,
+}
+
+func init() {
+	rootCmd.AddCommand(accountCmd)
+	accountCmd.AddCommand(accountListCmd)
+	accountCmd.AddCommand(accountUseCmd)
+}
+
+func runAccountList(_ *cobra.Command, _ []string) error {
+	profiles, err := listProfiles()
+	if err != nil {
+		return err
+	}
+
+	active := activeProfile()
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// Keep the listing free of per-profile cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+
+	for _, name := range profiles {
+		marker := " "
+		if name == active {
+			marker = "*"
+		}
+
+		authManager, err := newAuthManager(name, logger)
+		if err != nil {
+			return err
+		}
+		if err := authManager.LoadCookies(); err != nil {
+			logger.Debugf("Failed to load cookies for profile %s: %v", name, err)
+		}
+
+		state := i18n.T("not logged in")
+		if authManager.IsAuthenticated() {
+			state = i18n.T("logged in")
+			if uid := authManager.GetCookie("DedeUserID"); uid != "" {
+				state = i18n.Sprintf("logged in (UID: %s)", uid)
+			}
+		}
+		fmt.Printf("%s %-16s %s\n", marker, name, state)
+	}
+
+	if viper.GetString("profile") != "" {
+		i18n.Printf("\n--profile overrides the saved profile (%s) for this command.\n", savedProfile())
+	}
+	return nil
+}
+
+func runAccountUse(_ *cobra.Command, args []string) error {
+	name := args[0]
+	if err := validateProfileName(name); err != nil {
+		return err
+	}
+	if !profileExists(name) {
+		return i18n.Errorf("profile %q does not exist; create it with 'goBili login --profile %s'", name, name)
+	}
+
+	logger := newLogger()
+	authManager, err := newAuthManager(name, logger)
+	if err != nil {
+		return err
+	}
+	if err := authManager.LoadCookies(); err != nil {
+		return i18n.Errorf("failed to load cookies for profile %s: %w", name, err)
+	}
+
+	if err := saveActiveProfile(name); err != nil {
+		return err
+	}
+
+	if !authManager.IsAuthenticated() {
+		i18n.Printf("Switched to profile %s, but it has no saved session.\n", name)
+		i18n.Printf("Run 'goBili login' to authenticate this profile.\n")
+		return nil
+	}
+
+	// Validate the session so the user knows the switch is usable.
+	userInfo, err := authManager.GetUserInfo()
+	if err != nil {
+		logger.Debugf("Session validation failed: %v", err)
+		i18n.Printf("Switched to profile %s, but its session could not be validated.\n", name)
+		i18n.Println("You may need to log out and log in again for this profile.")
+		return nil
+	}
+
+	i18n.Printf("Switched to profile %s\n", name)
+	i18n.Printf("Subsequent commands will use: %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
+	return nil
+}
+
+// newLogger returns a logrus logger honoring the --verbose flag.
+func newLogger() *logrus.Logger {
+	logger := logrus.New()
+	if viper.GetBool("verbose") {
+		logger.SetLevel(logrus.DebugLevel)
+	} else {
+		logger.SetLevel(logrus.InfoLevel)
+	}
+	if !useColor() {
+		logger.SetFormatter(&logrus.TextFormatter{DisableColors: true})
+	}
+	return logger
+}
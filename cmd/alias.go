@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// maxAliasDepth bounds alias chains such as "a = b" and "b = download ...".
+const maxAliasDepth = 10
+
+// expandArgs rewrites the command line before cobra sees it: a leading
+// alias from the "aliases" config map is replaced by its expansion, and a
+// bare "goBili <URL>" becomes "goBili download <URL>".
+func expandArgs(args []string) ([]string, error) {
+	args, err := expandAliases(args, viper.GetStringMap("aliases"))
+	if err != nil {
+		return nil, err
+	}
+	return withDefaultCommand(args), nil
+}
+
+// expandAliases replaces the command name while it names an alias. Global
+// flags may precede it. Built-in commands always win over aliases of the
+// same name.
+func expandAliases(args []string, aliases map[string]interface{}) ([]string, error) {
+	seen := map[string]bool{}
+	for {
+		i := commandIndex(args)
+		if i < 0 {
+			return args, nil
+		}
+		name := args[i]
+		raw, ok := aliases[name]
+		if !ok || isBuiltinCommand(name) {
+			return args, nil
+		}
+		if seen[name] || len(seen) >= maxAliasDepth {
+			return nil, fmt.Errorf("alias %q expands to itself", name)
+		}
+		seen[name] = true
+
+		expansion, err := aliasExpansion(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias %q: %w", name, err)
+		}
+		expanded := append([]string{}, args[:i]...)
+		expanded = append(expanded, expansion...)
+		args = append(expanded, args[i+1:]...)
+	}
+}
+
+// commandIndex returns the index of the first argument that is not a global
+// flag or a global flag's value, or -1 if there is none.
+func commandIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return i
+		}
+		if strings.Contains(arg, "=") {
+			continue
+		}
+		var flag *pflag.Flag
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			flag = rootCmd.PersistentFlags().Lookup(name)
+		} else if len(arg) == 2 {
+			flag = rootCmd.PersistentFlags().ShorthandLookup(arg[1:])
+		}
+		if flag != nil && flag.NoOptDefVal == "" {
+			i++ // skip the flag's value
+		}
+	}
+	return -1
+}
+
+// aliasExpansion returns the arguments of one alias, written either as a
+// command line ("download -q 1080p") or as a YAML list of arguments.
+func aliasExpansion(raw interface{}) ([]string, error) {
+	var args []string
+	switch v := raw.(type) {
+	case string:
+		var err error
+		if args, err = splitCommandLine(v); err != nil {
+			return nil, err
+		}
+	case []interface{}:
+		for _, arg := range v {
+			args = append(args, fmt.Sprint(arg))
+		}
+	default:
+		return nil, fmt.Errorf("want a string or a list of arguments")
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty expansion")
+	}
+	return args, nil
+}
+
+// splitCommandLine splits s into arguments like a POSIX shell would,
+// honouring single quotes, double quotes and backslash escapes.
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+// isBuiltinCommand reports whether name is a subcommand or alias of a
+// subcommand of goBili.
+func isBuiltinCommand(name string) bool {
+	if name == "help" {
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaultCommand prepends "download" when no subcommand is given but
+// one of the arguments is a Bilibili URL.
+func withDefaultCommand(args []string) []string {
+	if len(args) == 0 || strings.HasPrefix(args[0], "__") {
+		return args // shell completion requests
+	}
+	if c, _, err := rootCmd.Find(args); err == nil && c != rootCmd {
+		return args
+	}
+	for _, arg := range args {
+		if looksLikeVideoURL(arg) {
+			return append([]string{downloadCmd.Name()}, args...)
+		}
+	}
+	return args
+}
+
+// looksLikeVideoURL reports whether arg is a Bilibili link.
+func looksLikeVideoURL(arg string) bool {
+	if strings.HasPrefix(arg, "-") {
+		return false
+	}
+	for _, host := range []string{"bilibili.com/", "b23.tv/"} {
+		if strings.Contains(arg, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFileFromArgs returns the value of --config in args, so the config
+// file can be read before cobra parses the command line.
+func configFileFromArgs(args []string) string {
+	return globalFlagFromArgs(args, "config")
+}
+
+// globalFlagFromArgs returns the value of the global flag --name in args,
+// which cobra has not parsed yet. A command with a --name flag of its own,
+// such as "subtitle --lang", shadows the global one after the command name,
+// so only the arguments before it are read then.
+func globalFlagFromArgs(args []string, name string) string {
+	end := len(args)
+	if at := commandIndex(args); at >= 0 {
+		if c, _, err := rootCmd.Find(args[at:]); err == nil && c.LocalNonPersistentFlags().Lookup(name) != nil {
+			end = at
+		}
+	}
+	for i, arg := range args[:end] {
+		if arg == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(arg, "--"+name+"="); ok {
+			return v
+		}
+		if arg == "--"+name && i+1 < end {
+			return args[i+1]
+		}
+	}
+	return ""
+}
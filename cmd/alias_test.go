@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"download -q 1080p", []string{"download", "-q", "1080p"}, false},
+		{"  download\t-q\n1080p  ", []string{"download", "-q", "1080p"}, false},
+		{`download -o "My Videos"`, []string{"download", "-o", "My Videos"}, false},
+		{`download -o 'it''s'`, []string{"download", "-o", "its"}, false},
+		{`download --exec 'echo "$GOBILI_TITLE" {}'`, []string{"download", "--exec", `echo "$GOBILI_TITLE" {}`}, false},
+		{`download -o My\ Videos`, []string{"download", "-o", "My Videos"}, false},
+		{`say "a \"quoted\" word"`, []string{"say", `a "quoted" word`}, false},
+		{`say 'no \escape'`, []string{"say", `no \escape`}, false},
+		{`empty "" ''`, []string{"empty", "", ""}, false},
+		{"", nil, false},
+		{`download "unterminated`, nil, true},
+		{`download trailing\`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitCommandLine(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitCommandLine(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommandLine(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommandIndex(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"dl", "URL"}, 0},
+		{[]string{"-v", "dl"}, 1},
+		{[]string{"--anonymous", "dl"}, 1},
+		{[]string{"-o", "out", "dl"}, 2},
+		{[]string{"--profile", "work", "-t", "8", "dl"}, 4},
+		{[]string{"--lang=zh", "dl"}, 1},
+		{[]string{"-", "dl"}, 0},
+		{[]string{"--profile", "work"}, -1},
+		{[]string{"--", "dl"}, -1},
+		{nil, -1},
+	}
+	for _, tt := range tests {
+		if got := commandIndex(tt.args); got != tt.want {
+			t.Errorf("commandIndex(%q) = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestGlobalFlagFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--lang", "zh", "login"}, "zh"},
+		{[]string{"--lang=zh", "login"}, "zh"},
+		{[]string{"login", "--lang", "zh"}, "zh"},
+		{[]string{"subtitle", "--lang", "en,ja", "URL"}, ""},
+		{[]string{"subtitle", "--lang=zh-CN,en", "URL"}, ""},
+		{[]string{"--lang", "zh", "subtitle", "--lang", "en,ja", "URL"}, "zh"},
+		{[]string{"download", "--", "--lang", "zh"}, ""},
+		{[]string{"--lang"}, ""},
+	}
+	for _, tt := range tests {
+		if got := globalFlagFromArgs(tt.args, "lang"); got != tt.want {
+			t.Errorf("globalFlagFromArgs(%q, lang) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExpandAliases(t *testing.T) {
+	aliases := map[string]interface{}{
+		"dl":       "download -q 1080p",
+		"music":    []interface{}{"dl", "--audio-only", "-o", "My Music"},
+		"self":     "self -v",
+		"ping":     "pong",
+		"pong":     "ping",
+		"download": "info",
+		"broken":   `download "unterminated`,
+		"empty":    "",
+		"number":   42,
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		{"simple", []string{"dl", "URL"}, []string{"download", "-q", "1080p", "URL"}, ""},
+		{"nested", []string{"music", "URL"}, []string{"download", "-q", "1080p", "--audio-only", "-o", "My Music", "URL"}, ""},
+		{"after global flags", []string{"--profile", "work", "-v", "dl", "URL"}, []string{"--profile", "work", "-v", "download", "-q", "1080p", "URL"}, ""},
+		{"unknown alias", []string{"nope", "URL"}, []string{"nope", "URL"}, ""},
+		{"no command", []string{"-v"}, []string{"-v"}, ""},
+		{"builtin wins", []string{"download", "URL"}, []string{"download", "URL"}, ""},
+		{"self reference", []string{"self"}, nil, `alias "self" expands to itself`},
+		{"loop", []string{"ping"}, nil, "expands to itself"},
+		{"bad quoting", []string{"broken"}, nil, `invalid alias "broken"`},
+		{"empty expansion", []string{"empty"}, nil, "empty expansion"},
+		{"wrong type", []string{"number"}, nil, "want a string or a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandAliases(tt.args, aliases)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandAliases(%q) error = %v, want %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandAliases(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandAliases_DepthLimit(t *testing.T) {
+	// A chain longer than maxAliasDepth fails instead of recursing on.
+	aliases := map[string]interface{}{}
+	for i := 0; i <= maxAliasDepth; i++ {
+		aliases[aliasName(i)] = aliasName(i + 1)
+	}
+	if _, err := expandAliases([]string{aliasName(0)}, aliases); err == nil {
+		t.Error("expected an error for an alias chain deeper than maxAliasDepth")
+	}
+}
+
+// aliasName returns the name of alias i of a chain: a0, a1, ...
+func aliasName(i int) string {
+	return "a" + strings.Repeat("x", i)
+}
@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/pkg/gobili"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// batchCmd represents the batch command
+var batchCmd = &cobra.Command{
+	Use:   "batch [URL...]",
+	Short: "Download many videos as one resumable job",
+	Long: `Download the videos behind several URLs as one job. Every URL is
+expanded into its videos and parts once, and the job's progress (completed,
+failed and pending entries) is saved under ~/.goBili/jobs after each video.
+
+An interrupted or partly failed job continues where it stopped with
+--resume; finished entries are skipped and the URLs are not parsed again.
+The download flags (quality, format, pages, ...) apply to every entry.
+
+Examples:
+  goBili batch "https://www.bilibili.com/video/BV1xx411c7mu" "https://www.bilibili.com/bangumi/play/ss33073"
+  goBili batch -i urls.txt
+  goBili batch --list
+  goBili batch --resume 3f9a1c2e`,
+	RunE: runBatch,
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+
+	// The download flags are added in download.go's init, once they exist.
+	batchCmd.Flags().StringP("input", "i", "", `read URLs from a file, one per line ("-" for stdin)`)
+	batchCmd.Flags().String("resume", "", "continue the job with this ID")
+	batchCmd.Flags().Bool("list", false, "list saved jobs and their progress")
+}
+
+// batchVideo is the part of a batch entry needed to download it again
+// without parsing its source URL.
+type batchVideo struct {
+	Info *parser.VideoInfo `json:"info"`
+	CID  int64             `json:"cid"`
+	// Page is the page passed to GetVideoStreamsForPage; 0 means a
+	// single-part video fetched with GetVideoStreams.
+	Page int `json:"page,omitempty"`
+}
+
+// batchDir returns the directory holding the job state files.
+func batchDir() string {
+	return filepath.Join(getConfigDir(), "jobs")
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	if list, _ := cmd.Flags().GetBool("list"); list {
+		return listBatches()
+	}
+	resume, _ := cmd.Flags().GetString("resume")
+	input, _ := cmd.Flags().GetString("input")
+
+	var batch *store.Batch
+	if resume != "" {
+		if len(args) > 0 || input != "" {
+			return i18n.Errorf("--resume cannot be combined with new URLs")
+		}
+		var err error
+		if batch, err = store.LoadBatch(batchDir(), resume); err != nil {
+			return err
+		}
+	} else {
+		sources, err := batchSources(args, input)
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			return i18n.Errorf("no URLs given: pass them as arguments or with --input")
+		}
+		batch = store.NewBatch(batchDir(), sources)
+	}
+
+	if viper.GetString("output") == "-" {
+		return i18n.Errorf("batch jobs cannot stream to stdout")
+	}
+	s, err := newDownloadSession(cmd, sessionOverrides{})
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		s.logger.Warnf("Download history disabled: %v", err)
+	} else {
+		defer st.Close()
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	asJSON := jsonOutput(cmd)
+	if asJSON {
+		// Standard output carries the report alone.
+		defer redirectStdout()()
+	}
+
+	report := newRunReport()
+	report.Job = batch.ID
+	err = runBatchJob(ctx, s, st, report, batch)
+	reportPath := viper.GetString("write_report")
+	if reportErr := finishReport(report, reportPath, true, asJSON); reportErr != nil {
+		s.logger.Warnf("%v", reportErr)
+	}
+	completed, failed, pending := batch.Counts()
+	i18n.Printf("\nJob %s: %d completed, %d failed, %d pending\n", batch.ID, completed, failed, pending)
+	if failed+pending > 0 {
+		i18n.Printf("Resume with: goBili batch --resume %s\n", batch.ID)
+	}
+	if err == nil && failed > 0 {
+		return withExitCode(ExitPartialFailure, i18n.Errorf("%d of %d downloads failed", failed, completed+failed+pending))
+	}
+	return err
+}
+
+// runBatchJob expands the sources that have not been expanded yet and
+// downloads the remaining entries, saving the job state as it goes.
+func runBatchJob(ctx context.Context, s *downloadSession, st store.Store, report *runReport, batch *store.Batch) error {
+	expandBatch(s, report, batch)
+	if err := batch.Save(); err != nil {
+		return err
+	}
+	i18n.Printf("Job %s: %d videos\n", batch.ID, len(batch.Entries))
+
+	var remaining []*store.BatchEntry
+	for _, entry := range batch.Remaining() {
+		if entry.Video != nil { // Sources that failed to parse have none.
+			remaining = append(remaining, entry)
+		}
+	}
+	for i, entry := range remaining {
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		i18n.Printf("\n[%d/%d] Downloading: %s\n", i+1, len(remaining), entry.Title)
+
+		err := downloadBatchEntry(ctx, s, st, report, entry)
+		if ctx.Err() != nil {
+			// The entry stays pending and is retried on resume.
+			return errInterrupted
+		}
+		if err != nil {
+			s.logger.Warnf("Failed to download %s: %v", entry.Title, err)
+			entry.Status, entry.Error = store.JobFailed, err.Error()
+		} else {
+			entry.Status, entry.Error = store.JobCompleted, ""
+		}
+		if err := batch.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandBatch turns every source without entries into one entry per video
+// part. A source that cannot be parsed becomes a failed entry without a
+// video, and is parsed again on resume.
+func expandBatch(s *downloadSession, report *runReport, batch *store.Batch) {
+	expanded := map[string]bool{}
+	entries := batch.Entries[:0]
+	for _, e := range batch.Entries {
+		if e.Video == nil {
+			continue // A failed parse; try the source again.
+		}
+		expanded[e.Source] = true
+		entries = append(entries, e)
+	}
+	batch.Entries = entries
+
+	for _, source := range batch.Sources {
+		if expanded[source] {
+			continue
+		}
+		videos, err := expandSource(s, source)
+		if err != nil {
+			s.logger.Warnf("Failed to parse %s: %v", source, err)
+			report.addFailure(source, "", 0, i18n.Errorf("failed to parse URL: %w", err))
+			batch.Add(&store.BatchEntry{Key: source, Source: source, Title: source, Status: store.JobFailed, Error: err.Error()})
+			continue
+		}
+		for _, v := range videos {
+			data, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			batch.Add(&store.BatchEntry{
+				Key:    fmt.Sprintf("%s/%d", v.Info.BVID, v.CID),
+				Source: source,
+				Title:  v.Info.Title,
+				Video:  data,
+			})
+		}
+	}
+}
+
+// expandSource parses one URL into the videos downloadSingleVideo and
+// downloadPlaylist would download.
+func expandSource(s *downloadSession, source string) ([]*batchVideo, error) {
+	videoInfo, err := s.parser.ParseURL(source)
+	if err != nil {
+		return nil, err
+	}
+	if videoInfo.Type == "video" && len(videoInfo.Pages) <= 1 {
+		v := &batchVideo{Info: videoInfo}
+		if len(videoInfo.Pages) == 1 {
+			v.CID = videoInfo.Pages[0].CID
+		}
+		return []*batchVideo{v}, nil
+	}
+
+	episodes, err := selectEpisodes(videoInfo, s.pages)
+	if err != nil {
+		return nil, err
+	}
+	var videos []*batchVideo
+	for _, episode := range episodes {
+		info, page := gobili.EpisodeVideo(videoInfo, episode)
+		// Keep only the page GetVideoStreamsForPage would pick.
+		if page >= 1 && page <= len(info.Pages) {
+			info.Pages = []*parser.PageInfo{info.Pages[page-1]}
+			page = 1
+		}
+		videos = append(videos, &batchVideo{Info: info, CID: episode.CID, Page: page})
+	}
+	return videos, nil
+}
+
+// downloadBatchEntry downloads one entry and records its outcome in the
+// report and, on success, its output path in the entry.
+func downloadBatchEntry(ctx context.Context, s *downloadSession, st store.Store, report *runReport, entry *store.BatchEntry) error {
+	var v batchVideo
+	if err := json.Unmarshal(entry.Video, &v); err != nil || v.Info == nil {
+		err := i18n.Errorf("damaged job entry")
+		report.addFailure(entry.Title, "", 0, err)
+		return err
+	}
+
+	result, err := downloadBatchVideo(ctx, s, st, &v)
+	if err != nil {
+		if ctx.Err() == nil {
+			report.addFailure(v.Info.Title, v.Info.BVID, v.CID, err)
+		}
+		return err
+	}
+	recordHistory(st, s.logger, v.Info, v.CID, result)
+	report.addResult(v.Info, v.CID, result)
+	entry.Path = result.Path
+	return nil
+}
+
+// downloadBatchVideo fetches the streams of v and downloads it, unless
+// the history has it already.
+func downloadBatchVideo(ctx context.Context, s *downloadSession, st store.Store, v *batchVideo) (*downloader.Result, error) {
+	if result := downloadedResult(st, s.logger, v.Info.BVID, v.CID); result != nil {
+		return result, nil
+	}
+
+	var streams []*parser.StreamInfo
+	var err error
+	if v.Page == 0 {
+		streams, err = s.parser.GetVideoStreams(v.Info)
+	} else {
+		streams, err = s.parser.GetVideoStreamsForPage(v.Info, v.Page)
+	}
+	if err != nil {
+		return nil, i18n.Errorf("failed to get video streams: %w", err)
+	}
+
+	attachPlayerInfo(s.parser, s.logger, v.Info, v.CID)
+	attachDanmaku(s.parser, s.logger, v.Info, v.CID)
+	attachStoryboard(s.parser, s.logger, v.Info, v.CID)
+
+	return s.dl.DownloadVideoResult(withStreamRefresher(ctx, s.parser, v.Info, max(v.Page, 1)), v.Info, streams)
+}
+
+// batchSources collects the URLs from the arguments and the input file.
+func batchSources(args []string, input string) ([]string, error) {
+	sources := append([]string(nil), args...)
+	if input == "" {
+		return sources, nil
+	}
+
+	var r io.Reader = os.Stdin
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return nil, i18n.Errorf("failed to open URL list: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	urls, err := readURLList(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(sources, urls...), nil
+}
+
+// readURLList reads one URL per line from r, skipping blank lines and
+// # comments.
+func readURLList(r io.Reader) ([]string, error) {
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, i18n.Errorf("failed to read URL list: %w", err)
+	}
+	return urls, nil
+}
+
+// listBatches prints the saved jobs, newest first.
+func listBatches() error {
+	batches, err := store.ListBatches(batchDir())
+	if err != nil {
+		return err
+	}
+	if len(batches) == 0 {
+		i18n.Println("No saved jobs.")
+		return nil
+	}
+	for _, b := range batches {
+		completed, failed, pending := b.Counts()
+		i18n.Printf("%s  %s  %d completed, %d failed, %d pending  (%d URLs)\n",
+			b.ID, b.CreatedAt.Format("2006-01-02 15:04"), completed, failed, pending, len(b.Sources))
+	}
+	return nil
+}
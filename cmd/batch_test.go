@@ -0,0 +1,24 @@
+package cmd
+
+import "testing"
+
+func TestExpandSource_Bangumi(t *testing.T) {
+	p := bangumiParser(t)
+	videos, err := expandSource(&downloadSession{parser: p, pages: "all"}, "https://www.bilibili.com/bangumi/play/ss2")
+	if err != nil {
+		t.Fatalf("expandSource: %v", err)
+	}
+	if len(videos) != 2 {
+		t.Fatalf("expandSource returned %d entries, want one per episode", len(videos))
+	}
+	for i, v := range videos {
+		wantCID := int64(100 * (i + 1))
+		if v.CID != wantCID || v.Page != 1 || len(v.Info.Pages) != 1 || v.Info.Pages[0].CID != wantCID {
+			t.Errorf("entry %d = cid %d, page %d, pages %+v; want the single page of cid %d", i, v.CID, v.Page, v.Info.Pages, wantCID)
+		}
+		streams, err := p.GetVideoStreamsForPage(v.Info, v.Page)
+		if err != nil || len(streams) == 0 {
+			t.Errorf("entry %d: streams = %v, %v", i, streams, err)
+		}
+	}
+}
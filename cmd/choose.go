@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/quality"
+)
+
+// qualityChooser asks which of the resolved streams to download, for
+// --choose-quality. The choice is remembered as a format ID, so the later
+// parts of a multi-part video or playlist use it without asking again;
+// reset forgets it before the next URL.
+type qualityChooser struct {
+	in       *bufio.Reader
+	out      io.Writer
+	formatID string
+}
+
+func newQualityChooser(in io.Reader, out io.Writer) *qualityChooser {
+	return &qualityChooser{in: bufio.NewReader(in), out: out}
+}
+
+// reset forgets the remembered choice.
+func (c *qualityChooser) reset() {
+	if c != nil {
+		c.formatID = ""
+	}
+}
+
+// choose returns the streams to pass to the downloader: only the chosen
+// one. A nil chooser returns streams unchanged. duration is the length of
+// the part in seconds, for the size estimates.
+func (c *qualityChooser) choose(title string, streams []*parser.StreamInfo, duration int) ([]*parser.StreamInfo, error) {
+	if c == nil || len(streams) <= 1 {
+		return streams, nil
+	}
+	if c.formatID != "" {
+		for _, s := range streams {
+			if s.FormatID == c.formatID {
+				return []*parser.StreamInfo{s}, nil
+			}
+		}
+		// The part lacks the remembered format, so ask again.
+	}
+
+	sorted := append([]*parser.StreamInfo(nil), streams...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Quality > sorted[j].Quality
+	})
+
+	fmt.Fprintf(c.out, "\n%s\n\n", i18n.Sprintf("Qualities of %s:", title))
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tQUALITY\tRESOLUTION\tFPS\tCODEC\tSIZE")
+	for i, s := range sorted {
+		codecs := s.VideoCodecs
+		if s.AudioURL != "" && s.AudioCodecs != "" {
+			codecs += " + " + s.AudioCodecs
+		}
+		size := "-"
+		if n := s.EstimatedSize(duration); n > 0 {
+			size = "~" + formatSize(n)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, quality.Name(s.Quality), s.Resolution,
+			orDash(s.FrameRate), codecs, size)
+	}
+	tw.Flush()
+
+	for {
+		fmt.Fprint(c.out, i18n.Sprintf("Choose a quality [1-%d, Enter for 1, q to quit]: ", len(sorted)))
+		line, err := c.in.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if err != nil && answer == "" {
+			return nil, fmt.Errorf("no quality chosen: %w", err)
+		}
+		if answer == "q" {
+			return nil, fmt.Errorf("quality selection canceled")
+		}
+		n := 1
+		if answer != "" {
+			if n, err = strconv.Atoi(answer); err != nil || n < 1 || n > len(sorted) {
+				fmt.Fprintln(c.out, i18n.Sprintf("Enter a number from 1 to %d.", len(sorted)))
+				continue
+			}
+		}
+		chosen := sorted[n-1]
+		c.formatID = chosen.FormatID
+		return []*parser.StreamInfo{chosen}, nil
+	}
+}
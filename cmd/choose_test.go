@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestQualityChooser(t *testing.T) {
+	streams := []*parser.StreamInfo{
+		{Quality: 64, FormatID: "64-avc", Resolution: "1280x720", VideoCodecs: "avc1"},
+		{Quality: 80, FormatID: "80-avc", Resolution: "1920x1080", VideoCodecs: "avc1"},
+		{Quality: 32, FormatID: "32-avc", Resolution: "852x480", VideoCodecs: "avc1"},
+	}
+
+	// An invalid answer asks again; 2 is the second best, 720p.
+	var out bytes.Buffer
+	c := newQualityChooser(strings.NewReader("9\n2\n"), &out)
+	got, err := c.choose("Part 1", streams, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].FormatID != "64-avc" {
+		t.Fatalf("choose = %v, want 64-avc", got)
+	}
+	if !strings.Contains(out.String(), "Enter a number from 1 to 3.") {
+		t.Errorf("no retry prompt in:\n%s", out.String())
+	}
+
+	// The choice is remembered for the next part without asking.
+	out.Reset()
+	if got, err := c.choose("Part 2", streams, 60); err != nil || got[0].FormatID != "64-avc" {
+		t.Errorf("second part = %v, %v; want 64-avc", got, err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("asked again for the second part:\n%s", out.String())
+	}
+
+	// Enter picks the best quality.
+	c = newQualityChooser(strings.NewReader("\n"), &out)
+	if got, err := c.choose("Other", streams, 60); err != nil || got[0].FormatID != "80-avc" {
+		t.Errorf("Enter = %v, %v; want 80-avc", got, err)
+	}
+	c.reset()
+	if c.formatID != "" {
+		t.Error("reset kept the choice")
+	}
+
+	if _, err := newQualityChooser(strings.NewReader("q\n"), &out).choose("Quit", streams, 60); err == nil {
+		t.Error("q did not cancel")
+	}
+	if _, err := newQualityChooser(strings.NewReader(""), &out).choose("EOF", streams, 60); err == nil {
+		t.Error("end of input did not fail")
+	}
+
+	var nilChooser *qualityChooser
+	if got, _ := nilChooser.choose("Any", streams, 60); len(got) != len(streams) {
+		t.Error("a nil chooser filtered the streams")
+	}
+}
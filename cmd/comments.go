@@ -0,0 +1,263 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// commentsCmd represents the comments command
+var commentsCmd = &cobra.Command{
+	Use:   "comments <URL>",
+	Short: "Export the comments of a video",
+	Long: `Fetch the comments (评论) of a video, with the replies to each, and save
+them in the output directory as JSON, top-level comments holding their
+replies, or as CSV, one row per comment with its replies after it. The
+parts of a multi-part video share their comments; each episode of a
+bangumi season has its own, chosen with --episodes.
+
+--pages limits the top-level comments to that many pages of 20, in the
+order of --sort; replies are always fetched in full unless --no-replies.
+With -o - a single video is written to stdout.
+
+Examples:
+  goBili comments "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili comments --pages 5 --sort likes --format csv "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili comments --no-replies -o - "https://www.bilibili.com/video/BV1xx411c7mu" | jq length`,
+	Args: cobra.ExactArgs(1),
+	RunE: runComments,
+}
+
+// commentSorts are the values of --sort.
+var commentSorts = map[string]parser.CommentSort{
+	"time":    parser.CommentsByTime,
+	"likes":   parser.CommentsByLikes,
+	"replies": parser.CommentsByReplies,
+}
+
+func init() {
+	rootCmd.AddCommand(commentsCmd)
+
+	commentsCmd.Flags().Int("pages", 0, "pages of 20 top-level comments to fetch, 0 for all")
+	commentsCmd.Flags().String("sort", "time", "order of the top-level comments: time (newest first), likes or replies")
+	commentsCmd.Flags().String("format", "json", "file format: json or csv")
+	commentsCmd.Flags().Bool("no-replies", false, "fetch the top-level comments only")
+	commentsCmd.Flags().String("episodes", "all", "episodes of a bangumi season to export (e.g., 1,2,3 or 1-5 or all)")
+}
+
+func runComments(cmd *cobra.Command, args []string) error {
+	pages, _ := cmd.Flags().GetInt("pages")
+	sortName, _ := cmd.Flags().GetString("sort")
+	format, _ := cmd.Flags().GetString("format")
+	noReplies, _ := cmd.Flags().GetBool("no-replies")
+	episodes, _ := cmd.Flags().GetString("episodes")
+
+	format = strings.ToLower(format)
+	if format != "json" && format != "csv" {
+		return i18n.Errorf("invalid format %q (want json or csv)", format)
+	}
+	order, ok := commentSorts[strings.ToLower(sortName)]
+	if !ok {
+		return i18n.Errorf("invalid sort %q (want time, likes or replies)", sortName)
+	}
+	if pages < 0 {
+		return i18n.Errorf("invalid --pages %d (want 0 for all, or more)", pages)
+	}
+
+	logger := newLogger()
+	output := viper.GetString("output")
+	if output == "-" && !viper.GetBool("verbose") {
+		// Keep stdout free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, _, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	targets, err := commentTargets(videoInfo, episodes)
+	if err != nil {
+		return err
+	}
+	if output == "-" && len(targets) > 1 {
+		return i18n.Errorf("-o - writes one video; choose it with --episodes")
+	}
+	if output != "-" {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return i18n.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var failed int
+	for _, target := range targets {
+		comments, err := fetchComments(ctx, p, logger, target.bvid, pages, order, !noReplies)
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		if err != nil {
+			logger.Warnf("Failed to fetch the comments of %s: %v", target.title, err)
+			failed++
+			continue
+		}
+		data, err := encodeComments(comments, format)
+		if err != nil {
+			return err
+		}
+
+		if output == "-" {
+			if _, err := os.Stdout.Write(data); err != nil {
+				return i18n.Errorf("failed to write comments: %w", err)
+			}
+			continue
+		}
+		path := filepath.Join(output, downloader.SanitizeFilename(target.title)+".comments."+format)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return i18n.Errorf("failed to save comments: %w", err)
+		}
+		i18n.Printf("Saved %d comments and %d replies to %s\n", len(comments), countReplies(comments), path)
+	}
+	if failed > 0 {
+		return i18n.Errorf("failed to fetch the comments of %d of %d videos", failed, len(targets))
+	}
+	return nil
+}
+
+// commentTargets returns the videos whose comments to export: the video
+// itself, or the selected episodes of a bangumi season. The parts of a
+// multi-part video are one video.
+func commentTargets(videoInfo *parser.VideoInfo, episodes string) ([]videoPart, error) {
+	if videoInfo.SeasonID == 0 {
+		return []videoPart{{title: videoInfo.Title, bvid: videoInfo.BVID}}, nil
+	}
+	selected, err := selectEpisodes(videoInfo, episodes)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]videoPart, 0, len(selected))
+	for _, episode := range selected {
+		targets = append(targets, videoPart{
+			title: videoInfo.Title + " " + episode.DisplayTitle(),
+			bvid:  episode.BVID,
+			cid:   episode.CID,
+		})
+	}
+	return targets, nil
+}
+
+// fetchComments fetches up to pages pages (0 for all) of the top-level
+// comments on the video bvid and, with replies, every reply to them.
+// Replies that cannot be fetched are logged and left out.
+func fetchComments(ctx context.Context, p *parser.BilibiliParser, logger *logrus.Logger, bvid string, pages int, order parser.CommentSort, replies bool) ([]*parser.Comment, error) {
+	var comments []*parser.Comment
+	for pn := 1; pages == 0 || pn <= pages; pn++ {
+		page, total, err := p.GetComments(ctx, bvid, pn, order)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		if len(page) == 0 || len(comments) >= total {
+			break
+		}
+	}
+	if !replies {
+		return comments, nil
+	}
+
+	for _, comment := range comments {
+		for pn := 1; len(comment.Replies) < comment.ReplyCount; pn++ {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			page, total, err := p.GetReplies(ctx, bvid, comment.RPID, pn)
+			if err != nil {
+				logger.Warnf("Failed to fetch the replies to comment %d: %v", comment.RPID, err)
+				break
+			}
+			comment.Replies = append(comment.Replies, page...)
+			if len(page) == 0 || len(comment.Replies) >= total {
+				break
+			}
+		}
+	}
+	return comments, nil
+}
+
+// countReplies returns the number of replies fetched for comments.
+func countReplies(comments []*parser.Comment) int {
+	n := 0
+	for _, c := range comments {
+		n += len(c.Replies)
+	}
+	return n
+}
+
+// commentColumns are the columns of the CSV export.
+var commentColumns = []string{"rpid", "root", "parent", "mid", "user", "time", "likes", "reply_count", "message"}
+
+// encodeComments renders comments in format: JSON, or CSV with each
+// comment followed by its replies.
+func encodeComments(comments []*parser.Comment, format string) ([]byte, error) {
+	if format == "json" {
+		if comments == nil {
+			comments = []*parser.Comment{}
+		}
+		data, err := json.MarshalIndent(comments, "", "  ")
+		if err != nil {
+			return nil, i18n.Errorf("failed to encode comments: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(commentColumns)
+	for _, c := range comments {
+		writeCommentRow(w, c)
+		replies := append([]*parser.Comment(nil), c.Replies...)
+		sort.SliceStable(replies, func(i, j int) bool { return replies[i].Time < replies[j].Time })
+		for _, r := range replies {
+			writeCommentRow(w, r)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, i18n.Errorf("failed to encode comments: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCommentRow(w *csv.Writer, c *parser.Comment) {
+	w.Write([]string{
+		strconv.FormatInt(c.RPID, 10),
+		strconv.FormatInt(c.Root, 10),
+		strconv.FormatInt(c.Parent, 10),
+		strconv.FormatInt(c.Mid, 10),
+		c.User,
+		time.Unix(c.Time, 0).Format(time.RFC3339),
+		strconv.Itoa(c.Likes),
+		strconv.Itoa(c.ReplyCount),
+		c.Message,
+	})
+}
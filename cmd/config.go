@@ -0,0 +1,370 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/dengmengmian/goBili/i18n"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and change settings in the config file",
+	Long: `Read and change the settings of the config file (~/.goBili.yaml, or the
+file given with --config). Keys are the config file keys, with dots for
+nested ones, e.g. quality, output_dir_template or danmaku.opacity. Every
+download flag has a key: --audio-only is audio_only, --format-id is
+format_id and so on.
+
+Examples:
+  goBili config set quality 1080p
+  goBili config set danmaku.opacity 0.6
+  goBili config get quality
+  goBili config list
+  goBili config edit`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Save a setting in the config file",
+	Long: `Save a setting in the config file, creating the file if needed. The
+value is read as YAML, so "true", "4" and "[a, b]" become a boolean, a
+number and a list; quote it to keep a string. In a YAML config file,
+comments and the order of the other settings are preserved; JSON and
+TOML config files are rewritten in their own format.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the effective settings",
+	Long: `List every setting with its effective value, from the config file,
+the environment or the built-in default.`,
+	Args: cobra.NoArgs,
+	RunE: runConfigList,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the config file in $VISUAL or $EDITOR",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigEdit,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd, configEditCmd)
+}
+
+// configFilePath returns the config file in use, or where a new one goes.
+func configFilePath() (string, error) {
+	if path := viper.ConfigFileUsed(); path != "" {
+		return path, nil
+	}
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".goBili.yaml"), nil
+}
+
+func runConfigGet(_ *cobra.Command, args []string) error {
+	key := strings.ToLower(args[0])
+	if !viper.IsSet(key) {
+		return i18n.Errorf("%s is not set", key)
+	}
+	text, err := formatConfigValue(viper.Get(key))
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+	return nil
+}
+
+func runConfigSet(_ *cobra.Command, args []string) error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	key := strings.ToLower(args[0])
+	switch strings.ToLower(filepath.Ext(path)) {
+	case "", ".yaml", ".yml":
+		err = setYAMLConfig(path, key, args[1])
+	default:
+		err = setConfigAs(path, key, args[1])
+	}
+	if err != nil {
+		return err
+	}
+	i18n.Printf("Set %s in %s\n", args[0], path)
+	return nil
+}
+
+// setYAMLConfig sets key in the YAML config file at path, keeping its
+// comments and the order of the other settings.
+func setYAMLConfig(path, key, value string) error {
+	doc, err := readConfigNode(path)
+	if err != nil {
+		return err
+	}
+	if err := setConfigNode(doc, key, value); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return i18n.Errorf("failed to encode config: %w", err)
+	}
+	return writeConfigFile(path, buf.Bytes())
+}
+
+// setConfigAs sets key in a config file of another format viper reads,
+// such as JSON or TOML, and writes it back in the format of its extension.
+// The value is parsed as YAML, as for YAML config files.
+func setConfigAs(path, key, value string) error {
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return i18n.Errorf("invalid value %q: %w", value, err)
+	}
+	if parsed == nil {
+		parsed = value
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		if err := v.ReadInConfig(); err != nil {
+			return i18n.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if part == "" {
+			return i18n.Errorf("invalid key %q", key)
+		}
+		if i < len(parts)-1 {
+			section := strings.Join(parts[:i+1], ".")
+			if v.IsSet(section) && v.Get(section) != nil {
+				if _, ok := v.Get(section).(map[string]interface{}); !ok {
+					return i18n.Errorf("%s is not a section", section)
+				}
+			}
+		}
+	}
+	v.Set(key, parsed)
+	v.SetConfigPermissions(mode)
+	if err := v.WriteConfigAs(path); err != nil {
+		return i18n.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+func runConfigList(_ *cobra.Command, _ []string) error {
+	if path := viper.ConfigFileUsed(); path != "" {
+		fmt.Printf("# %s\n", path)
+	} else {
+		i18n.Println("# no config file, showing defaults")
+	}
+
+	settings := map[string]interface{}{}
+	flattenSettings("", viper.AllSettings(), settings)
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		text, err := formatConfigValue(settings[key])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s = %s\n", key, text)
+	}
+	return nil
+}
+
+func runConfigEdit(_ *cobra.Command, _ []string) error {
+	path, err := configFilePath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := writeConfigFile(path, nil); err != nil {
+			return err
+		}
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	// $EDITOR may carry arguments, e.g. "code --wait".
+	fields := strings.Fields(editor)
+	editCmd := exec.Command(fields[0], append(fields[1:], path)...)
+	editCmd.Stdin = os.Stdin
+	editCmd.Stdout = os.Stdout
+	editCmd.Stderr = os.Stderr
+	if err := editCmd.Run(); err != nil {
+		return i18n.Errorf("%s failed: %w", fields[0], err)
+	}
+
+	// Catch syntax errors now rather than on the next download.
+	if _, err := readConfigNode(path); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readConfigNode parses the config file at path, keeping its comments. A
+// missing or empty file yields an empty mapping.
+func readConfigNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, i18n.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, i18n.Errorf("invalid config file %s: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, i18n.Errorf("invalid config file %s: the top level must be a mapping", path)
+	}
+	return &doc, nil
+}
+
+// setConfigNode sets the dotted key in doc to value, parsed as YAML.
+// Sections on the way are created as needed.
+func setConfigNode(doc *yaml.Node, key, value string) error {
+	var parsed yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &parsed); err != nil {
+		return i18n.Errorf("invalid value %q: %w", value, err)
+	}
+	newValue := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if len(parsed.Content) > 0 {
+		newValue = parsed.Content[0]
+	}
+
+	node := doc.Content[0]
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		if part == "" {
+			return i18n.Errorf("invalid key %q", key)
+		}
+		child := mappingValue(node, part)
+		if i == len(parts)-1 {
+			if child == nil {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, newValue)
+				return nil
+			}
+			newValue.LineComment = child.LineComment
+			*child = *newValue
+			return nil
+		}
+		if child == nil {
+			child = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: part}, child)
+		} else if child.Kind != yaml.MappingNode {
+			return i18n.Errorf("%s is not a section", strings.Join(parts[:i+1], "."))
+		}
+		node = child
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, matching keys
+// case-insensitively like viper does, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// writeConfigFile writes the config file, keeping the permissions of an
+// existing one. New files are private, since they may hold upload
+// credentials.
+func writeConfigFile(path string, data []byte) error {
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return i18n.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// flattenSettings adds the leaves of settings to flat under dotted keys.
+func flattenSettings(prefix string, settings map[string]interface{}, flat map[string]interface{}) {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenSettings(prefix+key+".", nested, flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+}
+
+// formatConfigValue renders a setting: scalars as they are, lists and
+// sections as flow-style YAML.
+func formatConfigValue(value interface{}) (string, error) {
+	switch value.(type) {
+	case []interface{}, []string, map[string]interface{}:
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			return "", err
+		}
+		setFlowStyle(&node)
+		data, err := yaml.Marshal(&node)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// setFlowStyle renders node and its children on one line.
+func setFlowStyle(node *yaml.Node) {
+	node.Style = yaml.FlowStyle
+	for _, child := range node.Content {
+		setFlowStyle(child)
+	}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useConfigFile makes the config commands use path until the test ends.
+func useConfigFile(t *testing.T, path string) {
+	t.Helper()
+	saved := cfgFile
+	cfgFile = path
+	t.Cleanup(func() { cfgFile = saved })
+	if viper.ConfigFileUsed() != "" {
+		t.Skip("a config file is already loaded")
+	}
+}
+
+func TestConfigSet_YAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goBili.yaml")
+	if err := os.WriteFile(path, []byte("# my settings\nquality: 720p # for the laptop\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useConfigFile(t, path)
+
+	if err := runConfigSet(nil, []string{"quality", "1080p"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConfigSet(nil, []string{"danmaku.opacity", "0.6"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"# my settings", "quality: 1080p # for the laptop", "danmaku:\n  opacity: 0.6"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("config lacks %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestConfigSet_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goBili.json")
+	if err := os.WriteFile(path, []byte(`{"quality": "720p", "format": "mkv"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useConfigFile(t, path)
+
+	if err := runConfigSet(nil, []string{"quality", "1080p"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConfigSet(nil, []string{"danmaku.opacity", "0.6"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runConfigSet(nil, []string{"format.x", "1"}); err == nil {
+		t.Error("set a key below the format setting")
+	}
+
+	data, _ := os.ReadFile(path)
+	var got struct {
+		Quality string `json:"quality"`
+		Format  string `json:"format"`
+		Danmaku struct {
+			Opacity float64 `json:"opacity"`
+		} `json:"danmaku"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("config is no longer JSON: %v\n%s", err, data)
+	}
+	if got.Quality != "1080p" || got.Format != "mkv" || got.Danmaku.Opacity != 0.6 {
+		t.Errorf("config = %s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("config mode = %o, want 600", info.Mode().Perm())
+	}
+}
+
+func TestConfigSet_TOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goBili.toml")
+	if err := os.WriteFile(path, []byte("quality = \"720p\"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	useConfigFile(t, path)
+
+	if err := runConfigSet(nil, []string{"embed_subs", "true"}); err != nil {
+		t.Fatal(err)
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		data, _ := os.ReadFile(path)
+		t.Fatalf("config is no longer TOML: %v\n%s", err, data)
+	}
+	if v.GetString("quality") != "720p" || !v.GetBool("embed_subs") {
+		t.Errorf("config settings = %v", v.AllSettings())
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// coverCmd represents the cover command
+var coverCmd = &cobra.Command{
+	Use:   "cover <URL>",
+	Short: "Download the cover image of a video or season",
+	Long: `Download the full-size cover image of a video, multi-part video or
+bangumi season into the output directory, without the video. With
+--episodes the covers of the selected episodes of a season are saved too.
+
+The file name is a template with the fields of output_dir_template:
+{{.Title}}, {{.BVID}}, {{.Owner}}, {{.OwnerMID}}, {{.SeriesTitle}},
+{{.Category}}, {{.Year}}, {{.Month}} and {{.Day}}; "/" makes directories.
+The extension of the image is appended. Set a default with the
+cover.name config key.
+
+Examples:
+  goBili cover "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili cover --name "{{.Owner}}/{{.Year}}-{{.Month}}-{{.Day}} {{.Title}}" "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili cover --episodes -p 1-12 --name "{{.SeriesTitle}}/{{.Title}}" "https://www.bilibili.com/bangumi/play/ss12345"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCover,
+}
+
+func init() {
+	rootCmd.AddCommand(coverCmd)
+
+	coverCmd.Flags().String("name", "{{.Title}}", "file name template, without the extension")
+	coverCmd.Flags().Bool("episodes", false, "also save the covers of the episodes of a season")
+	coverCmd.Flags().StringP("pages", "p", "all", "with --episodes, the episodes to save (e.g., 1,2,3 or 1-5 or all)")
+
+	if err := viper.BindPFlag("cover.name", coverCmd.Flags().Lookup("name")); err != nil {
+		cobra.CheckErr(err)
+	}
+}
+
+func runCover(cmd *cobra.Command, args []string) error {
+	episodes, _ := cmd.Flags().GetBool("episodes")
+	pages, _ := cmd.Flags().GetString("pages")
+	name := viper.GetString("cover.name")
+
+	output := viper.GetString("output")
+	if output == "-" {
+		return fmt.Errorf("cover cannot write to stdout")
+	}
+	if err := downloader.ValidateOutputDirTemplate(name); err != nil {
+		return fmt.Errorf("invalid cover name: %w", err)
+	}
+
+	logger := newLogger()
+	p, authManager, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	covers, err := coverTargets(videoInfo, episodes, pages)
+	if err != nil {
+		return err
+	}
+	if len(covers) == 0 {
+		return fmt.Errorf("%s has no cover image", videoInfo.Title)
+	}
+
+	dl := downloader.NewDownloader(downloader.Config{
+		FFmpegPath:  viper.GetString("ffmpeg_path"),
+		AuthManager: authManager,
+	})
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var failed int
+	for _, info := range covers {
+		rel, err := downloader.RenderOutputTemplate(name, info)
+		if err != nil {
+			return fmt.Errorf("invalid cover name: %w", err)
+		}
+		if rel == "" {
+			// Every field of the template was empty.
+			rel = downloader.SanitizeFilename(info.Title)
+		}
+		dest := filepath.Join(output, rel+coverExtension(info.Cover))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		err = dl.SaveCover(ctx, info.Cover, dest)
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		if err != nil {
+			logger.Warnf("Failed to save the cover of %s: %v", info.Title, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Saved cover to %s\n", dest)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to save %d of %d covers", failed, len(covers))
+	}
+	return nil
+}
+
+// coverTargets returns one VideoInfo per cover to save, carrying the
+// template fields of its file name: the video or season itself and, with
+// episodes, every selected episode that has a cover of its own.
+func coverTargets(videoInfo *parser.VideoInfo, episodes bool, pages string) ([]*parser.VideoInfo, error) {
+	var covers []*parser.VideoInfo
+	if videoInfo.Cover != "" {
+		covers = append(covers, videoInfo)
+	}
+	if !episodes || len(videoInfo.Episodes) == 0 {
+		return covers, nil
+	}
+
+	selected, err := selectEpisodes(videoInfo, pages)
+	if err != nil {
+		return nil, err
+	}
+	for _, episode := range selected {
+		if episode.Cover == "" || episode.Cover == videoInfo.Cover {
+			continue
+		}
+		covers = append(covers, &parser.VideoInfo{
+			BVID:     episode.BVID,
+			Title:    episode.Title,
+			Uploader: videoInfo.Uploader,
+			OwnerMID: videoInfo.OwnerMID,
+			PubDate:  videoInfo.PubDate,
+			Category: videoInfo.Category,
+			Cover:    episode.Cover,
+			Series:   videoInfo.Title,
+		})
+	}
+	return covers, nil
+}
+
+// coverExtension returns the file extension of a cover image URL,
+// defaulting to .jpg.
+func coverExtension(coverURL string) string {
+	u, err := url.Parse(downloader.OriginalCoverURL(coverURL))
+	if err != nil {
+		return ".jpg"
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".webp", ".gif", ".avif":
+		return ext
+	}
+	return ".jpg"
+}
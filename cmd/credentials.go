@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/dengmengmian/goBili/auth"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// passphraseEnv names the environment variable holding the passphrase of
+// the encrypted credential store, for unattended runs.
+const passphraseEnv = "GOBILI_PASSPHRASE"
+
+// credentialPassphrase caches the passphrase so commands touching several
+// profiles prompt only once.
+var credentialPassphrase string
+
+// newAuthManager creates the auth manager of the named profile with the
+// credential store selected by the credential_store key and the request
+// headers configured for the profile.
+func newAuthManager(profile string, logger *logrus.Logger) (*auth.AuthManager, error) {
+	authManager := auth.NewAuthManager(profileDir(profile), logger)
+
+	fp, err := profileFingerprint(profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := authManager.SetFingerprint(fp); err != nil {
+		return nil, err
+	}
+
+	kind := viper.GetString("credential_store")
+	if err := auth.ValidateCredentialStore(kind); err != nil {
+		return nil, err
+	}
+	var passphrase string
+	if kind == auth.StoreEncrypted {
+		var err error
+		if passphrase, err = readCredentialPassphrase(); err != nil {
+			return nil, err
+		}
+	}
+	if err := authManager.SetCredentialStore(kind, passphrase); err != nil {
+		return nil, err
+	}
+	return authManager, nil
+}
+
+// newAnonymousAuthManager creates a guest auth manager with the request
+// headers configured for the active profile.
+func newAnonymousAuthManager(logger *logrus.Logger) (*auth.AuthManager, error) {
+	authManager := auth.NewAnonymousAuthManager(logger)
+	fp, err := profileFingerprint(activeProfile())
+	if err != nil {
+		return nil, err
+	}
+	if err := authManager.SetFingerprint(fp); err != nil {
+		return nil, err
+	}
+	return authManager, nil
+}
+
+// readCredentialPassphrase returns the passphrase of the encrypted
+// credential store from the environment or, on a terminal, a prompt.
+func readCredentialPassphrase() (string, error) {
+	if credentialPassphrase != "" {
+		return credentialPassphrase, nil
+	}
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		var err error
+		passphrase, err = promptPassword(bufio.NewReader(os.Stdin), "Credential passphrase: ")
+		if err != nil {
+			return "", err
+		}
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("the encrypted credential store needs a passphrase (set %s)", passphraseEnv)
+	}
+	credentialPassphrase = passphrase
+	return passphrase, nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dengmengmian/goBili/server"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// daemonCmd represents the daemon command
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run the downloads in the queue",
+	Long: `Run the downloads added with 'goBili queue add' until stopped, waiting
+for new ones when the queue is empty. Up to --concurrency downloads run at
+once, with the download flags and config given here; a queued download
+may choose its own quality and pages.
+
+The daemon keeps no HTTP port open; use 'goBili serve' to queue and watch
+downloads from other machines. Several daemons, on one machine or
+sharing a SQL state store, split the queue between them.
+
+Ctrl+C or SIGTERM stops the daemon; running downloads are interrupted
+and run again on the next start.
+
+Examples:
+  goBili queue add "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili daemon --concurrency 2 -o ~/Videos`,
+	Args: cobra.NoArgs,
+	RunE: runDaemon,
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+
+	// The download flags are added in download.go's init, once they exist.
+	daemonCmd.Flags().IntP("concurrency", "j", 1, "downloads to run at once")
+
+	if err := viper.BindPFlag("daemon.concurrency", daemonCmd.Flags().Lookup("concurrency")); err != nil {
+		cobra.CheckErr(err)
+	}
+}
+
+func runDaemon(cmd *cobra.Command, _ []string) error {
+	if viper.GetString("output") == "-" {
+		return fmt.Errorf("daemon cannot stream to stdout")
+	}
+	concurrency := viper.GetInt("daemon.concurrency")
+	if concurrency < 1 {
+		return fmt.Errorf("invalid concurrency %d (want 1 or more)", concurrency)
+	}
+	logger := newLogger()
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open the job queue: %w", err)
+	}
+	defer st.Close()
+
+	srv := server.New(server.Options{
+		Store:   st,
+		Run:     jobRunner(cmd, st),
+		Workers: concurrency,
+		Logger:  logger,
+	})
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	logger.Infof("Running queued downloads, %d at a time", concurrency)
+	srv.Work(ctx)
+	logger.Info("Daemon stopped")
+	return nil
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// danmakuCmd represents the danmaku command
+var danmakuCmd = &cobra.Command{
+	Use:   "danmaku <URL>",
+	Short: "Download the danmaku of a video without the video",
+	Long: `Fetch the danmaku (bullet comments) of a video, multi-part video or
+bangumi and save them, one file per part, in the output directory:
+as Bilibili's XML, as ASS subtitles laid out like 'download --write-danmaku'
+does, or as JSON. Use it to add comments to videos you already have.
+
+The ASS layout uses the danmaku.* config keys and --danmaku-font,
+--danmaku-opacity and --danmaku-max; pass --resolution to match the video.
+With -o - a single part is written to stdout.
+
+Examples:
+  goBili danmaku "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili danmaku --format ass --resolution 1280x720 -o ~/Videos "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili danmaku --format json -p 2 -o - "https://www.bilibili.com/bangumi/play/ss12345" | jq length`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDanmaku,
+}
+
+func init() {
+	rootCmd.AddCommand(danmakuCmd)
+
+	danmakuCmd.Flags().String("format", "xml", "file format: xml, ass or json")
+	danmakuCmd.Flags().StringP("pages", "p", "all", "parts of a multi-part video or playlist (e.g., 1,2,3 or 1-5 or all)")
+	danmakuCmd.Flags().String("resolution", "1920x1080", "video size the ASS subtitles are laid out for")
+	danmakuCmd.Flags().String("danmaku-font", "", "font for ASS danmaku (default Microsoft YaHei)")
+	danmakuCmd.Flags().Float64("danmaku-opacity", 0, "ASS danmaku opacity from 0 to 1 (default 0.8)")
+	danmakuCmd.Flags().Int("danmaku-max", 0, "maximum ASS danmaku on screen at once, 0 for no limit")
+}
+
+func runDanmaku(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	pages, _ := cmd.Flags().GetString("pages")
+	resolution, _ := cmd.Flags().GetString("resolution")
+
+	format = strings.ToLower(format)
+	if format != "xml" && format != "ass" && format != "json" {
+		return fmt.Errorf("invalid format %q (want xml, ass or json)", format)
+	}
+	width, height, err := parseVideoSize(resolution)
+	if err != nil {
+		return err
+	}
+	style := danmakuStyleFromConfig()
+	if cmd.Flags().Changed("danmaku-font") {
+		style.Font, _ = cmd.Flags().GetString("danmaku-font")
+	}
+	if cmd.Flags().Changed("danmaku-opacity") {
+		style.Opacity, _ = cmd.Flags().GetFloat64("danmaku-opacity")
+	}
+	if cmd.Flags().Changed("danmaku-max") {
+		style.MaxOnScreen, _ = cmd.Flags().GetInt("danmaku-max")
+	}
+
+	logger := newLogger()
+	output := viper.GetString("output")
+	if output == "-" && !viper.GetBool("verbose") {
+		// Keep stdout free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, _, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	parts, err := selectParts(videoInfo, pages)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("no parts selected by --pages %s", pages)
+	}
+	if output == "-" && len(parts) > 1 {
+		return fmt.Errorf("-o - writes one part; choose it with --pages")
+	}
+	if output != "-" {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	var failed int
+	for _, part := range parts {
+		items, err := p.GetDanmaku(part.cid)
+		if err != nil {
+			logger.Warnf("Failed to fetch danmaku for %s: %v", part.title, err)
+			failed++
+			continue
+		}
+		data, err := encodeDanmaku(items, format, width, height, style)
+		if err != nil {
+			return err
+		}
+
+		if output == "-" {
+			if _, err := os.Stdout.Write(data); err != nil {
+				return fmt.Errorf("failed to write danmaku: %w", err)
+			}
+			continue
+		}
+		path := filepath.Join(output, downloader.SanitizeFilename(part.title)+danmakuExtension(format))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to save danmaku: %w", err)
+		}
+		fmt.Printf("Saved %d danmaku to %s\n", len(items), path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to fetch the danmaku of %d of %d parts", failed, len(parts))
+	}
+	return nil
+}
+
+// encodeDanmaku renders items in format; ASS is laid out for a width x
+// height video.
+func encodeDanmaku(items []parser.Danmaku, format string, width, height int, style downloader.DanmakuStyle) ([]byte, error) {
+	switch format {
+	case "ass":
+		return downloader.DanmakuToASS(items, width, height, style), nil
+	case "json":
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode danmaku: %w", err)
+		}
+		return append(data, '\n'), nil
+	default:
+		return parser.EncodeDanmakuXML(items), nil
+	}
+}
+
+// danmakuExtension returns the file suffix for format, matching the
+// sidecars 'download --write-danmaku' writes.
+func danmakuExtension(format string) string {
+	return ".danmaku." + format
+}
+
+// parseVideoSize parses a WIDTHxHEIGHT size such as 1920x1080.
+func parseVideoSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution %q (want e.g. 1920x1080)", s)
+	}
+	return width, height, nil
+}
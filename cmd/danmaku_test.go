@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestParseVideoSize(t *testing.T) {
+	w, h, err := parseVideoSize("1280X720")
+	if err != nil || w != 1280 || h != 720 {
+		t.Errorf("parseVideoSize(1280X720) = %d, %d, %v", w, h, err)
+	}
+	for _, s := range []string{"", "1280", "1280x", "x720", "0x720", "-1x720", "axb"} {
+		if _, _, err := parseVideoSize(s); err == nil {
+			t.Errorf("parseVideoSize(%q) succeeded", s)
+		}
+	}
+}
+
+func TestEncodeDanmaku(t *testing.T) {
+	items := []parser.Danmaku{
+		{Time: 1.5, Mode: 1, Size: 25, Color: 0xffffff, Text: "hello"},
+		{Time: 3, Mode: 5, Size: 25, Color: 0xff0000, Text: "top"},
+	}
+	style := downloader.DanmakuStyle{}
+
+	data, err := encodeDanmaku(items, "xml", 1920, 1080, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parser.ParseDanmakuXML(data)
+	if err != nil {
+		t.Fatalf("ParseDanmakuXML: %v", err)
+	}
+	if len(got) != len(items) || got[0].Text != "hello" || got[1].Mode != 5 {
+		t.Errorf("xml round trip = %+v", got)
+	}
+
+	data, err = encodeDanmaku(items, "json", 1920, 1080, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []parser.Danmaku
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json output: %v", err)
+	}
+	if len(decoded) != len(items) || decoded[1].Text != "top" || decoded[0].Time != 1.5 {
+		t.Errorf("json round trip = %+v", decoded)
+	}
+
+	data, err = encodeDanmaku(items, "ass", 1280, 720, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ass := string(data)
+	for _, want := range []string{"PlayResX: 1280", "PlayResY: 720", "hello", "top"} {
+		if !strings.Contains(ass, want) {
+			t.Errorf("ass output missing %q", want)
+		}
+	}
+}
+
+func TestDanmakuExtension(t *testing.T) {
+	if got := danmakuExtension("ass"); got != ".danmaku.ass" {
+		t.Errorf("danmakuExtension(ass) = %q", got)
+	}
+}
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorTimeout bounds each network and tool check.
+const doctorTimeout = 10 * time.Second
+
+// doctorHosts are probed for reachability: the API, the login service and
+// the main video and image CDNs. Any HTTP answer counts as reachable.
+var doctorHosts = []string{
+	"api.bilibili.com",
+	"passport.bilibili.com",
+	"upos-sz-mirrorcos.bilivideo.com",
+	"upos-sz-mirrorali.bilivideo.com",
+	"upos-sz-mirrorhw.bilivideo.com",
+	"i0.hdslb.com",
+}
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment and suggest fixes",
+	Long: `Check what goBili depends on and print a fix for every problem found:
+the config file syntax, ffmpeg (and MP4Box as a fallback), whether
+api.bilibili.com and the video CDNs are reachable, whether the login of
+the active profile is still valid, and whether the output directory is
+writable. Exits with an error when a check fails.`,
+	Args: cobra.NoArgs,
+	RunE: runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// checkLevel grades a doctor check.
+type checkLevel int
+
+const (
+	checkOK checkLevel = iota
+	checkWarn
+	checkFail
+)
+
+// checkResult is the outcome of one doctor check. Fix says what to do
+// about a warning or failure.
+type checkResult struct {
+	name   string
+	level  checkLevel
+	detail string
+	fix    string
+}
+
+func runDoctor(_ *cobra.Command, _ []string) error {
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// Keep the report free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+
+	results := []checkResult{checkConfigFile()}
+	results = append(results, checkMuxers()...)
+	results = append(results, checkNetwork()...)
+	results = append(results, checkLoginState(logger), checkOutputDir())
+
+	var failed int
+	for _, r := range results {
+		label := map[checkLevel]string{checkOK: "ok", checkWarn: "warn", checkFail: "FAIL"}[r.level]
+		color := map[checkLevel]style{checkOK: styleOK, checkWarn: styleWarn, checkFail: styleFail}[r.level]
+		fmt.Printf("[%s] %-34s %s\n", color.paint(fmt.Sprintf("%-4s", label)), r.name, r.detail)
+		if r.level != checkOK && r.fix != "" {
+			fmt.Printf("       %-34s -> %s\n", "", r.fix)
+		}
+		if r.level == checkFail {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	fmt.Printf("\n%s No problems found.\n", styleOK.mark())
+	return nil
+}
+
+// checkConfigFile parses the config file on its own, since a syntax error
+// otherwise only makes goBili ignore the file.
+func checkConfigFile() checkResult {
+	r := checkResult{name: "config file"}
+	path, err := configFilePath()
+	if err != nil {
+		r.level, r.detail = checkWarn, err.Error()
+		return r
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		r.detail = path + " does not exist; using the defaults"
+		return r
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if filepath.Ext(path) == "" {
+		v.SetConfigType("yaml")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		r.level, r.detail = checkFail, fmt.Sprintf("%s: %v", path, err)
+		r.fix = "fix the syntax with 'goBili config edit'; until then the file is ignored"
+		return r
+	}
+	r.detail = path
+	return r
+}
+
+// checkMuxers looks for ffmpeg, and for MP4Box when ffmpeg is missing.
+func checkMuxers() []checkResult {
+	ffmpeg := viper.GetString("ffmpeg_path")
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	r := checkResult{name: "ffmpeg"}
+	version, path, err := toolVersion(ffmpeg, "-version")
+	if err == nil {
+		r.detail = fmt.Sprintf("%s (%s)", version, path)
+		return []checkResult{r}
+	}
+
+	mp4box := viper.GetString("mp4box_path")
+	if mp4box == "" {
+		mp4box = "MP4Box"
+	}
+	fallback := checkResult{name: "MP4Box"}
+	if version, path, boxErr := toolVersion(mp4box, "-version"); boxErr == nil {
+		r.level, r.detail = checkWarn, err.Error()
+		r.fix = "install ffmpeg (https://ffmpeg.org/download.html) or set --ffmpeg-path; MP4Box only merges MP4"
+		fallback.detail = fmt.Sprintf("%s (%s)", version, path)
+		return []checkResult{r, fallback}
+	}
+	r.level, r.detail = checkFail, err.Error()
+	r.fix = "install ffmpeg (https://ffmpeg.org/download.html) or set --ffmpeg-path; without it video and audio stay separate"
+	return []checkResult{r}
+}
+
+// toolVersion runs bin with the version flag and returns the first line of
+// its output and the resolved path.
+func toolVersion(bin, flag string) (version, path string, err error) {
+	path, err = exec.LookPath(bin)
+	if err != nil {
+		return "", "", fmt.Errorf("%s not found", bin)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	// MP4Box prints its version to stderr and exits non-zero.
+	out, runErr := exec.CommandContext(ctx, path, flag).CombinedOutput()
+	version, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if version == "" {
+		if runErr != nil {
+			return "", path, fmt.Errorf("%s does not run: %v", path, runErr)
+		}
+		version = "unknown version"
+	}
+	return strings.TrimSpace(version), path, nil
+}
+
+// checkNetwork probes doctorHosts concurrently.
+func checkNetwork() []checkResult {
+	client := &http.Client{
+		Timeout: doctorTimeout,
+		// An answer is enough; redirects do not matter.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	results := make([]checkResult, len(doctorHosts))
+	var wg sync.WaitGroup
+	for i, host := range doctorHosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			results[i] = probeHost(client, host)
+		}(i, host)
+	}
+	wg.Wait()
+	return results
+}
+
+// probeHost sends a HEAD request to host over HTTPS.
+func probeHost(client *http.Client, host string) checkResult {
+	r := checkResult{name: host}
+	req, err := http.NewRequest(http.MethodHead, "https://"+host+"/", nil)
+	if err != nil {
+		r.level, r.detail = checkFail, err.Error()
+		return r
+	}
+	req.Header.Set("User-Agent", viper.GetString("user_agent"))
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		r.level, r.detail = checkFail, err.Error()
+		r.fix = "check the network, DNS and HTTPS_PROXY; a firewall may block Bilibili"
+		if strings.Contains(host, "bilivideo") || strings.Contains(host, "hdslb") {
+			// Other CDN nodes may still work.
+			r.level = checkWarn
+		}
+		return r
+	}
+	resp.Body.Close()
+	r.detail = fmt.Sprintf("reachable in %s", time.Since(start).Round(time.Millisecond))
+	return r
+}
+
+// checkLoginState checks that the active profile is logged in and that the
+// session is not about to expire.
+func checkLoginState(logger *logrus.Logger) checkResult {
+	r := checkResult{name: "login (" + activeProfile() + ")"}
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		r.level, r.detail = checkFail, err.Error()
+		r.fix = "check --credential-store and GOBILI_PASSPHRASE"
+		return r
+	}
+	if err := authManager.LoadCookies(); err != nil {
+		r.level, r.detail = checkFail, fmt.Sprintf("failed to load cookies: %v", err)
+		r.fix = "log in again with 'goBili login'"
+		return r
+	}
+	if !authManager.IsAuthenticated() {
+		r.level, r.detail = checkWarn, "not logged in; downloads need --allow-anonymous and stop at 480p"
+		r.fix = "log in with 'goBili login'"
+		return r
+	}
+	status, err := authManager.GetAccountStatus()
+	if err != nil {
+		r.level, r.detail = checkWarn, fmt.Sprintf("could not check the cookies: %v", err)
+		return r
+	}
+	if !status.IsLogin {
+		r.level, r.detail = checkFail, "the saved session has expired"
+		r.fix = "log in again with 'goBili login'"
+		return r
+	}
+	r.detail = fmt.Sprintf("%s (UID %d, %s)", status.Name, status.Mid, status.VipTier())
+	if expiry, ok := authManager.SessionExpiry(); ok && time.Until(expiry) < 7*24*time.Hour && authManager.RefreshToken() == "" {
+		r.level = checkWarn
+		r.detail += fmt.Sprintf("; the session expires in %s", formatDays(time.Until(expiry)))
+		r.fix = "log in with the QR code ('goBili login') so cookies renew automatically"
+	}
+	return r
+}
+
+// checkOutputDir checks that a file can be created in the output
+// directory.
+func checkOutputDir() checkResult {
+	dir := viper.GetString("output")
+	r := checkResult{name: "output directory"}
+	if dir == "-" {
+		r.detail = "stdout"
+		return r
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		r.level, r.detail = checkFail, err.Error()
+		r.fix = "choose another directory with -o or the output config key"
+		return r
+	}
+	f, err := os.CreateTemp(dir, ".goBili-doctor-*")
+	if err != nil {
+		r.level, r.detail = checkFail, fmt.Sprintf("%s is not writable: %v", dir, err)
+		r.fix = "fix the permissions or choose another directory with -o"
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+	r.detail = dir + " is writable"
+	return r
+}
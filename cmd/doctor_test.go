@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func TestCheckConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "goBili.yaml")
+	useConfigFile(t, path)
+
+	if r := checkConfigFile(); r.level != checkOK || !strings.Contains(r.detail, "does not exist") {
+		t.Errorf("missing file: %+v", r)
+	}
+
+	if err := os.WriteFile(path, []byte("quality: 1080p\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r := checkConfigFile(); r.level != checkOK || r.detail != path {
+		t.Errorf("valid file: %+v", r)
+	}
+
+	if err := os.WriteFile(path, []byte("quality: [1080p\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r := checkConfigFile(); r.level != checkFail || r.fix == "" {
+		t.Errorf("broken file: %+v", r)
+	}
+}
+
+func TestCheckMuxers_Missing(t *testing.T) {
+	viper.Set("ffmpeg_path", "goBili-no-such-ffmpeg")
+	viper.Set("mp4box_path", "goBili-no-such-mp4box")
+	defer viper.Set("ffmpeg_path", nil)
+	defer viper.Set("mp4box_path", nil)
+
+	results := checkMuxers()
+	if len(results) != 1 || results[0].level != checkFail || !strings.Contains(results[0].detail, "not found") {
+		t.Errorf("checkMuxers() = %+v, want one failed ffmpeg check", results)
+	}
+}
+
+func TestProbeHost(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	}))
+	defer srv.Close()
+	client := srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	host := strings.TrimPrefix(srv.URL, "https://")
+	if r := probeHost(client, host); r.level != checkOK || !strings.HasPrefix(r.detail, "reachable") {
+		t.Errorf("reachable host: %+v", r)
+	}
+
+	srv.Close()
+	if r := probeHost(client, host); r.level != checkFail || r.fix == "" {
+		t.Errorf("unreachable API host: %+v", r)
+	}
+	// A dead CDN node is only a warning.
+	if r := probeHost(client, "upos.bilivideo.invalid"); r.level != checkWarn {
+		t.Errorf("unreachable CDN host: %+v", r)
+	}
+}
+
+func TestCheckLoginState_NotLoggedIn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+
+	if r := checkLoginState(logger); r.level != checkWarn || !strings.Contains(r.detail, "not logged in") {
+		t.Errorf("checkLoginState() = %+v", r)
+	}
+}
+
+func TestCheckOutputDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "videos")
+	viper.Set("output", dir)
+	defer viper.Set("output", nil)
+
+	if r := checkOutputDir(); r.level != checkOK {
+		t.Errorf("new directory: %+v", r)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("probe file left behind: %v", entries)
+	}
+
+	// A path below a regular file cannot be created.
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("output", filepath.Join(file, "videos"))
+	if r := checkOutputDir(); r.level != checkFail || r.fix == "" {
+		t.Errorf("blocked directory: %+v", r)
+	}
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// newExistingFlagsCommand returns a command with the existing-file flags
+// existingPolicyFromFlags reads.
+func newExistingFlagsCommand() *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("skip-existing", false, "")
+	cmd.Flags().Bool("force-overwrite", false, "")
+	return cmd
+}
+
+func TestExistingPolicyFromFlags(t *testing.T) {
+	defer viper.Set("existing", nil)
+	tests := []struct {
+		name   string
+		args   []string
+		config string
+		want   downloader.ExistingPolicy
+	}{
+		// A rerun must not replace finished downloads unless asked to.
+		{"default", nil, "", downloader.ExistingSkip},
+		{"config", nil, "rename", downloader.ExistingRename},
+		{"config overwrite", nil, "overwrite", downloader.ExistingOverwrite},
+		{"--force-overwrite", []string{"--force-overwrite"}, "", downloader.ExistingOverwrite},
+		{"--skip-existing over the config", []string{"--skip-existing"}, "overwrite", downloader.ExistingSkip},
+	}
+	for _, tt := range tests {
+		cmd := newExistingFlagsCommand()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		viper.Set("existing", tt.config)
+		got, err := existingPolicyFromFlags(cmd)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: existingPolicyFromFlags = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	viper.Set("existing", "replace")
+	if _, err := existingPolicyFromFlags(newExistingFlagsCommand()); err == nil {
+		t.Error("existingPolicyFromFlags accepted existing: replace")
+	}
+}
+
+func TestNewDownloadSession_NeedsLogin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	viper.Set("output", t.TempDir())
+	defer viper.Set("output", nil)
+
+	// Without a saved login, downloads stop before any request unless
+	// --allow-anonymous is given.
+	_, err := newDownloadSession(downloadCmd, sessionOverrides{})
+	if !errors.Is(err, downloader.ErrAuthRequired) {
+		t.Fatalf("newDownloadSession err = %v, want ErrAuthRequired", err)
+	}
+	if code := ExitCode(err); code != ExitAuthRequired {
+		t.Errorf("exit code = %d, want %d", code, ExitAuthRequired)
+	}
+}
+
+func TestAnonymousQuality(t *testing.T) {
+	for label, want := range map[string]string{
+		"best":  "480p",
+		"1080p": "480p",
+		"720p":  "480p",
+		"480p":  "480p",
+		"360p":  "360p",
+	} {
+		if got := anonymousQuality(label); got != want {
+			t.Errorf("anonymousQuality(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestDownloadURLs(t *testing.T) {
+	stdin := strings.NewReader("# watch later\nhttps://b23.tv/b\n\n  https://b23.tv/c  \nhttps://b23.tv/a\n")
+	got, err := downloadURLs([]string{"https://b23.tv/a", "-", " https://b23.tv/d", "-"}, stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stdin is read once, in place of the first "-", and repeats are dropped.
+	want := []string{"https://b23.tv/a", "https://b23.tv/b", "https://b23.tv/c", "https://b23.tv/d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("downloadURLs = %q, want %q", got, want)
+	}
+
+	if _, err := downloadURLs([]string{"-"}, iotest.ErrReader(errors.New("broken pipe"))); err == nil {
+		t.Error("downloadURLs succeeded with an unreadable stdin")
+	}
+}
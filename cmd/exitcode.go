@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"net"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+)
+
+// Exit codes, so scripts can tell failures apart without parsing stderr.
+const (
+	ExitFailure        = 1   // any other error, including usage errors
+	ExitAuthRequired   = 2   // not logged in, or the login session expired
+	ExitParseError     = 3   // the URL could not be parsed or names no video
+	ExitNetwork        = 4   // Bilibili or its CDN could not be reached
+	ExitPartialFailure = 5   // some videos or URLs of the run failed
+	ExitNoMuxer        = 6   // neither ffmpeg nor MP4Box is available
+	ExitDiskFull       = 7   // the output could not be written
+	ExitInterrupted    = 130 // Ctrl+C or SIGTERM, as for a shell
+)
+
+// exitError is an error with the exit code it stands for.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// withExitCode returns err with the exit code code.
+func withExitCode(code int, err error) error {
+	return &exitError{code: code, err: err}
+}
+
+// parseURLError is the error of a URL that ParseURL rejected.
+func parseURLError(err error) error {
+	return withExitCode(ExitParseError, i18n.Errorf("failed to parse URL: %w", err))
+}
+
+// ExitCode returns the exit code for an error returned by Execute: 0 for
+// nil, and otherwise the code of its cause. The causes are checked before
+// the codes attached with withExitCode, and network failures before login
+// failures, so that a URL that could not be parsed, or a login that could
+// not be checked, because the network is down exits with ExitNetwork.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var netErr net.Error
+	var authErr *auth.AuthError
+	var coded *exitError
+	switch {
+	case errors.Is(err, errInterrupted):
+		return ExitInterrupted
+	case errors.Is(err, downloader.ErrNetworkTimeout), errors.Is(err, downloader.ErrServerError),
+		errors.Is(err, downloader.ErrURLExpired), errors.As(err, &netErr):
+		return ExitNetwork
+	case errors.Is(err, downloader.ErrAuthRequired), errors.As(err, &authErr):
+		return ExitAuthRequired
+	case errors.Is(err, downloader.ErrNoMuxer):
+		return ExitNoMuxer
+	case errors.Is(err, downloader.ErrDiskFull):
+		return ExitDiskFull
+	case errors.As(err, &coded):
+		return coded.code
+	}
+	return ExitFailure
+}
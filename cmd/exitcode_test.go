@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/downloader"
+)
+
+func TestExitCode(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host", Name: "api.bilibili.com", IsTimeout: true}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"other", errors.New("boom"), ExitFailure},
+		{"interrupted", fmt.Errorf("download: %w", errInterrupted), ExitInterrupted},
+		{"auth required", fmt.Errorf("video: %w", downloader.ErrAuthRequired), ExitAuthRequired},
+		{"auth error", &auth.AuthError{Op: "get video streams", Err: &auth.APIError{Code: -101}}, ExitAuthRequired},
+		{"session expired", &auth.AuthError{Op: "check session", Err: auth.ErrSessionExpired}, ExitAuthRequired},
+		{"auth error from the network", &auth.AuthError{Op: "check session", Err: dnsErr}, ExitNetwork},
+		{"network", fmt.Errorf("get: %w", dnsErr), ExitNetwork},
+		{"timeout", downloader.ErrNetworkTimeout, ExitNetwork},
+		{"server error", fmt.Errorf("cdn: %w", downloader.ErrServerError), ExitNetwork},
+		{"parse error", parseURLError(errors.New("not a video")), ExitParseError},
+		{"parse error from the network", parseURLError(dnsErr), ExitNetwork},
+		{"no muxer", downloader.ErrNoMuxer, ExitNoMuxer},
+		{"disk full", fmt.Errorf("write: %w", downloader.ErrDiskFull), ExitDiskFull},
+		{"partial failure", withExitCode(ExitPartialFailure, errors.New("2 of 5 failed")), ExitPartialFailure},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("%s: ExitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
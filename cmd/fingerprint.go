@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dengmengmian/goBili/auth"
+
+	"github.com/spf13/viper"
+)
+
+// profileFingerprint returns the request headers configured for profile.
+// The --user-agent, --referer and --header flags win over the
+// profiles.<name> section of the config file, which wins over the
+// top-level user_agent, referer and headers keys. Extra headers are merged
+// by name.
+func profileFingerprint(profile string) (auth.Fingerprint, error) {
+	fp := auth.Fingerprint{
+		UserAgent: viper.GetString("user_agent"),
+		Referer:   viper.GetString("referer"),
+		Headers:   viper.GetStringMapString("headers"),
+	}
+
+	section := "profiles." + profile + "."
+	if ua := viper.GetString(section + "user_agent"); ua != "" {
+		fp.UserAgent = ua
+	}
+	if referer := viper.GetString(section + "referer"); referer != "" {
+		fp.Referer = referer
+	}
+	for name, value := range viper.GetStringMapString(section + "headers") {
+		fp.Headers[name] = value
+	}
+
+	flags := rootCmd.PersistentFlags()
+	if ua, _ := flags.GetString("user-agent"); ua != "" {
+		fp.UserAgent = ua
+	}
+	if referer, _ := flags.GetString("referer"); referer != "" {
+		fp.Referer = referer
+	}
+	headers, _ := flags.GetStringArray("header")
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fp, fmt.Errorf("invalid header %q: expected \"Name: value\"", header)
+		}
+		fp.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	if err := auth.ValidateFingerprint(fp); err != nil {
+		return fp, fmt.Errorf("invalid request headers for profile %s: %w", profile, err)
+	}
+	return fp, nil
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/quality"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// formatsCmd represents the formats command
+var formatsCmd = &cobra.Command{
+	Use:   "formats <URL>",
+	Short: "List the available video and audio formats of a video",
+	Long: `List every DASH representation of one part of a video: the format id,
+quality, resolution, frame rate, codec, bandwidth and estimated size,
+followed by the audio tracks. Pass the ids to 'goBili download --format-id'
+to download exactly those formats, as VIDEO or VIDEO+AUDIO.
+
+Examples:
+  goBili formats "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili download --format-id 80-hevc+30280 "https://www.bilibili.com/video/BV1xx411c7mu"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runFormats,
+}
+
+func init() {
+	rootCmd.AddCommand(formatsCmd)
+
+	formatsCmd.Flags().IntP("page", "p", 1, "part of a multi-part video or playlist to list")
+}
+
+// formatsReport is the --json output of the formats command.
+type formatsReport struct {
+	Title string        `json:"title"`
+	Page  int           `json:"page"`
+	Video []infoFormat  `json:"video"`
+	Audio []audioFormat `json:"audio"`
+}
+
+// audioFormat describes one audio track.
+type audioFormat struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name,omitempty"`
+	Codecs        string `json:"codecs"`
+	Bandwidth     int    `json:"bandwidth"`
+	EstimatedSize int64  `json:"estimated_size"`
+}
+
+func runFormats(cmd *cobra.Command, args []string) error {
+	page, _ := cmd.Flags().GetInt("page")
+
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// Keep the table free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, _, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	title, streams, err := pageStreams(p, videoInfo, page)
+	if err != nil {
+		return err
+	}
+	duration := pageDuration(videoInfo, page)
+
+	if jsonOutput(cmd) {
+		report := formatsReport{Title: title, Page: page, Video: infoFormats(streams, duration), Audio: []audioFormat{}}
+		// Every DASH stream carries the same audio tracks.
+		if len(streams) > 0 {
+			for _, track := range streams[0].AudioTracks {
+				report.Audio = append(report.Audio, audioFormat{
+					ID:            track.ID,
+					Name:          parser.AudioTrackName(track.ID),
+					Codecs:        track.Codecs,
+					Bandwidth:     track.Bandwidth,
+					EstimatedSize: int64(track.Bandwidth) * int64(duration) / 8,
+				})
+			}
+		}
+		return printJSON("formats", report)
+	}
+
+	fmt.Printf("Formats of %s:\n\n", title)
+	printFormats(streams, duration)
+	return nil
+}
+
+// qualityRequires returns the account a quality id needs: "vip", "login",
+// or "" for none and unknown ids.
+func qualityRequires(qn int) string {
+	q, _ := quality.ByQN(qn)
+	return q.Requires()
+}
+
+// printFormats writes the video formats of streams, best quality first,
+// and their audio tracks as tables. Sizes are estimated from the bandwidth
+// for a part of duration seconds.
+func printFormats(streams []*parser.StreamInfo, duration int) {
+	sorted := append([]*parser.StreamInfo(nil), streams...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Quality > sorted[j].Quality
+	})
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tQUALITY\tRESOLUTION\tFPS\tCODEC\tBANDWIDTH\tSIZE\tACCESS")
+	for _, s := range sorted {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(s.FormatID), quality.Name(s.Quality),
+			s.Resolution, orDash(s.FrameRate), s.VideoCodecs, formatBandwidth(s.Bandwidth), estimateSize(s.Bandwidth, duration),
+			orDash(qualityRequires(s.Quality)))
+	}
+	tw.Flush()
+
+	// Every DASH stream carries the same audio tracks.
+	if len(streams) == 0 || len(streams[0].AudioTracks) == 0 {
+		return
+	}
+	fmt.Println()
+	tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tCODEC\tBANDWIDTH\tSIZE")
+	for _, track := range streams[0].AudioTracks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", strconv.Itoa(track.ID), orDash(parser.AudioTrackName(track.ID)),
+			track.Codecs, formatBandwidth(track.Bandwidth), estimateSize(track.Bandwidth, duration))
+	}
+	tw.Flush()
+}
+
+// formatBandwidth renders bits per second as kbps or Mbps.
+func formatBandwidth(bps int) string {
+	switch {
+	case bps <= 0:
+		return "-"
+	case bps >= 1000000:
+		return fmt.Sprintf("%.1f Mbps", float64(bps)/1000000)
+	default:
+		return fmt.Sprintf("%d kbps", bps/1000)
+	}
+}
+
+// estimateSize renders the size of duration seconds at bps, or "-" when
+// the bandwidth is unknown.
+func estimateSize(bps, duration int) string {
+	if bps <= 0 || duration <= 0 {
+		return "-"
+	}
+	return "~" + formatSize(int64(bps)*int64(duration)/8)
+}
+
+// orDash returns s, or "-" when it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/pkg/gobili"
+)
+
+// printStreamURLs implements --get-url: it resolves the streams of every
+// selected page and prints their direct URLs on stdout, one per line, video
+// before audio. The request headers the CDN expects go to stderr as "#"
+// comments so the URL list can be piped into other tools.
+func printStreamURLs(ctx context.Context, p *parser.BilibiliParser, dl *downloader.Downloader, videoInfo *parser.VideoInfo, pages string) error {
+	if videoInfo.Type == "video" && len(videoInfo.Pages) <= 1 {
+		streams, err := p.GetVideoStreams(videoInfo)
+		if err != nil {
+			return fmt.Errorf("failed to get video streams: %w", err)
+		}
+		return printResolved(ctx, dl, videoInfo.Title, streams)
+	}
+
+	episodes, err := selectEpisodes(videoInfo, pages)
+	if err != nil {
+		return err
+	}
+	for _, episode := range episodes {
+		episodeVideoInfo, page := gobili.EpisodeVideo(videoInfo, episode)
+		streams, err := p.GetVideoStreamsForPage(episodeVideoInfo, page)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "# Failed to get streams for episode %s: %v\n", episode.Title, err)
+			continue
+		}
+		if err := printResolved(ctx, dl, episode.Title, streams); err != nil {
+			fmt.Fprintf(os.Stderr, "# %s: %v\n", episode.Title, err)
+		}
+	}
+	return nil
+}
+
+// printResolved prints the URLs of one video for --get-url.
+func printResolved(ctx context.Context, dl *downloader.Downloader, title string, streams []*parser.StreamInfo) error {
+	urls, stream, err := dl.ResolveURLs(ctx, streams)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "# %s (%s)\n", title, stream.Resolution)
+	if len(urls) > 0 && len(urls[0].Headers) > 0 {
+		fmt.Fprintf(os.Stderr, "# Headers: %s\n", strings.Join(urls[0].Headers, "; "))
+	}
+	for _, u := range urls {
+		fmt.Println(u.URL)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dengmengmian/goBili/quality"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List, search and prune the download history",
+	Long: `Show the download history: every finished download with its BVID, part
+(cid), title, quality, path, size and time. The history lives in the state
+store, the SQLite database history.db in the config directory by default;
+a state.json from earlier versions is imported into it once. Set state_dsn
+to keep it elsewhere, e.g. json:///path/to/state.json.
+
+download, batch, daemon and serve skip parts the history already has
+while the file is still there; --no-dedup downloads them again.
+
+Examples:
+  goBili history
+  goBili history search 入门 --limit 5
+  goBili history rm 3f2a9c0d1e4b5a67 --delete-files`,
+	RunE: runHistoryList,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the downloads, newest first",
+	Args:  cobra.NoArgs,
+	RunE:  runHistoryList,
+}
+
+var historySearchCmd = &cobra.Command{
+	Use:   "search <words>...",
+	Short: "List the downloads whose title, BVID or path contain every word",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runHistoryList,
+}
+
+var historyRmCmd = &cobra.Command{
+	Use:     "rm <id>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove downloads from the history so they are fetched again",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runHistoryRm,
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyListCmd, historySearchCmd, historyRmCmd)
+
+	for _, c := range []*cobra.Command{historyCmd, historyListCmd, historySearchCmd} {
+		c.Flags().IntP("limit", "n", 0, "show at most this many downloads (0 for all)")
+	}
+	historyRmCmd.Flags().Bool("delete-files", false, "delete the downloaded files as well")
+}
+
+func runHistoryList(cmd *cobra.Command, args []string) error {
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	history, err := st.ListHistory(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	query := strings.Join(args, " ")
+	matches := store.SearchHistory(history, query)
+
+	// Newest first.
+	entries := make([]*store.HistoryEntry, 0, len(matches))
+	for i := len(matches) - 1; i >= 0; i-- {
+		entries = append(entries, matches[i])
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON("history", entries)
+	}
+	if len(entries) == 0 {
+		if query != "" {
+			fmt.Printf("No downloads match %q.\n", query)
+		} else {
+			fmt.Println("The download history is empty.")
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tDATE\tBVID\tCID\tQUALITY\tSIZE\tTITLE\tPATH")
+	for _, e := range entries {
+		name := "-"
+		if e.Quality > 0 {
+			name = quality.Name(e.Quality)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n", e.ID, e.CompletedAt.Local().Format("2006-01-02 15:04"),
+			e.BVID, e.CID, name, formatSize(e.Size), e.Title, e.Path)
+	}
+	tw.Flush()
+	if len(entries) < len(matches) {
+		fmt.Printf("\n%d of %d downloads shown\n", len(entries), len(matches))
+	}
+	return nil
+}
+
+func runHistoryRm(cmd *cobra.Command, args []string) error {
+	deleteFiles, _ := cmd.Flags().GetBool("delete-files")
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	history, err := st.ListHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	paths := make(map[string]string, len(history))
+	for _, e := range history {
+		paths[e.ID] = e.Path
+	}
+
+	var failed int
+	for _, id := range args {
+		if err := st.DeleteHistory(ctx, id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				err = fmt.Errorf("no such download in the history")
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Removed %s\n", id)
+		if path := paths[id]; deleteFiles && path != "" {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+				failed++
+				continue
+			}
+			fmt.Printf("Deleted %s\n", path)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d removals failed", failed, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/quality"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info <URL>",
+	Short: "Show the metadata and available qualities of a video",
+	Long: `Show the title, uploader, duration, parts or episodes, cover URL and
+the available qualities of a video or playlist without downloading
+anything. Qualities, codecs and estimated sizes are listed for one part
+(the first unless --page is given).
+
+Examples:
+  goBili info "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili info -p 2 "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili info --json "https://www.bilibili.com/bangumi/play/ss12345" | jq .formats`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInfo,
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().IntP("page", "p", 1, "part of a multi-part video or playlist to list the qualities of")
+}
+
+// infoReport is the --json output of the info command.
+type infoReport struct {
+	*parser.VideoInfo
+	FormatsPage int          `json:"formats_page"`
+	Formats     []infoFormat `json:"formats"`
+}
+
+// infoFormat describes one available stream.
+type infoFormat struct {
+	FormatID      string `json:"format_id,omitempty"`
+	Quality       int    `json:"quality"`
+	Name          string `json:"name"`
+	Requires      string `json:"requires,omitempty"`
+	Resolution    string `json:"resolution"`
+	FrameRate     string `json:"frame_rate,omitempty"`
+	VideoCodecs   string `json:"video_codecs"`
+	AudioCodecs   string `json:"audio_codecs,omitempty"`
+	Bandwidth     int    `json:"bandwidth"`
+	EstimatedSize int64  `json:"estimated_size"`
+}
+
+func runInfo(cmd *cobra.Command, args []string) error {
+	page, _ := cmd.Flags().GetInt("page")
+
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// Keep the output free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, _, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	_, streams, streamErr := pageStreams(p, videoInfo, page)
+	formats := infoFormats(streams, pageDuration(videoInfo, page))
+
+	if jsonOutput(cmd) {
+		if streamErr != nil {
+			logger.Warnf("Qualities unavailable: %v", streamErr)
+		}
+		return printJSON("info", infoReport{VideoInfo: videoInfo, FormatsPage: page, Formats: formats})
+	}
+
+	printInfo(videoInfo)
+	fmt.Println()
+	if streamErr != nil {
+		fmt.Printf("Qualities: unavailable (%v)\n", streamErr)
+		return nil
+	}
+	if len(videoInfo.Episodes) > 0 {
+		fmt.Printf("Qualities of part %d:\n", page)
+	} else {
+		fmt.Println("Qualities:")
+	}
+	for _, f := range formats {
+		codecs := f.VideoCodecs
+		if f.AudioCodecs != "" {
+			codecs += " + " + f.AudioCodecs
+		}
+		fmt.Printf("  %-6s %-10s %-28s ~%s\n", f.Name, f.Resolution, codecs, formatSize(f.EstimatedSize))
+	}
+	return nil
+}
+
+// printInfo prints the metadata part of the info report.
+func printInfo(videoInfo *parser.VideoInfo) {
+	fmt.Printf("Title:     %s\n", videoInfo.Title)
+	if videoInfo.BVID != "" {
+		fmt.Printf("BVID:      %s (av%d)\n", videoInfo.BVID, videoInfo.AID)
+	}
+	if videoInfo.Uploader != "" {
+		fmt.Printf("Uploader:  %s (UID: %d)\n", videoInfo.Uploader, videoInfo.OwnerMID)
+	}
+	if videoInfo.PubDate > 0 {
+		fmt.Printf("Published: %s\n", time.Unix(videoInfo.PubDate, 0).Format("2006-01-02 15:04"))
+	}
+	if videoInfo.Category != "" {
+		fmt.Printf("Category:  %s\n", videoInfo.Category)
+	}
+	if videoInfo.Duration > 0 {
+		fmt.Printf("Duration:  %s\n", formatDuration(videoInfo.Duration))
+	}
+	if videoInfo.Cover != "" {
+		fmt.Printf("Cover:     %s\n", videoInfo.Cover)
+	}
+	if len(videoInfo.Episodes) > 0 {
+		fmt.Printf("Parts:     %d\n", len(videoInfo.Episodes))
+		for i, episode := range videoInfo.Episodes {
+			fmt.Printf("  %3d. %s (%s)\n", i+1, episode.Title, formatDuration(episode.Duration))
+		}
+	}
+}
+
+// infoFormats describes streams, best quality first.
+func infoFormats(streams []*parser.StreamInfo, duration int) []infoFormat {
+	formats := make([]infoFormat, 0, len(streams))
+	for _, stream := range streams {
+		f := infoFormat{
+			FormatID:      stream.FormatID,
+			Quality:       stream.Quality,
+			Name:          quality.Name(stream.Quality),
+			Requires:      qualityRequires(stream.Quality),
+			Resolution:    stream.Resolution,
+			FrameRate:     stream.FrameRate,
+			VideoCodecs:   stream.VideoCodecs,
+			Bandwidth:     stream.Bandwidth,
+			EstimatedSize: stream.EstimatedSize(duration),
+		}
+		if stream.AudioURL != "" {
+			f.AudioCodecs = stream.AudioCodecs
+		}
+		formats = append(formats, f)
+	}
+	sort.SliceStable(formats, func(i, j int) bool {
+		return formats[i].Quality > formats[j].Quality
+	})
+	return formats
+}
+
+// pageDuration returns the duration in seconds of one part, counting from 1.
+func pageDuration(videoInfo *parser.VideoInfo, page int) int {
+	if page >= 1 && page <= len(videoInfo.Episodes) {
+		return videoInfo.Episodes[page-1].Duration
+	}
+	return videoInfo.Duration
+}
+
+// newReadOnlyParser returns a parser for commands that only read metadata
+// and streams: it uses the active profile's login when there is one and
+// guest access otherwise, or always with --anonymous.
+func newReadOnlyParser(logger *logrus.Logger) (*parser.BilibiliParser, *auth.AuthManager, error) {
+	authManager, err := readOnlyAuthManager(logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshLogin(authManager, logger)
+	ensureBiliTicket(authManager, logger)
+	return parser.NewBilibiliParser(authManager, logger), authManager, nil
+}
+
+// readOnlyAuthManager returns the saved login of the active profile, or a
+// guest session when there is none or --anonymous is set.
+func readOnlyAuthManager(logger *logrus.Logger) (*auth.AuthManager, error) {
+	if viper.GetBool("anonymous") {
+		// Keep metadata requests off the account and its rate limits.
+		return newAnonymousAuthManager(logger)
+	}
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := authManager.LoadCookies(); err != nil {
+		logger.Warnf("Failed to load cookies: %v", err)
+	}
+	if !authManager.IsAuthenticated() {
+		// Metadata and lower qualities work without an account.
+		return newAnonymousAuthManager(logger)
+	}
+	return authManager, nil
+}
+
+// formatDuration renders seconds as m:ss, or h:mm:ss from an hour.
+func formatDuration(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// formatSize returns a human-readable size.
+func formatSize(bytes int64) string {
+	const (
+		KB = 1024
+		MB = 1024 * KB
+		GB = 1024 * MB
+	)
+	switch {
+	case bytes >= GB:
+		return fmt.Sprintf("%.2f GB", float64(bytes)/float64(GB))
+	case bytes >= MB:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/float64(MB))
+	case bytes >= KB:
+		return fmt.Sprintf("%.0f KB", float64(bytes)/float64(KB))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+func TestReadOnlyAuthManager_Anonymous(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	logger := logrus.New()
+
+	saved, err := newAuthManager(defaultProfile, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.SetCookie("SESSDATA", "session")
+	saved.SetCookie("bili_jct", "csrf")
+	if err := saved.SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+
+	am, err := readOnlyAuthManager(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !am.IsAuthenticated() || am.IsAnonymous() {
+		t.Fatal("without --anonymous the saved login should be used")
+	}
+
+	viper.Set("anonymous", true)
+	defer viper.Set("anonymous", false)
+	am, err = readOnlyAuthManager(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if am.IsAuthenticated() || !am.IsAnonymous() {
+		t.Error("--anonymous should bypass the saved login")
+	}
+	if got := am.GetCookie("SESSDATA"); got != "" {
+		t.Errorf("anonymous session has SESSDATA %q", got)
+	}
+}
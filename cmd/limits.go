@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/ratelimit"
+	"github.com/spf13/viper"
+)
+
+// resourceLimitsFromConfig reads the nice, io_class and io_level config keys.
+// They apply to muxer processes started by download and by the daemon.
+func resourceLimitsFromConfig() (downloader.ResourceLimits, error) {
+	nice := viper.GetInt("nice")
+	if nice < 0 || nice > 19 {
+		return downloader.ResourceLimits{}, fmt.Errorf("invalid nice value %d (want 0-19)", nice)
+	}
+	ioClass, err := downloader.ParseIOClass(viper.GetString("io_class"))
+	if err != nil {
+		return downloader.ResourceLimits{}, err
+	}
+	ioLevel := viper.GetInt("io_level")
+	if ioLevel < 0 || ioLevel > 7 {
+		return downloader.ResourceLimits{}, fmt.Errorf("invalid io_level %d (want 0-7)", ioLevel)
+	}
+	return downloader.ResourceLimits{Nice: nice, IOClass: ioClass, IOLevel: ioLevel}, nil
+}
+
+// processLimiterFromConfig returns the limiter for the max_muxer_procs key,
+// or nil when it is unset.
+func processLimiterFromConfig() *downloader.ProcessLimiter {
+	return downloader.NewProcessLimiter(viper.GetInt("max_muxer_procs"))
+}
+
+// bufferPoolFromConfig returns the copy buffer pool for the buffer_size key,
+// or nil (the downloader's process-wide pool) for the default size.
+func bufferPoolFromConfig() (*downloader.BufferPool, error) {
+	size, err := downloader.ParseBufferSize(viper.GetString("buffer_size"))
+	if err != nil || size == downloader.DefaultBufferSize {
+		return nil, err
+	}
+	return downloader.NewBufferPool(size), nil
+}
+
+// hostLimiterFromConfig returns the limiter for the max_host_conns key, or
+// nil when it is unset.
+func hostLimiterFromConfig() *downloader.HostLimiter {
+	return downloader.NewHostLimiter(viper.GetInt("max_host_conns"))
+}
+
+// rateLimiterFromConfig returns the request budget of the api_rate and
+// cdn_rate keys, in requests per second, or nil when neither is set.
+func rateLimiterFromConfig() *ratelimit.Limiter {
+	return ratelimit.New(viper.GetFloat64("api_rate"), viper.GetFloat64("cdn_rate"))
+}
+
+// applyRequestDelay spaces out p's API calls by the api_delay key, e.g.
+// "500ms" or "300ms-2s".
+func applyRequestDelay(p *parser.BilibiliParser) error {
+	min, max, err := parser.ParseDelayRange(viper.GetString("api_delay"))
+	if err != nil {
+		return fmt.Errorf("invalid api_delay: %w", err)
+	}
+	p.SetRequestDelay(min, max)
+	return nil
+}
+
+// applyAreaProxies routes the playurl requests of region-locked videos
+// through the proxies of the area_proxies key, e.g. {hk: "http://..."}.
+func applyAreaProxies(p *parser.BilibiliParser) error {
+	if err := p.SetAreaProxies(viper.GetStringMapString("area_proxies")); err != nil {
+		return fmt.Errorf("invalid area_proxies: %w", err)
+	}
+	return nil
+}
@@ -1,17 +1,21 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/i18n"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 // loginCmd represents the login command
@@ -20,98 +24,193 @@ var loginCmd = &cobra.Command{
 	Short: "Login to Bilibili using QR code or cookie file",
 	Long: `Login to Bilibili using QR code authentication or cookie file.
 This will generate a QR code that you can scan with the Bilibili mobile app to authenticate,
-or you can provide a cookie file with authentication information.`,
+or you can provide a cookie file with authentication information.
+
+On headless servers, --password and --sms log in without a QR code; the
+captcha they require is solved in a browser on any device.`,
 	RunE: runLogin,
 }
 
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	addLoginFlags(loginCmd)
+}
 
+// addLoginFlags defines the flags of the login command on cmd.
+func addLoginFlags(cmd *cobra.Command) {
 	// Add flag for cookie file
-	loginCmd.Flags().StringP("cookie-file", "c", "", "path to cookie file containing authentication information")
+	cmd.Flags().StringP("cookie-file", "c", "", "path to cookie file containing authentication information")
 	// Add flag for browser login
-	loginCmd.Flags().BoolP("browser", "b", false, "open browser to login and automatically capture cookies")
+	cmd.Flags().BoolP("browser", "b", false, "open browser to login and automatically capture cookies")
+	// Add flag for TV client login
+	cmd.Flags().Bool("tv", false, "log in as the TV client to also obtain an access_key for the app API")
+	// Add flag for saving the QR code as an image
+	cmd.Flags().String("qr-output", "", "also save the login QR code as a PNG image (e.g. qr.png)")
+	// Add flags for password and SMS login
+	cmd.Flags().Bool("password", false, "log in with account name and password (captcha solved in a browser)")
+	cmd.Flags().Bool("sms", false, "log in with a code sent by SMS (captcha solved in a browser)")
+	cmd.Flags().String("username", "", "account name (phone number or email) for --password")
+	cmd.Flags().String("phone", "", "phone number for --sms")
+	cmd.Flags().Int("country-code", 86, "phone country code for --sms")
+	cmd.Flags().Bool("force", false, "log in again even if already logged in")
 }
 
-func runLogin(cmd *cobra.Command, _ []string) error {
-	// Get config directory
-	configDir := getConfigDir()
+// Login methods, as chosen by the flags of the login command.
+const (
+	loginQRCode     = "qrcode"
+	loginPassword   = "password"
+	loginSMS        = "sms"
+	loginTV         = "tv"
+	loginBrowser    = "browser"
+	loginCookieFile = "cookie-file"
+)
 
-	// Initialize logger
-	logger := logrus.New()
-	if viper.GetBool("verbose") {
-		logger.SetLevel(logrus.DebugLevel)
-	} else {
-		logger.SetLevel(logrus.InfoLevel)
+// loginMethod returns the login method selected by the flags of cmd, and
+// whether a flag selected it; without one it is the QR code login.
+func loginMethod(cmd *cobra.Command) (method string, explicit bool, err error) {
+	cookieFile, err := cmd.Flags().GetString("cookie-file")
+	if err != nil {
+		return "", false, i18n.Errorf("invalid cookie-file flag: %w", err)
+	}
+	useBrowser, err := cmd.Flags().GetBool("browser")
+	if err != nil {
+		return "", false, i18n.Errorf("invalid browser flag: %w", err)
+	}
+	useTV, err := cmd.Flags().GetBool("tv")
+	if err != nil {
+		return "", false, i18n.Errorf("invalid tv flag: %w", err)
 	}
+	usePassword, _ := cmd.Flags().GetBool("password")
+	useSMS, _ := cmd.Flags().GetBool("sms")
+
+	switch {
+	case usePassword:
+		return loginPassword, true, nil
+	case useSMS:
+		return loginSMS, true, nil
+	case useTV:
+		return loginTV, true, nil
+	case useBrowser:
+		return loginBrowser, true, nil
+	case cookieFile != "":
+		return loginCookieFile, true, nil
+	default:
+		return loginQRCode, false, nil
+	}
+}
+
+func runLogin(cmd *cobra.Command, _ []string) error {
+	logger := newLogger()
 
 	// Initialize auth manager
-	authManager := auth.NewAuthManager(configDir, logger)
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return err
+	}
 
 	// Load existing cookies if any
 	if err := authManager.LoadCookies(); err != nil {
-		logger.Warnf("Failed to load existing cookies: %v", err)
+		logger.Warnf(i18n.T("Failed to load existing cookies: %v"), err)
+	}
+
+	// Check login method
+	method, explicit, err := loginMethod(cmd)
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return i18n.Errorf("invalid force flag: %w", err)
 	}
 
-	// Check if already authenticated
-	if authManager.IsAuthenticated() {
+	// Check if already authenticated; an explicit login method or --force
+	// logs in again.
+	if !explicit && !force && authManager.IsAuthenticated() {
 		userInfo, err := authManager.GetUserInfo()
 		if err != nil {
-			logger.Warnf("Failed to get user info: %v", err)
-			fmt.Println("You appear to be logged in, but user info could not be retrieved.")
-			fmt.Println("You may need to re-login.")
+			logger.Warnf(i18n.T("Failed to get user info: %v"), err)
+			i18n.Println("You appear to be logged in, but user info could not be retrieved.")
+			i18n.Println("You may need to re-login.")
 		} else {
-			fmt.Printf("Already logged in as: %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
-			fmt.Println("Use --force flag to force re-login if needed.")
+			i18n.Printf("Already logged in as: %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
+			i18n.Println("Use --force flag to force re-login if needed.")
 			return nil
 		}
 	}
 
-	// Check login method
-	cookieFile, err := cmd.Flags().GetString("cookie-file")
+	qrOutput, err := cmd.Flags().GetString("qr-output")
 	if err != nil {
-		return fmt.Errorf("invalid cookie-file flag: %w", err)
+		return i18n.Errorf("invalid qr-output flag: %w", err)
 	}
-	useBrowser, err := cmd.Flags().GetBool("browser")
-	if err != nil {
-		return fmt.Errorf("invalid browser flag: %w", err)
-	}
-
-	if useBrowser {
+	authManager.SetQROutput(qrOutput)
+	authManager.SetPromptOutput(os.Stdout)
+
+	switch method {
+	case loginPassword:
+		// Password login
+		username, _ := cmd.Flags().GetString("username")
+		if err := loginWithPassword(authManager, username); err != nil {
+			return i18n.Errorf("password login failed: %w", err)
+		}
+	case loginSMS:
+		// SMS login
+		phone, _ := cmd.Flags().GetString("phone")
+		countryCode, _ := cmd.Flags().GetInt("country-code")
+		if err := loginWithSMS(authManager, countryCode, phone); err != nil {
+			return i18n.Errorf("SMS login failed: %w", err)
+		}
+	case loginTV:
+		// TV client QR code login
+		i18n.Println("Starting TV QR code login...")
+		ctx, stop := interruptContext()
+		defer stop()
+		if err := authManager.LoginWithTV(ctx); err != nil {
+			return i18n.Errorf("TV login failed: %w", err)
+		}
+	case loginBrowser:
 		// Browser login
-		fmt.Println("Starting browser login...")
+		i18n.Println("Starting browser login...")
 		if err := loginWithBrowser(authManager, logger); err != nil {
-			return fmt.Errorf("browser login failed: %w", err)
+			return i18n.Errorf("browser login failed: %w", err)
 		}
-	} else if cookieFile != "" {
+	case loginCookieFile:
 		// Load cookies from file
-		fmt.Printf("Loading cookies from file: %s\n", cookieFile)
+		cookieFile, _ := cmd.Flags().GetString("cookie-file")
+		i18n.Printf("Loading cookies from file: %s\n", cookieFile)
 		if err := loadCookiesFromFile(authManager, cookieFile); err != nil {
-			return fmt.Errorf("failed to load cookies from file: %w", err)
+			return i18n.Errorf("failed to load cookies from file: %w", err)
 		}
 
+		// Imported cookies come without a refresh token
+		authManager.SetRefreshToken("")
+
 		// Save cookies to config directory
 		if err := authManager.SaveCookies(); err != nil {
-			logger.Warnf("Failed to save cookies: %v", err)
+			logger.Warnf(i18n.T("Failed to save cookies: %v"), err)
 		}
-	} else {
+	default:
 		// Perform QR code login
-		fmt.Println("Starting QR code login...")
-		if err := authManager.LoginWithQRCode(); err != nil {
-			return fmt.Errorf("QR code login failed: %w", err)
+		i18n.Println("Starting QR code login...")
+		ctx, stop := interruptContext()
+		defer stop()
+		if err := authManager.LoginWithQRCode(ctx); err != nil {
+			return i18n.Errorf("QR code login failed: %w", err)
 		}
 	}
 
 	// Verify login by getting user info
 	userInfo, err := authManager.GetUserInfo()
 	if err != nil {
-		return fmt.Errorf("login verification failed: %w", err)
+		return i18n.Errorf("login verification failed: %w", err)
 	}
 
-	fmt.Printf("Login successful! Welcome, %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
-	fmt.Printf("User level: %d\n", userInfo.Level)
+	fmt.Println(styleOK.mark(), i18n.Sprintf("Login successful! Welcome, %s (UID: %d)", userInfo.Name, userInfo.Mid))
+	if profile := activeProfile(); profile != defaultProfile {
+		i18n.Printf("Saved to profile: %s\n", profile)
+	}
+	i18n.Printf("User level: %d\n", userInfo.Level)
 	if userInfo.VipStatus > 0 {
-		fmt.Println("VIP status: Active")
+		i18n.Println("VIP status: Active")
 	}
 
 	return nil
@@ -121,13 +220,13 @@ func runLogin(cmd *cobra.Command, _ []string) error {
 func loadCookiesFromFile(authManager *auth.AuthManager, filePath string) error {
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("cookie file does not exist: %s", filePath)
+		return i18n.Errorf("cookie file does not exist: %s", filePath)
 	}
 
 	// Read file content
 	content, err := os.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read cookie file: %w", err)
+		return i18n.Errorf("failed to read cookie file: %w", err)
 	}
 
 	// Parse cookie content
@@ -159,36 +258,36 @@ func loadCookiesFromFile(authManager *auth.AuthManager, filePath string) error {
 	}
 
 	if cookieCount == 0 {
-		return fmt.Errorf("no valid cookies found in file")
+		return i18n.Errorf("no valid cookies found in file")
 	}
 
-	fmt.Printf("Loaded %d cookies from file\n", cookieCount)
+	i18n.Printf("Loaded %d cookies from file\n", cookieCount)
 	return nil
 }
 
 // loginWithBrowser opens browser and provides instructions for manual cookie extraction
 func loginWithBrowser(_ *auth.AuthManager, logger *logrus.Logger) error {
-	fmt.Println("=== Browser Login Mode ===")
-	fmt.Println("This mode opens your browser for Bilibili login, then you extract cookies manually.")
+	i18n.Println("=== Browser Login Mode ===")
+	i18n.Println("This mode opens your browser for Bilibili login, then you extract cookies manually.")
 	fmt.Println()
 
 	// Open browser to Bilibili login page
 	bilibiliLoginURL := "https://passport.bilibili.com/login"
 
-	fmt.Printf("Opening browser: %s\n", bilibiliLoginURL)
+	i18n.Printf("Opening browser: %s\n", bilibiliLoginURL)
 
 	if err := openBrowser(bilibiliLoginURL); err != nil {
-		logger.Warnf("Failed to open browser: %v", err)
-		fmt.Printf("Please manually open: %s\n", bilibiliLoginURL)
+		logger.Warnf(i18n.T("Failed to open browser: %v"), err)
+		i18n.Printf("Please manually open: %s\n", bilibiliLoginURL)
 	}
 
 	fmt.Println()
-	fmt.Println("Complete the login in your browser, then follow these steps to extract cookies:")
+	i18n.Println("Complete the login in your browser, then follow these steps to extract cookies:")
 	fmt.Println()
-	fmt.Println("1. After login, press F12 to open Developer Tools")
-	fmt.Println("2. Go to the 'Application' or 'Storage' tab")
-	fmt.Println("3. Find 'Cookies' -> 'https://www.bilibili.com' in the sidebar")
-	fmt.Println("4. Copy the values of these cookies:")
+	i18n.Println("1. After login, press F12 to open Developer Tools")
+	i18n.Println("2. Go to the 'Application' or 'Storage' tab")
+	i18n.Println("3. Find 'Cookies' -> 'https://www.bilibili.com' in the sidebar")
+	i18n.Println("4. Copy the values of these cookies:")
 	fmt.Println("   - SESSDATA")
 	fmt.Println("   - bili_jct")
 	fmt.Println("   - DedeUserID")
@@ -197,30 +296,30 @@ func loginWithBrowser(_ *auth.AuthManager, logger *logrus.Logger) error {
 	fmt.Println("   - buvid3")
 	fmt.Println("   - buvid4")
 	fmt.Println()
-	fmt.Println("5. Save them as a tab-separated text file:")
+	i18n.Println("5. Save them as a tab-separated text file:")
 	fmt.Println("   SESSDATA\tyour_SESSDATA_value")
 	fmt.Println("   bili_jct\tyour_bili_jct_value")
 	fmt.Println("   DedeUserID\tyour_DedeUserID_value")
 	fmt.Println("   ...")
 	fmt.Println()
-	fmt.Println("6. Import the cookies:")
+	i18n.Println("6. Import the cookies:")
 	fmt.Println("   ./goBili login -c /path/to/cookie-file")
 	fmt.Println()
 
 	// Wait for user to complete the process
-	fmt.Print("Press Enter to continue, or type 'q' to quit: ")
+	fmt.Print(i18n.T("Press Enter to continue, or type 'q' to quit: "))
 	var input string
 	_, err := fmt.Scanln(&input)
 	if err != nil {
 		// If Scanln fails (e.g. EOF), treat as quit.
-		return fmt.Errorf("login canceled")
+		return i18n.Errorf("login canceled")
 	}
 
 	if input == "q" || input == "Q" {
-		return fmt.Errorf("user canceled login")
+		return i18n.Errorf("user canceled login")
 	}
 
-	return fmt.Errorf("please extract cookies manually and use the -c flag to import them")
+	return i18n.Errorf("please extract cookies manually and use the -c flag to import them")
 }
 
 // openBrowser opens the specified URL in the default browser
@@ -235,8 +334,150 @@ func openBrowser(url string) error {
 	case "linux":
 		cmd = exec.Command("xdg-open", url)
 	default:
-		return fmt.Errorf("unsupported platform: %s", runtime.GOOS)
+		return i18n.Errorf("unsupported platform: %s", runtime.GOOS)
 	}
 
 	return cmd.Start()
 }
+
+// loginWithPassword prompts for the missing credentials and the captcha,
+// then logs in with the password.
+func loginWithPassword(authManager *auth.AuthManager, username string) error {
+	in := bufio.NewReader(os.Stdin)
+	if username == "" {
+		username = prompt(in, i18n.T("Account (phone or email): "))
+	}
+	password, err := promptPassword(in, i18n.T("Password: "))
+	if err != nil {
+		return err
+	}
+	if username == "" || password == "" {
+		return i18n.Errorf("account and password are required")
+	}
+
+	captcha, result, err := solveCaptcha(authManager, in)
+	if err != nil {
+		return err
+	}
+	return authManager.LoginWithPassword(username, password, captcha, result)
+}
+
+// loginWithSMS sends a login code to phone and prompts for it.
+func loginWithSMS(authManager *auth.AuthManager, countryCode int, phone string) error {
+	in := bufio.NewReader(os.Stdin)
+	if phone == "" {
+		phone = prompt(in, i18n.T("Phone number: "))
+	}
+	if phone == "" {
+		return i18n.Errorf("phone number is required")
+	}
+
+	captcha, result, err := solveCaptcha(authManager, in)
+	if err != nil {
+		return err
+	}
+	captchaKey, err := authManager.SendSMSCode(countryCode, phone, captcha, result)
+	if err != nil {
+		return err
+	}
+	i18n.Printf("Code sent to +%d %s\n", countryCode, phone)
+	code := prompt(in, i18n.T("SMS code: "))
+	if code == "" {
+		return i18n.Errorf("SMS code is required")
+	}
+	return authManager.LoginWithSMS(countryCode, phone, code, captchaKey)
+}
+
+// solveCaptcha hands the geetest captcha off to a browser and reads back
+// the validate and seccode values it shows.
+func solveCaptcha(authManager *auth.AuthManager, in *bufio.Reader) (*auth.Captcha, auth.CaptchaResult, error) {
+	captcha, err := authManager.GetCaptcha()
+	if err != nil {
+		return nil, auth.CaptchaResult{}, err
+	}
+	i18n.Println("\nSolve the captcha in a browser (it can be another device):")
+	fmt.Printf("  %s\n", captcha.SolveURL())
+	i18n.Println("Then paste the values it shows.")
+
+	result := auth.CaptchaResult{
+		Validate: prompt(in, "validate: "),
+		Seccode:  prompt(in, "seccode: "),
+	}
+	if result.Validate == "" || result.Seccode == "" {
+		return nil, auth.CaptchaResult{}, i18n.Errorf("captcha was not solved")
+	}
+	return captcha, result, nil
+}
+
+// prompt prints label and reads one trimmed line from in.
+func prompt(in *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// promptPassword reads a password without echo when stdin is a terminal.
+func promptPassword(in *bufio.Reader, label string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return prompt(in, label), nil
+	}
+	fmt.Print(label)
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", i18n.Errorf("failed to read password: %w", err)
+	}
+	return string(password), nil
+}
+
+// sessionWarnWindow is how long before the session expires checkLogin
+// starts warning about it.
+const sessionWarnWindow = 7 * 24 * time.Hour
+
+// checkLogin validates the saved session before downloading, so an expired
+// login fails up front instead of as unexplained quality downgrades. It
+// warns when the session expires soon. Network errors only warn.
+func checkLogin(authManager *auth.AuthManager, logger *logrus.Logger) error {
+	status, err := authManager.CheckSession()
+	if errors.Is(err, auth.ErrSessionExpired) {
+		i18n.Println("Your login session has expired. Please login again using: goBili login")
+		return err
+	}
+	if err != nil {
+		logger.Warnf(i18n.T("Could not verify login session: %v"), err)
+		return nil
+	}
+	logger.Debugf("Logged in as %s (UID: %d)", status.Name, status.Mid)
+
+	if expiry, ok := authManager.SessionExpiry(); ok && time.Until(expiry) < sessionWarnWindow {
+		hint := i18n.T("run 'goBili login' to renew it")
+		if authManager.RefreshToken() != "" {
+			hint = i18n.T("it will be refreshed automatically when Bilibili allows")
+		}
+		logger.Warnf(i18n.T("Login session expires in %s (%s); %s"), formatDays(time.Until(expiry)), expiry.Format("2006-01-02"), hint)
+	}
+	return nil
+}
+
+// ensureBiliTicket obtains the bili_ticket cookie that keeps some APIs from
+// answering -352. Without it requests still mostly work, so failures are
+// only logged.
+func ensureBiliTicket(authManager *auth.AuthManager, logger *logrus.Logger) {
+	if err := authManager.EnsureBiliTicket(); err != nil {
+		logger.Debugf("Continuing without bili_ticket: %v", err)
+	}
+}
+
+// refreshLogin renews the saved login cookies when Bilibili reports them
+// due. Failures only warn: the current cookies usually still work.
+func refreshLogin(authManager *auth.AuthManager, logger *logrus.Logger) {
+	refreshed, err := authManager.RefreshCookiesIfNeeded()
+	if err != nil {
+		logger.Warnf(i18n.T("Failed to refresh login cookies: %v"), err)
+		return
+	}
+	if refreshed {
+		logger.Info(i18n.T("Refreshed login cookies"))
+	}
+}
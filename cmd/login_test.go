@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestLoginMethod(t *testing.T) {
+	tests := []struct {
+		args         []string
+		wantMethod   string
+		wantExplicit bool
+	}{
+		{nil, loginQRCode, false},
+		{[]string{"--qr-output", "qr.png"}, loginQRCode, false},
+		{[]string{"--force"}, loginQRCode, false},
+		{[]string{"--tv"}, loginTV, true},
+		{[]string{"--password", "--username", "me"}, loginPassword, true},
+		{[]string{"--sms", "--phone", "13800000000"}, loginSMS, true},
+		{[]string{"--browser"}, loginBrowser, true},
+		{[]string{"-c", "cookies.txt"}, loginCookieFile, true},
+		{[]string{"--tv", "--browser"}, loginTV, true},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		addLoginFlags(cmd)
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("ParseFlags(%q): %v", tt.args, err)
+		}
+		method, explicit, err := loginMethod(cmd)
+		if err != nil {
+			t.Errorf("loginMethod(%q): %v", tt.args, err)
+			continue
+		}
+		if method != tt.wantMethod || explicit != tt.wantExplicit {
+			t.Errorf("loginMethod(%q) = %q, %v, want %q, %v", tt.args, method, explicit, tt.wantMethod, tt.wantExplicit)
+		}
+	}
+}
@@ -6,18 +6,19 @@ import (
 	"path/filepath"
 
 	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/i18n"
 
-	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // logoutCmd represents the logout command
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Clear current login session and remove saved cookies",
-	Long: `Logout from Bilibili by clearing all saved authentication cookies.
-This will remove the current login session and require re-authentication for future downloads.`,
+	Long: `Logout from Bilibili by revoking the session on Bilibili's side and
+clearing all saved authentication cookies.
+This will remove the current login session and require re-authentication for future downloads.
+Use --local-only to only remove the saved cookies and leave the session valid.`,
 	RunE: runLogout,
 }
 
@@ -26,22 +27,20 @@ func init() {
 
 	// Add flag for force logout without confirmation
 	logoutCmd.Flags().BoolP("force", "f", false, "force logout without confirmation")
+	logoutCmd.Flags().Bool("local-only", false, "only remove saved cookies, without revoking the session on Bilibili")
 }
 
 func runLogout(cmd *cobra.Command, _ []string) error {
-	// Get config directory
-	configDir := getConfigDir()
+	// Get the active profile's config directory
+	configDir := getProfileDir()
 
-	// Initialize logger
-	logger := logrus.New()
-	if viper.GetBool("verbose") {
-		logger.SetLevel(logrus.DebugLevel)
-	} else {
-		logger.SetLevel(logrus.InfoLevel)
-	}
+	logger := newLogger()
 
 	// Initialize auth manager
-	authManager := auth.NewAuthManager(configDir, logger)
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return err
+	}
 
 	// Load existing cookies to check if logged in
 	if err := authManager.LoadCookies(); err != nil {
@@ -50,28 +49,28 @@ func runLogout(cmd *cobra.Command, _ []string) error {
 
 	// Check if currently logged in
 	if !authManager.IsAuthenticated() {
-		fmt.Println("No active login session found.")
+		i18n.Println("No active login session found.")
 		return nil
 	}
 
 	// Get user info before logout
 	userInfo, err := authManager.GetUserInfo()
 	if err != nil {
-		logger.Warnf("Failed to get user info: %v", err)
-		fmt.Println("Currently logged in (user info unavailable)")
+		logger.Warnf(i18n.T("Failed to get user info: %v"), err)
+		i18n.Println("Currently logged in (user info unavailable)")
 	} else {
-		fmt.Printf("Currently logged in as: %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
+		i18n.Printf("Currently logged in as: %s (UID: %d)\n", userInfo.Name, userInfo.Mid)
 	}
 
 	// Check for force flag
 	force, err := cmd.Flags().GetBool("force")
 	if err != nil {
-		return fmt.Errorf("invalid force flag: %w", err)
+		return i18n.Errorf("invalid force flag: %w", err)
 	}
 
 	if !force {
 		// Ask for confirmation
-		fmt.Print("Are you sure you want to logout? (y/N): ")
+		fmt.Print(i18n.T("Are you sure you want to logout? (y/N): "))
 		var input string
 		_, err := fmt.Scanln(&input)
 		if err != nil {
@@ -80,27 +79,54 @@ func runLogout(cmd *cobra.Command, _ []string) error {
 		}
 
 		if input != "y" && input != "Y" && input != "yes" && input != "Yes" {
-			fmt.Println("Logout canceled.")
+			i18n.Println("Logout canceled.")
 			return nil
 		}
 	}
 
+	// Revoke the session server-side so copies of the cookies stop working
+	localOnly, err := cmd.Flags().GetBool("local-only")
+	if err != nil {
+		return i18n.Errorf("invalid local-only flag: %w", err)
+	}
+	if !localOnly {
+		if err := authManager.Logout(); err != nil {
+			logger.Warnf(i18n.T("Failed to revoke session on Bilibili: %v"), err)
+			fmt.Println(styleFail.mark(), i18n.T("Session could not be revoked on Bilibili, removing local cookies only"))
+		} else {
+			fmt.Println(styleOK.mark(), i18n.T("Session revoked on Bilibili"))
+		}
+	}
+
 	// Remove cookie file
 	cookieFile := filepath.Join(configDir, "cookies.json")
 	if _, err := os.Stat(cookieFile); err == nil {
 		if err := os.Remove(cookieFile); err != nil {
-			return fmt.Errorf("failed to remove cookie file: %w", err)
+			return i18n.Errorf("failed to remove cookie file: %w", err)
 		}
-		fmt.Println("✓ Cookie file removed")
+		fmt.Println(styleOK.mark(), i18n.T("Cookie file removed"))
 	} else {
-		fmt.Println("✓ No cookie file found")
+		fmt.Println(styleOK.mark(), i18n.T("No cookie file found"))
+	}
+
+	// Remove the refresh token saved with the QR login and the access key
+	// of a TV login
+	for _, name := range []string{auth.RefreshTokenFile, auth.AccessKeyFile} {
+		if err := os.Remove(filepath.Join(configDir, name)); err != nil && !os.IsNotExist(err) {
+			return i18n.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	// Remove credentials kept in the keyring or encrypted file
+	if err := authManager.DeleteStoredCredentials(); err != nil {
+		return err
 	}
 
 	// Clear in-memory cookies
 	authManager.ClearCookies()
 
-	fmt.Println("✓ Login session cleared")
-	fmt.Println("You will need to login again to download videos.")
+	fmt.Println(styleOK.mark(), i18n.T("Login session cleared"))
+	i18n.Println("You will need to login again to download videos.")
 
 	return nil
 }
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// mirrorCheckDelay spaces out API calls so large archives are checked politely.
+const mirrorCheckDelay = time.Second
+
+// mirrorCmd represents the mirror command
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Verify archived downloads against Bilibili",
+	Long: `Check whether the videos in the download history still exist on
+Bilibili. The check is read-only: nothing is downloaded or deleted.
+
+Status changes (title changed, part re-uploaded, video deleted or hidden)
+are recorded in the history, and an alert is printed when an archived copy
+becomes the only remaining version.`,
+}
+
+// mirrorCheckCmd checks every archived video once, or periodically
+var mirrorCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check archived videos for deletions and changes",
+	Args:  cobra.NoArgs,
+	RunE:  runMirrorCheck,
+}
+
+// mirrorListCmd lists archived videos whose source is gone or changed
+var mirrorListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List archived videos that are gone or changed on Bilibili",
+	Args:  cobra.NoArgs,
+	RunE:  runMirrorList,
+}
+
+func init() {
+	rootCmd.AddCommand(mirrorCmd)
+	mirrorCmd.AddCommand(mirrorCheckCmd)
+	mirrorCmd.AddCommand(mirrorListCmd)
+
+	mirrorCheckCmd.Flags().Duration("interval", 0, "repeat the check at this interval (e.g. 24h) until interrupted")
+}
+
+func runMirrorCheck(cmd *cobra.Command, _ []string) error {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return fmt.Errorf("invalid interval flag: %w", err)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	logger := newLogger()
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return err
+	}
+	if err := authManager.LoadCookies(); err != nil {
+		logger.Warnf("Failed to load cookies: %v", err)
+	}
+	if !authManager.IsAuthenticated() {
+		// Public metadata is enough to tell whether a video still exists.
+		if authManager, err = newAnonymousAuthManager(logger); err != nil {
+			return err
+		}
+	}
+	refreshLogin(authManager, logger)
+	ensureBiliTicket(authManager, logger)
+	p := parser.NewBilibiliParser(authManager, logger)
+	if err := applyRequestDelay(p); err != nil {
+		return err
+	}
+	if err := applyAreaProxies(p); err != nil {
+		return err
+	}
+	p.SetRateLimiter(rateLimiterFromConfig())
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	for {
+		if err := checkMirror(ctx, st, p, logger); err != nil {
+			return err
+		}
+		if interval <= 0 {
+			return nil
+		}
+
+		fmt.Printf("Next check at %s\n", time.Now().Add(interval).Format("2006-01-02 15:04"))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// checkMirror checks each archived video once and records the results.
+func checkMirror(ctx context.Context, st store.Store, p *parser.BilibiliParser, logger *logrus.Logger) error {
+	history, err := st.ListHistory(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	// Several history entries (pages of one video) share a BVID; fetch each
+	// video only once.
+	videos := make(map[string]*parser.VideoInfo)
+	errs := make(map[string]error)
+
+	checked, alerts := 0, 0
+	for _, entry := range history {
+		if entry.BVID == "" {
+			continue
+		}
+
+		info, seen := videos[entry.BVID]
+		fetchErr := errs[entry.BVID]
+		if !seen && fetchErr == nil {
+			if len(videos)+len(errs) > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(mirrorCheckDelay):
+				}
+			}
+			info, fetchErr = p.ParseURL("https://www.bilibili.com/video/" + entry.BVID)
+			if fetchErr != nil {
+				errs[entry.BVID] = fetchErr
+			} else {
+				videos[entry.BVID] = info
+			}
+		}
+
+		if fetchErr != nil && !errors.Is(fetchErr, parser.ErrVideoUnavailable) {
+			logger.Warnf("Could not check %s: %v", entry.BVID, fetchErr)
+			continue
+		}
+
+		check := classifySource(entry, info, fetchErr)
+		checked++
+		if !entry.RecordSourceCheck(check) {
+			continue
+		}
+		if err := st.AddHistory(ctx, entry); err != nil {
+			return fmt.Errorf("failed to record check for %s: %w", entry.BVID, err)
+		}
+
+		fmt.Printf("%s (%s): %s", entry.Title, entry.BVID, check.Status)
+		if check.Detail != "" {
+			fmt.Printf(" - %s", check.Detail)
+		}
+		fmt.Println()
+		if entry.OnlyCopy() {
+			alerts++
+			fmt.Printf("  ALERT: your archived copy may now be the only remaining version: %s\n", entry.Path)
+		}
+	}
+
+	fmt.Printf("Checked %d archived items, %d only copies newly detected\n", checked, alerts)
+	return nil
+}
+
+// classifySource compares an archived entry with the current state of its
+// video. A modified status is kept until the video disappears, so a later
+// check does not report it as unchanged again.
+func classifySource(entry *store.HistoryEntry, info *parser.VideoInfo, fetchErr error) store.SourceCheck {
+	check := store.SourceCheck{CheckedAt: time.Now()}
+	if fetchErr != nil {
+		check.Status = store.SourceGone
+		check.Detail = fetchErr.Error()
+		return check
+	}
+
+	check.Title = info.Title
+	prev := entry.Source
+	switch {
+	case entry.CID != 0 && !hasPage(info, entry.CID):
+		check.Status = store.SourceModified
+		check.Detail = "the archived part was re-uploaded or removed"
+	case prev != nil && prev.Title != "" && prev.Title != info.Title:
+		check.Status = store.SourceModified
+		check.Detail = fmt.Sprintf("title changed from %q to %q", prev.Title, info.Title)
+	case prev != nil && prev.Status == store.SourceModified:
+		check.Status = store.SourceModified
+		check.Detail = prev.Detail
+	default:
+		check.Status = store.SourceAvailable
+	}
+	return check
+}
+
+// hasPage reports whether the video still contains the part with cid.
+func hasPage(info *parser.VideoInfo, cid int64) bool {
+	for _, page := range info.Pages {
+		if page.CID == cid {
+			return true
+		}
+	}
+	return false
+}
+
+func runMirrorList(_ *cobra.Command, _ []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	history, err := st.ListHistory(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+
+	found := false
+	for _, entry := range history {
+		if entry.Source == nil || entry.Source.Status == store.SourceAvailable {
+			continue
+		}
+		found = true
+		fmt.Printf("%-9s %s  %s (%s)\n", entry.Source.Status, entry.Source.CheckedAt.Format("2006-01-02"), entry.Title, entry.BVID)
+		if entry.Source.Detail != "" {
+			fmt.Printf("          %s\n", entry.Source.Detail)
+		}
+		fmt.Printf("          %s\n", entry.Path)
+	}
+	if !found {
+		fmt.Println("No archived videos are known to be gone or changed. Run 'goBili mirror check' to refresh.")
+	}
+	return nil
+}
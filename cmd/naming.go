@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// namingSchemes are the values of --naming. Plex, Jellyfin and Kodi all
+// read the same layout:
+//
+//	Show/Season 01/Show - S01E05 - Episode Title.mkv
+//
+// with the extras of a season numbered as specials, S00E01 and on.
+var namingSchemes = []string{"plex", "jellyfin", "kodi"}
+
+// checkNaming returns an error when scheme is not a --naming value.
+func checkNaming(scheme string) error {
+	if scheme == "" {
+		return nil
+	}
+	for _, s := range namingSchemes {
+		if scheme == s {
+			return nil
+		}
+	}
+	return i18n.Errorf("unsupported naming scheme %q (want %s)", scheme, strings.Join(namingSchemes, ", "))
+}
+
+// mediaServerSeason returns a copy of the bangumi season videoInfo, the
+// nth season of its show, whose episodes are titled for media servers,
+// e.g. "Show - S01E05 - Episode Title", together with the folder of the
+// season, "Show/Season 01".
+func mediaServerSeason(videoInfo *parser.VideoInfo, n int) (*parser.VideoInfo, string) {
+	show := videoInfo.Show
+	if strings.TrimSpace(show) == "" {
+		show = videoInfo.Title
+	}
+	show = downloader.SanitizeFilename(show)
+	if n < 1 {
+		n = 1
+	}
+
+	named := *videoInfo
+	named.Episodes = make([]*parser.EpisodeInfo, len(videoInfo.Episodes))
+	episode, special := 0, 0
+	for i, ep := range videoInfo.Episodes {
+		renamed := *ep
+		if ep.Section != "" {
+			special++
+			renamed.Title = mediaServerTitle(show, 0, special, ep.Title)
+			renamed.Section = "" // the S00 number marks it
+		} else {
+			episode++
+			renamed.Title = mediaServerTitle(show, n, episode, ep.LongTitle)
+		}
+		named.Episodes[i] = &renamed
+	}
+	return &named, filepath.Join(show, fmt.Sprintf("Season %02d", n))
+}
+
+// mediaServerTitle returns "Show - S01E05 - Episode Title", or
+// "Show - S01E05" for an episode without a title.
+func mediaServerTitle(show string, season, episode int, title string) string {
+	name := fmt.Sprintf("%s - S%02dE%02d", show, season, episode)
+	if strings.TrimSpace(title) != "" {
+		name += " - " + title
+	}
+	return name
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// stdout is where JSON documents are written. It stays the process's
+// standard output while os.Stdout is redirected by redirectStdout.
+var stdout io.Writer = os.Stdout
+
+// jsonOutput reports whether the global --json flag is set.
+func jsonOutput(cmd *cobra.Command) bool {
+	asJSON, _ := cmd.Flags().GetBool("json")
+	return asJSON
+}
+
+// printJSON writes v to standard output as indented JSON; what names the
+// document in the error.
+func printJSON(what string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", what, err)
+	}
+	_, err = fmt.Fprintln(stdout, string(data))
+	return err
+}
+
+// redirectStdout sends everything printed to os.Stdout, such as progress
+// and log lines, to standard error, so that standard output carries only
+// the JSON document. It returns the function that undoes it.
+func redirectStdout() (restore func()) {
+	saved := os.Stdout
+	os.Stdout = os.Stderr
+	return func() {
+		os.Stdout = saved
+	}
+}
+
+// style is the color of a status: success, skipped or warning, and error.
+type style string
+
+// Status styles, as ANSI color codes.
+const (
+	styleOK   style = "32" // green
+	styleWarn style = "33" // yellow
+	styleFail style = "31" // red
+)
+
+// useColor reports whether output is colored: not with --no-color, NO_COLOR
+// or TERM=dumb, and only when standard output is a terminal.
+func useColor() bool {
+	if viper.GetBool("no_color") || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return stdoutIsTerminal()
+}
+
+// stdoutIsTerminal reports whether standard output is a terminal. Tests
+// replace it.
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// paint returns s in the color of the style, or s itself when output is
+// not colored.
+func (st style) paint(s string) string {
+	if !useColor() {
+		return s
+	}
+	return "\x1b[" + string(st) + "m" + s + "\x1b[0m"
+}
+
+// mark returns the symbol of the style, ✓, ! or ✗, painted.
+func (st style) mark() string {
+	switch st {
+	case styleOK:
+		return st.paint("✓")
+	case styleWarn:
+		return st.paint("!")
+	default:
+		return st.paint("✗")
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// captureJSON runs fn with JSON documents written to a buffer and returns
+// what was written.
+func captureJSON(t *testing.T, fn func() error) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	defer func() { stdout = saved }()
+	if err := fn(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// setJSONFlag sets the global --json flag on cmd for the test.
+func setJSONFlag(t *testing.T, cmd *cobra.Command) {
+	t.Helper()
+	cmd.InheritedFlags() // merges the root's persistent flags into cmd.Flags()
+	if err := cmd.Flags().Set("json", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Flags().Set("json", "false") })
+}
+
+func TestRedirectStdout(t *testing.T) {
+	saved := os.Stdout
+	data := captureJSON(t, func() error {
+		restore := redirectStdout()
+		defer restore()
+		if os.Stdout != os.Stderr {
+			t.Error("os.Stdout not redirected to standard error")
+		}
+		return printJSON("test", map[string]int{"a": 1})
+	})
+	if os.Stdout != saved {
+		t.Error("restore did not bring back os.Stdout")
+	}
+	if string(data) != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("printJSON wrote %q", data)
+	}
+}
+
+func TestStatusJSON_NotLoggedIn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	setJSONFlag(t, statusCmd)
+
+	data := captureJSON(t, func() error { return runStatus(statusCmd, nil) })
+	var report map[string]interface{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("status output is not JSON: %v\n%s", err, data)
+	}
+	if report["profile"] != "default" || report["logged_in"] != false {
+		t.Errorf("status = %v", report)
+	}
+}
+
+func TestHistoryJSON_Empty(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	setJSONFlag(t, historyListCmd)
+
+	data := captureJSON(t, func() error { return runHistoryList(historyListCmd, nil) })
+	var entries []interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("history output is not JSON: %v\n%s", err, data)
+	}
+	if entries == nil || len(entries) != 0 {
+		t.Errorf("empty history = %s, want []", data)
+	}
+}
+
+func TestStylePaint(t *testing.T) {
+	saved := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = saved }()
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm-256color")
+
+	stdoutIsTerminal = func() bool { return true }
+	if got := styleFail.paint("boom"); got != "\x1b[31mboom\x1b[0m" {
+		t.Errorf("paint on a terminal = %q", got)
+	}
+	if got := styleOK.mark(); got != "\x1b[32m✓\x1b[0m" {
+		t.Errorf("mark on a terminal = %q", got)
+	}
+
+	for name, setup := range map[string]func(){
+		"--no-color": func() { viper.Set("no_color", true) },
+		"NO_COLOR":   func() { t.Setenv("NO_COLOR", "1") },
+		"TERM=dumb":  func() { t.Setenv("TERM", "dumb") },
+		"not a tty":  func() { stdoutIsTerminal = func() bool { return false } },
+	} {
+		stdoutIsTerminal = func() bool { return true }
+		t.Setenv("NO_COLOR", "")
+		t.Setenv("TERM", "xterm-256color")
+		setup()
+		if got := styleWarn.mark(); got != "!" {
+			t.Errorf("%s: mark = %q, want plain !", name, got)
+		}
+		viper.Set("no_color", nil)
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/pkg/gobili"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// playCmd represents the play command
+var playCmd = &cobra.Command{
+	Use:   "play <URL>",
+	Short: "Watch a video in mpv without saving it",
+	Long: `Resolve the selected quality of a video and open it in mpv, passing
+the direct video and audio URLs together with the Referer, User-Agent and
+Cookie headers Bilibili's CDN expects. Nothing is written to disk.
+
+With --pipe the video is streamed into the player's stdin instead, which
+works with any player that reads from "-" (seeking is then limited). The
+same stream can be written to stdout with 'goBili download -o - <URL>'.
+
+Examples:
+  goBili play "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili play -q 720p -p 3 "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili play --pipe --player vlc "https://www.bilibili.com/video/BV1xx411c7mu"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlay,
+}
+
+func init() {
+	rootCmd.AddCommand(playCmd)
+
+	playCmd.Flags().StringP("quality", "q", "best", qualityHelp+"; the best available when that quality is not")
+	playCmd.Flags().IntP("page", "p", 1, "part of a multi-part video or playlist to play")
+	playCmd.Flags().BoolP("audio-only", "a", false, "play the audio stream only")
+	playCmd.Flags().String("player", "mpv", "player executable")
+	playCmd.Flags().Bool("pipe", false, "stream into the player's stdin instead of passing URLs")
+
+	if err := viper.BindPFlag("player", playCmd.Flags().Lookup("player")); err != nil {
+		cobra.CheckErr(err)
+	}
+}
+
+func runPlay(cmd *cobra.Command, args []string) error {
+	quality, _ := cmd.Flags().GetString("quality")
+	page, _ := cmd.Flags().GetInt("page")
+	audioOnly, _ := cmd.Flags().GetBool("audio-only")
+	pipe, _ := cmd.Flags().GetBool("pipe")
+	player := viper.GetString("player")
+	if err := checkQuality(quality); err != nil {
+		return err
+	}
+
+	logger := newLogger()
+	p, authManager, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	title, streams, err := pageStreams(p, videoInfo, page)
+	if err != nil {
+		return err
+	}
+
+	dl := downloader.NewDownloader(downloader.Config{
+		Quality:     quality,
+		AudioOnly:   audioOnly,
+		FFmpegPath:  viper.GetString("ffmpeg_path"),
+		AuthManager: authManager,
+	})
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if pipe {
+		return pipeToPlayer(ctx, dl, logger, player, streams)
+	}
+	urls, _, err := dl.ResolveURLs(ctx, streams)
+	if err != nil {
+		return err
+	}
+	playerCmd := exec.CommandContext(ctx, player, mpvArgs(title, urls)...)
+	playerCmd.Stdout = os.Stdout
+	playerCmd.Stderr = os.Stderr
+	playerCmd.Stdin = os.Stdin
+	logger.Debugf("Running player: %s", player)
+	if err := playerCmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", player, err)
+	}
+	return nil
+}
+
+// pageStreams returns the title and streams of one page of a video or
+// playlist, counting from 1.
+func pageStreams(p *parser.BilibiliParser, videoInfo *parser.VideoInfo, page int) (string, []*parser.StreamInfo, error) {
+	if videoInfo.Type == "video" && len(videoInfo.Pages) <= 1 {
+		if page != 1 {
+			return "", nil, fmt.Errorf("page %d does not exist: the video has one part", page)
+		}
+		streams, err := p.GetVideoStreams(videoInfo)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get video streams: %w", err)
+		}
+		return videoInfo.Title, streams, nil
+	}
+
+	if page < 1 || page > len(videoInfo.Episodes) {
+		return "", nil, fmt.Errorf("page %d does not exist: there are %d parts", page, len(videoInfo.Episodes))
+	}
+	episode := videoInfo.Episodes[page-1]
+	episodeVideoInfo, episodePage := gobili.EpisodeVideo(videoInfo, episode)
+	streams, err := p.GetVideoStreamsForPage(episodeVideoInfo, episodePage)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get streams for %s: %w", episode.Title, err)
+	}
+	return episode.Title, streams, nil
+}
+
+// mpvArgs returns the mpv arguments for playing the resolved URLs. Each
+// header is appended as one list item, since header values (User-Agent)
+// may contain the commas mpv uses to separate list items.
+func mpvArgs(title string, urls []downloader.StreamURL) []string {
+	args := []string{"--force-media-title=" + title}
+	if len(urls) > 0 {
+		for _, header := range urls[0].Headers {
+			args = append(args, "--http-header-fields-append="+header)
+		}
+	}
+	for _, u := range urls[1:] {
+		args = append(args, "--audio-file="+u.URL)
+	}
+	if len(urls) > 0 {
+		args = append(args, urls[0].URL)
+	}
+	return args
+}
+
+// pipeToPlayer streams the video into the player's stdin.
+func pipeToPlayer(ctx context.Context, dl *downloader.Downloader, logger *logrus.Logger, player string, streams []*parser.StreamInfo) error {
+	playerCmd := exec.CommandContext(ctx, player, "-")
+	playerCmd.Stdout = os.Stdout
+	playerCmd.Stderr = os.Stderr
+	stdin, err := playerCmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := playerCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", player, err)
+	}
+
+	streamErr := dl.StreamTo(ctx, stdin, streams)
+	stdin.Close()
+	waitErr := playerCmd.Wait()
+	if ctx.Err() != nil {
+		return errInterrupted
+	}
+	if waitErr != nil {
+		return fmt.Errorf("%s failed: %w", player, waitErr)
+	}
+	if streamErr != nil {
+		// Closing the player early breaks the pipe; that is not an error.
+		logger.Debugf("Stream ended: %v", streamErr)
+	}
+	return nil
+}
+
+// streamToStdout implements "download -o -": it writes the first selected
+// page to stdout, e.g. for "goBili download -o - <URL> | mpv -".
+func streamToStdout(p *parser.BilibiliParser, dl *downloader.Downloader, logger *logrus.Logger, videoInfo *parser.VideoInfo, pages string) error {
+	page := 1
+	if pages != "all" {
+		indices, err := parsePageRange(pages, len(videoInfo.Episodes))
+		if err != nil || len(indices) == 0 {
+			return fmt.Errorf("invalid pages parameter: %q", pages)
+		}
+		if len(indices) > 1 {
+			logger.Warnf("Only page %d is streamed to stdout", indices[0])
+		}
+		page = indices[0]
+	}
+	_, streams, err := pageStreams(p, videoInfo, page)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	if err := dl.StreamTo(ctx, os.Stdout, streams); err != nil {
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// doerFunc is an auth.HTTPDoer answering from a function.
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+// bangumiParser returns a parser whose API serves a two-episode bangumi
+// season (ss2) and, for the playurl of any cid, a stream whose URL names
+// that cid.
+func bangumiParser(t *testing.T) *parser.BilibiliParser {
+	t.Helper()
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch q := req.URL.Query(); {
+		case q.Get("season_id") != "":
+			rec.WriteString(`{"code":0,"data":{"season_id":2,"title":"番剧","episodes":[
+				{"id":201,"bvid":"BV001","cid":100,"title":"1","long_title":"开始","duration":1440000},
+				{"id":202,"bvid":"BV002","cid":200,"title":"2","long_title":"结束","duration":1440000}]}}`)
+		case q.Get("cid") != "":
+			rec.WriteString(`{"code":0,"data":{"dash":{
+				"video":[{"id":80,"baseUrl":"https://v/` + q.Get("cid") + `.m4s"}],
+				"audio":[{"id":30280,"baseUrl":"https://v/audio.m4s"}]}}}`)
+		default:
+			t.Errorf("unexpected request %s", req.URL)
+			rec.WriteHeader(http.StatusNotFound)
+		}
+		return rec.Result(), nil
+	})
+	am := auth.NewAuthManager(t.TempDir(), logrus.New())
+	am.SetCookie("SESSDATA", "s")
+	return parser.NewBilibiliParserWithClient(am, logrus.New(), client)
+}
+
+func TestPageStreams_Bangumi(t *testing.T) {
+	p := bangumiParser(t)
+	videoInfo, err := p.ParseURL("https://www.bilibili.com/bangumi/play/ss2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	title, streams, err := pageStreams(p, videoInfo, 2)
+	if err != nil {
+		t.Fatalf("pageStreams: %v", err)
+	}
+	if title != "2" || len(streams) == 0 || streams[0].VideoURL != "https://v/200.m4s" {
+		t.Errorf("pageStreams = %q, %+v; want the streams of episode 2 (cid 200)", title, streams)
+	}
+	if _, _, err := pageStreams(p, videoInfo, 3); err == nil {
+		t.Error("expected an error for an episode that does not exist")
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// premierePoll is how long --wait waits between checks of a video that
+// should be out by now but is not, e.g. a premiere started late.
+var premierePoll = time.Minute
+
+// releaseTimeFormat shows go-live times in local time.
+const releaseTimeFormat = "2006-01-02 15:04 MST"
+
+// awaitPremiere handles a video that is scheduled to premiere or publish
+// later. Without --wait it fails with the go-live time; with --wait it
+// sleeps until then and checks again until the video is out, updating
+// videoInfo with its parts.
+func awaitPremiere(ctx context.Context, p *parser.BilibiliParser, logger *logrus.Logger, videoInfo *parser.VideoInfo) error {
+	if videoInfo.Premiere == 0 {
+		return nil
+	}
+	at := time.Unix(videoInfo.Premiere, 0)
+	if !viper.GetBool("wait") {
+		return i18n.Errorf("%s goes live at %s; run again then, or add --wait to wait for it", videoInfo.Title, at.Format(releaseTimeFormat))
+	}
+	for {
+		if err := awaitRelease(ctx, videoInfo.Title, at); err != nil {
+			return err
+		}
+		live, err := p.ParseURLContext(ctx, "https://www.bilibili.com/video/"+videoInfo.BVID)
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		switch {
+		case err != nil:
+			logger.Warnf(i18n.T("Failed to check whether %s is out: %v"), videoInfo.Title, err)
+			at = time.Now().Add(premierePoll)
+		case live.Premiere != 0:
+			at = time.Unix(live.Premiere, 0)
+		default:
+			videoInfo.Premiere = 0
+			videoInfo.Duration = live.Duration
+			videoInfo.Pages = live.Pages
+			return nil
+		}
+	}
+}
+
+// awaitRelease sleeps until at, when title is due to go live.
+func awaitRelease(ctx context.Context, title string, at time.Time) error {
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	i18n.Printf("Waiting for %s to go live at %s (in %s)\n", title, at.Format(releaseTimeFormat), delay.Round(time.Second))
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return errInterrupted
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// builtinPresets bundle download settings for common kinds of content.
+// Values are config keys, so a preset never overrides an explicit flag or a
+// setting in the config file. Presets can be adjusted (or new ones added)
+// under the "presets" key of the config file, e.g.:
+//
+//	presets:
+//	  music:
+//	    audio_format: flac
+var builtinPresets = map[string]map[string]interface{}{
+	// music keeps only the audio track, as a widely playable mp3, filed
+	// under the uploader.
+	"music": {
+		"audio_only":          true,
+		"audio_format":        "mp3",
+		"audio_quality":       "320k",
+		"embed_metadata":      true,
+		"embed_cover":         true,
+		"output_dir_template": "{{.Owner}}",
+	},
+	// lecture favours small files that play anywhere: slides and talking
+	// heads rarely need more than 720p, and AVC decodes on every device.
+	// Courses are kept together by uploader and series.
+	"lecture": {
+		"quality":             "720p",
+		"codec":               "avc",
+		"output_dir_template": "{{.Owner}}/{{.SeriesTitle}}",
+	},
+	// anime takes the best video quality available in HEVC and keeps every
+	// audio track and the CC subtitles in one MKV file, named and filed
+	// for a media server.
+	"anime": {
+		"quality":      "best",
+		"codec":        "hevc",
+		"format":       "mkv",
+		"audio_tracks": "all",
+		"embed_subs":   true,
+		"naming":       "jellyfin",
+	},
+	// archive is for unattended mirroring: best quality in HEVC to save
+	// space, filed by uploader and year, and files already downloaded by a
+	// previous run are left alone.
+	"archive": {
+		"quality":             "best",
+		"codec":               "hevc",
+		"format":              "mp4",
+		"existing":            "skip",
+		"embed_metadata":      true,
+		"embed_cover":         true,
+		"write_info_json":     true,
+		"output_dir_template": "{{.Owner}}/{{.Year}}",
+	},
+}
+
+// presetNames returns the names of all built-in and configured presets.
+func presetNames() []string {
+	seen := make(map[string]bool)
+	for name := range builtinPresets {
+		seen[name] = true
+	}
+	for name := range viper.GetStringMap("presets") {
+		seen[strings.ToLower(name)] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolvePreset returns the settings of the named preset, with overrides
+// from the config file merged over the built-in values.
+func resolvePreset(name string) (map[string]interface{}, error) {
+	name = strings.ToLower(name)
+	settings := make(map[string]interface{})
+	builtin, ok := builtinPresets[name]
+	for k, v := range builtin {
+		settings[k] = v
+	}
+
+	// viper lowercases nested keys, so the configured name matches directly.
+	configured := viper.GetStringMap("presets." + name)
+	for k, v := range configured {
+		settings[strings.ToLower(k)] = v
+	}
+
+	if !ok && len(configured) == 0 {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presetNames(), ", "))
+	}
+	return settings, nil
+}
+
+// applyPreset makes the named preset's settings the defaults for this run.
+// Flags and config file values still take precedence.
+func applyPreset(name string) error {
+	if name == "" {
+		return nil
+	}
+	settings, err := resolvePreset(name)
+	if err != nil {
+		return err
+	}
+	for k, v := range settings {
+		viper.SetDefault(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolvePreset(t *testing.T) {
+	for name := range builtinPresets {
+		settings, err := resolvePreset(name)
+		if err != nil {
+			t.Fatalf("resolvePreset(%s): %v", name, err)
+		}
+		// Every preset files its downloads and picks a codec (music an
+		// audio one).
+		if settings["output_dir_template"] == nil && settings["naming"] == nil {
+			t.Errorf("%s: no output_dir_template or naming", name)
+		}
+		if settings["codec"] == nil && settings["audio_format"] == nil {
+			t.Errorf("%s: no codec or audio_format", name)
+		}
+	}
+
+	viper.Set("presets", map[string]interface{}{
+		"music": map[string]interface{}{"audio_format": "flac"},
+		"vlog":  map[string]interface{}{"quality": "1080p"},
+	})
+	defer viper.Set("presets", nil)
+
+	music, err := resolvePreset("Music")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if music["audio_format"] != "flac" || music["audio_only"] != true {
+		t.Errorf("music = %v, want the configured audio_format over the built-in values", music)
+	}
+	if vlog, err := resolvePreset("vlog"); err != nil || vlog["quality"] != "1080p" {
+		t.Errorf("vlog = %v, %v", vlog, err)
+	}
+	if _, err := resolvePreset("nope"); err == nil {
+		t.Error("resolvePreset accepted an unknown preset")
+	}
+}
+
+func TestApplyPreset_Precedence(t *testing.T) {
+	flag := downloadCmd.Flags().Lookup("quality")
+	defer func() {
+		flag.Value.Set(flag.DefValue)
+		flag.Changed = false
+		for k := range builtinPresets["lecture"] {
+			viper.SetDefault(k, nil)
+		}
+	}()
+
+	if got := viper.GetString("quality"); got != "best" {
+		t.Fatalf("quality = %q before the preset, want the flag default", got)
+	}
+	if err := applyPreset("lecture"); err != nil {
+		t.Fatal(err)
+	}
+	// The preset beats the flag's built-in default...
+	if got := viper.GetString("quality"); got != "720p" {
+		t.Errorf("quality = %q with the lecture preset, want 720p", got)
+	}
+	if got := viper.GetString("codec"); got != "avc" {
+		t.Errorf("codec = %q with the lecture preset, want avc", got)
+	}
+	// ...and an explicit flag beats the preset.
+	if err := downloadCmd.Flags().Set("quality", "1080p"); err != nil {
+		t.Fatal(err)
+	}
+	if got := viper.GetString("quality"); got != "1080p" {
+		t.Errorf("quality = %q with --quality 1080p, want the flag", got)
+	}
+
+	if err := applyPreset("nope"); err == nil {
+		t.Error("applyPreset accepted an unknown preset")
+	}
+}
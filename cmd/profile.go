@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// defaultProfile is the profile whose cookies live directly in the config
+// directory, so installations that predate profiles keep working unchanged.
+const defaultProfile = "default"
+
+// activeProfileFile stores the name of the profile selected via 'account use'.
+const activeProfileFile = "active_profile"
+
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateProfileName rejects names that are unsafe as directory names.
+func validateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+// activeProfile returns the profile for this invocation: the --profile flag
+// wins, then the profile saved by 'account use', then the default profile.
+func activeProfile() string {
+	if name := viper.GetString("profile"); name != "" {
+		return name
+	}
+	return savedProfile()
+}
+
+// savedProfile returns the profile persisted by 'account use'.
+func savedProfile() string {
+	data, err := os.ReadFile(filepath.Join(getConfigDir(), activeProfileFile))
+	if err != nil {
+		return defaultProfile
+	}
+	name := strings.TrimSpace(string(data))
+	if validateProfileName(name) != nil {
+		return defaultProfile
+	}
+	return name
+}
+
+// saveActiveProfile persists name as the profile used by later commands.
+func saveActiveProfile(name string) error {
+	if err := os.MkdirAll(getConfigDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	path := filepath.Join(getConfigDir(), activeProfileFile)
+	if err := os.WriteFile(path, []byte(name+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save active profile: %w", err)
+	}
+	return nil
+}
+
+// profileDir returns the directory holding the cookies of the named profile.
+func profileDir(name string) string {
+	if name == "" || name == defaultProfile {
+		return getConfigDir()
+	}
+	return filepath.Join(getConfigDir(), "profiles", name)
+}
+
+// getProfileDir returns the directory of the active profile.
+func getProfileDir() string {
+	return profileDir(activeProfile())
+}
+
+// profileExists reports whether the named profile has been created.
+func profileExists(name string) bool {
+	if name == defaultProfile {
+		return true
+	}
+	info, err := os.Stat(profileDir(name))
+	return err == nil && info.IsDir()
+}
+
+// listProfiles returns all known profile names, default first.
+func listProfiles() ([]string, error) {
+	names := []string{defaultProfile}
+
+	entries, err := os.ReadDir(filepath.Join(getConfigDir(), "profiles"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return names, nil
+		}
+		return nil, fmt.Errorf("failed to read profiles directory: %w", err)
+	}
+
+	var others []string
+	for _, entry := range entries {
+		if entry.IsDir() && validateProfileName(entry.Name()) == nil && entry.Name() != defaultProfile {
+			others = append(others, entry.Name())
+		}
+	}
+	sort.Strings(others)
+
+	return append(names, others...), nil
+}
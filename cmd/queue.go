@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dengmengmian/goBili/server"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// queueCmd represents the queue command
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Queue downloads for the daemon",
+	Long: `Manage the persistent download queue in the state store (history.db in
+the config directory unless state_dsn says otherwise). Queued downloads
+are run by 'goBili daemon' or 'goBili serve', which may already be
+running or be started later, e.g. overnight.
+
+Examples:
+  goBili queue add "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili queue add -q 720p -p 1-3 "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili queue list
+  goBili queue pause 3f2a9c0d1e4b5a67
+  goBili daemon --concurrency 2`,
+	RunE: runQueueList,
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add <URL>...",
+	Short: "Add downloads to the queue",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runQueueAdd,
+}
+
+var queueListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the queued, running and finished downloads",
+	Args:  cobra.NoArgs,
+	RunE:  runQueueList,
+}
+
+var queueRemoveCmd = &cobra.Command{
+	Use:   "remove <id>...",
+	Short: "Remove downloads from the queue, canceling unfinished ones",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return queueEach(args, "Removed", func(q *server.Server, ctx context.Context, id string) error {
+			return q.Discard(ctx, id)
+		})
+	},
+}
+
+var queuePauseCmd = &cobra.Command{
+	Use:   "pause <id>...",
+	Short: "Hold queued or running downloads back until they are resumed",
+	Long: `Hold queued or running downloads back until they are resumed. A
+running download stops within a minute; resumed, it starts over.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return queueEach(args, "Paused", func(q *server.Server, ctx context.Context, id string) error {
+			_, err := q.Pause(ctx, id)
+			return err
+		})
+	},
+}
+
+var queueResumeCmd = &cobra.Command{
+	Use:   "resume <id>...",
+	Short: "Queue paused downloads again",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return queueEach(args, "Resumed", func(q *server.Server, ctx context.Context, id string) error {
+			_, err := q.Resume(ctx, id)
+			return err
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queueAddCmd, queueListCmd, queueRemoveCmd, queuePauseCmd, queueResumeCmd)
+
+	queueAddCmd.Flags().StringP("quality", "q", "", "video quality of these downloads (default the daemon's)")
+	queueAddCmd.Flags().StringP("pages", "p", "", "pages of these downloads (e.g., 1,2,3 or 1-5 or all; default the daemon's)")
+}
+
+// openQueue returns a server without workers for the job queue in the
+// state store, and a function closing the store.
+func openQueue() (*server.Server, func(), error) {
+	st, err := openStore()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open the job queue: %w", err)
+	}
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// The commands report what they did themselves.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	q := server.New(server.Options{Store: st, Logger: logger})
+	return q, func() { st.Close() }, nil
+}
+
+func runQueueAdd(cmd *cobra.Command, args []string) error {
+	quality, _ := cmd.Flags().GetString("quality")
+	pages, _ := cmd.Flags().GetString("pages")
+
+	q, closeQueue, err := openQueue()
+	if err != nil {
+		return err
+	}
+	defer closeQueue()
+
+	for _, url := range args {
+		j, err := q.Submit(context.Background(), server.Request{URL: url, Quality: quality, Pages: pages})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Queued %s: %s\n", j.ID, url)
+	}
+	return nil
+}
+
+func runQueueList(cmd *cobra.Command, _ []string) error {
+
+	q, closeQueue, err := openQueue()
+	if err != nil {
+		return err
+	}
+	defer closeQueue()
+
+	ctx := context.Background()
+	jobs, err := q.Jobs(ctx)
+	if err != nil {
+		return err
+	}
+	status := q.EstimateQueue(ctx, jobs)
+	if jsonOutput(cmd) {
+		return printJSON("queue", map[string]interface{}{"jobs": jobs, "queue": status})
+	}
+
+	if len(jobs) == 0 {
+		fmt.Println("The queue is empty.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tADDED\tURL\tDETAILS")
+	for _, j := range jobs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", j.ID, j.Status, j.CreatedAt.Local().Format("01-02 15:04"), j.URL, jobDetails(j.Job))
+	}
+	tw.Flush()
+
+	if status.Remaining > 0 {
+		fmt.Printf("\n%d unfinished", status.Remaining)
+		if status.FinishAt != nil {
+			fmt.Printf(", done around %s", status.FinishAt.Local().Format(time.Kitchen))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// jobDetails summarizes a job's own settings and its error for the list.
+func jobDetails(j *store.Job) string {
+	var details []string
+	if quality := j.Options["quality"]; quality != "" {
+		details = append(details, "quality "+quality)
+	}
+	if pages := j.Options["pages"]; pages != "" {
+		details = append(details, "pages "+pages)
+	}
+	if j.Error != "" {
+		details = append(details, j.Error)
+	}
+	return strings.Join(details, ", ")
+}
+
+// queueEach applies fn to the jobs with the given IDs, reporting each as
+// done. It goes through all of them and fails if any failed.
+func queueEach(ids []string, done string, fn func(q *server.Server, ctx context.Context, id string) error) error {
+	q, closeQueue, err := openQueue()
+	if err != nil {
+		return err
+	}
+	defer closeQueue()
+
+	var failed int
+	for _, id := range ids {
+		if err := fn(q, context.Background(), id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s %s\n", done, id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(ids))
+	}
+	return nil
+}
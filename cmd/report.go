@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// Outcomes of a video in a run report.
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeSkipped   = "skipped"
+	outcomeFailed    = "failed"
+)
+
+// reportEntry is the outcome of one video.
+type reportEntry struct {
+	Title   string `json:"title"`
+	BVID    string `json:"bvid,omitempty"`
+	CID     int64  `json:"cid,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+	NoAudio bool   `json:"no_audio,omitempty"` // saved as video only
+
+	AudioKbps int `json:"audio_kbps,omitempty"` // audio-only downloads
+}
+
+// runReport collects the outcome of every video of a download run, for the
+// end-of-run summary and --write-report.
+type runReport struct {
+	Job        string         `json:"job,omitempty"` // batch job ID
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+	Succeeded  int            `json:"succeeded"`
+	Skipped    int            `json:"skipped"`
+	Failed     int            `json:"failed"`
+	Entries    []*reportEntry `json:"entries"`
+	Sources    []*sourceEntry `json:"sources,omitempty"` // runs of several URLs
+}
+
+// sourceEntry is the outcome of one URL of a run: failed when the URL or
+// any of its videos failed, skipped when all of its videos were skipped.
+type sourceEntry struct {
+	URL    string `json:"url"`
+	Status string `json:"status"`
+	Videos int    `json:"videos"`
+	Error  string `json:"error,omitempty"`
+}
+
+func newRunReport() *runReport {
+	return &runReport{StartedAt: time.Now(), Entries: []*reportEntry{}}
+}
+
+// addResult records a finished or skipped download.
+func (r *runReport) addResult(videoInfo *parser.VideoInfo, cid int64, result *downloader.Result) {
+	entry := &reportEntry{
+		Title:   videoInfo.Title,
+		BVID:    videoInfo.BVID,
+		CID:     cid,
+		Status:  outcomeSucceeded,
+		Path:    result.Path,
+		Size:    result.Size,
+		Quality: result.Quality,
+		NoAudio: result.NoAudio,
+
+		AudioKbps: result.AudioKbps,
+	}
+	if result.Skipped {
+		entry.Status = outcomeSkipped
+		r.Skipped++
+	} else {
+		r.Succeeded++
+	}
+	r.Entries = append(r.Entries, entry)
+}
+
+// addFailure records a video that could not be downloaded.
+func (r *runReport) addFailure(title, bvid string, cid int64, err error) {
+	r.Failed++
+	r.Entries = append(r.Entries, &reportEntry{Title: title, BVID: bvid, CID: cid, Status: outcomeFailed, Error: err.Error()})
+}
+
+// addSource records the outcome of url, whose videos are entries; err is
+// the error that stopped it, if any.
+func (r *runReport) addSource(url string, entries []*reportEntry, err error) {
+	source := &sourceEntry{URL: url, Status: outcomeSkipped, Videos: len(entries)}
+	for _, e := range entries {
+		switch e.Status {
+		case outcomeFailed:
+			source.Status = outcomeFailed
+		case outcomeSucceeded:
+			if source.Status == outcomeSkipped {
+				source.Status = outcomeSucceeded
+			}
+		}
+	}
+	if err != nil {
+		source.Status, source.Error = outcomeFailed, err.Error()
+	}
+	r.Sources = append(r.Sources, source)
+}
+
+// printSummary writes the summary table: the totals, one line per video
+// that did not simply succeed, and one line per URL when there were several.
+func (r *runReport) printSummary(w io.Writer) {
+	fmt.Fprintf(w, "\nSummary: %s, %s, %s\n", styleOK.paint(fmt.Sprintf("%d succeeded", r.Succeeded)),
+		styleWarn.paint(fmt.Sprintf("%d skipped", r.Skipped)), styleFail.paint(fmt.Sprintf("%d failed", r.Failed)))
+
+	// Every label is painted, so the escape codes keep the columns aligned.
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range r.Entries {
+		switch {
+		case e.Status == outcomeFailed:
+			fmt.Fprintf(tw, "  %s %s\t%s\t%s\n", styleFail.mark(), styleFail.paint("FAILED"), e.Title, e.Error)
+		case e.Status == outcomeSkipped:
+			fmt.Fprintf(tw, "  %s %s\t%s\t%s\n", styleWarn.mark(), styleWarn.paint("SKIPPED"), e.Title, "already exists: "+e.Path)
+		case e.NoAudio:
+			fmt.Fprintf(tw, "  %s %s\t%s\t%s\n", styleWarn.mark(), styleWarn.paint("NO AUDIO"), e.Title, "saved as video only")
+		}
+	}
+	tw.Flush()
+
+	if len(r.Sources) < 2 {
+		return
+	}
+	fmt.Fprintln(w, "\nURLs:")
+	for _, src := range r.Sources {
+		result := fmt.Sprintf("%d videos", src.Videos)
+		if src.Videos == 1 {
+			result = "1 video"
+		}
+		mark := styleOK.mark()
+		switch src.Status {
+		case outcomeFailed:
+			mark = styleFail.mark()
+			if src.Error != "" {
+				result = src.Error
+			}
+		case outcomeSkipped:
+			mark = styleWarn.mark()
+			result += ", all skipped"
+		}
+		fmt.Fprintf(w, "  %s %s: %s\n", mark, src.URL, result)
+	}
+}
+
+// write saves the report as JSON at path.
+func (r *runReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return i18n.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return i18n.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
+
+// finishReport prints the summary of a multi-video run, or with --json the
+// whole report of any run, and writes the --write-report file when one was
+// requested.
+func finishReport(r *runReport, path string, summary, asJSON bool) error {
+	r.FinishedAt = time.Now()
+	switch {
+	case asJSON:
+		if err := printJSON("report", r); err != nil {
+			return err
+		}
+	case summary:
+		r.printSummary(os.Stdout)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := r.write(path); err != nil {
+		return err
+	}
+	i18n.Printf("Report written to %s\n", path)
+	return nil
+}
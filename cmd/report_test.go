@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestRunReport(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	r := newRunReport()
+	r.addResult(&parser.VideoInfo{Title: "Done", BVID: "BV1"}, 1, &downloader.Result{Path: "/out/Done.mp4", Size: 100, Quality: 80})
+	r.addResult(&parser.VideoInfo{Title: "Old", BVID: "BV2"}, 2, &downloader.Result{Path: "/out/Old.mp4", Skipped: true})
+	r.addResult(&parser.VideoInfo{Title: "Mute", BVID: "BV3"}, 3, &downloader.Result{Path: "/out/Mute.mp4", NoAudio: true})
+	r.addSource("https://b23.tv/a", r.Entries, nil)
+	r.addFailure("Gone", "BV4", 4, errors.New("video not found"))
+	r.addSource("https://b23.tv/b", r.Entries[3:], nil)
+	r.addSource("https://b23.tv/c", nil, errors.New("failed to parse URL"))
+
+	if r.Succeeded != 2 || r.Skipped != 1 || r.Failed != 1 {
+		t.Errorf("totals = %d succeeded, %d skipped, %d failed; want 2, 1, 1", r.Succeeded, r.Skipped, r.Failed)
+	}
+
+	var out bytes.Buffer
+	r.printSummary(&out)
+	for _, want := range []string{
+		"Summary: 2 succeeded, 1 skipped, 1 failed",
+		"SKIPPED   Old   already exists: /out/Old.mp4",
+		"NO AUDIO  Mute  saved as video only",
+		"FAILED    Gone  video not found",
+		"✓ https://b23.tv/a: 3 videos",
+		"✗ https://b23.tv/b: 1 video",
+		"✗ https://b23.tv/c: failed to parse URL",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Done") {
+		t.Errorf("summary lists a plain success:\n%s", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := r.write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved runReport
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("report is not JSON: %v", err)
+	}
+	if len(saved.Entries) != 4 || saved.Entries[3].Status != outcomeFailed || saved.Entries[3].Error != "video not found" {
+		t.Errorf("saved entries = %+v", saved.Entries)
+	}
+	var statuses []string
+	for _, src := range saved.Sources {
+		statuses = append(statuses, src.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "succeeded,failed,failed" {
+		t.Errorf("saved source statuses = %s, want succeeded,failed,failed", got)
+	}
+}
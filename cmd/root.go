@@ -6,22 +6,69 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/dengmengmian/goBili/i18n"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile    string
+	configRead bool
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "goBili",
 	Short: "A Bilibili video downloader written in Go",
 	Long: `goBili is a command-line tool for downloading videos from Bilibili.
-It supports downloading single videos and playlists with the highest quality available.`,
+It supports downloading single videos and playlists with the highest quality available.
+
+A bare "goBili <URL>" is the same as "goBili download <URL>". Command aliases
+can be defined under "aliases" in the config file, e.g.
+
+  aliases:
+    dl: download -q 1080p --embed-subs
+
+Exit codes:
+  0    success
+  1    any other error
+  2    not logged in, or the login session expired
+  3    the URL could not be parsed
+  4    network error reaching Bilibili or its CDN
+  5    some videos or URLs of the run failed
+  6    ffmpeg (or MP4Box) is missing
+  7    the output could not be written (disk full or no permission)
+  130  interrupted with Ctrl+C or SIGTERM`,
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		if name := viper.GetString("profile"); name != "" {
+			return validateProfileName(name)
+		}
+		return nil
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
+	// Aliases live in the config file, so it is read before cobra parses
+	// the command line.
+	args := os.Args[1:]
+	cfgFile = configFileFromArgs(args)
+	initConfig()
+
+	// Messages are translated from the start, so the language is chosen
+	// before cobra parses the command line too.
+	lang, err := chooseLanguage(globalFlagFromArgs(args, "lang"))
+	if err != nil {
+		return err
+	}
+	i18n.SetLanguage(lang)
+	rootCmd.SetErrPrefix(i18n.T("Error:"))
+
+	args, err = expandArgs(args)
+	if err != nil {
+		return err
+	}
+	rootCmd.SetArgs(args)
 	return rootCmd.Execute()
 }
 
@@ -33,6 +80,17 @@ func init() {
 	rootCmd.PersistentFlags().StringP("output", "o", "./downloads", "output directory for downloaded videos")
 	rootCmd.PersistentFlags().IntP("threads", "t", 4, "number of download threads")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().String("ffmpeg-path", "", "path to the ffmpeg executable (default is ffmpeg from PATH)")
+	rootCmd.PersistentFlags().String("mp4box-path", "", "path to the MP4Box executable, used when ffmpeg is unavailable")
+	rootCmd.PersistentFlags().String("profile", "", "account profile to use (default is the profile selected by 'account use')")
+	rootCmd.PersistentFlags().Bool("anonymous", false, "read metadata (info, formats, danmaku...) as a guest, without the saved login")
+	rootCmd.PersistentFlags().String("credential-store", "file", "where to keep login secrets: file, keyring or encrypted (passphrase from GOBILI_PASSPHRASE)")
+	rootCmd.PersistentFlags().String("user-agent", "", "User-Agent sent to Bilibili (default is the user_agent config key or a desktop Chrome UA)")
+	rootCmd.PersistentFlags().String("referer", "", "Referer sent to Bilibili (default is https://www.bilibili.com/)")
+	rootCmd.PersistentFlags().StringArray("header", nil, "extra request header as \"Name: value\" (repeatable)")
+	rootCmd.PersistentFlags().Bool("json", false, "print info, formats, status, history, stats and download summaries as JSON")
+	rootCmd.PersistentFlags().Bool("no-color", false, "do not color the output (also set by NO_COLOR, and when stdout is not a terminal)")
+	rootCmd.PersistentFlags().String("lang", "", "language of the messages: en or zh (default is GOBILI_LANG, the locale config key or the system locale)")
 
 	// Bind flags to viper
 	if err := viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")); err != nil {
@@ -44,10 +102,46 @@ func init() {
 	if err := viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
 		cobra.CheckErr(err)
 	}
+	if err := viper.BindPFlag("ffmpeg_path", rootCmd.PersistentFlags().Lookup("ffmpeg-path")); err != nil {
+		cobra.CheckErr(err)
+	}
+	if err := viper.BindPFlag("mp4box_path", rootCmd.PersistentFlags().Lookup("mp4box-path")); err != nil {
+		cobra.CheckErr(err)
+	}
+	if err := viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile")); err != nil {
+		cobra.CheckErr(err)
+	}
+	if err := viper.BindPFlag("anonymous", rootCmd.PersistentFlags().Lookup("anonymous")); err != nil {
+		cobra.CheckErr(err)
+	}
+	if err := viper.BindPFlag("credential_store", rootCmd.PersistentFlags().Lookup("credential-store")); err != nil {
+		cobra.CheckErr(err)
+	}
+	if err := viper.BindPFlag("no_color", rootCmd.PersistentFlags().Lookup("no-color")); err != nil {
+		cobra.CheckErr(err)
+	}
+}
+
+// chooseLanguage returns the language named by the --lang flag value, by
+// GOBILI_LANG or by the locale config key, whichever is set first, and
+// otherwise the language of the system locale.
+func chooseLanguage(flag string) (i18n.Language, error) {
+	for _, name := range []string{flag, os.Getenv("GOBILI_LANG"), viper.GetString("locale")} {
+		if name != "" {
+			return i18n.Parse(name)
+		}
+	}
+	return i18n.Detect(), nil
 }
 
-// initConfig reads in config file and ENV variables if set.
+// initConfig reads in config file and ENV variables if set. Only the first
+// call has an effect.
 func initConfig() {
+	if configRead {
+		return
+	}
+	configRead = true
+
 	if cfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(cfgFile)
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/server"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/skip2/go-qrcode"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run as a daemon that takes downloads over an HTTP API",
+	Long: `Run goBili as a daemon, e.g. on a NAS, and drive it from other machines
+over an HTTP API. Submitted downloads wait in the job queue of the state
+store and run one at a time with the download flags and config given
+here; a job may choose its own quality and pages.
+
+Open http://<host>:8080/ in a browser for the web UI: paste a URL, pick
+the quality, watch the progress and download finished files.
+
+Every API request needs the API token as "Authorization: Bearer <token>".
+Set it with --token or the serve.token config key; otherwise a random
+token is generated once and kept in ~/.goBili/serve-token.
+
+  GET    /api/jobs              list the jobs, with progress and a queue ETA
+  POST   /api/jobs              queue {"url": "...", "quality": "1080p", "pages": "1-3"}
+  GET    /api/jobs/<id>         show one job
+  POST   /api/jobs/<id>/cancel  cancel a queued or running job
+  POST   /api/jobs/<id>/pause   hold a queued or running job back
+  POST   /api/jobs/<id>/resume  queue a paused job again
+  DELETE /api/jobs/<id>         remove a finished job
+  GET    /api/login             show the logged-in account
+  POST   /api/login             start a QR code login (the code is in "image", a PNG)
+  GET    /api/login/<key>       poll the QR code until "state" is "done"
+  GET    /api/files?path=<dir>  list the output directory
+  GET    /files/<path>          download a file from the output directory
+
+Ctrl+C or SIGTERM stops the server; a running download is interrupted and
+runs again on the next start.
+
+Examples:
+  goBili serve --listen :8080
+  curl -H "Authorization: Bearer $(cat ~/.goBili/serve-token)" \
+    -d '{"url": "https://www.bilibili.com/video/BV1xx411c7mu"}' http://nas:8080/api/jobs`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	// The download flags are added in download.go's init, once they exist.
+	serveCmd.Flags().String("listen", ":8080", "address to listen on")
+	serveCmd.Flags().String("token", "", "API token clients must send (default is a generated token saved in ~/.goBili/serve-token)")
+
+	for key, flag := range map[string]string{
+		"serve.listen": "listen",
+		"serve.token":  "token",
+	} {
+		if err := viper.BindPFlag(key, serveCmd.Flags().Lookup(flag)); err != nil {
+			cobra.CheckErr(err)
+		}
+	}
+}
+
+func runServe(cmd *cobra.Command, _ []string) error {
+	if viper.GetString("output") == "-" {
+		return fmt.Errorf("serve cannot stream to stdout")
+	}
+	logger := newLogger()
+
+	token, err := serveToken()
+	if err != nil {
+		return err
+	}
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open the job queue: %w", err)
+	}
+	defer st.Close()
+
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return err
+	}
+	login := &serveLogin{am: authManager}
+	if account, err := login.Account(); err != nil {
+		logger.Warnf("Could not check the login: %v", err)
+	} else if account == nil && !viper.GetBool("allow_anonymous") {
+		logger.Warn("Not logged in; jobs fail until you log in with 'goBili login' or POST /api/login")
+	}
+
+	srv := server.New(server.Options{
+		Store:    st,
+		Token:    token,
+		Run:      jobRunner(cmd, st),
+		Login:    login,
+		FilesDir: viper.GetString("output"),
+		Logger:   logger,
+	})
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	listen := viper.GetString("serve.listen")
+	logger.Infof("Listening on %s", listen)
+	if err := srv.ListenAndServe(ctx, listen); err != nil {
+		return err
+	}
+	logger.Info("Server stopped")
+	return nil
+}
+
+// serveToken returns the API token: the configured one, or a random token
+// generated on first use and kept in the config directory.
+func serveToken() (string, error) {
+	if token := viper.GetString("serve.token"); token != "" {
+		return token, nil
+	}
+
+	path := filepath.Join(getConfigDir(), "serve-token")
+	data, err := os.ReadFile(path)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", i18n.Errorf("failed to read API token: %w", err)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", i18n.Errorf("failed to generate API token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	if err := os.MkdirAll(getConfigDir(), 0700); err != nil {
+		return "", i18n.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", i18n.Errorf("failed to save API token: %w", err)
+	}
+	i18n.Printf("Generated an API token in %s\n", path)
+	return token, nil
+}
+
+// jobRunner returns the function that downloads one job of the queue.
+// Every job gets a new download session, so a login made in the meantime
+// and the job's own quality and pages apply. Concurrent jobs share the
+// muxer, connection and rate limits.
+func jobRunner(cmd *cobra.Command, st store.Store) server.RunFunc {
+	var mu sync.Mutex // presets write viper defaults while a session is built
+	processLimiter, hostLimiter, rateLimiter := processLimiterFromConfig(), hostLimiterFromConfig(), rateLimiterFromConfig()
+	return func(ctx context.Context, req server.Request, update func(server.Progress)) ([]string, error) {
+		mu.Lock()
+		s, err := newDownloadSession(cmd, sessionOverrides{
+			quality:        req.Quality,
+			pages:          req.Pages,
+			processLimiter: processLimiter,
+			hostLimiter:    hostLimiter,
+			rateLimiter:    rateLimiter,
+		})
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		videos, err := expandSource(s, req.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse URL: %w", err)
+		}
+
+		var files []string
+		var failed int
+		var lastErr error
+		for i, v := range videos {
+			progress := server.Progress{Video: i + 1, Videos: len(videos), Title: v.Info.Title}
+			update(progress)
+			videoCtx := downloader.WithProgress(ctx, func(p downloader.DownloadProgress) {
+				current := progress
+				current.Downloaded, current.Total, current.Percent = p.Downloaded, p.TotalSize, p.Percentage
+				current.Speed, current.ETA = p.Speed, int64(p.ETA/time.Second)
+				update(current)
+			})
+
+			result, err := downloadBatchVideo(videoCtx, s, st, v)
+			if ctx.Err() != nil {
+				return files, errInterrupted
+			}
+			if err != nil {
+				s.logger.Warnf("Failed to download %s: %v", v.Info.Title, err)
+				failed, lastErr = failed+1, err
+				continue
+			}
+			recordHistory(st, s.logger, v.Info, v.CID, result)
+			if result.Path != "" {
+				files = append(files, result.Path)
+			}
+		}
+		switch {
+		case failed == 0:
+			return files, nil
+		case len(videos) == 1:
+			return files, lastErr
+		default:
+			return files, fmt.Errorf("%d of %d videos failed, the last with: %w", failed, len(videos), lastErr)
+		}
+	}
+}
+
+// serveLogin logs the active profile in for the serve API.
+type serveLogin struct {
+	am *auth.AuthManager
+}
+
+// Account implements server.Login.
+func (l *serveLogin) Account() (*server.Account, error) {
+	// Pick up a login made with the CLI meanwhile.
+	if err := l.am.LoadCookies(); err != nil {
+		return nil, fmt.Errorf("failed to load cookies: %w", err)
+	}
+	if !l.am.IsAuthenticated() {
+		return nil, nil
+	}
+	status, err := l.am.GetAccountStatus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account status: %w", err)
+	}
+	if !status.IsLogin {
+		return nil, nil
+	}
+	return &server.Account{Name: status.Name, MID: status.Mid, VIP: status.VipActive()}, nil
+}
+
+// StartQRCode implements server.Login.
+func (l *serveLogin) StartQRCode() (*server.QRCode, error) {
+	qr, err := l.am.GenerateQRCode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR code: %w", err)
+	}
+	image, err := qrcode.Encode(qr.URL, qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render QR code: %w", err)
+	}
+	return &server.QRCode{Key: qr.OAuthKey, URL: qr.URL, Image: image}, nil
+}
+
+// PollQRCode implements server.Login.
+func (l *serveLogin) PollQRCode(key string) (server.QRState, error) {
+	status, err := l.am.CheckQRCodeStatus(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to check QR code status: %w", err)
+	}
+	switch status.Data.Code {
+	case 0:
+		if err := l.am.CompleteQRCodeLogin(status); err != nil {
+			return "", err
+		}
+		return server.QRDone, nil
+	case 86101:
+		return server.QRWaiting, nil
+	case 86090:
+		return server.QRScanned, nil
+	case 86038:
+		return server.QRExpired, nil
+	default:
+		return "", fmt.Errorf("login failed: %s", status.Data.Message)
+	}
+}
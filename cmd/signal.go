@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// errInterrupted is returned when goBili is stopped with Ctrl+C or SIGTERM.
+var errInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context that is cancelled on SIGINT or
+// SIGTERM, so in-flight requests and muxer processes stop and the
+// downloader can clean up. After the first signal the default handling is
+// restored: a second Ctrl+C exits immediately.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
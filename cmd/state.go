@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// openStore opens the state store selected by the state_dsn config key,
+// defaulting to the SQLite database history.db in the config directory.
+func openStore() (store.Store, error) {
+	return store.Open(viper.GetString("state_dsn"), getConfigDir())
+}
+
+// recordHistory adds a finished download to the history. History is
+// best effort: failures are logged and never fail the download.
+func recordHistory(st store.Store, logger *logrus.Logger, videoInfo *parser.VideoInfo, cid int64, result *downloader.Result) {
+	// Downloads dispatched to aria2 end up on another machine.
+	if st == nil || result == nil || result.Skipped || len(result.Dispatched) > 0 {
+		return
+	}
+	entry := &store.HistoryEntry{
+		BVID:        videoInfo.BVID,
+		CID:         cid,
+		Title:       videoInfo.Title,
+		Uploader:    videoInfo.Uploader,
+		OwnerMID:    videoInfo.OwnerMID,
+		Quality:     result.Quality,
+		Path:        result.Path,
+		Size:        result.Size,
+		CompletedAt: time.Now(),
+		Duration:    result.Elapsed,
+		Length:      partLength(videoInfo, cid),
+		NoAudio:     result.NoAudio || viper.GetBool("video_only"),
+	}
+	if err := st.AddHistory(context.Background(), entry); err != nil {
+		logger.Warnf("Failed to record download history: %v", err)
+	}
+}
+
+// partLength returns the length in seconds of the part cid of videoInfo,
+// or 0 when it is not known.
+func partLength(videoInfo *parser.VideoInfo, cid int64) int {
+	for _, page := range videoInfo.Pages {
+		if page.CID == cid {
+			return page.Duration
+		}
+	}
+	for _, episode := range videoInfo.Episodes {
+		if episode.CID == cid {
+			return episode.Duration
+		}
+	}
+	if len(videoInfo.Pages) <= 1 {
+		return videoInfo.Duration
+	}
+	return 0
+}
+
+// audioExtensions are the outputs of audio-only downloads.
+var audioExtensions = map[string]bool{".m4a": true, ".mp3": true, ".flac": true, ".ogg": true, ".opus": true}
+
+// downloadedResult returns a skipped result when the history records an
+// earlier download of the part cid of bvid whose file is still there, so
+// it is not fetched again. Audio-only downloads and videos are told apart
+// by the file extension. It returns nil with no_dedup set or when the
+// history cannot be read.
+func downloadedResult(st store.Store, logger *logrus.Logger, bvid string, cid int64) *downloader.Result {
+	if st == nil || cid == 0 || viper.GetBool("no_dedup") {
+		return nil
+	}
+	history, err := st.ListHistory(context.Background())
+	if err != nil {
+		logger.Warnf("Failed to read download history: %v", err)
+		return nil
+	}
+	audioOnly := viper.GetBool("audio_only")
+	for _, e := range store.FindDownloads(history, bvid, cid) {
+		if audioExtensions[strings.ToLower(filepath.Ext(e.Path))] != audioOnly {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue // moved or removed since
+		}
+		logger.Infof("Already downloaded on %s: %s (use --no-dedup to download it again)", e.CompletedAt.Local().Format("2006-01-02"), e.Path)
+		return &downloader.Result{Path: e.Path, Size: e.Size, Quality: e.Quality, Skipped: true}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarize the download history and the output directory",
+	Long: `Summarize the download history: the number of videos and parts
+downloaded and their size, the uploaders with the most videos, and the
+downloads of each month. The disk usage of the output directory (-o) is
+counted from the files actually there, so it also covers files moved in
+or deleted since they were downloaded.
+
+Downloads recorded before goBili kept the uploader in the history are
+counted under "unknown".
+
+Examples:
+  goBili stats
+  goBili stats --top 0 -o ~/Videos
+  goBili stats --json`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().Int("top", 10, "show this many uploaders (0 for all)")
+}
+
+// diskUsage is the size of the files under a directory.
+type diskUsage struct {
+	Path  string `json:"path"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// statsReport is the --json document of the stats command.
+type statsReport struct {
+	*store.HistoryStats
+	Output *diskUsage `json:"output,omitempty"`
+}
+
+func runStats(cmd *cobra.Command, _ []string) error {
+	top, _ := cmd.Flags().GetInt("top")
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	history, err := st.ListHistory(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	stats := store.Stats(history, time.Local)
+
+	var usage *diskUsage
+	if dir := viper.GetString("output"); dir != "-" {
+		if usage, err = dirUsage(dir); err != nil {
+			return err
+		}
+	}
+
+	if jsonOutput(cmd) {
+		return printJSON("stats", statsReport{HistoryStats: stats, Output: usage})
+	}
+
+	if stats.Parts == 0 {
+		fmt.Println("The download history is empty.")
+	} else {
+		fmt.Printf("Downloaded:  %d videos (%d parts), %s\n", stats.Videos, stats.Parts, formatSize(stats.Bytes))
+		fmt.Printf("Period:      %s to %s\n", stats.First.Local().Format("2006-01-02"), stats.Last.Local().Format("2006-01-02"))
+
+		uploaders := stats.Uploaders
+		if top > 0 && len(uploaders) > top {
+			uploaders = uploaders[:top]
+		}
+		fmt.Printf("\nUploaders (%d of %d):\n", len(uploaders), len(stats.Uploaders))
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  UPLOADER\tMID\tVIDEOS\tPARTS\tSIZE")
+		for _, u := range uploaders {
+			name, mid := u.Name, "-"
+			if name == "" {
+				name = "unknown"
+			}
+			if u.MID != 0 {
+				mid = fmt.Sprint(u.MID)
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%d\t%s\n", name, mid, u.Videos, u.Parts, formatSize(u.Bytes))
+		}
+		tw.Flush()
+
+		fmt.Println("\nBy month:")
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  MONTH\tVIDEOS\tPARTS\tSIZE")
+		for _, m := range stats.Months {
+			fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\n", m.Name, m.Videos, m.Parts, formatSize(m.Bytes))
+		}
+		tw.Flush()
+	}
+
+	if usage != nil {
+		fmt.Printf("\nOutput directory %s: %s in %d files\n", usage.Path, formatSize(usage.Bytes), usage.Files)
+	}
+	return nil
+}
+
+// dirUsage adds up the sizes of the regular files under dir. A missing
+// directory is empty.
+func dirUsage(dir string) (*diskUsage, error) {
+	usage := &diskUsage{Path: dir}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure %s: %w", dir, err)
+	}
+	return usage, nil
+}
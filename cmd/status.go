@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/i18n"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:     "status",
+	Aliases: []string{"whoami"},
+	Short:   "Show the login state of the active profile",
+	Long: `Show whether the active profile is logged in and, if so, the account
+name, UID, VIP membership, level and coins, and when the saved session
+expires.`,
+	Args: cobra.NoArgs,
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
+
+// statusReport is the --json output of the status command.
+type statusReport struct {
+	Profile        string     `json:"profile"`
+	LoggedIn       bool       `json:"logged_in"`
+	Expired        bool       `json:"expired,omitempty"` // cookies saved but rejected by Bilibili
+	Name           string     `json:"name,omitempty"`
+	UID            int64      `json:"uid,omitempty"`
+	VIP            string     `json:"vip,omitempty"`
+	VIPExpires     *time.Time `json:"vip_expires,omitempty"`
+	Level          int        `json:"level,omitempty"`
+	Exp            int        `json:"exp,omitempty"`
+	NextExp        int        `json:"next_exp,omitempty"` // absent at the top level
+	Coins          float64    `json:"coins,omitempty"`
+	SessionExpires *time.Time `json:"session_expires,omitempty"`
+	Refreshable    bool       `json:"refreshable,omitempty"`
+}
+
+func runStatus(cmd *cobra.Command, _ []string) error {
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		// Keep the report free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+
+	authManager, err := newAuthManager(activeProfile(), logger)
+	if err != nil {
+		return err
+	}
+	if err := authManager.LoadCookies(); err != nil {
+		return i18n.Errorf("failed to load cookies: %w", err)
+	}
+	if jsonOutput(cmd) {
+		return printStatusJSON(authManager)
+	}
+
+	i18n.Printf("Profile:  %s\n", activeProfile())
+	if !authManager.IsAuthenticated() {
+		i18n.Println("Status:   not logged in")
+		i18n.Println("Run 'goBili login' to authenticate.")
+		return nil
+	}
+
+	status, err := authManager.GetAccountStatus()
+	if err != nil {
+		return i18n.Errorf("failed to get account status: %w", err)
+	}
+	if !status.IsLogin {
+		i18n.Println("Status:   session expired")
+		i18n.Println("Run 'goBili login' to log in again.")
+		return nil
+	}
+
+	i18n.Println("Status:   logged in")
+	i18n.Printf("User:     %s (UID: %d)\n", status.Name, status.Mid)
+
+	vip := status.VipTier()
+	if due, ok := status.VipExpiry(); ok && status.VipActive() {
+		vip += fmt.Sprintf(", until %s", due.Format("2006-01-02"))
+	}
+	i18n.Printf("VIP:      %s\n", vip)
+
+	level := fmt.Sprintf("Lv%d", status.LevelInfo.Level)
+	if next := status.NextLevelExp(); next >= 0 {
+		level += fmt.Sprintf(" (%d/%d exp)", status.LevelInfo.Exp, next)
+	}
+	i18n.Printf("Level:    %s\n", level)
+	i18n.Printf("Coins:    %g\n", status.Coins)
+
+	if expiry, ok := authManager.SessionExpiry(); ok {
+		i18n.Printf("Session:  expires %s (in %s)\n", expiry.Format("2006-01-02 15:04"), formatDays(time.Until(expiry)))
+	} else {
+		i18n.Println("Session:  expiry unknown")
+	}
+	if authManager.RefreshToken() != "" {
+		i18n.Println("Refresh:  enabled (cookies renew automatically)")
+	} else {
+		i18n.Println("Refresh:  unavailable (log in with the QR code to enable)")
+	}
+	return nil
+}
+
+// printStatusJSON prints the login state of the active profile as JSON.
+func printStatusJSON(authManager *auth.AuthManager) error {
+	report := statusReport{Profile: activeProfile()}
+	if !authManager.IsAuthenticated() {
+		return printJSON("status", report)
+	}
+	status, err := authManager.GetAccountStatus()
+	if err != nil {
+		return i18n.Errorf("failed to get account status: %w", err)
+	}
+	if !status.IsLogin {
+		report.Expired = true
+		return printJSON("status", report)
+	}
+
+	report.LoggedIn = true
+	report.Name, report.UID = status.Name, status.Mid
+	report.VIP = status.VipTier()
+	if due, ok := status.VipExpiry(); ok && status.VipActive() {
+		report.VIPExpires = &due
+	}
+	report.Level, report.Exp = status.LevelInfo.Level, status.LevelInfo.Exp
+	if next := status.NextLevelExp(); next >= 0 {
+		report.NextExp = next
+	}
+	report.Coins = status.Coins
+	if expiry, ok := authManager.SessionExpiry(); ok {
+		report.SessionExpires = &expiry
+	}
+	report.Refreshable = authManager.RefreshToken() != ""
+	return printJSON("status", report)
+}
+
+// formatDays renders a duration in whole days, or hours below a day.
+func formatDays(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,388 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Kinds of subscriptions: to an uploader's space, or to a bangumi season.
+const (
+	subscriptionUploader = "uploader"
+	subscriptionSeason   = "season"
+)
+
+// subscribeCmd represents the subscribe command
+var subscribeCmd = &cobra.Command{
+	Use:     "subscribe",
+	Aliases: []string{"sub"},
+	Short:   "Manage the uploaders and seasons followed by 'goBili watch'",
+	Long: `Manage subscriptions to uploaders and bangumi seasons, kept in the state
+store. 'goBili watch' checks the subscribed uploaders' spaces and downloads
+their new videos that pass the subscription's filters, and downloads the
+newly released episodes of subscribed seasons as "Title S01E03".
+
+A new subscription only follows videos and episodes published from now
+on; --since also takes earlier ones, back to a date or, with "all", all
+of them.
+
+Examples:
+  goBili subscribe add 546195
+  goBili subscribe add https://space.bilibili.com/546195 --match "教程|Tutorial" --min-duration 5m
+  goBili subscribe add 546195 --since 2024-01-01 -q 720p
+  goBili subscribe add https://www.bilibili.com/bangumi/play/ss33073 --since all
+  goBili subscribe list
+  goBili watch --interval 30m`,
+	RunE: runSubscribeList,
+}
+
+var subscribeAddCmd = &cobra.Command{
+	Use:   "add <mid|space URL|season URL>",
+	Short: "Subscribe to an uploader or a bangumi season",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSubscribeAdd,
+}
+
+var subscribeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the subscriptions",
+	Args:  cobra.NoArgs,
+	RunE:  runSubscribeList,
+}
+
+var subscribeRemoveCmd = &cobra.Command{
+	Use:     "remove <id>...",
+	Aliases: []string{"rm"},
+	Short:   "Remove subscriptions",
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runSubscribeRemove,
+}
+
+func init() {
+	rootCmd.AddCommand(subscribeCmd)
+	subscribeCmd.AddCommand(subscribeAddCmd, subscribeListCmd, subscribeRemoveCmd)
+
+	subscribeAddCmd.Flags().String("match", "", "uploaders: only download videos whose title matches this regular expression")
+	subscribeAddCmd.Flags().Duration("min-duration", 0, "uploaders: only download videos at least this long (e.g. 5m)")
+	subscribeAddCmd.Flags().Duration("max-duration", 0, "uploaders: only download videos at most this long (e.g. 1h)")
+	subscribeAddCmd.Flags().String("since", "", `also download videos or episodes published since this date (YYYY-MM-DD), or "all"`)
+	subscribeAddCmd.Flags().StringP("quality", "q", "", "video quality of this subscription's downloads (default watch's)")
+}
+
+var (
+	// spaceURLPattern matches the space URL of an uploader.
+	spaceURLPattern = regexp.MustCompile(`^(?:https?://)?space\.bilibili\.com/(\d+)`)
+	// seasonPattern matches a season_id, alone as "ss33073" or in a
+	// bangumi URL.
+	seasonPattern = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.|m\.)?bilibili\.com/bangumi/play/)?ss(\d+)`)
+)
+
+// parseSubscriptionTarget returns the kind and target of a subscription
+// from a space URL or a bare mid, for uploaders, or a season URL or
+// "ss<season_id>", for seasons.
+func parseSubscriptionTarget(arg string) (kind, target string, err error) {
+	arg = strings.TrimSpace(arg)
+	if m := seasonPattern.FindStringSubmatch(arg); m != nil {
+		return subscriptionSeason, m[1], nil
+	}
+	if m := spaceURLPattern.FindStringSubmatch(arg); m != nil {
+		arg = m[1]
+	}
+	mid, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || mid <= 0 {
+		return "", "", fmt.Errorf("invalid subscription %q (want a mid, a space.bilibili.com URL or a bangumi season URL)", arg)
+	}
+	return subscriptionUploader, arg, nil
+}
+
+// seasonURL returns the URL of the season with season_id id.
+func seasonURL(id string) string {
+	return "https://www.bilibili.com/bangumi/play/ss" + id
+}
+
+// parseSince returns the publish time, in Unix seconds, after which the
+// videos or episodes of a new subscription are downloaded: now, a date, or
+// 0 for all.
+func parseSince(since string, now time.Time) (int64, error) {
+	switch since {
+	case "":
+		return now.Unix(), nil
+	case "all":
+		return 0, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --since %q (want YYYY-MM-DD or all)", since)
+	}
+	// Videos published on the date itself are included.
+	return t.Unix() - 1, nil
+}
+
+func runSubscribeAdd(cmd *cobra.Command, args []string) error {
+	kind, target, err := parseSubscriptionTarget(args[0])
+	if err != nil {
+		return err
+	}
+	match, _ := cmd.Flags().GetString("match")
+	minDuration, _ := cmd.Flags().GetDuration("min-duration")
+	maxDuration, _ := cmd.Flags().GetDuration("max-duration")
+	sinceFlag, _ := cmd.Flags().GetString("since")
+	quality, _ := cmd.Flags().GetString("quality")
+
+	since, err := parseSince(sinceFlag, time.Now())
+	if err != nil {
+		return err
+	}
+	options := make(map[string]string)
+	if quality != "" {
+		options["quality"] = quality
+	}
+	if kind == subscriptionUploader {
+		options["since"] = strconv.FormatInt(since, 10)
+		if match != "" {
+			options["match"] = match
+		}
+		if minDuration > 0 {
+			options["min_duration"] = minDuration.String()
+		}
+		if maxDuration > 0 {
+			options["max_duration"] = maxDuration.String()
+		}
+		// Check the filters now rather than on every poll.
+		if _, err := newUploadFilter(options); err != nil {
+			return err
+		}
+	} else if match != "" || minDuration > 0 || maxDuration > 0 {
+		return fmt.Errorf("--match, --min-duration and --max-duration only filter the videos of uploaders")
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	ctx := context.Background()
+	subs, err := st.ListSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		if sub.Kind == kind && sub.Target == target {
+			return fmt.Errorf("already subscribed to %s as %s; remove it first to change its settings", subscriptionName(sub), sub.ID)
+		}
+	}
+
+	// Looking the target up checks it and finds its name.
+	logger := newLogger()
+	if !viper.GetBool("verbose") {
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, _, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+	sub := &store.Subscription{
+		Kind:      kind,
+		Target:    target,
+		Options:   options,
+		CreatedAt: time.Now(),
+	}
+	var found string
+	switch kind {
+	case subscriptionUploader:
+		mid, _ := strconv.ParseInt(target, 10, 64)
+		videos, total, err := p.GetUploaderVideos(mid, 1, 1)
+		if err != nil {
+			return fmt.Errorf("failed to look up uploader %d: %w", mid, err)
+		}
+		if len(videos) > 0 {
+			sub.Name = videos[0].Author
+		}
+		found = fmt.Sprintf("%d videos", total)
+	case subscriptionSeason:
+		info, err := p.ParseURL(seasonURL(target))
+		if err != nil {
+			return fmt.Errorf("failed to look up season %s: %w", target, err)
+		}
+		sub.Name = info.Title
+		options["episodes"] = strconv.Itoa(episodesReleasedBy(info.Episodes, since))
+		found = fmt.Sprintf("%d episodes", len(info.Episodes))
+	}
+
+	if err := st.PutSubscription(ctx, sub); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	fmt.Printf("Subscribed to %s (%s) as %s\n", subscriptionName(sub), found, sub.ID)
+	return nil
+}
+
+// episodesReleasedBy returns the number of leading episodes released by
+// since (Unix seconds), which a new season subscription treats as seen. A
+// since of 0 takes every episode.
+func episodesReleasedBy(episodes []*parser.EpisodeInfo, since int64) int {
+	if since == 0 {
+		return 0
+	}
+	n := 0
+	for _, ep := range episodes {
+		if ep.PubTime > since {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+func runSubscribeList(cmd *cobra.Command, _ []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	subs, err := st.ListSubscriptions(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+	if jsonOutput(cmd) {
+		if subs == nil {
+			subs = []*store.Subscription{}
+		}
+		return printJSON("subscriptions", subs)
+	}
+
+	if len(subs) == 0 {
+		fmt.Println("No subscriptions. Add one with 'goBili subscribe add <mid>'.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tNAME\tLAST CHECKED\tFILTERS")
+	for _, sub := range subs {
+		checked := "never"
+		if !sub.LastChecked.IsZero() {
+			checked = sub.LastChecked.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", sub.ID, sub.Kind, subscriptionName(sub), checked, orDash(subscriptionDetails(sub)))
+	}
+	return tw.Flush()
+}
+
+func runSubscribeRemove(_ *cobra.Command, args []string) error {
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	var failed int
+	for _, id := range args {
+		if err := st.DeleteSubscription(context.Background(), id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				err = fmt.Errorf("no such subscription")
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Removed %s\n", id)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d subscriptions could not be removed", failed, len(args))
+	}
+	return nil
+}
+
+// subscriptionName returns the name of a subscription's uploader or season
+// with its mid or season_id, or the ID alone when the name is not known.
+func subscriptionName(sub *store.Subscription) string {
+	id := sub.Target
+	if sub.Kind == subscriptionSeason {
+		id = "ss" + id
+	}
+	if sub.Name == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", sub.Name, id)
+}
+
+// subscriptionDetails summarizes a subscription's filters and settings for
+// the list.
+func subscriptionDetails(sub *store.Subscription) string {
+	var details []string
+	if match := sub.Options["match"]; match != "" {
+		details = append(details, fmt.Sprintf("title ~ %q", match))
+	}
+	if d := sub.Options["min_duration"]; d != "" {
+		details = append(details, "at least "+d)
+	}
+	if d := sub.Options["max_duration"]; d != "" {
+		details = append(details, "at most "+d)
+	}
+	if quality := sub.Options["quality"]; quality != "" {
+		details = append(details, "quality "+quality)
+	}
+	return strings.Join(details, ", ")
+}
+
+// uploadFilter selects the videos of a subscription that are downloaded.
+type uploadFilter struct {
+	match       *regexp.Regexp
+	minDuration time.Duration
+	maxDuration time.Duration
+}
+
+// newUploadFilter reads the filter of a subscription from its options.
+func newUploadFilter(options map[string]string) (*uploadFilter, error) {
+	f := &uploadFilter{}
+	if match := options["match"]; match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match pattern: %w", err)
+		}
+		f.match = re
+	}
+	for key, d := range map[string]*time.Duration{"min_duration": &f.minDuration, "max_duration": &f.maxDuration} {
+		if s := options[key]; s != "" {
+			v, err := time.ParseDuration(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %w", key, s, err)
+			}
+			*d = v
+		}
+	}
+	if f.maxDuration > 0 && f.minDuration > f.maxDuration {
+		return nil, fmt.Errorf("the minimum duration %s exceeds the maximum %s", f.minDuration, f.maxDuration)
+	}
+	return f, nil
+}
+
+// accepts reports whether v passes the filter. Videos of unknown length
+// pass the duration limits.
+func (f *uploadFilter) accepts(v *parser.UploaderVideo) bool {
+	if f.match != nil && !f.match.MatchString(v.Title) {
+		return false
+	}
+	if d := time.Duration(v.Duration()) * time.Second; d > 0 {
+		if f.minDuration > 0 && d < f.minDuration {
+			return false
+		}
+		if f.maxDuration > 0 && d > f.maxDuration {
+			return false
+		}
+	}
+	return true
+}
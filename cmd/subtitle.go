@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// subtitleCmd represents the subtitle command
+var subtitleCmd = &cobra.Command{
+	Use:   "subtitle <URL>",
+	Short: "List or download the CC subtitles of a video",
+	Long: `List the CC subtitles of every part of a video, multi-part video or
+bangumi, or download them without the media as SRT, WebVTT or ASS. Files
+are named <title>.<lang>.<format> in the output directory.
+
+--lang picks languages in order of preference: "zh-CN" matches that
+track and its AI-generated "ai-zh-CN" twin, and a bare language such as
+"en" matches every region of it. Without --lang every track is saved.
+With -o - the first matching track of a single part is written to stdout.
+To set the interface language, put the global --lang before the command:
+goBili --lang zh subtitle ...
+
+Examples:
+  goBili subtitle --list "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili subtitle --lang zh-CN,en "https://www.bilibili.com/video/BV1xx411c7mu"
+  goBili subtitle --lang en --format vtt -p 1-3 -o ~/Videos "https://www.bilibili.com/video/BV1xx411c7mu"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSubtitle,
+}
+
+func init() {
+	rootCmd.AddCommand(subtitleCmd)
+
+	subtitleCmd.Flags().StringSlice("lang", nil, "subtitle languages to save, e.g. zh-CN,en (default all)")
+	subtitleCmd.Flags().String("format", "srt", "file format: srt, vtt or ass")
+	subtitleCmd.Flags().StringP("pages", "p", "all", "parts of a multi-part video or playlist (e.g., 1,2,3 or 1-5 or all)")
+	subtitleCmd.Flags().BoolP("list", "l", false, "list the available subtitles instead of downloading them")
+}
+
+func runSubtitle(cmd *cobra.Command, args []string) error {
+	langs, _ := cmd.Flags().GetStringSlice("lang")
+	format, _ := cmd.Flags().GetString("format")
+	pages, _ := cmd.Flags().GetString("pages")
+	list, _ := cmd.Flags().GetBool("list")
+
+	format = strings.ToLower(format)
+	if err := downloader.ValidateSubtitleFormat(format); err != nil {
+		return err
+	}
+
+	logger := newLogger()
+	output := viper.GetString("output")
+	if (list || output == "-") && !viper.GetBool("verbose") {
+		// Keep the output free of cookie loading messages.
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	p, authManager, err := newReadOnlyParser(logger)
+	if err != nil {
+		return err
+	}
+
+	videoInfo, err := p.ParseURL(args[0])
+	if err != nil {
+		return parseURLError(err)
+	}
+	parts, err := selectParts(videoInfo, pages)
+	if err != nil {
+		return err
+	}
+	if len(parts) == 0 {
+		return fmt.Errorf("no parts selected by --pages %s", pages)
+	}
+	if list {
+		return listSubtitles(p, parts)
+	}
+	if output == "-" && len(parts) > 1 {
+		return fmt.Errorf("-o - writes one part; choose it with --pages")
+	}
+	if output != "-" {
+		if err := os.MkdirAll(output, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	dl := downloader.NewDownloader(downloader.Config{
+		FFmpegPath:  viper.GetString("ffmpeg_path"),
+		AuthManager: authManager,
+	})
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var saved, failed int
+	for _, part := range parts {
+		info, err := p.GetPlayerInfo(part.bvid, part.cid)
+		if err != nil {
+			logger.Warnf("Failed to look up subtitles for %s: %v", part.title, err)
+			failed++
+			continue
+		}
+		subs := matchSubtitles(info.Subtitles, langs)
+		if len(subs) == 0 {
+			logger.Warnf("No matching subtitles for %s", part.title)
+			continue
+		}
+		if output == "-" {
+			subs = subs[:1]
+		}
+
+		for _, sub := range subs {
+			data, err := dl.FetchSubtitle(ctx, sub.URL, format)
+			if ctx.Err() != nil {
+				return errInterrupted
+			}
+			if err != nil {
+				logger.Warnf("Failed to download %s subtitles for %s: %v", sub.Lang, part.title, err)
+				failed++
+				continue
+			}
+			if output == "-" {
+				if _, err := os.Stdout.Write(data); err != nil {
+					return fmt.Errorf("failed to write subtitles: %w", err)
+				}
+				saved++
+				continue
+			}
+			name := downloader.SanitizeFilename(part.title) + "." + downloader.SanitizeFilename(sub.Lang) + "." + format
+			path := filepath.Join(output, name)
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				return fmt.Errorf("failed to save subtitles: %w", err)
+			}
+			fmt.Printf("Saved %s subtitles to %s\n", sub.Lang, path)
+			saved++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d subtitle downloads failed", failed)
+	}
+	if saved == 0 {
+		return fmt.Errorf("no subtitles to save; see the available ones with --list")
+	}
+	return nil
+}
+
+// listSubtitles prints the subtitle tracks of every part.
+func listSubtitles(p *parser.BilibiliParser, parts []videoPart) error {
+	for i, part := range parts {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", part.title)
+		info, err := p.GetPlayerInfo(part.bvid, part.cid)
+		if err != nil {
+			fmt.Printf("  unavailable (%v)\n", err)
+			continue
+		}
+		if len(info.Subtitles) == 0 {
+			fmt.Println("  no subtitles")
+			continue
+		}
+		for _, sub := range info.Subtitles {
+			fmt.Printf("  %-10s %s\n", sub.Lang, sub.Name)
+		}
+	}
+	return nil
+}
+
+// matchSubtitles returns the subtitles matching langs, in the order of
+// langs, or all of them when langs is empty. A language matches its
+// AI-generated twin ("ai-" prefix), and one without a region matches
+// every region of it.
+func matchSubtitles(subs []parser.Subtitle, langs []string) []parser.Subtitle {
+	if len(langs) == 0 {
+		return subs
+	}
+	var matched []parser.Subtitle
+	seen := make(map[string]bool)
+	for _, want := range langs {
+		want = strings.TrimSpace(want)
+		for _, sub := range subs {
+			if seen[sub.Lang] || !subtitleLangMatches(sub.Lang, want) {
+				continue
+			}
+			seen[sub.Lang] = true
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+// subtitleLangMatches reports whether the subtitle language lang, such as
+// "zh-CN" or "ai-en", is selected by want.
+func subtitleLangMatches(lang, want string) bool {
+	lang = strings.TrimPrefix(strings.ToLower(lang), "ai-")
+	want = strings.TrimPrefix(strings.ToLower(want), "ai-")
+	if lang == want {
+		return true
+	}
+	base, _, _ := strings.Cut(lang, "-")
+	return !strings.Contains(want, "-") && base == want
+}
@@ -0,0 +1,773 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+const (
+	// tuiRefresh is how often the screen is redrawn when something changed.
+	tuiRefresh = 100 * time.Millisecond
+	// tuiMaxLogs is the number of log lines kept for the log pane.
+	tuiMaxLogs = 200
+)
+
+// tuiQualities are the choices of the quality picker.
+var tuiQualities = []string{"best", "1080p", "720p", "480p", "360p"}
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:     "tui [URL]",
+	Aliases: []string{"interactive"},
+	Short:   "Download in a full-screen terminal UI",
+	Long: `Download in a full-screen terminal UI instead of with flags: paste a URL,
+tick the parts or episodes to download, pick the quality and watch the
+progress bars and the log. "goBili <URL> --interactive" opens it with the
+URL already loaded. The download flags and config apply as usual.
+
+Keys:
+  Enter        load the URL / start the download / enter a new URL
+  ↑ ↓  (k j)   move through the parts
+  Space        tick or untick a part; a ticks or unticks all
+  ← →  (h l)   change the quality
+  Esc          go back; cancels a running download
+  q, Ctrl+C    quit (Ctrl+C cancels a running download first)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runTUI,
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+	// The download flags are added in download.go's init, once they exist.
+}
+
+// tuiState is the screen the UI shows.
+type tuiState int
+
+const (
+	tuiEnterURL tuiState = iota
+	tuiLoading
+	tuiSelect
+	tuiDownloading
+	tuiDone
+)
+
+// Part states on the download screen.
+const (
+	partWaiting     = "waiting"
+	partDownloading = "downloading"
+	partDone        = "done"
+	partSkipped     = "skipped"
+	partFailed      = "failed"
+	partCanceled    = "canceled"
+)
+
+// tuiPart is a page or episode of the loaded URL.
+type tuiPart struct {
+	title    string
+	selected bool
+	status   string
+	percent  float64
+	detail   string
+}
+
+// tui is the state of the terminal UI. Keys, the download and the log pipe
+// change it from their own goroutines; mu guards every field.
+type tui struct {
+	mu      sync.Mutex
+	cmd     *cobra.Command
+	st      store.Store
+	ctx     context.Context
+	state   tuiState
+	input   []rune
+	message string
+	url     string
+	title   string
+	parts   []*tuiPart
+	cursor  int
+	offset  int // first part shown in the list
+	quality int
+	logs    []string
+	cancel  context.CancelFunc // cancels the running download
+	running sync.WaitGroup     // the running download
+	quit    bool
+	dirty   bool
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	if viper.GetString("output") == "-" {
+		return fmt.Errorf("tui cannot stream to stdout")
+	}
+	inFD, outFD := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFD) || !term.IsTerminal(outFD) {
+		return fmt.Errorf("tui needs an interactive terminal")
+	}
+
+	st, storeErr := openStore()
+	if storeErr == nil {
+		defer st.Close()
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	t := &tui{cmd: cmd, st: st, ctx: ctx, dirty: true}
+	for i, q := range tuiQualities {
+		if q == viper.GetString("quality") {
+			t.quality = i
+		}
+	}
+	if storeErr != nil {
+		t.log(fmt.Sprintf("Download history disabled: %v", storeErr))
+	}
+
+	screen, err := openTUIScreen(inFD, t.log)
+	if err != nil {
+		return err
+	}
+	defer screen.close()
+
+	if len(args) == 1 {
+		t.mu.Lock()
+		t.input = []rune(args[0])
+		t.load()
+		t.mu.Unlock()
+	}
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+	for {
+		t.mu.Lock()
+		if t.quit {
+			cancel := t.cancel
+			t.mu.Unlock()
+			if cancel != nil {
+				// Let the downloader clean up before the terminal is restored.
+				cancel()
+				t.running.Wait()
+			}
+			return nil
+		}
+		if t.dirty {
+			t.dirty = false
+			width, height, err := term.GetSize(outFD)
+			if err != nil {
+				width, height = 80, 24
+			}
+			screen.draw(t.render(width, height))
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.mu.Lock()
+			t.quit = true
+			t.mu.Unlock()
+		case key, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			t.handleKey(key)
+		case <-ticker.C:
+		}
+	}
+}
+
+// log adds a line to the log pane.
+func (t *tui) log(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logs = append(t.logs, line)
+	if len(t.logs) > tuiMaxLogs {
+		t.logs = t.logs[len(t.logs)-tuiMaxLogs:]
+	}
+	t.dirty = true
+}
+
+// handleKey applies a key press to the current screen.
+func (t *tui) handleKey(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirty = true
+
+	switch t.state {
+	case tuiEnterURL:
+		switch key {
+		case "esc", "ctrl-c":
+			t.quit = true
+		case "enter":
+			if strings.TrimSpace(string(t.input)) != "" {
+				t.load()
+			}
+		case "backspace":
+			if len(t.input) > 0 {
+				t.input = t.input[:len(t.input)-1]
+			}
+		case "ctrl-u":
+			t.input = nil
+		default:
+			if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+				t.input = append(t.input, r)
+			}
+		}
+
+	case tuiLoading:
+		if key == "esc" || key == "ctrl-c" || key == "q" {
+			t.quit = true
+		}
+
+	case tuiSelect:
+		switch key {
+		case "up", "k":
+			if t.cursor > 0 {
+				t.cursor--
+			}
+		case "down", "j":
+			if t.cursor < len(t.parts)-1 {
+				t.cursor++
+			}
+		case "left", "h":
+			t.quality = (t.quality + len(tuiQualities) - 1) % len(tuiQualities)
+		case "right", "l":
+			t.quality = (t.quality + 1) % len(tuiQualities)
+		case " ":
+			t.parts[t.cursor].selected = !t.parts[t.cursor].selected
+		case "a":
+			all := true
+			for _, p := range t.parts {
+				all = all && p.selected
+			}
+			for _, p := range t.parts {
+				p.selected = !all
+			}
+		case "enter":
+			t.startDownload()
+		case "esc":
+			t.state, t.message = tuiEnterURL, ""
+		case "q", "ctrl-c":
+			t.quit = true
+		}
+
+	case tuiDownloading:
+		switch key {
+		case "esc", "ctrl-c", "q":
+			if t.cancel != nil {
+				t.cancel()
+				t.message = "Canceling..."
+			}
+		}
+
+	case tuiDone:
+		switch key {
+		case "enter", "n":
+			t.state, t.input, t.message, t.parts, t.title = tuiEnterURL, nil, "", nil, ""
+		case "esc", "q", "ctrl-c":
+			t.quit = true
+		}
+	}
+}
+
+// load parses the entered URL in the background and shows its parts.
+// t.mu is held.
+func (t *tui) load() {
+	t.url = strings.TrimSpace(string(t.input))
+	t.state, t.message, t.dirty = tuiLoading, "", true
+	url := t.url
+
+	go func() {
+		s, err := newDownloadSession(t.cmd, sessionOverrides{logFormatter: tuiLogFormatter{}})
+		if err == nil {
+			var parts []videoPart
+			videoInfo, parseErr := s.parser.ParseURL(url)
+			if parseErr == nil {
+				parts, parseErr = selectParts(videoInfo, "all")
+			}
+			if parseErr == nil {
+				t.mu.Lock()
+				t.title, t.parts, t.cursor, t.offset = videoInfo.Title, nil, 0, 0
+				for _, p := range parts {
+					t.parts = append(t.parts, &tuiPart{title: p.title, selected: true})
+				}
+				t.state, t.dirty = tuiSelect, true
+				t.mu.Unlock()
+				return
+			}
+			err = fmt.Errorf("failed to parse URL: %w", parseErr)
+		}
+		t.mu.Lock()
+		t.state, t.message, t.dirty = tuiEnterURL, err.Error(), true
+		t.mu.Unlock()
+	}()
+}
+
+// startDownload downloads the ticked parts in the background. t.mu is held.
+func (t *tui) startDownload() {
+	var indices []int
+	var pages []string
+	for i, p := range t.parts {
+		if p.selected {
+			indices = append(indices, i)
+			pages = append(pages, strconv.Itoa(i+1))
+		}
+		p.status, p.percent, p.detail = "", 0, ""
+	}
+	if len(indices) == 0 {
+		t.message = "Tick at least one part with Space."
+		return
+	}
+	for _, i := range indices {
+		t.parts[i].status = partWaiting
+	}
+
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.state, t.message, t.cancel, t.offset = tuiDownloading, "", cancel, 0
+	t.running.Add(1)
+	go func() {
+		defer t.running.Done()
+		defer cancel()
+		summary := t.download(ctx, tuiQualities[t.quality], strings.Join(pages, ","), indices)
+		t.mu.Lock()
+		t.state, t.message, t.cancel, t.dirty = tuiDone, summary, nil, true
+		t.mu.Unlock()
+	}()
+}
+
+// download runs the download of the parts at indices and returns a
+// summary for the status line.
+func (t *tui) download(ctx context.Context, quality, pages string, indices []int) string {
+	s, err := newDownloadSession(t.cmd, sessionOverrides{quality: quality, pages: pages, logFormatter: tuiLogFormatter{}})
+	if err != nil {
+		return "Failed: " + err.Error()
+	}
+	videos, err := expandSource(s, t.url)
+	if err != nil {
+		return "Failed to parse URL: " + err.Error()
+	}
+	if len(videos) > len(indices) {
+		videos = videos[:len(indices)]
+	}
+
+	var done, skipped, failed int
+	for i, v := range videos {
+		t.mu.Lock()
+		part := t.parts[indices[i]]
+		if ctx.Err() != nil {
+			part.status = partCanceled
+			t.mu.Unlock()
+			continue
+		}
+		part.status, t.dirty = partDownloading, true
+		t.mu.Unlock()
+
+		videoCtx := downloader.WithProgress(ctx, func(p downloader.DownloadProgress) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			part.percent = p.Percentage
+			part.detail = formatSize(p.Downloaded)
+			if p.TotalSize > 0 {
+				part.detail += " / " + formatSize(p.TotalSize)
+			}
+			if p.Speed > 0 {
+				part.detail += "  " + formatSize(p.Speed) + "/s"
+			}
+			if p.ETA > 0 {
+				part.detail += "  ETA " + formatDuration(int(p.ETA/time.Second))
+			}
+			t.dirty = true
+		})
+		result, err := downloadBatchVideo(videoCtx, s, t.st, v)
+
+		t.mu.Lock()
+		switch {
+		case ctx.Err() != nil:
+			part.status, part.detail = partCanceled, ""
+		case err != nil:
+			part.status, part.detail = partFailed, err.Error()
+			failed++
+		case result.Skipped:
+			part.status, part.percent, part.detail = partSkipped, 100, "already downloaded: "+result.Path
+			skipped++
+		default:
+			part.status, part.percent, part.detail = partDone, 100, fmt.Sprintf("%s  %s", formatSize(result.Size), result.Path)
+			done++
+		}
+		t.dirty = true
+		t.mu.Unlock()
+		if err == nil && ctx.Err() == nil {
+			recordHistory(t.st, s.logger, v.Info, v.CID, result)
+		}
+	}
+
+	summary := fmt.Sprintf("%d downloaded, %d skipped, %d failed", done, skipped, failed)
+	if ctx.Err() != nil {
+		summary = "Canceled; " + summary
+	}
+	return summary
+}
+
+// render returns the screen lines for a terminal of the given size. t.mu
+// is held.
+func (t *tui) render(width, height int) []string {
+	header := " goBili"
+	if t.title != "" {
+		header += " — " + t.title
+	}
+	lines := []string{"\x1b[7m" + padRight(fitWidth(header, width), width) + "\x1b[0m", ""}
+
+	logHeight := max(3, height/3)
+	bodyHeight := height - logHeight - 4 // header, blank, log rule, footer
+	if t.message != "" {
+		bodyHeight -= 2 // blank, message
+	}
+	bodyHeight = max(bodyHeight, 3)
+	var body []string
+	var footer string
+
+	switch t.state {
+	case tuiEnterURL:
+		body = append(body, " Video, playlist, season or favorites URL:", "", " > "+string(t.input)+"█")
+		body = append(body, "", " Output: "+viper.GetString("output"))
+		footer = "Enter load · Ctrl+U clear · Esc quit"
+	case tuiLoading:
+		body = append(body, " Loading "+t.url+" ...")
+		footer = "Esc quit"
+	case tuiSelect:
+		selected := 0
+		for _, p := range t.parts {
+			if p.selected {
+				selected++
+			}
+		}
+		body = append(body, " Quality: ◀ "+tuiQualities[t.quality]+" ▶", fmt.Sprintf(" Parts: %d of %d selected", selected, len(t.parts)))
+		rows := bodyHeight - len(body)
+		t.scrollTo(t.cursor, rows)
+		for i := t.offset; i < len(t.parts) && i < t.offset+rows; i++ {
+			p := t.parts[i]
+			cursor, box := " ", "[ ]"
+			if i == t.cursor {
+				cursor = ">"
+			}
+			if p.selected {
+				box = "[x]"
+			}
+			body = append(body, fmt.Sprintf(" %s %s %3d. %s", cursor, box, i+1, p.title))
+		}
+		footer = "↑↓ move · Space tick · a all · ←→ quality · Enter download · Esc back · q quit"
+	case tuiDownloading, tuiDone:
+		body = append(body, " Quality: "+tuiQualities[t.quality]+" · Output: "+viper.GetString("output"))
+		var shown []int
+		active := 0
+		for i, p := range t.parts {
+			if p.status != "" {
+				if p.status == partDownloading {
+					active = len(shown)
+				}
+				shown = append(shown, i)
+			}
+		}
+		rows := bodyHeight - len(body)
+		t.scrollTo(active, rows)
+		for j := t.offset; j < len(shown) && j < t.offset+rows; j++ {
+			i := shown[j]
+			p := t.parts[i]
+			body = append(body, fmt.Sprintf(" %s %s %5.1f%% %3d. %s  %s", partMark(p.status), progressBar(p.percent, 20), p.percent, i+1, p.title, p.detail))
+		}
+		if t.state == tuiDownloading {
+			footer = "Esc cancel"
+		} else {
+			footer = "Enter new URL · q quit"
+		}
+	}
+	for i := 0; i < bodyHeight; i++ {
+		line := ""
+		if i < len(body) {
+			line = body[i]
+		}
+		lines = append(lines, fitWidth(line, width))
+	}
+	if t.message != "" {
+		lines = append(lines, "", fitWidth(" "+t.message, width))
+	}
+
+	lines = append(lines, fitWidth("── Log "+strings.Repeat("─", width), width))
+	logs := t.logs
+	if len(logs) > logHeight {
+		logs = logs[len(logs)-logHeight:]
+	}
+	for i := 0; i < logHeight; i++ {
+		line := ""
+		if i < len(logs) {
+			line = " " + logs[i]
+		}
+		lines = append(lines, "\x1b[2m"+fitWidth(line, width)+"\x1b[0m")
+	}
+	return append(lines, "\x1b[7m"+padRight(fitWidth(" "+footer, width), width)+"\x1b[0m")
+}
+
+// scrollTo adjusts the list offset so that row is among the rows shown.
+func (t *tui) scrollTo(row, rows int) {
+	if rows < 1 {
+		rows = 1
+	}
+	if row < t.offset {
+		t.offset = row
+	}
+	if row >= t.offset+rows {
+		t.offset = row - rows + 1
+	}
+}
+
+// partMark returns the symbol of a part status.
+func partMark(status string) string {
+	switch status {
+	case partDownloading:
+		return "\x1b[36m↓\x1b[0m"
+	case partDone:
+		return "\x1b[32m✔\x1b[0m"
+	case partSkipped:
+		return "\x1b[33m↷\x1b[0m"
+	case partFailed:
+		return "\x1b[31m✘\x1b[0m"
+	case partCanceled:
+		return "\x1b[2m-\x1b[0m"
+	default:
+		return "·"
+	}
+}
+
+// progressBar draws percent as a bar of the given width.
+func progressBar(percent float64, width int) string {
+	filled := int(percent / 100 * float64(width))
+	filled = min(max(filled, 0), width)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", width-filled) + "]"
+}
+
+// fitWidth cuts s to at most width display columns. Escape sequences take
+// no columns.
+func fitWidth(s string, width int) string {
+	var b strings.Builder
+	cols, escape := 0, false
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = r != 'm'
+		case r == '\x1b':
+			escape = true
+		default:
+			if cols+downloader.RuneWidth(r) > width {
+				b.WriteString("\x1b[0m")
+				return b.String()
+			}
+			cols += downloader.RuneWidth(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// padRight pads s with spaces to width display columns.
+func padRight(s string, width int) string {
+	cols, escape := 0, false
+	for _, r := range s {
+		switch {
+		case escape:
+			escape = r != 'm'
+		case r == '\x1b':
+			escape = true
+		default:
+			cols += downloader.RuneWidth(r)
+		}
+	}
+	if cols >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-cols)
+}
+
+// tuiLogFormatter writes log entries as "LEVEL message" for the log pane.
+type tuiLogFormatter struct{}
+
+// Format implements logrus.Formatter.
+func (tuiLogFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	level := strings.ToUpper(entry.Level.String())
+	if len(level) > 4 {
+		level = level[:4]
+	}
+	return []byte(fmt.Sprintf("%-4s %s\n", level, entry.Message)), nil
+}
+
+// tuiScreen owns the terminal while the UI runs: raw input, the alternate
+// screen, and stdout and stderr redirected to the log pane so that the
+// downloader and ffmpeg cannot write over the UI.
+type tuiScreen struct {
+	out      *os.File
+	inFD     int
+	oldState *term.State
+	stdout   *os.File
+	stderr   *os.File
+	pipe     *os.File
+	logged   chan struct{}
+}
+
+// openTUIScreen takes over the terminal and passes every line written to
+// stdout or stderr to logLine.
+func openTUIScreen(inFD int, logLine func(string)) (*tuiScreen, error) {
+	oldState, err := term.MakeRaw(inFD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the terminal: %w", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		term.Restore(inFD, oldState) //nolint:errcheck // best effort
+		return nil, fmt.Errorf("failed to capture output: %w", err)
+	}
+
+	s := &tuiScreen{out: os.Stdout, inFD: inFD, oldState: oldState, stdout: os.Stdout, stderr: os.Stderr, pipe: w, logged: make(chan struct{})}
+	os.Stdout, os.Stderr = w, w
+	go func() {
+		defer close(s.logged)
+		readLogLines(r, logLine)
+	}()
+
+	// Alternate screen, hidden cursor.
+	fmt.Fprint(s.out, "\x1b[?1049h\x1b[?25l")
+	return s, nil
+}
+
+// draw replaces the screen contents with lines.
+func (s *tuiScreen) draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(s.out, b.String()) //nolint:errcheck // nothing to do about a broken terminal
+}
+
+// close gives the terminal back.
+func (s *tuiScreen) close() {
+	os.Stdout, os.Stderr = s.stdout, s.stderr
+	s.pipe.Close()
+	<-s.logged
+	fmt.Fprint(s.out, "\x1b[?25h\x1b[?1049l")
+	term.Restore(s.inFD, s.oldState) //nolint:errcheck // best effort
+}
+
+// readLogLines splits r into lines at \n and \r and passes them to logLine.
+// The downloader's own progress lines, which are the ones redrawn after a
+// \r, are dropped; the UI draws progress bars instead.
+func readLogLines(r io.Reader, logLine func(string)) {
+	br := bufio.NewReader(r)
+	var line strings.Builder
+	redrawn := false
+	flush := func() {
+		text := strings.TrimSpace(line.String())
+		line.Reset()
+		if text == "" || redrawn {
+			return
+		}
+		logLine(text)
+	}
+	for {
+		r, _, err := br.ReadRune()
+		if err != nil {
+			flush()
+			return
+		}
+		switch {
+		case r == '\n' || r == '\r':
+			flush()
+			redrawn = r == '\r'
+		case r == '\t':
+			line.WriteByte(' ')
+		case unicode.IsControl(r):
+			// Drops escape sequences' ESC; the rest is harmless text.
+		default:
+			line.WriteRune(r)
+		}
+	}
+}
+
+// readKeys sends the keys typed on in to keys, as "up", "enter", "ctrl-c"
+// and so on, or as the typed character. It closes keys when in ends.
+func readKeys(in io.Reader, keys chan<- string) {
+	defer close(keys)
+	buf := make([]byte, 256)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		for data := buf[:n]; len(data) > 0; {
+			key, size := decodeKey(data)
+			data = data[size:]
+			if key != "" {
+				keys <- key
+			}
+		}
+	}
+}
+
+// decodeKey returns the first key in data and its length in bytes.
+func decodeKey(data []byte) (string, int) {
+	switch data[0] {
+	case 0x1b:
+		if len(data) >= 3 && (data[1] == '[' || data[1] == 'O') {
+			switch data[2] {
+			case 'A':
+				return "up", 3
+			case 'B':
+				return "down", 3
+			case 'C':
+				return "right", 3
+			case 'D':
+				return "left", 3
+			}
+			// Skip other sequences up to their final byte.
+			for i := 2; i < len(data); i++ {
+				if data[i] >= 0x40 && data[i] <= 0x7e {
+					return "", i + 1
+				}
+			}
+			return "", len(data)
+		}
+		return "esc", 1
+	case '\r', '\n':
+		return "enter", 1
+	case 0x7f, 0x08:
+		return "backspace", 1
+	case 0x03:
+		return "ctrl-c", 1
+	case 0x15:
+		return "ctrl-u", 1
+	}
+	r, size := utf8.DecodeRune(data)
+	if r == utf8.RuneError {
+		return "", size
+	}
+	return string(r), size
+}
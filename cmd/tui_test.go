@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadKeys(t *testing.T) {
+	keys := make(chan string)
+	go readKeys(strings.NewReader("a\x1b[A\x1b[B\x1b[5~é\r\x7f\x03\x1b"), keys)
+	var got []string
+	for key := range keys {
+		got = append(got, key)
+	}
+	want := []string{"a", "up", "down", "é", "enter", "backspace", "ctrl-c", "esc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+}
+
+func TestReadLogLines(t *testing.T) {
+	var got []string
+	input := "INFO starting\n\rDownloading: 42%\rDownloading: 84%\nffmpeg:\tdone\x1b[0m\n\nlast"
+	readLogLines(strings.NewReader(input), func(line string) { got = append(got, line) })
+	// Progress lines start with \r and are dropped.
+	want := []string{"INFO starting", "ffmpeg: done[0m", "last"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestFitWidth(t *testing.T) {
+	if got := fitWidth("\x1b[1m番剧abc\x1b[0m", 5); got != "\x1b[1m番剧a\x1b[0m" {
+		t.Errorf("fitWidth = %q", got)
+	}
+	if got := fitWidth("short", 10); got != "short" {
+		t.Errorf("fitWidth = %q", got)
+	}
+	if got := padRight("\x1b[7m番\x1b[0m", 4); got != "\x1b[7m番\x1b[0m  " {
+		t.Errorf("padRight = %q", got)
+	}
+}
+
+func TestTUIHandleKey(t *testing.T) {
+	u := &tui{state: tuiEnterURL}
+	for _, key := range []string{"B", "V", "x", "backspace", "1"} {
+		u.handleKey(key)
+	}
+	if string(u.input) != "BV1" {
+		t.Errorf("input = %q, want BV1", string(u.input))
+	}
+
+	u = &tui{state: tuiSelect, parts: []*tuiPart{{selected: true}, {selected: true}, {selected: true}}}
+	u.handleKey("down")
+	u.handleKey(" ")
+	if u.cursor != 1 || u.parts[1].selected {
+		t.Errorf("space did not untick the part under the cursor")
+	}
+	u.handleKey("a")
+	for i, p := range u.parts {
+		if !p.selected {
+			t.Errorf("a with some unticked: part %d not ticked", i)
+		}
+	}
+	u.handleKey("a")
+	for i, p := range u.parts {
+		if p.selected {
+			t.Errorf("a with all ticked: part %d still ticked", i)
+		}
+	}
+	u.handleKey("left")
+	if tuiQualities[u.quality] != "360p" {
+		t.Errorf("left from best = %s, want 360p", tuiQualities[u.quality])
+	}
+	u.handleKey("esc")
+	if u.state != tuiEnterURL {
+		t.Errorf("esc on the part list: state = %d", u.state)
+	}
+	u.handleKey("ctrl-c")
+	if !u.quit {
+		t.Error("ctrl-c did not quit")
+	}
+}
@@ -0,0 +1,344 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [DIR|FILE]",
+	Short: "Re-check downloads against their recorded checksums",
+	Long: `Hash every file listed in a sha256sums.txt written by
+download --write-checksums and report files that changed or disappeared.
+The argument is the checksum file or the directory holding it (default:
+the output directory).
+
+The file uses the sha256sum format, so "sha256sum -c sha256sums.txt" in the
+same directory checks it as well.
+
+With --probe, every video and audio file under the directory (or the single
+file given) is opened with ffprobe instead, which finds downloads that were
+cut off or lost a track: files ffprobe cannot read, videos without an audio
+track, tracks of different lengths, and files shorter or longer than the
+video on Bilibili. The length and whether the video had sound are taken from
+the download history, so only files downloaded by goBili are compared with
+Bilibili. ffprobe comes with FFmpeg; with --ffmpeg-path set, the ffprobe
+next to it is used.
+
+Broken files found in the history can be downloaded again: goBili asks
+first, or does so right away with --redownload. They are replaced using the
+download settings of the config file, in the output directory (-o).
+
+Examples:
+  goBili verify ~/Videos
+  goBili verify --probe ~/Videos
+  goBili verify --probe --redownload -q ~/Videos`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runVerify,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().BoolP("quiet", "q", false, "only print files that failed")
+	verifyCmd.Flags().Bool("probe", false, "check the media files with ffprobe instead, for truncated files and missing tracks")
+	verifyCmd.Flags().Bool("redownload", false, "with --probe, download broken files again without asking")
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	probe, _ := cmd.Flags().GetBool("probe")
+	redownload, _ := cmd.Flags().GetBool("redownload")
+
+	list := viper.GetString("output")
+	if len(args) == 1 {
+		list = args[0]
+	}
+	if probe {
+		return runVerifyProbe(list, quiet, redownload)
+	}
+	if redownload {
+		return fmt.Errorf("--redownload needs --probe")
+	}
+	if info, err := os.Stat(list); err == nil && info.IsDir() {
+		list = filepath.Join(list, downloader.ChecksumsFile)
+	}
+
+	results, err := downloader.VerifyChecksums(list)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		switch r.Status {
+		case downloader.ChecksumOK:
+			if !quiet {
+				fmt.Printf("%s: %s\n", r.Name, styleOK.paint(r.Status))
+			}
+			continue
+		case downloader.ChecksumMissing:
+			fmt.Printf("%s: %s (%v)\n", r.Name, styleFail.paint(r.Status), r.Err)
+		default:
+			fmt.Printf("%s: %s\n", r.Name, styleFail.paint(r.Status))
+		}
+		failed++
+	}
+
+	fmt.Printf("\n%d files checked, %d OK, %d failed\n", len(results), len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, len(results))
+	}
+	return nil
+}
+
+// videoExtensions are the outputs of video downloads; with audioExtensions
+// they are the files verify --probe checks.
+var videoExtensions = map[string]bool{".mp4": true, ".mkv": true, ".flv": true, ".m4v": true, ".mov": true}
+
+// brokenFile is a file verify --probe found broken.
+type brokenFile struct {
+	path  string
+	entry *store.HistoryEntry // the download that wrote it; nil if unknown
+}
+
+func runVerifyProbe(target string, quiet, redownload bool) error {
+	ffprobe := downloader.FFprobeBin(viper.GetString("ffmpeg_path"))
+	if _, err := exec.LookPath(ffprobe); err != nil {
+		return fmt.Errorf("ffprobe not found, install FFmpeg: %w", err)
+	}
+	files, err := mediaFiles(target)
+	if err != nil {
+		return err
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	history, err := st.ListHistory(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	// History is oldest first: the latest download of a path wins.
+	downloads := make(map[string]*store.HistoryEntry, len(history))
+	for _, e := range history {
+		if abs, err := filepath.Abs(e.Path); err == nil && e.Path != "" {
+			downloads[abs] = e
+		}
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	var broken []*brokenFile
+	for _, path := range files {
+		abs, _ := filepath.Abs(path)
+		entry := downloads[abs]
+		problems, err := probeDownload(ctx, ffprobe, path, entry)
+		if ctx.Err() != nil {
+			return errInterrupted
+		}
+		if err != nil {
+			problems = []string{err.Error()}
+		}
+		if len(problems) == 0 {
+			if !quiet {
+				fmt.Printf("%s: %s\n", path, styleOK.paint("OK"))
+			}
+			continue
+		}
+		fmt.Printf("%s: %s (%s)\n", path, styleFail.paint("BROKEN"), strings.Join(problems, "; "))
+		broken = append(broken, &brokenFile{path: path, entry: entry})
+	}
+
+	fmt.Printf("\n%d files checked, %d OK, %d broken\n", len(files), len(files)-len(broken), len(broken))
+	if len(broken) == 0 {
+		return nil
+	}
+	verifyErr := fmt.Errorf("%d of %d files failed verification", len(broken), len(files))
+
+	var again []*brokenFile
+	for _, b := range broken {
+		if b.entry != nil && b.entry.BVID != "" {
+			again = append(again, b)
+		}
+	}
+	if n := len(broken) - len(again); n > 0 {
+		fmt.Printf("%d broken files are not in the download history and cannot be downloaded again.\n", n)
+	}
+	if len(again) == 0 {
+		return verifyErr
+	}
+	if !redownload {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Printf("Run with --redownload to download the %d in the history again.\n", len(again))
+			return verifyErr
+		}
+		fmt.Printf("Download %d broken files again? (y/N): ", len(again))
+		var input string
+		fmt.Scanln(&input)
+		if input != "y" && input != "Y" && input != "yes" && input != "Yes" {
+			return verifyErr
+		}
+	}
+
+	failed, err := redownloadBroken(ctx, st, again)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return withExitCode(ExitPartialFailure, fmt.Errorf("%d of %d broken files could not be downloaded again", failed, len(again)))
+	}
+	if len(again) < len(broken) {
+		return verifyErr
+	}
+	return nil
+}
+
+// mediaFiles returns target when it is a file, or the video and audio files
+// under the directory target, skipping hidden directories such as the
+// working directory of unfinished downloads.
+func mediaFiles(target string) ([]string, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{target}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != target && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if d.Type().IsRegular() && (videoExtensions[ext] || audioExtensions[ext]) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", target, err)
+	}
+	return files, nil
+}
+
+// probeDownload returns what is wrong with the file at path, written by the
+// download entry when it is not nil. Audio files only need an audio track;
+// videos need both, unless they were saved without sound.
+func probeDownload(ctx context.Context, ffprobe, path string, entry *store.HistoryEntry) ([]string, error) {
+	probe, err := downloader.ProbeMedia(ctx, ffprobe, path)
+	if err != nil {
+		return nil, err
+	}
+	wantVideo := !audioExtensions[strings.ToLower(filepath.Ext(path))]
+	wantAudio := true
+	var length time.Duration
+	if entry != nil {
+		wantAudio = !wantVideo || !entry.NoAudio
+		length = time.Duration(entry.Length) * time.Second
+	}
+	return probe.Problems(length, wantVideo, wantAudio), nil
+}
+
+// redownloadBroken downloads the files of broken again, replacing them, and
+// drops their old history entries. It returns how many could not be
+// downloaded.
+func redownloadBroken(ctx context.Context, st store.Store, broken []*brokenFile) (int, error) {
+	// The broken files are still there and in the history: overwrite them
+	// instead of skipping or renaming the new downloads.
+	viper.Set("existing", string(downloader.ExistingOverwrite))
+	viper.Set("no_dedup", true)
+	// The download command's flags are at their defaults here, so the
+	// config file decides.
+	s, err := newDownloadSession(downloadCmd, sessionOverrides{})
+	if err != nil {
+		return 0, err
+	}
+	if s.toStdout {
+		return 0, fmt.Errorf("cannot download again to stdout; set the output directory with -o")
+	}
+
+	failed := 0
+	for _, b := range broken {
+		path, err := redownloadFile(ctx, s, st, b.entry)
+		if errors.Is(err, errInterrupted) {
+			return failed, err
+		}
+		if err != nil {
+			fmt.Printf("%s: %s (%v)\n", b.path, styleFail.paint("FAILED"), err)
+			failed++
+			continue
+		}
+		// A changed file name template puts the new download elsewhere.
+		oldPath, _ := filepath.Abs(b.path)
+		if newPath, _ := filepath.Abs(path); newPath != oldPath {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				s.logger.Warnf("Failed to remove %s: %v", b.path, err)
+			}
+		}
+		if err := st.DeleteHistory(ctx, b.entry.ID); err != nil && !errors.Is(err, store.ErrNotFound) {
+			s.logger.Warnf("Failed to update download history: %v", err)
+		}
+		fmt.Printf("%s: %s %s\n", b.path, styleOK.paint("replaced by"), path)
+	}
+	return failed, nil
+}
+
+// redownloadFile downloads the part of the history entry e again and
+// returns the path of the new file.
+func redownloadFile(ctx context.Context, s *downloadSession, st store.Store, e *store.HistoryEntry) (string, error) {
+	videoInfo, err := s.parser.ParseURL("https://www.bilibili.com/video/" + e.BVID)
+	if err != nil {
+		return "", parseURLError(err)
+	}
+	s.pages = "all"
+	if len(videoInfo.Pages) > 1 {
+		s.pages = ""
+		for i, page := range videoInfo.Pages {
+			if page.CID == e.CID {
+				s.pages = strconv.Itoa(i + 1)
+			}
+		}
+		if s.pages == "" {
+			return "", fmt.Errorf("part %d is no longer in %s", e.CID, e.BVID)
+		}
+	}
+
+	report := newRunReport()
+	if err := downloadContent(ctx, s, st, report, videoInfo); err != nil {
+		return "", err
+	}
+	for _, r := range report.Entries {
+		switch r.Status {
+		case outcomeSucceeded:
+			return r.Path, nil
+		case outcomeFailed:
+			return "", errors.New(r.Error)
+		}
+	}
+	return "", fmt.Errorf("nothing was downloaded")
+}
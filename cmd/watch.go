@@ -0,0 +1,408 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// uploadsPageSize is the number of videos fetched per page of a space.
+const uploadsPageSize = 30
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Download the new videos and episodes of subscriptions",
+	Long: `Check the uploaders and bangumi seasons added with 'goBili subscribe add'
+and download their new videos that pass the subscription's filters and
+their newly released episodes, then check again every --interval until
+stopped. Downloads use the download flags and config given here, and are
+recorded in the download history like any other, so a video is never
+fetched twice. Season episodes are named "Title S01E03 - episode title".
+
+Each subscription remembers the newest video or episode handled; one that
+fails is tried again on the next check, together with those after it.
+
+--on-new (config key on_new) runs a shell command for each new video or
+episode before it is downloaded, e.g. to send a notification. The command
+gets GOBILI_SUBSCRIPTION, GOBILI_TITLE, GOBILI_URL and GOBILI_BVID in its
+environment, and GOBILI_EPISODE (e.g. S01E03) for season episodes.
+
+Ctrl+C or SIGTERM stops watching; an interrupted download is tried again
+on the next start.
+
+Examples:
+  goBili watch
+  goBili watch --interval 30m -o ~/Videos/subscriptions --output-dir-template "{{.Owner}}"
+  goBili watch --on-new 'notify-send "goBili" "$GOBILI_TITLE"'
+  goBili watch --once`,
+	Args: cobra.NoArgs,
+	RunE: runWatch,
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+
+	// The download flags are added in download.go's init, once they exist.
+	watchCmd.Flags().Duration("interval", time.Hour, "time between checks")
+	watchCmd.Flags().Bool("once", false, "check once and exit instead of watching")
+	watchCmd.Flags().StringArray("on-new", nil, "run a shell command for each new video or episode, before downloading it (repeatable)")
+
+	if err := viper.BindPFlag("watch.interval", watchCmd.Flags().Lookup("interval")); err != nil {
+		cobra.CheckErr(err)
+	}
+}
+
+// watcher checks subscriptions and downloads what is new.
+type watcher struct {
+	cmd   *cobra.Command
+	s     *downloadSession
+	st    store.Store
+	onNew []string // commands run for each new video or episode
+}
+
+func runWatch(cmd *cobra.Command, _ []string) error {
+	once, _ := cmd.Flags().GetBool("once")
+	interval := viper.GetDuration("watch.interval")
+	if !once && interval <= 0 {
+		return fmt.Errorf("invalid interval %s (want a positive duration)", interval)
+	}
+	onNew, err := commandsFromConfig("on_new")
+	if err != nil {
+		return err
+	}
+	flagged, _ := cmd.Flags().GetStringArray("on-new")
+	onNew = append(onNew, flagged...)
+
+	st, err := openStore()
+	if err != nil {
+		return fmt.Errorf("failed to open the subscriptions: %w", err)
+	}
+	defer st.Close()
+
+	s, err := newDownloadSession(cmd, sessionOverrides{})
+	if err != nil {
+		return err
+	}
+	if s.toStdout {
+		return fmt.Errorf("watch cannot stream to stdout")
+	}
+	asJSON := jsonOutput(cmd)
+	if asJSON {
+		// Standard output carries the reports alone.
+		defer redirectStdout()()
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	w := &watcher{cmd: cmd, s: s, st: st, onNew: onNew}
+	for {
+		failedSubs, failedVideos, err := w.check(ctx, asJSON)
+		if err != nil {
+			return err
+		}
+		if once {
+			switch {
+			case failedSubs > 0:
+				return withExitCode(ExitPartialFailure, fmt.Errorf("%d subscriptions could not be checked", failedSubs))
+			case failedVideos > 0:
+				return withExitCode(ExitPartialFailure, fmt.Errorf("%d videos failed", failedVideos))
+			}
+			return nil
+		}
+
+		fmt.Printf("Next check at %s\n", time.Now().Add(interval).Format("2006-01-02 15:04"))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check checks every subscription once and downloads what is new. It
+// returns the number of subscriptions that could not be checked and of
+// videos that failed; an error of one subscription is logged and does not
+// stop the others.
+func (w *watcher) check(ctx context.Context, asJSON bool) (failedSubs, failedVideos int, err error) {
+	subs, err := w.st.ListSubscriptions(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+	if len(subs) == 0 {
+		return 0, 0, fmt.Errorf("no subscriptions; add one with 'goBili subscribe add <mid>'")
+	}
+
+	report := newRunReport()
+	for _, sub := range subs {
+		if sub.Options == nil {
+			sub.Options = make(map[string]string)
+		}
+		switch sub.Kind {
+		case subscriptionUploader:
+			err = w.checkUploader(ctx, report, sub)
+		case subscriptionSeason:
+			err = w.checkSeason(ctx, report, sub)
+		default:
+			continue
+		}
+		if errors.Is(err, errInterrupted) {
+			break
+		}
+		if err != nil {
+			failedSubs++
+			w.s.logger.Errorf("%s: %v", subscriptionName(sub), err)
+		}
+	}
+	if reportErr := finishReport(report, viper.GetString("write_report"), len(report.Entries) > 0, asJSON); reportErr != nil {
+		w.s.logger.Warnf("%v", reportErr)
+	}
+	if errors.Is(err, errInterrupted) {
+		return failedSubs, report.Failed, err
+	}
+	return failedSubs, report.Failed, nil
+}
+
+// session returns the download session of a subscription: watch's own, or
+// one with the subscription's quality.
+func (w *watcher) session(sub *store.Subscription) (*downloadSession, error) {
+	if quality := sub.Options["quality"]; quality != "" {
+		return newDownloadSession(w.cmd, sessionOverrides{quality: quality})
+	}
+	return w.s, nil
+}
+
+// save records a check of sub; the context may be canceled, so it is
+// saved regardless.
+func (w *watcher) save(sub *store.Subscription) error {
+	sub.LastChecked = time.Now()
+	if err := w.st.PutSubscription(context.Background(), sub); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
+
+// notify runs the --on-new commands for a new video or episode. Failing
+// commands are only logged.
+func (w *watcher) notify(ctx context.Context, sub *store.Subscription, title, url, bvid, episode string) {
+	env := []string{
+		"GOBILI_SUBSCRIPTION=" + subscriptionName(sub),
+		"GOBILI_TITLE=" + title,
+		"GOBILI_URL=" + url,
+		"GOBILI_BVID=" + bvid,
+	}
+	if episode != "" {
+		env = append(env, "GOBILI_EPISODE="+episode)
+	}
+	for _, command := range w.onNew {
+		if err := downloader.RunShell(ctx, command, env, os.Stdout, os.Stderr); err != nil {
+			w.s.logger.Warnf("New video command failed: %v", err)
+		}
+	}
+}
+
+// checkUploader downloads the videos an uploader published since the last
+// check, oldest first, and records the check in the subscription.
+func (w *watcher) checkUploader(ctx context.Context, report *runReport, sub *store.Subscription) error {
+	mid, err := strconv.ParseInt(sub.Target, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uploader %q", sub.Target)
+	}
+	filter, err := newUploadFilter(sub.Options)
+	if err != nil {
+		return err
+	}
+	since, _ := strconv.ParseInt(sub.Options["since"], 10, 64)
+
+	uploads, err := newUploads(w.s.parser, mid, since)
+	if err != nil {
+		return fmt.Errorf("failed to list videos: %w", err)
+	}
+	if len(uploads) == 0 {
+		return w.save(sub)
+	}
+	if uploads[0].Author != "" {
+		sub.Name = uploads[0].Author
+	}
+	fmt.Printf("\n==> %s: %d new videos\n", subscriptionName(sub), len(uploads))
+	s, err := w.session(sub)
+	if err != nil {
+		return err
+	}
+
+	// The newest video handled moves forward until a video fails, so the
+	// failed one is listed again next time; history keeps the ones after
+	// it from being downloaded twice.
+	blocked := false
+	for _, v := range uploads {
+		if filter.accepts(v) {
+			url := "https://www.bilibili.com/video/" + v.BVID
+			w.notify(ctx, sub, v.Title, url, v.BVID, "")
+			failed := report.Failed
+			err = downloadUpload(ctx, s, w.st, report, url, v.Title, v.BVID)
+			if errors.Is(err, errInterrupted) {
+				break
+			}
+			if err != nil || report.Failed > failed {
+				blocked = true
+			}
+		} else {
+			s.logger.Infof("Skipping %s (%s): filtered out", v.Title, v.BVID)
+		}
+		if !blocked {
+			since = v.Created
+		}
+	}
+
+	sub.Options["since"] = strconv.FormatInt(since, 10)
+	if saveErr := w.save(sub); saveErr != nil {
+		return saveErr
+	}
+	if errors.Is(err, errInterrupted) {
+		return err
+	}
+	return nil
+}
+
+// checkSeason downloads the episodes of a season released since the last
+// check, named "Title S01E03 - episode title", and records the check in
+// the subscription.
+func (w *watcher) checkSeason(ctx context.Context, report *runReport, sub *store.Subscription) error {
+	info, err := w.s.parser.ParseURL(seasonURL(sub.Target))
+	if err != nil {
+		return fmt.Errorf("failed to get the episodes: %w", err)
+	}
+	seen, _ := strconv.Atoi(sub.Options["episodes"])
+	if seen > len(info.Episodes) {
+		// Episodes were removed; follow the ones there are now.
+		seen = len(info.Episodes)
+	}
+	sub.Name = info.Title
+
+	// Episodes announced but not yet out are left for a later check.
+	now := time.Now().Unix()
+	var episodes []*parser.EpisodeInfo
+	for i, ep := range info.Episodes[seen:] {
+		if ep.PubTime > now {
+			break
+		}
+		named := *ep
+		named.Title = seasonEpisodeTitle(info.Title, info.Season, seen+i+1, ep.LongTitle)
+		episodes = append(episodes, &named)
+	}
+	if len(episodes) == 0 {
+		return w.save(sub)
+	}
+	fmt.Printf("\n==> %s: %d new episodes\n", subscriptionName(sub), len(episodes))
+	s, err := w.session(sub)
+	if err != nil {
+		return err
+	}
+	for i, ep := range episodes {
+		w.notify(ctx, sub, ep.Title, episodeURL(ep), ep.BVID, seasonEpisodeCode(info.Season, seen+i+1))
+	}
+
+	season := *info
+	season.Episodes = episodes
+	first := len(report.Entries)
+	err = downloadPlaylist(ctx, s.parser, s.dl, w.st, report, s.logger, nil, &season, "all")
+
+	// Count the episodes handled up to the first one that failed or was
+	// not reached, so it is tried again next time.
+	done := make(map[int64]bool)
+	for _, entry := range report.Entries[first:] {
+		if entry.Status != outcomeFailed {
+			done[entry.CID] = true
+		}
+	}
+	for _, ep := range episodes {
+		if !done[ep.CID] {
+			break
+		}
+		seen++
+	}
+
+	sub.Options["episodes"] = strconv.Itoa(seen)
+	if saveErr := w.save(sub); saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// seasonEpisodeCode returns the code of an episode, e.g. "S01E03".
+func seasonEpisodeCode(season, episode int) string {
+	if season < 1 {
+		season = 1
+	}
+	return fmt.Sprintf("S%02dE%02d", season, episode)
+}
+
+// seasonEpisodeTitle names an episode of a season after the season, e.g.
+// "Title S01E03 - episode title".
+func seasonEpisodeTitle(series string, season, episode int, longTitle string) string {
+	title := series + " " + seasonEpisodeCode(season, episode)
+	if longTitle != "" {
+		title += " - " + longTitle
+	}
+	return title
+}
+
+// episodeURL returns the page of a bangumi episode, or of its video when
+// the episode ID is not known.
+func episodeURL(ep *parser.EpisodeInfo) string {
+	if ep.EpID != 0 {
+		return fmt.Sprintf("https://www.bilibili.com/bangumi/play/ep%d", ep.EpID)
+	}
+	return "https://www.bilibili.com/video/" + ep.BVID
+}
+
+// downloadUpload downloads the video at url, with the title and bvid of
+// the listing for the report should it fail to parse.
+func downloadUpload(ctx context.Context, s *downloadSession, st store.Store, report *runReport, url, title, bvid string) error {
+	videoInfo, err := s.parser.ParseURL(url)
+	if err != nil {
+		err = parseURLError(err)
+		report.addFailure(title, bvid, 0, err)
+		s.logger.Errorf("%s: %v", bvid, err)
+		return err
+	}
+	return downloadContent(ctx, s, st, report, videoInfo)
+}
+
+// newUploads returns the videos of the uploader mid published after since
+// (Unix seconds), oldest first.
+func newUploads(p *parser.BilibiliParser, mid, since int64) ([]*parser.UploaderVideo, error) {
+	var found []*parser.UploaderVideo
+	for pn := 1; ; pn++ {
+		videos, total, err := p.GetUploaderVideos(mid, pn, uploadsPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range videos {
+			if v.Created > since {
+				found = append(found, v)
+			}
+		}
+		// Pages are newest first: a page reaching back to since is the last
+		// one needed.
+		if len(videos) < uploadsPageSize || pn*uploadsPageSize >= total || videos[len(videos)-1].Created <= since {
+			break
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Created < found[j].Created
+	})
+	return found, nil
+}
@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Downloaders that fetch the media streams.
+const (
+	DownloaderNative = "native" // goBili's own chunked HTTP client
+	DownloaderAria2c = "aria2c" // an external aria2c process
+)
+
+// ValidateDownloader checks a --downloader value.
+func ValidateDownloader(name string) error {
+	switch name {
+	case "", DownloaderNative, DownloaderAria2c:
+		return nil
+	}
+	return fmt.Errorf("unsupported downloader %q (want %s or %s)", name, DownloaderNative, DownloaderAria2c)
+}
+
+// aria2cBin returns the aria2c executable to run.
+func (d *Downloader) aria2cBin() string {
+	if d.config.Aria2cPath != "" {
+		return d.config.Aria2cPath
+	}
+	return "aria2c"
+}
+
+// useAria2c reports whether streams are handed to aria2c. A missing aria2c
+// falls back to the native downloader with a warning.
+func (d *Downloader) useAria2c() bool {
+	if d.config.Downloader != DownloaderAria2c {
+		return false
+	}
+	if _, err := exec.LookPath(d.aria2cBin()); err != nil {
+		d.aria2cWarning.Do(func() {
+			d.logger.Warnf("aria2c not found (%v), using the built-in downloader", err)
+		})
+		return false
+	}
+	return true
+}
+
+// downloadWithAria2c downloads url to outputPath with aria2c, using
+// config.Threads connections. The request headers (Referer, Cookie,
+// User-Agent) go through an input file readable only by the current user,
+// so the cookie never shows up in the process list.
+func (d *Downloader) downloadWithAria2c(ctx context.Context, url, outputPath string) error {
+	headers, err := d.mediaHeaders(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	input, err := os.CreateTemp(filepath.Dir(outputPath), ".aria2-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to write aria2c input file: %w", err)
+	}
+	defer os.Remove(input.Name())
+	if _, err := input.WriteString(aria2cInput(url, filepath.Base(outputPath), headers)); err != nil {
+		input.Close()
+		return fmt.Errorf("failed to write aria2c input file: %w", err)
+	}
+	if err := input.Close(); err != nil {
+		return fmt.Errorf("failed to write aria2c input file: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, d.aria2cBin(), aria2cArgs(input.Name(), filepath.Dir(outputPath), d.config.Threads)...)
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running aria2c command: %s", strings.Join(cmd.Args, " "))
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("aria2c failed: %w", err)
+	}
+	d.logger.Infof("Successfully downloaded: %s", outputPath)
+	return nil
+}
+
+// mediaHeaders returns the headers goBili would send for url, as
+// "Name: value" lines for external downloaders.
+func (d *Downloader) mediaHeaders(ctx context.Context, url string) ([]string, error) {
+	req, err := d.newMediaRequest(ctx, "GET", url)
+	if err != nil {
+		return nil, err
+	}
+	var headers []string
+	for name, values := range req.Header {
+		for _, value := range values {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	sort.Strings(headers)
+	return headers, nil
+}
+
+// aria2cInput returns an aria2c input file entry for one download.
+func aria2cInput(url, name string, headers []string) string {
+	var b strings.Builder
+	b.WriteString(url + "\n")
+	b.WriteString("  out=" + name + "\n")
+	for _, header := range headers {
+		b.WriteString("  header=" + header + "\n")
+	}
+	return b.String()
+}
+
+// aria2cArgs returns the aria2c arguments for downloading the entries of
+// inputFile into dir with the given number of connections.
+func aria2cArgs(inputFile, dir string, threads int) []string {
+	if threads < 1 {
+		threads = 1
+	}
+	if threads > 16 {
+		threads = 16 // aria2c's limit per server
+	}
+	n := strconv.Itoa(threads)
+	return []string{
+		"--input-file=" + inputFile,
+		"--dir=" + dir,
+		"--split=" + n,
+		"--max-connection-per-server=" + n,
+		"--min-split-size=1M",
+		"--continue=true",
+		"--allow-overwrite=true",
+		"--auto-file-renaming=false",
+		"--file-allocation=none",
+		"--console-log-level=warn",
+		"--summary-interval=0",
+		"--download-result=hide",
+	}
+}
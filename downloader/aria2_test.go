@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateDownloader(t *testing.T) {
+	for _, name := range []string{"", "native", "aria2c"} {
+		if err := ValidateDownloader(name); err != nil {
+			t.Errorf("ValidateDownloader(%q): %v", name, err)
+		}
+	}
+	if err := ValidateDownloader("wget"); err == nil {
+		t.Error("ValidateDownloader(wget) should fail")
+	}
+}
+
+func TestAria2cArgs(t *testing.T) {
+	args := strings.Join(aria2cArgs("/tmp/in.txt", "/out", 32), " ")
+	for _, want := range []string{"--input-file=/tmp/in.txt", "--dir=/out", "--split=16", "--max-connection-per-server=16"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("aria2c args %q missing %q", args, want)
+		}
+	}
+}
+
+func TestDownloadWithAria2c(t *testing.T) {
+	dir := t.TempDir()
+	seen := filepath.Join(dir, "input-copy")
+	// The fake aria2c keeps a copy of its input file and writes the
+	// requested output into --dir.
+	aria2c := writeScript(t, dir, "aria2c", `for arg; do
+  case "$arg" in
+    --input-file=*) in="${arg#--input-file=}" ;;
+    --dir=*) out_dir="${arg#--dir=}" ;;
+  esac
+done
+cp "$in" "`+seen+`"
+name=$(sed -n 's/^  out=//p' "$in")
+echo data > "$out_dir/$name"
+`)
+	d := &Downloader{
+		config: Config{Downloader: DownloaderAria2c, Aria2cPath: aria2c, Threads: 4},
+		logger: logrus.New(),
+	}
+
+	out := filepath.Join(dir, "v_video.mp4")
+	if err := d.downloadFile(context.Background(), "https://upos.example/v.m4s", out); err != nil {
+		t.Fatalf("downloadFile: %v", err)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "data\n" {
+		t.Errorf("output = %q (%v)", data, err)
+	}
+	input, err := os.ReadFile(seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(input), "https://upos.example/v.m4s\n  out=v_video.mp4\n") {
+		t.Errorf("aria2c input = %q", input)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".aria2-*")); len(matches) != 0 {
+		t.Errorf("input file not removed: %v", matches)
+	}
+}
+
+func TestUseAria2c_MissingFallsBack(t *testing.T) {
+	d := &Downloader{
+		config: Config{Downloader: DownloaderAria2c, Aria2cPath: filepath.Join(t.TempDir(), "missing")},
+		logger: logrus.New(),
+	}
+	if d.useAria2c() {
+		t.Error("useAria2c() = true without an aria2c executable")
+	}
+}
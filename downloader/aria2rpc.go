@@ -0,0 +1,235 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// Aria2RPC is a client for the JSON-RPC interface of a (remote) aria2
+// instance, e.g. one running on a NAS.
+type Aria2RPC struct {
+	url    string
+	token  string
+	client *http.Client
+	nextID atomic.Int64
+}
+
+// NewAria2RPC returns a client for the aria2 RPC endpoint rpcURL, e.g.
+// "http://nas:6800/jsonrpc". token is aria2's --rpc-secret, if any.
+func NewAria2RPC(rpcURL, token string) *Aria2RPC {
+	return &Aria2RPC{
+		url:    rpcURL,
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Aria2Status is the state of one aria2 download.
+type Aria2Status struct {
+	GID             string `json:"gid"`
+	Status          string `json:"status"` // active, waiting, paused, error, complete or removed
+	TotalLength     string `json:"totalLength"`
+	CompletedLength string `json:"completedLength"`
+	ErrorMessage    string `json:"errorMessage"`
+}
+
+// call invokes method with params and decodes the result into result.
+func (c *Aria2RPC) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	if c.token != "" {
+		params = append([]interface{}{"token:" + c.token}, params...)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      strconv.FormatInt(c.nextID.Add(1), 10),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create aria2 request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("aria2 RPC %s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("aria2 RPC %s failed: %w", method, err)
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		return fmt.Errorf("aria2 RPC %s: invalid response (HTTP %d): %w", method, resp.StatusCode, err)
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("aria2 RPC %s: %s (code %d)", method, rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+	if result != nil {
+		if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+			return fmt.Errorf("aria2 RPC %s: unexpected result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// AddURI queues a download of uri and returns its GID.
+func (c *Aria2RPC) AddURI(ctx context.Context, uri string, options map[string]interface{}) (string, error) {
+	var gid string
+	if err := c.call(ctx, "aria2.addUri", []interface{}{[]string{uri}, options}, &gid); err != nil {
+		return "", err
+	}
+	return gid, nil
+}
+
+// TellStatus returns the state of the download gid.
+func (c *Aria2RPC) TellStatus(ctx context.Context, gid string) (*Aria2Status, error) {
+	var status Aria2Status
+	keys := []string{"gid", "status", "totalLength", "completedLength", "errorMessage"}
+	if err := c.call(ctx, "aria2.tellStatus", []interface{}{gid, keys}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// aria2PollInterval is how often Wait asks aria2 for progress.
+var aria2PollInterval = 2 * time.Second
+
+// Wait polls until every download in gids is complete. A download that
+// fails or is removed on the aria2 side is an error.
+func (c *Aria2RPC) Wait(ctx context.Context, gids []string) error {
+	pending := append([]string(nil), gids...)
+	for {
+		var still []string
+		for _, gid := range pending {
+			status, err := c.TellStatus(ctx, gid)
+			if err != nil {
+				return err
+			}
+			switch status.Status {
+			case "complete":
+			case "error":
+				return fmt.Errorf("aria2 download %s failed: %s", gid, status.ErrorMessage)
+			case "removed":
+				return fmt.Errorf("aria2 download %s was removed", gid)
+			default:
+				still = append(still, gid)
+			}
+		}
+		if len(still) == 0 {
+			return nil
+		}
+		pending = still
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(aria2PollInterval):
+		}
+	}
+}
+
+// aria2Job is one file pushed to aria2.
+type aria2Job struct {
+	url  string
+	name string
+}
+
+// aria2Jobs returns the files to push for stream. Merging happens locally,
+// so DASH video and audio are queued as separate files next to each other.
+func (d *Downloader) aria2Jobs(stream *parser.StreamInfo, filename string) []aria2Job {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	switch {
+	case d.config.AudioOnly:
+		return []aria2Job{{stream.AudioURL, base + ".m4a"}}
+	case d.config.VideoOnly || stream.AudioURL == "":
+		return []aria2Job{{stream.VideoURL, filename}}
+	}
+	return []aria2Job{
+		{stream.VideoURL, base + ".video.mp4"},
+		{stream.AudioURL, base + ".audio.m4a"},
+	}
+}
+
+// dispatchToAria2 pushes the streams to the configured aria2 instance
+// instead of downloading them, and waits for them when Aria2Wait is set.
+func (d *Downloader) dispatchToAria2(ctx context.Context, stream *parser.StreamInfo, filename string) (*Result, error) {
+	if d.config.AudioOnly && stream.AudioURL == "" {
+		return nil, ErrNoAudio
+	}
+
+	if _, transcode := d.audioTranscodeCodec(); d.config.AudioOnly && transcode {
+		d.logger.Warn("Audio conversion is not available when dispatching to aria2; the m4a stream is queued as is")
+	}
+
+	result := &Result{Quality: stream.Quality, NoAudio: stream.AudioURL == "" && !stream.Muxed}
+	for _, job := range d.aria2Jobs(stream, filename) {
+		options, err := d.aria2Options(ctx, job)
+		if err != nil {
+			return nil, err
+		}
+		gid, err := d.config.Aria2RPC.AddURI(ctx, job.url, options)
+		if err != nil {
+			return nil, err
+		}
+		d.logger.Infof("Queued %s on aria2 (gid %s)", job.name, gid)
+		if result.Path == "" {
+			result.Path = job.name
+		}
+		result.Dispatched = append(result.Dispatched, gid)
+	}
+
+	if d.config.Aria2Wait {
+		d.logger.Info("Waiting for aria2 to finish...")
+		if err := d.config.Aria2RPC.Wait(ctx, result.Dispatched); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// aria2Options returns the aria2 options for one job, carrying the headers
+// Bilibili's CDN requires.
+func (d *Downloader) aria2Options(ctx context.Context, job aria2Job) (map[string]interface{}, error) {
+	headers, err := d.mediaHeaders(ctx, job.url)
+	if err != nil {
+		return nil, err
+	}
+
+	options := map[string]interface{}{"out": job.name}
+	if len(headers) > 0 {
+		options["header"] = headers
+	}
+	if d.config.Aria2Dir != "" {
+		options["dir"] = d.config.Aria2Dir
+	}
+	if d.config.Threads > 1 {
+		n := strconv.Itoa(min(d.config.Threads, 16))
+		options["split"] = n
+		options["max-connection-per-server"] = n
+	}
+	return options, nil
+}
@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeAria2 is an aria2 JSON-RPC server that completes downloads after a
+// number of status polls.
+type fakeAria2 struct {
+	mu      sync.Mutex
+	added   [][]interface{} // params of aria2.addUri calls
+	polls   map[string]int
+	failGID string
+}
+
+func (f *fakeAria2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string        `json:"id"`
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(req.Params) == 0 || req.Params[0] != "token:secret" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "error": map[string]interface{}{"code": 1, "message": "Unauthorized"}})
+		return
+	}
+	var result interface{}
+	switch req.Method {
+	case "aria2.addUri":
+		f.added = append(f.added, req.Params[1:])
+		result = fmt.Sprintf("gid%d", len(f.added))
+	case "aria2.tellStatus":
+		gid := req.Params[1].(string)
+		f.polls[gid]++
+		status := "active"
+		if gid == f.failGID {
+			status = "error"
+		} else if f.polls[gid] > 1 {
+			status = "complete"
+		}
+		result = map[string]string{"gid": gid, "status": status, "errorMessage": "disk full"}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result})
+}
+
+func TestDispatchToAria2(t *testing.T) {
+	old := aria2PollInterval
+	aria2PollInterval = time.Millisecond
+	defer func() { aria2PollInterval = old }()
+
+	fake := &fakeAria2{polls: map[string]int{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	d := &Downloader{
+		config: Config{
+			Aria2RPC:  NewAria2RPC(server.URL, "secret"),
+			Aria2Dir:  "/volume1/bili",
+			Aria2Wait: true,
+			Threads:   4,
+		},
+		logger: logrus.New(),
+	}
+	stream := &parser.StreamInfo{Quality: 80, VideoURL: "https://v/video.m4s", AudioURL: "https://v/audio.m4s"}
+
+	result, err := d.dispatchToAria2(context.Background(), stream, "Title [1080P].mp4")
+	if err != nil {
+		t.Fatalf("dispatchToAria2: %v", err)
+	}
+	if !reflect.DeepEqual(result.Dispatched, []string{"gid1", "gid2"}) {
+		t.Errorf("Dispatched = %v", result.Dispatched)
+	}
+	if result.Path != "Title [1080P].video.mp4" {
+		t.Errorf("Path = %q", result.Path)
+	}
+	if len(fake.added) != 2 {
+		t.Fatalf("addUri calls = %d, want 2", len(fake.added))
+	}
+	uris := fake.added[1][0].([]interface{})
+	options := fake.added[1][1].(map[string]interface{})
+	if uris[0] != "https://v/audio.m4s" || options["out"] != "Title [1080P].audio.m4a" ||
+		options["dir"] != "/volume1/bili" || options["split"] != "4" {
+		t.Errorf("second addUri = %v %v", uris, options)
+	}
+	if fake.polls["gid1"] < 2 || fake.polls["gid2"] < 2 {
+		t.Errorf("polls = %v, want each download polled until complete", fake.polls)
+	}
+}
+
+func TestAria2RPC_Errors(t *testing.T) {
+	fake := &fakeAria2{polls: map[string]int{}, failGID: "gid9"}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	if _, err := NewAria2RPC(server.URL, "wrong").AddURI(context.Background(), "https://v", nil); err == nil ||
+		!strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("AddURI with a wrong token: %v", err)
+	}
+	err := NewAria2RPC(server.URL, "secret").Wait(context.Background(), []string{"gid9"})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Wait on a failed download: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// audioCodec describes how ffmpeg encodes one --audio-format.
+type audioCodec struct {
+	ext            string // output file extension
+	encoder        string // ffmpeg audio encoder
+	defaultBitrate string // used when no --audio-quality is given; "" for lossless
+}
+
+// audioCodecs lists the supported transcoding targets. "m4a" keeps the
+// original AAC stream without re-encoding.
+var audioCodecs = map[string]audioCodec{
+	"mp3":  {ext: ".mp3", encoder: "libmp3lame", defaultBitrate: "192k"},
+	"flac": {ext: ".flac", encoder: "flac"},
+	"ogg":  {ext: ".ogg", encoder: "libvorbis", defaultBitrate: "192k"},
+	"opus": {ext: ".opus", encoder: "libopus", defaultBitrate: "128k"},
+}
+
+// ValidateAudioOptions checks an --audio-format / --audio-quality pair.
+// An empty format or "m4a" means no transcoding.
+func ValidateAudioOptions(format, quality string) error {
+	format = strings.ToLower(format)
+	if format == "" || format == "m4a" {
+		if quality != "" {
+			return fmt.Errorf("--audio-quality needs an --audio-format to transcode to")
+		}
+		return nil
+	}
+	codec, ok := audioCodecs[format]
+	if !ok {
+		return fmt.Errorf("unsupported audio format %q (want m4a, mp3, flac, ogg or opus)", format)
+	}
+	if quality == "" {
+		return nil
+	}
+	if codec.defaultBitrate == "" {
+		return fmt.Errorf("--audio-quality does not apply to lossless %s", format)
+	}
+	if _, err := parseBitrate(quality); err != nil {
+		return err
+	}
+	return nil
+}
+
+// parseBitrate accepts "320k", "320K" or "320" (kbit/s) and returns the
+// ffmpeg form "320k".
+func parseBitrate(s string) (string, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(s), "k"))
+	if err != nil || n < 8 || n > 512 {
+		return "", fmt.Errorf("invalid audio quality %q (want a bitrate such as 128k or 320k)", s)
+	}
+	return strconv.Itoa(n) + "k", nil
+}
+
+// audioTranscodeCodec returns the codec to transcode audio-only downloads
+// to, or false if the original m4a should be kept.
+func (d *Downloader) audioTranscodeCodec() (audioCodec, bool) {
+	codec, ok := audioCodecs[strings.ToLower(d.config.AudioFormat)]
+	return codec, ok
+}
+
+// audioExt returns the file extension of audio-only downloads.
+func (d *Downloader) audioExt() string {
+	if codec, ok := d.audioTranscodeCodec(); ok {
+		return codec.ext
+	}
+	return ".m4a"
+}
+
+// transcodeAudio converts the downloaded m4a at src into outputPath with ffmpeg.
+func (d *Downloader) transcodeAudio(ctx context.Context, codec audioCodec, src, outputPath string) error {
+	if !d.isFFmpegAvailable() {
+		return fmt.Errorf("converting audio to %s requires ffmpeg; install it or point --ffmpeg-path at it", strings.TrimPrefix(codec.ext, "."))
+	}
+
+	args := []string{"-i", src, "-vn", "-c:a", codec.encoder}
+	if codec.defaultBitrate != "" {
+		bitrate := codec.defaultBitrate
+		if d.config.AudioQuality != "" {
+			b, err := parseBitrate(d.config.AudioQuality)
+			if err != nil {
+				return err
+			}
+			bitrate = b
+		}
+		args = append(args, "-b:a", bitrate)
+	}
+	args = append(args, "-y", outputPath)
+
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), args...)
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+	if err := d.runLimited(ctx, cmd); err != nil {
+		return fmt.Errorf("ffmpeg failed to convert audio: %w", err)
+	}
+	return nil
+}
+
+// nominalAudioKbps maps Bilibili's DASH audio IDs to the bitrates the site
+// advertises; the bandwidth field of a track varies around them.
+var nominalAudioKbps = map[int]int{
+	30216: 64,
+	30232: 132,
+	30280: 192,
+}
+
+// audioTrackKbps returns the bitrate of track in kbit/s.
+func audioTrackKbps(track parser.AudioTrack) int {
+	if kbps, ok := nominalAudioKbps[track.ID]; ok {
+		return kbps
+	}
+	return track.Bandwidth / 1000
+}
+
+// ValidateAudioBitrate checks an --audio-bitrate value: "best" (or empty)
+// or a maximum such as "132k".
+func ValidateAudioBitrate(bitrate string) error {
+	_, err := maxAudioKbps(bitrate)
+	return err
+}
+
+// maxAudioKbps parses an --audio-bitrate value; 0 means the best track.
+func maxAudioKbps(bitrate string) (int, error) {
+	if bitrate == "" || strings.EqualFold(bitrate, "best") {
+		return 0, nil
+	}
+	rate, err := parseBitrate(bitrate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid audio bitrate %q (want best or a maximum such as 132k)", bitrate)
+	}
+	return strconv.Atoi(strings.TrimSuffix(rate, "k"))
+}
+
+// selectAudioTrack picks the audio track of an audio-only download: the
+// highest bitrate, or with AudioBitrate the highest not above it (the
+// lowest when all are above). Without track details, the stream's own
+// audio URL is used.
+func (d *Downloader) selectAudioTrack(stream *parser.StreamInfo) parser.AudioTrack {
+	limit, _ := maxAudioKbps(d.config.AudioBitrate)
+
+	var best, lowest *parser.AudioTrack
+	for i := range stream.AudioTracks {
+		track := &stream.AudioTracks[i]
+		if track.URL == "" {
+			continue
+		}
+		kbps := audioTrackKbps(*track)
+		if lowest == nil || kbps < audioTrackKbps(*lowest) {
+			lowest = track
+		}
+		if limit > 0 && kbps > limit {
+			continue
+		}
+		if best == nil || kbps > audioTrackKbps(*best) {
+			best = track
+		}
+	}
+	switch {
+	case best != nil:
+		return *best
+	case lowest != nil:
+		return *lowest
+	}
+	return parser.AudioTrack{URL: stream.AudioURL, Codecs: stream.AudioCodecs}
+}
+
+// audioOnlyStream returns stream with its audio replaced by the track
+// selectAudioTrack picks, for audio-only downloads. Other streams, and
+// those whose audio track was chosen with FormatID, are returned as they
+// are.
+func (d *Downloader) audioOnlyStream(stream *parser.StreamInfo) *parser.StreamInfo {
+	if _, audioID := splitFormatID(d.config.FormatID); audioID != "" {
+		return stream
+	}
+	if !d.config.AudioOnly || stream.AudioURL == "" {
+		return stream
+	}
+	track := d.selectAudioTrack(stream)
+	if kbps := audioTrackKbps(track); kbps > 0 {
+		d.logger.Infof("Selected audio: %d kbps (%s)", kbps, track.Codecs)
+	}
+	if track.URL == stream.AudioURL {
+		return stream
+	}
+	selected := *stream
+	selected.AudioURL = track.URL
+	selected.AudioCodecs = track.Codecs
+	return &selected
+}
@@ -0,0 +1,122 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateAudioOptions(t *testing.T) {
+	tests := []struct {
+		format, quality string
+		wantErr         bool
+	}{
+		{"", "", false},
+		{"m4a", "", false},
+		{"MP3", "320k", false},
+		{"opus", "96", false},
+		{"flac", "", false},
+		{"flac", "320k", true},
+		{"wav", "", true},
+		{"mp3", "loud", true},
+		{"", "192k", true},
+	}
+	for _, tt := range tests {
+		err := ValidateAudioOptions(tt.format, tt.quality)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateAudioOptions(%q, %q) error = %v, wantErr %v", tt.format, tt.quality, err, tt.wantErr)
+		}
+	}
+}
+
+func TestFinalOutputPath_AudioFormat(t *testing.T) {
+	d := &Downloader{config: Config{AudioOnly: true, AudioFormat: "flac"}}
+	if got := d.finalOutputPath("out/song.mp4"); got != "out/song.flac" {
+		t.Errorf("finalOutputPath = %q, want out/song.flac", got)
+	}
+	d.config.AudioFormat = ""
+	if got := d.finalOutputPath("out/song.mp4"); got != "out/song.m4a" {
+		t.Errorf("finalOutputPath = %q, want out/song.m4a", got)
+	}
+}
+
+func TestTranscodeAudio_Args(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ffmpeg := writeScript(t, dir, "ffmpeg", "echo \"$@\" > "+argsFile+"\nfor last; do :; done\necho out > \"$last\"\n")
+	src := filepath.Join(dir, "song_audio.m4a")
+	if err := os.WriteFile(src, []byte("aac"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{
+		config: Config{FFmpegPath: ffmpeg, AudioOnly: true, AudioFormat: "mp3", AudioQuality: "320"},
+		logger: logrus.New(),
+	}
+	codec, ok := d.audioTranscodeCodec()
+	if !ok {
+		t.Fatal("mp3 should require transcoding")
+	}
+
+	out := filepath.Join(dir, "song.mp3")
+	if err := d.transcodeAudio(context.Background(), codec, src, out); err != nil {
+		t.Fatalf("transcodeAudio: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-c:a libmp3lame", "-b:a 320k", out} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args %q missing %q", args, want)
+		}
+	}
+}
+
+func TestSelectAudioTrack(t *testing.T) {
+	// Bilibili lists the tracks in no particular order; 30216 often first.
+	stream := &parser.StreamInfo{
+		AudioURL: "https://cdn/30216.m4s",
+		AudioTracks: []parser.AudioTrack{
+			{ID: 30216, URL: "https://cdn/30216.m4s", Bandwidth: 67000},
+			{ID: 30280, URL: "https://cdn/30280.m4s", Bandwidth: 319000},
+			{ID: 30232, URL: "https://cdn/30232.m4s", Bandwidth: 132000},
+		},
+	}
+	for _, tt := range []struct {
+		bitrate  string
+		wantID   int
+		wantKbps int
+	}{
+		{"", 30280, 192},
+		{"best", 30280, 192},
+		{"132k", 30232, 132},
+		{"150", 30232, 132},
+		{"32k", 30216, 64}, // all above: the lowest
+	} {
+		d := &Downloader{config: Config{AudioOnly: true, AudioBitrate: tt.bitrate}, logger: logrus.New()}
+		track := d.selectAudioTrack(stream)
+		if track.ID != tt.wantID || audioTrackKbps(track) != tt.wantKbps {
+			t.Errorf("AudioBitrate %q: got track %d (%d kbps), want %d (%d kbps)", tt.bitrate, track.ID, audioTrackKbps(track), tt.wantID, tt.wantKbps)
+		}
+		if got := d.audioOnlyStream(stream).AudioURL; got != track.URL {
+			t.Errorf("AudioBitrate %q: audioOnlyStream AudioURL = %s, want %s", tt.bitrate, got, track.URL)
+		}
+	}
+
+	// Streams without track details keep their audio URL.
+	d := &Downloader{config: Config{AudioOnly: true}, logger: logrus.New()}
+	bare := &parser.StreamInfo{AudioURL: "https://cdn/a.m4s"}
+	if got := d.audioOnlyStream(bare); got != bare {
+		t.Errorf("audioOnlyStream changed a stream without tracks: %+v", got)
+	}
+
+	if err := ValidateAudioBitrate("fast"); err == nil {
+		t.Error("ValidateAudioBitrate(\"fast\") = nil, want error")
+	}
+}
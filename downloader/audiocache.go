@@ -0,0 +1,203 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AudioCache keeps the audio streams downloaded for the pages of one
+// video, so that pages sharing their audio, as slide-style multi-part
+// videos often do, download it once and merge the same file. A stream is
+// recognised by its URL path, which names the file on the CDN whatever
+// the mirror and signature, or by the ETag and size the CDN reports for
+// it. Close removes the kept files.
+type AudioCache struct {
+	mu      sync.Mutex
+	dir     string // created in the working directory on first use
+	userDir bool   // the working directory is the configured TempDir
+	files   map[string]string
+	hits    int
+}
+
+// NewAudioCache returns an empty cache.
+func NewAudioCache() *AudioCache {
+	return &AudioCache{files: map[string]string{}}
+}
+
+// audioCacheKey is the context key of the active AudioCache.
+type audioCacheKey struct{}
+
+// WithAudioCache returns a context whose downloads take their audio
+// streams from c and add the ones they download to it.
+func WithAudioCache(ctx context.Context, c *AudioCache) context.Context {
+	return context.WithValue(ctx, audioCacheKey{}, c)
+}
+
+// audioCacheFromContext returns the AudioCache of ctx, or nil.
+func audioCacheFromContext(ctx context.Context) *AudioCache {
+	c, _ := ctx.Value(audioCacheKey{}).(*AudioCache)
+	return c
+}
+
+// Hits returns how many downloads reused a kept audio stream.
+func (c *AudioCache) Hits() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits
+}
+
+// Close removes the kept audio streams.
+func (c *AudioCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = map[string]string{}
+	if c.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.dir)
+	if !c.userDir {
+		// Like cleanupWorkDir: fails while other downloads use it.
+		_ = os.Remove(filepath.Dir(c.dir))
+	}
+	c.dir = ""
+	return err
+}
+
+// restore links or copies the stream kept under one of keys to path. It
+// reports false when none is kept.
+func (c *AudioCache) restore(keys []string, path string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		kept, ok := c.files[key]
+		if !ok {
+			continue
+		}
+		if err := linkOrCopy(kept, path); err != nil {
+			return false, err
+		}
+		c.hits++
+		return true, nil
+	}
+	return false, nil
+}
+
+// store keeps a copy of the stream downloaded to path under keys. workDir
+// is the working directory of the download, userDir whether it is the
+// configured TempDir.
+func (c *AudioCache) store(keys []string, path, workDir string, userDir bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dir == "" {
+		dir, err := os.MkdirTemp(workDir, ".audio-cache-")
+		if err != nil {
+			return fmt.Errorf("failed to create audio cache: %w", err)
+		}
+		c.dir, c.userDir = dir, userDir
+	}
+	kept := filepath.Join(c.dir, strconv.Itoa(len(c.files))+filepath.Ext(path))
+	if err := linkOrCopy(path, kept); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		c.files[key] = kept
+	}
+	return nil
+}
+
+// linkOrCopy hard-links src to dst, or copies it when the filesystem
+// cannot link. An existing dst is replaced.
+func linkOrCopy(src, dst string) error {
+	os.Remove(dst)
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open cached audio: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create audio file: %w", err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy cached audio: %w", err)
+	}
+	return out.Close()
+}
+
+// audioPathKey returns the cache key of an audio URL: its path, without
+// the host of the mirror and the signed query.
+func audioPathKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+	return "path:" + u.Path
+}
+
+// audioETagKey asks the CDN for the ETag and size of an audio URL and
+// returns them as a cache key, or "" when it reports no strong ETag.
+func (d *Downloader) audioETagKey(ctx context.Context, rawURL string) string {
+	req, err := d.newMediaRequest(ctx, "HEAD", rawURL)
+	if err != nil {
+		return ""
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	resp.Body.Close()
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode >= 400 || etag == "" || strings.HasPrefix(etag, "W/") || resp.ContentLength <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("etag:%s/%d", etag, resp.ContentLength)
+}
+
+// fetchAudio downloads the audio stream at rawURL to path, or, when the
+// AudioCache of ctx already holds it, reuses that file.
+func (d *Downloader) fetchAudio(ctx context.Context, rawURL, path string) error {
+	c := audioCacheFromContext(ctx)
+	if c == nil {
+		return d.downloadFile(ctx, rawURL, path)
+	}
+
+	var keys []string
+	if key := audioPathKey(rawURL); key != "" {
+		keys = append(keys, key)
+	}
+	if ok, err := c.restore(keys, path); ok || err != nil {
+		if ok {
+			d.logger.Info("Reusing the audio of an earlier part")
+		}
+		return err
+	}
+	if key := d.audioETagKey(ctx, rawURL); key != "" {
+		keys = append(keys, key)
+		if ok, err := c.restore(keys[len(keys)-1:], path); ok || err != nil {
+			if ok {
+				d.logger.Info("Reusing the identical audio of an earlier part")
+			}
+			return err
+		}
+	}
+
+	if err := d.downloadFile(ctx, rawURL, path); err != nil {
+		return err
+	}
+	if err := c.store(keys, path, d.WorkDir(), d.config.TempDir != ""); err != nil {
+		d.logger.Warnf("Failed to keep the audio for later parts: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchAudio_Cache(t *testing.T) {
+	var gets atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"same"`
+		if r.URL.Path == "/other/3-30280.m4s" {
+			etag = `"other"`
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Length", "5")
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			w.Write([]byte("audio"))
+		}
+	}))
+	defer server.Close()
+
+	workDir := filepath.Join(t.TempDir(), defaultTempDirName)
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	d := NewDownloader(Config{OutputDir: filepath.Dir(workDir)})
+	cache := NewAudioCache()
+	ctx := WithAudioCache(context.Background(), cache)
+
+	fetch := func(path, name string, wantGets int64) {
+		t.Helper()
+		out := filepath.Join(workDir, name)
+		if err := d.fetchAudio(ctx, server.URL+path, out); err != nil {
+			t.Fatalf("fetchAudio(%s): %v", path, err)
+		}
+		if data, err := os.ReadFile(out); err != nil || string(data) != "audio" {
+			t.Errorf("%s = %q, %v", name, data, err)
+		}
+		if n := gets.Load(); n != wantGets {
+			t.Errorf("after %s: %d downloads, want %d", path, n, wantGets)
+		}
+	}
+	fetch("/a/1-30280.m4s?deadline=1", "p1_audio.m4a", 1)
+	fetch("/a/1-30280.m4s?deadline=2", "p2_audio.m4a", 1) // same file, new signature
+	fetch("/b/2-30280.m4s", "p3_audio.m4a", 1)            // same ETag and size
+	fetch("/other/3-30280.m4s", "p4_audio.m4a", 2)
+	if cache.Hits() != 2 {
+		t.Errorf("Hits = %d, want 2", cache.Hits())
+	}
+
+	// The merge removes its copy; the kept one stays for later parts.
+	os.Remove(filepath.Join(workDir, "p1_audio.m4a"))
+	fetch("/a/1-30280.m4s", "p5_audio.m4a", 2)
+
+	for _, name := range []string{"p2_audio.m4a", "p3_audio.m4a", "p4_audio.m4a", "p5_audio.m4a"} {
+		os.Remove(filepath.Join(workDir, name))
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(workDir); !os.IsNotExist(err) {
+		t.Errorf("working directory left behind: %v", err)
+	}
+}
+
+func TestFetchAudio_NoCache(t *testing.T) {
+	var gets atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gets.Add(1)
+		w.Write([]byte("audio"))
+	}))
+	defer server.Close()
+
+	d := NewDownloader(Config{OutputDir: t.TempDir()})
+	for i := 0; i < 2; i++ {
+		if err := d.fetchAudio(context.Background(), server.URL+"/a.m4s", filepath.Join(t.TempDir(), "a.m4a")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gets.Load() != 2 {
+		t.Errorf("%d requests without a cache, want 2", gets.Load())
+	}
+}
@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseBufferSize(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{"", DefaultBufferSize, false},
+		{"65536", 65536, false},
+		{"64k", 64 * 1024, false},
+		{"1M", 1024 * 1024, false},
+		{" 2m ", 2 * 1024 * 1024, false},
+		{"1k", 0, true},   // below 4k
+		{"128M", 0, true}, // above 64M
+		{"fast", 0, true},
+		{"-8k", 0, true},
+	} {
+		got, err := ParseBufferSize(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBufferSize(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// writeRecorder records the largest single Write.
+type writeRecorder struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestCopyUsesPoolBuffer(t *testing.T) {
+	d := &Downloader{config: Config{Buffers: NewBufferPool(128 * 1024)}, logger: logrus.New()}
+	content := bytes.Repeat([]byte("x"), 1<<20)
+
+	var w writeRecorder
+	n, err := d.copy(&w, bytes.NewReader(content))
+	if err != nil || n != int64(len(content)) {
+		t.Fatalf("copy = %d, %v; want %d bytes", n, err, len(content))
+	}
+	// bytes.Reader's WriteTo would write everything at once; the copy must
+	// go through the 128 KiB buffer instead.
+	if w.max != 128*1024 {
+		t.Errorf("largest write = %d, want %d", w.max, 128*1024)
+	}
+	if !bytes.Equal(w.Bytes(), content) {
+		t.Error("copied content differs")
+	}
+}
@@ -0,0 +1,49 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// ffmetadataEscaper escapes the characters FFMETADATA treats specially.
+var ffmetadataEscaper = strings.NewReplacer(
+	`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n",
+)
+
+// supportsChapters reports whether outputs with extension ext can carry
+// chapter markers.
+func supportsChapters(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".mp4", ".m4a", ".m4v", ".mov", ".mkv", ".mp3":
+		return true
+	default:
+		return false
+	}
+}
+
+// chaptersMetadata renders chapters in ffmpeg's FFMETADATA format.
+func chaptersMetadata(chapters []parser.Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for _, c := range chapters {
+		if c.End <= c.Start {
+			continue
+		}
+		fmt.Fprintf(&b, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(c.Start)*1000, int64(c.End)*1000, ffmetadataEscaper.Replace(c.Title))
+	}
+	return b.String()
+}
+
+// writeChaptersFile writes an FFMETADATA chapters file next to path.
+func writeChaptersFile(path string, chapters []parser.Chapter) (string, error) {
+	chaptersPath := filepath.Join(filepath.Dir(path), ".chapters."+filepath.Base(path)+".txt")
+	if err := os.WriteFile(chaptersPath, []byte(chaptersMetadata(chapters)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write chapters file: %w", err)
+	}
+	return chaptersPath, nil
+}
@@ -0,0 +1,57 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestChaptersMetadata(t *testing.T) {
+	got := chaptersMetadata([]parser.Chapter{
+		{Title: "Intro", Start: 0, End: 30},
+		{Title: "a=b; #1", Start: 30, End: 90},
+		{Title: "empty", Start: 90, End: 90}, // zero length; dropped
+	})
+
+	want := ";FFMETADATA1\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=30000\ntitle=Intro\n" +
+		"\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=30000\nEND=90000\ntitle=a\\=b\\; \\#1\n"
+	if got != want {
+		t.Errorf("chaptersMetadata =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTagOutput_Chapters(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ffmpeg := writeScript(t, dir, "ffmpeg", "echo \"$@\" > "+argsFile+"\nfor last; do :; done\necho tagged > \"$last\"\n")
+	path := filepath.Join(dir, "v.mp4")
+	if err := os.WriteFile(path, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := &parser.VideoInfo{Chapters: []parser.Chapter{{Title: "Intro", Start: 0, End: 10}}}
+
+	d := &Downloader{config: Config{FFmpegPath: ffmpeg}, logger: logrus.New()}
+	if err := d.tagOutput(context.Background(), path, info); err != nil {
+		t.Fatalf("tagOutput: %v", err)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), ".chapters.v.mp4.txt -map 0 -map_chapters 1") {
+		t.Errorf("ffmpeg args = %q", args)
+	}
+
+	// --no-chapters leaves the file untouched.
+	os.Remove(argsFile)
+	d.config.NoChapters = true
+	if err := d.tagOutput(context.Background(), path, info); err != nil {
+		t.Fatalf("tagOutput: %v", err)
+	}
+	if fileExists(argsFile) {
+		t.Error("ffmpeg should not run when chapters are disabled")
+	}
+}
@@ -0,0 +1,166 @@
+package downloader
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ChecksumsFile is the name of the checksum list kept in the output
+// directory, in the format of sha256sum(1): "<hex>  <relative path>".
+const ChecksumsFile = "sha256sums.txt"
+
+// checksumsMu serializes updates of checksum files by the downloaders of
+// one process.
+var checksumsMu sync.Mutex
+
+// Outcomes of VerifyChecksums for one file.
+const (
+	ChecksumOK       = "OK"
+	ChecksumMismatch = "FAILED"
+	ChecksumMissing  = "MISSING"
+)
+
+// ChecksumResult is the outcome of checking one listed file.
+type ChecksumResult struct {
+	Name   string // Slash-separated path relative to the checksum file
+	Status string // ChecksumOK, ChecksumMismatch or ChecksumMissing
+	Err    error  // Why the file could not be read, for ChecksumMissing
+}
+
+// fileSHA256 returns the hex SHA-256 of the file at path.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readChecksums parses a sha256sum(1) list into name -> hex digest.
+func readChecksums(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("malformed checksum line %q", line)
+		}
+		// "  name" is text mode, " *name" binary mode; both mean the same here.
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		sums[name] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// writeChecksums saves sums as a sorted sha256sum(1) list, atomically.
+func writeChecksums(path string, sums map[string]string) error {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+ChecksumsFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// recordChecksums adds the SHA-256 of a finished download and its sidecars
+// to the checksum file of the output directory, replacing older entries
+// of the same names.
+func (d *Downloader) recordChecksums(outputPath string) error {
+	sums := map[string]string{}
+	for _, path := range d.outputFiles(outputPath) {
+		sum, err := fileSHA256(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		sums[d.remoteName(path)] = sum
+	}
+
+	checksumsMu.Lock()
+	defer checksumsMu.Unlock()
+
+	listPath := filepath.Join(d.config.OutputDir, ChecksumsFile)
+	existing, err := readChecksums(listPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", listPath, err)
+	}
+	for name, sum := range existing {
+		if _, ok := sums[name]; !ok {
+			sums[name] = sum
+		}
+	}
+	if err := writeChecksums(listPath, sums); err != nil {
+		return fmt.Errorf("failed to write %s: %w", listPath, err)
+	}
+	return nil
+}
+
+// VerifyChecksums re-hashes every file listed in the checksum file at
+// listPath, relative to its directory, and returns the outcomes sorted by
+// name.
+func VerifyChecksums(listPath string) ([]ChecksumResult, error) {
+	sums, err := readChecksums(listPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", listPath, err)
+	}
+	dir := filepath.Dir(listPath)
+
+	results := make([]ChecksumResult, 0, len(sums))
+	for name, want := range sums {
+		result := ChecksumResult{Name: name, Status: ChecksumOK}
+		got, err := fileSHA256(filepath.Join(dir, filepath.FromSlash(name)))
+		switch {
+		case err != nil:
+			result.Status, result.Err = ChecksumMissing, err
+		case got != want:
+			result.Status = ChecksumMismatch
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+	return results, nil
+}
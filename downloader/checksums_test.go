@@ -0,0 +1,72 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRecordAndVerifyChecksums(t *testing.T) {
+	dir := t.TempDir()
+	d := &Downloader{config: Config{OutputDir: dir, Checksums: true, WriteInfoJSON: true}, logger: logrus.New()}
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	first := write("a.mp4", "first")
+	write("a.info.json", "{}")
+	second := write("UP/b.mp4", "second")
+	for _, path := range []string{first, second} {
+		if err := d.recordChecksums(path); err != nil {
+			t.Fatalf("recordChecksums(%s): %v", path, err)
+		}
+	}
+	// Downloading a file again replaces its entry.
+	write("a.mp4", "first, again")
+	if err := d.recordChecksums(first); err != nil {
+		t.Fatal(err)
+	}
+
+	list := filepath.Join(dir, ChecksumsFile)
+	data, err := os.ReadFile(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("checksum file has %d lines, want 3:\n%s", len(lines), data)
+	}
+	// sha256sum format, sorted by name.
+	if !strings.HasSuffix(lines[0], "  UP/b.mp4") || !strings.HasSuffix(lines[2], "  a.mp4") {
+		t.Errorf("unexpected checksum file:\n%s", data)
+	}
+
+	write("UP/b.mp4", "bit rot")
+	os.Remove(filepath.Join(dir, "a.info.json"))
+
+	results, err := VerifyChecksums(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, r := range results {
+		got[r.Name] = r.Status
+	}
+	want := map[string]string{"UP/b.mp4": ChecksumMismatch, "a.info.json": ChecksumMissing, "a.mp4": ChecksumOK}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s: status %q, want %q", name, got[name], status)
+		}
+	}
+}
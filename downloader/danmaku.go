@@ -0,0 +1,312 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// DanmakuStyle controls how danmaku are laid out as ASS subtitles.
+type DanmakuStyle struct {
+	Font        string  // font family (default "Microsoft YaHei")
+	FontSize    int     // pixel size of a normal comment at 1080p (default 50)
+	Opacity     float64 // 0 (transparent) to 1 (opaque), default 0.8
+	ScrollTime  float64 // seconds a scrolling comment takes to cross the screen (default 8)
+	FixedTime   float64 // seconds a top or bottom comment stays (default 4)
+	Area        float64 // fraction of the screen height used for comments (default 1)
+	MaxOnScreen int     // comments shown at once; later ones are dropped (0: unlimited)
+}
+
+// withDefaults fills in unset fields.
+func (s DanmakuStyle) withDefaults() DanmakuStyle {
+	if s.Font == "" {
+		s.Font = "Microsoft YaHei"
+	}
+	if s.FontSize <= 0 {
+		s.FontSize = 50
+	}
+	if s.Opacity <= 0 || s.Opacity > 1 {
+		s.Opacity = 0.8
+	}
+	if s.ScrollTime <= 0 {
+		s.ScrollTime = 8
+	}
+	if s.FixedTime <= 0 {
+		s.FixedTime = 4
+	}
+	if s.Area <= 0 || s.Area > 1 {
+		s.Area = 1
+	}
+	return s
+}
+
+// normalDanmakuSize is the font size of a normal comment in Bilibili's XML.
+const normalDanmakuSize = 25
+
+// scrollSlot is the last comment placed on a scrolling lane.
+type scrollSlot struct {
+	start, width, speed float64
+}
+
+// danmakuLayout assigns comments to lanes so they do not overlap.
+type danmakuLayout struct {
+	style      DanmakuStyle
+	width      float64
+	height     float64
+	fontSize   float64 // normal comment size at this resolution
+	lineHeight float64
+	scroll     map[int][]scrollSlot // per mode: scroll and reverse
+	fixed      map[int][]float64    // per mode: end time of the last comment per lane
+	onScreen   []float64            // end times of the comments shown
+}
+
+// DanmakuToASS converts danmaku to ASS subtitles for a width x height
+// video. Scrolling, top and bottom comments get their own lanes; comments
+// that find no free lane or exceed MaxOnScreen are dropped.
+func DanmakuToASS(items []parser.Danmaku, width, height int, style DanmakuStyle) []byte {
+	style = style.withDefaults()
+	fontSize := float64(style.FontSize) * float64(height) / 1080
+	l := &danmakuLayout{
+		style:      style,
+		width:      float64(width),
+		height:     float64(height),
+		fontSize:   fontSize,
+		lineHeight: fontSize * 1.2,
+		scroll:     map[int][]scrollSlot{},
+		fixed:      map[int][]float64{},
+	}
+	lanes := int(l.height * style.Area / l.lineHeight)
+	if lanes < 1 {
+		lanes = 1
+	}
+	for _, mode := range []int{parser.DanmakuScroll, parser.DanmakuReverse} {
+		l.scroll[mode] = make([]scrollSlot, lanes)
+	}
+	for _, mode := range []int{parser.DanmakuTop, parser.DanmakuBottom} {
+		l.fixed[mode] = make([]float64, lanes)
+	}
+
+	var b strings.Builder
+	alpha := fmt.Sprintf("%02X", int((1-style.Opacity)*255+0.5))
+	fmt.Fprintf(&b, "[Script Info]\nScriptType: v4.00+\nPlayResX: %d\nPlayResY: %d\nWrapStyle: 2\nScaledBorderAndShadow: yes\n\n", width, height)
+	b.WriteString("[V4+ Styles]\n")
+	b.WriteString("Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	fmt.Fprintf(&b, "Style: Danmaku,%s,%d,&H%sFFFFFF,&H%sFFFFFF,&H%s000000,&H%s000000,0,0,0,0,100,100,0,0,1,%.1f,0,7,0,0,0,1\n\n",
+		style.Font, int(fontSize+0.5), alpha, alpha, alpha, alpha, fontSize/25)
+	b.WriteString("[Events]\n")
+	b.WriteString("Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+
+	for _, d := range items {
+		if event, ok := l.place(d); ok {
+			b.WriteString(event)
+		}
+	}
+	return []byte(b.String())
+}
+
+// place returns the Dialogue line for d, or false if d is dropped.
+func (l *danmakuLayout) place(d parser.Danmaku) (string, bool) {
+	text := assEscape(d.Text)
+	if text == "" {
+		return "", false
+	}
+	if l.style.MaxOnScreen > 0 {
+		shown := l.onScreen[:0]
+		for _, end := range l.onScreen {
+			if end > d.Time {
+				shown = append(shown, end)
+			}
+		}
+		l.onScreen = shown
+		if len(shown) >= l.style.MaxOnScreen {
+			return "", false
+		}
+	}
+
+	size := l.fontSize
+	if d.Size > 0 {
+		size = l.fontSize * float64(d.Size) / normalDanmakuSize
+	}
+	textWidth := estimateTextWidth(d.Text, size)
+
+	var end float64
+	var override string
+	switch d.Mode {
+	case parser.DanmakuTop, parser.DanmakuBottom:
+		lane := l.fixedLane(d.Mode, d.Time)
+		if lane < 0 {
+			return "", false
+		}
+		end = d.Time + l.style.FixedTime
+		l.fixed[d.Mode][lane] = end
+		if d.Mode == parser.DanmakuTop {
+			override = fmt.Sprintf(`\an8\pos(%d,%d)`, int(l.width/2), int(float64(lane)*l.lineHeight))
+		} else {
+			override = fmt.Sprintf(`\an2\pos(%d,%d)`, int(l.width/2), int(l.height-float64(lane)*l.lineHeight))
+		}
+	default:
+		speed := (l.width + textWidth) / l.style.ScrollTime
+		lane := l.scrollLane(d.Mode, d.Time, speed)
+		if lane < 0 {
+			return "", false
+		}
+		end = d.Time + l.style.ScrollTime
+		l.scroll[d.Mode][lane] = scrollSlot{start: d.Time, width: textWidth, speed: speed}
+		y := int(float64(lane) * l.lineHeight)
+		from, to := int(l.width), -int(textWidth+0.5)
+		if d.Mode == parser.DanmakuReverse {
+			from, to = to, from
+		}
+		override = fmt.Sprintf(`\move(%d,%d,%d,%d)`, from, y, to, y)
+	}
+	if l.style.MaxOnScreen > 0 {
+		l.onScreen = append(l.onScreen, end)
+	}
+
+	if d.Size > 0 && d.Size != normalDanmakuSize {
+		override += fmt.Sprintf(`\fs%d`, int(size+0.5))
+	}
+	if color := d.Color & 0xFFFFFF; color != 0xFFFFFF {
+		// ASS colours are BGR.
+		override += fmt.Sprintf(`\c&H%02X%02X%02X&`, color&0xFF, color>>8&0xFF, color>>16)
+	}
+	return fmt.Sprintf("Dialogue: 0,%s,%s,Danmaku,,0,0,0,,{%s}%s\n",
+		assTimestamp(d.Time), assTimestamp(end), override, text), true
+}
+
+// scrollLane returns the first lane where a comment starting at t with the
+// given speed neither overlaps nor catches up with the previous one, or -1.
+func (l *danmakuLayout) scrollLane(mode int, t, speed float64) int {
+	for i, prev := range l.scroll[mode] {
+		if prev.speed == 0 {
+			return i
+		}
+		entered := prev.start+prev.width/prev.speed <= t
+		leavesFirst := prev.start+(l.width+prev.width)/prev.speed <= t+l.width/speed
+		if entered && leavesFirst {
+			return i
+		}
+	}
+	return -1
+}
+
+// fixedLane returns the first top or bottom lane that is free at t, or -1.
+func (l *danmakuLayout) fixedLane(mode int, t float64) int {
+	for i, end := range l.fixed[mode] {
+		if end <= t {
+			return i
+		}
+	}
+	return -1
+}
+
+// estimateTextWidth approximates the rendered width of s: CJK characters
+// are about one em wide, Latin characters a little over half.
+func estimateTextWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		if r < 0x80 {
+			w += 0.55
+		} else {
+			w += 1
+		}
+	}
+	return w * size
+}
+
+// assEscape makes s safe for a Dialogue line: override braces and
+// backslashes are replaced by their full-width forms and line breaks by
+// spaces.
+func assEscape(s string) string {
+	s = strings.NewReplacer("\\", "＼", "{", "｛", "}", "｝", "\r", "", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
+
+// assTimestamp formats seconds as an ASS timestamp, e.g. 0:01:02.50.
+func assTimestamp(seconds float64) string {
+	cs := int64(seconds*100 + 0.5)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
+
+// parseResolution parses "1920x1080", falling back to 1920x1080.
+func parseResolution(s string) (width, height int) {
+	w, h, ok := strings.Cut(s, "x")
+	width, err1 := strconv.Atoi(w)
+	height, err2 := strconv.Atoi(h)
+	if !ok || err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return 1920, 1080
+	}
+	return width, height
+}
+
+// danmakuASS renders videoInfo.Danmaku for the selected stream.
+func (d *Downloader) danmakuASS(videoInfo *parser.VideoInfo, stream *parser.StreamInfo) []byte {
+	width, height := parseResolution(stream.Resolution)
+	return DanmakuToASS(videoInfo.Danmaku, width, height, d.config.Danmaku)
+}
+
+// burnDanmaku re-encodes the video at path with the danmaku drawn into the
+// picture. Audio and other streams are copied.
+func (d *Downloader) burnDanmaku(ctx context.Context, path string, ass []byte) error {
+	if !d.isFFmpegAvailable() {
+		return fmt.Errorf("burning in danmaku needs ffmpeg")
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+
+	// The subtitles filter has its own escaping rules; a plain file name
+	// relative to the working directory avoids them.
+	file, err := os.CreateTemp(dir, "danmaku-*.ass")
+	if err != nil {
+		return fmt.Errorf("failed to write danmaku: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(ass); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write danmaku: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write danmaku: %w", err)
+	}
+
+	d.logger.Info("Burning in danmaku (re-encoding video)...")
+
+	tmp := filepath.Join(dir, ".burn."+filepath.Base(path))
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), burnArgs(path, filepath.Base(file.Name()), tmp)...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+	if err := d.runLimited(ctx, cmd); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ffmpeg failed to burn in danmaku: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// burnArgs returns the ffmpeg arguments that draw the ASS file assName onto
+// the video of in.
+func burnArgs(in, assName, out string) []string {
+	return []string{
+		"-i", in,
+		"-map", "0",
+		"-vf", "subtitles=" + assName,
+		"-c", "copy",
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+		"-y", out,
+	}
+}
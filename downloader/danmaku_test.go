@@ -0,0 +1,112 @@
+package downloader
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// dialogues returns the Dialogue lines of an ASS file.
+func dialogues(ass []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(ass), "\n") {
+		if strings.HasPrefix(line, "Dialogue:") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestDanmakuToASS(t *testing.T) {
+	items := []parser.Danmaku{
+		{Time: 1, Mode: parser.DanmakuScroll, Size: 25, Color: 0xFFFFFF, Text: "你好"},
+		{Time: 1, Mode: parser.DanmakuScroll, Size: 25, Color: 0xFF0000, Text: "{red}"},
+		{Time: 2, Mode: parser.DanmakuTop, Size: 25, Color: 0xFFFFFF, Text: "top"},
+		{Time: 3, Mode: parser.DanmakuBottom, Size: 25, Color: 0xFFFFFF, Text: "bottom"},
+	}
+	ass := DanmakuToASS(items, 1920, 1080, DanmakuStyle{Font: "Noto Sans CJK SC", Opacity: 0.5})
+
+	if !strings.Contains(string(ass), "PlayResX: 1920\nPlayResY: 1080") {
+		t.Error("missing play resolution")
+	}
+	if !strings.Contains(string(ass), "Style: Danmaku,Noto Sans CJK SC,50,&H80FFFFFF") {
+		t.Errorf("unexpected style:\n%s", ass)
+	}
+
+	want := []string{
+		`Dialogue: 0,0:00:01.00,0:00:09.00,Danmaku,,0,0,0,,{\move(1920,0,-100,0)}你好`,
+		`Dialogue: 0,0:00:01.00,0:00:09.00,Danmaku,,0,0,0,,{\move(1920,60,-138,60)\c&H0000FF&}｛red｝`,
+		`Dialogue: 0,0:00:02.00,0:00:06.00,Danmaku,,0,0,0,,{\an8\pos(960,0)}top`,
+		`Dialogue: 0,0:00:03.00,0:00:07.00,Danmaku,,0,0,0,,{\an2\pos(960,1080)}bottom`,
+	}
+	if got := dialogues(ass); !reflect.DeepEqual(got, want) {
+		t.Errorf("dialogues =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDanmakuToASS_DensityCaps(t *testing.T) {
+	var items []parser.Danmaku
+	for i := 0; i < 10; i++ {
+		items = append(items, parser.Danmaku{Time: 1, Mode: parser.DanmakuTop, Size: 25, Text: "x"})
+	}
+
+	// Half the screen of 60px lines at 1080p holds 9 lanes.
+	if got := len(dialogues(DanmakuToASS(items, 1920, 1080, DanmakuStyle{Area: 0.5}))); got != 9 {
+		t.Errorf("with area 0.5: %d comments, want 9", got)
+	}
+	if got := len(dialogues(DanmakuToASS(items, 1920, 1080, DanmakuStyle{MaxOnScreen: 3}))); got != 3 {
+		t.Errorf("with max 3 on screen: %d comments, want 3", got)
+	}
+
+	// Once the first ones have left, new comments fit again.
+	items = append(items, parser.Danmaku{Time: 10, Mode: parser.DanmakuTop, Size: 25, Text: "y"})
+	if got := len(dialogues(DanmakuToASS(items, 1920, 1080, DanmakuStyle{MaxOnScreen: 3}))); got != 4 {
+		t.Errorf("after the screen clears: %d comments, want 4", got)
+	}
+}
+
+func TestScrollLane_NoCatchUp(t *testing.T) {
+	long := "a very long comment that moves fast"
+
+	// The long comment has fully entered by t=3 and leaves before the
+	// slower short one reaches the left edge: they share the first lane.
+	lines := dialogues(DanmakuToASS([]parser.Danmaku{
+		{Time: 0, Mode: parser.DanmakuScroll, Size: 25, Text: long},
+		{Time: 3, Mode: parser.DanmakuScroll, Size: 25, Text: "short"},
+	}, 1920, 1080, DanmakuStyle{}))
+	if len(lines) != 2 || !strings.Contains(lines[1], `\move(1920,0,`) {
+		t.Errorf("dialogues = %q, want both on the first lane", lines)
+	}
+
+	// The faster long comment would catch up with the short one: it takes
+	// the next lane.
+	lines = dialogues(DanmakuToASS([]parser.Danmaku{
+		{Time: 0, Mode: parser.DanmakuScroll, Size: 25, Text: "short"},
+		{Time: 1, Mode: parser.DanmakuScroll, Size: 25, Text: long},
+	}, 1920, 1080, DanmakuStyle{}))
+	if len(lines) != 2 || !strings.Contains(lines[1], `\move(1920,60,`) {
+		t.Errorf("dialogues = %q, want the long comment on the second lane", lines)
+	}
+}
+
+func TestParseResolution(t *testing.T) {
+	if w, h := parseResolution("1280x720"); w != 1280 || h != 720 {
+		t.Errorf("parseResolution = %dx%d", w, h)
+	}
+	if w, h := parseResolution(""); w != 1920 || h != 1080 {
+		t.Errorf("fallback = %dx%d", w, h)
+	}
+}
+
+func TestBurnArgs(t *testing.T) {
+	want := []string{
+		"-i", "/w/v.mp4", "-map", "0", "-vf", "subtitles=danmaku-1.ass",
+		"-c", "copy", "-c:v", "libx264", "-preset", "veryfast", "-crf", "20",
+		"-y", "/w/.burn.v.mp4",
+	}
+	if got := burnArgs("/w/v.mp4", "danmaku-1.ass", "/w/.burn.v.mp4"); !reflect.DeepEqual(got, want) {
+		t.Errorf("burnArgs = %q", got)
+	}
+}
@@ -5,40 +5,127 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-	"unicode"
 
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/httpclient"
+	"github.com/dengmengmian/goBili/i18n"
+	"github.com/dengmengmian/goBili/logging"
 	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/quality"
+	"github.com/dengmengmian/goBili/ratelimit"
+	"github.com/dengmengmian/goBili/upload"
 
 	"github.com/sirupsen/logrus"
 )
 
 // Config holds downloader configuration
 type Config struct {
-	OutputDir   string
-	Threads     int
-	Verbose     bool
-	Quality     string
-	Format      string
-	AudioOnly   bool
-	VideoOnly   bool
-	AuthManager interface{} // Will be cast to *auth.AuthManager when needed
+	OutputDir     string
+	Threads       int
+	Verbose       bool
+	Quality       string
+	Format        string
+	AudioOnly     bool
+	VideoOnly     bool
+	FormatID      string          // Exact formats from 'goBili formats', "VIDEO[+AUDIO]"; overrides Quality
+	Codec         string          // Preferred video codec of a quality served in several: "avc", "hevc" or "av1" ("" takes the first)
+	AudioFormat   string          // Transcode audio-only downloads to mp3, flac, ogg or opus ("" or "m4a" keeps the stream)
+	AudioQuality  string          // Target bitrate for lossy AudioFormat, e.g. "320k"
+	AudioBitrate  string          // Audio stream of audio-only downloads: "best" (default) or a maximum, e.g. "132k"
+	Existing      ExistingPolicy  // What to do when the output file already exists
+	TempDir       string          // Working directory for intermediate files (default: OutputDir/.goBili-tmp)
+	KeepTemp      bool            // Keep intermediate files of interrupted downloads for inspection
+	StreamMerge   bool            // Pipe video and audio straight into ffmpeg instead of using temp files
+	NoPreallocate bool            // Do not reserve disk space for downloads before writing them
+	Downloader    string          // Stream downloader: "native" (default) or "aria2c"
+	Aria2cPath    string          // aria2c executable (default: "aria2c" from PATH)
+	Aria2RPC      *Aria2RPC       // Push downloads to this aria2 instance instead of downloading locally
+	Aria2Dir      string          // Download directory on the aria2 side (default: aria2's own)
+	Aria2Wait     bool            // Wait for aria2 to finish dispatched downloads
+	FFmpegPath    string          // ffmpeg executable (default: "ffmpeg" from PATH)
+	MP4BoxPath    string          // MP4Box executable used when ffmpeg is unavailable (default: "MP4Box")
+	EmbedMetadata bool            // Tag outputs with title, uploader, description, date and category
+	EmbedCover    bool            // Embed the video cover as cover art (MKV: attachment)
+	EmbedSubs     bool            // Mux VideoInfo.Subtitles into MKV outputs
+	AudioTracks   string          // Audio tracks muxed into MKV outputs: "best" (default) or "all"
+	NoChapters    bool            // Do not mux VideoInfo.Chapters into the output
+	WriteInfoJSON bool            // Save the video metadata next to the output
+	WriteCover    bool            // Save the cover image next to the output
+	WriteNFO      bool            // Save Kodi/Jellyfin NFO metadata next to the output
+	Storyboard    bool            // Save the VideoInfo.Storyboard thumbnails and a WebVTT track next to the output
+	SplitChapters bool            // Also cut audio-only outputs into one track per chapter, with a cue sheet
+	NoMtime       bool            // Keep the download time as mtime instead of the publish date
+	Checksums     bool            // Record the SHA-256 of outputs and sidecars in OutputDir/sha256sums.txt
+	Hooks         []string        // Shell commands run after each finished download; see runHook
+	Uploader      upload.Uploader // Copy finished downloads and their sidecars to remote storage
+	UploadDelete  bool            // Remove the local files after a successful upload
+	WriteDanmaku  bool            // Save VideoInfo.Danmaku as XML and ASS next to the output
+	BurnDanmaku   bool            // Re-encode the video with VideoInfo.Danmaku drawn in
+	EmbedDanmaku  bool            // Mux VideoInfo.Danmaku into MKV outputs as an ASS track
+	Danmaku       DanmakuStyle    // Layout of danmaku converted to ASS
+
+	// OutputDirTemplate places each video in a subdirectory of OutputDir,
+	// e.g. "{{.Owner}}/{{.SeriesTitle}}"; see OutputDirData.
+	OutputDirTemplate string
+	// NoQualitySuffix names files after the title alone, without a
+	// suffix such as "_1080p", as media servers expect.
+	NoQualitySuffix bool
+	// QualityFallback is what to download when a video lacks Quality:
+	// quality.FallbackBest (also ""), FallbackNextLower or FallbackAbort.
+	QualityFallback string
+
+	// SidecarSuffixes overrides the default names of sidecar files; see
+	// SidecarPath.
+	SidecarSuffixes map[SidecarKind]string
+
+	// ProcessLimiter bounds concurrent muxer processes across downloaders
+	// sharing it; Limits lowers the priority of this job's muxer processes.
+	ProcessLimiter *ProcessLimiter
+	Limits         ResourceLimits
+
+	// HostLimiter bounds concurrent media connections per CDN host across
+	// downloaders sharing it; nil means no limit.
+	HostLimiter *HostLimiter
+
+	// RateLimiter is the request budget shared with the parser; media
+	// requests, each chunk and retry included, take a token of its CDN
+	// bucket. Downloads handed to aria2 are not limited. nil means no limit.
+	RateLimiter *ratelimit.Limiter
+
+	// Buffers supplies the copy buffers of the downloads; nil uses a
+	// process-wide pool of DefaultBufferSize buffers.
+	Buffers *BufferPool
+
+	// Logger receives the log; nil logs to stderr at the level set by
+	// Verbose. Console receives what is meant for a terminal: the
+	// progress line and the output of ffmpeg and the other tools run;
+	// nil means standard output and error, io.Discard drops it.
+	Logger  logging.Logger
+	Console io.Writer
+
+	// AuthManager signs the media requests with the login; nil signs
+	// them as a guest, with the headers the CDN requires but no cookies.
+	AuthManager auth.RequestSigner
 }
 
 // Downloader handles video downloading
 type Downloader struct {
 	config Config
-	logger *logrus.Logger
+	logger logging.Logger
 	client *http.Client
+
+	aria2cWarning sync.Once // warn only once about a missing aria2c
 }
 
 // DownloadProgress represents download progress information
@@ -52,31 +139,26 @@ type DownloadProgress struct {
 
 // NewDownloader creates a new downloader instance
 func NewDownloader(config Config) *Downloader {
-	logger := logrus.New()
-	if config.Verbose {
-		logger.SetLevel(logrus.DebugLevel)
-	} else {
-		logger.SetLevel(logrus.InfoLevel)
-	}
-
-	// Transport with sensible timeouts to prevent hanging connections.
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout:   15 * time.Second,
-		ResponseHeaderTimeout: 30 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-		MaxIdleConns:          10,
-		IdleConnTimeout:       90 * time.Second,
+	logger := config.Logger
+	if logger == nil {
+		l := logrus.New()
+		if config.Verbose {
+			l.SetLevel(logrus.DebugLevel)
+		} else {
+			l.SetLevel(logrus.InfoLevel)
+		}
+		logger = l
 	}
 
+	// Downloaders share the connections of the process's transport.
+	transport := httpclient.Shared()
+
 	return &Downloader{
 		config: config,
 		logger: logger,
 		client: &http.Client{
-			Transport: transport,
+			// Wait for the rate limit before taking a connection slot.
+			Transport: config.RateLimiter.CDNBucket().Transport(config.HostLimiter.Transport(transport)),
 			Timeout:   0, // No global timeout; per-operation deadlines are handled via context.
 		},
 	}
@@ -100,77 +182,214 @@ func (d *Downloader) DownloadVideo(videoInfo *parser.VideoInfo, streams []*parse
 
 // DownloadVideoContext downloads a video with context support for cancellation.
 func (d *Downloader) DownloadVideoContext(ctx context.Context, videoInfo *parser.VideoInfo, streams []*parser.StreamInfo) error {
+	_, err := d.DownloadVideoResult(ctx, videoInfo, streams)
+	return err
+}
+
+// Result describes a finished download.
+type Result struct {
+	Path    string        // Final output file
+	Size    int64         // Size of the output file in bytes
+	Quality int           // Bilibili quality code of the selected stream
+	Elapsed time.Duration // Wall-clock time from start to finish
+	Skipped bool          // The output already existed and the existing-file policy skipped it
+	NoAudio bool          // The video has no audio stream and was saved as video only
+
+	// AudioKbps is the bitrate of the audio stream of an audio-only
+	// download, in kbit/s; 0 when unknown or not audio-only.
+	AudioKbps int
+
+	// Dispatched holds the aria2 GIDs when the download was pushed to
+	// aria2 instead of being written locally; Path is then the file name
+	// on the aria2 side.
+	Dispatched []string
+
+	// Uploaded is the remote location of the output when an Uploader is
+	// configured.
+	Uploaded string
+
+	// Tracks are the files of the chapters of an audio download split
+	// with SplitChapters.
+	Tracks []string
+}
+
+// DownloadVideoResult is like DownloadVideoContext but also reports what was
+// written, for callers that record history.
+func (d *Downloader) DownloadVideoResult(ctx context.Context, videoInfo *parser.VideoInfo, streams []*parser.StreamInfo) (*Result, error) {
+	start := time.Now()
+	defer finishPipeline(ctx)
+
 	// Select the appropriate stream based on quality preference
-	stream := d.selectStream(streams)
-	if stream == nil {
-		return fmt.Errorf("no suitable stream found")
+	stream, err := d.pickStream(streams)
+	if err != nil {
+		return nil, err
 	}
 
+	if want, ok := quality.Parse(d.config.Quality); ok && d.config.FormatID == "" && !d.config.AudioOnly && stream.Quality != want.QN {
+		d.logger.Warnf("%s is not available for this video; downloading %s", want.Label, quality.Name(stream.Quality))
+	}
 	d.logger.Infof("Selected stream: %s (%s)", stream.Resolution, stream.Format)
+	stream = d.audioOnlyStream(stream)
+	ctx = withSelectedStream(ctx, stream)
 
 	// Generate output filename
-	filename := d.generateFilename(videoInfo, stream)
-	outputPath := filepath.Join(d.config.OutputDir, filename)
+	subdir, err := d.outputSubdir(videoInfo)
+	if err != nil {
+		return nil, err
+	}
+	subdir = filepath.Join(subdir, contextSubdir(ctx))
+	filename := filepath.Join(subdir, d.generateFilename(videoInfo, stream))
+	if d.config.Aria2RPC != nil {
+		return d.dispatchToAria2(ctx, stream, filepath.ToSlash(filename))
+	}
+
+	outputPath := d.finalOutputPath(filepath.Join(d.config.OutputDir, filename))
 
 	// Create output directory if it doesn't exist
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Apply the existing-file policy to the final output path.
+	outputPath, skip, err := d.resolveOutputPath(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Path: outputPath, Quality: stream.Quality}
+	if d.config.AudioOnly {
+		result.AudioKbps = audioTrackKbps(d.selectAudioTrack(stream))
+	}
+	if skip {
+		d.logger.Infof("Skipping existing file: %s", outputPath)
+		result.Skipped = true
+		return result, nil
 	}
 
 	// Check context before starting downloads.
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
 
-	// Download based on configuration
-	if d.config.AudioOnly {
-		return d.downloadAudio(ctx, stream, outputPath)
+	// Everything is written inside the working directory first; only the
+	// finished file is moved into the output directory.
+	workDir := d.WorkDir()
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	if d.config.VideoOnly {
-		return d.downloadVideoOnly(ctx, stream, outputPath)
+	defer d.cleanupWorkDir(workDir)
+	// Each download gets a directory of its own, so downloads of same-named
+	// outputs (queue workers, playlist slots) never touch each other's files.
+	jobDir, err := os.MkdirTemp(workDir, "dl-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	return d.downloadVideoAndAudio(ctx, stream, outputPath)
-}
+	defer d.removeJobDir(ctx, jobDir)
+	workPath := filepath.Join(jobDir, filepath.Base(outputPath))
 
-// selectStream selects the appropriate stream based on quality preference
-func (d *Downloader) selectStream(streams []*parser.StreamInfo) *parser.StreamInfo {
-	if len(streams) == 0 {
-		return nil
+	// Download based on configuration
+	switch {
+	case d.config.AudioOnly:
+		if stream.AudioURL == "" {
+			return nil, ErrNoAudio
+		}
+		err = d.downloadAudio(ctx, stream, workPath)
+	case d.config.VideoOnly:
+		err = d.downloadVideoOnly(ctx, stream, workPath)
+	case stream.AudioURL == "":
+		// Muxed legacy streams carry their own audio; otherwise there is
+		// no audio to merge and the video is kept on its own.
+		if !stream.Muxed {
+			d.logger.Warn("No audio stream available, saving video only")
+			result.NoAudio = true
+		}
+		err = d.downloadVideoOnly(ctx, stream, workPath)
+	case d.wantsMKVMux(workPath, videoInfo, stream):
+		err = d.downloadMKV(ctx, videoInfo, stream, workPath)
+	default:
+		err = d.downloadVideoAndAudio(ctx, stream, workPath)
+	}
+	if err == nil {
+		// Post-processing runs ffmpeg as well; let the next video download.
+		err = enterMerge(ctx)
+	}
+	if err != nil {
+		os.Remove(workPath) // Never leave a half-finished file behind.
+		return nil, err
 	}
 
-	// Quality mapping
-	qualityMap := map[string]int{
-		"best":  80,
-		"1080p": 80,
-		"720p":  64,
-		"480p":  32,
-		"360p":  16,
+	if d.config.BurnDanmaku && !d.config.AudioOnly && len(videoInfo.Danmaku) > 0 {
+		if err := d.burnDanmaku(ctx, workPath, d.danmakuASS(videoInfo, stream)); err != nil {
+			d.logger.Warnf("Failed to burn in danmaku, keeping the video without it: %v", err)
+		}
 	}
 
-	targetQuality, exists := qualityMap[d.config.Quality]
-	if !exists {
-		// Default to best quality
-		targetQuality = 80
+	if err := d.tagOutput(ctx, workPath, videoInfo); err != nil {
+		d.logger.Warnf("Failed to embed metadata: %v", err)
 	}
 
-	// Find exact quality match
-	for _, stream := range streams {
-		if stream.Quality == targetQuality {
-			return stream
+	if err := d.moveIntoPlace(workPath, outputPath); err != nil {
+		return nil, err
+	}
+	d.writeSidecars(ctx, outputPath, videoInfo, stream)
+	if d.config.AudioOnly && d.config.SplitChapters {
+		tracks, err := d.splitChapters(ctx, outputPath, videoInfo)
+		if err != nil {
+			d.logger.Warnf("Failed to split the audio by chapters: %v", err)
 		}
+		result.Tracks = tracks
 	}
-
-	// If exact quality not found, return the best available
-	best := streams[0]
-	for _, stream := range streams[1:] {
-		if stream.Quality > best.Quality {
-			best = stream
+	d.setPublishTime(outputPath, videoInfo)
+	if d.config.Checksums {
+		if err := d.recordChecksums(outputPath); err != nil {
+			d.logger.Warnf("Failed to record checksums: %v", err)
+		}
+	}
+	if info, err := os.Stat(outputPath); err == nil {
+		result.Size = info.Size()
+	}
+	if d.config.Uploader != nil {
+		if err := d.upload(ctx, result); err != nil {
+			return nil, err
 		}
 	}
+	result.Elapsed = time.Since(start)
+	d.runHooks(ctx, result, videoInfo)
+	if d.config.Uploader != nil && d.config.UploadDelete {
+		d.removeUploaded(result)
+	}
+	return result, nil
+}
 
-	return best
+// finalOutputPath returns outputPath with the extension matching the
+// configured download mode (audio-only, video-only, or merged).
+func (d *Downloader) finalOutputPath(outputPath string) string {
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	switch {
+	case d.config.AudioOnly:
+		return base + d.audioExt()
+	case d.config.VideoOnly:
+		return base + ".mp4"
+	default:
+		return outputPath
+	}
+}
+
+// selectStream selects the stream of the configured quality, or the one
+// QualityFallback picks when that is not available; nil when it picks
+// none. Audio-only downloads take any video quality. Of a quality served
+// in several codecs, the configured Codec is preferred.
+func (d *Downloader) selectStream(streams []*parser.StreamInfo) *parser.StreamInfo {
+	fallback := d.config.QualityFallback
+	if d.config.AudioOnly {
+		fallback = quality.FallbackBest
+	}
+	stream := parser.StreamByQualityFallback(streams, d.config.Quality, fallback)
+	if stream != nil && d.config.Codec != "" {
+		stream = preferCodec(streams, stream, d.config.Codec)
+	}
+	return stream
 }
 
 // generateFilename generates a filename for the downloaded video
@@ -178,88 +397,59 @@ func (d *Downloader) generateFilename(videoInfo *parser.VideoInfo, stream *parse
 	// Clean the title for use as filename
 	title := sanitizeFilename(videoInfo.Title)
 
-	// Add quality suffix
-	qualitySuffix := ""
-	switch stream.Quality {
-	case 80:
-		qualitySuffix = "_1080p"
-	case 64:
-		qualitySuffix = "_720p"
-	case 32:
-		qualitySuffix = "_480p"
-	case 16:
-		qualitySuffix = "_360p"
+	if d.config.NoQualitySuffix {
+		return title + "." + d.config.Format
 	}
-
-	return fmt.Sprintf("%s%s.%s", title, qualitySuffix, d.config.Format)
+	return fmt.Sprintf("%s%s.%s", title, quality.Suffix(stream.Quality), d.config.Format)
 }
 
-// sanitizeFilename cleans a string to be a safe filename component.
-// It removes path separators, control characters, and other unsafe runes,
-// truncates to a reasonable length, and ensures the result is not empty
-// and does not resolve to a parent directory.
-func sanitizeFilename(name string) string {
-	// Replace known dangerous characters with underscores.
-	replacer := strings.NewReplacer(
-		"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_",
-		"\"", "_", "<", "_", ">", "_", "|", "_",
-	)
-	clean := replacer.Replace(name)
-
-	// Remove any remaining control or non-printable characters.
-	clean = strings.Map(func(r rune) rune {
-		if unicode.IsControl(r) || !unicode.IsPrint(r) {
-			return '_'
-		}
-		return r
-	}, clean)
-
-	// Strip leading/trailing spaces and dots (problematic on Windows).
-	clean = strings.Trim(clean, " .")
+// downloadAudio downloads only the audio stream
+func (d *Downloader) downloadAudio(ctx context.Context, stream *parser.StreamInfo, outputPath string) error {
+	d.logger.Info("Downloading audio...")
 
-	// Ensure the result is not empty.
-	if clean == "" {
-		clean = "video"
+	codec, transcode := d.audioTranscodeCodec()
+	if !transcode {
+		return d.fetchAudio(ctx, stream.AudioURL, outputPath)
 	}
 
-	// Truncate to a reasonable maximum length.
-	const maxLen = 200
-	cleanRunes := []rune(clean)
-	if len(cleanRunes) > maxLen {
-		clean = string(cleanRunes[:maxLen])
+	audioPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_audio.m4a"
+	if err := d.fetchAudio(ctx, stream.AudioURL, audioPath); err != nil {
+		os.Remove(audioPath)
+		return err
 	}
+	defer os.Remove(audioPath)
 
-	// Prevent path traversal: use only the base name.
-	clean = filepath.Base(clean)
-	if clean == "." || clean == ".." {
-		clean = "video"
+	if err := enterMerge(ctx); err != nil {
+		return err
 	}
-
-	return clean
-}
-
-// downloadAudio downloads only the audio stream
-func (d *Downloader) downloadAudio(ctx context.Context, stream *parser.StreamInfo, outputPath string) error {
-	d.logger.Info("Downloading audio...")
-
-	// Change extension to audio format
-	outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".m4a"
-
-	return d.downloadFile(ctx, stream.AudioURL, outputPath)
+	d.logger.Infof("Converting audio to %s...", strings.TrimPrefix(codec.ext, "."))
+	return d.transcodeAudio(ctx, codec, audioPath, outputPath)
 }
 
 // downloadVideoOnly downloads only the video stream
 func (d *Downloader) downloadVideoOnly(ctx context.Context, stream *parser.StreamInfo, outputPath string) error {
 	d.logger.Info("Downloading video...")
 
-	// Change extension to video format
-	outputPath = strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".mp4"
-
 	return d.downloadFile(ctx, stream.VideoURL, outputPath)
 }
 
 // downloadVideoAndAudio downloads both video and audio streams
 func (d *Downloader) downloadVideoAndAudio(ctx context.Context, stream *parser.StreamInfo, outputPath string) error {
+	if d.config.StreamMerge {
+		if d.useAria2c() {
+			d.logger.Warn("Streaming merge is not available with aria2c; using temporary files")
+		} else if d.canStreamMerge() {
+			err := d.streamMerge(ctx, stream, outputPath)
+			if err == nil || ctx.Err() != nil {
+				return err
+			}
+			d.logger.Warnf("Streaming merge failed, falling back to temporary files: %v", err)
+			os.Remove(outputPath)
+		} else {
+			d.logger.Warn("Streaming merge needs ffmpeg and is not supported on Windows; using temporary files")
+		}
+	}
+
 	d.logger.Info("Downloading video and audio...")
 
 	// For simplicity, we'll download them separately and then merge
@@ -269,6 +459,7 @@ func (d *Downloader) downloadVideoAndAudio(ctx context.Context, stream *parser.S
 	audioPath := strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "_audio.m4a"
 
 	// Download video and audio concurrently with context.
+	parent := ctx
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -287,7 +478,7 @@ func (d *Downloader) downloadVideoAndAudio(ctx context.Context, stream *parser.S
 
 	go func() {
 		defer wg.Done()
-		audioErr = d.downloadFile(ctx, stream.AudioURL, audioPath)
+		audioErr = d.fetchAudio(ctx, stream.AudioURL, audioPath)
 		if audioErr != nil {
 			cancel() // Cancel video download if audio fails.
 		}
@@ -296,39 +487,53 @@ func (d *Downloader) downloadVideoAndAudio(ctx context.Context, stream *parser.S
 	wg.Wait()
 
 	if videoErr != nil {
-		os.Remove(audioPath) // Clean up partial audio.
+		if !d.keepPartial(parent) {
+			os.Remove(audioPath) // Clean up partial audio.
+		}
 		return fmt.Errorf("failed to download video: %w", videoErr)
 	}
 	if audioErr != nil {
-		os.Remove(videoPath) // Clean up partial video.
+		if !d.keepPartial(parent) {
+			os.Remove(videoPath) // Clean up partial video.
+		}
 		return fmt.Errorf("failed to download audio: %w", audioErr)
 	}
 
 	// For now, just copy the video file as the final output
 	// In a real implementation, you would merge video and audio using ffmpeg
-	return d.mergeVideoAndAudio(videoPath, audioPath, outputPath)
+	return d.mergeVideoAndAudio(ctx, videoPath, audioPath, outputPath)
 }
 
 // downloadFile downloads a file from URL to local path
 func (d *Downloader) downloadFile(ctx context.Context, url, outputPath string) error {
 	d.logger.Debugf("Downloading %s to %s", url, outputPath)
 
+	if d.useAria2c() {
+		return d.downloadWithAria2c(ctx, url, outputPath)
+	}
+
+	// Expired URLs are replaced mid-download when the context carries a
+	// StreamRefresher.
+	src := newMediaURL(ctx, url)
+
 	// Use chunked download when threads > 1 and server supports Range.
 	if d.config.Threads > 1 {
-		supportsRange, contentLength, err := d.checkRangeSupport(ctx, url)
+		supportsRange, contentLength, err := d.checkRangeSupport(ctx, src)
 		if err == nil && supportsRange && contentLength > 0 {
 			d.logger.Infof("Using chunked download with %d threads (%.2f MB)",
 				d.config.Threads, float64(contentLength)/(1024*1024))
-			return d.downloadFileChunked(ctx, url, outputPath, contentLength)
+			return d.downloadFileChunked(ctx, src, outputPath, contentLength)
 		}
 		d.logger.Debug("Range not supported, falling back to single-threaded download")
 	}
 
-	return d.downloadFileSingle(ctx, url, outputPath)
+	return d.downloadFileSingle(ctx, src, outputPath)
 }
 
-// downloadFileSingle downloads a file with a single HTTP request and retry support.
-func (d *Downloader) downloadFileSingle(ctx context.Context, url, outputPath string) error {
+// downloadFileSingle downloads a file with a single HTTP request and retry
+// support. A retry after an interruption or an expired URL continues after
+// the bytes already written when the server honours Range.
+func (d *Downloader) downloadFileSingle(ctx context.Context, src *mediaURL, outputPath string) error {
 	// Create the output file.
 	file, err := os.Create(outputPath)
 	if err != nil {
@@ -337,99 +542,178 @@ func (d *Downloader) downloadFileSingle(ctx context.Context, url, outputPath str
 	defer file.Close()
 
 	cfg := defaultRetryConfig()
-
-	return retry(ctx, cfg, func() (int, error) {
-		// Build the request.
-		var req *http.Request
-		var reqErr error
-
-		if d.config.AuthManager != nil {
-			if authManager, ok := d.config.AuthManager.(interface {
-				CreateAuthenticatedRequest(method, url string, body io.Reader) (*http.Request, error)
-			}); ok {
-				req, reqErr = authManager.CreateAuthenticatedRequest("GET", url, nil)
+	var written int64
+
+	for {
+		url, gen := src.get()
+		err := retry(ctx, cfg, func() (int, error) {
+			// Build the request.
+			req, err := d.newMediaRequest(ctx, "GET", url)
+			if err != nil {
+				return 0, err
+			}
+			if written > 0 {
+				req.Header.Set("Range", fmt.Sprintf("bytes=%d-", written))
 			}
-		}
-		if req == nil && reqErr == nil {
-			req, reqErr = http.NewRequestWithContext(ctx, "GET", url, nil)
-		}
-		if reqErr != nil {
-			return 0, fmt.Errorf("failed to create request: %w", reqErr)
-		}
 
-		req = req.WithContext(ctx)
+			resp, err := d.client.Do(req)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+
+			switch {
+			case resp.StatusCode == http.StatusPartialContent && written > 0:
+				if start := contentRangeStart(resp.Header.Get("Content-Range")); start != written {
+					// Appending would corrupt the file; start over.
+					d.logger.Warnf("Server resumed at byte %d instead of %d; restarting the download", start, written)
+					written = 0
+					if err := file.Truncate(0); err != nil {
+						return 0, fmt.Errorf("failed to truncate file: %w", err)
+					}
+					return 0, errRangeMismatch
+				}
+				d.logger.Debugf("Resuming at %d bytes", written)
+			case resp.StatusCode == http.StatusOK:
+				written = 0
+				if err := file.Truncate(0); err != nil {
+					return 0, fmt.Errorf("failed to truncate file: %w", err)
+				}
+				if resp.ContentLength > 0 {
+					if err := d.allocate(file, resp.ContentLength); err != nil {
+						return 0, err
+					}
+				}
+			default:
+				return resp.StatusCode, mediaStatusError(resp)
+			}
+			if _, err := file.Seek(written, io.SeekStart); err != nil {
+				return 0, fmt.Errorf("failed to seek file: %w", err)
+			}
 
-		resp, err := d.client.Do(req)
-		if err != nil {
-			return 0, err
-		}
-		defer resp.Body.Close()
+			// A resumed response carries only the remaining bytes.
+			totalSize := resp.ContentLength
+			if totalSize > 0 {
+				totalSize += written
+				if written == 0 {
+					d.logger.Infof("File size: %.2f MB", float64(totalSize)/(1024*1024))
+				}
+			}
 
-		if resp.StatusCode != http.StatusOK {
-			return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-		}
+			progressReader := &ProgressReader{
+				Reader:     resp.Body,
+				Total:      totalSize,
+				Offset:     written,
+				Progress:   nil, // No progress channel for simple downloads
+				Console:    d.config.Console,
+				OnProgress: progressFromContext(ctx),
+			}
 
-		totalSize := resp.ContentLength
-		if totalSize > 0 {
-			d.logger.Infof("File size: %.2f MB", float64(totalSize)/(1024*1024))
-		}
+			n, err := d.copy(file, progressReader)
+			written += n
+			if err != nil {
+				return 0, fmt.Errorf("failed to write file: %w", err)
+			}
+			// Drop any preallocated space the body did not fill.
+			if err := file.Truncate(written); err != nil {
+				return 0, fmt.Errorf("failed to truncate file: %w", err)
+			}
 
-		if err := file.Truncate(0); err != nil {
-			return 0, fmt.Errorf("failed to truncate file: %w", err)
+			d.logger.Infof("Successfully downloaded: %s", outputPath)
+			return resp.StatusCode, nil
+		})
+		if errors.Is(err, ErrURLExpired) && ctx.Err() == nil {
+			if err := src.renew(ctx, gen, d.logger); err != nil {
+				return err
+			}
+			continue
 		}
-		if _, err := file.Seek(0, 0); err != nil {
-			return 0, fmt.Errorf("failed to seek file: %w", err)
+		if errors.Is(err, errRangeMismatch) {
+			continue // written is 0 again, so the next request has no Range
 		}
+		return err
+	}
+}
 
-		progressReader := &ProgressReader{
-			Reader:   resp.Body,
-			Total:    totalSize,
-			Progress: nil, // No progress channel for simple downloads
-		}
+// errRangeMismatch is returned when a resumed response does not start at
+// the requested offset.
+var errRangeMismatch = errors.New("resumed response starts at the wrong offset")
 
-		if _, err := io.Copy(file, progressReader); err != nil {
-			return 0, fmt.Errorf("failed to write file: %w", err)
-		}
+// contentRangeStart returns the first byte position of a Content-Range
+// header such as "bytes 100-999/1000", or -1 if it cannot be parsed.
+func contentRangeStart(header string) int64 {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return start
+}
 
-		d.logger.Infof("Successfully downloaded: %s", outputPath)
-		return resp.StatusCode, nil
-	})
+// newMediaRequest builds a request for a media URL, carrying the
+// authentication headers (Cookie, Referer, User-Agent) of the auth
+// manager, or of a guest when none is configured.
+func (d *Downloader) newMediaRequest(ctx context.Context, method, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	signer := d.config.AuthManager
+	if signer == nil {
+		signer = guestSigner()
+	}
+	signer.SignRequest(req)
+	return req, nil
 }
 
+// guestSigner signs the media requests of downloaders without an auth
+// manager.
+var guestSigner = sync.OnceValue(func() auth.RequestSigner {
+	return auth.NewAnonymousAuthManager(logging.Discard)
+})
+
 // checkRangeSupport checks if the server supports HTTP Range requests.
 // It returns (supportsRange, contentLength, error).
-func (d *Downloader) checkRangeSupport(ctx context.Context, url string) (bool, int64, error) {
-	req, reqErr := http.NewRequestWithContext(ctx, "HEAD", url, nil)
-	if reqErr != nil {
-		return false, 0, reqErr
-	}
-
-	// Try to use auth if available.
-	if d.config.AuthManager != nil {
-		if authManager, ok := d.config.AuthManager.(interface {
-			CreateAuthenticatedRequest(method, url string, body io.Reader) (*http.Request, error)
-		}); ok {
-			if authReq, err := authManager.CreateAuthenticatedRequest("HEAD", url, nil); err == nil {
-				authReq = authReq.WithContext(ctx)
-				req = authReq
-			}
+func (d *Downloader) checkRangeSupport(ctx context.Context, src *mediaURL) (bool, int64, error) {
+	for {
+		url, gen := src.get()
+		req, err := d.newMediaRequest(ctx, "HEAD", url)
+		if err != nil {
+			return false, 0, err
 		}
-	}
 
-	resp, err := d.client.Do(req)
-	if err != nil {
-		return false, 0, err
-	}
-	defer resp.Body.Close()
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return false, 0, err
+		}
+		resp.Body.Close()
+
+		if err := mediaStatusError(resp); resp.StatusCode >= 400 {
+			if errors.Is(err, ErrURLExpired) {
+				if err := src.renew(ctx, gen, d.logger); err != nil {
+					return false, 0, err
+				}
+				continue
+			}
+			return false, 0, err
+		}
 
-	acceptRanges := resp.Header.Get("Accept-Ranges")
-	supportsRange := strings.EqualFold(acceptRanges, "bytes")
+		acceptRanges := resp.Header.Get("Accept-Ranges")
+		supportsRange := strings.EqualFold(acceptRanges, "bytes")
 
-	return supportsRange, resp.ContentLength, nil
+		return supportsRange, resp.ContentLength, nil
+	}
 }
 
 // downloadFileChunked downloads a file using concurrent chunked Range requests.
-func (d *Downloader) downloadFileChunked(ctx context.Context, url string, outputPath string, contentLength int64) error {
+func (d *Downloader) downloadFileChunked(ctx context.Context, src *mediaURL, outputPath string, contentLength int64) error {
 	numThreads := d.config.Threads
 	if numThreads < 1 {
 		numThreads = 1
@@ -441,7 +725,7 @@ func (d *Downloader) downloadFileChunked(ctx context.Context, url string, output
 	chunkSize := contentLength / int64(numThreads)
 	if chunkSize < 1024*1024 {
 		// File too small for chunking; fall back to single-threaded.
-		return d.downloadFileSingle(ctx, url, outputPath)
+		return d.downloadFileSingle(ctx, src, outputPath)
 	}
 
 	// Create the output file and pre-allocate it.
@@ -451,13 +735,14 @@ func (d *Downloader) downloadFileChunked(ctx context.Context, url string, output
 	}
 	defer file.Close()
 
-	if err := file.Truncate(contentLength); err != nil {
-		return fmt.Errorf("failed to pre-allocate file: %w", err)
+	if err := d.allocate(file, contentLength); err != nil {
+		return err
 	}
 
 	// Download chunks concurrently.
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	progress := newProgressCounter(contentLength, progressFromContext(ctx))
 
 	var wg sync.WaitGroup
 	errs := make(chan error, numThreads)
@@ -472,7 +757,7 @@ func (d *Downloader) downloadFileChunked(ctx context.Context, url string, output
 		wg.Add(1)
 		go func(chunkStart, chunkEnd int64) {
 			defer wg.Done()
-			if err := d.downloadChunk(ctx, url, file, chunkStart, chunkEnd); err != nil {
+			if err := d.downloadChunk(ctx, src, file, chunkStart, chunkEnd, progress); err != nil {
 				errs <- fmt.Errorf("chunk %d-%d: %w", chunkStart, chunkEnd, err)
 				cancel()
 			}
@@ -493,92 +778,75 @@ func (d *Downloader) downloadFileChunked(ctx context.Context, url string, output
 	return nil
 }
 
-// downloadChunk downloads a single byte range to the file at the given offset.
-func (d *Downloader) downloadChunk(ctx context.Context, url string, file *os.File, start, end int64) error {
+// downloadChunk downloads a single byte range to the file at the given
+// offset, counting the bytes in progress. When the URL expires, the range
+// is fetched again from a fresh one.
+func (d *Downloader) downloadChunk(ctx context.Context, src *mediaURL, file *os.File, start, end int64, progress *progressCounter) error {
 	cfg := defaultRetryConfig()
 
-	return retry(ctx, cfg, func() (int, error) {
-		var req *http.Request
-		var reqErr error
-
-		if d.config.AuthManager != nil {
-			if authManager, ok := d.config.AuthManager.(interface {
-				CreateAuthenticatedRequest(method, url string, body io.Reader) (*http.Request, error)
-			}); ok {
-				req, reqErr = authManager.CreateAuthenticatedRequest("GET", url, nil)
+	for {
+		url, gen := src.get()
+		err := retry(ctx, cfg, func() (int, error) {
+			req, err := d.newMediaRequest(ctx, "GET", url)
+			if err != nil {
+				return 0, err
 			}
-		}
-		if req == nil && reqErr == nil {
-			req, reqErr = http.NewRequestWithContext(ctx, "GET", url, nil)
-		}
-		if reqErr != nil {
-			return 0, fmt.Errorf("failed to create request: %w", reqErr)
-		}
-
-		req = req.WithContext(ctx)
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-		resp, err := d.client.Do(req)
-		if err != nil {
-			return 0, err
-		}
-		defer resp.Body.Close()
+			resp, err := d.client.Do(req)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-			return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
-		}
+			if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+				return resp.StatusCode, mediaStatusError(resp)
+			}
 
-		// Read the chunk into memory.
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return 0, fmt.Errorf("failed to read chunk: %w", err)
-		}
+			// Write the chunk at its offset as it arrives.
+			if _, err := d.copy(io.NewOffsetWriter(file, start), progress.reader(io.LimitReader(resp.Body, end-start+1))); err != nil {
+				return 0, fmt.Errorf("failed to write chunk at offset %d: %w", start, err)
+			}
 
-		// Write chunk at the correct offset.
-		if _, err := file.WriteAt(data, start); err != nil {
-			return 0, fmt.Errorf("failed to write chunk at offset %d: %w", start, err)
+			return resp.StatusCode, nil
+		})
+		if errors.Is(err, ErrURLExpired) && ctx.Err() == nil {
+			if err := src.renew(ctx, gen, d.logger); err != nil {
+				return err
+			}
+			continue
 		}
-
-		return resp.StatusCode, nil
-	})
+		return err
+	}
 }
 
-// mergeVideoAndAudio merges video and audio files using ffmpeg
-func (d *Downloader) mergeVideoAndAudio(videoPath, audioPath, outputPath string) error {
+// mergeVideoAndAudio merges video and audio files using ffmpeg, or MP4Box
+// when ffmpeg is unavailable or fails. If neither muxer works, the separate
+// streams are kept and an error is returned rather than silently producing
+// a file without audio.
+func (d *Downloader) mergeVideoAndAudio(ctx context.Context, videoPath, audioPath, outputPath string) error {
+	if err := enterMerge(ctx); err != nil {
+		return err
+	}
 	d.logger.Info("Merging video and audio...")
 
-	// Check if ffmpeg is available
-	if !d.isFFmpegAvailable() {
-		d.logger.Warn("ffmpeg not found, copying video file only (no audio)")
-		// Fallback: just copy the video file
-		return d.copyFile(videoPath, outputPath)
+	var err error
+	switch {
+	case d.isFFmpegAvailable():
+		err = d.mergeWithFFmpeg(ctx, videoPath, audioPath, outputPath)
+		if err != nil && ctx.Err() == nil && d.isMP4BoxAvailable() {
+			d.logger.Warnf("ffmpeg failed (%v), retrying with MP4Box", err)
+			err = d.mergeWithMP4Box(ctx, videoPath, audioPath, outputPath)
+		}
+	case d.isMP4BoxAvailable():
+		d.logger.Info("ffmpeg not found, merging with MP4Box")
+		err = d.mergeWithMP4Box(ctx, videoPath, audioPath, outputPath)
+	default:
+		return fmt.Errorf("%w; the separate streams were kept at %s and %s", ErrNoMuxer, videoPath, audioPath)
 	}
-
-	// Use ffmpeg to merge video and audio
-	cmd := exec.Command("ffmpeg",
-		"-i", videoPath, // Input video
-		"-i", audioPath, // Input audio
-		"-c:v", "copy", // Copy video stream without re-encoding
-		"-c:a", "aac", // Encode audio to AAC
-		"-map", "0:v:0", // Map video from first input
-		"-map", "1:a:0", // Map audio from second input
-		"-y",       // Overwrite output file
-		outputPath, // Output file
-	)
-
-	// Set up command output
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
-
-	// Execute ffmpeg command
-	err := cmd.Run()
 	if err != nil {
-		d.logger.Errorf("ffmpeg failed: %v", err)
-		// Fallback: just copy the video file
-		d.logger.Warn("Falling back to video-only output")
-		return d.copyFile(videoPath, outputPath)
+		return fmt.Errorf("failed to merge video and audio: %w (the separate streams were kept at %s and %s)",
+			err, videoPath, audioPath)
 	}
 
 	// Clean up temporary files
@@ -593,34 +861,41 @@ func (d *Downloader) mergeVideoAndAudio(videoPath, audioPath, outputPath string)
 	return nil
 }
 
-// isFFmpegAvailable checks if ffmpeg is available in the system
-func (d *Downloader) isFFmpegAvailable() bool {
-	_, err := exec.LookPath("ffmpeg")
-	return err == nil
-}
+// mergeWithFFmpeg muxes the two files with ffmpeg.
+func (d *Downloader) mergeWithFFmpeg(ctx context.Context, videoPath, audioPath, outputPath string) error {
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), mergeArgs(videoPath, audioPath, outputPath)...)
 
-// copyFile copies a file from src to dst
-func (d *Downloader) copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %w", err)
-	}
-	defer srcFile.Close()
+	// Set up command output
+	cmd.Stdout, cmd.Stderr = d.console()
 
-	dstFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
-	}
-	defer dstFile.Close()
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
 
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+	if err := d.runLimited(ctx, cmd); err != nil {
+		return &MergeError{Tool: "ffmpeg", Err: err}
 	}
-
 	return nil
 }
 
+// mergeArgs returns the ffmpeg arguments that mux videoIn and audioIn
+// (file paths or pipe: URLs) into outputPath.
+func mergeArgs(videoIn, audioIn, outputPath string) []string {
+	// Matroska carries the original AAC, FLAC or E-AC-3 streams as they are.
+	audioCodec := "aac"
+	if isMKV(outputPath) {
+		audioCodec = "copy"
+	}
+	return []string{
+		"-i", videoIn, // Input video
+		"-i", audioIn, // Input audio
+		"-c:v", "copy", // Copy video stream without re-encoding
+		"-c:a", audioCodec, // Encode audio to AAC
+		"-map", "0:v:0", // Map video from first input
+		"-map", "1:a:0", // Map audio from second input
+		"-y",       // Overwrite output file
+		outputPath, // Output file
+	}
+}
+
 // DownloadWithProgress downloads a file with progress reporting
 func (d *Downloader) DownloadWithProgress(ctx context.Context, url, outputPath string, progressChan chan<- DownloadProgress) error {
 	// Create the output file
@@ -667,10 +942,11 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url, outputPath s
 			Reader:   resp.Body,
 			Total:    totalSize,
 			Progress: progressChan,
+			Console:  d.config.Console,
 		}
 
 		// Copy with progress
-		if _, err := io.Copy(file, progressReader); err != nil {
+		if _, err := d.copy(file, progressReader); err != nil {
 			return 0, fmt.Errorf("failed to write file: %w", err)
 		}
 
@@ -682,63 +958,66 @@ func (d *Downloader) DownloadWithProgress(ctx context.Context, url, outputPath s
 type ProgressReader struct {
 	Reader    io.Reader
 	Total     int64
+	Offset    int64 // bytes already downloaded before Reader, e.g. when resuming
 	Progress  chan<- DownloadProgress
+	Console   io.Writer // where the progress line is shown; nil for stdout
 	ReadBytes int64
 	startTime time.Time
-	lastTime  time.Time
-	lastBytes int64
+	lastEmit  time.Time
+	speed     speedEstimator
+
+	// OnProgress, when set, receives the same updates as Progress.
+	OnProgress ProgressFunc
 }
 
 func (pr *ProgressReader) Read(p []byte) (n int, err error) {
+	if pr.startTime.IsZero() {
+		// Anchor the estimator before the first read so its bytes are
+		// attributed to the time actually spent reading them.
+		pr.startTime = time.Now()
+		pr.lastEmit = pr.startTime
+		pr.speed.add(pr.startTime, 0)
+	}
+
 	n, err = pr.Reader.Read(p)
 	pr.ReadBytes += int64(n)
 
 	now := time.Now()
-	if pr.startTime.IsZero() {
-		pr.startTime = now
-		pr.lastTime = now
-	}
+	pr.speed.add(now, pr.ReadBytes)
 
 	// Emit progress periodically: every 500ms or on completion/error.
-	sinceLast := now.Sub(pr.lastTime)
-	if sinceLast >= 500*time.Millisecond || err != nil {
+	if now.Sub(pr.lastEmit) >= progressInterval || err != nil {
+		pr.lastEmit = now
+
+		// Speed counts this reader's bytes only; the offset was not timed.
+		downloaded := pr.Offset + pr.ReadBytes
 		progress := DownloadProgress{
 			TotalSize:  pr.Total,
-			Downloaded: pr.ReadBytes,
+			Downloaded: downloaded,
+			Speed:      int64(pr.speed.rate()),
 		}
-
 		if pr.Total > 0 {
-			progress.Percentage = float64(pr.ReadBytes) / float64(pr.Total) * 100
+			progress.Percentage = float64(downloaded) / float64(pr.Total) * 100
+			progress.ETA = estimateETA(pr.Total-downloaded, pr.speed.rate())
 		}
 
-		// Windowed speed (bytes since last emission / time since last emission).
-		bytesDelta := pr.ReadBytes - pr.lastBytes
-		timeDelta := now.Sub(pr.lastTime)
-		if timeDelta > 0 {
-			progress.Speed = int64(float64(bytesDelta) / timeDelta.Seconds())
-		}
-
-		// ETA based on windowed speed.
-		if progress.Speed > 0 && pr.Total > 0 {
-			remaining := pr.Total - pr.ReadBytes
-			progress.ETA = time.Duration(remaining/progress.Speed) * time.Second
-		}
-
-		pr.lastTime = now
-		pr.lastBytes = pr.ReadBytes
-
 		// Print progress to stdout for basic progress display.
 		// (A proper progress bar library replaces this in a follow-up.)
+		console := pr.Console
+		if console == nil {
+			console = os.Stdout
+		}
 		if pr.Total > 0 {
-			fmt.Printf("\rDownloading: %.1f%% (%.2f/%.2f MB) %s/s",
+			fmt.Fprint(console, i18n.Sprintf("\rDownloading: %.1f%% (%.2f/%.2f MB) %s/s ETA %s",
 				progress.Percentage,
-				float64(pr.ReadBytes)/(1024*1024),
+				float64(downloaded)/(1024*1024),
 				float64(pr.Total)/(1024*1024),
-				formatSpeed(progress.Speed))
+				formatSpeed(progress.Speed),
+				formatETA(progress.ETA)))
 		} else {
-			fmt.Printf("\rDownloading: %.2f MB %s/s",
-				float64(pr.ReadBytes)/(1024*1024),
-				formatSpeed(progress.Speed))
+			fmt.Fprint(console, i18n.Sprintf("\rDownloading: %.2f MB %s/s",
+				float64(downloaded)/(1024*1024),
+				formatSpeed(progress.Speed)))
 		}
 
 		if pr.Progress != nil {
@@ -747,6 +1026,9 @@ func (pr *ProgressReader) Read(p []byte) (n int, err error) {
 			default:
 			}
 		}
+		if pr.OnProgress != nil {
+			pr.OnProgress(progress)
+		}
 	}
 
 	return n, err
@@ -2,10 +2,16 @@ package downloader
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dengmengmian/goBili/parser"
 )
@@ -36,12 +42,27 @@ func TestSanitizeFilename(t *testing.T) {
 		{"leading trailing stripped", "  hello  ", "hello"},
 		{"dots at edges", "...hello...", "hello"},
 		// Truncation
-		{"very long name", strings.Repeat("a", 300), strings.Repeat("a", 200)},
+		{"very long name", strings.Repeat("a", 300), strings.Repeat("a", 160)},
 		// Unicode
 		{"chinese title", "测试视频", "测试视频"},
 		{"mixed unicode", "テスト動画", "テスト動画"},
 		// Null byte (should be replaced)
 		{"null byte", "video\x00name", "video_name"},
+		// CJK titles are cut by width and bytes, never inside a rune
+		{"long chinese title", strings.Repeat("测", 100), strings.Repeat("测", 66)},
+		{"wide title by width", strings.Repeat("ｗ", 150), strings.Repeat("ｗ", 66)},
+		{"truncation exposes trailing space", strings.Repeat("a", 159) + " b", strings.Repeat("a", 159)},
+		// Windows device names
+		{"reserved name", "CON", "CON_"},
+		{"reserved name with extension", "nul.txt", "nul_.txt"},
+		{"reserved name with trailing space", "Aux .tar", "Aux _.tar"},
+		{"reserved prefix is fine", "CONSOLE", "CONSOLE"},
+		{"com port", "com1", "com1_"},
+		// Unicode normalization and invisible characters
+		{"decomposed accent to NFC", "Cafe\u0301", "Caf\u00e9"},
+		{"ideographic space", "第一集\u3000开始", "第一集 开始"},
+		{"zero width space dropped", "a\u200bb", "ab"},
+		{"tab becomes space", "a\tb", "a b"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -53,6 +74,23 @@ func TestSanitizeFilename(t *testing.T) {
 	}
 }
 
+func TestSanitizeFilename_Limits(t *testing.T) {
+	for _, input := range []string{
+		strings.Repeat("测试", 200),
+		strings.Repeat("a测", 200),
+		strings.Repeat("😀", 100),
+		strings.Repeat("e\u0301", 200),
+	} {
+		got := sanitizeFilename(input)
+		if !utf8.ValidString(got) {
+			t.Errorf("sanitizeFilename(%.20q...) produced invalid UTF-8", input)
+		}
+		if len(got) > maxFilenameBytes {
+			t.Errorf("sanitizeFilename(%.20q...) is %d bytes, want at most %d", input, len(got), maxFilenameBytes)
+		}
+	}
+}
+
 func TestGenerateFilename(t *testing.T) {
 	d := &Downloader{
 		config: Config{Format: "mp4"},
@@ -82,6 +120,16 @@ func TestGenerateFilename(t *testing.T) {
 	if got != want {
 		t.Errorf("generateFilename() = %q, want %q", got, want)
 	}
+
+	// Media server naming drops the suffix.
+	d.config.NoQualitySuffix = true
+	stream.Quality = 80
+	info.Title = "Show - S01E05 - Title"
+	got = d.generateFilename(info, stream)
+	want = "Show - S01E05 - Title.mp4"
+	if got != want {
+		t.Errorf("generateFilename() = %q, want %q", got, want)
+	}
 }
 
 func TestSelectStream(t *testing.T) {
@@ -276,3 +324,60 @@ func TestDownloadProgress(t *testing.T) {
 		t.Errorf("Percentage = %f", dp.Percentage)
 	}
 }
+
+func TestDownloadVideoResult_NoAudio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	d := NewDownloader(Config{OutputDir: dir, Format: "mp4", Quality: "best"})
+	info := &parser.VideoInfo{BVID: "BV1", Title: "silent"}
+	streams := []*parser.StreamInfo{{Quality: 80, VideoURL: server.URL + "/v.m4s"}}
+
+	result, err := d.DownloadVideoResult(context.Background(), info, streams)
+	if err != nil {
+		t.Fatalf("DownloadVideoResult: %v", err)
+	}
+	if !result.NoAudio {
+		t.Error("NoAudio should be set for a stream without audio")
+	}
+	if data, err := os.ReadFile(result.Path); err != nil || string(data) != "video" {
+		t.Errorf("output = %q, %v; want the video stream", data, err)
+	}
+
+	// Audio-only downloads of such a video fail clearly.
+	d = NewDownloader(Config{OutputDir: dir, Format: "mp4", Quality: "best", AudioOnly: true})
+	if _, err := d.DownloadVideoResult(context.Background(), info, streams); !errors.Is(err, ErrNoAudio) {
+		t.Errorf("audio-only error = %v, want ErrNoAudio", err)
+	}
+}
+
+// headerSigner is a RequestSigner setting one header.
+type headerSigner struct{ name, value string }
+
+func (s headerSigner) SignRequest(req *http.Request) { req.Header.Set(s.name, s.value) }
+
+func TestNewMediaRequest_Signer(t *testing.T) {
+	d := &Downloader{config: Config{AuthManager: headerSigner{"Cookie", "SESSDATA=s"}}}
+	req, err := d.newMediaRequest(context.Background(), "GET", "https://upos.example/v.m4s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Cookie"); got != "SESSDATA=s" {
+		t.Errorf("Cookie = %q, want the signer's", got)
+	}
+
+	// Without an auth manager the CDN still gets the browser headers.
+	d = &Downloader{}
+	if req, err = d.newMediaRequest(context.Background(), "GET", "https://upos.example/v.m4s"); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Referer") == "" || req.Header.Get("User-Agent") == "" {
+		t.Errorf("guest request headers = %v, want Referer and User-Agent", req.Header)
+	}
+	if strings.Contains(req.Header.Get("Cookie"), "SESSDATA") {
+		t.Errorf("guest request carries a login: %q", req.Header.Get("Cookie"))
+	}
+}
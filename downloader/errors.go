@@ -3,16 +3,22 @@ package downloader
 import (
 	"errors"
 	"fmt"
+
+	"github.com/dengmengmian/goBili/i18n"
 )
 
-// Sentinel errors for common failure modes.
+// Sentinel errors for common failure modes. Their messages are translated
+// when they are read, after the language has been chosen.
 var (
-	ErrAuthRequired   = errors.New("authentication required: please login first using 'goBili login'")
-	ErrNetworkTimeout = errors.New("network timeout: check your internet connection and try again")
-	ErrServerError    = errors.New("server error: the remote server is temporarily unavailable, try again later")
-	ErrDiskFull       = errors.New("disk full or write permission denied: check available space and permissions")
-	ErrInvalidURL     = errors.New("invalid URL: the provided Bilibili URL could not be parsed")
-	ErrFileExists     = errors.New("output file already exists: use --force to overwrite")
+	ErrAuthRequired   = i18n.NewError("authentication required: please login first using 'goBili login'")
+	ErrNetworkTimeout = i18n.NewError("network timeout: check your internet connection and try again")
+	ErrServerError    = i18n.NewError("server error: the remote server is temporarily unavailable, try again later")
+	ErrDiskFull       = i18n.NewError("disk full or write permission denied: check available space and permissions")
+	ErrInvalidURL     = i18n.NewError("invalid URL: the provided Bilibili URL could not be parsed")
+	ErrFileExists     = i18n.NewError("output file already exists: use --force-overwrite to replace it")
+	ErrNoAudio        = i18n.NewError("no audio stream available for this video")
+	ErrURLExpired     = i18n.NewError("stream URL expired or was rejected by the CDN")
+	ErrNoMuxer        = i18n.NewError("no muxer available: install ffmpeg or MP4Box, or point --ffmpeg-path/--mp4box-path at them")
 )
 
 // DownloadError wraps an error with a user-friendly message and a suggested action.
@@ -24,9 +30,9 @@ type DownloadError struct {
 }
 
 func (e *DownloadError) Error() string {
-	msg := fmt.Sprintf("%s failed", e.Op)
+	msg := i18n.Sprintf("%s failed", e.Op)
 	if e.URL != "" {
-		msg += fmt.Sprintf(" for %s", e.URL)
+		msg += i18n.Sprintf(" for %s", e.URL)
 	}
 	if e.Err != nil {
 		msg += fmt.Sprintf(": %v", e.Err)
@@ -55,8 +61,49 @@ func NewDownloadError(op, url string, err error, action string) *DownloadError {
 // These errors should not show stack traces or debug info.
 func UserError(msg string) error {
 	return &DownloadError{
-		Op:     "user input",
+		Op:     i18n.T("user input"),
 		Err:    errors.New(msg),
-		Action: "Check the command syntax with 'goBili help'",
+		Action: i18n.T("Check the command syntax with 'goBili help'"),
+	}
+}
+
+// StreamNotFoundError is returned when a video has no stream for the
+// requested quality or --format-id.
+type StreamNotFoundError struct {
+	FormatID  string   // the video or audio format asked for; empty when selecting by quality
+	Audio     bool     // FormatID names an audio track
+	Quality   string   // the quality asked for, when missing with the abort fallback
+	Available []string // format IDs of the video's streams
+}
+
+func (e *StreamNotFoundError) Error() string {
+	switch {
+	case e.Quality != "":
+		return fmt.Sprintf("quality %s is not available for this video (list them with 'goBili formats', or change --quality-fallback)", e.Quality)
+	case e.FormatID == "":
+		return "no suitable stream found"
+	case e.Audio:
+		return fmt.Sprintf("audio format %s is not available for this video (list them with 'goBili formats')", e.FormatID)
 	}
+	return fmt.Sprintf("format %s is not available for this video (list them with 'goBili formats')", e.FormatID)
+}
+
+// MergeError is returned when the muxer fails to merge the video and audio
+// of a download.
+type MergeError struct {
+	Tool   string // "ffmpeg" or "MP4Box"
+	Err    error
+	Stderr string // the last lines the tool printed, when captured
+}
+
+func (e *MergeError) Error() string {
+	msg := fmt.Sprintf("%s failed: %v", e.Tool, e.Err)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+func (e *MergeError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,70 @@
+package downloader
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExistingPolicy controls what happens when an output file already exists.
+type ExistingPolicy string
+
+// Supported policies for existing output files.
+const (
+	// ExistingRename keeps the existing file and writes to "name (1).ext",
+	// "name (2).ext", and so on.
+	ExistingRename ExistingPolicy = "rename"
+	// ExistingSkip leaves the existing file untouched and skips the download.
+	ExistingSkip ExistingPolicy = "skip"
+	// ExistingOverwrite truncates and replaces the existing file. This is
+	// the default.
+	ExistingOverwrite ExistingPolicy = "overwrite"
+)
+
+// maxRenameAttempts bounds the " (N)" suffix search.
+const maxRenameAttempts = 1000
+
+// resolveOutputPath applies the configured ExistingPolicy to path.
+// It returns the path to write to and whether the download should be skipped.
+func (d *Downloader) resolveOutputPath(path string) (string, bool, error) {
+	if !fileExists(path) {
+		return path, false, nil
+	}
+
+	switch d.config.Existing {
+	case ExistingSkip:
+		return path, true, nil
+	case ExistingOverwrite, "":
+		return path, false, nil
+	case ExistingRename:
+		renamed, err := nextAvailableName(path)
+		if err != nil {
+			return "", false, err
+		}
+		return renamed, false, nil
+	default:
+		return "", false, fmt.Errorf("unknown existing-file policy: %q", d.config.Existing)
+	}
+}
+
+// nextAvailableName returns the first "name (N).ext" variant of path that
+// does not exist yet.
+func nextAvailableName(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	for i := 1; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !fileExists(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free name for %s after %d attempts", ErrFileExists, path, maxRenameAttempts)
+}
+
+// fileExists reports whether path exists (regardless of its type).
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
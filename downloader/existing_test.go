@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(existing, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "new.mp4")
+
+	tests := []struct {
+		name     string
+		policy   ExistingPolicy
+		path     string
+		wantPath string
+		wantSkip bool
+	}{
+		{"missing file untouched", ExistingSkip, missing, missing, false},
+		{"skip existing", ExistingSkip, existing, existing, true},
+		{"overwrite existing", ExistingOverwrite, existing, existing, false},
+		{"rename existing", ExistingRename, existing, filepath.Join(dir, "video (1).mp4"), false},
+		{"default is overwrite", "", existing, existing, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Downloader{config: Config{Existing: tt.policy}}
+			got, skip, err := d.resolveOutputPath(tt.path)
+			if err != nil {
+				t.Fatalf("resolveOutputPath error: %v", err)
+			}
+			if got != tt.wantPath || skip != tt.wantSkip {
+				t.Errorf("resolveOutputPath(%q) = (%q, %v), want (%q, %v)", tt.path, got, skip, tt.wantPath, tt.wantSkip)
+			}
+		})
+	}
+
+	d := &Downloader{config: Config{Existing: "bogus"}}
+	if _, _, err := d.resolveOutputPath(existing); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestNextAvailableName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.m4a", "a (1).m4a", "a (2).m4a"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := nextAvailableName(filepath.Join(dir, "a.m4a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "a (3).m4a"); got != want {
+		t.Errorf("nextAvailableName = %q, want %q", got, want)
+	}
+}
+
+func TestFinalOutputPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"merged keeps format", Config{}, "out/v.flv"},
+		{"audio only", Config{AudioOnly: true}, "out/v.m4a"},
+		{"video only", Config{VideoOnly: true}, "out/v.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Downloader{config: tt.config}
+			if got := d.finalOutputPath("out/v.flv"); got != tt.want {
+				t.Errorf("finalOutputPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package downloader
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+	"golang.org/x/text/width"
+)
+
+// Limits of a sanitized filename. Most file systems allow 255 bytes per
+// name; the rest is left for the quality suffix, the extension, sidecar
+// suffixes and temporary-file decorations. The width keeps long Latin
+// titles readable in file managers; CJK titles, at two columns but three
+// bytes per character, hit the byte limit first.
+const (
+	maxFilenameBytes = 200
+	maxFilenameWidth = 160
+)
+
+// windowsReserved are the device names Windows refuses as file names, with
+// or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename cleans a string to be a safe filename component on
+// Linux, macOS and Windows. It normalizes to NFC (so macOS-decomposed
+// titles match), replaces path separators and characters Windows rejects,
+// turns any whitespace into a space, drops control and invisible format
+// characters, and truncates on rune boundaries by display width and byte
+// length. Leading and trailing spaces and dots are stripped, Windows
+// device names are escaped, and the result is never empty, "." or "..".
+func sanitizeFilename(name string) string {
+	clean := strings.Map(func(r rune) rune {
+		switch {
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.Is(unicode.Cf, r):
+			return -1 // Zero-width and direction marks.
+		case unicode.IsControl(r) || !unicode.IsPrint(r):
+			return '_'
+		}
+		return r
+	}, norm.NFC.String(name))
+
+	clean = truncateFilename(strings.Trim(clean, " ."))
+	// Truncation can expose new trailing spaces or dots.
+	clean = strings.Trim(clean, " .")
+
+	if clean == "" {
+		return "video"
+	}
+
+	// "CON", "con.mp4" or "Nul .txt" would open a device on Windows.
+	stem, rest, _ := strings.Cut(clean, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		clean = stem + "_"
+		if rest != "" {
+			clean += "." + rest
+		}
+	}
+	return clean
+}
+
+// SanitizeFilename is sanitizeFilename for files named outside the
+// downloader, such as standalone danmaku.
+func SanitizeFilename(name string) string {
+	return sanitizeFilename(name)
+}
+
+// truncateFilename cuts s to maxFilenameWidth columns and maxFilenameBytes
+// bytes without splitting a rune.
+func truncateFilename(s string) string {
+	cols, size := 0, 0
+	for i, r := range s {
+		cols += RuneWidth(r)
+		size = i + utf8.RuneLen(r)
+		if cols > maxFilenameWidth || size > maxFilenameBytes {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// RuneWidth returns the display columns of r: two for wide East Asian
+// characters, none for combining marks, one otherwise.
+func RuneWidth(r rune) int {
+	if unicode.Is(unicode.Mn, r) {
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
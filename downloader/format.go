@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// formatIDRegex matches a --format-id value: a video format such as
+// "80-hevc", optionally followed by "+" and an audio track ID.
+var formatIDRegex = regexp.MustCompile(`^\d+-[a-z0-9]+(\+\d+)?$`)
+
+// ValidateFormatID checks a --format-id value, e.g. "80-hevc" or
+// "80-hevc+30280". The IDs are those listed by 'goBili formats'.
+func ValidateFormatID(id string) error {
+	if id != "" && !formatIDRegex.MatchString(id) {
+		return fmt.Errorf("invalid format id %q (want VIDEO or VIDEO+AUDIO, e.g. 80-hevc+30280)", id)
+	}
+	return nil
+}
+
+// Video codecs for Config.Codec, as named by parser.CodecFamily.
+const (
+	CodecAVC  = "avc"
+	CodecHEVC = "hevc"
+	CodecAV1  = "av1"
+)
+
+// ValidateCodec checks a --codec value.
+func ValidateCodec(codec string) error {
+	switch codec {
+	case "", CodecAVC, CodecHEVC, CodecAV1:
+		return nil
+	}
+	return fmt.Errorf("unsupported codec %q (want %s, %s or %s)", codec, CodecAVC, CodecHEVC, CodecAV1)
+}
+
+// preferCodec returns the stream of the quality of stream in codec, or
+// stream itself when that quality is not served in codec.
+func preferCodec(streams []*parser.StreamInfo, stream *parser.StreamInfo, codec string) *parser.StreamInfo {
+	for _, s := range streams {
+		if s.Quality == stream.Quality && parser.CodecFamily(s.VideoCodecs) == codec {
+			return s
+		}
+	}
+	return stream
+}
+
+// splitFormatID splits a --format-id value into its video and audio IDs.
+func splitFormatID(id string) (video, audio string) {
+	video, audio, _ = strings.Cut(id, "+")
+	return video, audio
+}
+
+// pickStream selects the stream to download: the one named by FormatID,
+// or else the one selectStream picks for Quality.
+func (d *Downloader) pickStream(streams []*parser.StreamInfo) (*parser.StreamInfo, error) {
+	if d.config.FormatID != "" {
+		return selectFormat(streams, d.config.FormatID)
+	}
+	stream := d.selectStream(streams)
+	if stream == nil {
+		notFound := &StreamNotFoundError{Available: formatIDs(streams)}
+		if len(streams) > 0 {
+			notFound.Quality = d.config.Quality
+		}
+		return nil, notFound
+	}
+	return stream, nil
+}
+
+// selectFormat returns the stream with the video format of id, with its
+// audio replaced by the requested track when id names one.
+func selectFormat(streams []*parser.StreamInfo, id string) (*parser.StreamInfo, error) {
+	videoID, audioID := splitFormatID(id)
+
+	var stream *parser.StreamInfo
+	for _, s := range streams {
+		if s.FormatID == videoID {
+			stream = s
+			break
+		}
+	}
+	if stream == nil {
+		return nil, &StreamNotFoundError{FormatID: videoID, Available: formatIDs(streams)}
+	}
+	if audioID == "" {
+		return stream, nil
+	}
+
+	for _, track := range stream.AudioTracks {
+		if strconv.Itoa(track.ID) == audioID && track.URL != "" {
+			selected := *stream
+			selected.AudioURL = track.URL
+			selected.AudioCodecs = track.Codecs
+			return &selected, nil
+		}
+	}
+	var available []string
+	for _, track := range stream.AudioTracks {
+		available = append(available, strconv.Itoa(track.ID))
+	}
+	return nil, &StreamNotFoundError{FormatID: audioID, Audio: true, Available: available}
+}
+
+// formatIDs returns the format IDs of streams.
+func formatIDs(streams []*parser.StreamInfo) []string {
+	ids := make([]string, 0, len(streams))
+	for _, s := range streams {
+		ids = append(ids, s.FormatID)
+	}
+	return ids
+}
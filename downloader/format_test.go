@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/dengmengmian/goBili/quality"
+)
+
+func TestValidateFormatID(t *testing.T) {
+	for _, id := range []string{"", "80-avc", "116-hevc", "80-av1+30280"} {
+		if err := ValidateFormatID(id); err != nil {
+			t.Errorf("ValidateFormatID(%q) = %v", id, err)
+		}
+	}
+	for _, id := range []string{"80", "hevc", "80-hevc+", "80-hevc+aac", "80-hevc+30280+30216"} {
+		if err := ValidateFormatID(id); err == nil {
+			t.Errorf("ValidateFormatID(%q) accepted", id)
+		}
+	}
+}
+
+func TestPickStream_FormatID(t *testing.T) {
+	tracks := []parser.AudioTrack{
+		{ID: 30280, URL: "https://cdn/a-192.m4s", Codecs: "mp4a.40.2"},
+		{ID: 30216, URL: "https://cdn/a-64.m4s", Codecs: "mp4a.40.5"},
+	}
+	streams := []*parser.StreamInfo{
+		{Quality: 80, FormatID: "80-avc", VideoURL: "https://cdn/v-80-avc.m4s", AudioURL: tracks[0].URL, AudioTracks: tracks},
+		{Quality: 80, FormatID: "80-hevc", VideoURL: "https://cdn/v-80-hevc.m4s", AudioURL: tracks[0].URL, AudioTracks: tracks},
+		{Quality: 116, FormatID: "116-hevc", VideoURL: "https://cdn/v-116-hevc.m4s", AudioURL: tracks[0].URL, AudioTracks: tracks},
+	}
+
+	d := &Downloader{config: Config{Quality: "best", FormatID: "116-hevc"}}
+	got, err := d.pickStream(streams)
+	if err != nil || got.VideoURL != "https://cdn/v-116-hevc.m4s" || got.AudioURL != tracks[0].URL {
+		t.Errorf("pickStream(116-hevc) = %+v, %v", got, err)
+	}
+
+	d.config.FormatID = "80-hevc+30216"
+	got, err = d.pickStream(streams)
+	if err != nil || got.VideoURL != "https://cdn/v-80-hevc.m4s" || got.AudioURL != tracks[1].URL || got.AudioCodecs != "mp4a.40.5" {
+		t.Errorf("pickStream(80-hevc+30216) = %+v, %v", got, err)
+	}
+	if streams[1].AudioURL != tracks[0].URL {
+		t.Error("pickStream modified the parsed stream")
+	}
+	// The requested audio track survives audio-only selection.
+	d.config.AudioOnly = true
+	if only := d.audioOnlyStream(got); only.AudioURL != tracks[1].URL {
+		t.Errorf("audioOnlyStream replaced the requested track with %s", only.AudioURL)
+	}
+
+	for _, tt := range []struct {
+		id, missing string
+		audio       bool
+	}{{"64-avc", "64-avc", false}, {"80-avc+30251", "30251", true}} {
+		d.config.FormatID = tt.id
+		_, err := d.pickStream(streams)
+		var notFound *StreamNotFoundError
+		if !errors.As(err, &notFound) || notFound.FormatID != tt.missing || notFound.Audio != tt.audio {
+			t.Errorf("pickStream(%s) error = %v, want a StreamNotFoundError for %s", tt.id, err, tt.missing)
+		}
+	}
+
+	d.config.FormatID = "64-avc"
+	_, err = d.pickStream(streams)
+	var notFound *StreamNotFoundError
+	if errors.As(err, &notFound) && len(notFound.Available) != 3 {
+		t.Errorf("Available = %v, want the three video formats", notFound.Available)
+	}
+}
+
+func TestPickStream_QualityFallback(t *testing.T) {
+	streams := []*parser.StreamInfo{{Quality: 116}, {Quality: 64}, {Quality: 32}}
+
+	d := &Downloader{config: Config{Quality: "1080p", QualityFallback: quality.FallbackNextLower}}
+	if got, err := d.pickStream(streams); err != nil || got.Quality != 64 {
+		t.Errorf("next-lower: pickStream = %+v, %v; want 720p", got, err)
+	}
+
+	d.config.QualityFallback = quality.FallbackAbort
+	_, err := d.pickStream(streams)
+	var notFound *StreamNotFoundError
+	if !errors.As(err, &notFound) || notFound.Quality != "1080p" {
+		t.Errorf("abort: pickStream error = %v, want a StreamNotFoundError for 1080p", err)
+	}
+
+	// Audio-only downloads do not care about the video quality.
+	d.config.AudioOnly = true
+	if got, err := d.pickStream(streams); err != nil || got.Quality != 116 {
+		t.Errorf("abort, audio only: pickStream = %+v, %v", got, err)
+	}
+}
+
+func TestPickStream_Codec(t *testing.T) {
+	streams := []*parser.StreamInfo{
+		{Quality: 116, VideoCodecs: "avc1.640032", VideoURL: "116-avc"},
+		{Quality: 116, VideoCodecs: "hev1.1.6.L150.90", VideoURL: "116-hevc"},
+		{Quality: 80, VideoCodecs: "avc1.640032", VideoURL: "80-avc"},
+		{Quality: 80, VideoCodecs: "av01.0.08M.08", VideoURL: "80-av1"},
+	}
+	for _, tt := range []struct {
+		quality, codec, want string
+	}{
+		{"best", "", "116-avc"},
+		{"best", CodecHEVC, "116-hevc"},
+		{"1080p", CodecAV1, "80-av1"},
+		// 4K is not served in AV1; the quality wins over the codec.
+		{"best", CodecAV1, "116-avc"},
+	} {
+		d := &Downloader{config: Config{Quality: tt.quality, Codec: tt.codec}}
+		got, err := d.pickStream(streams)
+		if err != nil || got.VideoURL != tt.want {
+			t.Errorf("pickStream(%s, %q) = %+v, %v; want %s", tt.quality, tt.codec, got, err, tt.want)
+		}
+	}
+
+	if err := ValidateCodec("h264"); err == nil {
+		t.Error("ValidateCodec accepted h264")
+	}
+}
@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"context"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// StreamURL is a direct media URL together with the request headers the
+// CDN expects (Referer, User-Agent and, when logged in, Cookie).
+type StreamURL struct {
+	Kind    string   `json:"kind"` // "video" or "audio"
+	URL     string   `json:"url"`
+	Headers []string `json:"headers"` // "Name: value", sorted
+}
+
+// ResolveURLs selects a stream the way DownloadVideoResult does and returns
+// the URLs it would download, video first, instead of downloading them.
+func (d *Downloader) ResolveURLs(ctx context.Context, streams []*parser.StreamInfo) ([]StreamURL, *parser.StreamInfo, error) {
+	stream, err := d.pickStream(streams)
+	if err != nil {
+		return nil, nil, err
+	}
+	stream = d.audioOnlyStream(stream)
+
+	var kinds []string
+	switch {
+	case d.config.AudioOnly:
+		if stream.AudioURL == "" {
+			return nil, nil, ErrNoAudio
+		}
+		kinds = []string{"audio"}
+	case d.config.VideoOnly || stream.AudioURL == "":
+		kinds = []string{"video"}
+	default:
+		kinds = []string{"video", "audio"}
+	}
+
+	urls := make([]StreamURL, 0, len(kinds))
+	for _, kind := range kinds {
+		url := stream.VideoURL
+		if kind == "audio" {
+			url = stream.AudioURL
+		}
+		headers, err := d.mediaHeaders(ctx, url)
+		if err != nil {
+			return nil, nil, err
+		}
+		urls = append(urls, StreamURL{Kind: kind, URL: url, Headers: headers})
+	}
+	return urls, stream, nil
+}
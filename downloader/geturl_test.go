@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestResolveURLs(t *testing.T) {
+	streams := []*parser.StreamInfo{
+		{Quality: 64, VideoURL: "https://cdn/720.m4s", AudioURL: "https://cdn/a.m4s"},
+		{Quality: 80, VideoURL: "https://cdn/1080.m4s", AudioURL: "https://cdn/a.m4s"},
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{"dash", Config{Quality: "best"}, []string{"video https://cdn/1080.m4s", "audio https://cdn/a.m4s"}},
+		{"quality", Config{Quality: "720p"}, []string{"video https://cdn/720.m4s", "audio https://cdn/a.m4s"}},
+		{"audio only", Config{Quality: "best", AudioOnly: true}, []string{"audio https://cdn/a.m4s"}},
+		{"video only", Config{Quality: "best", VideoOnly: true}, []string{"video https://cdn/1080.m4s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, _, err := NewDownloader(tt.config).ResolveURLs(context.Background(), streams)
+			if err != nil {
+				t.Fatalf("ResolveURLs: %v", err)
+			}
+			var got []string
+			for _, u := range urls {
+				got = append(got, u.Kind+" "+u.URL)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	silent := []*parser.StreamInfo{{Quality: 80, VideoURL: "https://cdn/v.m4s"}}
+	if _, _, err := NewDownloader(Config{AudioOnly: true}).ResolveURLs(context.Background(), silent); !errors.Is(err, ErrNoAudio) {
+		t.Errorf("audio-only error = %v, want ErrNoAudio", err)
+	}
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// hookPlaceholder is replaced by the quoted output path in hook commands.
+const hookPlaceholder = "{}"
+
+// runHooks runs the configured post-download commands for a finished
+// download. A failing hook is reported but does not fail the download,
+// which is already complete.
+func (d *Downloader) runHooks(ctx context.Context, result *Result, videoInfo *parser.VideoInfo) {
+	for _, command := range d.config.Hooks {
+		if err := d.runHook(ctx, command, result, videoInfo); err != nil {
+			d.logger.Warnf("Post-download command failed: %v", err)
+		}
+	}
+}
+
+// runHook runs one command through the shell. "{}" in command is replaced
+// by the output path; without it, the path is appended. Metadata is
+// passed in GOBILI_* environment variables.
+func (d *Downloader) runHook(ctx context.Context, command string, result *Result, videoInfo *parser.VideoInfo) error {
+	quoted := shellQuote(result.Path)
+	if strings.Contains(command, hookPlaceholder) {
+		command = strings.ReplaceAll(command, hookPlaceholder, quoted)
+	} else {
+		command += " " + quoted
+	}
+
+	d.logger.Debugf("Running post-download command: %s", command)
+	stdout, stderr := d.console()
+	return RunShell(ctx, command, hookEnv(result, videoInfo), stdout, stderr)
+}
+
+// RunShell runs command through the platform's shell with env added to
+// the environment, writing its output to stdout and stderr.
+func RunShell(ctx context.Context, command string, env []string, stdout, stderr io.Writer) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+// hookEnv returns the GOBILI_* environment of a hook.
+func hookEnv(result *Result, videoInfo *parser.VideoInfo) []string {
+	env := []string{
+		"GOBILI_PATH=" + result.Path,
+		"GOBILI_SIZE=" + strconv.FormatInt(result.Size, 10),
+		"GOBILI_QUALITY=" + strconv.Itoa(result.Quality),
+		"GOBILI_BVID=" + videoInfo.BVID,
+		"GOBILI_TITLE=" + videoInfo.Title,
+		"GOBILI_UPLOADER=" + videoInfo.Uploader,
+		"GOBILI_CATEGORY=" + videoInfo.Category,
+		"GOBILI_DURATION=" + strconv.Itoa(videoInfo.Duration),
+	}
+	if result.Uploaded != "" {
+		env = append(env, "GOBILI_REMOTE_URL="+result.Uploaded)
+	}
+	if videoInfo.BVID != "" {
+		env = append(env, "GOBILI_URL="+videoURL(videoInfo.BVID))
+	}
+	if videoInfo.OwnerMID != 0 {
+		env = append(env, "GOBILI_UPLOADER_MID="+strconv.FormatInt(videoInfo.OwnerMID, 10))
+	}
+	if videoInfo.PubDate != 0 {
+		env = append(env, "GOBILI_PUBDATE="+time.Unix(videoInfo.PubDate, 0).Format("2006-01-02"))
+	}
+	return env
+}
+
+// shellQuote quotes s as a single argument for the platform's shell.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
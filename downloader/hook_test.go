@@ -0,0 +1,63 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test use sh syntax")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "hook.out")
+	appended := filepath.Join(dir, "appended.out")
+	d := &Downloader{
+		config: Config{Hooks: []string{
+			`printf '%s|%s|%s' {} "$GOBILI_TITLE" "$GOBILI_URL" > ` + shellQuote(out),
+			`false`, // a failing hook must not stop the others
+			`printf '%s' > ` + shellQuote(appended),
+		}},
+		logger: logrus.New(),
+	}
+	result := &Result{Path: filepath.Join(dir, "it's [1080P].mp4")}
+	info := &parser.VideoInfo{BVID: "BV1qt4y1X7TW", Title: "标题"}
+
+	d.runHooks(context.Background(), result, info)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hook did not run: %v", err)
+	}
+	want := result.Path + "|标题|https://www.bilibili.com/video/BV1qt4y1X7TW"
+	if string(data) != want {
+		t.Errorf("hook output = %q, want %q", data, want)
+	}
+	// Without {}, the path is appended as the last argument.
+	if data, err := os.ReadFile(appended); err != nil || string(data) != result.Path {
+		t.Errorf("appended hook output = %q (%v), want the path", data, err)
+	}
+}
+
+func TestRunHooks_Console(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in this test use sh syntax")
+	}
+	var console bytes.Buffer
+	d := &Downloader{
+		config: Config{Hooks: []string{`echo out; echo err >&2; :`}, Console: &console},
+		logger: logrus.New(),
+	}
+	d.runHooks(context.Background(), &Result{Path: "v.mp4"}, &parser.VideoInfo{})
+
+	if got, want := console.String(), "out\nerr\n"; got != want {
+		t.Errorf("console = %q, want the hook's output %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package downloader
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// minHostConns is the smallest per-host limit: a streaming merge reads the
+// video and audio of one video from the same CDN host at the same time.
+const minHostConns = 2
+
+// HostLimiter bounds the concurrent media connections per CDN host. A
+// single limiter is meant to be shared by every Downloader in a process,
+// like ProcessLimiter, so that parallel chunks, episodes and queue jobs
+// together stay below what the CDN tolerates.
+type HostLimiter struct {
+	max int
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// NewHostLimiter returns a limiter allowing max connections per host. It
+// returns nil (no limit) when max <= 0; smaller positive values are raised
+// to 2.
+func NewHostLimiter(max int) *HostLimiter {
+	if max <= 0 {
+		return nil
+	}
+	if max < minHostConns {
+		max = minHostConns
+	}
+	return &HostLimiter{max: max, hosts: map[string]chan struct{}{}}
+}
+
+// slots returns the semaphore of host.
+func (l *HostLimiter) slots(host string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.hosts[host]
+	if !ok {
+		s = make(chan struct{}, l.max)
+		l.hosts[host] = s
+	}
+	return s
+}
+
+// Transport wraps base so that each request holds a slot of its host until
+// its response body is closed. A nil limiter returns base unchanged.
+func (l *HostLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &hostLimitedTransport{base: base, limiter: l}
+}
+
+type hostLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *HostLimiter
+}
+
+func (t *hostLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	slots := t.limiter.slots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := sync.OnceFunc(func() { <-slots })
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody frees the host slot of its response when closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
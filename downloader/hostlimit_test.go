@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/ratelimit"
+)
+
+func TestHostLimiter(t *testing.T) {
+	var active, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1) // Before the client can see the response.
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	if NewHostLimiter(0) != nil {
+		t.Error("NewHostLimiter(0) should mean no limit")
+	}
+	client := &http.Client{Transport: NewHostLimiter(2).Transport(server.Client().Transport)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("peak concurrent connections = %d, want at most 2", peak)
+	}
+}
+
+func TestNewDownloader_RateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	d := NewDownloader(Config{RateLimiter: ratelimit.New(0, 25), HostLimiter: NewHostLimiter(2)})
+	start := time.Now()
+	for i := 0; i < 27; i++ {
+		req, err := d.newMediaRequest(context.Background(), "GET", server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := d.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// 25 go out in the burst, the last two 40ms apart.
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("27 media requests at 25/s took %v, want at least 80ms", elapsed)
+	}
+}
@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ProcessLimiter bounds how many external muxer processes (ffmpeg, MP4Box)
+// run at once. A single limiter is meant to be shared by every Downloader in
+// a process, e.g. all jobs of the queue daemon, so that background archiving
+// cannot saturate the machine.
+type ProcessLimiter struct {
+	slots chan struct{}
+}
+
+// NewProcessLimiter returns a limiter allowing max concurrent processes.
+// It returns nil (no limit) when max <= 0.
+func NewProcessLimiter(max int) *ProcessLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &ProcessLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire blocks until a slot is free or ctx is done. A nil limiter never blocks.
+func (l *ProcessLimiter) acquire(ctx context.Context) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// IOClass is a Linux I/O scheduling class for external processes.
+type IOClass string
+
+// Supported I/O classes. Realtime is deliberately omitted: it needs root and
+// can starve the rest of the system.
+const (
+	IOClassDefault    IOClass = ""
+	IOClassBestEffort IOClass = "best-effort"
+	IOClassIdle       IOClass = "idle"
+)
+
+// ParseIOClass validates an I/O class name from the command line or config.
+func ParseIOClass(s string) (IOClass, error) {
+	switch c := IOClass(strings.ToLower(strings.TrimSpace(s))); c {
+	case IOClassDefault, IOClassBestEffort, IOClassIdle:
+		return c, nil
+	default:
+		return "", fmt.Errorf("invalid I/O class %q (want best-effort or idle)", s)
+	}
+}
+
+// ResourceLimits lowers the scheduling priority of muxer processes started
+// for a job. The zero value leaves priorities unchanged.
+type ResourceLimits struct {
+	Nice    int     // CPU nice increment (0-19); 0 keeps the current priority
+	IOClass IOClass // Linux I/O scheduling class
+	IOLevel int     // Priority within the best-effort class (0 highest - 7 lowest)
+}
+
+// runLimited runs cmd under the process limiter and resource limits.
+func (d *Downloader) runLimited(ctx context.Context, cmd *exec.Cmd) error {
+	release, err := d.startLimited(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return cmd.Wait()
+}
+
+// startLimited waits for a limiter slot, starts cmd, and applies the
+// configured resource limits to the new process. The caller must Wait for
+// cmd and then call release.
+func (d *Downloader) startLimited(ctx context.Context, cmd *exec.Cmd) (release func(), err error) {
+	release, err = d.config.ProcessLimiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		release()
+		return nil, err
+	}
+	if err := applyResourceLimits(cmd.Process.Pid, d.config.Limits); err != nil {
+		// Limits are best effort; the job still runs at normal priority.
+		d.logger.Warnf("Failed to apply resource limits to %s: %v", cmd.Path, err)
+	}
+	return release, nil
+}
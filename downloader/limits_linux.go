@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Linux ioprio_set(2) constants.
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassShift  = 13
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	ioprioMaxBELevel  = 7
+	ioprioDefaultBELv = 4
+)
+
+// maxNice is the lowest scheduling priority, the highest nice value.
+const maxNice = 19
+
+// applyResourceLimits raises the nice value of pid by limits.Nice, up to
+// maxNice, and sets its I/O priority.
+func applyResourceLimits(pid int, limits ResourceLimits) error {
+	if limits.Nice != 0 {
+		// The raw getpriority(2) syscall returns 20 - nice, in 1..40.
+		prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid)
+		if err != nil {
+			return fmt.Errorf("getpriority: %w", err)
+		}
+		nice := 20 - prio + limits.Nice
+		if nice > maxNice {
+			nice = maxNice
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice); err != nil {
+			return fmt.Errorf("setpriority: %w", err)
+		}
+	}
+
+	var prio int
+	switch limits.IOClass {
+	case IOClassDefault:
+		return nil
+	case IOClassIdle:
+		prio = ioprioClassIdle << ioprioClassShift
+	case IOClassBestEffort:
+		level := limits.IOLevel
+		if level < 0 || level > ioprioMaxBELevel {
+			level = ioprioDefaultBELv
+		}
+		prio = ioprioClassBE<<ioprioClassShift | level
+	default:
+		return fmt.Errorf("unknown I/O class %q", limits.IOClass)
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio)); errno != 0 {
+		return fmt.Errorf("ioprio_set: %w", errno)
+	}
+	return nil
+}
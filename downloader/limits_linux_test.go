@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestApplyResourceLimits_NiceIsRelative(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	pid := cmd.Process.Pid
+
+	nice := func() int {
+		t.Helper()
+		prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, pid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return 20 - prio
+	}
+	start := nice()
+	if start > maxNice-6 {
+		t.Skipf("test process already runs at nice %d", start)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := applyResourceLimits(pid, ResourceLimits{Nice: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := nice(); got != start+6 {
+		t.Errorf("nice after two increments of 3 = %d, want %d", got, start+6)
+	}
+
+	if err := applyResourceLimits(pid, ResourceLimits{Nice: maxNice}); err != nil {
+		t.Fatal(err)
+	}
+	if got := nice(); got != maxNice {
+		t.Errorf("nice = %d, want it clamped to %d", got, maxNice)
+	}
+}
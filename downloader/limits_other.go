@@ -0,0 +1,9 @@
+//go:build !linux
+
+package downloader
+
+// applyResourceLimits is a no-op outside Linux; nice and I/O priorities are
+// only supported there.
+func applyResourceLimits(_ int, _ ResourceLimits) error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewProcessLimiter_Unlimited(t *testing.T) {
+	if l := NewProcessLimiter(0); l != nil {
+		t.Fatalf("NewProcessLimiter(0) = %v, want nil", l)
+	}
+
+	var l *ProcessLimiter
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("nil limiter acquire: %v", err)
+	}
+	release()
+}
+
+func TestProcessLimiter_Blocks(t *testing.T) {
+	l := NewProcessLimiter(1)
+
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acquire error = %v, want deadline exceeded", err)
+	}
+
+	release()
+	release2, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	release2()
+}
+
+func TestParseIOClass(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    IOClass
+		wantErr bool
+	}{
+		{"", IOClassDefault, false},
+		{"idle", IOClassIdle, false},
+		{" Best-Effort ", IOClassBestEffort, false},
+		{"realtime", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseIOClass(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIOClass(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIOClass(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
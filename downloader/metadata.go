@@ -0,0 +1,289 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// videoURL returns the canonical page URL of a video.
+func videoURL(bvid string) string {
+	return "https://www.bilibili.com/video/" + bvid
+}
+
+// metadataArgs returns the ffmpeg -metadata arguments describing videoInfo.
+// Empty fields are omitted so existing tags are not blanked. Audio outputs
+// are tagged for music players as well: the playlist or season is the
+// album, the uploader its artist and the part or episode the track, and
+// the lyrics of audio tracks are added. ffmpeg writes the tags as ID3
+// frames, MP4 atoms or Vorbis comments.
+func metadataArgs(videoInfo *parser.VideoInfo, audio bool) []string {
+	var tags [][2]string
+	add := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			tags = append(tags, [2]string{key, value})
+		}
+	}
+
+	add("title", videoInfo.Title)
+	add("artist", videoInfo.Uploader)
+	add("description", videoInfo.Desc)
+	add("genre", videoInfo.Category)
+	if audio {
+		add("album", videoInfo.Series)
+		add("album_artist", videoInfo.Uploader)
+		if videoInfo.Episode > 0 {
+			add("track", strconv.Itoa(videoInfo.Episode))
+		}
+		add("lyrics", videoInfo.Lyrics)
+	}
+	if videoInfo.BVID != "" {
+		add("comment", videoInfo.BVID+" "+videoURL(videoInfo.BVID))
+	}
+	if videoInfo.PubDate > 0 {
+		add("date", time.Unix(videoInfo.PubDate, 0).Format("2006-01-02"))
+	}
+
+	args := make([]string, 0, 2*len(tags))
+	for _, tag := range tags {
+		args = append(args, "-metadata", tag[0]+"="+tag[1])
+	}
+	return args
+}
+
+// coverArgs returns the ffmpeg input and output arguments that add the
+// cover image as input number index, or nil if the container cannot carry
+// cover art. hasVideo tells whether input 0 has a video stream.
+func coverArgs(ext, cover string, index int, hasVideo bool) (inputs, outputs []string) {
+	// The cover becomes the video stream after the input's own video, if any.
+	coverStream := "v:0"
+	if hasVideo {
+		coverStream = "v:1"
+	}
+	mapCover := strconv.Itoa(index)
+
+	switch strings.ToLower(ext) {
+	case ".mp4", ".m4a", ".m4v", ".mov":
+		return []string{"-i", cover}, []string{"-map", "0", "-map", mapCover, "-disposition:" + coverStream, "attached_pic"}
+	case ".mp3":
+		return []string{"-i", cover}, []string{"-map", "0:a", "-map", mapCover, "-id3v2_version", "3",
+			"-metadata:s:v", "title=Album cover", "-disposition:v:0", "attached_pic"}
+	case ".flac":
+		return []string{"-i", cover}, []string{"-map", "0:a", "-map", mapCover, "-disposition:v:0", "attached_pic"}
+	case ".ogg", ".opus":
+		// Ogg carries covers as a METADATA_BLOCK_PICTURE comment, read
+		// from the FFMETADATA file of vorbisPictureFile.
+		return []string{"-i", cover}, []string{"-map", "0:a", "-map_metadata", mapCover}
+	case ".mkv":
+		// Matroska stores covers as attachments rather than video streams.
+		return nil, []string{"-map", "0", "-attach", cover, "-metadata:s:t", "mimetype=image/jpeg", "-metadata:s:t", "filename=cover.jpg"}
+	default:
+		return nil, nil
+	}
+}
+
+// supportsCover reports whether outputs with extension ext can carry cover art.
+func supportsCover(ext string) bool {
+	_, outputs := coverArgs(ext, "", 1, false)
+	return outputs != nil
+}
+
+// OriginalCoverURL returns the full-size image of a cover URL: resize
+// suffixes such as "@672w_378h_1c.webp" are dropped and http is upgraded
+// to https.
+func OriginalCoverURL(coverURL string) string {
+	if i := strings.LastIndex(coverURL, "@"); i > strings.LastIndex(coverURL, "/") {
+		coverURL = coverURL[:i]
+	}
+	// The API often returns http:// URLs; the CDN serves the same over TLS.
+	return strings.Replace(coverURL, "http://", "https://", 1)
+}
+
+// SaveCover downloads the full-size cover image to dest.
+func (d *Downloader) SaveCover(ctx context.Context, coverURL, dest string) error {
+	return d.fetchCover(ctx, OriginalCoverURL(coverURL), dest)
+}
+
+// fetchCover downloads the cover image to dest.
+func (d *Downloader) fetchCover(ctx context.Context, coverURL, dest string) error {
+	return d.fetchImage(ctx, coverURL, dest, "cover")
+}
+
+// fetchImage downloads an image from the Bilibili CDN to dest; what names
+// it in errors.
+func (d *Downloader) fetchImage(ctx context.Context, imageURL, dest, what string) error {
+	// The API often returns http:// URLs; the CDN serves the same over TLS.
+	imageURL = strings.Replace(imageURL, "http://", "https://", 1)
+
+	req, err := d.newMediaRequest(ctx, "GET", imageURL)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", what, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s: HTTP %d", what, resp.StatusCode)
+	}
+
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create %s file: %w", what, err)
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(dest)
+		return fmt.Errorf("failed to save %s: %w", what, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("failed to save %s: %w", what, err)
+	}
+	return nil
+}
+
+// tagOutput rewrites path in place with the configured metadata tags, cover
+// art and chapters. Streams are copied, not re-encoded. Tagging is a nicety,
+// so a missing ffmpeg or an unreachable cover only produces a warning.
+func (d *Downloader) tagOutput(ctx context.Context, path string, videoInfo *parser.VideoInfo) error {
+	ext := filepath.Ext(path)
+
+	var tags []string
+	if d.config.EmbedMetadata {
+		tags = metadataArgs(videoInfo, d.config.AudioOnly)
+	}
+	wantCover := d.config.EmbedCover && videoInfo.Cover != "" && supportsCover(ext)
+	wantChapters := !d.config.NoChapters && len(videoInfo.Chapters) > 0 && supportsChapters(ext)
+	if len(tags) == 0 && !wantCover && !wantChapters {
+		return nil
+	}
+	if !d.isFFmpegAvailable() {
+		d.logger.Warn("ffmpeg not found, skipping metadata, cover and chapter embedding")
+		return nil
+	}
+
+	inputs := []string{"-i", path}
+	var outputs []string
+	if wantCover {
+		cover := filepath.Join(filepath.Dir(path), ".cover."+filepath.Base(path)+".jpg")
+		err := d.fetchCover(ctx, videoInfo.Cover, cover)
+		if err == nil {
+			defer os.Remove(cover)
+			if isOgg(ext) {
+				if cover, err = vorbisPictureFile(cover); err == nil {
+					defer os.Remove(cover)
+				}
+			}
+		}
+		if err != nil {
+			d.logger.Warnf("Skipping cover art: %v", err)
+		} else {
+			in, out := coverArgs(ext, cover, len(inputs)/2, !d.config.AudioOnly)
+			inputs = append(inputs, in...)
+			outputs = append(outputs, out...)
+		}
+	}
+	if wantChapters {
+		chapters, err := writeChaptersFile(path, videoInfo.Chapters)
+		if err != nil {
+			d.logger.Warnf("Skipping chapters: %v", err)
+		} else {
+			defer os.Remove(chapters)
+			outputs = append(outputs, "-map_chapters", strconv.Itoa(len(inputs)/2))
+			inputs = append(inputs, "-i", chapters)
+		}
+	}
+	if len(inputs) == 2 && len(outputs) == 0 && len(tags) == 0 {
+		return nil // everything optional failed; leave the file alone
+	}
+	if !hasMap(outputs) {
+		outputs = append([]string{"-map", "0"}, outputs...)
+	}
+
+	// Keep the extension so ffmpeg picks the same container.
+	tmp := filepath.Join(filepath.Dir(path), ".meta."+filepath.Base(path))
+	args := append(inputs, outputs...)
+	args = append(args, "-c", "copy")
+	args = append(args, tags...)
+	args = append(args, "-y", tmp)
+
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), args...)
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+	if err := d.runLimited(ctx, cmd); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("ffmpeg failed to tag output: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// isOgg reports whether outputs with extension ext are Ogg files.
+func isOgg(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".ogg", ".opus":
+		return true
+	}
+	return false
+}
+
+// vorbisPictureFile writes the cover image at path as the
+// METADATA_BLOCK_PICTURE comment of an FFMETADATA file next to it, the
+// front cover in FLAC picture block form, base64-encoded. The comment is
+// too long to pass to ffmpeg as an argument.
+func vorbisPictureFile(path string) (string, error) {
+	image, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cover: %w", err)
+	}
+	mime := http.DetectContentType(image)
+
+	var block bytes.Buffer
+	field := func(v uint32) { binary.Write(&block, binary.BigEndian, v) }
+	field(3) // front cover
+	field(uint32(len(mime)))
+	block.WriteString(mime)
+	field(0) // no description
+	for i := 0; i < 4; i++ {
+		field(0) // width, height, colour depth and palette size unknown
+	}
+	field(uint32(len(image)))
+	block.Write(image)
+
+	picture := base64.StdEncoding.EncodeToString(block.Bytes())
+	metaPath := path + ".txt"
+	data := ";FFMETADATA1\nMETADATA_BLOCK_PICTURE=" + ffmetadataEscaper.Replace(picture) + "\n"
+	if err := os.WriteFile(metaPath, []byte(data), 0644); err != nil {
+		return "", fmt.Errorf("failed to write cover metadata: %w", err)
+	}
+	return metaPath, nil
+}
+
+// hasMap reports whether args contain an explicit -map option.
+func hasMap(args []string) bool {
+	for _, arg := range args {
+		if arg == "-map" {
+			return true
+		}
+	}
+	return false
+}
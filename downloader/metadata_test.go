@@ -0,0 +1,178 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMetadataArgs(t *testing.T) {
+	pub := time.Date(2023, 11, 14, 12, 0, 0, 0, time.Local).Unix()
+	info := &parser.VideoInfo{
+		BVID:     "BV1qt4y1X7TW",
+		Title:    "Title",
+		Uploader: "UP",
+		Category: "单机游戏",
+		PubDate:  pub,
+	}
+
+	want := []string{
+		"-metadata", "title=Title",
+		"-metadata", "artist=UP",
+		"-metadata", "genre=单机游戏",
+		"-metadata", "comment=BV1qt4y1X7TW https://www.bilibili.com/video/BV1qt4y1X7TW",
+		"-metadata", "date=2023-11-14",
+	}
+	if got := metadataArgs(info, false); !reflect.DeepEqual(got, want) {
+		t.Errorf("metadataArgs = %q, want %q", got, want)
+	}
+
+	if got := metadataArgs(&parser.VideoInfo{}, false); len(got) != 0 {
+		t.Errorf("metadataArgs(empty) = %q, want none", got)
+	}
+
+	// Audio is tagged with its album and track for music players.
+	info.Series, info.Episode = "Album", 3
+	got := strings.Join(metadataArgs(info, true), " ")
+	for _, want := range []string{"album=Album", "album_artist=UP", "track=3", "date=2023-11-14"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("audio metadataArgs = %q, lacks %s", got, want)
+		}
+	}
+	if strings.Contains(strings.Join(metadataArgs(info, false), " "), "album") {
+		t.Error("video tagged with an album")
+	}
+}
+
+func TestEmbedMetadata(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ffmpeg := writeScript(t, dir, "ffmpeg", "echo \"$@\" > "+argsFile+"\nfor last; do :; done\necho tagged > \"$last\"\n")
+	path := filepath.Join(dir, "v.mp4")
+	if err := os.WriteFile(path, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{config: Config{FFmpegPath: ffmpeg, EmbedMetadata: true}, logger: logrus.New()}
+	if err := d.tagOutput(context.Background(), path, &parser.VideoInfo{Title: "T"}); err != nil {
+		t.Fatalf("tagOutput: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(data)) != "tagged" {
+		t.Errorf("output = %q, want the ffmpeg result", data)
+	}
+	args, _ := os.ReadFile(argsFile)
+	if !strings.Contains(string(args), "-map 0 -c copy -metadata title=T") {
+		t.Errorf("ffmpeg args = %q", args)
+	}
+}
+
+func TestTagOutput_Cover(t *testing.T) {
+	// fetchCover upgrades cover URLs to https.
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("jpeg"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ffmpeg := writeScript(t, dir, "ffmpeg", "echo \"$@\" > "+argsFile+"\nfor last; do :; done\necho tagged > \"$last\"\n")
+	path := filepath.Join(dir, "song.mp3")
+	if err := os.WriteFile(path, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Downloader{
+		config: Config{FFmpegPath: ffmpeg, EmbedCover: true, AudioOnly: true},
+		logger: logrus.New(),
+		client: server.Client(),
+	}
+	if err := d.tagOutput(context.Background(), path, &parser.VideoInfo{Cover: server.URL + "/cover.jpg"}); err != nil {
+		t.Fatalf("tagOutput: %v", err)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	for _, want := range []string{"-map 0:a -map 1", "attached_pic", ".cover.song.mp3.jpg"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args %q missing %q", args, want)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".cover.*")); len(matches) != 0 {
+		t.Errorf("cover file left behind: %v", matches)
+	}
+}
+
+func TestCoverArgs(t *testing.T) {
+	in, out := coverArgs(".mp4", "c.jpg", 1, true)
+	if strings.Join(in, " ") != "-i c.jpg" || !strings.Contains(strings.Join(out, " "), "-map 1 -disposition:v:1 attached_pic") {
+		t.Errorf("mp4 cover args = %q %q", in, out)
+	}
+	if _, out := coverArgs(".m4a", "c.jpg", 1, false); !strings.Contains(strings.Join(out, " "), "-disposition:v:0 attached_pic") {
+		t.Errorf("m4a cover args = %q", out)
+	}
+	if in, out := coverArgs(".mkv", "c.jpg", 1, true); in != nil || !strings.Contains(strings.Join(out, " "), "-attach c.jpg") {
+		t.Errorf("mkv cover args = %q %q", in, out)
+	}
+	if in, out := coverArgs(".opus", "c.txt", 1, false); strings.Join(in, " ") != "-i c.txt" || strings.Join(out, " ") != "-map 0:a -map_metadata 1" {
+		t.Errorf("opus cover args = %q %q", in, out)
+	}
+	if supportsCover(".flv") {
+		t.Error("flv should not support cover art")
+	}
+}
+
+func TestVorbisPictureFile(t *testing.T) {
+	cover := filepath.Join(t.TempDir(), "c.jpg")
+	image := []byte("\xff\xd8\xff\xe0 jpeg")
+	if err := os.WriteFile(cover, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := vorbisPictureFile(cover)
+	if err != nil {
+		t.Fatalf("vorbisPictureFile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	encoded, ok := strings.CutPrefix(text, ";FFMETADATA1\nMETADATA_BLOCK_PICTURE=")
+	if !ok {
+		t.Fatalf("metadata file = %q", data)
+	}
+	block, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, `\=`, "="))
+	if err != nil {
+		t.Fatalf("picture is not base64: %v", err)
+	}
+	if binary.BigEndian.Uint32(block) != 3 || !bytes.Contains(block, []byte("image/jpeg")) || !bytes.HasSuffix(block, image) {
+		t.Errorf("picture block = %q", block)
+	}
+}
+
+func TestOriginalCoverURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://i0.hdslb.com/bfs/archive/abc.jpg":                    "https://i0.hdslb.com/bfs/archive/abc.jpg",
+		"https://i0.hdslb.com/bfs/archive/abc.jpg@672w_378h_1c.webp": "https://i0.hdslb.com/bfs/archive/abc.jpg",
+		"https://i0.hdslb.com/bfs/a@b/abc.png":                       "https://i0.hdslb.com/bfs/a@b/abc.png",
+	} {
+		if got := OriginalCoverURL(in); got != want {
+			t.Errorf("OriginalCoverURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
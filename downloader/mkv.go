@@ -0,0 +1,237 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// Audio track selections for MKV output.
+const (
+	AudioTracksBest = "best" // only the selected audio stream
+	AudioTracksAll  = "all"  // every DASH audio stream of the selected quality
+)
+
+// ValidateAudioTracks checks an --audio-tracks value.
+func ValidateAudioTracks(tracks string) error {
+	switch tracks {
+	case "", AudioTracksBest, AudioTracksAll:
+		return nil
+	}
+	return fmt.Errorf("unsupported audio track selection %q (want %s or %s)", tracks, AudioTracksBest, AudioTracksAll)
+}
+
+// mkvTrack is an extra input muxed into a Matroska file.
+type mkvTrack struct {
+	path  string
+	kind  string // "a" for audio, "s" for subtitles
+	codec string // subtitle codec: "srt" or "ass"
+	lang  string // ISO 639-2 code
+	title string
+}
+
+// isMKV reports whether outputPath is a Matroska file.
+func isMKV(outputPath string) bool {
+	return strings.EqualFold(filepath.Ext(outputPath), ".mkv")
+}
+
+// wantsMKVMux reports whether the download needs the Matroska muxer:
+// several audio tracks, subtitles or danmaku that mergeVideoAndAudio cannot
+// carry.
+func (d *Downloader) wantsMKVMux(outputPath string, videoInfo *parser.VideoInfo, stream *parser.StreamInfo) bool {
+	if !isMKV(outputPath) {
+		return false
+	}
+	if d.config.EmbedSubs && len(videoInfo.Subtitles) > 0 {
+		return true
+	}
+	if d.config.EmbedDanmaku && len(videoInfo.Danmaku) > 0 {
+		return true
+	}
+	return len(d.selectAudioTracks(stream)) > 1
+}
+
+// selectAudioTracks returns the audio streams to mux, best first.
+func (d *Downloader) selectAudioTracks(stream *parser.StreamInfo) []parser.AudioTrack {
+	if stream.AudioURL == "" {
+		return nil
+	}
+	best := parser.AudioTrack{URL: stream.AudioURL}
+	for _, track := range stream.AudioTracks {
+		if track.URL == stream.AudioURL {
+			best = track
+		}
+	}
+	tracks := []parser.AudioTrack{best}
+	if d.config.AudioTracks != AudioTracksAll {
+		return tracks
+	}
+	for _, track := range stream.AudioTracks {
+		if track.URL != best.URL {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// downloadMKV downloads the video, the selected audio tracks and the CC
+// subtitles and muxes them, plus the danmaku as ASS, into one Matroska file.
+func (d *Downloader) downloadMKV(ctx context.Context, videoInfo *parser.VideoInfo, stream *parser.StreamInfo, outputPath string) error {
+	if !d.isFFmpegAvailable() {
+		return fmt.Errorf("%w; MKV output with several tracks needs ffmpeg", ErrNoMuxer)
+	}
+
+	d.logger.Info("Downloading video and audio tracks...")
+
+	base := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	videoPath := base + "_video.mp4"
+	audios := d.selectAudioTracks(stream)
+	var tracks []mkvTrack
+	for i, audio := range audios {
+		tracks = append(tracks, mkvTrack{
+			path:  fmt.Sprintf("%s_audio%d.m4a", base, i),
+			kind:  "a",
+			lang:  "und",
+			title: parser.AudioTrackName(audio.ID),
+		})
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(audios)+1)
+	var wg sync.WaitGroup
+	fetch := func(i int, url, path string) {
+		defer wg.Done()
+		if errs[i] = d.downloadFile(ctx, url, path); errs[i] != nil {
+			cancel() // One failed track fails the whole download.
+		}
+	}
+	wg.Add(len(audios) + 1)
+	go fetch(0, stream.VideoURL, videoPath)
+	for i, audio := range audios {
+		go fetch(i+1, audio.URL, tracks[i].path)
+	}
+	wg.Wait()
+
+	inputs := []string{videoPath}
+	for _, track := range tracks {
+		inputs = append(inputs, track.path)
+	}
+	defer func() {
+		if d.keepPartial(parent) {
+			return
+		}
+		for _, path := range inputs {
+			os.Remove(path)
+		}
+	}()
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("failed to download tracks: %w", err)
+		}
+	}
+
+	if d.config.EmbedSubs {
+		for i, sub := range videoInfo.Subtitles {
+			path := fmt.Sprintf("%s_sub%d.srt", base, i)
+			if err := d.fetchSubtitle(ctx, sub.URL, path); err != nil {
+				d.logger.Warnf("Skipping %s subtitles: %v", sub.Lang, err)
+				continue
+			}
+			inputs = append(inputs, path)
+			tracks = append(tracks, mkvTrack{path: path, kind: "s", codec: "srt", lang: subtitleLanguage(sub.Lang), title: sub.Name})
+		}
+	}
+	if d.config.EmbedDanmaku && len(videoInfo.Danmaku) > 0 {
+		path := base + "_danmaku.ass"
+		if err := os.WriteFile(path, d.danmakuASS(videoInfo, stream), 0644); err != nil {
+			d.logger.Warnf("Skipping danmaku: %v", err)
+		} else {
+			inputs = append(inputs, path)
+			tracks = append(tracks, mkvTrack{path: path, kind: "s", codec: "ass", lang: "chi", title: "弹幕"})
+		}
+	}
+
+	if err := enterMerge(ctx); err != nil {
+		return err
+	}
+	d.logger.Info("Muxing Matroska file...")
+
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), mkvArgs(videoPath, tracks, outputPath)...)
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+	if err := d.runLimited(ctx, cmd); err != nil {
+		return fmt.Errorf("ffmpeg failed to mux %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// mkvArgs returns the ffmpeg arguments that mux videoIn and tracks into a
+// Matroska file. Streams are copied; subtitles are converted to their
+// track's codec.
+func mkvArgs(videoIn string, tracks []mkvTrack, outputPath string) []string {
+	args := []string{"-i", videoIn}
+	for _, track := range tracks {
+		args = append(args, "-i", track.path)
+	}
+	args = append(args, "-map", "0:v:0")
+	for i, track := range tracks {
+		args = append(args, "-map", fmt.Sprintf("%d:%s:0", i+1, track.kind))
+	}
+	args = append(args, "-c", "copy")
+
+	counts := map[string]int{}
+	for _, track := range tracks {
+		spec := fmt.Sprintf("%s:%d", track.kind, counts[track.kind])
+		counts[track.kind]++
+		if track.codec != "" {
+			args = append(args, "-c:"+spec, track.codec)
+		}
+		args = append(args, "-metadata:s:"+spec, "language="+track.lang)
+		if track.title != "" {
+			args = append(args, "-metadata:s:"+spec, "title="+track.title)
+		}
+	}
+	// The first audio and subtitle tracks are the defaults.
+	if counts["a"] > 0 {
+		args = append(args, "-disposition:a:0", "default")
+	}
+	if counts["s"] > 0 {
+		args = append(args, "-disposition:s:0", "default")
+	}
+	return append(args, "-y", outputPath)
+}
+
+// subtitleLanguage maps Bilibili subtitle languages ("zh-CN", "ai-en", ...)
+// to the ISO 639-2 codes Matroska expects.
+func subtitleLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimPrefix(lang, "ai-"))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	switch lang {
+	case "zh":
+		return "chi"
+	case "en":
+		return "eng"
+	case "ja":
+		return "jpn"
+	case "ko":
+		return "kor"
+	case "es":
+		return "spa"
+	case "ar":
+		return "ara"
+	}
+	return "und"
+}
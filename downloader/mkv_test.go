@@ -0,0 +1,84 @@
+package downloader
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestSelectAudioTracks(t *testing.T) {
+	stream := &parser.StreamInfo{
+		AudioURL: "https://a/192",
+		AudioTracks: []parser.AudioTrack{
+			{ID: 30216, URL: "https://a/64"},
+			{ID: 30280, URL: "https://a/192"},
+			{ID: 30232, URL: "https://a/132"},
+		},
+	}
+
+	best := (&Downloader{}).selectAudioTracks(stream)
+	if len(best) != 1 || best[0].ID != 30280 {
+		t.Errorf("best tracks = %+v, want only 30280", best)
+	}
+
+	all := (&Downloader{config: Config{AudioTracks: AudioTracksAll}}).selectAudioTracks(stream)
+	var ids []int
+	for _, track := range all {
+		ids = append(ids, track.ID)
+	}
+	if want := []int{30280, 30216, 30232}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("all track IDs = %v, want %v", ids, want)
+	}
+
+	if got := (&Downloader{}).selectAudioTracks(&parser.StreamInfo{}); got != nil {
+		t.Errorf("tracks without audio = %+v, want none", got)
+	}
+}
+
+func TestMKVArgs(t *testing.T) {
+	tracks := []mkvTrack{
+		{path: "a0.m4a", kind: "a", lang: "und", title: "192K"},
+		{path: "a1.m4a", kind: "a", lang: "und"},
+		{path: "s0.srt", kind: "s", codec: "srt", lang: "chi", title: "中文"},
+		{path: "d.ass", kind: "s", codec: "ass", lang: "chi"},
+	}
+	want := []string{
+		"-i", "v.mp4", "-i", "a0.m4a", "-i", "a1.m4a", "-i", "s0.srt", "-i", "d.ass",
+		"-map", "0:v:0", "-map", "1:a:0", "-map", "2:a:0", "-map", "3:s:0", "-map", "4:s:0",
+		"-c", "copy",
+		"-metadata:s:a:0", "language=und", "-metadata:s:a:0", "title=192K",
+		"-metadata:s:a:1", "language=und",
+		"-c:s:0", "srt", "-metadata:s:s:0", "language=chi", "-metadata:s:s:0", "title=中文",
+		"-c:s:1", "ass", "-metadata:s:s:1", "language=chi",
+		"-disposition:a:0", "default", "-disposition:s:0", "default",
+		"-y", "out.mkv",
+	}
+	if got := mkvArgs("v.mp4", tracks, "out.mkv"); !reflect.DeepEqual(got, want) {
+		t.Errorf("mkvArgs =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestSubtitleLanguage(t *testing.T) {
+	for lang, want := range map[string]string{
+		"zh-CN":   "chi",
+		"zh-Hans": "chi",
+		"ai-zh":   "chi",
+		"en-US":   "eng",
+		"ja":      "jpn",
+		"xx":      "und",
+	} {
+		if got := subtitleLanguage(lang); got != want {
+			t.Errorf("subtitleLanguage(%q) = %q, want %q", lang, got, want)
+		}
+	}
+}
+
+func TestMergeArgs_MKVCopiesAudio(t *testing.T) {
+	if got := mergeArgs("v", "a", "out.mkv"); got[7] != "copy" {
+		t.Errorf("mkv audio codec = %q, want copy", got[7])
+	}
+	if got := mergeArgs("v", "a", "out.mp4"); got[7] != "aac" {
+		t.Errorf("mp4 audio codec = %q, want aac", got[7])
+	}
+}
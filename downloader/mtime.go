@@ -0,0 +1,24 @@
+package downloader
+
+import (
+	"os"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// setPublishTime sets the modification time of a finished download and its
+// sidecars to the video's publish date, so an archive sorted by date
+// follows the upload order. Videos without a publish date keep the time of
+// the download.
+func (d *Downloader) setPublishTime(outputPath string, videoInfo *parser.VideoInfo) {
+	if d.config.NoMtime || videoInfo.PubDate <= 0 {
+		return
+	}
+	published := time.Unix(videoInfo.PubDate, 0)
+	for _, path := range d.outputFiles(outputPath) {
+		if err := os.Chtimes(path, time.Now(), published); err != nil {
+			d.logger.Warnf("Failed to set the modification time of %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetPublishTime(t *testing.T) {
+	pubDate := time.Date(2020, 5, 17, 12, 0, 0, 0, time.UTC)
+	videoInfo := &parser.VideoInfo{Title: "t", PubDate: pubDate.Unix()}
+
+	for _, tt := range []struct {
+		name   string
+		config Config
+		want   bool // mtime is the publish date
+	}{
+		{"default", Config{WriteInfoJSON: true}, true},
+		{"no-mtime", Config{WriteInfoJSON: true, NoMtime: true}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Downloader{config: tt.config, logger: logrus.New()}
+			out := filepath.Join(t.TempDir(), "video.mp4")
+			sidecar := d.SidecarPath(out, SidecarInfo, "")
+			for _, path := range []string{out, sidecar} {
+				if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			d.setPublishTime(out, videoInfo)
+
+			for _, path := range []string{out, sidecar} {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := info.ModTime().Equal(pubDate); got != tt.want {
+					t.Errorf("%s: mtime = %v, publish date %v", filepath.Base(path), info.ModTime(), pubDate)
+				}
+			}
+		})
+	}
+}
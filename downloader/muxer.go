@@ -0,0 +1,77 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ffmpegBin returns the ffmpeg executable to run.
+func (d *Downloader) ffmpegBin() string {
+	if d.config.FFmpegPath != "" {
+		return d.config.FFmpegPath
+	}
+	return "ffmpeg"
+}
+
+// console returns where the tools run by the downloader write their output
+// and errors.
+func (d *Downloader) console() (stdout, stderr io.Writer) {
+	if d.config.Console != nil {
+		return d.config.Console, d.config.Console
+	}
+	return os.Stdout, os.Stderr
+}
+
+// mp4boxBin returns the MP4Box executable to run.
+func (d *Downloader) mp4boxBin() string {
+	if d.config.MP4BoxPath != "" {
+		return d.config.MP4BoxPath
+	}
+	return "MP4Box"
+}
+
+// isFFmpegAvailable checks if ffmpeg is available in the system
+func (d *Downloader) isFFmpegAvailable() bool {
+	_, err := exec.LookPath(d.ffmpegBin())
+	return err == nil
+}
+
+// isMP4BoxAvailable checks if MP4Box is available in the system
+func (d *Downloader) isMP4BoxAvailable() bool {
+	_, err := exec.LookPath(d.mp4boxBin())
+	return err == nil
+}
+
+// mergeWithMP4Box muxes the two files with MP4Box. MP4Box only writes
+// ISO-BMFF containers, so other output formats are rejected.
+func (d *Downloader) mergeWithMP4Box(ctx context.Context, videoPath, audioPath, outputPath string) error {
+	switch strings.ToLower(filepath.Ext(outputPath)) {
+	case ".mp4", ".m4v", ".mov":
+	default:
+		return fmt.Errorf("MP4Box cannot write %s files", filepath.Ext(outputPath))
+	}
+
+	// MP4Box appends to an existing output; start from a clean file.
+	if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale output: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, d.mp4boxBin(),
+		"-add", videoPath+"#video",
+		"-add", audioPath+"#audio",
+		"-new", outputPath,
+	)
+	cmd.Stdout, cmd.Stderr = d.console()
+
+	d.logger.Debugf("Running MP4Box command: %s", strings.Join(cmd.Args, " "))
+
+	if err := d.runLimited(ctx, cmd); err != nil {
+		return &MergeError{Tool: "MP4Box", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writeScript creates an executable shell script named name in dir.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script muxers are not supported on Windows")
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newMergeFixture creates video/audio inputs and returns their paths.
+func newMergeFixture(t *testing.T) (dir, video, audio string) {
+	t.Helper()
+	dir = t.TempDir()
+	video = filepath.Join(dir, "v_video.mp4")
+	audio = filepath.Join(dir, "v_audio.m4a")
+	for _, p := range []string{video, audio} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir, video, audio
+}
+
+func TestMergeVideoAndAudio_NoMuxer(t *testing.T) {
+	dir, video, audio := newMergeFixture(t)
+	d := &Downloader{
+		config: Config{
+			FFmpegPath: filepath.Join(dir, "missing-ffmpeg"),
+			MP4BoxPath: filepath.Join(dir, "missing-mp4box"),
+		},
+		logger: logrus.New(),
+	}
+
+	err := d.mergeVideoAndAudio(context.Background(), video, audio, filepath.Join(dir, "v.mp4"))
+	if !errors.Is(err, ErrNoMuxer) {
+		t.Fatalf("error = %v, want ErrNoMuxer", err)
+	}
+	if !fileExists(video) || !fileExists(audio) {
+		t.Error("input streams must be kept when no muxer is available")
+	}
+	if fileExists(filepath.Join(dir, "v.mp4")) {
+		t.Error("no output should be produced without a muxer")
+	}
+}
+
+func TestMergeVideoAndAudio_MP4BoxFallback(t *testing.T) {
+	dir, video, audio := newMergeFixture(t)
+	mp4box := writeScript(t, dir, "MP4Box", "for last; do :; done\necho merged > \"$last\"\n")
+	d := &Downloader{
+		config: Config{FFmpegPath: filepath.Join(dir, "missing-ffmpeg"), MP4BoxPath: mp4box},
+		logger: logrus.New(),
+	}
+
+	out := filepath.Join(dir, "v.mp4")
+	if err := d.mergeVideoAndAudio(context.Background(), video, audio, out); err != nil {
+		t.Fatalf("mergeVideoAndAudio: %v", err)
+	}
+	if !fileExists(out) {
+		t.Error("MP4Box output missing")
+	}
+	if fileExists(video) || fileExists(audio) {
+		t.Error("input streams should be removed after a successful merge")
+	}
+
+	if err := d.mergeWithMP4Box(context.Background(), video, audio, filepath.Join(dir, "v.flv")); err == nil {
+		t.Error("MP4Box should refuse non-MP4 containers")
+	}
+}
+
+func TestMergeVideoAndAudio_CustomFFmpegPath(t *testing.T) {
+	dir, video, audio := newMergeFixture(t)
+	ffmpeg := writeScript(t, dir, "my-ffmpeg", "for last; do :; done\necho ffmpeg > \"$last\"\n")
+	d := &Downloader{config: Config{FFmpegPath: ffmpeg}, logger: logrus.New()}
+
+	if got := d.ffmpegBin(); got != ffmpeg {
+		t.Errorf("ffmpegBin() = %q, want %q", got, ffmpeg)
+	}
+	out := filepath.Join(dir, "v.mp4")
+	if err := d.mergeVideoAndAudio(context.Background(), video, audio, out); err != nil {
+		t.Fatalf("mergeVideoAndAudio: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "ffmpeg\n" {
+		t.Errorf("output = %q, want it produced by the configured ffmpeg", data)
+	}
+}
+
+func TestMergeVideoAndAudio_MergeError(t *testing.T) {
+	dir, video, audio := newMergeFixture(t)
+	ffmpeg := writeScript(t, dir, "ffmpeg", "exit 1\n")
+	d := &Downloader{
+		config: Config{FFmpegPath: ffmpeg, MP4BoxPath: filepath.Join(dir, "missing-mp4box")},
+		logger: logrus.New(),
+	}
+
+	err := d.mergeVideoAndAudio(context.Background(), video, audio, filepath.Join(dir, "v.mp4"))
+	var mergeErr *MergeError
+	if !errors.As(err, &mergeErr) || mergeErr.Tool != "ffmpeg" {
+		t.Fatalf("error = %v, want a MergeError from ffmpeg", err)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("MergeError does not wrap the exit status: %v", err)
+	}
+	if !fileExists(video) || !fileExists(audio) {
+		t.Error("input streams must be kept when the merge fails")
+	}
+}
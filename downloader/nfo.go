@@ -0,0 +1,208 @@
+package downloader
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// nfoActor is an <actor> of an NFO.
+type nfoActor struct {
+	Name  string `xml:"name"`
+	Role  string `xml:"role,omitempty"`
+	Order int    `xml:"order"`
+}
+
+// nfoThumb is a <thumb> artwork reference of an NFO.
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr,omitempty"`
+	URL    string `xml:",chardata"`
+}
+
+// nfoUniqueID is a <uniqueid> of an NFO: the BVID or season of the video.
+type nfoUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr,omitempty"`
+	ID      string `xml:",chardata"`
+}
+
+type movieNFO struct {
+	XMLName   xml.Name      `xml:"movie"`
+	Title     string        `xml:"title"`
+	Plot      string        `xml:"plot,omitempty"`
+	Premiered string        `xml:"premiered,omitempty"`
+	Year      int           `xml:"year,omitempty"`
+	Studio    string        `xml:"studio,omitempty"`
+	Genre     string        `xml:"genre,omitempty"`
+	Actors    []nfoActor    `xml:"actor"`
+	Thumbs    []nfoThumb    `xml:"thumb"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+}
+
+type episodeNFO struct {
+	XMLName   xml.Name      `xml:"episodedetails"`
+	Title     string        `xml:"title"`
+	ShowTitle string        `xml:"showtitle,omitempty"`
+	Season    int           `xml:"season"`
+	Episode   int           `xml:"episode"`
+	Plot      string        `xml:"plot,omitempty"`
+	Aired     string        `xml:"aired,omitempty"`
+	Studio    string        `xml:"studio,omitempty"`
+	Actors    []nfoActor    `xml:"actor"`
+	Thumbs    []nfoThumb    `xml:"thumb"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+}
+
+type tvshowNFO struct {
+	XMLName   xml.Name      `xml:"tvshow"`
+	Title     string        `xml:"title"`
+	Plot      string        `xml:"plot,omitempty"`
+	Studio    string        `xml:"studio,omitempty"`
+	Genre     string        `xml:"genre,omitempty"`
+	Actors    []nfoActor    `xml:"actor"`
+	Thumbs    []nfoThumb    `xml:"thumb"`
+	UniqueIDs []nfoUniqueID `xml:"uniqueid"`
+}
+
+// writeNFO writes the NFO file, the XML metadata Kodi and Jellyfin read,
+// of the download at outputPath. A download taken from a playlist is an
+// episode: it gets an <episodedetails> NFO, and its show a tvshow.nfo in
+// the show folder, the one above the season folder when the season has
+// one (see WithSubdir). Any other download is a movie. Both NFOs are
+// named after the video, which Kodi accepts for movie.nfo too, so videos
+// can share a folder.
+func (d *Downloader) writeNFO(ctx context.Context, outputPath string, videoInfo *parser.VideoInfo) error {
+	path := d.SidecarPath(outputPath, SidecarNFO, "")
+	if videoInfo.Series == "" {
+		return writeXMLFile(path, newMovieNFO(videoInfo))
+	}
+	if err := writeXMLFile(path, newEpisodeNFO(videoInfo)); err != nil {
+		return err
+	}
+	showDir := filepath.Dir(outputPath)
+	if contextSubdir(ctx) != "" {
+		showDir = filepath.Dir(showDir)
+	}
+	return writeXMLFile(filepath.Join(showDir, "tvshow.nfo"), newTVShowNFO(videoInfo))
+}
+
+func newMovieNFO(v *parser.VideoInfo) *movieNFO {
+	nfo := &movieNFO{
+		Title:     v.Title,
+		Plot:      v.Desc,
+		Premiered: nfoDate(v.PubDate),
+		Studio:    nfoStudio(v),
+		Genre:     v.Category,
+		Actors:    nfoActors(v.Actors),
+		Thumbs:    nfoThumbs(v.Cover, "poster"),
+		UniqueIDs: nfoUniqueIDs(v.BVID, 0),
+	}
+	if v.PubDate > 0 {
+		nfo.Year = time.Unix(v.PubDate, 0).Year()
+	}
+	return nfo
+}
+
+func newEpisodeNFO(v *parser.VideoInfo) *episodeNFO {
+	season := v.Season
+	if v.SeasonID == 0 {
+		season = 1 // a part of a multi-part video
+	}
+	return &episodeNFO{
+		Title:     v.Title,
+		ShowTitle: nfoShowTitle(v),
+		Season:    season,
+		Episode:   v.Episode,
+		Plot:      v.Desc,
+		Aired:     nfoDate(v.PubDate),
+		Studio:    nfoStudio(v),
+		Actors:    nfoActors(v.Actors),
+		Thumbs:    nfoThumbs(v.Cover, "thumb"),
+		UniqueIDs: nfoUniqueIDs(v.BVID, 0),
+	}
+}
+
+func newTVShowNFO(v *parser.VideoInfo) *tvshowNFO {
+	return &tvshowNFO{
+		Title:     nfoShowTitle(v),
+		Plot:      v.Desc,
+		Studio:    nfoStudio(v),
+		Genre:     v.Category,
+		Actors:    nfoActors(v.Actors),
+		Thumbs:    nfoThumbs(v.Cover, "poster"),
+		UniqueIDs: nfoUniqueIDs("", v.SeasonID),
+	}
+}
+
+// nfoShowTitle returns the title of the show of an episode: the show of a
+// bangumi season, or the playlist it was taken from.
+func nfoShowTitle(v *parser.VideoInfo) string {
+	if v.Show != "" {
+		return v.Show
+	}
+	return v.Series
+}
+
+// nfoStudio returns the studio of a bangumi season, or the uploader of
+// other videos.
+func nfoStudio(v *parser.VideoInfo) string {
+	if v.Studio != "" {
+		return v.Studio
+	}
+	return v.Uploader
+}
+
+// nfoDate formats a Unix time as an NFO date, or returns "" for 0.
+func nfoDate(unix int64) string {
+	if unix <= 0 {
+		return ""
+	}
+	return time.Unix(unix, 0).Format("2006-01-02")
+}
+
+func nfoActors(actors []parser.Actor) []nfoActor {
+	var out []nfoActor
+	for i, a := range actors {
+		out = append(out, nfoActor{Name: a.Name, Role: a.Role, Order: i})
+	}
+	return out
+}
+
+func nfoThumbs(cover, aspect string) []nfoThumb {
+	if cover == "" {
+		return nil
+	}
+	return []nfoThumb{{Aspect: aspect, URL: cover}}
+}
+
+// nfoUniqueIDs returns the ids of a video, its BVID, or of a bangumi
+// season, "ss<id>"; empty ones are left out.
+func nfoUniqueIDs(bvid string, seasonID int64) []nfoUniqueID {
+	var ids []nfoUniqueID
+	if bvid != "" {
+		ids = append(ids, nfoUniqueID{Type: "bilibili", Default: true, ID: bvid})
+	}
+	if seasonID != 0 {
+		ids = append(ids, nfoUniqueID{Type: "bilibili", Default: true, ID: "ss" + strconv.FormatInt(seasonID, 10)})
+	}
+	return ids
+}
+
+// writeXMLFile writes v as an indented, standalone XML document.
+func writeXMLFile(path string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	data = append([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+"\n"), data...)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
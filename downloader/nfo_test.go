@@ -0,0 +1,76 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWriteSidecars_EpisodeNFO(t *testing.T) {
+	dir := t.TempDir()
+	seasonDir := filepath.Join(dir, "Show", "Season 02")
+	if err := os.MkdirAll(seasonDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(seasonDir, "Show - S02E03 - Title.mkv")
+	d := &Downloader{config: Config{WriteNFO: true}, logger: logrus.New()}
+	aired := time.Date(2024, 4, 5, 12, 0, 0, 0, time.Local).Unix()
+
+	ctx := WithSubdir(context.Background(), filepath.Join("Show", "Season 02"))
+	d.writeSidecars(ctx, out, &parser.VideoInfo{
+		BVID: "BV1", Title: "Show - S02E03 - Title", Desc: "A & B", PubDate: aired,
+		Series: "Show 第二季", Show: "Show", Season: 2, SeasonID: 42, Episode: 3,
+		Actors: []parser.Actor{{Name: "声优A", Role: "角色A"}}, Studio: "Studio",
+		Cover: "https://i0.hdslb.com/cover.jpg",
+	}, &parser.StreamInfo{})
+
+	episode, err := os.ReadFile(filepath.Join(seasonDir, "Show - S02E03 - Title.nfo"))
+	if err != nil {
+		t.Fatalf("episode NFO not written: %v", err)
+	}
+	for _, want := range []string{
+		"<episodedetails>", "<showtitle>Show</showtitle>", "<season>2</season>", "<episode>3</episode>",
+		"<plot>A &amp; B</plot>", "<aired>2024-04-05</aired>", "<studio>Studio</studio>",
+		"<name>声优A</name>", "<role>角色A</role>", `<uniqueid type="bilibili" default="true">BV1</uniqueid>`,
+	} {
+		if !strings.Contains(string(episode), want) {
+			t.Errorf("episode NFO lacks %s:\n%s", want, episode)
+		}
+	}
+
+	show, err := os.ReadFile(filepath.Join(dir, "Show", "tvshow.nfo"))
+	if err != nil {
+		t.Fatalf("tvshow.nfo not written next to the season folder: %v", err)
+	}
+	for _, want := range []string{"<tvshow>", "<title>Show</title>", `<thumb aspect="poster">https://i0.hdslb.com/cover.jpg</thumb>`, ">ss42<"} {
+		if !strings.Contains(string(show), want) {
+			t.Errorf("tvshow.nfo lacks %s:\n%s", want, show)
+		}
+	}
+}
+
+func TestWriteSidecars_MovieNFO(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "v.mp4")
+	d := &Downloader{config: Config{WriteNFO: true}, logger: logrus.New()}
+
+	d.writeSidecars(context.Background(), out, &parser.VideoInfo{BVID: "BV1", Title: "T", Uploader: "UP"}, &parser.StreamInfo{})
+
+	data, err := os.ReadFile(filepath.Join(dir, "v.nfo"))
+	if err != nil {
+		t.Fatalf("movie NFO not written: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "<?xml") || !strings.Contains(string(data), "<movie>") ||
+		!strings.Contains(string(data), "<studio>UP</studio>") {
+		t.Errorf("movie NFO = %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tvshow.nfo")); err == nil {
+		t.Error("tvshow.nfo written for a single video")
+	}
+}
@@ -0,0 +1,129 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// OutputDirData is the data of an output directory template, e.g.
+// "{{.Owner}}/{{.SeriesTitle}}". Every field is already safe as a path
+// component; empty ones are skipped with their directory level.
+type OutputDirData struct {
+	Owner       string // Uploader name
+	OwnerMID    string // Uploader ID
+	SeriesTitle string // Playlist, season or multi-part video title; empty for single videos
+	Title       string // Video or episode title
+	BVID        string
+	Category    string // 分区 name
+	Year        string // Publish date parts, empty when unknown
+	Month       string
+	Day         string
+}
+
+// parseOutputDirTemplate compiles an output directory template; unknown
+// fields are errors rather than "<no value>".
+func parseOutputDirTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output-dir").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output directory template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ValidateOutputDirTemplate checks an output directory template by
+// rendering it for a sample video.
+func ValidateOutputDirTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	_, err := renderOutputDir(text, &parser.VideoInfo{Title: "title", Uploader: "owner", Series: "series", PubDate: 1})
+	return err
+}
+
+// outputDirData returns the template data of videoInfo.
+func outputDirData(videoInfo *parser.VideoInfo) OutputDirData {
+	clean := func(s string) string {
+		if strings.TrimSpace(s) == "" {
+			return ""
+		}
+		return sanitizeFilename(s)
+	}
+	data := OutputDirData{
+		Owner:       clean(videoInfo.Uploader),
+		SeriesTitle: clean(videoInfo.Series),
+		Title:       clean(videoInfo.Title),
+		BVID:        clean(videoInfo.BVID),
+		Category:    clean(videoInfo.Category),
+	}
+	if videoInfo.OwnerMID > 0 {
+		data.OwnerMID = fmt.Sprint(videoInfo.OwnerMID)
+	}
+	if videoInfo.PubDate > 0 {
+		published := time.Unix(videoInfo.PubDate, 0)
+		data.Year = published.Format("2006")
+		data.Month = published.Format("01")
+		data.Day = published.Format("02")
+	}
+	return data
+}
+
+// renderOutputDir renders the template text for videoInfo into a relative
+// directory. Each "/"-separated level is sanitized, and levels that render
+// empty (a single video has no SeriesTitle) are dropped.
+func renderOutputDir(text string, videoInfo *parser.VideoInfo) (string, error) {
+	tmpl, err := parseOutputDirTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, outputDirData(videoInfo)); err != nil {
+		return "", fmt.Errorf("invalid output directory template: %w", err)
+	}
+
+	var levels []string
+	for _, level := range strings.Split(filepath.ToSlash(b.String()), "/") {
+		if strings.Trim(level, " .") == "" {
+			continue
+		}
+		levels = append(levels, sanitizeFilename(level))
+	}
+	return filepath.Join(levels...), nil
+}
+
+// RenderOutputTemplate renders an output directory template, or any
+// "/"-separated path template with the same fields, for videoInfo into a
+// relative path of sanitized components.
+func RenderOutputTemplate(text string, videoInfo *parser.VideoInfo) (string, error) {
+	return renderOutputDir(text, videoInfo)
+}
+
+// outputSubdir returns the directory below OutputDir that videoInfo is
+// saved in, from Config.OutputDirTemplate.
+func (d *Downloader) outputSubdir(videoInfo *parser.VideoInfo) (string, error) {
+	if d.config.OutputDirTemplate == "" {
+		return "", nil
+	}
+	return renderOutputDir(d.config.OutputDirTemplate, videoInfo)
+}
+
+// subdirKey is the context key of the directory set with WithSubdir.
+type subdirKey struct{}
+
+// WithSubdir returns a context whose downloads are saved in dir, a
+// relative path, below their output directory (and their subdirectory of
+// OutputDirTemplate), e.g. one folder per season of a show.
+func WithSubdir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, subdirKey{}, dir)
+}
+
+// contextSubdir returns the directory set with WithSubdir, or "".
+func contextSubdir(ctx context.Context) string {
+	dir, _ := ctx.Value(subdirKey{}).(string)
+	return dir
+}
@@ -0,0 +1,79 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+func TestRenderOutputDir(t *testing.T) {
+	pubDate := time.Date(2023, 7, 9, 12, 0, 0, 0, time.Local).Unix()
+	episode := &parser.VideoInfo{Title: "第1话", Uploader: "UP主", OwnerMID: 42, Series: "番剧: 第一季", PubDate: pubDate}
+	single := &parser.VideoInfo{Title: "vlog", Uploader: "UP主"}
+
+	for _, tt := range []struct {
+		name string
+		tmpl string
+		info *parser.VideoInfo
+		want string
+	}{
+		{"owner and series", "{{.Owner}}/{{.SeriesTitle}}", episode, filepath.Join("UP主", "番剧_ 第一季")},
+		{"single video drops empty series", "{{.Owner}}/{{.SeriesTitle}}", single, "UP主"},
+		{"date parts", "{{.Year}}/{{.Year}}-{{.Month}}-{{.Day}}", episode, filepath.Join("2023", "2023-07-09")},
+		{"owner id", "{{.OwnerMID}} {{.Owner}}", episode, "42 UP主"},
+		{"slash in a value stays one level", "{{.Title}}", &parser.VideoInfo{Title: "a/b"}, "a_b"},
+		{"no traversal", "../{{.Owner}}/..", single, "UP主"},
+		{"conditional", "{{if .SeriesTitle}}{{.SeriesTitle}}{{else}}singles{{end}}", single, "singles"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderOutputDir(tt.tmpl, tt.info)
+			if err != nil {
+				t.Fatalf("renderOutputDir(%q): %v", tt.tmpl, err)
+			}
+			if got != tt.want {
+				t.Errorf("renderOutputDir(%q) = %q, want %q", tt.tmpl, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOutputDirTemplate(t *testing.T) {
+	for _, tt := range []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{"", false},
+		{"{{.Owner}}/{{.SeriesTitle}}", false},
+		{"{{.Owner", true},
+		{"{{.Uploader}}", true}, // not a field
+	} {
+		if err := ValidateOutputDirTemplate(tt.tmpl); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateOutputDirTemplate(%q) error = %v, want error %v", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDownloadVideoResult_Subdir(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("video"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	d := NewDownloader(Config{OutputDir: dir, Format: "mp4", Quality: "best", OutputDirTemplate: "{{.SeriesTitle}}"})
+	info := &parser.VideoInfo{BVID: "BV1", Title: "第1话", Series: "番剧"}
+	streams := []*parser.StreamInfo{{Quality: 80, VideoURL: server.URL + "/v.m4s", Muxed: true}}
+
+	result, err := d.DownloadVideoResult(WithSubdir(context.Background(), "第二季"), info, streams)
+	if err != nil {
+		t.Fatalf("DownloadVideoResult: %v", err)
+	}
+	if want := filepath.Join(dir, "番剧", "第二季", "第1话_1080p.mp4"); result.Path != want {
+		t.Errorf("path = %s, want %s", result.Path, want)
+	}
+}
@@ -0,0 +1,100 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+)
+
+// Pipeline overlaps the downloads and merges of consecutive videos: a
+// video holds a download slot while it fetches its streams and gives it
+// up for a merge slot once muxing starts, so the next video downloads
+// while ffmpeg merges the previous one. One merge runs at a time, keeping
+// the CPU-bound muxing from competing with itself.
+type Pipeline struct {
+	downloads chan struct{}
+	merges    chan struct{}
+}
+
+// NewPipeline returns a pipeline running up to downloads downloads (at
+// least 1) next to a single merge.
+func NewPipeline(downloads int) *Pipeline {
+	if downloads < 1 {
+		downloads = 1
+	}
+	return &Pipeline{
+		downloads: make(chan struct{}, downloads),
+		merges:    make(chan struct{}, 1),
+	}
+}
+
+// pipelineKey is the context key of the active pipelineStage.
+type pipelineKey struct{}
+
+// pipelineStage is the place of one video in a Pipeline.
+type pipelineStage struct {
+	p *Pipeline
+
+	mu       sync.Mutex
+	download bool // holds a download slot
+	merge    bool // holds the merge slot
+}
+
+// Start waits for a download slot and returns a context whose video
+// download holds it until its merge starts. DownloadVideoResult under the
+// context releases its slots when it returns; the returned done function
+// does the same for videos that are given up before.
+func (p *Pipeline) Start(ctx context.Context) (context.Context, func(), error) {
+	select {
+	case p.downloads <- struct{}{}:
+	case <-ctx.Done():
+		return ctx, func() {}, ctx.Err()
+	}
+	stage := &pipelineStage{p: p, download: true}
+	return context.WithValue(ctx, pipelineKey{}, stage), stage.finish, nil
+}
+
+// enterMerge moves the video of ctx from its download slot to the merge
+// slot, waiting for the merge before it to end. Without a pipeline it
+// returns at once.
+func enterMerge(ctx context.Context) error {
+	stage, ok := ctx.Value(pipelineKey{}).(*pipelineStage)
+	if !ok {
+		return nil
+	}
+	stage.mu.Lock()
+	defer stage.mu.Unlock()
+	if stage.merge {
+		return nil
+	}
+	if stage.download {
+		<-stage.p.downloads
+		stage.download = false
+	}
+	select {
+	case stage.p.merges <- struct{}{}:
+		stage.merge = true
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// finishPipeline releases the slots the video of ctx holds.
+func finishPipeline(ctx context.Context) {
+	if stage, ok := ctx.Value(pipelineKey{}).(*pipelineStage); ok {
+		stage.finish()
+	}
+}
+
+func (s *pipelineStage) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.download {
+		<-s.p.downloads
+		s.download = false
+	}
+	if s.merge {
+		<-s.p.merges
+		s.merge = false
+	}
+}
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	p := NewPipeline(1)
+	ctx := context.Background()
+
+	first, _, err := p.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second video waits for the first one's download...
+	started := make(chan context.Context)
+	go func() {
+		second, _, err := p.Start(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		started <- second
+	}()
+	select {
+	case <-started:
+		t.Fatal("second download started during the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// ...but not for its merge.
+	if err := enterMerge(first); err != nil {
+		t.Fatal(err)
+	}
+	var second context.Context
+	select {
+	case second = <-started:
+	case <-time.After(time.Second):
+		t.Fatal("second download did not start while the first merges")
+	}
+
+	// Merges run one at a time.
+	merged := make(chan error)
+	go func() { merged <- enterMerge(second) }()
+	select {
+	case <-merged:
+		t.Fatal("second merge started during the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+	finishPipeline(first)
+	if err := <-merged; err != nil {
+		t.Fatal(err)
+	}
+	finishPipeline(second)
+	finishPipeline(second) // idempotent
+
+	// All slots are free again.
+	third, done, err := p.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enterMerge(third); err != nil {
+		t.Fatal(err)
+	}
+	done()
+}
+
+func TestPipeline_Canceled(t *testing.T) {
+	p := NewPipeline(1)
+	_, done, err := p.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := p.Start(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Start = %v, want the context's error", err)
+	}
+	if err := enterMerge(context.Background()); err != nil {
+		t.Errorf("enterMerge without a pipeline = %v", err)
+	}
+}
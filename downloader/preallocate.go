@@ -0,0 +1,36 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// errPreallocateUnsupported is returned by preallocate when the platform or
+// file system cannot reserve space; the download then proceeds without.
+var errPreallocateUnsupported = errors.New("preallocation not supported")
+
+// allocate reserves size bytes for file before it is written, which keeps
+// the file contiguous and fails at once when the disk is too small. Without
+// platform support it only sets the file size. With NoPreallocate it leaves
+// the file alone, so the download grows it as it is written.
+func (d *Downloader) allocate(file *os.File, size int64) error {
+	if d.config.NoPreallocate {
+		return nil
+	}
+	err := preallocate(file, size)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrDiskFull):
+		return fmt.Errorf("failed to allocate %.2f MB for %s: %w", float64(size)/(1024*1024), file.Name(), err)
+	case errors.Is(err, errPreallocateUnsupported):
+		d.logger.Debugf("Preallocation unavailable for %s, writing without", file.Name())
+	default:
+		d.logger.Debugf("Preallocation of %s failed: %v", file.Name(), err)
+	}
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to pre-allocate file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// preallocate reserves size bytes for f with fallocate(2).
+func preallocate(f *os.File, size int64) error {
+	var err error
+	for {
+		err = syscall.Fallocate(int(f.Fd()), 0, 0, size)
+		if err != syscall.EINTR {
+			break
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EFBIG):
+		return fmt.Errorf("%w: fallocate: %v", ErrDiskFull, err)
+	case errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOSYS):
+		return errPreallocateUnsupported
+	default:
+		return fmt.Errorf("fallocate: %w", err)
+	}
+}
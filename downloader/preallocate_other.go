@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package downloader
+
+import "os"
+
+// preallocate is not supported outside Linux and Windows; files grow as
+// they are written.
+func preallocate(_ *os.File, _ int64) error {
+	return errPreallocateUnsupported
+}
@@ -0,0 +1,35 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestAllocate(t *testing.T) {
+	for _, noPreallocate := range []bool{false, true} {
+		d := &Downloader{config: Config{NoPreallocate: noPreallocate}, logger: logrus.New()}
+		file, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		if err := d.allocate(file, 3<<20); err != nil {
+			t.Fatalf("allocate (NoPreallocate=%v): %v", noPreallocate, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := int64(3 << 20)
+		if noPreallocate {
+			want = 0 // untouched
+		}
+		if info.Size() != want {
+			t.Errorf("size = %d, want %d (NoPreallocate=%v)", info.Size(), want, noPreallocate)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// errorDiskFull is the Windows ERROR_DISK_FULL code.
+const errorDiskFull syscall.Errno = 112
+
+// preallocate reserves size bytes for f. Truncate moves the end of file
+// with SetEndOfFile, which allocates the clusters on NTFS.
+func preallocate(f *os.File, size int64) error {
+	err := f.Truncate(size)
+	if errors.Is(err, errorDiskFull) {
+		return fmt.Errorf("%w: SetEndOfFile: %v", ErrDiskFull, err)
+	}
+	return err
+}
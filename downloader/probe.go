@@ -0,0 +1,170 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaProbe is what ffprobe reports about a media file.
+type MediaProbe struct {
+	Duration time.Duration // of the container; 0 when unknown
+	HasVideo bool
+	HasAudio bool
+
+	// Durations of the first video and audio track; 0 when unknown.
+	VideoDuration time.Duration
+	AudioDuration time.Duration
+}
+
+// FFprobeBin returns the ffprobe executable that comes with the ffmpeg at
+// ffmpegPath, or ffprobe from PATH when ffmpegPath is empty or not named
+// after ffmpeg.
+func FFprobeBin(ffmpegPath string) string {
+	dir, base := filepath.Split(ffmpegPath)
+	name := strings.Replace(base, "ffmpeg", "ffprobe", 1)
+	if name == base {
+		return "ffprobe"
+	}
+	return dir + name
+}
+
+// ProbeMedia runs ffprobe on path. Files ffprobe cannot read, such as
+// downloads cut off before the MP4 index was written, fail with its
+// error message.
+func ProbeMedia(ctx context.Context, ffprobe, path string) (*MediaProbe, error) {
+	cmd := exec.CommandContext(ctx, ffprobe, "-v", "error",
+		"-print_format", "json", "-show_format", "-show_streams", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffprobe: %s", lastLine(msg))
+		}
+		return nil, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseProbe(out)
+}
+
+// lastLine returns the last line of s; ffprobe ends with the error that
+// made it give up.
+func lastLine(s string) string {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// parseProbe reads the JSON written by ffprobe -show_format -show_streams.
+func parseProbe(data []byte) (*MediaProbe, error) {
+	var out struct {
+		Streams []struct {
+			CodecType string            `json:"codec_type"`
+			Duration  string            `json:"duration"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	probe := &MediaProbe{Duration: probeDuration(out.Format.Duration)}
+	for _, s := range out.Streams {
+		// Matroska keeps track durations in a tag instead.
+		d := probeDuration(s.Duration)
+		if d == 0 {
+			d = probeDuration(s.Tags["DURATION"])
+		}
+		switch s.CodecType {
+		case "video":
+			// Embedded cover art is a video stream too, of one frame.
+			if s.Tags["mimetype"] != "" || strings.EqualFold(s.Tags["comment"], "Cover (front)") {
+				continue
+			}
+			if !probe.HasVideo {
+				probe.HasVideo, probe.VideoDuration = true, d
+			}
+		case "audio":
+			if !probe.HasAudio {
+				probe.HasAudio, probe.AudioDuration = true, d
+			}
+		}
+	}
+	return probe, nil
+}
+
+// probeDuration parses a duration as ffprobe prints it: seconds
+// ("180.021000") or, in Matroska tags, "00:03:00.021000000". Unknown or
+// malformed durations are 0.
+func probeDuration(s string) time.Duration {
+	if s == "" || s == "N/A" {
+		return 0
+	}
+	var seconds float64
+	for _, part := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + v
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// durationTolerance is how far a duration may be off length before the file
+// counts as truncated: 2% of it, but at least three seconds, as Bilibili
+// rounds lengths to the second and streams start a little apart.
+func durationTolerance(length time.Duration) time.Duration {
+	if tolerance := length / 50; tolerance > 3*time.Second {
+		return tolerance
+	}
+	return 3 * time.Second
+}
+
+// Problems lists what is wrong with a file for a download of length (0 when
+// unknown) that should have a video and/or an audio track. An empty list
+// means the file looks complete.
+func (m *MediaProbe) Problems(length time.Duration, wantVideo, wantAudio bool) []string {
+	var problems []string
+	if wantVideo && !m.HasVideo {
+		problems = append(problems, "no video track")
+	}
+	if wantAudio && !m.HasAudio {
+		problems = append(problems, "no audio track")
+	}
+	if m.Duration == 0 && !m.HasVideo && !m.HasAudio {
+		problems = append(problems, "no media streams")
+		return problems
+	}
+
+	if length > 0 && absDuration(m.Duration-length) > durationTolerance(length) {
+		problems = append(problems, fmt.Sprintf("duration %s, expected %s",
+			m.Duration.Round(time.Second), length.Round(time.Second)))
+	}
+	// A merge of a truncated stream leaves the tracks of different lengths.
+	if m.VideoDuration > 0 && m.AudioDuration > 0 {
+		longest := max(m.VideoDuration, m.AudioDuration)
+		if absDuration(m.VideoDuration-m.AudioDuration) > durationTolerance(longest) {
+			problems = append(problems, fmt.Sprintf("video track %s, audio track %s",
+				m.VideoDuration.Round(time.Second), m.AudioDuration.Round(time.Second)))
+		}
+	}
+	return problems
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFFprobeBin(t *testing.T) {
+	tests := map[string]string{
+		"":                       "ffprobe",
+		"ffmpeg":                 "ffprobe",
+		"/opt/ffmpeg/bin/ffmpeg": "/opt/ffmpeg/bin/ffprobe",
+		"/usr/local/bin/avconv":  "ffprobe",
+	}
+	for in, want := range tests {
+		if got := FFprobeBin(in); got != want {
+			t.Errorf("FFprobeBin(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	mp4 := `{
+		"streams": [
+			{"codec_type": "video", "duration": "180.000000"},
+			{"codec_type": "audio", "duration": "61.500000"}
+		],
+		"format": {"duration": "180.021000"}
+	}`
+	probe, err := parseProbe([]byte(mp4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !probe.HasVideo || !probe.HasAudio || probe.Duration != 180021*time.Millisecond ||
+		probe.VideoDuration != 180*time.Second || probe.AudioDuration != 61500*time.Millisecond {
+		t.Errorf("mp4 probe = %+v", probe)
+	}
+
+	// Matroska track durations are tags; the cover attachment is no video.
+	mkv := `{
+		"streams": [
+			{"codec_type": "audio", "tags": {"DURATION": "00:03:00.021000000"}},
+			{"codec_type": "video", "tags": {"mimetype": "image/jpeg", "filename": "cover.jpg"}}
+		],
+		"format": {"duration": "180.021000"}
+	}`
+	if probe, err = parseProbe([]byte(mkv)); err != nil {
+		t.Fatal(err)
+	}
+	if probe.HasVideo || !probe.HasAudio || probe.AudioDuration != 180021*time.Millisecond {
+		t.Errorf("mkv probe = %+v", probe)
+	}
+}
+
+func TestMediaProbeProblems(t *testing.T) {
+	complete := &MediaProbe{Duration: 180 * time.Second, HasVideo: true, HasAudio: true,
+		VideoDuration: 180 * time.Second, AudioDuration: 179 * time.Second}
+	tests := []struct {
+		name      string
+		probe     *MediaProbe
+		length    time.Duration
+		wantVideo bool
+		wantAudio bool
+		want      string
+	}{
+		{"complete", complete, 181 * time.Second, true, true, ""},
+		{"unknown length", complete, 0, true, true, ""},
+		{"truncated", complete, 10 * time.Minute, true, true, "duration 3m0s, expected 10m0s"},
+		{"missing audio", &MediaProbe{Duration: time.Minute, HasVideo: true}, time.Minute, true, true, "no audio track"},
+		{"video only", &MediaProbe{Duration: time.Minute, HasVideo: true}, time.Minute, true, false, ""},
+		{"audio only", &MediaProbe{Duration: time.Minute, HasAudio: true}, time.Minute, false, true, ""},
+		{"short audio track", &MediaProbe{Duration: time.Minute, HasVideo: true, HasAudio: true,
+			VideoDuration: time.Minute, AudioDuration: 20 * time.Second}, 0, true, true, "video track 1m0s, audio track 20s"},
+		{"empty", &MediaProbe{}, time.Minute, false, true, "no audio track; no media streams"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(tt.probe.Problems(tt.length, tt.wantVideo, tt.wantAudio), "; ")
+		if got != tt.want {
+			t.Errorf("%s: Problems() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProbeMedia(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script as ffprobe")
+	}
+	dir := t.TempDir()
+	ffprobe := filepath.Join(dir, "ffprobe")
+	script := "#!/bin/sh\ncase \"$7\" in\n" +
+		"*broken*) echo 'first warning' >&2; echo \"$7: moov atom not found\" >&2; exit 1 ;;\n" +
+		"*) echo '{\"streams\":[{\"codec_type\":\"audio\",\"duration\":\"5.0\"}],\"format\":{\"duration\":\"5.0\"}}' ;;\nesac\n"
+	if err := os.WriteFile(ffprobe, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	probe, err := ProbeMedia(context.Background(), ffprobe, "good.m4a")
+	if err != nil {
+		t.Fatalf("ProbeMedia error: %v", err)
+	}
+	if !probe.HasAudio || probe.Duration != 5*time.Second {
+		t.Errorf("probe = %+v", probe)
+	}
+
+	_, err = ProbeMedia(context.Background(), ffprobe, "broken.mp4")
+	if err == nil || err.Error() != "ffprobe: broken.mp4: moov atom not found" {
+		t.Errorf("ProbeMedia(broken) error = %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// progressInterval is how often ProgressReader emits updates.
+	progressInterval = 500 * time.Millisecond
+	// speedWindow is the span of recent samples used for the speed estimate.
+	speedWindow = 5 * time.Second
+	// speedSampleInterval is the minimum spacing between stored samples.
+	speedSampleInterval = 100 * time.Millisecond
+)
+
+// speedSample is the cumulative byte count observed at a point in time.
+type speedSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// speedEstimator computes throughput over a sliding time window. Unlike a
+// lifetime average it reacts to speed changes within a few seconds, and
+// unlike a single-interval delta it does not jump around on every read.
+type speedEstimator struct {
+	samples []speedSample
+	latest  speedSample
+}
+
+// add records the cumulative byte count at time now.
+func (e *speedEstimator) add(now time.Time, total int64) {
+	e.latest = speedSample{at: now, bytes: total}
+
+	if n := len(e.samples); n == 0 || now.Sub(e.samples[n-1].at) >= speedSampleInterval {
+		e.samples = append(e.samples, e.latest)
+	}
+
+	// Drop samples that fell out of the window, but keep the newest one at
+	// or before the window start so the window stays fully covered.
+	cutoff := now.Add(-speedWindow)
+	drop := 0
+	for drop+1 < len(e.samples) && !e.samples[drop+1].at.After(cutoff) {
+		drop++
+	}
+	if drop > 0 {
+		e.samples = append(e.samples[:0], e.samples[drop:]...)
+	}
+}
+
+// rate returns the estimated speed in bytes per second.
+func (e *speedEstimator) rate() float64 {
+	if len(e.samples) == 0 {
+		return 0
+	}
+	oldest := e.samples[0]
+	elapsed := e.latest.at.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(e.latest.bytes-oldest.bytes) / elapsed
+}
+
+// estimateETA returns the time needed to transfer remaining bytes at rate
+// bytes per second, or 0 when it cannot be estimated.
+func estimateETA(remaining int64, rate float64) time.Duration {
+	if remaining <= 0 || rate <= 0 {
+		return 0
+	}
+	seconds := math.Ceil(float64(remaining) / rate)
+	return time.Duration(seconds) * time.Second
+}
+
+// formatETA renders an ETA as m:ss or h:mm:ss ("--:--" when unknown).
+func formatETA(d time.Duration) string {
+	if d <= 0 {
+		return "--:--"
+	}
+	total := int64(d.Round(time.Second) / time.Second)
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// ProgressFunc receives the progress of the file being downloaded.
+type ProgressFunc func(DownloadProgress)
+
+// progressKey is the context key of the active ProgressFunc.
+type progressKey struct{}
+
+// WithProgress returns a context whose downloads report their progress to
+// fn, at most every progressInterval per file. Each file starts again from
+// zero, so a video and its audio stream are reported one after the other.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFromContext returns the ProgressFunc of ctx, or nil.
+func progressFromContext(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// progressCounter adds up the bytes of the concurrent chunks of one file
+// and reports them like ProgressReader does for single-request downloads.
+type progressCounter struct {
+	mu       sync.Mutex
+	total    int64
+	done     int64
+	speed    speedEstimator
+	lastEmit time.Time
+	report   ProgressFunc
+}
+
+// newProgressCounter returns a counter for a file of total bytes, or nil
+// when there is nobody to report to.
+func newProgressCounter(total int64, report ProgressFunc) *progressCounter {
+	if report == nil {
+		return nil
+	}
+	c := &progressCounter{total: total, report: report, lastEmit: time.Now()}
+	c.speed.add(c.lastEmit, 0)
+	return c
+}
+
+// add records n more bytes.
+func (c *progressCounter) add(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A retried chunk is fetched again from its start; never report more
+	// than the file holds.
+	c.done = min(c.done+int64(n), c.total)
+	now := time.Now()
+	c.speed.add(now, c.done)
+	if now.Sub(c.lastEmit) < progressInterval && c.done < c.total {
+		return
+	}
+	c.lastEmit = now
+	rate := c.speed.rate()
+	c.report(DownloadProgress{
+		TotalSize:  c.total,
+		Downloaded: c.done,
+		Percentage: float64(c.done) / float64(c.total) * 100,
+		Speed:      int64(rate),
+		ETA:        estimateETA(c.total-c.done, rate),
+	})
+}
+
+// reader counts what is read from r; a nil counter returns r unchanged.
+func (c *progressCounter) reader(r io.Reader) io.Reader {
+	if c == nil {
+		return r
+	}
+	return &countingReader{r: r, c: c}
+}
+
+// countingReader feeds the bytes read through it into a progressCounter.
+type countingReader struct {
+	r io.Reader
+	c *progressCounter
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if n > 0 {
+		cr.c.add(n)
+	}
+	return n, err
+}
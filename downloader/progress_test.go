@@ -0,0 +1,131 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSpeedEstimator_SteadyRate(t *testing.T) {
+	var e speedEstimator
+	start := time.Unix(0, 0)
+	// 1 MB/s for 10 seconds, sampled every 250ms.
+	for i := 0; i <= 40; i++ {
+		e.add(start.Add(time.Duration(i)*250*time.Millisecond), int64(i)*256*1024)
+	}
+	if got := e.rate(); math.Abs(got-1024*1024) > 1 {
+		t.Errorf("rate = %.0f, want 1048576", got)
+	}
+}
+
+func TestSpeedEstimator_ReactsToChange(t *testing.T) {
+	var e speedEstimator
+	start := time.Unix(0, 0)
+	var total int64
+	// 10 seconds at 10 MB/s, then 10 seconds at 1 MB/s.
+	for i := 1; i <= 20; i++ {
+		if i <= 10 {
+			total += 10 * 1024 * 1024
+		} else {
+			total += 1024 * 1024
+		}
+		e.add(start.Add(time.Duration(i)*time.Second), total)
+	}
+	// The window only covers the slow phase; a lifetime average would be 5.5 MB/s.
+	if got := e.rate(); math.Abs(got-1024*1024) > 1 {
+		t.Errorf("rate = %.0f, want 1048576 after slowdown", got)
+	}
+	if len(e.samples) > int(speedWindow/time.Second)+2 {
+		t.Errorf("estimator kept %d samples, expected old ones to be pruned", len(e.samples))
+	}
+}
+
+func TestSpeedEstimator_Empty(t *testing.T) {
+	var e speedEstimator
+	if got := e.rate(); got != 0 {
+		t.Errorf("rate of empty estimator = %f, want 0", got)
+	}
+	e.add(time.Unix(0, 0), 100)
+	if got := e.rate(); got != 0 {
+		t.Errorf("rate with a single sample = %f, want 0", got)
+	}
+}
+
+func TestEstimateETA(t *testing.T) {
+	tests := []struct {
+		remaining int64
+		rate      float64
+		want      time.Duration
+	}{
+		{1000, 100, 10 * time.Second},
+		{1050, 100, 11 * time.Second}, // rounds up
+		{1000, 0, 0},
+		{0, 100, 0},
+		{3 * 1024 * 1024, 2 * 1024 * 1024, 2 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := estimateETA(tt.remaining, tt.rate); got != tt.want {
+			t.Errorf("estimateETA(%d, %.0f) = %v, want %v", tt.remaining, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "--:--"},
+		{5 * time.Second, "0:05"},
+		{83 * time.Second, "1:23"},
+		{3*time.Hour + 2*time.Minute + 1*time.Second, "3:02:01"},
+	}
+	for _, tt := range tests {
+		if got := formatETA(tt.d); got != tt.want {
+			t.Errorf("formatETA(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestWithProgress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024) // 4.8 MB, enough for chunks
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "v.m4s", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	for _, threads := range []int{1, 4} {
+		t.Run("threads="+strconv.Itoa(threads), func(t *testing.T) {
+			d := &Downloader{config: Config{Threads: threads}, logger: logrus.New(), client: server.Client()}
+			var mu sync.Mutex
+			var last DownloadProgress
+			ctx := WithProgress(context.Background(), func(p DownloadProgress) {
+				mu.Lock()
+				defer mu.Unlock()
+				if p.Downloaded < last.Downloaded {
+					t.Errorf("progress went back from %d to %d", last.Downloaded, p.Downloaded)
+				}
+				last = p
+			})
+
+			if err := d.downloadFile(ctx, server.URL+"/v.m4s", filepath.Join(t.TempDir(), "v.m4s")); err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+			if last.Downloaded != int64(len(content)) || last.TotalSize != int64(len(content)) {
+				t.Errorf("last update = %d/%d bytes, want %d", last.Downloaded, last.TotalSize, len(content))
+			}
+			if last.Percentage != 100 {
+				t.Errorf("last percentage = %v, want 100", last.Percentage)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/dengmengmian/goBili/logging"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// maxURLRefreshes bounds how often one file re-resolves its URL, so a CDN
+// that rejects every URL (e.g. a missing Referer) still fails.
+const maxURLRefreshes = 3
+
+// StreamRefresher fetches fresh streams for the video being downloaded. The
+// playurl links carry a deadline; once it has passed, the CDN answers 403
+// or 410 and only a new playurl call helps.
+type StreamRefresher func(ctx context.Context) ([]*parser.StreamInfo, error)
+
+// refreshKey is the context key of the active refreshState.
+type refreshKey struct{}
+
+// refreshState is the stream refresher of one video download together with
+// the stream DownloadVideoResult selected, used to pick the matching URL
+// from refreshed streams.
+type refreshState struct {
+	refresh StreamRefresher
+	stream  *parser.StreamInfo
+}
+
+// WithStreamRefresher returns a context whose downloads call refresh when
+// their stream URLs expire and then continue where they stopped.
+func WithStreamRefresher(ctx context.Context, refresh StreamRefresher) context.Context {
+	return context.WithValue(ctx, refreshKey{}, &refreshState{refresh: refresh})
+}
+
+// withSelectedStream records the selected stream in ctx's refresh state.
+func withSelectedStream(ctx context.Context, stream *parser.StreamInfo) context.Context {
+	state, ok := ctx.Value(refreshKey{}).(*refreshState)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, refreshKey{}, &refreshState{refresh: state.refresh, stream: stream})
+}
+
+// mediaURL is the URL of one file being downloaded. It is replaced when
+// the CDN reports it expired; concurrent chunks share one replacement.
+type mediaURL struct {
+	mu        sync.Mutex
+	url       string
+	gen       int // incremented on every replacement
+	refreshes int
+	state     *refreshState
+}
+
+// newMediaURL returns the replaceable URL of a file download.
+func newMediaURL(ctx context.Context, rawURL string) *mediaURL {
+	state, _ := ctx.Value(refreshKey{}).(*refreshState)
+	return &mediaURL{url: rawURL, state: state}
+}
+
+// get returns the current URL and its generation.
+func (m *mediaURL) get() (string, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.url, m.gen
+}
+
+// renew replaces the URL of generation gen after it expired. When another
+// chunk already replaced it, renew returns at once.
+func (m *mediaURL) renew(ctx context.Context, gen int, logger logging.Logger) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.gen != gen {
+		return nil
+	}
+	if m.state == nil || m.state.refresh == nil {
+		return ErrURLExpired
+	}
+	if m.refreshes >= maxURLRefreshes {
+		return fmt.Errorf("%w (still rejected after %d refreshes)", ErrURLExpired, m.refreshes)
+	}
+	m.refreshes++
+
+	logger.Warnf("Stream URL expired, fetching a new one")
+	streams, err := m.state.refresh(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to refresh expired stream URL: %w", err)
+	}
+	fresh := matchStreamURL(m.url, m.state.stream, streams)
+	if fresh == "" {
+		return fmt.Errorf("%w; the refreshed streams no longer contain it", ErrURLExpired)
+	}
+	m.url = fresh
+	m.gen++
+	return nil
+}
+
+// matchStreamURL finds the URL in streams that replaces old: the one with
+// the same path (the query carries the deadline and signature), or else
+// the same kind of track of a stream with the same quality and codecs.
+func matchStreamURL(old string, selected *parser.StreamInfo, streams []*parser.StreamInfo) string {
+	oldPath := urlPath(old)
+	for _, s := range streams {
+		for _, candidate := range streamURLs(s) {
+			if urlPath(candidate) == oldPath {
+				return candidate
+			}
+		}
+	}
+
+	if selected == nil {
+		return ""
+	}
+	for _, s := range streams {
+		if s.Quality != selected.Quality || s.VideoCodecs != selected.VideoCodecs {
+			continue
+		}
+		// Tracks first: an audio-only download's AudioURL may be any of them.
+		for i, track := range selected.AudioTracks {
+			if track.URL == old && i < len(s.AudioTracks) && s.AudioTracks[i].ID == track.ID {
+				return s.AudioTracks[i].URL
+			}
+		}
+		switch old {
+		case selected.VideoURL:
+			return s.VideoURL
+		case selected.AudioURL:
+			return s.AudioURL
+		}
+	}
+	return ""
+}
+
+// streamURLs returns every media URL of s.
+func streamURLs(s *parser.StreamInfo) []string {
+	urls := []string{s.VideoURL, s.AudioURL}
+	for _, track := range s.AudioTracks {
+		urls = append(urls, track.URL)
+	}
+	return urls
+}
+
+// urlPath returns the host-independent path of a media URL.
+func urlPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || rawURL == "" {
+		return rawURL
+	}
+	return u.Path
+}
+
+// mediaStatusError returns the error for an unexpected media response
+// status. 403 and 410 mean the URL's deadline has passed.
+func mediaStatusError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		return fmt.Errorf("%w: HTTP %d", ErrURLExpired, resp.StatusCode)
+	}
+	return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+}
@@ -0,0 +1,156 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// expiringServer serves content for URLs with deadline=new and answers 403
+// for any other deadline, like the CDN after a playurl link expired. With
+// headOK, HEAD requests succeed regardless, so the link expires between the
+// range check and the chunk requests.
+func expiringServer(t *testing.T, content []byte, headOK bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("deadline") != "new" && !(headOK && r.Method == http.MethodHead) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "v.m4s", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDownloadFile_RefreshesExpiredURL(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 300*1024) // 4.8 MB, enough for chunks
+	for _, tt := range []struct {
+		threads int
+		headOK  bool
+	}{{1, false}, {4, false}, {4, true}} {
+		threads := tt.threads
+		server := expiringServer(t, content, tt.headOK)
+		t.Run("threads="+strconv.Itoa(threads)+"/headOK="+strconv.FormatBool(tt.headOK), func(t *testing.T) {
+			d := &Downloader{config: Config{Threads: threads}, logger: logrus.New(), client: server.Client()}
+			var calls int32
+			ctx := WithStreamRefresher(context.Background(), func(ctx context.Context) ([]*parser.StreamInfo, error) {
+				atomic.AddInt32(&calls, 1)
+				return []*parser.StreamInfo{{VideoURL: server.URL + "/v.m4s?deadline=new"}}, nil
+			})
+
+			out := filepath.Join(t.TempDir(), "v.m4s")
+			if err := d.downloadFile(ctx, server.URL+"/v.m4s?deadline=old", out); err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+			data, _ := os.ReadFile(out)
+			if !bytes.Equal(data, content) {
+				t.Errorf("downloaded %d bytes, want %d", len(data), len(content))
+			}
+			if calls != 1 {
+				t.Errorf("refresher called %d times, want once for all chunks", calls)
+			}
+		})
+	}
+}
+
+func TestDownloadFile_ExpiredWithoutRefresher(t *testing.T) {
+	server := expiringServer(t, []byte("x"), false)
+	d := &Downloader{config: Config{Threads: 1}, logger: logrus.New(), client: server.Client()}
+
+	err := d.downloadFile(context.Background(), server.URL+"/v.m4s?deadline=old", filepath.Join(t.TempDir(), "v"))
+	if !errors.Is(err, ErrURLExpired) {
+		t.Errorf("error = %v, want ErrURLExpired", err)
+	}
+
+	// A refresher that keeps returning rejected URLs gives up.
+	ctx := WithStreamRefresher(context.Background(), func(ctx context.Context) ([]*parser.StreamInfo, error) {
+		return []*parser.StreamInfo{{VideoURL: server.URL + "/v.m4s?deadline=old"}}, nil
+	})
+	err = d.downloadFile(ctx, server.URL+"/v.m4s?deadline=old", filepath.Join(t.TempDir(), "v"))
+	if !errors.Is(err, ErrURLExpired) {
+		t.Errorf("error = %v, want ErrURLExpired after repeated refreshes", err)
+	}
+}
+
+func TestMatchStreamURL(t *testing.T) {
+	selected := &parser.StreamInfo{
+		Quality: 80, VideoCodecs: "avc1",
+		VideoURL: "https://a.example/v80.m4s?deadline=1",
+		AudioURL: "https://a.example/a.m4s?deadline=1",
+	}
+	fresh := []*parser.StreamInfo{
+		{Quality: 64, VideoCodecs: "avc1", VideoURL: "https://b.example/v64.m4s?deadline=2", AudioURL: "https://b.example/a.m4s?deadline=2"},
+		{Quality: 80, VideoCodecs: "avc1", VideoURL: "https://b.example/v80-new.m4s?deadline=2", AudioURL: "https://b.example/a-new.m4s?deadline=2"},
+	}
+
+	// Same path on another host.
+	if got := matchStreamURL(selected.AudioURL, selected, fresh); got != "https://b.example/a.m4s?deadline=2" {
+		t.Errorf("audio = %q", got)
+	}
+	// Different path: the same kind of track of the same quality.
+	if got := matchStreamURL(selected.VideoURL, selected, fresh); got != "https://b.example/v80-new.m4s?deadline=2" {
+		t.Errorf("video = %q", got)
+	}
+	if got := matchStreamURL("https://a.example/other.m4s", selected, fresh); got != "" {
+		t.Errorf("unknown URL matched %q", got)
+	}
+}
+
+func TestDownloadFile_ResumesAfterDroppedConnection(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1 MB
+	half := len(content) / 2
+	for _, wrongStart := range []bool{false, true} {
+		t.Run("wrongStart="+strconv.FormatBool(wrongStart), func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				switch rng := r.Header.Get("Range"); {
+				case rng == "":
+					w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+					if atomic.LoadInt32(&requests) == 1 {
+						// Drop the connection halfway through the body.
+						w.Write(content[:half])
+						panic(http.ErrAbortHandler)
+					}
+					w.Write(content)
+				case wrongStart:
+					// Ignore the requested offset and send the file again.
+					w.Header().Set("Content-Range", "bytes 0-"+strconv.Itoa(len(content)-1)+"/"+strconv.Itoa(len(content)))
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write(content)
+				default:
+					http.ServeContent(w, r, "v.m4s", time.Time{}, bytes.NewReader(content))
+				}
+			}))
+			defer server.Close()
+
+			var last DownloadProgress
+			ctx := WithProgress(context.Background(), func(p DownloadProgress) { last = p })
+			d := &Downloader{config: Config{Threads: 1, Console: io.Discard}, logger: logrus.New(), client: server.Client()}
+			out := filepath.Join(t.TempDir(), "v.m4s")
+			if err := d.downloadFile(ctx, server.URL+"/v.m4s", out); err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+			data, _ := os.ReadFile(out)
+			if !bytes.Equal(data, content) {
+				t.Errorf("downloaded %d bytes, want the %d bytes of the file", len(data), len(content))
+			}
+			if last.TotalSize != int64(len(content)) || last.Downloaded != int64(len(content)) || last.Percentage != 100 {
+				t.Errorf("last progress = %+v, want the whole file at 100%%", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// SidecarKind identifies a file written next to a download.
+type SidecarKind string
+
+// Sidecar kinds with configurable names.
+const (
+	SidecarInfo       SidecarKind = "info"        // video metadata as JSON
+	SidecarDanmaku    SidecarKind = "danmaku"     // danmaku in Bilibili's XML format
+	SidecarDanmakuASS SidecarKind = "danmaku_ass" // danmaku converted to ASS subtitles
+	SidecarSubtitle   SidecarKind = "subtitle"    // CC subtitles; "{lang}" is replaced by the language code
+	SidecarCover      SidecarKind = "cover"       // cover image
+	SidecarNFO        SidecarKind = "nfo"         // Kodi/Jellyfin NFO metadata
+	SidecarLyrics     SidecarKind = "lyrics"      // LRC lyrics of an audio track
+	SidecarCue        SidecarKind = "cue"         // cue sheet of an audio download split by chapters
+	SidecarStoryboard SidecarKind = "storyboard"  // WebVTT track of the preview thumbnails
+)
+
+// langPlaceholder is replaced by the subtitle language in sidecar suffixes.
+const langPlaceholder = "{lang}"
+
+// defaultSidecarSuffixes are appended to the output path without its
+// extension, e.g. "video.mp4" gets "video.info.json".
+var defaultSidecarSuffixes = map[SidecarKind]string{
+	SidecarInfo:       ".info.json",
+	SidecarDanmaku:    ".danmaku.xml",
+	SidecarDanmakuASS: ".danmaku.ass",
+	SidecarSubtitle:   ".{lang}.srt",
+	SidecarCover:      ".jpg",
+	SidecarNFO:        ".nfo",
+	SidecarLyrics:     ".lrc",
+	SidecarCue:        ".cue",
+	SidecarStoryboard: ".storyboard.vtt",
+}
+
+// ParseSidecarSuffixes validates user-supplied suffixes keyed by kind name,
+// e.g. {"info": ".json", "subtitle": ".srt"}. Suffixes must not contain path
+// separators, so sidecars always land next to their download.
+func ParseSidecarSuffixes(raw map[string]string) (map[SidecarKind]string, error) {
+	suffixes := make(map[SidecarKind]string, len(raw))
+	for name, suffix := range raw {
+		kind := SidecarKind(strings.ToLower(name))
+		if _, ok := defaultSidecarSuffixes[kind]; !ok {
+			return nil, fmt.Errorf("unknown sidecar kind %q (want %s)", name, strings.Join(sidecarKindNames(), ", "))
+		}
+		if suffix == "" || strings.ContainsAny(suffix, `/\`) {
+			return nil, fmt.Errorf("invalid %s sidecar suffix %q", name, suffix)
+		}
+		suffixes[kind] = suffix
+	}
+	return suffixes, nil
+}
+
+// sidecarKindNames returns the configurable kinds, sorted.
+func sidecarKindNames() []string {
+	names := make([]string, 0, len(defaultSidecarSuffixes))
+	for kind := range defaultSidecarSuffixes {
+		names = append(names, string(kind))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SidecarPath returns the path of a sidecar of the given kind for the
+// download at outputPath. lang is only used by subtitle sidecars.
+func (d *Downloader) SidecarPath(outputPath string, kind SidecarKind, lang string) string {
+	suffix, ok := d.config.SidecarSuffixes[kind]
+	if !ok {
+		suffix = defaultSidecarSuffixes[kind]
+	}
+	suffix = strings.ReplaceAll(suffix, langPlaceholder, lang)
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + suffix
+}
+
+// writeSidecars writes the configured sidecar files for a finished download.
+// Sidecars are extras: failures are logged and never fail the download.
+func (d *Downloader) writeSidecars(ctx context.Context, outputPath string, videoInfo *parser.VideoInfo, stream *parser.StreamInfo) {
+	if d.config.WriteInfoJSON {
+		path := d.SidecarPath(outputPath, SidecarInfo, "")
+		if err := writeInfoJSON(path, videoInfo); err != nil {
+			d.logger.Warnf("Failed to write info JSON: %v", err)
+		}
+	}
+	if d.config.WriteDanmaku && len(videoInfo.Danmaku) > 0 {
+		sidecars := map[SidecarKind][]byte{
+			SidecarDanmaku:    parser.EncodeDanmakuXML(videoInfo.Danmaku),
+			SidecarDanmakuASS: d.danmakuASS(videoInfo, stream),
+		}
+		for kind, data := range sidecars {
+			path := d.SidecarPath(outputPath, kind, "")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				d.logger.Warnf("Failed to write %s: %v", path, err)
+			}
+		}
+	}
+	if d.config.AudioOnly && videoInfo.Lyrics != "" {
+		path := d.SidecarPath(outputPath, SidecarLyrics, "")
+		if err := os.WriteFile(path, []byte(videoInfo.Lyrics), 0644); err != nil {
+			d.logger.Warnf("Failed to write lyrics: %v", err)
+		}
+	}
+	if d.config.WriteNFO {
+		if err := d.writeNFO(ctx, outputPath, videoInfo); err != nil {
+			d.logger.Warnf("Failed to write NFO: %v", err)
+		}
+	}
+	if d.config.Storyboard && videoInfo.Storyboard != nil {
+		if err := d.writeStoryboard(ctx, outputPath, videoInfo); err != nil {
+			d.logger.Warnf("Failed to save storyboard: %v", err)
+		}
+	}
+	if d.config.WriteCover && videoInfo.Cover != "" {
+		path := d.SidecarPath(outputPath, SidecarCover, "")
+		if err := d.fetchCover(ctx, videoInfo.Cover, path); err != nil {
+			d.logger.Warnf("Failed to save cover: %v", err)
+		}
+	}
+}
+
+// writeInfoJSON saves videoInfo as indented JSON.
+func writeInfoJSON(path string, videoInfo *parser.VideoInfo) error {
+	data, err := json.MarshalIndent(videoInfo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode video info: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSidecarPath(t *testing.T) {
+	d := &Downloader{}
+	if got := d.SidecarPath("out/v.mp4", SidecarInfo, ""); got != "out/v.info.json" {
+		t.Errorf("info = %q, want out/v.info.json", got)
+	}
+	if got := d.SidecarPath("out/v.mp4", SidecarSubtitle, "zh-CN"); got != "out/v.zh-CN.srt" {
+		t.Errorf("subtitle = %q, want out/v.zh-CN.srt", got)
+	}
+
+	d.config.SidecarSuffixes = map[SidecarKind]string{SidecarInfo: ".json", SidecarCover: "-poster.jpg"}
+	if got := d.SidecarPath("out/v.mp4", SidecarInfo, ""); got != "out/v.json" {
+		t.Errorf("custom info = %q, want out/v.json", got)
+	}
+	if got := d.SidecarPath("out/v.mp4", SidecarCover, ""); got != "out/v-poster.jpg" {
+		t.Errorf("custom cover = %q, want out/v-poster.jpg", got)
+	}
+}
+
+func TestParseSidecarSuffixes(t *testing.T) {
+	got, err := ParseSidecarSuffixes(map[string]string{"Subtitle": ".srt"})
+	if err != nil {
+		t.Fatalf("ParseSidecarSuffixes: %v", err)
+	}
+	if got[SidecarSubtitle] != ".srt" {
+		t.Errorf("subtitle suffix = %q, want .srt", got[SidecarSubtitle])
+	}
+
+	for _, raw := range []map[string]string{
+		{"thumbnail": ".jpg"},
+		{"info": "../x.json"},
+		{"nfo": ""},
+	} {
+		if _, err := ParseSidecarSuffixes(raw); err == nil {
+			t.Errorf("ParseSidecarSuffixes(%v) should fail", raw)
+		}
+	}
+}
+
+func TestWriteSidecars_InfoJSON(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "v.mp4")
+	d := &Downloader{config: Config{WriteInfoJSON: true}, logger: logrus.New()}
+
+	d.writeSidecars(context.Background(), out, &parser.VideoInfo{BVID: "BV1", Title: "T"}, &parser.StreamInfo{})
+
+	data, err := os.ReadFile(filepath.Join(dir, "v.info.json"))
+	if err != nil {
+		t.Fatalf("info JSON not written: %v", err)
+	}
+	var info parser.VideoInfo
+	if err := json.Unmarshal(data, &info); err != nil || info.BVID != "BV1" {
+		t.Errorf("info JSON = %s (%v)", data, err)
+	}
+}
+
+func TestWriteSidecars_Lyrics(t *testing.T) {
+	dir := t.TempDir()
+	info := &parser.VideoInfo{BVID: "BV1", Title: "Song", Lyrics: "[00:01.00]line\n"}
+
+	video := &Downloader{logger: logrus.New()}
+	video.writeSidecars(context.Background(), filepath.Join(dir, "v.mp4"), info, &parser.StreamInfo{})
+	if _, err := os.Stat(filepath.Join(dir, "v.lrc")); err == nil {
+		t.Error("lyrics written next to a video")
+	}
+
+	audio := &Downloader{config: Config{AudioOnly: true}, logger: logrus.New()}
+	audio.writeSidecars(context.Background(), filepath.Join(dir, "a.m4a"), info, &parser.StreamInfo{})
+	data, err := os.ReadFile(filepath.Join(dir, "a.lrc"))
+	if err != nil || string(data) != info.Lyrics {
+		t.Errorf("lyrics = %q (%v), want %q", data, err, info.Lyrics)
+	}
+}
@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// splitChapters cuts the audio download at outputPath into one file per
+// chapter of videoInfo, as for full-album uploads: "01 - Title.m4a" and
+// on, in a folder named after the download, each tagged as a track of
+// the album. The download itself is kept, with a cue sheet describing the
+// same tracks next to it. It returns the track files; a video with fewer
+// than two chapters is left whole.
+func (d *Downloader) splitChapters(ctx context.Context, outputPath string, videoInfo *parser.VideoInfo) ([]string, error) {
+	var chapters []parser.Chapter
+	for _, c := range videoInfo.Chapters {
+		if c.End > c.Start {
+			chapters = append(chapters, c)
+		}
+	}
+	if len(chapters) < 2 {
+		d.logger.Infof("No chapters to split %s at", filepath.Base(outputPath))
+		return nil, nil
+	}
+	if !d.isFFmpegAvailable() {
+		return nil, fmt.Errorf("splitting chapters requires ffmpeg; install it or point --ffmpeg-path at it")
+	}
+
+	ext := filepath.Ext(outputPath)
+	dir := strings.TrimSuffix(outputPath, ext)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create track folder: %w", err)
+	}
+	var tracks []string
+	for i, c := range chapters {
+		track := filepath.Join(dir, fmt.Sprintf("%02d - %s%s", i+1, sanitizeFilename(c.Title), ext))
+		args := []string{"-i", outputPath, "-ss", strconv.Itoa(c.Start), "-to", strconv.Itoa(c.End),
+			"-map", "0", "-map_chapters", "-1", "-c", "copy",
+			"-metadata", "title=" + c.Title,
+			"-metadata", fmt.Sprintf("track=%d/%d", i+1, len(chapters)),
+			"-metadata", "album=" + videoInfo.Title}
+		if videoInfo.Uploader != "" {
+			args = append(args, "-metadata", "artist="+videoInfo.Uploader)
+		}
+		args = append(args, "-y", track)
+		cmd := exec.CommandContext(ctx, d.ffmpegBin(), args...)
+		cmd.Stdout, cmd.Stderr = d.console()
+
+		d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+		if err := d.runLimited(ctx, cmd); err != nil {
+			return tracks, fmt.Errorf("ffmpeg failed to cut chapter %d: %w", i+1, err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	cue := d.SidecarPath(outputPath, SidecarCue, "")
+	if err := os.WriteFile(cue, []byte(cueSheet(videoInfo, filepath.Base(outputPath), chapters)), 0644); err != nil {
+		return tracks, fmt.Errorf("failed to write cue sheet: %w", err)
+	}
+	return tracks, nil
+}
+
+// cueSheet returns the cue sheet of the album audio in file, one track per
+// chapter.
+func cueSheet(videoInfo *parser.VideoInfo, file string, chapters []parser.Chapter) string {
+	var b strings.Builder
+	if videoInfo.Uploader != "" {
+		fmt.Fprintf(&b, "PERFORMER %s\n", cueString(videoInfo.Uploader))
+	}
+	fmt.Fprintf(&b, "TITLE %s\n", cueString(videoInfo.Title))
+	fileType := "WAVE" // what players expect for anything but MP3
+	if strings.EqualFold(filepath.Ext(file), ".mp3") {
+		fileType = "MP3"
+	}
+	fmt.Fprintf(&b, "FILE %s %s\n", cueString(file), fileType)
+	for i, c := range chapters {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %s\n", cueString(c.Title))
+		if videoInfo.Uploader != "" {
+			fmt.Fprintf(&b, "    PERFORMER %s\n", cueString(videoInfo.Uploader))
+		}
+		// mm:ss:ff, in frames of 1/75 s; chapters start on whole seconds.
+		fmt.Fprintf(&b, "    INDEX 01 %02d:%02d:00\n", c.Start/60, c.Start%60)
+	}
+	return b.String()
+}
+
+// cueString quotes s for a cue sheet, which has no escapes for quotes.
+func cueString(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `"`, "'"), "\n", " ") + `"`
+}
@@ -0,0 +1,69 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSplitChapters(t *testing.T) {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	ffmpeg := writeScript(t, dir, "ffmpeg", "echo \"$@\" >> "+argsFile+"\nfor last; do :; done\necho track > \"$last\"\n")
+	out := filepath.Join(dir, "Album.m4a")
+	if err := os.WriteFile(out, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := &parser.VideoInfo{Title: "Album", Uploader: "UP", Chapters: []parser.Chapter{
+		{Title: "Intro", Start: 0, End: 65},
+		{Title: "A/B", Start: 65, End: 200},
+		{Title: "empty", Start: 200, End: 200},
+	}}
+
+	d := &Downloader{config: Config{FFmpegPath: ffmpeg}, logger: logrus.New()}
+	tracks, err := d.splitChapters(context.Background(), out, info)
+	if err != nil {
+		t.Fatalf("splitChapters: %v", err)
+	}
+	want := []string{filepath.Join(dir, "Album", "01 - Intro.m4a"), filepath.Join(dir, "Album", "02 - A_B.m4a")}
+	if strings.Join(tracks, ",") != strings.Join(want, ",") {
+		t.Errorf("tracks = %q, want %q", tracks, want)
+	}
+	for _, track := range want {
+		if _, err := os.Stat(track); err != nil {
+			t.Errorf("track not written: %v", err)
+		}
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	for _, want := range []string{"-ss 65 -to 200", "title=A/B", "track=2/2", "album=Album", "artist=UP"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("ffmpeg args lack %q:\n%s", want, args)
+		}
+	}
+
+	cue, err := os.ReadFile(filepath.Join(dir, "Album.cue"))
+	if err != nil {
+		t.Fatalf("cue sheet not written: %v", err)
+	}
+	for _, want := range []string{`FILE "Album.m4a" WAVE`, "TRACK 02 AUDIO", `TITLE "A/B"`, "INDEX 01 01:05:00"} {
+		if !strings.Contains(string(cue), want) {
+			t.Errorf("cue sheet lacks %q:\n%s", want, cue)
+		}
+	}
+}
+
+func TestSplitChapters_NoChapters(t *testing.T) {
+	d := &Downloader{logger: logrus.New()}
+	tracks, err := d.splitChapters(context.Background(), filepath.Join(t.TempDir(), "a.m4a"), &parser.VideoInfo{
+		Chapters: []parser.Chapter{{Title: "All", Start: 0, End: 100}},
+	})
+	if tracks != nil || err != nil {
+		t.Errorf("splitChapters = %q, %v; want nothing", tracks, err)
+	}
+}
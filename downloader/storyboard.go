@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// storyboardSprite returns the path of sprite sheet i (from 0) of the
+// storyboard whose WebVTT index is at vttPath: "video.storyboard-01.jpg"
+// for "video.storyboard.vtt".
+func storyboardSprite(vttPath string, i int) string {
+	return fmt.Sprintf("%s-%02d.jpg", strings.TrimSuffix(vttPath, filepath.Ext(vttPath)), i+1)
+}
+
+// storyboardSprites returns the sprite sheets that exist next to the
+// storyboard index at vttPath.
+func storyboardSprites(vttPath string) []string {
+	var sprites []string
+	for i := 0; ; i++ {
+		sprite := storyboardSprite(vttPath, i)
+		if _, err := os.Stat(sprite); err != nil {
+			return sprites
+		}
+		sprites = append(sprites, sprite)
+	}
+}
+
+// writeStoryboard saves the preview thumbnails of videoInfo next to the
+// download at outputPath: the sprite sheets, and a WebVTT thumbnail track
+// pointing each stretch of the video at its tile ("sprite.jpg#xywh=..."),
+// which players and web front ends use for seek previews.
+func (d *Downloader) writeStoryboard(ctx context.Context, outputPath string, videoInfo *parser.VideoInfo) error {
+	sb := videoInfo.Storyboard
+	vttPath := d.SidecarPath(outputPath, SidecarStoryboard, "")
+	vtt, err := storyboardVTT(sb, videoInfo.Duration, func(i int) string {
+		return filepath.Base(storyboardSprite(vttPath, i))
+	})
+	if err != nil {
+		return err
+	}
+	for i, image := range sb.Images {
+		if err := d.fetchImage(ctx, image, storyboardSprite(vttPath, i), "storyboard"); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(vttPath, []byte(vtt), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", vttPath, err)
+	}
+	return nil
+}
+
+// storyboardVTT returns the WebVTT thumbnail track of sb for a video of
+// duration seconds, naming sprite sheet i by sprite(i). Thumbnails without
+// times are spread evenly over the video.
+func storyboardVTT(sb *parser.Storyboard, duration int, sprite func(int) string) (string, error) {
+	n := sb.Thumbnails()
+	perSheet := sb.Columns * sb.Rows
+	if n == 0 || perSheet == 0 {
+		return "", fmt.Errorf("storyboard has no thumbnails")
+	}
+	times := make([]float64, n)
+	for i := range times {
+		if len(sb.Times) >= n {
+			times[i] = float64(sb.Times[i])
+		} else if duration > 0 {
+			times[i] = float64(i*duration) / float64(n)
+		} else {
+			return "", fmt.Errorf("storyboard has no thumbnail times")
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, start := range times {
+		var end float64
+		switch {
+		case i+1 < n:
+			end = times[i+1]
+		case float64(duration) > start:
+			end = float64(duration)
+		case i > 0:
+			end = start + start - times[i-1]
+		default:
+			end = start + 1
+		}
+		if end <= start {
+			continue // a repeated time; the next thumbnail covers it
+		}
+		tile := i % perSheet
+		fmt.Fprintf(&b, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n", vttTimestamp(start), vttTimestamp(end),
+			sprite(i/perSheet), tile%sb.Columns*sb.Width, tile/sb.Columns*sb.Height, sb.Width, sb.Height)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,67 @@
+package downloader
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWriteSidecars_Storyboard(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sprite " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	out := filepath.Join(dir, "v.mp4")
+	info := &parser.VideoInfo{Duration: 30, Storyboard: &parser.Storyboard{
+		Images:  []string{server.URL + "/1.jpg", server.URL + "/2.jpg"},
+		Columns: 2, Rows: 2, Width: 160, Height: 90,
+		Times: []int{0, 5, 10, 15, 20},
+	}}
+	d := &Downloader{config: Config{Storyboard: true}, logger: logrus.New(), client: server.Client()}
+	d.writeSidecars(context.Background(), out, info, &parser.StreamInfo{})
+
+	vtt, err := os.ReadFile(filepath.Join(dir, "v.storyboard.vtt"))
+	if err != nil {
+		t.Fatalf("storyboard track not written: %v", err)
+	}
+	for _, want := range []string{
+		"WEBVTT\n\n00:00:00.000 --> 00:00:05.000\nv.storyboard-01.jpg#xywh=0,0,160,90\n",
+		"00:00:15.000 --> 00:00:20.000\nv.storyboard-01.jpg#xywh=160,90,160,90\n",
+		"00:00:20.000 --> 00:00:30.000\nv.storyboard-02.jpg#xywh=0,0,160,90\n",
+	} {
+		if !strings.Contains(string(vtt), want) {
+			t.Errorf("storyboard track lacks %q:\n%s", want, vtt)
+		}
+	}
+	sprite, err := os.ReadFile(filepath.Join(dir, "v.storyboard-02.jpg"))
+	if err != nil || string(sprite) != "sprite /2.jpg" {
+		t.Errorf("sprite = %q (%v)", sprite, err)
+	}
+	if files := d.outputFiles(out); len(files) != 4 {
+		t.Errorf("outputFiles = %q, want the output, track and both sprites", files)
+	}
+}
+
+func TestStoryboardVTT_NoTimes(t *testing.T) {
+	sb := &parser.Storyboard{Images: []string{"a"}, Columns: 2, Rows: 1, Width: 10, Height: 5}
+	vtt, err := storyboardVTT(sb, 60, func(i int) string { return "s.jpg" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n00:00:00.000 --> 00:00:30.000\ns.jpg#xywh=0,0,10,5\n\n00:00:30.000 --> 00:01:00.000\ns.jpg#xywh=10,0,10,5\n\n"
+	if vtt != want {
+		t.Errorf("storyboardVTT = %q, want %q", vtt, want)
+	}
+	if _, err := storyboardVTT(sb, 0, func(i int) string { return "s.jpg" }); err == nil {
+		t.Error("expected an error without times or duration")
+	}
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// canStreamMerge reports whether streaming straight into ffmpeg is possible.
+// It needs ffmpeg and an extra inherited file descriptor for the second
+// input, which os/exec does not support on Windows.
+func (d *Downloader) canStreamMerge() bool {
+	return runtime.GOOS != "windows" && d.isFFmpegAvailable()
+}
+
+// streamMerge pipes the video and audio HTTP bodies directly into ffmpeg,
+// so the merge happens while downloading and no intermediate _video/_audio
+// files are written.
+func (d *Downloader) streamMerge(ctx context.Context, stream *parser.StreamInfo, outputPath string) error {
+	d.logger.Info("Streaming video and audio into ffmpeg...")
+
+	if err := d.pipeIntoFFmpeg(ctx, stream, mergeArgs("pipe:0", "pipe:3", outputPath), nil); err != nil {
+		return err
+	}
+
+	d.logger.Infof("Successfully merged: %s", outputPath)
+	return nil
+}
+
+// StreamTo writes the selected stream to w instead of saving it, for
+// playback without a download. A single stream is copied as is; DASH video
+// and audio are muxed into Matroska on the fly, which needs ffmpeg.
+func (d *Downloader) StreamTo(ctx context.Context, w io.Writer, streams []*parser.StreamInfo) error {
+	urls, stream, err := d.ResolveURLs(ctx, streams)
+	if err != nil {
+		return err
+	}
+	d.logger.Infof("Selected stream: %s (%s)", stream.Resolution, stream.Format)
+
+	if len(urls) == 1 {
+		resp, err := d.openStream(ctx, urls[0].URL)
+		if err != nil {
+			return fmt.Errorf("failed to open %s stream: %w", urls[0].Kind, err)
+		}
+		defer resp.Body.Close()
+		if _, err := d.copy(w, resp.Body); err != nil {
+			return fmt.Errorf("failed to stream %s: %w", urls[0].Kind, err)
+		}
+		return nil
+	}
+
+	if !d.canStreamMerge() {
+		return fmt.Errorf("%w; streaming video with audio needs ffmpeg (not supported on Windows)", ErrNoMuxer)
+	}
+	args := []string{
+		"-loglevel", "error",
+		"-i", "pipe:0",
+		"-i", "pipe:3",
+		"-map", "0:v:0",
+		"-map", "1:a:0",
+		"-c", "copy",
+		"-f", "matroska",
+		"pipe:1",
+	}
+	return d.pipeIntoFFmpeg(ctx, stream, args, w)
+}
+
+// pipeIntoFFmpeg runs ffmpeg with args, feeding the video HTTP body on
+// stdin (pipe:0) and the audio body on an extra inherited descriptor
+// (pipe:3). ffmpeg's stdout goes to stdout when it is set; otherwise the
+// download progress is shown.
+func (d *Downloader) pipeIntoFFmpeg(ctx context.Context, stream *parser.StreamInfo, args []string, stdout io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	videoResp, err := d.openStream(ctx, stream.VideoURL)
+	if err != nil {
+		return fmt.Errorf("failed to open video stream: %w", err)
+	}
+	defer videoResp.Body.Close()
+
+	audioResp, err := d.openStream(ctx, stream.AudioURL)
+	if err != nil {
+		return fmt.Errorf("failed to open audio stream: %w", err)
+	}
+	defer audioResp.Body.Close()
+
+	audioR, audioW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create audio pipe: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, d.ffmpegBin(), args...)
+	if stdout != nil {
+		cmd.Stdin = videoResp.Body
+		cmd.Stdout = stdout
+	} else {
+		cmd.Stdin = &ProgressReader{Reader: videoResp.Body, Total: videoResp.ContentLength, Console: d.config.Console}
+	}
+	cmd.ExtraFiles = []*os.File{audioR}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	d.logger.Debugf("Running ffmpeg command: %s", strings.Join(cmd.Args, " "))
+
+	release, err := d.startLimited(ctx, cmd)
+	if err != nil {
+		audioR.Close()
+		audioW.Close()
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	defer release()
+	// The child has its own copy of the read end now.
+	audioR.Close()
+
+	audioDone := make(chan error, 1)
+	go func() {
+		_, err := d.copy(audioW, audioResp.Body)
+		audioW.Close() // Signal EOF to ffmpeg.
+		audioDone <- err
+	}()
+
+	waitErr := cmd.Wait()
+	audioErr := <-audioDone
+	if stdout == nil {
+		console, _ := d.console()
+		fmt.Fprintln(console)
+	}
+
+	if waitErr != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return &MergeError{Tool: "ffmpeg", Err: waitErr, Stderr: lastLines(stderr.String(), 5)}
+	}
+	if audioErr != nil && !errors.Is(audioErr, os.ErrClosed) {
+		return fmt.Errorf("failed to stream audio: %w", audioErr)
+	}
+	return nil
+}
+
+// openStream issues a GET for a media URL, retrying until the server
+// answers with 200 OK. The caller owns the response body.
+func (d *Downloader) openStream(ctx context.Context, url string) (*http.Response, error) {
+	var resp *http.Response
+
+	err := retry(ctx, defaultRetryConfig(), func() (int, error) {
+		req, err := d.newMediaRequest(ctx, "GET", url)
+		if err != nil {
+			return 0, err
+		}
+
+		r, err := d.client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		if r.StatusCode != http.StatusOK {
+			r.Body.Close()
+			return r.StatusCode, fmt.Errorf("HTTP %d: %s", r.StatusCode, r.Status)
+		}
+
+		resp = r
+		return r.StatusCode, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// lastLines returns the last n non-empty lines of s, for concise error output.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,123 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// installFakeFFmpeg puts an "ffmpeg" script on PATH that concatenates its
+// stdin (pipe:0) and fd 3 (pipe:3) into the output file (last argument).
+func installFakeFFmpeg(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("streaming merge is not supported on Windows")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\ncat > \"$last\"\ncat <&3 >> \"$last\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestStreamMerge(t *testing.T) {
+	installFakeFFmpeg(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/video.m4s":
+			w.Write([]byte("VIDEO"))
+		case "/audio.m4s":
+			w.Write([]byte("AUDIO"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	d := &Downloader{
+		config: Config{StreamMerge: true},
+		logger: logrus.New(),
+		client: server.Client(),
+	}
+	if !d.canStreamMerge() {
+		t.Fatal("canStreamMerge() = false with ffmpeg on PATH")
+	}
+
+	out := filepath.Join(t.TempDir(), "out.mp4")
+	stream := &parser.StreamInfo{VideoURL: server.URL + "/video.m4s", AudioURL: server.URL + "/audio.m4s"}
+	if err := d.streamMerge(context.Background(), stream, out); err != nil {
+		t.Fatalf("streamMerge error: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "VIDEOAUDIO" {
+		t.Errorf("merged output = %q, want VIDEOAUDIO", data)
+	}
+}
+
+func TestStreamMerge_HTTPError(t *testing.T) {
+	installFakeFFmpeg(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	d := &Downloader{logger: logrus.New(), client: server.Client()}
+	stream := &parser.StreamInfo{VideoURL: server.URL + "/v", AudioURL: server.URL + "/a"}
+	if err := d.streamMerge(context.Background(), stream, filepath.Join(t.TempDir(), "o.mp4")); err == nil {
+		t.Error("expected error for 403 stream")
+	}
+}
+
+func TestStreamTo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("streaming merge is not supported on Windows")
+	}
+	// This ffmpeg writes both inputs to stdout (pipe:1).
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\ncat\ncat <&3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.ToUpper(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".m4s"))))
+	}))
+	defer server.Close()
+
+	streams := []*parser.StreamInfo{{Quality: 80, VideoURL: server.URL + "/video.m4s", AudioURL: server.URL + "/audio.m4s"}}
+	for _, tt := range []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"dash", Config{}, "VIDEOAUDIO"},
+		{"audio only", Config{AudioOnly: true}, "AUDIO"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Downloader{config: tt.config, logger: logrus.New(), client: server.Client()}
+			var out bytes.Buffer
+			if err := d.StreamTo(context.Background(), &out, streams); err != nil {
+				t.Fatalf("StreamTo: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("output = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
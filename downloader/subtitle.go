@@ -0,0 +1,151 @@
+package downloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Subtitle file formats CC subtitles can be converted to.
+const (
+	SubtitleSRT = "srt"
+	SubtitleVTT = "vtt"
+	SubtitleASS = "ass"
+)
+
+// ValidateSubtitleFormat checks a subtitle format name.
+func ValidateSubtitleFormat(format string) error {
+	switch format {
+	case SubtitleSRT, SubtitleVTT, SubtitleASS:
+		return nil
+	}
+	return fmt.Errorf("invalid subtitle format %q (want srt, vtt or ass)", format)
+}
+
+// bccLine is one cue of a subtitle in Bilibili's JSON (BCC) format.
+type bccLine struct {
+	From    float64 `json:"from"`
+	To      float64 `json:"to"`
+	Content string  `json:"content"`
+}
+
+// FetchSubtitle downloads a CC subtitle in Bilibili's JSON format and
+// converts it to format.
+func (d *Downloader) FetchSubtitle(ctx context.Context, subURL, format string) ([]byte, error) {
+	if err := ValidateSubtitleFormat(format); err != nil {
+		return nil, err
+	}
+	req, err := d.newMediaRequest(ctx, "GET", subURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch subtitles: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subtitles: %w", err)
+	}
+	return ConvertSubtitle(data, format)
+}
+
+// fetchSubtitle downloads a CC subtitle and saves it to dest as SRT.
+func (d *Downloader) fetchSubtitle(ctx context.Context, subURL, dest string) error {
+	srt, err := d.FetchSubtitle(ctx, subURL, SubtitleSRT)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, srt, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dest, err)
+	}
+	return nil
+}
+
+// ConvertSubtitle converts Bilibili's JSON subtitles to format.
+func ConvertSubtitle(data []byte, format string) ([]byte, error) {
+	var bcc struct {
+		Body []bccLine `json:"body"`
+	}
+	if err := json.Unmarshal(data, &bcc); err != nil {
+		return nil, fmt.Errorf("invalid subtitle file: %w", err)
+	}
+	switch format {
+	case SubtitleSRT:
+		return bccToSRT(bcc.Body), nil
+	case SubtitleVTT:
+		return bccToVTT(bcc.Body), nil
+	case SubtitleASS:
+		return bccToASS(bcc.Body), nil
+	}
+	return nil, ValidateSubtitleFormat(format)
+}
+
+// bccToSRT renders subtitle cues as SRT.
+func bccToSRT(lines []bccLine) []byte {
+	var b strings.Builder
+	for i, line := range lines {
+		b.WriteString(strconv.Itoa(i + 1))
+		b.WriteString("\n")
+		b.WriteString(srtTimestamp(line.From) + " --> " + srtTimestamp(line.To))
+		b.WriteString("\n")
+		b.WriteString(strings.TrimSpace(line.Content))
+		b.WriteString("\n\n")
+	}
+	return []byte(b.String())
+}
+
+// bccToVTT renders subtitle cues as WebVTT.
+func bccToVTT(lines []bccLine) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, line := range lines {
+		b.WriteString(vttTimestamp(line.From) + " --> " + vttTimestamp(line.To))
+		b.WriteString("\n")
+		// A blank line would end the cue early.
+		b.WriteString(strings.ReplaceAll(strings.TrimSpace(line.Content), "\n\n", "\n"))
+		b.WriteString("\n\n")
+	}
+	return []byte(b.String())
+}
+
+// bccToASS renders subtitle cues as ASS, at the bottom of a 1920x1080
+// frame.
+func bccToASS(lines []bccLine) []byte {
+	var b strings.Builder
+	b.WriteString("[Script Info]\nScriptType: v4.00+\nPlayResX: 1920\nPlayResY: 1080\nWrapStyle: 0\nScaledBorderAndShadow: yes\n\n")
+	b.WriteString("[V4+ Styles]\n")
+	b.WriteString("Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n")
+	b.WriteString("Style: Default,Microsoft YaHei,60,&H00FFFFFF,&H00FFFFFF,&H00000000,&H80000000,0,0,0,0,100,100,0,0,1,3,0,2,40,40,50,1\n\n")
+	b.WriteString("[Events]\n")
+	b.WriteString("Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n")
+	for _, line := range lines {
+		rows := strings.Split(strings.TrimSpace(line.Content), "\n")
+		for i, row := range rows {
+			rows[i] = assEscape(row)
+		}
+		text := strings.Join(rows, `\N`)
+		fmt.Fprintf(&b, "Dialogue: 0,%s,%s,Default,,0,0,0,,%s\n", assTimestamp(line.From), assTimestamp(line.To), text)
+	}
+	return []byte(b.String())
+}
+
+// srtTimestamp formats seconds as an SRT timestamp, e.g. 00:01:02,500.
+func srtTimestamp(seconds float64) string {
+	ms := int64(seconds*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// vttTimestamp formats seconds as a WebVTT timestamp, e.g. 00:01:02.500.
+func vttTimestamp(seconds float64) string {
+	return strings.Replace(srtTimestamp(seconds), ",", ".", 1)
+}
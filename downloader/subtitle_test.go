@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"strings"
+	"testing"
+)
+
+var testBCC = []byte(`{"body":[{"from":0.5,"to":2,"content":"你好"},{"from":3661.25,"to":3662,"content":" bye\n{now} "}]}`)
+
+func TestConvertSubtitle_SRT(t *testing.T) {
+	got, err := ConvertSubtitle(testBCC, SubtitleSRT)
+	if err != nil {
+		t.Fatalf("ConvertSubtitle: %v", err)
+	}
+	want := "1\n00:00:00,500 --> 00:00:02,000\n你好\n\n2\n01:01:01,250 --> 01:01:02,000\nbye\n{now}\n\n"
+	if string(got) != want {
+		t.Errorf("srt =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestConvertSubtitle_VTT(t *testing.T) {
+	got, err := ConvertSubtitle(testBCC, SubtitleVTT)
+	if err != nil {
+		t.Fatalf("ConvertSubtitle: %v", err)
+	}
+	want := "WEBVTT\n\n00:00:00.500 --> 00:00:02.000\n你好\n\n01:01:01.250 --> 01:01:02.000\nbye\n{now}\n\n"
+	if string(got) != want {
+		t.Errorf("vtt =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestConvertSubtitle_ASS(t *testing.T) {
+	got, err := ConvertSubtitle(testBCC, SubtitleASS)
+	if err != nil {
+		t.Fatalf("ConvertSubtitle: %v", err)
+	}
+	for _, want := range []string{
+		"Dialogue: 0,0:00:00.50,0:00:02.00,Default,,0,0,0,,你好\n",
+		"Dialogue: 0,1:01:01.25,1:01:02.00,Default,,0,0,0,,bye\\N｛now｝\n",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("ass output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConvertSubtitle_Invalid(t *testing.T) {
+	if _, err := ConvertSubtitle([]byte("not json"), SubtitleSRT); err == nil {
+		t.Error("ConvertSubtitle should reject invalid input")
+	}
+	if _, err := ConvertSubtitle(testBCC, "sub"); err == nil {
+		t.Error("ConvertSubtitle should reject unknown formats")
+	}
+}
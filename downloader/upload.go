@@ -0,0 +1,65 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// outputSidecars are the sidecar kinds that follow their output file:
+// they are uploaded with it and share its modification time.
+var outputSidecars = []SidecarKind{SidecarInfo, SidecarDanmaku, SidecarDanmakuASS, SidecarCover, SidecarNFO, SidecarLyrics, SidecarCue, SidecarStoryboard}
+
+// outputFiles returns the output file and the sidecars that exist next to
+// it, output first.
+func (d *Downloader) outputFiles(outputPath string) []string {
+	files := []string{outputPath}
+	for _, kind := range outputSidecars {
+		path := d.SidecarPath(outputPath, kind, "")
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	files = append(files, storyboardSprites(d.SidecarPath(outputPath, SidecarStoryboard, ""))...)
+	return files
+}
+
+// remoteName returns the slash-separated name of path relative to the
+// output directory, so uploads keep the local layout.
+func (d *Downloader) remoteName(path string) string {
+	rel, err := filepath.Rel(d.config.OutputDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// upload copies a finished download and its sidecars to the configured
+// uploader and records the remote location of the output in result.
+func (d *Downloader) upload(ctx context.Context, result *Result) error {
+	files := append(d.outputFiles(result.Path), result.Tracks...)
+	for i, path := range files {
+		name := d.remoteName(path)
+		d.logger.Infof("Uploading %s", name)
+		location, err := d.config.Uploader.Upload(ctx, path, name)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, err)
+		}
+		if i == 0 {
+			result.Uploaded = location
+			d.logger.Infof("Uploaded to %s", location)
+		}
+	}
+	return nil
+}
+
+// removeUploaded deletes the local copies of an uploaded download.
+func (d *Downloader) removeUploaded(result *Result) {
+	for _, path := range d.outputFiles(result.Path) {
+		if err := os.Remove(path); err != nil {
+			d.logger.Warnf("Failed to remove %s after upload: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fakeUploader records the remote names it was asked to upload.
+type fakeUploader struct {
+	names []string
+}
+
+func (f *fakeUploader) Upload(ctx context.Context, localPath, name string) (string, error) {
+	if _, err := os.Stat(localPath); err != nil {
+		return "", err
+	}
+	f.names = append(f.names, name)
+	return "remote:" + name, nil
+}
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "UP", "Title.mp4")
+	os.MkdirAll(filepath.Dir(out), 0755)
+	for _, path := range []string{out, filepath.Join(dir, "UP", "Title.info.json")} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	up := &fakeUploader{}
+	d := &Downloader{config: Config{OutputDir: dir, Uploader: up}, logger: logrus.New()}
+	result := &Result{Path: out}
+
+	if err := d.upload(context.Background(), result); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if len(up.names) != 2 || up.names[0] != "UP/Title.mp4" || up.names[1] != "UP/Title.info.json" {
+		t.Errorf("uploaded %v", up.names)
+	}
+	if result.Uploaded != "remote:UP/Title.mp4" {
+		t.Errorf("Uploaded = %q", result.Uploaded)
+	}
+
+	d.removeUploaded(result)
+	if fileExists(out) || fileExists(filepath.Join(dir, "UP", "Title.info.json")) {
+		t.Error("local files should be removed after upload")
+	}
+}
+
+func TestRemoteName_OutsideOutputDir(t *testing.T) {
+	d := &Downloader{config: Config{OutputDir: "/data/videos"}}
+	if got := d.remoteName("/tmp/elsewhere/v.mp4"); got != "v.mp4" {
+		t.Errorf("remoteName = %q, want v.mp4", got)
+	}
+}
@@ -0,0 +1,108 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// defaultTempDirName is the working directory created inside OutputDir when
+// no TempDir is configured. Keeping it on the same filesystem guarantees the
+// final rename is atomic.
+const defaultTempDirName = ".goBili-tmp"
+
+// WorkDir returns the directory used for intermediate files.
+func (d *Downloader) WorkDir() string {
+	if d.config.TempDir != "" {
+		return d.config.TempDir
+	}
+	return filepath.Join(d.config.OutputDir, defaultTempDirName)
+}
+
+// cleanupWorkDir removes the default working directory once it is empty.
+// A user-configured TempDir is left in place.
+func (d *Downloader) cleanupWorkDir(dir string) {
+	if d.config.TempDir != "" {
+		return
+	}
+	// os.Remove fails on non-empty directories, which is what we want when
+	// another download is still using it.
+	_ = os.Remove(dir)
+}
+
+// keepPartial reports whether the intermediate files of a download should
+// survive because it was interrupted and KeepTemp is set.
+func (d *Downloader) keepPartial(ctx context.Context) bool {
+	return d.config.KeepTemp && ctx.Err() != nil
+}
+
+// removeJobDir removes the working directory of one download with the
+// intermediate files (separate streams, subtitles, danmaku) left in it,
+// unless the download was interrupted and KeepTemp is set.
+func (d *Downloader) removeJobDir(ctx context.Context, dir string) {
+	if d.keepPartial(ctx) {
+		d.logger.Infof("Keeping partial files in %s", dir)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		d.logger.Warnf("Failed to remove partial files in %s: %v", dir, err)
+	}
+}
+
+// moveIntoPlace moves a finished file from the working directory to its
+// final location. A plain rename is atomic on the same filesystem; when the
+// working directory lives on another disk, the file is first copied next to
+// the destination under a hidden name and then renamed, so the output
+// directory never exposes a partially written file.
+func (d *Downloader) moveIntoPlace(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		d.logger.Debugf("Moved %s to %s", src, dst)
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create staging file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if err := copyAndSync(src, tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close staging file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+	if err := os.Remove(src); err != nil {
+		d.logger.Warnf("failed to remove temporary file %s: %v", src, err)
+	}
+
+	d.logger.Debugf("Copied %s to %s", src, dst)
+	return nil
+}
+
+// copyAndSync copies src into dst and flushes dst to stable storage.
+func copyAndSync(src string, dst *os.File) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %w", err)
+	}
+	defer in.Close()
+
+	if _, err := io.Copy(dst, in); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	return nil
+}
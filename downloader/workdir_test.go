@@ -0,0 +1,119 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWorkDir(t *testing.T) {
+	d := &Downloader{config: Config{OutputDir: "out"}}
+	if got, want := d.WorkDir(), filepath.Join("out", defaultTempDirName); got != want {
+		t.Errorf("WorkDir() = %q, want %q", got, want)
+	}
+
+	d.config.TempDir = "/scratch"
+	if got := d.WorkDir(); got != "/scratch" {
+		t.Errorf("WorkDir() with TempDir = %q, want /scratch", got)
+	}
+}
+
+func TestMoveIntoPlace(t *testing.T) {
+	work := t.TempDir()
+	out := t.TempDir()
+	d := &Downloader{logger: logrus.New()}
+
+	src := filepath.Join(work, "video.mp4")
+	if err := os.WriteFile(src, []byte("payload"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(out, "video.mp4")
+
+	if err := d.moveIntoPlace(src, dst); err != nil {
+		t.Fatalf("moveIntoPlace error: %v", err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("destination missing: %v", err)
+	}
+	if string(data) != "payload" {
+		t.Errorf("destination content = %q, want payload", data)
+	}
+	if fileExists(src) {
+		t.Error("source file should be gone after move")
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("output dir has %d entries, want only the final file", len(entries))
+	}
+}
+
+func TestCleanupWorkDir(t *testing.T) {
+	out := t.TempDir()
+	d := &Downloader{config: Config{OutputDir: out}}
+	dir := d.WorkDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	d.cleanupWorkDir(dir)
+	if fileExists(dir) {
+		t.Error("empty default work dir should be removed")
+	}
+
+	custom := t.TempDir()
+	d.config.TempDir = custom
+	d.cleanupWorkDir(custom)
+	if !fileExists(custom) {
+		t.Error("configured TempDir must not be removed")
+	}
+}
+
+func TestRemoveJobDir(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		keep     bool
+		wantKept bool
+	}{
+		{"finished", context.Background(), true, false},
+		{"interrupted", canceled, false, false},
+		{"interrupted with KeepTemp", canceled, true, true},
+	}
+	for _, tt := range tests {
+		work := t.TempDir()
+		dir, err := os.MkdirTemp(work, "dl-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "v [1080P]_video.mp4"), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		other := filepath.Join(work, "dl-other", "v [1080P]_video.mp4")
+		if err := os.MkdirAll(filepath.Dir(other), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(other, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		d := &Downloader{config: Config{KeepTemp: tt.keep}, logger: logrus.New()}
+		d.removeJobDir(tt.ctx, dir)
+		if fileExists(dir) != tt.wantKept {
+			t.Errorf("%s: job dir exists = %v, want %v", tt.name, !tt.wantKept, tt.wantKept)
+		}
+		if !fileExists(other) {
+			t.Errorf("%s: files of another download with the same name must be left alone", tt.name)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package httpclient builds the HTTP transport of goBili's clients. The
+// auth manager, the parser and the downloaders share one transport, so a
+// playlist run reuses the TLS connections to the API and the CDN hosts
+// across requests, chunks and episodes instead of dialing anew; Go's
+// default transport keeps only two idle connections per host and closes
+// the others as soon as more requests run in parallel.
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxIdleConnsPerHost is the number of idle connections kept per host,
+	// enough for the parallel chunks of a video and its audio.
+	MaxIdleConnsPerHost = 32
+	// MaxIdleConns is the number of idle connections kept in total.
+	MaxIdleConns = 128
+)
+
+// NewTransport returns a transport with connection reuse tuned for many
+// parallel requests to few hosts, HTTP/2 where the server offers it, and
+// timeouts for each step up to the response headers, so that a stalled
+// connection fails instead of hanging. The transport has no overall
+// timeout: downloads last as long as their context. Requests go through
+// the proxy of their context (see WithProxy), or else that of the
+// environment.
+func NewTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: requestProxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          MaxIdleConns,
+		MaxIdleConnsPerHost:   MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
+
+// proxyKey is the context key of WithProxy.
+type proxyKey struct{}
+
+// WithProxy returns a context whose requests go through proxy on the
+// transports of NewTransport, whatever the environment says, so that a
+// few requests, such as the API calls for region-locked videos, can take
+// another route than the rest of the traffic on the same transport.
+func WithProxy(ctx context.Context, proxy *url.URL) context.Context {
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// requestProxy returns the proxy of req: that of its context, or else that
+// of the environment.
+func requestProxy(req *http.Request) (*url.URL, error) {
+	if proxy, _ := req.Context().Value(proxyKey{}).(*url.URL); proxy != nil {
+		return proxy, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// Shared returns the transport shared by the clients of the process,
+// created by NewTransport on first use.
+var Shared = sync.OnceValue(NewTransport)
+
+// NewClient returns a client on the shared transport with timeout for
+// each request, including reading the body; 0 means none.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: Shared(), Timeout: timeout}
+}
@@ -0,0 +1,118 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newCountingServer returns a TLS server answering small bodies and a
+// counter of the connections it accepted.
+func newCountingServer(t testing.TB) (*httptest.Server, *atomic.Int64) {
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 4096))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, &conns
+}
+
+// trusting returns tr trusting the test server's certificate.
+func trusting(tr *http.Transport, server *httptest.Server) *http.Transport {
+	tr.TLSClientConfig = &tls.Config{RootCAs: server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	return tr
+}
+
+// fetch sends rounds of parallel GETs to server, as the chunks of a
+// playlist's segments would.
+func fetch(t testing.TB, client *http.Client, server *httptest.Server, parallel, rounds int) {
+	for i := 0; i < rounds; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < parallel; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func TestNewTransport_ReusesConnections(t *testing.T) {
+	server, conns := newCountingServer(t)
+	tr := trusting(NewTransport(), server)
+	tr.ForceAttemptHTTP2 = false // one connection per parallel request
+	defer tr.CloseIdleConnections()
+
+	fetch(t, &http.Client{Transport: tr}, server, 8, 10)
+	if n := conns.Load(); n > 8 {
+		t.Errorf("80 requests, 8 at a time, opened %d connections, want at most 8", n)
+	}
+}
+
+func TestShared(t *testing.T) {
+	if Shared() != Shared() {
+		t.Error("Shared returned different transports")
+	}
+	if c := NewClient(0); c.Transport != Shared() {
+		t.Error("NewClient does not use the shared transport")
+	}
+}
+
+// BenchmarkSmallSegments compares Go's default transport settings with
+// NewTransport on parallel requests for small bodies.
+func BenchmarkSmallSegments(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		new  func() *http.Transport
+	}{
+		{"default", func() *http.Transport { return http.DefaultTransport.(*http.Transport).Clone() }},
+		{"tuned", NewTransport},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			server, conns := newCountingServer(b)
+			tr := trusting(bc.new(), server)
+			tr.ForceAttemptHTTP2 = false
+			defer tr.CloseIdleConnections()
+			client := &http.Client{Transport: tr}
+
+			b.ResetTimer()
+			fetch(b, client, server, 8, b.N)
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.example:8080")
+	req := httptest.NewRequest("GET", "https://api.bilibili.com/x/player/playurl", nil)
+
+	got, err := NewTransport().Proxy(req.WithContext(WithProxy(context.Background(), proxy)))
+	if err != nil || got != proxy {
+		t.Errorf("Proxy = %v, %v; want %v", got, err, proxy)
+	}
+	env, _ := http.ProxyFromEnvironment(req)
+	if got, err := NewTransport().Proxy(req); err != nil || got != env {
+		t.Errorf("Proxy without a context proxy = %v, %v; want the environment's %v", got, err, env)
+	}
+}
@@ -0,0 +1,145 @@
+// Package i18n translates goBili's user-facing messages.
+//
+// Messages are written in English in the code, and that text is the key
+// under which the other catalogs hold their translation, gettext style:
+// the English catalog is the source itself. Format strings keep their
+// verbs in the same order, so a translation may move words around the
+// arguments but not the arguments themselves.
+//
+// The language is English unless SetLanguage selects another; Detect
+// picks one from GOBILI_LANG and the locale.
+package i18n
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Language is a language with a message catalog.
+type Language string
+
+// Supported languages.
+const (
+	English Language = "en"
+	Chinese Language = "zh"
+)
+
+// catalogs maps a language to its translations, keyed by the English
+// message. English needs no catalog.
+var catalogs = map[Language]map[string]string{
+	Chinese: zh,
+}
+
+var (
+	mu      sync.RWMutex
+	current = English
+)
+
+// Parse returns the language named by s: a language code such as "zh" or
+// "en", a locale such as "zh_CN.UTF-8", or "chinese"/"english".
+func Parse(s string) (Language, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(name, "_-.@"); i >= 0 {
+		name = name[:i]
+	}
+	switch name {
+	case "en", "english", "c", "posix":
+		return English, nil
+	case "zh", "cn", "chinese":
+		return Chinese, nil
+	}
+	return "", fmt.Errorf("unsupported language %q (want en or zh)", s)
+}
+
+// Detect returns the language chosen by GOBILI_LANG or, failing that, by
+// the LC_ALL, LC_MESSAGES and LANG locale variables. Unsupported or unset
+// values mean English.
+func Detect() Language {
+	if lang, err := Parse(os.Getenv("GOBILI_LANG")); err == nil {
+		return lang
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(env); value != "" {
+			// The first variable set wins, as in setlocale.
+			if lang, err := Parse(value); err == nil {
+				return lang
+			}
+			return English
+		}
+	}
+	return English
+}
+
+// SetLanguage selects the language of the messages.
+func SetLanguage(lang Language) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = lang
+}
+
+// Current returns the selected language.
+func Current() Language {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// T returns the translation of msg, or msg itself when the current
+// language has none.
+func T(msg string) string {
+	mu.RLock()
+	catalog := catalogs[current]
+	mu.RUnlock()
+	if translated, ok := catalog[msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// Sprintf formats the translation of format.
+func Sprintf(format string, a ...interface{}) string {
+	return fmt.Sprintf(T(format), a...)
+}
+
+// Printf prints the translation of format to standard output.
+func Printf(format string, a ...interface{}) {
+	fmt.Printf(T(format), a...)
+}
+
+// Println prints the translation of msg and a newline to standard output.
+func Println(msg string) {
+	fmt.Println(T(msg))
+}
+
+// Fprintf prints the translation of format to w.
+func Fprintf(w io.Writer, format string, a ...interface{}) {
+	fmt.Fprintf(w, T(format), a...)
+}
+
+// Fprintln prints the translation of msg and a newline to w.
+func Fprintln(w io.Writer, msg string) {
+	fmt.Fprintln(w, T(msg))
+}
+
+// Errorf is fmt.Errorf with the translation of format; %w wraps as usual.
+func Errorf(format string, a ...interface{}) error {
+	return fmt.Errorf(T(format), a...)
+}
+
+// NewError returns an error whose message is translated when it is read,
+// for sentinel errors created before the language is chosen. Like errors
+// made by errors.New, each call returns a distinct error.
+func NewError(msg string) error {
+	return &message{msg: msg}
+}
+
+type message struct {
+	msg string
+}
+
+func (m *message) Error() string {
+	return T(m.msg)
+}
@@ -0,0 +1,180 @@
+package i18n
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Language
+	}{
+		{"en", English},
+		{"EN", English},
+		{"en_US.UTF-8", English},
+		{"C", English},
+		{"POSIX", English},
+		{"zh", Chinese},
+		{"zh_CN.UTF-8", Chinese},
+		{"zh-TW", Chinese},
+		{" chinese ", Chinese},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "fr", "ja_JP.UTF-8"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want Language
+	}{
+		{map[string]string{}, English},
+		{map[string]string{"LANG": "zh_CN.UTF-8"}, Chinese},
+		{map[string]string{"LC_ALL": "en_US.UTF-8", "LANG": "zh_CN.UTF-8"}, English},
+		{map[string]string{"LC_ALL": "fr_FR.UTF-8", "LANG": "zh_CN.UTF-8"}, English},
+		{map[string]string{"GOBILI_LANG": "zh", "LC_ALL": "en_US.UTF-8"}, Chinese},
+		{map[string]string{"GOBILI_LANG": "klingon", "LANG": "zh_CN"}, Chinese},
+	}
+	for _, tt := range tests {
+		for _, env := range []string{"GOBILI_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+			t.Setenv(env, tt.env[env])
+		}
+		if got := Detect(); got != tt.want {
+			t.Errorf("Detect() with %v = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	defer SetLanguage(English)
+
+	err := NewError("no audio stream available for this video")
+	if got := T("Password: "); got != "Password: " {
+		t.Errorf("English T = %q", got)
+	}
+
+	SetLanguage(Chinese)
+	if got := T("Password: "); got != zh["Password: "] {
+		t.Errorf("Chinese T = %q, want %q", got, zh["Password: "])
+	}
+	if got := T("a message without a translation"); got != "a message without a translation" {
+		t.Errorf("T of an unknown message = %q", got)
+	}
+	if got := err.Error(); got != zh["no audio stream available for this video"] {
+		t.Errorf("NewError after SetLanguage = %q", got)
+	}
+	wrapped := Errorf("failed to parse URL: %w", err)
+	if !errors.Is(wrapped, err) {
+		t.Error("Errorf does not wrap its %w argument")
+	}
+}
+
+// translatedCalls are the functions taking a message, with the index of
+// the message among their arguments.
+var translatedCalls = map[string]int{
+	"T": 0, "Sprintf": 0, "Printf": 0, "Println": 0, "Errorf": 0, "NewError": 0,
+	"Fprintf": 1, "Fprintln": 1,
+}
+
+// messages returns the literal messages passed to this package in the
+// non-test Go files of dirs.
+func messages(t *testing.T, dirs ...string) map[string]string {
+	t.Helper()
+	found := make(map[string]string)
+	for _, dir := range dirs {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range files {
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, name, nil, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				arg, ok := translatedCalls[sel.Sel.Name]
+				if !ok || len(call.Args) <= arg {
+					return true
+				}
+				if pkg, ok := sel.X.(*ast.Ident); !ok || pkg.Name != "i18n" {
+					return true
+				}
+				lit, ok := call.Args[arg].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				msg, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				found[msg] = fset.Position(lit.Pos()).String()
+				return true
+			})
+		}
+	}
+	return found
+}
+
+func TestCatalogComplete(t *testing.T) {
+	used := messages(t, "..", "../cmd", "../auth", "../downloader")
+	if len(used) == 0 {
+		t.Fatal("found no translated messages")
+	}
+	for lang, catalog := range catalogs {
+		for msg, pos := range used {
+			if _, ok := catalog[msg]; !ok {
+				t.Errorf("%s: %q has no %s translation", pos, msg, lang)
+			}
+		}
+		for msg := range catalog {
+			if _, ok := used[msg]; !ok {
+				t.Errorf("%s catalog translates %q, which is not used", lang, msg)
+			}
+		}
+	}
+}
+
+var verb = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]*)?[a-zA-Z%]`)
+
+func TestCatalogVerbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, translated := range catalog {
+			want := strings.Join(verb.FindAllString(msg, -1), " ")
+			if got := strings.Join(verb.FindAllString(translated, -1), " "); got != want {
+				t.Errorf("%s translation of %q has verbs %q, want %q", lang, msg, got, want)
+			}
+			if strings.HasSuffix(msg, "\n") != strings.HasSuffix(translated, "\n") {
+				t.Errorf("%s translation of %q does not keep the trailing newline", lang, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package i18n
+
+// zh is the Simplified Chinese catalog.
+var zh = map[string]string{
+	// Errors and messages shared by every command.
+	"Error:":      "错误：",
+	"Error: %v\n": "错误：%v\n",
+	"%s failed":   "%s失败",
+	" for %s":     "（%s）",
+	"user input":  "用户输入",
+	"Check the command syntax with 'goBili help'":                                                "请用 'goBili help' 检查命令用法",
+	"authentication required: please login first using 'goBili login'":                           "需要登录：请先运行 'goBili login' 登录",
+	"network timeout: check your internet connection and try again":                              "网络超时：请检查网络连接后重试",
+	"server error: the remote server is temporarily unavailable, try again later":                "服务器错误：远程服务器暂时不可用，请稍后重试",
+	"disk full or write permission denied: check available space and permissions":                "磁盘已满或没有写入权限：请检查可用空间和权限",
+	"invalid URL: the provided Bilibili URL could not be parsed":                                 "无效的链接：无法解析该哔哩哔哩链接",
+	"output file already exists: use --force-overwrite to replace it":                            "输出文件已存在：使用 --force-overwrite 覆盖",
+	"no audio stream available for this video":                                                   "该视频没有音频流",
+	"stream URL expired or was rejected by the CDN":                                              "流地址已过期或被 CDN 拒绝",
+	"no muxer available: install ffmpeg or MP4Box, or point --ffmpeg-path/--mp4box-path at them": "没有可用的合并工具：请安装 ffmpeg 或 MP4Box，或用 --ffmpeg-path/--mp4box-path 指定其路径",
+	"failed to load cookies: %w":                                                                 "加载 Cookie 失败：%w",
+	"Failed to load cookies: %v":                                                                 "加载 Cookie 失败：%v",
+
+	// download
+	"--audio-only and --video-only cannot be combined": "--audio-only 和 --video-only 不能同时使用",
+	"--split-chapters needs --audio-only":              "--split-chapters 需要配合 --audio-only 使用",
+	"unsupported format %q (want mp4, flv or mkv)":     "不支持的格式 %q（可选 mp4、flv 或 mkv）",
+	"failed to create output directory: %w":            "创建输出目录失败：%w",
+	"unsupported naming scheme %q (want %s)":           "不支持的命名方式 %q（可选 %s）",
+	"Quality %s needs a login; using %s":               "画质 %s 需要登录，改用 %s",
+	"Downloading without login: quality is limited to 480p, and members-only or region-locked videos will fail. Run 'goBili login' for full access.": "未登录下载：画质最高 480p，会员专享或有地区限制的视频会下载失败。运行 'goBili login' 登录以获得完整权限。",
+	"Not authenticated. Please login first using: goBili login":                                                                                      "未登录。请先运行以下命令登录：goBili login",
+	"Or pass --allow-anonymous to download at up to 480p without an account.":                                                                        "或者加上 --allow-anonymous，不登录下载最高 480p 的视频。",
+	"invalid sidecar_suffixes: %w":                                      "无效的 sidecar_suffixes：%w",
+	"failed to parse URL: %w":                                           "解析链接失败：%w",
+	"Download history disabled: %v":                                     "下载历史已停用：%v",
+	"unsupported content type: %s":                                      "不支持的内容类型：%s",
+	"\nInterrupted; partial files were kept in %s\n":                    "\n已中断，未完成的文件保留在 %s\n",
+	"\nInterrupted; partial files were removed.":                        "\n已中断，未完成的文件已删除。",
+	"\n==> URL %d of %d: %s\n":                                          "\n==> 第 %d/%d 个链接：%s\n",
+	"Downloading video: %s\n":                                           "正在下载视频：%s\n",
+	"Detected multi-part video with %d parts\n":                         "检测到多分P视频，共 %d 个分P\n",
+	"failed to get video streams: %w":                                   "获取视频流失败：%w",
+	"Note: this video has no audio stream; it was saved as video only.": "注意：该视频没有音频流，只保存了视频。",
+	"Downloading playlist: %s (%d episodes)\n":                          "正在下载合集：%s（共 %d 集）\n",
+	"\n[%d/%d] Downloading: %s\n":                                       "\n[%d/%d] 正在下载：%s\n",
+	"Downloading all %d seasons\n":                                      "正在下载全部 %d 季\n",
+	"\n==> Season %d of %d: %s\n":                                       "\n==> 第 %d/%d 季：%s\n",
+	"failed to look up season %s: %w":                                   "查询季度 %s 失败：%w",
+	"Failed to download episode %s: %v":                                 "下载剧集 %s 失败：%v",
+	"Playlist download completed!":                                      "合集下载完成！",
+	"invalid pages parameter: %w":                                       "无效的分P参数：%w",
+	"no pages found for video":                                          "该视频没有分P",
+	"Failed to fetch danmaku for %s: %v":                                "获取 %s 的弹幕失败：%v",
+	"Failed to fetch storyboard for %s: %v":                             "获取 %s 的视频快照失败：%v",
+	"invalid %s: want a command or a list of commands":                  "无效的 %s：应为一条命令或命令列表",
+	"invalid exec flag: %w":                                             "无效的 exec 参数：%w",
+	"invalid upload target: %w":                                         "无效的上传目标：%w",
+	"invalid skip-existing flag: %w":                                    "无效的 skip-existing 参数：%w",
+	"invalid force-overwrite flag: %w":                                  "无效的 force-overwrite 参数：%w",
+	"invalid existing setting %q (want rename, skip or overwrite)":      "无效的 existing 设置 %q（可选 rename、skip 或 overwrite）",
+	"invalid range format: %s":                                          "无效的范围格式：%s",
+	"invalid start page: %s":                                            "无效的起始分P：%s",
+	"invalid end page: %s":                                              "无效的结束分P：%s",
+	"start page (%d) cannot be greater than end page (%d)":              "起始分P（%d）不能大于结束分P（%d）",
+	"invalid page number: %s":                                           "无效的分P编号：%s",
+	"Qualities of %s:":                                                  "%s 的可选画质：",
+	"Choose a quality [1-%d, Enter for 1, q to quit]: ":                 "选择画质 [1-%d，回车选 1，q 退出]：",
+	"Enter a number from 1 to %d.":                                      "请输入 1 到 %d 之间的数字。",
+	"\rDownloading: %.1f%% (%.2f/%.2f MB) %s/s ETA %s":                  "\r下载中：%.1f%%（%.2f/%.2f MB）%s/s 剩余 %s",
+	"\rDownloading: %.2f MB %s/s":                                       "\r下载中：%.2f MB %s/s",
+
+	// login
+	"Failed to load existing cookies: %v":                               "加载已有 Cookie 失败：%v",
+	"Failed to get user info: %v":                                       "获取用户信息失败：%v",
+	"You appear to be logged in, but user info could not be retrieved.": "你似乎已经登录，但无法获取用户信息。",
+	"You may need to re-login.":                                         "可能需要重新登录。",
+	"Already logged in as: %s (UID: %d)\n":                              "已登录：%s（UID：%d）\n",
+	"Use --force flag to force re-login if needed.":                     "如需重新登录，请使用 --force 参数。",
+	"invalid cookie-file flag: %w":                                      "无效的 cookie-file 参数：%w",
+	"invalid browser flag: %w":                                          "无效的 browser 参数：%w",
+	"invalid tv flag: %w":                                               "无效的 tv 参数：%w",
+	"invalid qr-output flag: %w":                                        "无效的 qr-output 参数：%w",
+	"password login failed: %w":                                         "密码登录失败：%w",
+	"SMS login failed: %w":                                              "短信登录失败：%w",
+	"Starting TV QR code login...":                                      "开始 TV 端二维码登录……",
+	"TV login failed: %w":                                               "TV 端登录失败：%w",
+	"Starting browser login...":                                         "开始浏览器登录……",
+	"browser login failed: %w":                                          "浏览器登录失败：%w",
+	"Loading cookies from file: %s\n":                                   "从文件加载 Cookie：%s\n",
+	"failed to load cookies from file: %w":                              "从文件加载 Cookie 失败：%w",
+	"Failed to save cookies: %v":                                        "保存 Cookie 失败：%v",
+	"Starting QR code login...":                                         "开始二维码登录……",
+	"QR code login failed: %w":                                          "二维码登录失败：%w",
+	"login verification failed: %w":                                     "登录验证失败：%w",
+	"Login successful! Welcome, %s (UID: %d)":                           "登录成功！欢迎，%s（UID：%d）",
+	"Saved to profile: %s\n":                                            "已保存到账号配置：%s\n",
+	"User level: %d\n":                                                  "用户等级：%d\n",
+	"VIP status: Active":                                                "大会员状态：有效",
+	"cookie file does not exist: %s":                                    "Cookie 文件不存在：%s",
+	"failed to read cookie file: %w":                                    "读取 Cookie 文件失败：%w",
+	"no valid cookies found in file":                                    "文件中没有有效的 Cookie",
+	"Loaded %d cookies from file\n":                                     "已从文件加载 %d 个 Cookie\n",
+	"=== Browser Login Mode ===":                                        "=== 浏览器登录模式 ===",
+	"This mode opens your browser for Bilibili login, then you extract cookies manually.": "此模式会打开浏览器登录哔哩哔哩，之后需要你手动提取 Cookie。",
+	"Opening browser: %s\n":      "正在打开浏览器：%s\n",
+	"Failed to open browser: %v": "打开浏览器失败：%v",
+	"Please manually open: %s\n": "请手动打开：%s\n",
+	"Complete the login in your browser, then follow these steps to extract cookies:": "请在浏览器中完成登录，然后按以下步骤提取 Cookie：",
+	"1. After login, press F12 to open Developer Tools":                               "1. 登录后按 F12 打开开发者工具",
+	"2. Go to the 'Application' or 'Storage' tab":                                     "2. 切换到“应用”（Application）或“存储”（Storage）标签页",
+	"3. Find 'Cookies' -> 'https://www.bilibili.com' in the sidebar":                  "3. 在侧边栏找到 Cookies -> https://www.bilibili.com",
+	"4. Copy the values of these cookies:":                                            "4. 复制以下 Cookie 的值：",
+	"5. Save them as a tab-separated text file:":                                      "5. 将它们保存为以制表符分隔的文本文件：",
+	"6. Import the cookies:":                                                          "6. 导入 Cookie：",
+	"Press Enter to continue, or type 'q' to quit: ":                                  "按回车继续，或输入 q 退出：",
+	"login canceled":      "已取消登录",
+	"user canceled login": "用户取消了登录",
+	"please extract cookies manually and use the -c flag to import them": "请手动提取 Cookie，并用 -c 参数导入",
+	"unsupported platform: %s":          "不支持的平台：%s",
+	"Account (phone or email): ":        "账号（手机号或邮箱）：",
+	"Password: ":                        "密码：",
+	"account and password are required": "账号和密码不能为空",
+	"Phone number: ":                    "手机号：",
+	"phone number is required":          "手机号不能为空",
+	"Code sent to +%d %s\n":             "验证码已发送至 +%d %s\n",
+	"SMS code: ":                        "短信验证码：",
+	"SMS code is required":              "短信验证码不能为空",
+	"\nSolve the captcha in a browser (it can be another device):": "\n请在浏览器中完成人机验证（可以在其他设备上）：",
+	"Then paste the values it shows.":                              "然后粘贴页面显示的值。",
+	"captcha was not solved":                                       "人机验证未完成",
+	"failed to read password: %w":                                  "读取密码失败：%w",
+
+	// Login session checks
+	"Your login session has expired. Please login again using: goBili login": "登录已过期。请运行以下命令重新登录：goBili login",
+	"Could not verify login session: %v":                                     "无法验证登录状态：%v",
+	"run 'goBili login' to renew it":                                         "运行 'goBili login' 续期",
+	"it will be refreshed automatically when Bilibili allows":                "哔哩哔哩允许时会自动刷新",
+	"Login session expires in %s (%s); %s":                                   "登录将在 %s 后过期（%s）；%s",
+	"Failed to refresh login cookies: %v":                                    "刷新登录 Cookie 失败：%v",
+	"Refreshed login cookies":                                                "已刷新登录 Cookie",
+
+	// QR code login
+	"Scan the QR code with the Bilibili mobile app to log in:\n": "请使用哔哩哔哩手机客户端扫描二维码登录：\n",
+	"QR code URL: %s\n":                                      "二维码链接：%s\n",
+	"Or visit: %s\n":                                         "或访问：%s\n",
+	"\nWaiting for scan...":                                  "\n等待扫码……",
+	"\nQR code expired; generating a new one...":             "\n二维码已过期，正在生成新的二维码……",
+	"Login successful!":                                      "登录成功！",
+	"\nQR code scanned. Please confirm login on your phone.": "\n已扫码，请在手机上确认登录。",
+	"\n=== QR Code ===":                                      "\n=== 二维码 ===",
+	"=== QR Code ===":                                        "=== 二维码 ===",
+	"Unable to display QR code in terminal; please use the link above.": "无法在终端中显示二维码，请使用上面的链接。",
+	"QR code image saved to %s\n":                                       "二维码图片已保存到 %s\n",
+
+	// logout
+	"No active login session found.":                                        "没有找到有效的登录会话。",
+	"Currently logged in (user info unavailable)":                           "当前已登录（无法获取用户信息）",
+	"Currently logged in as: %s (UID: %d)\n":                                "当前登录账号：%s（UID：%d）\n",
+	"invalid force flag: %w":                                                "无效的 force 参数：%w",
+	"Are you sure you want to logout? (y/N): ":                              "确定要退出登录吗？(y/N)：",
+	"Logout canceled.":                                                      "已取消退出登录。",
+	"invalid local-only flag: %w":                                           "无效的 local-only 参数：%w",
+	"Failed to revoke session on Bilibili: %v":                              "在哔哩哔哩注销会话失败：%v",
+	"Session could not be revoked on Bilibili, removing local cookies only": "无法在哔哩哔哩注销会话，仅删除本地 Cookie",
+	"Session revoked on Bilibili":                                           "已在哔哩哔哩注销会话",
+	"failed to remove cookie file: %w":                                      "删除 Cookie 文件失败：%w",
+	"Cookie file removed":                                                   "已删除 Cookie 文件",
+	"No cookie file found":                                                  "没有找到 Cookie 文件",
+	"failed to remove %s: %w":                                               "删除 %s 失败：%w",
+	"Login session cleared":                                                 "已清除登录会话",
+	"You will need to login again to download videos.":                      "下载视频前需要重新登录。",
+
+	// status
+	"Profile:  %s\n":                                            "账号配置：%s\n",
+	"Status:   not logged in":                                   "状态：    未登录",
+	"Run 'goBili login' to authenticate.":                       "运行 'goBili login' 登录。",
+	"failed to get account status: %w":                          "获取账号状态失败：%w",
+	"Status:   session expired":                                 "状态：    登录已过期",
+	"Run 'goBili login' to log in again.":                       "运行 'goBili login' 重新登录。",
+	"Status:   logged in":                                       "状态：    已登录",
+	"User:     %s (UID: %d)\n":                                  "用户：    %s（UID：%d）\n",
+	"VIP:      %s\n":                                            "大会员：    %s\n",
+	"Level:    %s\n":                                            "等级：    %s\n",
+	"Coins:    %g\n":                                            "硬币：    %g\n",
+	"Session:  expires %s (in %s)\n":                            "会话：    %s 过期（剩余 %s）\n",
+	"Session:  expiry unknown":                                  "会话：    过期时间未知",
+	"Refresh:  enabled (cookies renew automatically)":           "刷新：    已启用（Cookie 自动续期）",
+	"Refresh:  unavailable (log in with the QR code to enable)": "刷新：    不可用（使用二维码登录后启用）",
+
+	// report
+	"failed to encode report: %w": "编码报告失败：%w",
+	"failed to write report: %w":  "写入报告失败：%w",
+	"Report written to %s\n":      "报告已写入 %s\n",
+
+	// premiere
+	"%s goes live at %s; run again then, or add --wait to wait for it": "%s 将于 %s 上线；届时再次运行，或加上 --wait 等待上线",
+	"Waiting for %s to go live at %s (in %s)\n":                        "等待 %s 于 %s 上线（还有 %s）\n",
+	"Failed to check whether %s is out: %v":                            "检查 %s 是否已上线失败：%v",
+
+	// quality fallback
+	"unknown quality fallback %q (want %s)": "未知的清晰度回退策略 %q（应为 %s）",
+
+	// account
+	"not logged in":       "未登录",
+	"logged in":           "已登录",
+	"logged in (UID: %s)": "已登录（UID：%s）",
+	"\n--profile overrides the saved profile (%s) for this command.\n":      "\n--profile 在本次命令中覆盖已保存的账号配置（%s）。\n",
+	"profile %q does not exist; create it with 'goBili login --profile %s'": "账号配置 %q 不存在；请用 'goBili login --profile %s' 创建",
+	"failed to load cookies for profile %s: %w":                             "加载账号配置 %s 的 Cookie 失败：%w",
+	"Switched to profile %s, but it has no saved session.\n":                "已切换到账号配置 %s，但它没有保存的会话。\n",
+	"Run 'goBili login' to authenticate this profile.\n":                    "运行 'goBili login' 登录此账号配置。\n",
+	"Switched to profile %s, but its session could not be validated.\n":     "已切换到账号配置 %s，但无法验证其会话。\n",
+	"You may need to log out and log in again for this profile.":            "可能需要为此账号配置注销后重新登录。",
+	"Switched to profile %s\n":                                              "已切换到账号配置 %s\n",
+	"Subsequent commands will use: %s (UID: %d)\n":                          "后续命令将使用：%s（UID：%d）\n",
+
+	// batch
+	"--resume cannot be combined with new URLs":                "--resume 不能与新的链接一起使用",
+	"no URLs given: pass them as arguments or with --input":    "未提供链接：请作为参数传入或使用 --input",
+	"batch jobs cannot stream to stdout":                       "批量任务不能输出到标准输出",
+	"\nJob %s: %d completed, %d failed, %d pending\n":          "\n任务 %s：%d 个完成，%d 个失败，%d 个待下载\n",
+	"Resume with: goBili batch --resume %s\n":                  "继续下载：goBili batch --resume %s\n",
+	"%d of %d downloads failed":                                "%d 个下载失败（共 %d 个）",
+	"Job %s: %d videos\n":                                      "任务 %s：%d 个视频\n",
+	"damaged job entry":                                        "任务条目已损坏",
+	"failed to open URL list: %w":                              "打开链接列表失败：%w",
+	"failed to read URL list: %w":                              "读取链接列表失败：%w",
+	"No saved jobs.":                                           "没有保存的任务。",
+	"%s  %s  %d completed, %d failed, %d pending  (%d URLs)\n": "%s  %s  %d 个完成，%d 个失败，%d 个待下载  （%d 个链接）\n",
+
+	// comments
+	"invalid format %q (want json or csv)":             "无效的格式 %q（应为 json 或 csv）",
+	"invalid sort %q (want time, likes or replies)":    "无效的排序 %q（应为 time、likes 或 replies）",
+	"invalid --pages %d (want 0 for all, or more)":     "无效的 --pages %d（0 表示全部，或更大的数）",
+	"-o - writes one video; choose it with --episodes": "-o - 只能写出一个视频；请用 --episodes 选择",
+	"failed to write comments: %w":                     "写入评论失败：%w",
+	"failed to save comments: %w":                      "保存评论失败：%w",
+	"Saved %d comments and %d replies to %s\n":         "已将 %d 条评论和 %d 条回复保存到 %s\n",
+	"failed to fetch the comments of %d of %d videos":  "%d 个视频的评论获取失败（共 %d 个）",
+	"failed to encode comments: %w":                    "编码评论失败：%w",
+
+	// config
+	"%s is not set":                                           "%s 未设置",
+	"Set %s in %s\n":                                          "已设置 %s，保存于 %s\n",
+	"failed to encode config: %w":                             "编码配置失败：%w",
+	"invalid value %q: %w":                                    "无效的值 %q：%w",
+	"invalid config file %s: %w":                              "无效的配置文件 %s：%w",
+	"invalid key %q":                                          "无效的键 %q",
+	"%s is not a section":                                     "%s 不是配置节",
+	"failed to write config file: %w":                         "写入配置文件失败：%w",
+	"failed to read config file: %w":                          "读取配置文件失败：%w",
+	"# no config file, showing defaults":                      "# 没有配置文件，显示默认值",
+	"%s failed: %w":                                           "%s 执行失败：%w",
+	"invalid config file %s: the top level must be a mapping": "无效的配置文件 %s：顶层必须是映射",
+
+	// download
+	"--interactive takes a single URL":                      "--interactive 只接受一个链接",
+	"--choose-quality cannot be combined with --format-id":  "--choose-quality 不能与 --format-id 一起使用",
+	"--choose-quality cannot be combined with --audio-only": "--choose-quality 不能与 --audio-only 一起使用",
+	"--choose-quality needs an interactive terminal":        "--choose-quality 需要交互式终端",
+	"no URLs given": "未提供链接",
+	"--json cannot be combined with streaming to stdout": "--json 不能与输出到标准输出一起使用",
+	"streaming to stdout takes a single URL":             "输出到标准输出只接受一个链接",
+	"%d of %d URLs failed":                               "%d 个链接失败（共 %d 个）",
+	"%d of %d videos failed":                             "%d 个视频失败（共 %d 个）",
+
+	// serve
+	"failed to read API token: %w":          "读取 API 令牌失败：%w",
+	"failed to generate API token: %w":      "生成 API 令牌失败：%w",
+	"failed to create config directory: %w": "创建配置目录失败：%w",
+	"failed to save API token: %w":          "保存 API 令牌失败：%w",
+	"Generated an API token in %s\n":        "已在 %s 中生成 API 令牌\n",
+}
@@ -0,0 +1,92 @@
+// Package logging is the logger interface of goBili's packages. The auth
+// manager, parser, downloader and server log through a Logger, so programs
+// embedding them choose the format and destination: a *logrus.Logger
+// implements it as is, and FromSlog adapts a log/slog Logger.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Logger is the subset of *logrus.Logger the packages use.
+type Logger interface {
+	Debug(args ...interface{})
+	Info(args ...interface{})
+	Warn(args ...interface{})
+	Error(args ...interface{})
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Discard is a Logger that drops everything.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Debug(...interface{})          {}
+func (discard) Info(...interface{})           {}
+func (discard) Warn(...interface{})           {}
+func (discard) Error(...interface{})          {}
+func (discard) Debugf(string, ...interface{}) {}
+func (discard) Infof(string, ...interface{})  {}
+func (discard) Warnf(string, ...interface{})  {}
+func (discard) Errorf(string, ...interface{}) {}
+
+// OrDiscard returns l, or Discard when l is nil.
+func OrDiscard(l Logger) Logger {
+	if l == nil {
+		return Discard
+	}
+	return l
+}
+
+// FromSlog returns a Logger writing to l, with the message formatted as
+// the logrus methods would. A nil l uses slog.Default().
+func FromSlog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct{ l *slog.Logger }
+
+// log writes msg at level, attributing it to the caller of the Logger
+// method, as slog.Logger's own methods do.
+func (s slogLogger) log(level slog.Level, msg string) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip Callers, log and the Logger method
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	_ = s.l.Handler().Handle(ctx, r)
+}
+
+func (s slogLogger) Debug(args ...interface{}) { s.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func (s slogLogger) Info(args ...interface{})  { s.log(slog.LevelInfo, fmt.Sprint(args...)) }
+func (s slogLogger) Warn(args ...interface{})  { s.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func (s slogLogger) Error(args ...interface{}) { s.log(slog.LevelError, fmt.Sprint(args...)) }
+
+func (s slogLogger) Debugf(format string, args ...interface{}) {
+	s.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Infof(format string, args ...interface{}) {
+	s.log(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Warnf(format string, args ...interface{}) {
+	s.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (s slogLogger) Errorf(format string, args ...interface{}) {
+	s.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,47 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The packages accept the logrus loggers of the command unchanged.
+var _ Logger = logrus.New()
+
+func TestFromSlog(t *testing.T) {
+	var buf bytes.Buffer
+	l := FromSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo, AddSource: true})))
+
+	l.Debugf("hidden %d", 1)
+	l.Infof("Downloading %s (%d/%d)", "BV1xx", 1, 2)
+	l.Warn("disk ", "almost full")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") {
+		t.Errorf("debug message logged at info level:\n%s", out)
+	}
+	for _, want := range []string{
+		`level=INFO`, `msg="Downloading BV1xx (1/2)"`,
+		`level=WARN`, `msg="disk almost full"`,
+		"logging_test.go", // the caller, not the adapter
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestOrDiscard(t *testing.T) {
+	if OrDiscard(nil) != Discard {
+		t.Error("OrDiscard(nil) is not Discard")
+	}
+	l := logrus.New()
+	if OrDiscard(l) != l {
+		t.Error("OrDiscard replaced a logger")
+	}
+	Discard.Errorf("dropped %v", 1)
+}
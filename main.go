@@ -5,7 +5,28 @@
 // Usage:
 //
 //	goBili login           authenticate via QR code
+//	goBili status          show the login state and account info
 //	goBili download <URL>  download a video or playlist
+//	goBili <URL>           same as download
+//	goBili tui [URL]       pick parts and quality in a terminal UI
+//	goBili info <URL>      show metadata and available qualities
+//	goBili formats <URL>   list every video and audio format
+//	goBili danmaku <URL>   save the danmaku as XML, ASS or JSON
+//	goBili subtitle <URL>  list or save the CC subtitles
+//	goBili cover <URL>     save the full-size cover image
+//	goBili batch -i FILE   download a list of URLs as a resumable job
+//	goBili play <URL>      watch a video in mpv without saving it
+//	goBili verify [DIR]    re-check downloads against sha256sums.txt or, with
+//	                       --probe, look for truncated files with ffprobe
+//	goBili history         list, search and prune the download history
+//	goBili stats           summarize the history and the output's disk usage
+//	goBili config set K V  change a setting in ~/.goBili.yaml
+//	goBili queue add <URL> queue a download for the daemon
+//	goBili daemon          run the queued downloads
+//	goBili subscribe add X follow an uploader (mid) or a season (ss<id>)
+//	goBili watch           download new videos and episodes of subscriptions
+//	goBili serve           run a download daemon with an HTTP API
+//	goBili doctor          check ffmpeg, the network, the login and the config
 //	goBili version         print version information
 package main
 
@@ -14,11 +35,15 @@ import (
 	"os"
 
 	"github.com/dengmengmian/goBili/cmd"
+	"github.com/dengmengmian/goBili/i18n"
+
+	_ "github.com/go-sql-driver/mysql" // MySQL driver for mysql:// state DSNs
+	_ "github.com/jackc/pgx/v5/stdlib" // PostgreSQL driver for postgres:// state DSNs
 )
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, i18n.T("Error: %v\n"), err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestBvidToAID(t *testing.T) {
+	tests := []struct {
+		bvid string
+		want int64
+	}{
+		{"BV17x411w7KC", 170001},
+		{"BV1L9Uoa9EUx", 111298867365120},
+	}
+	for _, tt := range tests {
+		got, err := bvidToAID(tt.bvid)
+		if err != nil || got != tt.want {
+			t.Errorf("bvidToAID(%s) = %d, %v; want %d", tt.bvid, got, err, tt.want)
+		}
+	}
+	if _, err := bvidToAID("BV1short"); err == nil {
+		t.Error("expected an error for a malformed BV ID")
+	}
+}
+
+// tvAuthManager returns an AuthManager loaded with a TV login.
+func tvAuthManager(t *testing.T) *auth.AuthManager {
+	t.Helper()
+	dir := t.TempDir()
+	cookies := `{"SESSDATA":"s","bili_jct":"j"}`
+	key := `{"access_token":"tv-token","expires_at":` + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10) + `}`
+	if err := os.WriteFile(filepath.Join(dir, "cookies.json"), []byte(cookies), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, auth.AccessKeyFile), []byte(key), 0600); err != nil {
+		t.Fatal(err)
+	}
+	am := auth.NewAuthManager(dir, logrus.New())
+	if err := am.LoadCookies(); err != nil {
+		t.Fatal(err)
+	}
+	return am
+}
+
+func TestGetVideoStreams_PrefersAppAPI(t *testing.T) {
+	var webCalls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x/tv/ugc/playurl" {
+			webCalls++
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("access_key") != "tv-token" || q.Get("object_id") != "170001" || q.Get("sign") == "" {
+			t.Errorf("app playurl query = %v", q)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"dash": map[string]interface{}{
+					"video": []map[string]interface{}{{"id": 80, "base_url": "https://v/app.m4s", "width": 1920, "height": 1080}},
+					"audio": []map[string]interface{}{{"id": 30280, "base_url": "https://a/app.m4s"}},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: tvAuthManager(t),
+		logger:      logrus.New(),
+	}
+
+	streams, err := p.getVideoStreamsByCID("BV17x411w7KC", 1)
+	if err != nil {
+		t.Fatalf("getVideoStreamsByCID: %v", err)
+	}
+	if len(streams) != 1 || streams[0].VideoURL != "https://v/app.m4s" || streams[0].AudioURL != "https://a/app.m4s" {
+		t.Fatalf("streams = %+v, want the app stream", streams)
+	}
+	if webCalls != 0 {
+		t.Errorf("web API called %d times", webCalls)
+	}
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dengmengmian/goBili/httpclient"
+)
+
+// ErrAreaRestricted is returned when a video cannot be played from the
+// region of the request (地区限制), such as bangumi licensed for Hong
+// Kong, Macau and Taiwan only.
+var ErrAreaRestricted = errors.New("video is not available in this area")
+
+// areaRestrictedCodes are playurl codes meaning the video is locked to
+// other regions: -10403 (抱歉您所在地区不可观看) and 6002003 (地区限制).
+var areaRestrictedCodes = map[int]bool{-10403: true, 6002003: true}
+
+// areaHints are words of the titles of region-locked bangumi, e.g.
+// "某番（僅限港澳台地區）", and the areas they name, most specific last.
+var areaHints = []struct {
+	word  string
+	areas []string
+}{
+	{"港澳台", []string{"hk", "tw"}},
+	{"港澳", []string{"hk"}},
+	{"台灣", []string{"tw"}},
+	{"台湾", []string{"tw"}},
+}
+
+// SetAreaProxies sets proxies for videos locked to other regions, keyed
+// by area, e.g. {"hk": "http://127.0.0.1:8080", "tw": "socks5://..."}.
+// A playurl request refused for the area of the request is retried
+// through the proxies of the areas named by the title of the video (hk
+// for 港澳, tw for 台灣), then through the others in name order; the first
+// that works serves the rest of the video's playurl requests. Media
+// downloads and the other API calls stay direct. The proxies take effect
+// on the transports of httpclient. An empty map removes them.
+func (p *BilibiliParser) SetAreaProxies(proxies map[string]string) error {
+	parsed := make(map[string]*url.URL, len(proxies))
+	for area, raw := range proxies {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy %q for area %s", raw, area)
+		}
+		parsed[strings.ToLower(area)] = u
+	}
+	p.areaProxies = parsed
+	return nil
+}
+
+// inArea routes req through the proxy of the area chosen for bvid, if
+// any.
+func (p *BilibiliParser) inArea(req *http.Request, bvid string) *http.Request {
+	proxy := p.areaProxies[p.sessions.session(bvid).area]
+	if proxy == nil {
+		return req
+	}
+	return req.WithContext(httpclient.WithProxy(req.Context(), proxy))
+}
+
+// streamsInArea retries the playurl request of cid, refused with err,
+// through the area proxies in the order of areaOrder. It returns err when
+// none is configured or every one is refused too.
+func (p *BilibiliParser) streamsInArea(videoInfo *VideoInfo, cid int64, err error) ([]*StreamInfo, error) {
+	if !errors.Is(err, ErrAreaRestricted) {
+		return nil, err
+	}
+	tried := p.sessions.session(videoInfo.BVID).area
+	for _, area := range p.areaOrder(videoInfo) {
+		if area == tried {
+			continue
+		}
+		p.logger.Infof("%s is locked to another area, retrying through the %s proxy", videoInfo.BVID, area)
+		p.sessions.setArea(videoInfo.BVID, area)
+		streams, areaErr := p.getVideoStreamsByCID(videoInfo.BVID, cid)
+		if areaErr == nil {
+			return streams, nil
+		}
+		if !errors.Is(areaErr, ErrAreaRestricted) {
+			return nil, areaErr
+		}
+	}
+	p.sessions.setArea(videoInfo.BVID, "")
+	return nil, err
+}
+
+// areaOrder returns the configured areas to try for videoInfo: those its
+// title names first, then the rest by name.
+func (p *BilibiliParser) areaOrder(videoInfo *VideoInfo) []string {
+	var order []string
+	seen := make(map[string]bool)
+	add := func(area string) {
+		if p.areaProxies[area] != nil && !seen[area] {
+			seen[area] = true
+			order = append(order, area)
+		}
+	}
+	title := videoInfo.Title + " " + videoInfo.Series + " " + videoInfo.Show
+	for _, hint := range areaHints {
+		if strings.Contains(title, hint.word) {
+			for _, area := range hint.areas {
+				add(area)
+			}
+			break
+		}
+	}
+	rest := make([]string, 0, len(p.areaProxies))
+	for area := range p.areaProxies {
+		rest = append(rest, area)
+	}
+	sort.Strings(rest)
+	for _, area := range rest {
+		add(area)
+	}
+	return order
+}
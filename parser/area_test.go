@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/httpclient"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGetVideoStreams_AreaProxy(t *testing.T) {
+	var routes []string
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		proxy, _ := httpclient.NewTransport().Proxy(req)
+		rec := httptest.NewRecorder()
+		if proxy == nil || proxy.Host != "tw:1080" {
+			routes = append(routes, "direct")
+			rec.WriteString(`{"code":-10403,"message":"抱歉您所在地区不可观看！"}`)
+			return rec.Result(), nil
+		}
+		routes = append(routes, proxy.Host)
+		rec.WriteString(`{"code":0,"data":{"dash":{
+			"video":[{"id":80,"baseUrl":"https://v/video.m4s"}],
+			"audio":[{"id":30280,"baseUrl":"https://v/audio.m4s"}]}}}`)
+		return rec.Result(), nil
+	})
+	authMgr := auth.NewAuthManager(t.TempDir(), logrus.New())
+	authMgr.SetCookie("SESSDATA", "s")
+	p := NewBilibiliParserWithClient(authMgr, logrus.New(), client)
+
+	info := &VideoInfo{BVID: "BV1qt4y1X7TW", Title: "某番（僅限台灣地區）", Pages: []*PageInfo{{CID: 1}, {CID: 2}}}
+	if _, err := p.GetVideoStreamsForPage(info, 1); !errors.Is(err, ErrAreaRestricted) {
+		t.Fatalf("without proxies: err = %v, want ErrAreaRestricted", err)
+	}
+
+	if err := p.SetAreaProxies(map[string]string{"hk": "http://hk:1080", "tw": "socks5://tw:1080"}); err != nil {
+		t.Fatal(err)
+	}
+	routes = nil
+	streams, err := p.GetVideoStreamsForPage(info, 1)
+	if err != nil || len(streams) == 0 {
+		t.Fatalf("GetVideoStreamsForPage = %v, %v", streams, err)
+	}
+	// The next page goes straight through the proxy that worked.
+	if _, err := p.GetVideoStreamsForPage(info, 2); err != nil {
+		t.Fatalf("page 2: %v", err)
+	}
+	if want := []string{"direct", "tw:1080", "tw:1080"}; !reflect.DeepEqual(routes, want) {
+		t.Errorf("routes = %v, want %v", routes, want)
+	}
+}
+
+func TestAreaOrder(t *testing.T) {
+	p := &BilibiliParser{}
+	if err := p.SetAreaProxies(map[string]string{"tw": "http://tw:1", "hk": "http://hk:1", "cn": "http://cn:1"}); err != nil {
+		t.Fatal(err)
+	}
+	for title, want := range map[string][]string{
+		"某番（僅限港澳台地區）": {"hk", "tw", "cn"},
+		"某番（僅限台灣地區）":  {"tw", "cn", "hk"},
+		"某番":          {"cn", "hk", "tw"},
+	} {
+		if got := p.areaOrder(&VideoInfo{Title: title}); !reflect.DeepEqual(got, want) {
+			t.Errorf("areaOrder(%s) = %v, want %v", title, got, want)
+		}
+	}
+	if err := p.SetAreaProxies(map[string]string{"hk": "hk:1080"}); err == nil {
+		t.Error("expected an error for a proxy without a scheme")
+	}
+}
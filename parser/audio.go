@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/auth"
+)
+
+// audioBase is the base URL of the API of the audio zone (音频区).
+const audioBase = "https://www.bilibili.com/audio/music-service-c/web"
+
+// maxLyricsSize caps the lyrics read for a track; LRC files are a few KB.
+const maxLyricsSize = 1 << 20
+
+// audioExtractor handles /audio/au... track URLs of the audio zone. A
+// track is downloaded from the video it was published with, carrying the
+// track's title, artist, cover and lyrics.
+type audioExtractor struct{ p *BilibiliParser }
+
+var songIDRegex = regexp.MustCompile(`/audio/au(\d+)`)
+
+func (e audioExtractor) Match(u *url.URL) bool {
+	return songIDRegex.MatchString(u.Path)
+}
+
+func (e audioExtractor) Extract(ctx context.Context, u *url.URL) (*VideoInfo, error) {
+	sid, err := strconv.ParseInt(songIDRegex.FindStringSubmatch(u.Path)[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not extract audio ID from URL: %w", err)
+	}
+	return e.p.getSongInfo(ctx, sid)
+}
+
+// songData is the data of the song info API (song/info).
+type songData struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Author   string `json:"author"` // the singer
+	Uname    string `json:"uname"`  // the uploader
+	UID      int64  `json:"uid"`
+	Intro    string `json:"intro"`
+	Cover    string `json:"cover"`
+	Lyric    string `json:"lyric"`    // URL of the LRC file, if any
+	Passtime int64  `json:"passtime"` // publish time, Unix seconds
+	BVID     string `json:"bvid"`     // the video of the track, if any
+	CID      int64  `json:"cid"`
+}
+
+// getSongInfo looks up audio track sid and the part of its video that
+// holds it.
+func (p *BilibiliParser) getSongInfo(ctx context.Context, sid int64) (*VideoInfo, error) {
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", fmt.Sprintf("%s/song/info?sid=%d", audioBase, sid), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	apiResp, err := decodeAPI[songData](resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckResponse("get audio info", apiResp.Code, apiResp.Message); err != nil {
+		return nil, err
+	}
+	song := &apiResp.Data
+	if song.BVID == "" {
+		return nil, fmt.Errorf("audio au%d has no video to download it from", sid)
+	}
+
+	videoInfo, err := p.getVideoInfo(ctx, song.BVID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	// Keep only the part of the track, for multi-part videos.
+	for _, page := range videoInfo.Pages {
+		if page.CID == song.CID {
+			videoInfo.Pages = []*PageInfo{page}
+			break
+		}
+	}
+	videoInfo.Type = "video"
+	videoInfo.Title = song.Title
+	videoInfo.Uploader, videoInfo.OwnerMID = song.Uname, song.UID
+	if song.Author != "" {
+		videoInfo.Uploader = song.Author
+	}
+	if song.Intro != "" {
+		videoInfo.Desc = song.Intro
+	}
+	if song.Cover != "" {
+		videoInfo.Cover = song.Cover
+	}
+	if song.Passtime > 0 {
+		videoInfo.PubDate = song.Passtime
+	}
+	if song.Lyric != "" {
+		// Lyrics are a nicety; the track downloads without them.
+		if videoInfo.Lyrics, err = p.fetchLyrics(ctx, song.Lyric); err != nil {
+			p.logger.Warnf("Failed to fetch lyrics of au%d: %v", sid, err)
+		}
+	}
+	return videoInfo, nil
+}
+
+// fetchLyrics downloads the LRC file at lyricURL.
+func (p *BilibiliParser) fetchLyrics(ctx context.Context, lyricURL string) (string, error) {
+	// The API often returns http:// URLs; the CDN serves the same over TLS.
+	lyricURL = strings.Replace(lyricURL, "http://", "https://", 1)
+	req, err := http.NewRequestWithContext(ctx, "GET", lyricURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLyricsSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
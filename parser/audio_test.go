@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseURL_Audio(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/audio/music-service-c/web/song/info":
+			if r.URL.Query().Get("sid") != "123" {
+				t.Errorf("song request = %s", r.URL)
+			}
+			w.Write([]byte(`{"code":0,"msg":"success","data":{
+				"id":123,"title":"Song","author":"Singer","uname":"UP","uid":9,"intro":"About",
+				"cover":"http://i0.hdslb.com/song.jpg","lyric":"http://i0.hdslb.com/song.lrc",
+				"passtime":1700000000,"bvid":"BV1mv","cid":2}}`))
+		case "/x/web-interface/view":
+			w.Write([]byte(`{"code":0,"data":{"bvid":"BV1mv","title":"MV","owner":{"name":"UP"},
+				"pages":[{"cid":1,"page":1,"part":"Intro"},{"cid":2,"page":2,"part":"Song"}]}}`))
+		case "/song.lrc":
+			w.Write([]byte("\ufeff[00:01.00]第一句\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	info, err := p.ParseURL("https://www.bilibili.com/audio/au123")
+	if err != nil {
+		t.Fatalf("ParseURL(audio): %v", err)
+	}
+	if info.Type != "video" || info.BVID != "BV1mv" || info.Title != "Song" || info.Uploader != "Singer" {
+		t.Errorf("audio info = %+v", info)
+	}
+	if len(info.Pages) != 1 || info.Pages[0].CID != 2 {
+		t.Errorf("pages = %+v, want the part of the track", info.Pages)
+	}
+	if info.Lyrics != "[00:01.00]第一句\n" {
+		t.Errorf("lyrics = %q", info.Lyrics)
+	}
+}
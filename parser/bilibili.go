@@ -4,37 +4,113 @@
 package parser
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dengmengmian/goBili/auth"
-
-	"github.com/sirupsen/logrus"
+	"github.com/dengmengmian/goBili/httpclient"
+	"github.com/dengmengmian/goBili/logging"
+	"github.com/dengmengmian/goBili/quality"
 )
 
+// apiBase is the base URL of the Bilibili web API.
+const apiBase = "https://api.bilibili.com"
+
 // BilibiliParser handles parsing of Bilibili URLs and API responses
 type BilibiliParser struct {
-	client      *http.Client
+	client      auth.HTTPDoer
 	authManager *auth.AuthManager
-	logger      *logrus.Logger
+	logger      logging.Logger
+
+	// extractors are those added with RegisterExtractor.
+	extractors []Extractor
+
+	sessions playurlCache
+
+	// areaProxies route the playurl requests of region-locked videos; see
+	// SetAreaProxies.
+	areaProxies map[string]*url.URL
 }
 
 // VideoInfo represents information about a video
 type VideoInfo struct {
-	BVID     string         `json:"bvid"`
-	AID      int64          `json:"aid"`
-	Title    string         `json:"title"`
-	Desc     string         `json:"desc"`
-	Duration int            `json:"duration"`
-	Type     string         `json:"type"` // "video" or "playlist"
-	Episodes []*EpisodeInfo `json:"episodes,omitempty"`
-	Pages    []*PageInfo    `json:"pages,omitempty"`
+	BVID      string         `json:"bvid"`
+	AID       int64          `json:"aid"`
+	Title     string         `json:"title"`
+	Desc      string         `json:"desc"`
+	Duration  int            `json:"duration"`
+	Type      string         `json:"type"` // "video" or "playlist"
+	Uploader  string         `json:"uploader,omitempty"`
+	OwnerMID  int64          `json:"owner_mid,omitempty"`
+	PubDate   int64          `json:"pubdate,omitempty"`   // Unix seconds
+	Category  string         `json:"category,omitempty"`  // 分区 name
+	Cover     string         `json:"cover,omitempty"`     // cover image URL
+	Chapters  []Chapter      `json:"chapters,omitempty"`  // set from GetPlayerInfo
+	Subtitles []Subtitle     `json:"subtitles,omitempty"` // set from GetPlayerInfo
+	Danmaku   []Danmaku      `json:"-"`                   // set from GetDanmaku; too large for info JSON
+	Episodes  []*EpisodeInfo `json:"episodes,omitempty"`
+	Pages     []*PageInfo    `json:"pages,omitempty"`
+
+	// Extras are the PVs, OP/ED, 花絮 and other videos listed beside the
+	// episodes of a bangumi season; they are not among Episodes.
+	Extras []*EpisodeInfo `json:"extras,omitempty"`
+
+	// Series is the title of the playlist or multi-part video an episode
+	// was taken from; set by the caller, empty for single videos.
+	Series string `json:"series,omitempty"`
+
+	// Season is the number of a bangumi season among the seasons of its
+	// show, from 1; 0 for other content.
+	Season int `json:"season,omitempty"`
+	// SeasonID is the season_id of a bangumi season, and Seasons lists
+	// every season of its show in order, this one included.
+	SeasonID int64       `json:"season_id,omitempty"`
+	Seasons  []SeasonRef `json:"seasons,omitempty"`
+	// Show is the title of the show a bangumi season belongs to, without
+	// the season, e.g. "某番" for "某番 第二季".
+	Show string `json:"show,omitempty"`
+	// Episode is the number of an episode in its playlist: of a bangumi
+	// episode in its season, of an extra among the specials (Season 0),
+	// of a part in its video. Set by gobili.EpisodeVideo.
+	Episode int `json:"episode,omitempty"`
+
+	// Actors are the cast of a bangumi season: the 声优 and their roles.
+	Actors []Actor `json:"actors,omitempty"`
+	// Studio is the animation studio of a bangumi season.
+	Studio string `json:"studio,omitempty"`
+
+	// Lyrics are the LRC lyrics of an audio track.
+	Lyrics string `json:"lyrics,omitempty"`
+
+	// Premiere is when a video scheduled to premiere (首映) or publish
+	// later goes live, in Unix seconds; 0 once it is out.
+	Premiere int64 `json:"premiere,omitempty"`
+
+	// Storyboard holds the preview thumbnails of a page; set from
+	// GetStoryboard.
+	Storyboard *Storyboard `json:"storyboard,omitempty"`
+}
+
+// Actor is a member of the cast of a bangumi season.
+type Actor struct {
+	Name string `json:"name"`           // e.g. the 声优
+	Role string `json:"role,omitempty"` // the character played
+}
+
+// SeasonRef is one season of a bangumi show: a numbered season, a movie
+// (剧场版) or a special.
+type SeasonRef struct {
+	ID    int64  `json:"season_id"`
+	Title string `json:"title"` // e.g. "第二季" or "剧场版"
 }
 
 // EpisodeInfo represents information about an episode in a playlist
@@ -44,6 +120,45 @@ type EpisodeInfo struct {
 	Title    string `json:"title"`
 	Duration int    `json:"duration"`
 	Index    int    `json:"index"`
+	Cover    string `json:"cover,omitempty"` // episode cover image URL, when it has its own
+
+	// Bangumi episodes only.
+	EpID      int64  `json:"ep_id,omitempty"`
+	LongTitle string `json:"long_title,omitempty"` // e.g. "初次见面" for episode "1"
+	PubTime   int64  `json:"pub_time,omitempty"`   // release time, Unix seconds
+
+	// Section is the section of an extra, e.g. "PV&其他"; empty for
+	// episodes.
+	Section string `json:"section,omitempty"`
+	// Season and Number number a bangumi episode as media servers do:
+	// its season and its place in it, or 0 and the place among the
+	// extras for an extra.
+	Season int `json:"season,omitempty"`
+	Number int `json:"number,omitempty"`
+}
+
+// DisplayTitle returns the title of an extra labelled with its section,
+// e.g. "[PV&其他] PV1", telling its files from those of the episodes.
+// Episodes keep their title.
+func (e *EpisodeInfo) DisplayTitle() string {
+	if e.Section == "" {
+		return e.Title
+	}
+	return "[" + e.Section + "] " + e.Title
+}
+
+// Chapter is a 分段章节 (view point) of a video, in seconds
+type Chapter struct {
+	Title string `json:"title"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Subtitle is a CC subtitle track in Bilibili's JSON (BCC) format
+type Subtitle struct {
+	Lang string `json:"lang"` // e.g. "zh-CN", "en-US", "ai-zh"
+	Name string `json:"name"` // e.g. "中文（中国）"
+	URL  string `json:"url"`
 }
 
 // PageInfo represents information about a page in a multi-page video
@@ -65,6 +180,23 @@ type StreamInfo struct {
 	AudioCodecs string `json:"audio_codecs"`
 	Bandwidth   int    `json:"bandwidth"`
 	Resolution  string `json:"resolution"`
+	FrameRate   string `json:"frame_rate,omitempty"`
+	// FormatID identifies a DASH video representation as "<quality>-<codec>",
+	// e.g. "80-hevc", for --format-id. Legacy streams have none.
+	FormatID string `json:"format_id,omitempty"`
+	// Muxed is set for legacy (durl) streams whose VideoURL already carries
+	// the audio track. An empty AudioURL without Muxed means no audio exists.
+	Muxed bool `json:"muxed,omitempty"`
+	// AudioTracks lists every DASH audio stream; AudioURL is one of them.
+	AudioTracks []AudioTrack `json:"audio_tracks,omitempty"`
+}
+
+// AudioTrack is one DASH audio stream. Its ID doubles as its --format-id.
+type AudioTrack struct {
+	ID        int    `json:"id"` // e.g. 30280 (192K), 30232 (132K), 30216 (64K)
+	URL       string `json:"url"`
+	Codecs    string `json:"codecs"`
+	Bandwidth int    `json:"bandwidth"`
 }
 
 // APIResponse represents the structure of Bilibili API responses
@@ -74,6 +206,27 @@ type APIResponse struct {
 	Data    json.RawMessage `json:"data"`
 }
 
+// apiResult is an API response with its data decoded as T.
+type apiResult[T any] struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    T      `json:"data"`
+}
+
+// decodeAPI decodes the API response read from body, streaming the data
+// straight into T instead of buffering the body and decoding it twice.
+// Data not fitting T is an error, except in responses reporting a failure,
+// whose data often has another shape; callers check their code first.
+func decodeAPI[T any](body io.Reader) (*apiResult[T], error) {
+	var result apiResult[T]
+	err := json.NewDecoder(body).Decode(&result)
+	var typeErr *json.UnmarshalTypeError
+	if err != nil && !(errors.As(err, &typeErr) && result.Code != 0) {
+		return nil, fmt.Errorf("failed to decode API response: %w", err)
+	}
+	return &result, nil
+}
+
 // VideoAPIResponse represents video API response data
 type VideoAPIResponse struct {
 	BVID     string      `json:"bvid"`
@@ -81,7 +234,21 @@ type VideoAPIResponse struct {
 	Title    string      `json:"title"`
 	Desc     string      `json:"desc"`
 	Duration int         `json:"duration"`
+	PubDate  int64       `json:"pubdate"`
+	TName    string      `json:"tname"`
+	Pic      string      `json:"pic"`
+	Owner    Owner       `json:"owner"`
 	Pages    []*PageInfo `json:"pages"`
+	// Premiere is set for videos scheduled to premiere (首映).
+	Premiere struct {
+		StartTime int64 `json:"start_time"` // Unix seconds
+	} `json:"premiere"`
+}
+
+// Owner is the uploader (UP主) of a video
+type Owner struct {
+	Mid  int64  `json:"mid"`
+	Name string `json:"name"`
 }
 
 // PlaylistAPIResponse represents playlist API response data
@@ -90,37 +257,58 @@ type PlaylistAPIResponse struct {
 	Episodes []*EpisodeInfo `json:"episodes"`
 }
 
+// ErrVideoUnavailable is returned when a video was deleted, hidden, or is
+// otherwise no longer viewable on Bilibili.
+var ErrVideoUnavailable = errors.New("video is no longer available")
+
+// unavailableCodes are view API codes meaning the video is gone:
+// -404 (not found), 62002 (稿件不可见), 62004 (审核中), 62012 (仅UP主自己可见).
+var unavailableCodes = map[int]bool{-404: true, 62002: true, 62004: true, 62012: true}
+
 // NewBilibiliParser creates a new Bilibili parser
-func NewBilibiliParser(authManager *auth.AuthManager, logger *logrus.Logger) *BilibiliParser {
+func NewBilibiliParser(authManager *auth.AuthManager, logger logging.Logger) *BilibiliParser {
+	return NewBilibiliParserWithClient(authManager, logger, nil)
+}
+
+// NewBilibiliParserWithClient is NewBilibiliParser sending its API
+// requests through client; nil uses an http.Client on the shared
+// transport of httpclient with a 30 second timeout. Cookies set in responses are recorded in authManager. A nil
+// logger discards the log.
+func NewBilibiliParserWithClient(authManager *auth.AuthManager, logger logging.Logger, client auth.HTTPDoer) *BilibiliParser {
+	if client == nil {
+		client = httpclient.NewClient(30 * time.Second)
+	}
 	return &BilibiliParser{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		client:      authManager.WithCookieJar(client),
 		authManager: authManager,
-		logger:      logger,
+		logger:      logging.OrDiscard(logger),
 	}
 }
 
 // ParseURL parses a Bilibili URL and returns video information
 func (p *BilibiliParser) ParseURL(rawURL string) (*VideoInfo, error) {
-	// Parse the URL
+	return p.ParseURLContext(context.Background(), rawURL)
+}
+
+// ParseURLContext is ParseURL with a context for the API requests. The URL
+// goes to the first extractor that matches it: those registered with
+// RegisterExtractor, then the built-in ones for videos, bangumi and audio
+// tracks.
+func (p *BilibiliParser) ParseURLContext(ctx context.Context, rawURL string) (*VideoInfo, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
-
-	// Extract BVID or other identifiers from URL
-	if strings.Contains(u.Path, "/video/") {
-		return p.parseVideoURL(rawURL)
-	} else if strings.Contains(u.Path, "/bangumi/play/") {
-		return p.parsePlaylistURL(rawURL)
+	for _, e := range p.allExtractors() {
+		if e.Match(u) {
+			return e.Extract(ctx, u)
+		}
 	}
-
 	return nil, fmt.Errorf("unsupported URL format")
 }
 
 // parseVideoURL parses a single video URL
-func (p *BilibiliParser) parseVideoURL(rawURL string) (*VideoInfo, error) {
+func (p *BilibiliParser) parseVideoURL(ctx context.Context, rawURL string) (*VideoInfo, error) {
 	// Extract BVID from URL
 	bvidRegex := regexp.MustCompile(`BV[a-zA-Z0-9]+`)
 	bvid := bvidRegex.FindString(rawURL)
@@ -129,7 +317,7 @@ func (p *BilibiliParser) parseVideoURL(rawURL string) (*VideoInfo, error) {
 	}
 
 	// Get video information from API
-	videoInfo, err := p.getVideoInfo(bvid)
+	videoInfo, err := p.getVideoInfo(ctx, bvid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get video info: %w", err)
 	}
@@ -162,7 +350,7 @@ func (p *BilibiliParser) parseVideoURL(rawURL string) (*VideoInfo, error) {
 }
 
 // parsePlaylistURL parses a playlist URL
-func (p *BilibiliParser) parsePlaylistURL(rawURL string) (*VideoInfo, error) {
+func (p *BilibiliParser) parsePlaylistURL(ctx context.Context, rawURL string) (*VideoInfo, error) {
 	// Extract season ID from URL
 	seasonRegex := regexp.MustCompile(`ss(\d+)`)
 	matches := seasonRegex.FindStringSubmatch(rawURL)
@@ -173,7 +361,7 @@ func (p *BilibiliParser) parsePlaylistURL(rawURL string) (*VideoInfo, error) {
 	seasonID := matches[1]
 
 	// Get playlist information from API
-	playlistInfo, err := p.getPlaylistInfo(seasonID)
+	playlistInfo, err := p.getPlaylistInfo(ctx, seasonID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get playlist info: %w", err)
 	}
@@ -183,13 +371,17 @@ func (p *BilibiliParser) parsePlaylistURL(rawURL string) (*VideoInfo, error) {
 }
 
 // getVideoInfo fetches video information from Bilibili API
-func (p *BilibiliParser) getVideoInfo(bvid string) (*VideoInfo, error) {
-	apiURL := fmt.Sprintf("https://api.bilibili.com/x/web-interface/view?bvid=%s", bvid)
+func (p *BilibiliParser) getVideoInfo(ctx context.Context, bvid string) (*VideoInfo, error) {
+	apiURL, err := p.apiURL("/x/web-interface/view", "/x/web-interface/wbi/view", url.Values{"bvid": {bvid}})
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -197,25 +389,20 @@ func (p *BilibiliParser) getVideoInfo(bvid string) (*VideoInfo, error) {
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	apiResp, err := decodeAPI[VideoAPIResponse](resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
-	}
-
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API error: %s", apiResp.Message)
+	if unavailableCodes[apiResp.Code] {
+		return nil, fmt.Errorf("%w: %w", ErrVideoUnavailable, &auth.APIError{Code: apiResp.Code, Message: apiResp.Message})
 	}
-
-	var videoData VideoAPIResponse
-	if err := json.Unmarshal(apiResp.Data, &videoData); err != nil {
+	if err := auth.CheckResponse("get video info", apiResp.Code, apiResp.Message); err != nil {
 		return nil, err
 	}
 
+	videoData := &apiResp.Data
+
 	// Convert to VideoInfo
 	videoInfo := &VideoInfo{
 		BVID:     videoData.BVID,
@@ -223,20 +410,86 @@ func (p *BilibiliParser) getVideoInfo(bvid string) (*VideoInfo, error) {
 		Title:    videoData.Title,
 		Desc:     videoData.Desc,
 		Duration: videoData.Duration,
+		Uploader: videoData.Owner.Name,
+		OwnerMID: videoData.Owner.Mid,
+		PubDate:  videoData.PubDate,
+		Category: videoData.TName,
+		Cover:    videoData.Pic,
 		Pages:    videoData.Pages,
 	}
+	// Premieres and videos set to publish later (定时发布) are listed
+	// before they can be played.
+	if start := max(videoData.Premiere.StartTime, videoData.PubDate); start > time.Now().Unix() {
+		videoInfo.Premiere = start
+	}
 
 	return videoInfo, nil
 }
 
+// seasonEpisode is an episode in the season API.
+type seasonEpisode struct {
+	ID        int64  `json:"id"`
+	BVID      string `json:"bvid"`
+	CID       int64  `json:"cid"`
+	Title     string `json:"title"`
+	LongTitle string `json:"long_title"`
+	Duration  int    `json:"duration"`
+	Index     int    `json:"index"`
+	Cover     string `json:"cover"`
+	PubTime   int64  `json:"pub_time"`
+}
+
+// episodeInfo converts ep.
+func (ep *seasonEpisode) episodeInfo() *EpisodeInfo {
+	return &EpisodeInfo{
+		BVID:      ep.BVID,
+		CID:       ep.CID,
+		Title:     ep.Title,
+		Duration:  ep.Duration,
+		Index:     ep.Index,
+		Cover:     ep.Cover,
+		EpID:      ep.ID,
+		LongTitle: ep.LongTitle,
+		PubTime:   ep.PubTime,
+	}
+}
+
+// seasonData is the data of the season API (pgc/view/web/season).
+type seasonData struct {
+	SeasonID int64           `json:"season_id"`
+	Title    string          `json:"title"`
+	Cover    string          `json:"cover"`
+	Evaluate string          `json:"evaluate"` // synopsis
+	Actors   string          `json:"actors"`   // "角色：声优" lines
+	Staff    string          `json:"staff"`    // "职位：名字" lines
+	Episodes []seasonEpisode `json:"episodes"`
+	Publish  struct {
+		PubTime string `json:"pub_time"` // "2006-01-02 15:04:05", Beijing time
+	} `json:"publish"`
+	// Sections hold the extras: PVs, OP/ED, 花絮 and the like.
+	Sections []struct {
+		Title    string          `json:"title"` // e.g. "PV&其他"
+		Episodes []seasonEpisode `json:"episodes"`
+	} `json:"section"`
+	Series struct {
+		Title string `json:"series_title"`
+	} `json:"series"`
+	// Seasons are all seasons of the show, in order.
+	Seasons []struct {
+		SeasonID    int64  `json:"season_id"`
+		SeasonTitle string `json:"season_title"`
+	} `json:"seasons"`
+}
+
 // getPlaylistInfo fetches playlist information from Bilibili API
-func (p *BilibiliParser) getPlaylistInfo(seasonID string) (*VideoInfo, error) {
+func (p *BilibiliParser) getPlaylistInfo(ctx context.Context, seasonID string) (*VideoInfo, error) {
 	apiURL := fmt.Sprintf("https://api.bilibili.com/pgc/view/web/season?season_id=%s", seasonID)
 
 	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -244,54 +497,125 @@ func (p *BilibiliParser) getPlaylistInfo(seasonID string) (*VideoInfo, error) {
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Season payloads list every episode; decode them as they arrive.
+	apiResp, err := decodeAPI[seasonData](resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
+	if err := auth.CheckResponse("get playlist info", apiResp.Code, apiResp.Message); err != nil {
 		return nil, err
 	}
+	playlistData := &apiResp.Data
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("API error: %s", apiResp.Message)
+	// Convert to VideoInfo
+	videoInfo := &VideoInfo{
+		Title:    playlistData.Title,
+		Type:     "playlist",
+		Cover:    playlistData.Cover,
+		Season:   1,
+		SeasonID: playlistData.SeasonID,
+		Show:     playlistData.Series.Title,
+		Desc:     playlistData.Evaluate,
+		PubDate:  parseBeijingTime(playlistData.Publish.PubTime),
+		Actors:   parseActors(playlistData.Actors),
+		Studio:   parseStudio(playlistData.Staff),
+	}
+	for i, season := range playlistData.Seasons {
+		if season.SeasonID == playlistData.SeasonID {
+			videoInfo.Season = i + 1
+		}
+		videoInfo.Seasons = append(videoInfo.Seasons, SeasonRef{ID: season.SeasonID, Title: season.SeasonTitle})
 	}
 
-	var playlistData struct {
-		Title    string `json:"title"`
-		Episodes []struct {
-			BVID     string `json:"bvid"`
-			CID      int64  `json:"cid"`
-			Title    string `json:"title"`
-			Duration int    `json:"duration"`
-			Index    int    `json:"index"`
-		} `json:"episodes"`
+	// Convert episodes
+	for i := range playlistData.Episodes {
+		episode := playlistData.Episodes[i].episodeInfo()
+		episode.Season, episode.Number = videoInfo.Season, i+1
+		videoInfo.Episodes = append(videoInfo.Episodes, episode)
+	}
+	for _, section := range playlistData.Sections {
+		for i := range section.Episodes {
+			extra := section.Episodes[i].episodeInfo()
+			extra.Section = section.Title
+			if extra.Section == "" {
+				extra.Section = "花絮"
+			}
+			extra.Number = len(videoInfo.Extras) + 1
+			videoInfo.Extras = append(videoInfo.Extras, extra)
+		}
 	}
 
-	if err := json.Unmarshal(apiResp.Data, &playlistData); err != nil {
-		return nil, err
+	return videoInfo, nil
+}
+
+// beijing is the time zone of the times the season API gives as text.
+var beijing = time.FixedZone("CST", 8*60*60)
+
+// parseBeijingTime returns the Unix time of a "2006-01-02 15:04:05" time
+// in Beijing time, or 0 when s is not one.
+func parseBeijingTime(s string) int64 {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, beijing)
+	if err != nil {
+		return 0
 	}
+	return t.Unix()
+}
 
-	// Convert to VideoInfo
-	videoInfo := &VideoInfo{
-		Title: playlistData.Title,
-		Type:  "playlist",
+// parseActors parses the cast of a season, "角色：声优" lines, into
+// actors. Lines without a role are the name alone.
+func parseActors(s string) []Actor {
+	var actors []Actor
+	for _, line := range strings.Split(s, "\n") {
+		role, name, ok := cutCredit(line)
+		if !ok {
+			role, name = "", strings.TrimSpace(line)
+		}
+		if name != "" {
+			actors = append(actors, Actor{Name: name, Role: role})
+		}
 	}
+	return actors
+}
 
-	// Convert episodes
-	for _, ep := range playlistData.Episodes {
-		episode := &EpisodeInfo{
-			BVID:     ep.BVID,
-			CID:      ep.CID,
-			Title:    ep.Title,
-			Duration: ep.Duration,
-			Index:    ep.Index,
+// parseStudio returns the studio credited in the staff of a season,
+// "职位：名字" lines, under 动画制作 or a like title.
+func parseStudio(staff string) string {
+	for _, line := range strings.Split(staff, "\n") {
+		title, name, ok := cutCredit(line)
+		if !ok {
+			continue
+		}
+		switch title {
+		case "动画制作", "制作", "制作公司", "アニメーション制作":
+			return name
 		}
-		videoInfo.Episodes = append(videoInfo.Episodes, episode)
 	}
+	return ""
+}
 
-	return videoInfo, nil
+// cutCredit splits a "title：name" credit line at its full- or
+// half-width colon.
+func cutCredit(line string) (title, name string, ok bool) {
+	i := strings.IndexAny(line, "：:")
+	if i < 0 {
+		return "", "", false
+	}
+	_, size := utf8.DecodeRuneInString(line[i:])
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+size:]), true
+}
+
+// apiURL builds an api.bilibili.com URL. Anonymous sessions use the
+// WBI-signed variant of the endpoint (wbiPath) instead of plainPath.
+func (p *BilibiliParser) apiURL(plainPath, wbiPath string, params url.Values) (string, error) {
+	if p.authManager.IsAnonymous() {
+		signed, err := p.authManager.SignWbi(params)
+		if err != nil {
+			return "", fmt.Errorf("failed to sign request: %w", err)
+		}
+		return apiBase + wbiPath + "?" + signed.Encode(), nil
+	}
+	return apiBase + plainPath + "?" + params.Encode(), nil
 }
 
 // GetVideoStreams fetches available video streams for a video
@@ -301,172 +625,283 @@ func (p *BilibiliParser) GetVideoStreams(videoInfo *VideoInfo) ([]*StreamInfo, e
 
 // GetVideoStreamsForPage gets video streams for a specific page
 func (p *BilibiliParser) GetVideoStreamsForPage(videoInfo *VideoInfo, pageNum int) ([]*StreamInfo, error) {
-	// Find the specific page
-	var cid int64
-	if len(videoInfo.Pages) > 0 {
-		// If pageNum is specified, find that page
-		if pageNum > 0 && pageNum <= len(videoInfo.Pages) {
-			cid = videoInfo.Pages[pageNum-1].CID
-		} else {
-			// Default to first page
-			cid = videoInfo.Pages[0].CID
-		}
-	} else {
+	cid, err := pageCID(videoInfo, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := p.getVideoStreamsByCID(videoInfo.BVID, cid)
+	if err != nil {
+		return p.streamsInArea(videoInfo, cid, err)
+	}
+	return streams, nil
+}
+
+// RefreshVideoStreamsForPage is GetVideoStreamsForPage bypassing the stream
+// cache, for when the cached URLs have expired.
+func (p *BilibiliParser) RefreshVideoStreamsForPage(videoInfo *VideoInfo, pageNum int) ([]*StreamInfo, error) {
+	cid, err := pageCID(videoInfo, pageNum)
+	if err != nil {
+		return nil, err
+	}
+	p.InvalidateStreams(cid)
+	streams, err := p.getVideoStreamsByCID(videoInfo.BVID, cid)
+	if err != nil {
+		return p.streamsInArea(videoInfo, cid, err)
+	}
+	return streams, nil
+}
+
+// pageCID returns the CID of page pageNum, or of the first page when
+// pageNum is out of range.
+func pageCID(videoInfo *VideoInfo, pageNum int) (int64, error) {
+	if len(videoInfo.Pages) == 0 {
 		// If no pages, we need to get the CID from the video info
 		// This would require an additional API call
-		return nil, fmt.Errorf("no pages found for video")
+		return 0, fmt.Errorf("no pages found for video")
 	}
-
-	return p.getVideoStreamsByCID(videoInfo.BVID, cid)
+	if pageNum > 0 && pageNum <= len(videoInfo.Pages) {
+		return videoInfo.Pages[pageNum-1].CID, nil
+	}
+	return videoInfo.Pages[0].CID, nil
 }
 
 // getVideoStreamsByCID fetches video streams by CID
 func (p *BilibiliParser) getVideoStreamsByCID(bvid string, cid int64) ([]*StreamInfo, error) {
+	if streams := p.sessions.streams(cid); streams != nil {
+		p.logger.Debugf("Reusing streams for cid %d", cid)
+		return streams, nil
+	}
+
+	// A TV login's access_key reaches the app API, which may grant more.
+	if p.authManager.AccessKey() != "" {
+		streams, err := p.getAppStreams(bvid, cid)
+		if err == nil && len(streams) > 0 {
+			p.sessions.putStreams(cid, streams)
+			return streams, nil
+		}
+		p.logger.Debugf("App playurl unavailable, using the web API: %v", err)
+	}
+
+	// Earlier pages of this video already showed that only the legacy
+	// streams are usable; skip the DASH request.
+	session := p.sessions.session(bvid)
+	if session.legacy {
+		return p.getLegacyVideoStreams(bvid, cid)
+	}
+
 	// Call the play URL API
-	apiURL := fmt.Sprintf("https://api.bilibili.com/x/player/playurl?bvid=%s&cid=%d&qn=0&fnval=16&fourk=1", bvid, cid)
+	params := url.Values{
+		"bvid":  {bvid},
+		"cid":   {strconv.FormatInt(cid, 10)},
+		"qn":    {"0"},
+		"fnval": {"16"},
+		"fourk": {"1"},
+	}
+	if session.id != "" {
+		params.Set("session", session.id)
+	}
+	apiURL, err := p.apiURL("/x/player/playurl", "/x/player/wbi/playurl", params)
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := p.client.Do(req)
+	resp, err := p.client.Do(p.inArea(req, bvid))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	apiResp, err := decodeAPI[playurlData](resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	var apiResp struct {
-		Code int `json:"code"`
-		Data struct {
-			Dash struct {
-				Video []struct {
-					ID        int      `json:"id"`
-					BaseURL   string   `json:"baseUrl"`
-					BackupURL []string `json:"backupUrl"`
-					Bandwidth int      `json:"bandwidth"`
-					MimeType  string   `json:"mimeType"`
-					Codecs    string   `json:"codecs"`
-					Width     int      `json:"width"`
-					Height    int      `json:"height"`
-					FrameRate string   `json:"frameRate"`
-				} `json:"video"`
-				Audio []struct {
-					ID        int      `json:"id"`
-					BaseURL   string   `json:"baseUrl"`
-					BackupURL []string `json:"backupUrl"`
-					Bandwidth int      `json:"bandwidth"`
-					MimeType  string   `json:"mimeType"`
-					Codecs    string   `json:"codecs"`
-				} `json:"audio"`
-			} `json:"dash"`
-			AcceptQuality     []int    `json:"accept_quality"`
-			AcceptDescription []string `json:"accept_description"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
+	if areaRestrictedCodes[apiResp.Code] {
+		return nil, fmt.Errorf("%w: %w", ErrAreaRestricted, &auth.APIError{Code: apiResp.Code, Message: apiResp.Message})
+	}
+	if err := auth.CheckResponse("get video streams", apiResp.Code, apiResp.Message); err != nil {
+		return nil, fmt.Errorf("failed to get video streams: %w", err)
 	}
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("failed to get video streams: %d", apiResp.Code)
+	streams := dashStreams(&apiResp.Data)
+
+	if apiResp.Data.Session != "" {
+		p.sessions.setSessionID(bvid, apiResp.Data.Session)
 	}
 
-	// Convert to StreamInfo
+	// If no DASH streams, try legacy format
+	if len(streams) == 0 {
+		p.sessions.setLegacy(bvid)
+		return p.getLegacyVideoStreams(bvid, cid)
+	}
+
+	// Some (mostly old) videos return DASH video without an audio array.
+	// The legacy durl streams carry muxed audio, so prefer those; otherwise
+	// the DASH streams are returned without audio and saved as video only.
+	if len(apiResp.Data.Dash.Audio) == 0 {
+		p.logger.Warnf("No DASH audio for %s (cid %d), trying legacy streams", bvid, cid)
+		legacy, err := p.getLegacyVideoStreams(bvid, cid)
+		if err == nil && len(legacy) > 0 {
+			p.sessions.setLegacy(bvid)
+			return legacy, nil
+		}
+		p.logger.Debugf("Legacy streams unavailable: %v", err)
+	}
+
+	p.sessions.putStreams(cid, streams)
+	return streams, nil
+}
+
+// playurlData is the data of a DASH playurl response. The web API names
+// the URL fields baseUrl/backupUrl; the app API uses base_url/backup_url.
+type playurlData struct {
+	Dash struct {
+		Video []dashMedia `json:"video"`
+		Audio []dashMedia `json:"audio"`
+	} `json:"dash"`
+	AcceptQuality     []int    `json:"accept_quality"`
+	AcceptDescription []string `json:"accept_description"`
+	Session           string   `json:"session"`
+}
+
+// dashMedia is one DASH representation.
+type dashMedia struct {
+	ID         int      `json:"id"`
+	BaseURL    string   `json:"baseUrl"`
+	BaseURLApp string   `json:"base_url"`
+	BackupURL  []string `json:"backupUrl"`
+	Bandwidth  int      `json:"bandwidth"`
+	MimeType   string   `json:"mimeType"`
+	Codecs     string   `json:"codecs"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	FrameRate  string   `json:"frameRate"`
+}
+
+// url returns the primary URL of the representation.
+func (m *dashMedia) url() string {
+	if m.BaseURL != "" {
+		return m.BaseURL
+	}
+	return m.BaseURLApp
+}
+
+// dashStreams converts a DASH playurl response into streams, one per video
+// representation. A quality served in several codecs yields one stream per
+// codec.
+func dashStreams(data *playurlData) []*StreamInfo {
 	var streams []*StreamInfo
 
-	// Quality mapping
-	qualityMap := map[int]int{
-		80: 80, // 1080p
-		64: 64, // 720p
-		32: 32, // 480p
-		16: 16, // 360p
+	var audioTracks []AudioTrack
+	for _, audio := range data.Dash.Audio {
+		audioTracks = append(audioTracks, AudioTrack{
+			ID:        audio.ID,
+			URL:       audio.url(),
+			Codecs:    audio.Codecs,
+			Bandwidth: audio.Bandwidth,
+		})
 	}
 
 	// Process video streams
-	for _, video := range apiResp.Data.Dash.Video {
-		quality, exists := qualityMap[video.ID]
-		if !exists {
-			continue
-		}
-
+	for _, video := range data.Dash.Video {
 		// Find corresponding audio stream
 		var audioURL string
-		if len(apiResp.Data.Dash.Audio) > 0 {
-			audioURL = apiResp.Data.Dash.Audio[0].BaseURL
+		if len(data.Dash.Audio) > 0 {
+			audioURL = data.Dash.Audio[0].url()
 		}
 
 		stream := &StreamInfo{
-			Quality:     quality,
+			Quality:     video.ID,
 			Format:      "mp4",
-			VideoURL:    video.BaseURL,
+			VideoURL:    video.url(),
 			AudioURL:    audioURL,
 			VideoCodecs: video.Codecs,
 			AudioCodecs: func() string {
-				if len(apiResp.Data.Dash.Audio) > 0 {
-					return apiResp.Data.Dash.Audio[0].Codecs
+				if len(data.Dash.Audio) > 0 {
+					return data.Dash.Audio[0].Codecs
 				}
 				return ""
 			}(),
-			Bandwidth:  video.Bandwidth,
-			Resolution: fmt.Sprintf("%dx%d", video.Width, video.Height),
+			Bandwidth:   video.Bandwidth,
+			Resolution:  fmt.Sprintf("%dx%d", video.Width, video.Height),
+			FrameRate:   video.FrameRate,
+			FormatID:    fmt.Sprintf("%d-%s", video.ID, CodecFamily(video.Codecs)),
+			AudioTracks: audioTracks,
 		}
 
 		streams = append(streams, stream)
 	}
+	return streams
+}
 
-	// If no DASH streams, try legacy format
-	if len(streams) == 0 {
-		return p.getLegacyVideoStreams(bvid, cid)
+// CodecFamily returns the short name of a codecs string: "avc" for
+// avc1.640032, "hevc" for hev1/hvc1, "av1" for av01, or the prefix before
+// the first dot otherwise.
+func CodecFamily(codecs string) string {
+	prefix, _, _ := strings.Cut(codecs, ".")
+	switch prefix {
+	case "avc1", "avc3":
+		return "avc"
+	case "hev1", "hvc1":
+		return "hevc"
+	case "av01":
+		return "av1"
 	}
-
-	return streams, nil
+	return prefix
 }
 
 // getLegacyVideoStreams gets video streams in legacy format
 func (p *BilibiliParser) getLegacyVideoStreams(bvid string, cid int64) ([]*StreamInfo, error) {
-	apiURL := fmt.Sprintf("https://api.bilibili.com/x/player/playurl?bvid=%s&cid=%d&qn=80", bvid, cid)
+	// Ask for the quality granted to earlier pages, which the server would
+	// otherwise negotiate down to again.
+	qn := 80
+	if session := p.sessions.session(bvid); session.qn > 0 {
+		qn = session.qn
+	}
+	apiURL, err := p.apiURL("/x/player/playurl", "/x/player/wbi/playurl", url.Values{
+		"bvid": {bvid},
+		"cid":  {strconv.FormatInt(cid, 10)},
+		"qn":   {strconv.Itoa(qn)},
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := p.client.Do(req)
+	resp, err := p.client.Do(p.inArea(req, bvid))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	type legacyData struct {
+		DURL []struct {
+			URL    string `json:"url"`
+			Size   int64  `json:"size"`
+			Length int    `json:"length"`
+		} `json:"durl"`
+		Quality int `json:"quality"`
+	}
+	apiResp, err := decodeAPI[legacyData](resp.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	var apiResp struct {
-		Code int `json:"code"`
-		Data struct {
-			DURL []struct {
-				URL    string `json:"url"`
-				Size   int64  `json:"size"`
-				Length int    `json:"length"`
-			} `json:"durl"`
-			Quality int `json:"quality"`
-		} `json:"data"`
-	}
-
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, err
+	if err := auth.CheckResponse("get legacy video streams", apiResp.Code, apiResp.Message); err != nil {
+		return nil, fmt.Errorf("failed to get legacy video streams: %w", err)
 	}
 
-	if apiResp.Code != 0 {
-		return nil, fmt.Errorf("failed to get legacy video streams: %d", apiResp.Code)
+	if apiResp.Data.Quality > 0 {
+		p.sessions.setQuality(bvid, apiResp.Data.Quality)
 	}
 
 	var streams []*StreamInfo
@@ -479,14 +914,130 @@ func (p *BilibiliParser) getLegacyVideoStreams(bvid string, cid int64) ([]*Strea
 			VideoCodecs: "avc1",
 			AudioCodecs: "mp4a",
 			Bandwidth:   0,
-			Resolution:  "unknown",
+			Resolution:  quality.Resolution(apiResp.Data.Quality),
+			Muxed:       true,
 		}
 		streams = append(streams, stream)
 	}
 
+	p.sessions.putStreams(cid, streams)
 	return streams, nil
 }
 
+// PlayerInfo holds per-page extras from the player API
+type PlayerInfo struct {
+	Chapters  []Chapter
+	Subtitles []Subtitle
+}
+
+// GetPlayerInfo returns the 分段章节 and CC subtitles of one page of a video
+// from the player API.
+func (p *BilibiliParser) GetPlayerInfo(bvid string, cid int64) (*PlayerInfo, error) {
+	apiURL, err := p.apiURL("/x/player/v2", "/x/player/wbi/v2", url.Values{
+		"bvid": {bvid},
+		"cid":  {strconv.FormatInt(cid, 10)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	type playerData struct {
+		ViewPoints []struct {
+			Type    int    `json:"type"`
+			From    int    `json:"from"`
+			To      int    `json:"to"`
+			Content string `json:"content"`
+		} `json:"view_points"`
+		Subtitle struct {
+			Subtitles []struct {
+				Lan         string `json:"lan"`
+				LanDoc      string `json:"lan_doc"`
+				SubtitleURL string `json:"subtitle_url"`
+			} `json:"subtitles"`
+		} `json:"subtitle"`
+	}
+	apiResp, err := decodeAPI[playerData](resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckResponse("get player info", apiResp.Code, apiResp.Message); err != nil {
+		return nil, fmt.Errorf("failed to get player info: %w", err)
+	}
+
+	info := &PlayerInfo{}
+	for _, vp := range apiResp.Data.ViewPoints {
+		// Type 2 marks chapters set by the uploader.
+		if vp.Type != 2 || vp.To <= vp.From {
+			continue
+		}
+		info.Chapters = append(info.Chapters, Chapter{Title: vp.Content, Start: vp.From, End: vp.To})
+	}
+	for _, sub := range apiResp.Data.Subtitle.Subtitles {
+		if sub.SubtitleURL == "" {
+			continue
+		}
+		subURL := sub.SubtitleURL
+		if strings.HasPrefix(subURL, "//") {
+			subURL = "https:" + subURL
+		}
+		info.Subtitles = append(info.Subtitles, Subtitle{Lang: sub.Lan, Name: sub.LanDoc, URL: subURL})
+	}
+	return info, nil
+}
+
+// GetChapters returns the 分段章节 of one page of a video.
+// Videos without chapters return an empty slice.
+func (p *BilibiliParser) GetChapters(bvid string, cid int64) ([]Chapter, error) {
+	info, err := p.GetPlayerInfo(bvid, cid)
+	if err != nil {
+		return nil, err
+	}
+	return info.Chapters, nil
+}
+
+// QualityName returns the name of a quality id, e.g. "1080p" for 80, or
+// "qn<id>" for unknown ids. It is quality.Name.
+func QualityName(qn int) string {
+	return quality.Name(qn)
+}
+
+// audioTrackNames maps DASH audio IDs to the names shown by the web player.
+var audioTrackNames = map[int]string{
+	30216: "64K",
+	30232: "132K",
+	30280: "192K",
+	30250: "Dolby Atmos",
+	30251: "Hi-Res",
+}
+
+// AudioTrackName returns the web player's name of a DASH audio ID, e.g.
+// "192K" for 30280, or "" for unknown IDs.
+func AudioTrackName(id int) string {
+	return audioTrackNames[id]
+}
+
+// EstimatedSize estimates the download size in bytes of the stream, with
+// its first audio track, for a video of duration seconds. It is based on
+// the advertised average bandwidths, so the real size differs somewhat.
+func (s *StreamInfo) EstimatedSize(duration int) int64 {
+	bandwidth := int64(s.Bandwidth)
+	if s.AudioURL != "" && len(s.AudioTracks) > 0 {
+		bandwidth += int64(s.AudioTracks[0].Bandwidth)
+	}
+	return bandwidth * int64(duration) / 8
+}
+
 // GetBestQualityStream returns the highest quality stream available
 func (p *BilibiliParser) GetBestQualityStream(streams []*StreamInfo) *StreamInfo {
 	if len(streams) == 0 {
@@ -503,27 +1054,35 @@ func (p *BilibiliParser) GetBestQualityStream(streams []*StreamInfo) *StreamInfo
 	return best
 }
 
-// GetStreamByQuality returns a stream with the specified quality
-func (p *BilibiliParser) GetStreamByQuality(streams []*StreamInfo, quality string) *StreamInfo {
-	qualityMap := map[string]int{
-		"best":  80,
-		"1080p": 80,
-		"720p":  64,
-		"480p":  32,
-		"360p":  16,
-	}
+// GetStreamByQuality returns the stream of a --quality value, e.g.
+// "1080p", or the best one when that quality is not available (see
+// quality.Pick).
+func (p *BilibiliParser) GetStreamByQuality(streams []*StreamInfo, label string) *StreamInfo {
+	return StreamByQuality(streams, label)
+}
 
-	targetQuality, exists := qualityMap[quality]
-	if !exists {
-		return p.GetBestQualityStream(streams)
-	}
+// StreamByQuality returns the first stream of the quality quality.Pick
+// picks among streams for label, or nil when there are none.
+func StreamByQuality(streams []*StreamInfo, label string) *StreamInfo {
+	return StreamByQualityFallback(streams, label, quality.FallbackBest)
+}
 
-	for _, stream := range streams {
-		if stream.Quality == targetQuality {
-			return stream
+// StreamByQualityFallback is StreamByQuality replacing a missing quality
+// as fallback says (see quality.PickFallback); it returns nil when
+// fallback is quality.FallbackAbort and label is missing.
+func StreamByQualityFallback(streams []*StreamInfo, label, fallback string) *StreamInfo {
+	available := make([]int, len(streams))
+	for i, s := range streams {
+		available[i] = s.Quality
+	}
+	qn, ok := quality.PickFallback(available, label, fallback)
+	if !ok {
+		return nil
+	}
+	for _, s := range streams {
+		if s.Quality == qn {
+			return s
 		}
 	}
-
-	// If exact quality not found, return the best available
-	return p.GetBestQualityStream(streams)
+	return nil
 }
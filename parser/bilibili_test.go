@@ -1,11 +1,17 @@
 package parser
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dengmengmian/goBili/auth"
 	"github.com/sirupsen/logrus"
@@ -153,6 +159,68 @@ func TestGetStreamByQuality_Empty(t *testing.T) {
 	}
 }
 
+func TestQualityName(t *testing.T) {
+	tests := map[int]string{80: "1080p", 64: "720p", 32: "480p", 16: "360p", 116: "1080p60", 999: "qn999"}
+	for quality, want := range tests {
+		if got := QualityName(quality); got != want {
+			t.Errorf("QualityName(%d) = %q, want %q", quality, got, want)
+		}
+	}
+}
+
+func TestCodecFamily(t *testing.T) {
+	tests := map[string]string{
+		"avc1.640032":                    "avc",
+		"hev1.1.6.L150.90":               "hevc",
+		"hvc1.2.4.L153.90":               "hevc",
+		"av01.0.08M.08.0.110.01.01.01.0": "av1",
+		"vp09.00.10.08":                  "vp09",
+	}
+	for codecs, want := range tests {
+		if got := CodecFamily(codecs); got != want {
+			t.Errorf("CodecFamily(%q) = %q, want %q", codecs, got, want)
+		}
+	}
+}
+
+func TestDashStreams_AllRepresentations(t *testing.T) {
+	var data playurlData
+	data.Dash.Video = []dashMedia{
+		{ID: 116, BaseURL: "https://cdn/116-hevc.m4s", Codecs: "hev1.1.6.L150.90", Width: 1920, Height: 1080, FrameRate: "59.997"},
+		{ID: 80, BaseURL: "https://cdn/80-avc.m4s", Codecs: "avc1.640032", Width: 1920, Height: 1080, FrameRate: "29.412"},
+		{ID: 80, BaseURL: "https://cdn/80-hevc.m4s", Codecs: "hev1.1.6.L120.90", Width: 1920, Height: 1080, FrameRate: "29.412"},
+	}
+	data.Dash.Audio = []dashMedia{{ID: 30280, BaseURL: "https://cdn/a.m4s", Codecs: "mp4a.40.2"}}
+
+	streams := dashStreams(&data)
+	var ids []string
+	for _, s := range streams {
+		ids = append(ids, s.FormatID)
+	}
+	if want := []string{"116-hevc", "80-avc", "80-hevc"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("format ids = %v, want %v", ids, want)
+	}
+	if streams[0].Quality != 116 || streams[0].FrameRate != "59.997" {
+		t.Errorf("first stream = %+v", streams[0])
+	}
+}
+
+func TestEstimatedSize(t *testing.T) {
+	stream := &StreamInfo{
+		Bandwidth:   2000000,
+		AudioURL:    "https://example.com/audio.m4s",
+		AudioTracks: []AudioTrack{{Bandwidth: 320000}, {Bandwidth: 128000}},
+	}
+	// (2,000,000 + 320,000) bit/s for 100s
+	if got := stream.EstimatedSize(100); got != 29000000 {
+		t.Errorf("EstimatedSize = %d, want 29000000", got)
+	}
+	stream.AudioURL = ""
+	if got := stream.EstimatedSize(100); got != 25000000 {
+		t.Errorf("EstimatedSize without audio = %d, want 25000000", got)
+	}
+}
+
 func TestVideoAPIResponse_Unmarshal(t *testing.T) {
 	body := `{
 		"code": 0,
@@ -235,6 +303,97 @@ func TestPlaylistAPIResponse_Unmarshal(t *testing.T) {
 	}
 }
 
+func TestDecodeAPI(t *testing.T) {
+	resp, err := decodeAPI[VideoAPIResponse](strings.NewReader(`{"code":0,"data":{"bvid":"BV1","aid":1,"pages":[{"cid":2,"page":1}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.BVID != "BV1" || len(resp.Data.Pages) != 1 || resp.Data.Pages[0].CID != 2 {
+		t.Errorf("data = %+v", resp.Data)
+	}
+
+	// Data of the wrong type is an error, not a zero value.
+	if _, err := decodeAPI[VideoAPIResponse](strings.NewReader(`{"code":0,"data":{"aid":"1"}}`)); err == nil {
+		t.Error("decoded a string aid")
+	}
+
+	// Failures keep their code whatever their data looks like.
+	resp, err = decodeAPI[VideoAPIResponse](strings.NewReader(`{"code":-404,"message":"啥都木有","data":[]}`))
+	if err != nil {
+		t.Fatalf("failure response: %v", err)
+	}
+	if resp.Code != -404 || resp.Message != "啥都木有" {
+		t.Errorf("failure = %d %q", resp.Code, resp.Message)
+	}
+
+	if _, err := decodeAPI[VideoAPIResponse](strings.NewReader(`<html>`)); err == nil {
+		t.Error("decoded HTML")
+	}
+}
+
+func TestGetPlaylistInfo_Season(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("season_id") != "2" {
+			t.Errorf("request = %s", r.URL)
+		}
+		w.Write([]byte(`{"code":0,"data":{
+			"season_id":2,"title":"番剧 第二季","series":{"series_title":"番剧"},
+			"evaluate":"简介","actors":"角色A：声优A\n声优B","staff":"导演：某人\n动画制作：某社",
+			"publish":{"pub_time":"2023-10-01 00:00:00"},
+			"seasons":[{"season_id":1,"season_title":"第一季"},{"season_id":2,"season_title":"第二季"}],
+			"episodes":[
+				{"id":201,"bvid":"BV001","cid":100,"title":"1","long_title":"开始","pub_time":1700000000},
+				{"id":202,"bvid":"BV002","cid":200,"title":"2","long_title":"","pub_time":1700600000}],
+			"section":[{"title":"PV&其他","episodes":[{"id":301,"bvid":"BV003","cid":300,"title":"PV1"}]}]}}`))
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	info, err := p.getPlaylistInfo(context.Background(), "2")
+	if err != nil {
+		t.Fatalf("getPlaylistInfo failed: %v", err)
+	}
+	if info.Season != 2 || info.SeasonID != 2 || info.Show != "番剧" {
+		t.Errorf("season = %d (id %d), want 2", info.Season, info.SeasonID)
+	}
+	if want := []SeasonRef{{ID: 1, Title: "第一季"}, {ID: 2, Title: "第二季"}}; !reflect.DeepEqual(info.Seasons, want) {
+		t.Errorf("seasons = %+v, want %+v", info.Seasons, want)
+	}
+	if len(info.Episodes) != 2 {
+		t.Fatalf("episodes len = %d, want 2", len(info.Episodes))
+	}
+	if ep := info.Episodes[0]; ep.EpID != 201 || ep.LongTitle != "开始" || ep.PubTime != 1700000000 {
+		t.Errorf("first episode = %+v", ep)
+	}
+	if len(info.Extras) != 1 {
+		t.Fatalf("extras len = %d, want 1", len(info.Extras))
+	}
+	if extra := info.Extras[0]; extra.EpID != 301 || extra.DisplayTitle() != "[PV&其他] PV1" {
+		t.Errorf("extra = %+v (%q)", extra, extra.DisplayTitle())
+	}
+	if title := info.Episodes[0].DisplayTitle(); title != "1" {
+		t.Errorf("episode title = %q, want 1", title)
+	}
+	if ep, extra := info.Episodes[1], info.Extras[0]; ep.Season != 2 || ep.Number != 2 || extra.Season != 0 || extra.Number != 1 {
+		t.Errorf("numbers = S%dE%d, extra S%dE%d; want S2E2, S0E1", ep.Season, ep.Number, extra.Season, extra.Number)
+	}
+
+	if info.Desc != "简介" || info.Studio != "某社" {
+		t.Errorf("desc = %q, studio = %q", info.Desc, info.Studio)
+	}
+	if want := time.Date(2023, 10, 1, 0, 0, 0, 0, beijing).Unix(); info.PubDate != want {
+		t.Errorf("pubdate = %d, want %d", info.PubDate, want)
+	}
+	if want := []Actor{{Name: "声优A", Role: "角色A"}, {Name: "声优B"}}; !reflect.DeepEqual(info.Actors, want) {
+		t.Errorf("actors = %+v, want %+v", info.Actors, want)
+	}
+}
+
 func TestStreamResponse_Unmarshal(t *testing.T) {
 	body := `{
 		"code": 0,
@@ -323,6 +482,10 @@ func TestGetVideoInfo_HTTP(t *testing.T) {
 			Title:    "Test Video",
 			Desc:     "Description",
 			Duration: 360,
+			PubDate:  1700000000,
+			TName:    "单机游戏",
+			Pic:      "http://i0.hdslb.com/bfs/archive/cover.jpg",
+			Owner:    Owner{Mid: 42, Name: "Uploader"},
 			Pages: []*PageInfo{
 				{CID: 111, Part: "P1", Duration: 180, Page: 1},
 			},
@@ -344,7 +507,7 @@ func TestGetVideoInfo_HTTP(t *testing.T) {
 		logger:      logrus.New(),
 	}
 
-	videoInfo, err := p.getVideoInfo("BV1qt4y1X7TW")
+	videoInfo, err := p.getVideoInfo(context.Background(), "BV1qt4y1X7TW")
 	if err != nil {
 		t.Fatalf("getVideoInfo failed: %v", err)
 	}
@@ -357,6 +520,15 @@ func TestGetVideoInfo_HTTP(t *testing.T) {
 	if len(videoInfo.Pages) != 1 {
 		t.Errorf("pages len = %d, want 1", len(videoInfo.Pages))
 	}
+	if videoInfo.Uploader != "Uploader" || videoInfo.OwnerMID != 42 {
+		t.Errorf("uploader = %q (%d), want Uploader (42)", videoInfo.Uploader, videoInfo.OwnerMID)
+	}
+	if videoInfo.PubDate != 1700000000 || videoInfo.Category != "单机游戏" {
+		t.Errorf("pubdate/category = %d/%q", videoInfo.PubDate, videoInfo.Category)
+	}
+	if videoInfo.Cover != "http://i0.hdslb.com/bfs/archive/cover.jpg" {
+		t.Errorf("cover = %q", videoInfo.Cover)
+	}
 }
 
 // singleHostTransport rewrites all requests to a single base URL for testing.
@@ -377,3 +549,280 @@ func (t *singleHostTransport) RoundTrip(req *http.Request) (*http.Response, erro
 	newReq.Header = req.Header
 	return http.DefaultTransport.RoundTrip(newReq)
 }
+
+func TestGetVideoInfo_AnonymousUsesWbi(t *testing.T) {
+	var viewPath, viewQuery, cookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/web-interface/nav" {
+			w.Write([]byte(`{"code":-101,"data":{"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+			return
+		}
+		viewPath, viewQuery, cookie = r.URL.Path, r.URL.RawQuery, r.Header.Get("Cookie")
+		data, _ := json.Marshal(VideoAPIResponse{BVID: "BV1qt4y1X7TW", Title: "Public"})
+		json.NewEncoder(w).Encode(APIResponse{Code: 0, Data: data})
+	}))
+	defer server.Close()
+
+	// Persist a logged-in session that the anonymous parser must ignore.
+	dir := t.TempDir()
+	stored := auth.NewAuthManager(dir, logrus.New())
+	stored.SetCookie("SESSDATA", "secret")
+	stored.SetCookie("bili_jct", "secret")
+	if err := stored.SaveCookies(); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &singleHostTransport{base: server.URL}
+	authMgr := auth.NewAnonymousAuthManagerWithClient(logrus.New(), &http.Client{Transport: transport})
+	if err := authMgr.LoadCookies(); err != nil {
+		t.Fatal(err)
+	}
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: transport},
+		authManager: authMgr,
+		logger:      logrus.New(),
+	}
+
+	if _, err := p.getVideoInfo(context.Background(), "BV1qt4y1X7TW"); err != nil {
+		t.Fatalf("getVideoInfo failed: %v", err)
+	}
+	if viewPath != "/x/web-interface/wbi/view" {
+		t.Errorf("path = %q, want the WBI endpoint", viewPath)
+	}
+	if !regexp.MustCompile(`w_rid=[0-9a-f]{32}`).MatchString(viewQuery) {
+		t.Errorf("query %q is missing w_rid", viewQuery)
+	}
+	if regexp.MustCompile(`SESSDATA`).MatchString(cookie) {
+		t.Errorf("anonymous request leaked session cookie: %q", cookie)
+	}
+}
+
+func TestGetVideoInfo_Unavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 62002, "message": "稿件不可见"})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	_, err := p.getVideoInfo(context.Background(), "BV1qt4y1X7TW")
+	if !errors.Is(err, ErrVideoUnavailable) {
+		t.Fatalf("error = %v, want ErrVideoUnavailable", err)
+	}
+	var apiErr *auth.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 62002 {
+		t.Errorf("error = %v, want the APIError of code 62002", err)
+	}
+}
+
+func TestGetVideoStreams_MissingAudioFallsBackToLegacy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fnval") == "16" {
+			// DASH response with video but no audio array.
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{
+					"dash": map[string]interface{}{
+						"video": []map[string]interface{}{{"id": 80, "baseUrl": "https://v/dash.m4s", "width": 1920, "height": 1080}},
+					},
+				},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"quality": 80,
+				"durl":    []map[string]interface{}{{"url": "https://v/legacy.flv", "size": 100}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	streams, err := p.getVideoStreamsByCID("BV1qt4y1X7TW", 1)
+	if err != nil {
+		t.Fatalf("getVideoStreamsByCID: %v", err)
+	}
+	if len(streams) != 1 || streams[0].VideoURL != "https://v/legacy.flv" || !streams[0].Muxed {
+		t.Fatalf("streams = %+v, want the muxed legacy stream", streams)
+	}
+}
+
+func TestGetChapters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x/player/v2" {
+			t.Errorf("path = %s, want /x/player/v2", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"view_points": []map[string]interface{}{
+					{"type": 2, "from": 0, "to": 60, "content": "开场"},
+					{"type": 2, "from": 60, "to": 300, "content": "正片"},
+					{"type": 1, "from": 10, "to": 20, "content": "highlight"},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	chapters, err := p.GetChapters("BV1qt4y1X7TW", 1)
+	if err != nil {
+		t.Fatalf("GetChapters: %v", err)
+	}
+	want := []Chapter{{Title: "开场", Start: 0, End: 60}, {Title: "正片", Start: 60, End: 300}}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("chapters = %+v, want %+v", chapters, want)
+	}
+}
+
+func TestGetPlayerInfo_Subtitles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"subtitle": map[string]interface{}{
+					"subtitles": []map[string]interface{}{
+						{"lan": "zh-CN", "lan_doc": "中文（中国）", "subtitle_url": "//aisubtitle.hdslb.com/bfs/subtitle/zh.json"},
+						{"lan": "en-US", "lan_doc": "English", "subtitle_url": ""},
+					},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	info, err := p.GetPlayerInfo("BV1qt4y1X7TW", 1)
+	if err != nil {
+		t.Fatalf("GetPlayerInfo: %v", err)
+	}
+	want := []Subtitle{{Lang: "zh-CN", Name: "中文（中国）", URL: "https://aisubtitle.hdslb.com/bfs/subtitle/zh.json"}}
+	if !reflect.DeepEqual(info.Subtitles, want) {
+		t.Errorf("subtitles = %+v, want %+v", info.Subtitles, want)
+	}
+}
+
+func TestRefreshVideoStreamsForPage(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		url := fmt.Sprintf("https://v/%d.m4s?deadline=%d", calls, calls)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"dash": map[string]interface{}{
+					"video": []map[string]interface{}{{"id": 80, "baseUrl": url, "width": 1920, "height": 1080}},
+					"audio": []map[string]interface{}{{"id": 30280, "baseUrl": url}},
+				},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+	info := &VideoInfo{BVID: "BV1qt4y1X7TW", Pages: []*PageInfo{{CID: 1}, {CID: 2}}}
+
+	first, err := p.GetVideoStreamsForPage(info, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, _ := p.GetVideoStreamsForPage(info, 2)
+	if cached[0].VideoURL != first[0].VideoURL {
+		t.Fatal("second lookup should come from the cache")
+	}
+
+	fresh, err := p.RefreshVideoStreamsForPage(info, 2)
+	if err != nil {
+		t.Fatalf("RefreshVideoStreamsForPage: %v", err)
+	}
+	if fresh[0].VideoURL == first[0].VideoURL || calls != 2 {
+		t.Errorf("refresh returned %s after %d API calls, want new URLs from a second call", fresh[0].VideoURL, calls)
+	}
+}
+
+// doerFunc is an auth.HTTPDoer answering from a function.
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestNewBilibiliParserWithClient(t *testing.T) {
+	var paths []string
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		rec := httptest.NewRecorder()
+		rec.WriteString(`{"code":0,"data":{"bvid":"BV1qt4y1X7TW","title":"Mocked","duration":60,
+			"owner":{"mid":1,"name":"Up"},"pages":[{"cid":5,"part":"P1","duration":60,"page":1}]}}`)
+		return rec.Result(), nil
+	})
+
+	authMgr := auth.NewAuthManager(t.TempDir(), logrus.New())
+	authMgr.SetCookie("SESSDATA", "s")
+	authMgr.SetCookie("bili_jct", "j")
+	p := NewBilibiliParserWithClient(authMgr, logrus.New(), client)
+	p.SetRequestDelay(time.Millisecond, time.Millisecond) // wraps the client
+
+	info, err := p.ParseURL("https://www.bilibili.com/video/BV1qt4y1X7TW")
+	if err != nil {
+		t.Fatalf("ParseURL: %v", err)
+	}
+	if info.Title != "Mocked" || info.Uploader != "Up" || len(info.Pages) != 1 {
+		t.Errorf("info = %+v", info)
+	}
+	if len(paths) != 1 || paths[0] != "/x/web-interface/view" {
+		t.Errorf("requests = %v", paths)
+	}
+}
+
+func TestParseURL_Premiere(t *testing.T) {
+	start := time.Now().Add(time.Hour).Unix()
+	for _, tt := range []struct {
+		name, data string
+		want       int64
+	}{
+		{"premiere", fmt.Sprintf(`"pubdate":1700000000,"premiere":{"start_time":%d}`, start), start},
+		{"scheduled", fmt.Sprintf(`"pubdate":%d,"premiere":null`, start), start},
+		{"out", `"pubdate":1700000000,"premiere":{"start_time":1700000000}`, 0},
+	} {
+		client := doerFunc(func(req *http.Request) (*http.Response, error) {
+			rec := httptest.NewRecorder()
+			rec.WriteString(`{"code":0,"data":{"bvid":"BV1qt4y1X7TW","title":"T",` + tt.data + `}}`)
+			return rec.Result(), nil
+		})
+		p := NewBilibiliParserWithClient(auth.NewAuthManager(t.TempDir(), logrus.New()), logrus.New(), client)
+		info, err := p.ParseURL("https://www.bilibili.com/video/BV1qt4y1X7TW")
+		if err != nil {
+			t.Fatalf("%s: ParseURL: %v", tt.name, err)
+		}
+		if info.Premiere != tt.want {
+			t.Errorf("%s: Premiere = %d, want %d", tt.name, info.Premiere, tt.want)
+		}
+	}
+}
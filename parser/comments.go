@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/dengmengmian/goBili/auth"
+)
+
+// CommentSort is the order of the comments listed by GetComments.
+type CommentSort int
+
+// Comment orders of the reply API.
+const (
+	CommentsByTime    CommentSort = 0 // newest first
+	CommentsByLikes   CommentSort = 1
+	CommentsByReplies CommentSort = 2
+)
+
+// CommentsPageSize is the number of comments per page of GetComments and
+// GetReplies, the most the reply API serves.
+const CommentsPageSize = 20
+
+// Comment is a comment (评论) on a video, or a reply to one.
+type Comment struct {
+	RPID    int64  `json:"rpid"`
+	Root    int64  `json:"root,omitempty"`   // the top-level comment of a reply
+	Parent  int64  `json:"parent,omitempty"` // the comment a reply answers
+	Mid     int64  `json:"mid"`
+	User    string `json:"user"`
+	Message string `json:"message"`
+	Likes   int    `json:"likes"`
+	Time    int64  `json:"time"` // Unix seconds
+	// ReplyCount is the number of replies to a top-level comment; Replies
+	// holds those fetched.
+	ReplyCount int        `json:"reply_count,omitempty"`
+	Replies    []*Comment `json:"replies,omitempty"`
+}
+
+// replyItem is a comment in the reply API.
+type replyItem struct {
+	RPID   int64 `json:"rpid"`
+	Root   int64 `json:"root"`
+	Parent int64 `json:"parent"`
+	Mid    int64 `json:"mid"`
+	Member struct {
+		Uname string `json:"uname"`
+	} `json:"member"`
+	Content struct {
+		Message string `json:"message"`
+	} `json:"content"`
+	Like   int   `json:"like"`
+	Ctime  int64 `json:"ctime"`
+	RCount int   `json:"rcount"`
+}
+
+func (r *replyItem) comment() *Comment {
+	return &Comment{
+		RPID: r.RPID, Root: r.Root, Parent: r.Parent,
+		Mid: r.Mid, User: r.Member.Uname, Message: r.Content.Message,
+		Likes: r.Like, Time: r.Ctime, ReplyCount: r.RCount,
+	}
+}
+
+// replyData is the data of the reply APIs.
+type replyData struct {
+	Page struct {
+		Count int `json:"count"` // top-level comments, or replies to root
+	} `json:"page"`
+	Replies []*replyItem `json:"replies"`
+}
+
+// GetComments returns page pn (from 1) of the top-level comments on the
+// video bvid in the given order, and the number of top-level comments.
+// Their Replies are left empty; see GetReplies.
+func (p *BilibiliParser) GetComments(ctx context.Context, bvid string, pn int, sort CommentSort) ([]*Comment, int, error) {
+	return p.getReplies(ctx, "/x/v2/reply", "get comments", bvid, url.Values{
+		"pn":   {strconv.Itoa(pn)},
+		"sort": {strconv.Itoa(int(sort))},
+	})
+}
+
+// GetReplies returns page pn (from 1) of the replies to the top-level
+// comment root on the video bvid, oldest first, and their number.
+func (p *BilibiliParser) GetReplies(ctx context.Context, bvid string, root int64, pn int) ([]*Comment, int, error) {
+	return p.getReplies(ctx, "/x/v2/reply/reply", "get replies", bvid, url.Values{
+		"pn":   {strconv.Itoa(pn)},
+		"root": {strconv.FormatInt(root, 10)},
+	})
+}
+
+// getReplies requests one page of a reply API for the video bvid.
+func (p *BilibiliParser) getReplies(ctx context.Context, path, op, bvid string, params url.Values) ([]*Comment, int, error) {
+	aid, err := bvidToAID(bvid)
+	if err != nil {
+		return nil, 0, err
+	}
+	params.Set("type", "1") // comments on a video
+	params.Set("oid", strconv.FormatInt(aid, 10))
+	params.Set("ps", strconv.Itoa(CommentsPageSize))
+
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiBase+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := p.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	apiResp, err := decodeAPI[replyData](resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := auth.CheckResponse(op, apiResp.Code, apiResp.Message); err != nil {
+		return nil, 0, err
+	}
+	comments := make([]*Comment, 0, len(apiResp.Data.Replies))
+	for _, r := range apiResp.Data.Replies {
+		comments = append(comments, r.comment())
+	}
+	return comments, apiResp.Data.Page.Count, nil
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGetComments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("type") != "1" || q.Get("oid") != "170001" || q.Get("ps") != "20" {
+			t.Errorf("reply request = %s", r.URL)
+		}
+		switch r.URL.Path {
+		case "/x/v2/reply":
+			if q.Get("pn") != "2" || q.Get("sort") != "1" {
+				t.Errorf("comments request = %s", r.URL)
+			}
+			w.Write([]byte(`{"code":0,"data":{"page":{"count":21},"replies":[
+				{"rpid":7,"root":0,"parent":0,"mid":9,"member":{"uname":"U"},
+				 "content":{"message":"hi"},"like":3,"ctime":1700000000,"rcount":1}]}}`))
+		case "/x/v2/reply/reply":
+			if q.Get("root") != "7" {
+				t.Errorf("replies request = %s", r.URL)
+			}
+			w.Write([]byte(`{"code":0,"data":{"page":{"count":1},"replies":[
+				{"rpid":8,"root":7,"parent":7,"mid":10,"member":{"uname":"V"},
+				 "content":{"message":"yo"},"like":0,"ctime":1700000100}]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	comments, total, err := p.GetComments(context.Background(), "BV17x411w7KC", 2, CommentsByLikes)
+	if err != nil {
+		t.Fatalf("GetComments: %v", err)
+	}
+	want := Comment{RPID: 7, Mid: 9, User: "U", Message: "hi", Likes: 3, Time: 1700000000, ReplyCount: 1}
+	if total != 21 || len(comments) != 1 || !reflect.DeepEqual(*comments[0], want) {
+		t.Errorf("GetComments = %+v, %d", comments, total)
+	}
+
+	replies, total, err := p.GetReplies(context.Background(), "BV17x411w7KC", 7, 1)
+	if err != nil {
+		t.Fatalf("GetReplies: %v", err)
+	}
+	if total != 1 || len(replies) != 1 || replies[0].Root != 7 || replies[0].Parent != 7 || replies[0].User != "V" {
+		t.Errorf("GetReplies = %+v, %d", replies, total)
+	}
+}
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Danmaku modes as used in the "p" attribute of Bilibili's XML.
+const (
+	DanmakuScroll  = 1 // right to left
+	DanmakuBottom  = 4 // fixed at the bottom
+	DanmakuTop     = 5 // fixed at the top
+	DanmakuReverse = 6 // left to right
+)
+
+// Danmaku is one bullet comment
+type Danmaku struct {
+	Time  float64 `json:"time"`  // seconds into the video
+	Mode  int     `json:"mode"`  // see the Danmaku* mode constants
+	Size  int     `json:"size"`  // font size, 25 is normal
+	Color int     `json:"color"` // 0xRRGGBB
+	Text  string  `json:"text"`
+}
+
+// GetDanmaku returns the danmaku of one page of a video, ordered by time.
+func (p *BilibiliParser) GetDanmaku(cid int64) ([]Danmaku, error) {
+	apiURL := apiBase + "/x/v1/dm/list.so?oid=" + strconv.FormatInt(cid, 10)
+
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get danmaku: HTTP %d", resp.StatusCode)
+	}
+
+	// The endpoint answers with a raw deflate stream whether or not it was
+	// asked to.
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "deflate") {
+		fr := flate.NewReader(resp.Body)
+		defer fr.Close()
+		body = fr
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read danmaku: %w", err)
+	}
+	return ParseDanmakuXML(data)
+}
+
+// ParseDanmakuXML parses danmaku in Bilibili's XML format. Entries with a
+// malformed "p" attribute and unsupported modes (advanced and code danmaku)
+// are skipped.
+func ParseDanmakuXML(data []byte) ([]Danmaku, error) {
+	var doc struct {
+		Items []struct {
+			P    string `xml:"p,attr"`
+			Text string `xml:",chardata"`
+		} `xml:"d"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid danmaku XML: %w", err)
+	}
+
+	items := make([]Danmaku, 0, len(doc.Items))
+	for _, d := range doc.Items {
+		fields := strings.Split(d.P, ",")
+		if len(fields) < 4 {
+			continue
+		}
+		t, err1 := strconv.ParseFloat(fields[0], 64)
+		mode, err2 := strconv.Atoi(fields[1])
+		size, err3 := strconv.Atoi(fields[2])
+		color, err4 := strconv.Atoi(fields[3])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		switch mode {
+		case DanmakuScroll, 2, 3:
+			mode = DanmakuScroll
+		case DanmakuBottom, DanmakuTop, DanmakuReverse:
+		default:
+			continue
+		}
+		items = append(items, Danmaku{Time: t, Mode: mode, Size: size, Color: color, Text: d.Text})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Time < items[j].Time })
+	return items, nil
+}
+
+// EncodeDanmakuXML writes danmaku in Bilibili's XML format, so saved files
+// open in existing danmaku players and converters.
+func EncodeDanmakuXML(items []Danmaku) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString("<i>\n")
+	for _, d := range items {
+		fmt.Fprintf(&b, `  <d p="%s,%d,%d,%d">`, strconv.FormatFloat(d.Time, 'f', -1, 64), d.Mode, d.Size, d.Color)
+		xml.EscapeText(&b, []byte(d.Text))
+		b.WriteString("</d>\n")
+	}
+	b.WriteString("</i>\n")
+	return b.Bytes()
+}
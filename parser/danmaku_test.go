@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"bytes"
+	"compress/flate"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+const danmakuXML = `<?xml version="1.0" encoding="UTF-8"?><i><chatid>1</chatid>
+<d p="12.5,1,25,16777215,1600000000,0,abc,1">later</d>
+<d p="1.25,5,25,16711680,1600000000,0,abc,2">top &amp; red</d>
+<d p="3,7,25,16777215,1600000000,0,abc,3">[advanced]</d>
+<d p="bad">broken</d>
+<d p="2,2,18,16777215,1600000000,0,abc,4">small</d>
+</i>`
+
+func TestParseDanmakuXML(t *testing.T) {
+	items, err := ParseDanmakuXML([]byte(danmakuXML))
+	if err != nil {
+		t.Fatalf("ParseDanmakuXML: %v", err)
+	}
+	want := []Danmaku{
+		{Time: 1.25, Mode: DanmakuTop, Size: 25, Color: 0xFF0000, Text: "top & red"},
+		{Time: 2, Mode: DanmakuScroll, Size: 18, Color: 0xFFFFFF, Text: "small"},
+		{Time: 12.5, Mode: DanmakuScroll, Size: 25, Color: 0xFFFFFF, Text: "later"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("items = %+v, want %+v", items, want)
+	}
+
+	again, err := ParseDanmakuXML(EncodeDanmakuXML(items))
+	if err != nil || !reflect.DeepEqual(again, want) {
+		t.Errorf("round trip = %+v (%v), want %+v", again, err, want)
+	}
+}
+
+func TestGetDanmaku_Deflate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/x/v1/dm/list.so" || r.URL.Query().Get("oid") != "42" {
+			t.Errorf("request = %s", r.URL)
+		}
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		fw.Write([]byte(danmakuXML))
+		fw.Close()
+		w.Header().Set("Content-Encoding", "deflate")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	items, err := p.GetDanmaku(42)
+	if err != nil {
+		t.Fatalf("GetDanmaku: %v", err)
+	}
+	if len(items) != 3 {
+		t.Errorf("got %d danmaku, want 3", len(items))
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// Extractor looks up the content behind one kind of URL. ParseURL hands a
+// URL to the first extractor whose Match accepts it, so new content types
+// can be added without touching the others.
+//
+// The streams of the result are looked up by its BVID and CIDs, so an
+// extractor returns Bilibili videos, however it finds them.
+type Extractor interface {
+	// Match reports whether the extractor handles u. It must not make
+	// requests.
+	Match(u *url.URL) bool
+
+	// Extract looks up the video, multi-part video or playlist at u.
+	Extract(ctx context.Context, u *url.URL) (*VideoInfo, error)
+}
+
+// RegisterExtractor adds e to the extractors of ParseURL. Registered
+// extractors are tried in the order they were added, before the built-in
+// ones, so they can also take over URLs those handle. It must not be
+// called concurrently with ParseURL.
+func (p *BilibiliParser) RegisterExtractor(e Extractor) {
+	p.extractors = append(p.extractors, e)
+}
+
+// allExtractors returns the extractors in the order ParseURL tries them.
+func (p *BilibiliParser) allExtractors() []Extractor {
+	all := make([]Extractor, 0, len(p.extractors)+3)
+	all = append(all, p.extractors...)
+	return append(all, videoExtractor{p}, bangumiExtractor{p}, audioExtractor{p})
+}
+
+// videoExtractor handles /video/BV... URLs, turning multi-part videos
+// into playlists of their parts.
+type videoExtractor struct{ p *BilibiliParser }
+
+func (e videoExtractor) Match(u *url.URL) bool {
+	return strings.Contains(u.Path, "/video/")
+}
+
+func (e videoExtractor) Extract(ctx context.Context, u *url.URL) (*VideoInfo, error) {
+	return e.p.parseVideoURL(ctx, u.String())
+}
+
+// bangumiExtractor handles /bangumi/play/ss... season URLs.
+type bangumiExtractor struct{ p *BilibiliParser }
+
+func (e bangumiExtractor) Match(u *url.URL) bool {
+	return strings.Contains(u.Path, "/bangumi/play/")
+}
+
+func (e bangumiExtractor) Extract(ctx context.Context, u *url.URL) (*VideoInfo, error) {
+	return e.p.parsePlaylistURL(ctx, u.String())
+}
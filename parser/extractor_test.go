@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+// courseExtractor is a third-party extractor for cheese course URLs.
+type courseExtractor struct{ calls int }
+
+func (e *courseExtractor) Match(u *url.URL) bool {
+	return strings.HasPrefix(u.Path, "/cheese/")
+}
+
+func (e *courseExtractor) Extract(ctx context.Context, u *url.URL) (*VideoInfo, error) {
+	e.calls++
+	return &VideoInfo{Title: "Course", Type: "playlist"}, nil
+}
+
+func TestRegisterExtractor(t *testing.T) {
+	var requests int
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		requests++
+		rec := httptest.NewRecorder()
+		rec.WriteString(`{"code":0,"data":{"bvid":"BV1qt4y1X7TW","title":"Video","pages":[{"cid":5,"page":1}]}}`)
+		return rec.Result(), nil
+	})
+	p := NewBilibiliParserWithClient(auth.NewAuthManager(t.TempDir(), logrus.New()), logrus.New(), client)
+
+	if _, err := p.ParseURL("https://www.bilibili.com/cheese/play/ss123"); err == nil {
+		t.Error("course URL parsed without an extractor for it")
+	}
+
+	course := &courseExtractor{}
+	p.RegisterExtractor(course)
+	info, err := p.ParseURL("https://www.bilibili.com/cheese/play/ss123")
+	if err != nil || info.Title != "Course" || course.calls != 1 {
+		t.Errorf("ParseURL(course) = %+v, %v; %d calls", info, err, course.calls)
+	}
+
+	// Other URLs still reach the built-in extractors.
+	info, err = p.ParseURL("https://www.bilibili.com/video/BV1qt4y1X7TW")
+	if err != nil || info.Title != "Video" || course.calls != 1 || requests != 1 {
+		t.Errorf("ParseURL(video) = %+v, %v", info, err)
+	}
+}
+
+func TestParseURLContext_Canceled(t *testing.T) {
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		if err := req.Context().Err(); err != nil {
+			return nil, err
+		}
+		t.Error("request sent without the context")
+		return httptest.NewRecorder().Result(), nil
+	})
+	p := NewBilibiliParserWithClient(auth.NewAuthManager(t.TempDir(), logrus.New()), logrus.New(), client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, u := range []string{"https://www.bilibili.com/video/BV1qt4y1X7TW", "https://www.bilibili.com/bangumi/play/ss2"} {
+		if _, err := p.ParseURLContext(ctx, u); !errors.Is(err, context.Canceled) {
+			t.Errorf("ParseURLContext(%s) error = %v, want context.Canceled", u, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/ratelimit"
+)
+
+// SetRequestDelay spaces out the parser's API calls: each call starts a
+// random delay between min and max after the previous one, so long runs
+// (space syncs, big playlists) stay under Bilibili's rate limiting. A zero
+// max disables the delay.
+func (p *BilibiliParser) SetRequestDelay(min, max time.Duration) {
+	if paced, ok := p.client.(*pacedDoer); ok {
+		p.client = paced.base
+	}
+	if max <= 0 {
+		return
+	}
+	p.client = &pacedDoer{base: p.client, min: min, max: max}
+}
+
+// SetRateLimiter makes the parser's API calls take a token of the API
+// bucket of l first, sharing that budget with everything else using l. A
+// nil l, or one without an API bucket, removes the limit. The request
+// delay, if any, is waited before the token.
+func (p *BilibiliParser) SetRateLimiter(l *ratelimit.Limiter) {
+	paced, isPaced := p.client.(*pacedDoer)
+	if isPaced {
+		p.client = paced.base
+	}
+	if limited, ok := p.client.(*limitedDoer); ok {
+		p.client = limited.base
+	}
+	if bucket := l.APIBucket(); bucket != nil {
+		p.client = &limitedDoer{base: p.client, bucket: bucket}
+	}
+	if isPaced {
+		paced.base = p.client
+		p.client = paced
+	}
+}
+
+// ParseDelayRange parses a request delay: a single duration ("500ms") or a
+// range ("300ms-2s") to pick from at random. An empty string means none.
+func ParseDelayRange(s string) (min, max time.Duration, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, nil
+	}
+	lo, hi, isRange := strings.Cut(s, "-")
+	if min, err = time.ParseDuration(strings.TrimSpace(lo)); err != nil {
+		return 0, 0, fmt.Errorf("invalid delay %q: %w", s, err)
+	}
+	max = min
+	if isRange {
+		if max, err = time.ParseDuration(strings.TrimSpace(hi)); err != nil {
+			return 0, 0, fmt.Errorf("invalid delay %q: %w", s, err)
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid delay %q: want a duration or an increasing range like 300ms-2s", s)
+	}
+	return min, max, nil
+}
+
+// pacedDoer delays each request until a random interval after the one
+// before it has passed.
+type pacedDoer struct {
+	base     auth.HTTPDoer
+	min, max time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request
+}
+
+func (t *pacedDoer) Do(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	now := time.Now()
+	start := t.next
+	if start.Before(now) {
+		start = now
+	}
+	delay := t.min
+	if t.max > t.min {
+		delay += time.Duration(rand.Int63n(int64(t.max - t.min)))
+	}
+	t.next = start.Add(delay)
+	t.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	return t.base.Do(req)
+}
+
+// limitedDoer takes a token of bucket before each request.
+type limitedDoer struct {
+	base   auth.HTTPDoer
+	bucket *ratelimit.Bucket
+}
+
+func (t *limitedDoer) Do(req *http.Request) (*http.Response, error) {
+	if err := t.bucket.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.Do(req)
+}
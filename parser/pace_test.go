@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/ratelimit"
+)
+
+func TestParseDelayRange(t *testing.T) {
+	for _, tt := range []struct {
+		in       string
+		min, max time.Duration
+		wantErr  bool
+	}{
+		{"", 0, 0, false},
+		{"500ms", 500 * time.Millisecond, 500 * time.Millisecond, false},
+		{"300ms-2s", 300 * time.Millisecond, 2 * time.Second, false},
+		{" 1s - 3s ", time.Second, 3 * time.Second, false},
+		{"2s-1s", 0, 0, true},
+		{"soon", 0, 0, true},
+		{"1s-", 0, 0, true},
+	} {
+		min, max, err := ParseDelayRange(tt.in)
+		if (err != nil) != tt.wantErr || min != tt.min || max != tt.max {
+			t.Errorf("ParseDelayRange(%q) = %v, %v, %v; want %v, %v, error %v", tt.in, min, max, err, tt.min, tt.max, tt.wantErr)
+		}
+	}
+}
+
+func TestSetRequestDelay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	p := &BilibiliParser{client: server.Client()}
+	p.SetRequestDelay(40*time.Millisecond, 40*time.Millisecond)
+	p.SetRequestDelay(40*time.Millisecond, 40*time.Millisecond) // replaces, does not stack
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := p.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// The first call goes out at once, the next two 40ms apart.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("3 paced calls took %v, want about 80ms", elapsed)
+	}
+
+	p.SetRequestDelay(0, 0)
+	if _, ok := p.client.(*pacedDoer); ok {
+		t.Error("SetRequestDelay(0, 0) kept the pacing")
+	}
+}
+
+func TestSetRateLimiter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	p := &BilibiliParser{client: server.Client()}
+	p.SetRequestDelay(time.Millisecond, time.Millisecond)
+	p.SetRateLimiter(ratelimit.New(20, 0))
+	p.SetRateLimiter(ratelimit.New(20, 0)) // replaces, does not stack
+
+	paced, ok := p.client.(*pacedDoer)
+	if !ok {
+		t.Fatalf("client = %T, want the pacing kept outermost", p.client)
+	}
+	limited, ok := paced.base.(*limitedDoer)
+	if !ok || limited.base != server.Client() {
+		t.Fatalf("paced client = %T, want one limiter around the base client", paced.base)
+	}
+
+	start := time.Now()
+	for i := 0; i < 22; i++ {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := p.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// 20 go out in the burst, the last two 50ms apart.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("22 calls at 20/s took %v, want at least 100ms", elapsed)
+	}
+
+	p.SetRateLimiter(nil)
+	if paced, ok := p.client.(*pacedDoer); !ok || paced.base != server.Client() {
+		t.Errorf("SetRateLimiter(nil) left client %T", p.client)
+	}
+}
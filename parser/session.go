@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"sync"
+	"time"
+)
+
+// streamCacheTTL bounds how long resolved stream URLs are reused. Bilibili
+// CDN URLs stay valid for roughly two hours; a shorter TTL leaves headroom
+// for the download itself.
+const streamCacheTTL = 30 * time.Minute
+
+// playSession holds the playurl parameters negotiated for one video.
+type playSession struct {
+	id     string // "session" value returned by playurl, sent back on later requests
+	qn     int    // quality granted by the legacy API
+	legacy bool   // DASH is unusable for this video; request legacy streams directly
+	area   string // area proxy the playurl requests go through; see SetAreaProxies
+}
+
+// cachedStreams is a playurl result for one cid.
+type cachedStreams struct {
+	streams []*StreamInfo
+	fetched time.Time
+}
+
+// playurlCache remembers playurl sessions per video and resolved streams per
+// cid for the lifetime of a parser. Stream URLs are per cid, so every page
+// still takes one playurl request; the cache saves the repeat requests for
+// a page already resolved within streamCacheTTL and, for videos that only
+// serve legacy streams, the DASH request that would fail again on every
+// later page. The zero value is ready to use.
+type playurlCache struct {
+	mu       sync.Mutex
+	sessions map[string]*playSession
+	byCID    map[int64]cachedStreams
+	now      func() time.Time // for tests
+}
+
+func (c *playurlCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// session returns a copy of the negotiated parameters for bvid.
+func (c *playurlCache) session(bvid string) playSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s := c.sessions[bvid]; s != nil {
+		return *s
+	}
+	return playSession{}
+}
+
+// update applies fn to the session of bvid, creating it if needed.
+func (c *playurlCache) update(bvid string, fn func(*playSession)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessions == nil {
+		c.sessions = make(map[string]*playSession)
+	}
+	s := c.sessions[bvid]
+	if s == nil {
+		s = &playSession{}
+		c.sessions[bvid] = s
+	}
+	fn(s)
+}
+
+func (c *playurlCache) setSessionID(bvid, id string) {
+	c.update(bvid, func(s *playSession) { s.id = id })
+}
+
+func (c *playurlCache) setLegacy(bvid string) {
+	c.update(bvid, func(s *playSession) { s.legacy = true })
+}
+
+func (c *playurlCache) setArea(bvid, area string) {
+	c.update(bvid, func(s *playSession) { s.area = area })
+}
+
+func (c *playurlCache) setQuality(bvid string, qn int) {
+	c.update(bvid, func(s *playSession) { s.qn = qn })
+}
+
+// streams returns the cached streams of cid, or nil if absent or stale.
+func (c *playurlCache) streams(cid int64) []*StreamInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.byCID[cid]
+	if !ok || c.clock().Sub(entry.fetched) > streamCacheTTL {
+		return nil
+	}
+	return entry.streams
+}
+
+// putStreams caches the streams resolved for cid.
+func (c *playurlCache) putStreams(cid int64, streams []*StreamInfo) {
+	if len(streams) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byCID == nil {
+		c.byCID = make(map[int64]cachedStreams)
+	}
+	c.byCID[cid] = cachedStreams{streams: streams, fetched: c.clock()}
+}
+
+// InvalidateStreams drops the cached streams of cid, e.g. after their URLs
+// were rejected, so the next lookup asks the API again.
+func (p *BilibiliParser) InvalidateStreams(cid int64) {
+	p.sessions.mu.Lock()
+	defer p.sessions.mu.Unlock()
+	delete(p.sessions.byCID, cid)
+}
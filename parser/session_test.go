@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGetVideoStreams_ReusesPlayurlSession(t *testing.T) {
+	var dashCalls, legacyCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fnval") == "16" {
+			dashCalls.Add(1)
+			// DASH without audio forces the legacy fallback.
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"code": 0,
+				"data": map[string]interface{}{
+					"session": "abc",
+					"dash": map[string]interface{}{
+						"video": []map[string]interface{}{{"id": 80, "baseUrl": "https://v/dash.m4s"}},
+					},
+				},
+			})
+			return
+		}
+		legacyCalls.Add(1)
+		if qn := r.URL.Query().Get("qn"); legacyCalls.Load() > 1 && qn != "64" {
+			t.Errorf("legacy qn = %s, want the negotiated 64", qn)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{
+				"quality": 64,
+				"durl":    []map[string]interface{}{{"url": "https://v/legacy.flv"}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+
+	for _, cid := range []int64{1, 2, 3, 1} {
+		if _, err := p.getVideoStreamsByCID("BV1", cid); err != nil {
+			t.Fatalf("getVideoStreamsByCID(%d): %v", cid, err)
+		}
+	}
+
+	// Page 1 needs DASH + legacy; pages 2 and 3 go straight to legacy, and
+	// the repeated page 1 is served from the cache.
+	if got := dashCalls.Load(); got != 1 {
+		t.Errorf("DASH calls = %d, want 1", got)
+	}
+	if got := legacyCalls.Load(); got != 3 {
+		t.Errorf("legacy calls = %d, want 3", got)
+	}
+}
+
+func TestPlayurlCache_Expires(t *testing.T) {
+	now := time.Now()
+	c := &playurlCache{now: func() time.Time { return now }}
+	c.putStreams(1, []*StreamInfo{{Quality: 80}})
+	if c.streams(1) == nil {
+		t.Fatal("fresh streams should be cached")
+	}
+
+	now = now.Add(streamCacheTTL + time.Second)
+	if c.streams(1) != nil {
+		t.Error("stale streams should not be returned")
+	}
+}
+
+func TestInvalidateStreams(t *testing.T) {
+	p := &BilibiliParser{}
+	p.sessions.putStreams(1, []*StreamInfo{{Quality: 80}})
+	p.InvalidateStreams(1)
+	if p.sessions.streams(1) != nil {
+		t.Error("invalidated streams should not be returned")
+	}
+}
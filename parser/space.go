@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/auth"
+)
+
+// UploaderVideo is a video listed on an uploader's space.
+type UploaderVideo struct {
+	BVID    string `json:"bvid"`
+	AID     int64  `json:"aid"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	Mid     int64  `json:"mid"`
+	Created int64  `json:"created"` // publish time, Unix seconds
+	Length  string `json:"length"`  // "mm:ss", or "h:mm:ss"
+	Pic     string `json:"pic"`
+}
+
+// Duration returns the length of the video in seconds, or 0 when it is
+// not known.
+func (v *UploaderVideo) Duration() int {
+	seconds := 0
+	for _, field := range strings.Split(v.Length, ":") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// GetUploaderVideos returns page pn (from 1) of the videos of the uploader
+// mid, newest first, with up to ps videos per page, and the uploader's
+// total number of videos. The space API always requires a WBI signature.
+func (p *BilibiliParser) GetUploaderVideos(mid int64, pn, ps int) ([]*UploaderVideo, int, error) {
+	params := url.Values{
+		"mid":   {strconv.FormatInt(mid, 10)},
+		"pn":    {strconv.Itoa(pn)},
+		"ps":    {strconv.Itoa(ps)},
+		"order": {"pubdate"},
+	}
+	signed, err := p.authManager.SignWbi(params)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiBase+"/x/space/wbi/arc/search?"+signed.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	type arcSearchData struct {
+		List struct {
+			VList []*UploaderVideo `json:"vlist"`
+		} `json:"list"`
+		Page struct {
+			Count int `json:"count"`
+		} `json:"page"`
+	}
+	apiResp, err := decodeAPI[arcSearchData](resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := auth.CheckResponse("get uploader videos", apiResp.Code, apiResp.Message); err != nil {
+		return nil, 0, err
+	}
+	return apiResp.Data.List.VList, apiResp.Data.Page.Count, nil
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestUploaderVideoDuration(t *testing.T) {
+	tests := map[string]int{
+		"04:05":   245,
+		"1:02:03": 3723,
+		"":        0,
+		"--:--":   0,
+	}
+	for length, want := range tests {
+		v := &UploaderVideo{Length: length}
+		if got := v.Duration(); got != want {
+			t.Errorf("Duration of %q = %d, want %d", length, got, want)
+		}
+	}
+}
+
+func TestGetUploaderVideos(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/web-interface/nav" {
+			w.Write([]byte(`{"code":0,"data":{"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+			return
+		}
+		if r.URL.Path != "/x/space/wbi/arc/search" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"code":0,"data":{
+			"list":{"vlist":[
+				{"bvid":"BV1new","aid":2,"title":"New","author":"Up","mid":42,"created":1700000100,"length":"10:00"},
+				{"bvid":"BV1old","aid":1,"title":"Old","author":"Up","mid":42,"created":1700000000,"length":"01:30"}]},
+			"page":{"pn":1,"ps":2,"count":7}}}`))
+	}))
+	defer server.Close()
+
+	transport := &singleHostTransport{base: server.URL}
+	authMgr := auth.NewAuthManagerWithClient(t.TempDir(), logrus.New(), &http.Client{Transport: transport})
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: transport},
+		authManager: authMgr,
+		logger:      logrus.New(),
+	}
+
+	videos, total, err := p.GetUploaderVideos(42, 1, 2)
+	if err != nil {
+		t.Fatalf("GetUploaderVideos: %v", err)
+	}
+	if total != 7 || len(videos) != 2 {
+		t.Fatalf("got %d videos of %d, want 2 of 7", len(videos), total)
+	}
+	if v := videos[0]; v.BVID != "BV1new" || v.Author != "Up" || v.Created != 1700000100 || v.Duration() != 600 {
+		t.Errorf("first video = %+v", v)
+	}
+	for _, param := range []string{"mid=42", "pn=1", "ps=2", "order=pubdate"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("query %q is missing %s", query, param)
+		}
+	}
+	if !regexp.MustCompile(`w_rid=[0-9a-f]{32}`).MatchString(query) {
+		t.Errorf("query %q is missing w_rid", query)
+	}
+}
+
+func TestGetUploaderVideos_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x/web-interface/nav" {
+			w.Write([]byte(`{"code":0,"data":{"wbi_img":{"img_url":"https://x/a.png","sub_url":"https://x/b.png"}}}`))
+			return
+		}
+		w.Write([]byte(`{"code":-352,"message":"风控校验失败"}`))
+	}))
+	defer server.Close()
+
+	transport := &singleHostTransport{base: server.URL}
+	authMgr := auth.NewAuthManagerWithClient(t.TempDir(), logrus.New(), &http.Client{Transport: transport})
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: transport},
+		authManager: authMgr,
+		logger:      logrus.New(),
+	}
+
+	if _, _, err := p.GetUploaderVideos(42, 1, 30); !errors.Is(err, &auth.APIError{Code: -352}) {
+		t.Errorf("error = %v, want the API error code", err)
+	}
+}
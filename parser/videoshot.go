@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dengmengmian/goBili/auth"
+)
+
+// Storyboard is the videoshot (视频快照) of one page of a video: the
+// preview thumbnails the player shows while seeking, packed row by row
+// into sprite sheets of Columns×Rows thumbnails of Width×Height pixels.
+type Storyboard struct {
+	Images  []string `json:"images"` // sprite sheet URLs
+	Columns int      `json:"columns"`
+	Rows    int      `json:"rows"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	// Times is the time in seconds of each thumbnail, in sprite order. The
+	// last sheet is usually not full, so it also gives their number.
+	Times []int `json:"times,omitempty"`
+}
+
+// Thumbnails returns the number of thumbnails in the sprite sheets.
+func (s *Storyboard) Thumbnails() int {
+	n := len(s.Images) * s.Columns * s.Rows
+	if len(s.Times) > 0 && len(s.Times) < n {
+		n = len(s.Times)
+	}
+	return n
+}
+
+// GetStoryboard returns the videoshot of one page of a video. Videos
+// without one, such as very short or new uploads, return an error.
+func (p *BilibiliParser) GetStoryboard(bvid string, cid int64) (*Storyboard, error) {
+	params := url.Values{
+		"bvid":  {bvid},
+		"cid":   {strconv.FormatInt(cid, 10)},
+		"index": {"1"}, // include the thumbnail times
+	}
+	req, err := p.authManager.CreateAuthenticatedRequest("GET", apiBase+"/x/player/videoshot?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	type videoshotData struct {
+		Image    []string `json:"image"`
+		Index    []int    `json:"index"`
+		ImgXLen  int      `json:"img_x_len"`
+		ImgYLen  int      `json:"img_y_len"`
+		ImgXSize int      `json:"img_x_size"`
+		ImgYSize int      `json:"img_y_size"`
+	}
+	apiResp, err := decodeAPI[videoshotData](resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := auth.CheckResponse("get videoshot", apiResp.Code, apiResp.Message); err != nil {
+		return nil, fmt.Errorf("failed to get videoshot: %w", err)
+	}
+	data := apiResp.Data
+	if len(data.Image) == 0 || data.ImgXLen <= 0 || data.ImgYLen <= 0 {
+		return nil, fmt.Errorf("video %s has no videoshot", bvid)
+	}
+
+	sb := &Storyboard{
+		Columns: data.ImgXLen,
+		Rows:    data.ImgYLen,
+		Width:   data.ImgXSize,
+		Height:  data.ImgYSize,
+		Times:   data.Index,
+	}
+	for _, image := range data.Image {
+		if strings.HasPrefix(image, "//") {
+			image = "https:" + image
+		}
+		sb.Images = append(sb.Images, image)
+	}
+	return sb, nil
+}
@@ -0,0 +1,45 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/sirupsen/logrus"
+)
+
+func TestGetStoryboard(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.URL.Path != "/x/player/videoshot" || q.Get("bvid") != "BV1" || q.Get("cid") != "2" || q.Get("index") != "1" {
+			t.Errorf("videoshot request = %s", r.URL)
+		}
+		w.Write([]byte(`{"code":0,"data":{"pvdata":"//i0.hdslb.com/pv.bin",
+			"img_x_len":10,"img_y_len":10,"img_x_size":160,"img_y_size":90,
+			"image":["//i0.hdslb.com/bfs/videoshot/1.jpg"],"index":[0,3,6]}}`))
+	}))
+	defer server.Close()
+
+	p := &BilibiliParser{
+		client:      &http.Client{Transport: &singleHostTransport{base: server.URL}},
+		authManager: auth.NewAuthManager(t.TempDir(), logrus.New()),
+		logger:      logrus.New(),
+	}
+	sb, err := p.GetStoryboard("BV1", 2)
+	if err != nil {
+		t.Fatalf("GetStoryboard: %v", err)
+	}
+	want := &Storyboard{
+		Images:  []string{"https://i0.hdslb.com/bfs/videoshot/1.jpg"},
+		Columns: 10, Rows: 10, Width: 160, Height: 90,
+		Times: []int{0, 3, 6},
+	}
+	if !reflect.DeepEqual(sb, want) {
+		t.Errorf("GetStoryboard = %+v, want %+v", sb, want)
+	}
+	if n := sb.Thumbnails(); n != 3 {
+		t.Errorf("Thumbnails = %d, want 3", n)
+	}
+}
@@ -0,0 +1,37 @@
+package gobili_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/dengmengmian/goBili/pkg/gobili"
+)
+
+func Example() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Use the login of 'goBili login', if there is one.
+	c, err := gobili.New(gobili.Options{ConfigDir: filepath.Join(home, ".goBili")})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := c.Download(context.Background(), "https://www.bilibili.com/video/BV1xx411c7mu", gobili.DownloadOptions{
+		OutputDir: "videos",
+		Quality:   "1080p",
+		Progress: func(p gobili.Progress) {
+			fmt.Printf("\r%.1f%%", p.Percentage)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		fmt.Println("\nsaved", r.Path)
+	}
+}
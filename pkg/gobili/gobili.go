@@ -0,0 +1,361 @@
+// Package gobili downloads Bilibili videos from Go programs. It is the
+// library behind the goBili command: a Client looks up videos, multi-part
+// videos and bangumi seasons, lists their streams and downloads them,
+// merging video and audio with ffmpeg when it is installed.
+//
+// The Client uses the login saved by 'goBili login' when Options.ConfigDir
+// points at it; otherwise it is anonymous and limited to 480p. It prints
+// nothing: logging goes to Options.Logger, and progress to
+// DownloadOptions.Progress.
+//
+//	c, err := gobili.New(gobili.Options{ConfigDir: filepath.Join(home, ".goBili")})
+//	if err != nil {
+//		return err
+//	}
+//	results, err := c.Download(ctx, "https://www.bilibili.com/video/BV1xx411c7mu",
+//		gobili.DownloadOptions{OutputDir: "videos", Quality: "1080p"})
+package gobili
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/dengmengmian/goBili/auth"
+	"github.com/dengmengmian/goBili/downloader"
+	"github.com/dengmengmian/goBili/logging"
+	"github.com/dengmengmian/goBili/parser"
+)
+
+// Types shared with the packages the Client is built on.
+type (
+	VideoInfo   = parser.VideoInfo
+	PageInfo    = parser.PageInfo
+	EpisodeInfo = parser.EpisodeInfo
+	StreamInfo  = parser.StreamInfo
+	Result      = downloader.Result
+	Progress    = downloader.DownloadProgress
+
+	// ExistingPolicy is what Download does when an output file exists.
+	ExistingPolicy = downloader.ExistingPolicy
+
+	// HTTPDoer sends HTTP requests; *http.Client implements it.
+	HTTPDoer = auth.HTTPDoer
+
+	// Extractor looks up the videos behind a kind of URL for Info.
+	Extractor = parser.Extractor
+
+	// Logger receives the log. A *logrus.Logger is one; FromSlog adapts
+	// a log/slog Logger.
+	Logger = logging.Logger
+)
+
+// FromSlog returns a Logger writing to l; nil uses slog.Default().
+func FromSlog(l *slog.Logger) Logger {
+	return logging.FromSlog(l)
+}
+
+// Errors returned by the Client; match them with errors.As.
+type (
+	// APIError is a Bilibili API response with a non-zero code.
+	APIError = auth.APIError
+	// AuthError is returned for requests that need a login.
+	AuthError = auth.AuthError
+	// StreamNotFoundError is returned when no stream matches the quality.
+	StreamNotFoundError = downloader.StreamNotFoundError
+	// MergeError is returned when ffmpeg fails to merge video and audio.
+	MergeError = downloader.MergeError
+)
+
+// Existing-file policies.
+const (
+	ExistingRename    = downloader.ExistingRename
+	ExistingSkip      = downloader.ExistingSkip
+	ExistingOverwrite = downloader.ExistingOverwrite
+)
+
+// ErrVideoUnavailable is returned for videos that were deleted, hidden or
+// are otherwise no longer viewable.
+var ErrVideoUnavailable = parser.ErrVideoUnavailable
+
+// Options configures a Client.
+type Options struct {
+	// ConfigDir is the directory holding the login of 'goBili login',
+	// usually ~/.goBili. Empty, or a directory without a login, gives an
+	// anonymous client.
+	ConfigDir string
+
+	// Logger receives the log of the client and its downloads; nil
+	// discards it.
+	Logger Logger
+
+	// RequestDelay is waited between API calls, to avoid rate limiting.
+	RequestDelay time.Duration
+
+	// HTTPClient sends the API requests, e.g. through a tracing or caching
+	// transport; nil uses an http.Client with a 30 second timeout. Media
+	// downloads use a client of their own.
+	HTTPClient HTTPDoer
+
+	// Extractors handle URLs the built-in ones do not, or take over some
+	// of theirs; they are tried in order, before the built-in ones.
+	Extractors []Extractor
+}
+
+// DownloadOptions configures a download. The zero value downloads every
+// part in the best quality as MP4 into the current directory.
+type DownloadOptions struct {
+	OutputDir string // default "."
+	Quality   string // best (default) or a label of the quality package, e.g. 1080p; the best available otherwise
+	Format    string // mp4 (default), flv or mkv
+	AudioOnly bool   // save the audio track as M4A
+	VideoOnly bool   // save the video without its audio
+
+	// Pages are the parts of a multi-part video, or the episodes of a
+	// season, to download, from 1; nil for all.
+	Pages []int
+	// IncludeExtras adds the extras of a season (PVs, OP/ED, 花絮) after
+	// its episodes, numbered on from them for Pages.
+	IncludeExtras bool
+
+	Threads    int            // connections per file; default 4
+	Existing   ExistingPolicy // default ExistingOverwrite
+	FFmpegPath string         // default ffmpeg from PATH
+
+	// Progress receives the progress of each file; a part may be
+	// downloaded as several files, each starting again from zero.
+	Progress func(Progress)
+
+	// Console receives the output of ffmpeg; nil discards it.
+	Console io.Writer
+}
+
+// Client downloads from Bilibili.
+type Client struct {
+	auth   *auth.AuthManager
+	parser *parser.BilibiliParser
+	logger Logger
+}
+
+// New returns a Client with the login in opts.ConfigDir, if any.
+func New(opts Options) (*Client, error) {
+	logger := logging.OrDiscard(opts.Logger)
+
+	am := auth.NewAnonymousAuthManagerWithClient(logger, opts.HTTPClient)
+	if opts.ConfigDir != "" {
+		loggedIn := auth.NewAuthManagerWithClient(opts.ConfigDir, logger, opts.HTTPClient)
+		if err := loggedIn.LoadCookies(); err != nil {
+			return nil, fmt.Errorf("failed to load the login: %w", err)
+		}
+		if loggedIn.IsAuthenticated() {
+			am = loggedIn
+		}
+	}
+
+	p := parser.NewBilibiliParserWithClient(am, logger, opts.HTTPClient)
+	p.SetRequestDelay(opts.RequestDelay, opts.RequestDelay)
+	for _, e := range opts.Extractors {
+		p.RegisterExtractor(e)
+	}
+	return &Client{auth: am, parser: p, logger: logger}, nil
+}
+
+// LoggedIn reports whether the client downloads with a login.
+func (c *Client) LoggedIn() bool {
+	return !c.auth.IsAnonymous()
+}
+
+// Info looks up the video, multi-part video or bangumi season at url.
+// Multi-part videos and seasons have Type "playlist" and list their parts
+// as Episodes.
+func (c *Client) Info(url string) (*VideoInfo, error) {
+	return c.parser.ParseURL(url)
+}
+
+// Streams lists the streams of a video as returned by Info, or of a part
+// as returned by EpisodeVideo, best first.
+func (c *Client) Streams(video *VideoInfo, page int) ([]*StreamInfo, error) {
+	return c.parser.GetVideoStreamsForPage(video, page)
+}
+
+// EpisodeVideo returns the video of one episode of a playlist, with the
+// playlist's metadata, and its page number for Streams. Bangumi episodes
+// are videos of their own; the parts of a multi-part video are pages of
+// the same video.
+func EpisodeVideo(playlist *VideoInfo, episode *EpisodeInfo) (*VideoInfo, int) {
+	pages, page := playlist.Pages, episode.Index
+	if len(pages) == 0 {
+		pages = []*PageInfo{{CID: episode.CID, Part: episode.Title, Duration: episode.Duration, Page: 1}}
+		page = 1
+	}
+	pubDate := playlist.PubDate
+	if episode.PubTime > 0 {
+		pubDate = episode.PubTime
+	}
+	number := episode.Number
+	if number == 0 {
+		number = episode.Index
+	}
+	return &VideoInfo{
+		BVID:  episode.BVID,
+		Title: episode.DisplayTitle(),
+		Desc:  playlist.Desc,
+		Type:  "video",
+		Pages: pages,
+
+		Uploader: playlist.Uploader,
+		OwnerMID: playlist.OwnerMID,
+		PubDate:  pubDate,
+		Category: playlist.Category,
+		Cover:    playlist.Cover,
+		Series:   playlist.Title,
+
+		Season:   episode.Season,
+		SeasonID: playlist.SeasonID,
+		Show:     playlist.Show,
+		Episode:  number,
+		Actors:   playlist.Actors,
+		Studio:   playlist.Studio,
+	}, page
+}
+
+// WithExtras returns a copy of the season video with its extras appended
+// to its episodes, or video itself when it has none.
+func WithExtras(video *VideoInfo) *VideoInfo {
+	if len(video.Extras) == 0 {
+		return video
+	}
+	withExtras := *video
+	withExtras.Episodes = append(append([]*EpisodeInfo(nil), video.Episodes...), video.Extras...)
+	return &withExtras
+}
+
+// Download downloads the video at url and returns the result of each part.
+// It stops at the first part that fails, returning the results so far.
+func (c *Client) Download(ctx context.Context, url string, opts DownloadOptions) ([]*Result, error) {
+	video, err := c.parser.ParseURLContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return c.DownloadVideo(ctx, video, opts)
+}
+
+// DownloadVideo is Download for a video already looked up with Info.
+func (c *Client) DownloadVideo(ctx context.Context, video *VideoInfo, opts DownloadOptions) ([]*Result, error) {
+	dl, err := c.newDownloader(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Progress != nil {
+		ctx = downloader.WithProgress(ctx, opts.Progress)
+	}
+
+	if opts.IncludeExtras {
+		video = WithExtras(video)
+	}
+	parts := selectParts(video, opts.Pages)
+	if len(parts) > 1 {
+		// Parts sharing their audio download it once.
+		cache := downloader.NewAudioCache()
+		defer cache.Close()
+		ctx = downloader.WithAudioCache(ctx, cache)
+	}
+
+	var results []*Result
+	for _, part := range parts {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result, err := c.downloadPart(ctx, dl, part.video, part.page)
+		if err != nil {
+			return results, fmt.Errorf("%s: %w", part.video.Title, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// part is one video to download and its page number.
+type part struct {
+	video *VideoInfo
+	page  int
+}
+
+// selectParts returns the parts of video chosen by pages (from 1; nil for
+// all). Numbers out of range are ignored.
+func selectParts(video *VideoInfo, pages []int) []part {
+	if video.Type != "playlist" {
+		return []part{{video: video, page: 1}}
+	}
+	episodes := video.Episodes
+	if pages != nil {
+		episodes = nil
+		for _, n := range pages {
+			if n > 0 && n <= len(video.Episodes) {
+				episodes = append(episodes, video.Episodes[n-1])
+			}
+		}
+	}
+	parts := make([]part, 0, len(episodes))
+	for _, episode := range episodes {
+		v, page := EpisodeVideo(video, episode)
+		parts = append(parts, part{video: v, page: page})
+	}
+	return parts
+}
+
+// downloadPart downloads page of video, fetching its stream URLs again
+// when they expire midway.
+func (c *Client) downloadPart(ctx context.Context, dl *downloader.Downloader, video *VideoInfo, page int) (*Result, error) {
+	streams, err := c.parser.GetVideoStreamsForPage(video, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video streams: %w", err)
+	}
+	ctx = downloader.WithStreamRefresher(ctx, func(context.Context) ([]*StreamInfo, error) {
+		return c.parser.RefreshVideoStreamsForPage(video, page)
+	})
+	return dl.DownloadVideoResult(ctx, video, streams)
+}
+
+// newDownloader checks opts and builds the downloader for them.
+func (c *Client) newDownloader(opts DownloadOptions) (*downloader.Downloader, error) {
+	if opts.AudioOnly && opts.VideoOnly {
+		return nil, errors.New("AudioOnly and VideoOnly cannot be combined")
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = "mp4"
+	case "mp4", "flv", "mkv":
+	default:
+		return nil, fmt.Errorf("unsupported format %q (want mp4, flv or mkv)", opts.Format)
+	}
+	if opts.OutputDir == "" {
+		opts.OutputDir = "."
+	}
+	if opts.Quality == "" {
+		opts.Quality = "best"
+	}
+	if opts.Threads <= 0 {
+		opts.Threads = 4
+	}
+	if opts.Console == nil {
+		opts.Console = io.Discard
+	}
+
+	return downloader.NewDownloader(downloader.Config{
+		OutputDir:   opts.OutputDir,
+		Threads:     opts.Threads,
+		Quality:     opts.Quality,
+		Format:      opts.Format,
+		AudioOnly:   opts.AudioOnly,
+		VideoOnly:   opts.VideoOnly,
+		Existing:    opts.Existing,
+		FFmpegPath:  opts.FFmpegPath,
+		AuthManager: c.auth,
+		Logger:      c.logger,
+		Console:     opts.Console,
+	}), nil
+}
@@ -0,0 +1,166 @@
+package gobili
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNew_Login(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(Options{ConfigDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LoggedIn() {
+		t.Error("LoggedIn() = true without saved cookies")
+	}
+
+	cookies := `{"SESSDATA": "s", "bili_jct": "j", "DedeUserID": "1"}`
+	if err := os.WriteFile(filepath.Join(dir, "cookies.json"), []byte(cookies), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = New(Options{ConfigDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.LoggedIn() {
+		t.Error("LoggedIn() = false with saved cookies")
+	}
+}
+
+// doerFunc is an HTTPDoer answering from a function.
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestInfo_HTTPClient(t *testing.T) {
+	client := doerFunc(func(req *http.Request) (*http.Response, error) {
+		rec := httptest.NewRecorder()
+		switch req.URL.Path {
+		case "/x/web-interface/nav":
+			rec.WriteString(`{"code":0,"data":{"wbi_img":{
+				"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png",
+				"sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`)
+		case "/x/web-interface/wbi/view":
+			rec.WriteString(`{"code":0,"data":{"bvid":"BV1xx411c7mu","title":"Mocked",
+				"pages":[{"cid":1,"page":1},{"cid":2,"page":2}]}}`)
+		default:
+			rec.WriteHeader(http.StatusNotFound)
+		}
+		return rec.Result(), nil
+	})
+
+	c, err := New(Options{HTTPClient: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	video, err := c.Info("https://www.bilibili.com/video/BV1xx411c7mu")
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if video.Title != "Mocked" || video.Type != "playlist" || len(video.Episodes) != 2 {
+		t.Errorf("video = %+v", video)
+	}
+}
+
+func TestEpisodeVideo(t *testing.T) {
+	multiPart := &VideoInfo{
+		BVID: "BV1mp", Title: "Course", Type: "playlist", Uploader: "Up",
+		Pages:    []*PageInfo{{CID: 1, Page: 1}, {CID: 2, Page: 2}},
+		Episodes: []*EpisodeInfo{{BVID: "BV1mp", CID: 1, Title: "Intro", Index: 1}, {BVID: "BV1mp", CID: 2, Title: "Outro", Index: 2}},
+	}
+	video, page := EpisodeVideo(multiPart, multiPart.Episodes[1])
+	if page != 2 || len(video.Pages) != 2 || video.Title != "Outro" || video.Series != "Course" || video.Uploader != "Up" {
+		t.Errorf("part = %+v, page %d", video, page)
+	}
+
+	if video.Episode != 2 {
+		t.Errorf("part number = %d, want 2", video.Episode)
+	}
+
+	season := &VideoInfo{Title: "Show", Type: "playlist", PubDate: 100, Show: "Show", SeasonID: 7, Studio: "Studio",
+		Episodes: []*EpisodeInfo{{BVID: "BV1ep", CID: 9, Title: "1", Duration: 1440, Season: 2, Number: 5, PubTime: 200}}}
+	video, page = EpisodeVideo(season, season.Episodes[0])
+	if page != 1 || video.BVID != "BV1ep" || len(video.Pages) != 1 || video.Pages[0].CID != 9 || video.Type != "video" {
+		t.Errorf("episode = %+v, page %d", video, page)
+	}
+	if video.Season != 2 || video.Episode != 5 || video.PubDate != 200 || video.Show != "Show" || video.Studio != "Studio" {
+		t.Errorf("episode metadata = %+v", video)
+	}
+}
+
+func TestWithExtras(t *testing.T) {
+	season := &VideoInfo{Title: "Show", Type: "playlist",
+		Episodes: []*EpisodeInfo{{BVID: "BV1ep", CID: 9, Title: "1", Index: 1}},
+		Extras:   []*EpisodeInfo{{BVID: "BV1pv", CID: 10, Title: "PV1", Section: "PV&其他"}}}
+	if WithExtras(&VideoInfo{}) == nil {
+		t.Fatal("WithExtras returned nil")
+	}
+
+	withExtras := WithExtras(season)
+	if len(withExtras.Episodes) != 2 || len(season.Episodes) != 1 {
+		t.Fatalf("episodes = %d (season %d), want 2 (1)", len(withExtras.Episodes), len(season.Episodes))
+	}
+	video, _ := EpisodeVideo(withExtras, withExtras.Episodes[1])
+	if video.Title != "[PV&其他] PV1" {
+		t.Errorf("extra title = %q", video.Title)
+	}
+}
+
+func TestSelectParts(t *testing.T) {
+	single := &VideoInfo{BVID: "BV1s", Type: "video", Pages: []*PageInfo{{CID: 1, Page: 1}}}
+	if parts := selectParts(single, []int{3}); len(parts) != 1 || parts[0].video != single || parts[0].page != 1 {
+		t.Errorf("single video parts = %+v", parts)
+	}
+
+	playlist := &VideoInfo{Type: "playlist", Episodes: []*EpisodeInfo{
+		{BVID: "BV1a", CID: 1, Index: 1}, {BVID: "BV1b", CID: 2, Index: 2}, {BVID: "BV1c", CID: 3, Index: 3},
+	}}
+	if parts := selectParts(playlist, nil); len(parts) != 3 {
+		t.Errorf("all parts = %d, want 3", len(parts))
+	}
+	parts := selectParts(playlist, []int{3, 1, 7})
+	if len(parts) != 2 || parts[0].video.BVID != "BV1c" || parts[1].video.BVID != "BV1a" {
+		t.Errorf("parts 3,1,7 = %+v", parts)
+	}
+}
+
+func TestDownloadVideo_InvalidOptions(t *testing.T) {
+	c, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	video := &VideoInfo{Type: "video"}
+	for _, opts := range []DownloadOptions{
+		{AudioOnly: true, VideoOnly: true},
+		{Format: "avi"},
+	} {
+		if _, err := c.DownloadVideo(context.Background(), video, opts); err == nil {
+			t.Errorf("DownloadVideo(%+v) succeeded", opts)
+		}
+	}
+}
+
+// stubExtractor answers every URL on example.com with one video.
+type stubExtractor struct{}
+
+func (stubExtractor) Match(u *url.URL) bool { return u.Host == "example.com" }
+
+func (stubExtractor) Extract(ctx context.Context, u *url.URL) (*VideoInfo, error) {
+	return &VideoInfo{BVID: "BV1st", Title: "Stub", Type: "video"}, nil
+}
+
+func TestNew_Extractors(t *testing.T) {
+	c, err := New(Options{Extractors: []Extractor{stubExtractor{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	video, err := c.Info("https://example.com/watch/1")
+	if err != nil || video.Title != "Stub" {
+		t.Errorf("Info = %+v, %v", video, err)
+	}
+}
@@ -0,0 +1,211 @@
+// Package quality is the registry of Bilibili's video qualities: the qn
+// ids of the API, the labels --quality takes and file names carry, their
+// resolutions, and the account each needs. Stream selection, file names
+// and the formats listings all read it.
+package quality
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quality is one video quality of Bilibili.
+type Quality struct {
+	QN     int    // id in the API, e.g. 80
+	Label  string // e.g. "1080p"; a --quality value and file name suffix
+	Name   string // name in the web player, e.g. "1080P 高清"
+	Width  int    // of a 16:9 picture; portrait videos are narrower
+	Height int
+
+	Login bool // guests do not get it
+	VIP   bool // only 大会员 members get it
+}
+
+// Resolution returns q's resolution, e.g. "1920x1080".
+func (q Quality) Resolution() string {
+	return fmt.Sprintf("%dx%d", q.Width, q.Height)
+}
+
+// Requires returns what account q needs: "vip", "login", or "" for none.
+func (q Quality) Requires() string {
+	switch {
+	case q.VIP:
+		return "vip"
+	case q.Login:
+		return "login"
+	}
+	return ""
+}
+
+// qualities are the known qualities, best first.
+var qualities = []Quality{
+	{QN: 127, Label: "8K", Name: "8K 超高清", Width: 7680, Height: 4320, Login: true, VIP: true},
+	{QN: 126, Label: "DolbyVision", Name: "杜比视界", Width: 3840, Height: 2160, Login: true, VIP: true},
+	{QN: 125, Label: "HDR", Name: "HDR 真彩", Width: 3840, Height: 2160, Login: true, VIP: true},
+	{QN: 120, Label: "4K", Name: "4K 超清", Width: 3840, Height: 2160, Login: true, VIP: true},
+	{QN: 116, Label: "1080p60", Name: "1080P 60帧", Width: 1920, Height: 1080, Login: true, VIP: true},
+	{QN: 112, Label: "1080p+", Name: "1080P 高码率", Width: 1920, Height: 1080, Login: true, VIP: true},
+	{QN: 80, Label: "1080p", Name: "1080P 高清", Width: 1920, Height: 1080, Login: true},
+	{QN: 74, Label: "720p60", Name: "720P 60帧", Width: 1280, Height: 720, Login: true},
+	{QN: 64, Label: "720p", Name: "720P 高清", Width: 1280, Height: 720, Login: true},
+	{QN: 32, Label: "480p", Name: "480P 清晰", Width: 852, Height: 480},
+	{QN: 16, Label: "360p", Name: "360P 流畅", Width: 640, Height: 360},
+	{QN: 6, Label: "240p", Name: "240P 极速", Width: 426, Height: 240},
+}
+
+// Best is the --quality value asking for the best quality available.
+const Best = "best"
+
+// GuestMax is the best quality guests get.
+const GuestMax = 32
+
+// All returns the known qualities, best first.
+func All() []Quality {
+	return append([]Quality(nil), qualities...)
+}
+
+// Labels returns the labels of the known qualities, best first.
+func Labels() []string {
+	labels := make([]string, len(qualities))
+	for i, q := range qualities {
+		labels[i] = q.Label
+	}
+	return labels
+}
+
+// ByQN returns the quality of a qn id.
+func ByQN(qn int) (Quality, bool) {
+	for _, q := range qualities {
+		if q.QN == qn {
+			return q, true
+		}
+	}
+	return Quality{}, false
+}
+
+// Parse returns the quality of a label, e.g. "1080p" or "4k"; case does
+// not matter.
+func Parse(label string) (Quality, bool) {
+	for _, q := range qualities {
+		if strings.EqualFold(q.Label, label) {
+			return q, true
+		}
+	}
+	return Quality{}, false
+}
+
+// Valid reports whether label is a --quality value: "best" or the label
+// of a known quality.
+func Valid(label string) bool {
+	_, ok := Parse(label)
+	return ok || label == Best
+}
+
+// Name returns the label of a qn id, e.g. "1080p" for 80, or "qn<id>" for
+// unknown ids.
+func Name(qn int) string {
+	if q, ok := ByQN(qn); ok {
+		return q.Label
+	}
+	return fmt.Sprintf("qn%d", qn)
+}
+
+// Suffix returns the file name suffix of a qn id, e.g. "_1080p", or ""
+// for unknown ids.
+func Suffix(qn int) string {
+	if q, ok := ByQN(qn); ok {
+		return "_" + q.Label
+	}
+	return ""
+}
+
+// Resolution returns the resolution of a qn id, e.g. "1920x1080", or
+// "unknown".
+func Resolution(qn int) string {
+	if q, ok := ByQN(qn); ok {
+		return q.Resolution()
+	}
+	return "unknown"
+}
+
+// What to download when a video does not offer the quality asked for,
+// the --quality-fallback values.
+const (
+	FallbackBest      = "best"       // the best quality available
+	FallbackNextLower = "next-lower" // the best below it, or else the closest above
+	FallbackAbort     = "abort"      // nothing: the download fails
+)
+
+// Fallbacks returns the --quality-fallback values.
+func Fallbacks() []string {
+	return []string{FallbackBest, FallbackNextLower, FallbackAbort}
+}
+
+// ValidFallback reports whether s is a --quality-fallback value.
+func ValidFallback(s string) bool {
+	return s == FallbackBest || s == FallbackNextLower || s == FallbackAbort
+}
+
+// Pick returns which of the available qn ids to download for the
+// --quality value label: the one of label when it is available, and
+// otherwise, as for "best" and unknown labels, the best one. It returns
+// false when available is empty.
+func Pick(available []int, label string) (int, bool) {
+	return PickFallback(available, label, FallbackBest)
+}
+
+// PickFallback is Pick with fallback choosing what replaces a quality
+// that is not available; "" means FallbackBest. It returns false when
+// available is empty, or with FallbackAbort when label is missing.
+// "best" and unknown labels always get the best quality.
+func PickFallback(available []int, label, fallback string) (int, bool) {
+	if len(available) == 0 {
+		return 0, false
+	}
+	best := available[0]
+	for _, qn := range available[1:] {
+		if qn > best {
+			best = qn
+		}
+	}
+	q, ok := Parse(label)
+	if !ok {
+		return best, true
+	}
+	for _, qn := range available {
+		if qn == q.QN {
+			return qn, true
+		}
+	}
+
+	switch fallback {
+	case FallbackAbort:
+		return 0, false
+	case FallbackNextLower:
+		// Qualities rank by qn id, best first in the table.
+		lower, higher := 0, 0
+		for _, qn := range available {
+			if qn < q.QN && qn > lower {
+				lower = qn
+			}
+			if qn > q.QN && (higher == 0 || qn < higher) {
+				higher = qn
+			}
+		}
+		if lower != 0 {
+			return lower, true
+		}
+		return higher, true
+	}
+	return best, true
+}
+
+// ForGuests caps a --quality value at what guests get: qualities needing
+// a login, and "best", become "480p".
+func ForGuests(label string) string {
+	q, ok := Parse(label)
+	if label == Best || ok && q.Login {
+		return Name(GuestMax)
+	}
+	return label
+}
@@ -0,0 +1,124 @@
+package quality
+
+import "testing"
+
+func TestTable(t *testing.T) {
+	seen := map[string]bool{}
+	for i, q := range qualities {
+		if i > 0 && q.QN >= qualities[i-1].QN {
+			t.Errorf("%s (%d) is not below %s (%d)", q.Label, q.QN, qualities[i-1].Label, qualities[i-1].QN)
+		}
+		if seen[q.Label] {
+			t.Errorf("label %s is used twice", q.Label)
+		}
+		seen[q.Label] = true
+		if q.VIP && !q.Login {
+			t.Errorf("%s needs VIP but not a login", q.Label)
+		}
+	}
+	if q, _ := ByQN(GuestMax); q.Login {
+		t.Errorf("GuestMax %d needs a login", GuestMax)
+	}
+}
+
+func TestLookups(t *testing.T) {
+	if got := Name(80); got != "1080p" {
+		t.Errorf("Name(80) = %q", got)
+	}
+	if got := Name(999); got != "qn999" {
+		t.Errorf("Name(999) = %q", got)
+	}
+	if got := Suffix(116); got != "_1080p60" {
+		t.Errorf("Suffix(116) = %q", got)
+	}
+	if got := Suffix(999); got != "" {
+		t.Errorf("Suffix(999) = %q", got)
+	}
+	if got := Resolution(64); got != "1280x720" {
+		t.Errorf("Resolution(64) = %q", got)
+	}
+	if got := Resolution(999); got != "unknown" {
+		t.Errorf("Resolution(999) = %q", got)
+	}
+	if q, ok := Parse("4k"); !ok || q.QN != 120 || q.Requires() != "vip" {
+		t.Errorf("Parse(4k) = %+v, %v", q, ok)
+	}
+	if q, _ := ByQN(64); q.Requires() != "login" {
+		t.Errorf("720p requires %q", q.Requires())
+	}
+	if q, _ := ByQN(16); q.Requires() != "" {
+		t.Errorf("360p requires %q", q.Requires())
+	}
+	for _, label := range []string{"best", "1080p", "1080P", "8K", "240p"} {
+		if !Valid(label) {
+			t.Errorf("Valid(%q) = false", label)
+		}
+	}
+	for _, label := range []string{"", "BEST", "2160p", "qn80"} {
+		if Valid(label) {
+			t.Errorf("Valid(%q) = true", label)
+		}
+	}
+}
+
+func TestPick(t *testing.T) {
+	available := []int{64, 116, 80, 32}
+	tests := []struct {
+		label string
+		want  int
+	}{
+		{"best", 116},
+		{"1080p", 80},
+		{"480p", 32},
+		{"4K", 116},    // not available
+		{"bogus", 116}, // unknown
+	}
+	for _, tt := range tests {
+		if got, ok := Pick(available, tt.label); !ok || got != tt.want {
+			t.Errorf("Pick(%v, %q) = %d, %v; want %d", available, tt.label, got, ok, tt.want)
+		}
+	}
+	if _, ok := Pick(nil, "best"); ok {
+		t.Error("Pick(nil) found a quality")
+	}
+}
+
+func TestPickFallback(t *testing.T) {
+	available := []int{64, 116, 32}
+	tests := []struct {
+		label, fallback string
+		want            int
+		ok              bool
+	}{
+		{"1080p", FallbackBest, 116, true},
+		{"1080p", "", 116, true},
+		{"1080p", FallbackNextLower, 64, true},
+		{"360p", FallbackNextLower, 32, true}, // nothing lower: the closest above
+		{"1080p", FallbackAbort, 0, false},
+		{"720p", FallbackAbort, 64, true},
+		{"best", FallbackAbort, 116, true},
+	}
+	for _, tt := range tests {
+		if got, ok := PickFallback(available, tt.label, tt.fallback); ok != tt.ok || got != tt.want {
+			t.Errorf("PickFallback(%q, %q) = %d, %v; want %d, %v", tt.label, tt.fallback, got, ok, tt.want, tt.ok)
+		}
+	}
+	if !ValidFallback(FallbackNextLower) || ValidFallback("lower") {
+		t.Error("ValidFallback accepts the wrong values")
+	}
+}
+
+func TestForGuests(t *testing.T) {
+	for label, want := range map[string]string{
+		"best":  "480p",
+		"4K":    "480p",
+		"720p":  "480p",
+		"480p":  "480p",
+		"360p":  "360p",
+		"bogus": "bogus",
+	} {
+		if got := ForGuests(label); got != want {
+			t.Errorf("ForGuests(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
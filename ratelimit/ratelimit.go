@@ -0,0 +1,131 @@
+// Package ratelimit holds the request budgets of a goBili process: token
+// buckets for the calls to api.bilibili.com and for the media requests to
+// the CDNs, shared by the parser and every downloader so that concurrent
+// episodes and queue jobs together stay within them.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket: it holds up to burst tokens, refilled at rate
+// per second, and each request takes one, waiting for it when the bucket
+// is empty. A nil Bucket never waits.
+type Bucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64 // negative while requests wait for tokens
+	last   time.Time
+}
+
+// NewBucket returns a bucket allowing rate requests per second, with
+// bursts of up to the rate rounded up. It returns nil (no limit) when rate
+// <= 0.
+func NewBucket(rate float64) *Bucket {
+	if rate <= 0 {
+		return nil
+	}
+	burst := math.Max(1, math.Ceil(rate))
+	return &Bucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Rate returns the requests per second of b, 0 for a nil bucket.
+func (b *Bucket) Rate() float64 {
+	if b == nil {
+		return 0
+	}
+	return b.rate
+}
+
+// Wait takes a token, waiting until there is one or ctx is done.
+func (b *Bucket) Wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens-- // reserved, so that waiting requests queue up in order
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens++ // give the reservation back
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Transport wraps base so that each request waits for a token of b. A nil
+// bucket returns base unchanged.
+func (b *Bucket) Transport(base http.RoundTripper) http.RoundTripper {
+	if b == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &limitedTransport{base: base, bucket: b}
+}
+
+type limitedTransport struct {
+	base   http.RoundTripper
+	bucket *Bucket
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.bucket.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// Limiter is the request budget of a process: API for the parser's calls
+// to the Bilibili API, CDN for the downloaders' media requests. A nil
+// Limiter, or a nil bucket, does not limit.
+type Limiter struct {
+	API *Bucket
+	CDN *Bucket
+}
+
+// New returns a Limiter allowing apiRate API calls and cdnRate media
+// requests per second; a rate <= 0 leaves those requests unlimited. It
+// returns nil when neither is limited.
+func New(apiRate, cdnRate float64) *Limiter {
+	if apiRate <= 0 && cdnRate <= 0 {
+		return nil
+	}
+	return &Limiter{API: NewBucket(apiRate), CDN: NewBucket(cdnRate)}
+}
+
+// APIBucket returns the bucket of API calls; nil when l is nil.
+func (l *Limiter) APIBucket() *Bucket {
+	if l == nil {
+		return nil
+	}
+	return l.API
+}
+
+// CDNBucket returns the bucket of media requests; nil when l is nil.
+func (l *Limiter) CDNBucket() *Bucket {
+	if l == nil {
+		return nil
+	}
+	return l.CDN
+}
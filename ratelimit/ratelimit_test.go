@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewBucket(t *testing.T) {
+	if NewBucket(0) != nil || NewBucket(-1) != nil {
+		t.Error("NewBucket(<= 0) is not nil")
+	}
+	var nilBucket *Bucket
+	if err := nilBucket.Wait(context.Background()); err != nil || nilBucket.Rate() != 0 {
+		t.Errorf("nil bucket: Wait = %v, Rate = %v", err, nilBucket.Rate())
+	}
+	if b := NewBucket(0.5); b.burst != 1 {
+		t.Errorf("burst of 0.5/s = %v, want 1", b.burst)
+	}
+	if b := NewBucket(2.5); b.burst != 3 {
+		t.Errorf("burst of 2.5/s = %v, want 3", b.burst)
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	b := NewBucket(20) // a token every 50ms, bursts of 20
+	ctx := context.Background()
+	start := time.Now()
+	for i := 0; i < 22; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The burst goes at once; the two requests after it wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("22 requests at 20/s took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestBucketWait_Canceled(t *testing.T) {
+	b := NewBucket(1)
+	b.Wait(context.Background()) // empty the bucket
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait = %v, want the context's error", err)
+	}
+	// The canceled request gave its reservation back.
+	if b.tokens < -0.1 {
+		t.Errorf("tokens = %v after a canceled wait", b.tokens)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	if New(0, 0) != nil {
+		t.Error("New(0, 0) is not nil")
+	}
+	var nilLimiter *Limiter
+	if nilLimiter.APIBucket() != nil || nilLimiter.CDNBucket() != nil {
+		t.Error("nil limiter has buckets")
+	}
+	l := New(2, 0)
+	if l.APIBucket().Rate() != 2 || l.CDNBucket() != nil {
+		t.Errorf("New(2, 0) = %+v", l)
+	}
+}
+
+func TestBucketTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var nilBucket *Bucket
+	if nilBucket.Transport(http.DefaultTransport) != http.DefaultTransport {
+		t.Error("nil bucket wrapped the transport")
+	}
+
+	client := &http.Client{Transport: NewBucket(25).Transport(nil)}
+	start := time.Now()
+	for i := 0; i < 27; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("27 requests at 25/s took %v, want at least 80ms", elapsed)
+	}
+}
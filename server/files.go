@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileInfo is an entry of the download directory.
+type FileInfo struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"` // relative to the download directory, with slashes
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// handleFiles lists a directory below the download directory, given as
+// ?path=, directories first and then the newest files first. Hidden
+// entries, such as the temporary directory, are left out.
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if s.opts.FilesDir == "" {
+		writeError(w, http.StatusNotImplemented, errors.New("file browsing is not available"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	rel := strings.TrimPrefix(path.Clean("/"+r.URL.Query().Get("path")), "/")
+	if hasHiddenSegment(rel) {
+		writeError(w, http.StatusNotFound, errors.New("directory not found"))
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(s.opts.FilesDir, filepath.FromSlash(rel)))
+	if errors.Is(err, os.ErrNotExist) {
+		writeError(w, http.StatusNotFound, errors.New("directory not found"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to list directory: %w", err))
+		return
+	}
+
+	files := []FileInfo{}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // removed meanwhile
+		}
+		f := FileInfo{Name: e.Name(), Path: path.Join(rel, e.Name()), Dir: e.IsDir(), ModTime: info.ModTime()}
+		if !f.Dir {
+			f.Size = info.Size()
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Dir != files[j].Dir {
+			return files[i].Dir
+		}
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"path": rel, "files": files})
+}
+
+// fileServer serves the files below the download directory at /files/.
+func (s *Server) fileServer() http.Handler {
+	if s.opts.FilesDir == "" {
+		return http.NotFoundHandler()
+	}
+	files := http.StripPrefix("/files/", http.FileServer(http.Dir(s.opts.FilesDir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasHiddenSegment(strings.TrimPrefix(r.URL.Path, "/files/")) {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// hasHiddenSegment reports whether a slash-separated path goes through a
+// hidden file or directory.
+func hasHiddenSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+	return false
+}
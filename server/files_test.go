@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dengmengmian/goBili/store"
+)
+
+// newFilesServer returns an HTTP test server whose download directory is a
+// fresh temporary directory, which it also returns.
+func newFilesServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	st, err := store.OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("OpenJSON: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	dir := t.TempDir()
+	s := New(Options{Store: st, Worker: "test", Token: testToken, FilesDir: dir})
+	api := httptest.NewServer(s.Handler())
+	t.Cleanup(api.Close)
+	return api, dir
+}
+
+func TestWebUI_NeedsNoToken(t *testing.T) {
+	_, api := newTestServer(t, nil)
+
+	resp, err := http.Get(api.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET / = %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestFiles(t *testing.T) {
+	api, dir := newFilesServer(t)
+	os.MkdirAll(filepath.Join(dir, "uploader", "series"), 0755)
+	os.WriteFile(filepath.Join(dir, "uploader", "a.mp4"), []byte("video"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".goBili-tmp"), 0755)
+	os.WriteFile(filepath.Join(dir, ".goBili-tmp", "part.m4s"), []byte("partial"), 0644)
+
+	var root struct {
+		Files []FileInfo `json:"files"`
+	}
+	call(t, api, "GET", "/api/files", nil, &root)
+	if len(root.Files) != 1 || root.Files[0].Path != "uploader" || !root.Files[0].Dir {
+		t.Errorf("root listing = %+v, want only the uploader directory", root.Files)
+	}
+
+	var sub struct {
+		Files []FileInfo `json:"files"`
+	}
+	call(t, api, "GET", "/api/files?path=uploader", nil, &sub)
+	if len(sub.Files) != 2 || !sub.Files[0].Dir || sub.Files[1].Path != "uploader/a.mp4" || sub.Files[1].Size != 5 {
+		t.Errorf("uploader listing = %+v", sub.Files)
+	}
+
+	if status := call(t, api, "GET", "/api/files?path=../..", nil, &root); status != http.StatusOK || len(root.Files) != 1 {
+		t.Errorf("path=../.. = %d with %d entries, want the root listing", status, len(root.Files))
+	}
+	if status := call(t, api, "GET", "/api/files?path=.goBili-tmp", nil, nil); status != http.StatusNotFound {
+		t.Errorf("hidden directory: status %d, want 404", status)
+	}
+}
+
+func TestFiles_Download(t *testing.T) {
+	api, dir := newFilesServer(t)
+	os.WriteFile(filepath.Join(dir, "a.mp4"), []byte("video"), 0644)
+	os.MkdirAll(filepath.Join(dir, ".goBili-tmp"), 0755)
+	os.WriteFile(filepath.Join(dir, ".goBili-tmp", "part.m4s"), []byte("partial"), 0644)
+
+	get := func(path string, cookie string) int {
+		req, _ := http.NewRequest("GET", api.URL+path, nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: tokenCookie, Value: cookie})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get("/files/a.mp4", ""); status != http.StatusUnauthorized {
+		t.Errorf("without token: status %d, want 401", status)
+	}
+	if status := get("/files/a.mp4", "wrong"); status != http.StatusUnauthorized {
+		t.Errorf("wrong cookie: status %d, want 401", status)
+	}
+	if status := get("/files/a.mp4", testToken); status != http.StatusOK {
+		t.Errorf("with cookie: status %d, want 200", status)
+	}
+	if status := get("/files/.goBili-tmp/part.m4s", testToken); status != http.StatusNotFound {
+		t.Errorf("hidden file: status %d, want 404", status)
+	}
+
+	// The cookie alone never authorizes API calls.
+	if status := get("/api/jobs", testToken); status != http.StatusUnauthorized {
+		t.Errorf("API with cookie only: status %d, want 401", status)
+	}
+}
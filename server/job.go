@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dengmengmian/goBili/store"
+)
+
+var (
+	errInvalidRequest = errors.New("invalid request")
+	errJobFinished    = errors.New("job has already finished")
+	errJobActive      = errors.New("job is still queued or running; cancel it first")
+	errJobNotPaused   = errors.New("job is not paused")
+)
+
+// Request is what a client submits: a URL and the settings that differ
+// from the server's defaults.
+type Request struct {
+	URL string `json:"url"`
+	// Quality overrides the download quality (best, 1080p, 720p, ...).
+	Quality string `json:"quality,omitempty"`
+	// Pages selects the parts or episodes, like --pages.
+	Pages string `json:"pages,omitempty"`
+}
+
+// options returns the job options stored for req.
+func (r Request) options() map[string]string {
+	opts := map[string]string{}
+	if r.Quality != "" {
+		opts["quality"] = r.Quality
+	}
+	if r.Pages != "" {
+		opts["pages"] = r.Pages
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return opts
+}
+
+// requestOf rebuilds the request a stored job was submitted with.
+func requestOf(j *store.Job) Request {
+	return Request{URL: j.URL, Quality: j.Options["quality"], Pages: j.Options["pages"]}
+}
+
+// Progress is how far a running job has got: which of its videos it is
+// downloading and how much of the current file has arrived.
+type Progress struct {
+	Video      int     `json:"video"` // 1-based
+	Videos     int     `json:"videos"`
+	Title      string  `json:"title,omitempty"`
+	Downloaded int64   `json:"downloaded"`
+	Total      int64   `json:"total"` // 0 when unknown
+	Percent    float64 `json:"percent"`
+	Speed      int64   `json:"speed"` // bytes per second
+	ETA        int64   `json:"eta"`   // seconds, 0 when unknown
+}
+
+// Job is a queued download as reported by the API: the stored job, the
+// progress while this server runs it, and the files it saved.
+type Job struct {
+	*store.Job
+	Progress *Progress `json:"progress,omitempty"`
+	Files    []string  `json:"files,omitempty"`
+}
+
+// QueueStatus is the estimate for the unfinished jobs.
+type QueueStatus struct {
+	Remaining int        `json:"remaining"`
+	ETA       int64      `json:"eta,omitempty"` // seconds
+	FinishAt  *time.Time `json:"finish_at,omitempty"`
+	Summary   string     `json:"summary"` // e.g. "3 jobs, finishing around 06:40"
+}
+
+// isFinished reports whether a job has stopped for good.
+func isFinished(status store.JobStatus) bool {
+	return status.Finished()
+}
+
+// statusFor maps errors to HTTP status codes.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, errJobFinished), errors.Is(err, errJobActive), errors.Is(err, errJobNotPaused):
+		return http.StatusConflict
+	case errors.Is(err, errInvalidRequest):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes v as the response body.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as {"error": "..."}.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// methodNotAllowed answers 405 listing the allowed methods.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
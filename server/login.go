@@ -0,0 +1,95 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Login logs the server's account in with a QR code scanned in the
+// Bilibili app, the same way 'goBili login' does on a terminal.
+type Login interface {
+	// Account returns the logged-in account, or nil when logged out.
+	Account() (*Account, error)
+	// StartQRCode creates a QR code to scan.
+	StartQRCode() (*QRCode, error)
+	// PollQRCode returns the state of the QR code with the given key and
+	// completes the login once it is confirmed.
+	PollQRCode(key string) (QRState, error)
+}
+
+// Account is the logged-in Bilibili account.
+type Account struct {
+	Name string `json:"name"`
+	MID  int64  `json:"mid"`
+	VIP  bool   `json:"vip"`
+}
+
+// QRCode is a login QR code. Image is a PNG of it, base64-encoded in JSON.
+type QRCode struct {
+	Key   string `json:"key"`
+	URL   string `json:"url"`
+	Image []byte `json:"image,omitempty"`
+}
+
+// QRState is how far a QR code login has got.
+type QRState string
+
+const (
+	QRWaiting QRState = "waiting" // not scanned yet
+	QRScanned QRState = "scanned" // scanned, waiting for confirmation in the app
+	QRExpired QRState = "expired" // a new code is needed
+	QRDone    QRState = "done"    // logged in
+)
+
+// handleLogin reports the account (GET) or starts a QR code login (POST).
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Login == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("login is not available"))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		account, err := s.opts.Login.Account()
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"logged_in": account != nil, "account": account})
+	case http.MethodPost:
+		qr, err := s.opts.Login.StartQRCode()
+		if err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, qr)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleLoginPoll reports the state of the QR code /api/login/{key}.
+func (s *Server) handleLoginPoll(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Login == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("login is not available"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/api/login/")
+	if key == "" || strings.Contains(key, "/") {
+		writeError(w, http.StatusNotFound, errors.New("unknown QR code"))
+		return
+	}
+	state, err := s.opts.Login.PollQRCode(key)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if state == QRDone {
+		s.opts.Logger.Info("Logged in through the API")
+	}
+	writeJSON(w, http.StatusOK, map[string]QRState{"state": state})
+}
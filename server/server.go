@@ -0,0 +1,586 @@
+// Package server runs goBili as a daemon: an HTTP API to queue downloads,
+// follow their progress, cancel them and log in, so a goBili on a NAS or
+// home server can be driven from other machines.
+//
+// Jobs are kept in the state store's job queue, so queued jobs survive a
+// restart and several servers sharing a SQL store split the work between
+// them.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dengmengmian/goBili/logging"
+	"github.com/dengmengmian/goBili/store"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// shutdownTimeout bounds how long Serve waits for open requests when
+	// it stops.
+	shutdownTimeout = 10 * time.Second
+	// pollInterval is how often an idle worker looks for jobs submitted
+	// to the store by other servers.
+	pollInterval = 5 * time.Second
+)
+
+// RunFunc downloads what req describes. It reports its progress through
+// update, which may be called from any goroutine, and returns the files it
+// saved. It must return soon after ctx is cancelled.
+type RunFunc func(ctx context.Context, req Request, update func(Progress)) ([]string, error)
+
+// Options configure a Server.
+type Options struct {
+	// Store holds the job queue.
+	Store store.Store
+	// Worker identifies this server in job leases (default
+	// store.WorkerID()).
+	Worker string
+	// Token is the bearer token every request must carry in its
+	// Authorization header. An empty token disables the check.
+	Token string
+	// Run downloads one job.
+	Run RunFunc
+	// Login backs the /api/login endpoints; without it they answer 501.
+	Login Login
+	// FilesDir is the download directory browsed through /api/files and
+	// /files/; when empty, they are not available.
+	FilesDir string
+	// Workers is how many jobs run at once (default 1).
+	Workers int
+	// Logger receives the job events.
+	Logger logging.Logger
+}
+
+// Server is the HTTP API in front of the job queue, and the workers that
+// run the queued jobs, oldest first.
+type Server struct {
+	opts Options
+	wake chan struct{} // signals a worker that a job was submitted
+
+	mu   sync.Mutex
+	live map[string]*liveJob // jobs run by this server since it started
+}
+
+// liveJob is what the store does not keep about a job: its progress, the
+// files it saved, and how to cancel it while it runs.
+type liveJob struct {
+	progress Progress
+	files    []string
+	cancel   context.CancelFunc
+	paused   bool // cancel was called to pause the job, not to cancel it
+}
+
+// New returns a server for the queue in opts.Store.
+func New(opts Options) *Server {
+	if opts.Worker == "" {
+		opts.Worker = store.WorkerID()
+	}
+	if opts.Logger == nil {
+		opts.Logger = logrus.New()
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	return &Server{
+		opts: opts,
+		wake: make(chan struct{}, 1),
+		live: map[string]*liveJob{},
+	}
+}
+
+// ListenAndServe listens on addr and serves until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve runs the workers and answers API requests on l until ctx is
+// cancelled. It then stops accepting requests, waits up to
+// shutdownTimeout for open ones, and interrupts the running jobs, which
+// go back to the queue and run again on the next start.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		s.Work(workerCtx)
+	}()
+
+	httpServer := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- httpServer.Serve(l) }()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		err = httpServer.Shutdown(shutdownCtx)
+		cancel()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		err = nil
+	}
+
+	stopWorker()
+	<-workerDone
+	return err
+}
+
+// Work runs the queued jobs with opts.Workers workers until ctx is
+// cancelled, without the HTTP API. Running jobs are then interrupted and
+// go back to the queue.
+func (s *Server) Work(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Workers; i++ {
+		worker := s.opts.Worker
+		if s.opts.Workers > 1 {
+			worker = fmt.Sprintf("%s-%d", s.opts.Worker, i+1)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, worker)
+		}()
+	}
+	wg.Wait()
+}
+
+// Submit queues a download and returns its job.
+func (s *Server) Submit(ctx context.Context, req Request) (*Job, error) {
+	if strings.TrimSpace(req.URL) == "" {
+		return nil, fmt.Errorf("%w: url is required", errInvalidRequest)
+	}
+	now := time.Now()
+	j := &store.Job{
+		URL:       req.URL,
+		Options:   req.options(),
+		Status:    store.JobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.opts.Store.PutJob(ctx, j); err != nil {
+		return nil, fmt.Errorf("failed to queue job: %w", err)
+	}
+	s.opts.Logger.Infof("Job %s queued: %s", j.ID, req.URL)
+	s.notify()
+	return s.view(j), nil
+}
+
+// notify wakes an idle worker to look for jobs.
+func (s *Server) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Jobs returns every job in the queue, oldest first.
+func (s *Server) Jobs(ctx context.Context) ([]*Job, error) {
+	jobs, err := s.opts.Store.ListJobs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	views := make([]*Job, 0, len(jobs))
+	for _, j := range jobs {
+		views = append(views, s.view(j))
+	}
+	return views, nil
+}
+
+// Get returns a single job.
+func (s *Server) Get(ctx context.Context, id string) (*Job, error) {
+	j, err := s.opts.Store.GetJob(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(j), nil
+}
+
+// Cancel stops a queued or running job. A job running on this server
+// reaches JobCanceled once its download has stopped; one running on
+// another server stops when that server next renews its lease.
+func (s *Server) Cancel(ctx context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	if live := s.live[id]; live != nil && live.cancel != nil {
+		// Canceling overrides a pause still waiting for the download to stop.
+		live.paused = false
+		live.cancel()
+		s.mu.Unlock()
+		return s.Get(ctx, id)
+	}
+	s.mu.Unlock()
+
+	j, err := s.opts.Store.StopJob(ctx, id, store.JobCanceled)
+	if errors.Is(err, store.ErrJobFinished) {
+		return nil, errJobFinished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel job: %w", err)
+	}
+	s.opts.Logger.Infof("Job %s canceled", id)
+	return s.view(j), nil
+}
+
+// Pause holds a queued or running job back until it is resumed. A job
+// running on this server stops and reaches JobPaused once its download has
+// stopped; one running elsewhere stops when its worker next renews its
+// lease. A resumed job starts over.
+func (s *Server) Pause(ctx context.Context, id string) (*Job, error) {
+	s.mu.Lock()
+	if live := s.live[id]; live != nil && live.cancel != nil {
+		live.paused = true
+		live.cancel()
+		s.mu.Unlock()
+		return s.Get(ctx, id)
+	}
+	s.mu.Unlock()
+
+	j, err := s.opts.Store.StopJob(ctx, id, store.JobPaused)
+	if errors.Is(err, store.ErrJobFinished) {
+		return nil, errJobFinished
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause job: %w", err)
+	}
+	s.opts.Logger.Infof("Job %s paused", id)
+	return s.view(j), nil
+}
+
+// Resume queues a paused job again.
+func (s *Server) Resume(ctx context.Context, id string) (*Job, error) {
+	j, err := s.opts.Store.ResumeJob(ctx, id)
+	if errors.Is(err, store.ErrJobNotPaused) {
+		return nil, errJobNotPaused
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume job: %w", err)
+	}
+	s.opts.Logger.Infof("Job %s resumed", id)
+	s.notify()
+	return s.view(j), nil
+}
+
+// Remove deletes a finished job from the queue.
+func (s *Server) Remove(ctx context.Context, id string) error {
+	j, err := s.opts.Store.GetJob(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !isFinished(j.Status) {
+		return errJobActive
+	}
+	if err := s.opts.Store.DeleteJob(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.live, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Discard removes a job whatever its state, canceling it first unless it
+// has finished. A job running elsewhere stops when its worker next renews
+// its lease and finds the job gone.
+func (s *Server) Discard(ctx context.Context, id string) error {
+	if _, err := s.Cancel(ctx, id); err != nil && !errors.Is(err, errJobFinished) {
+		return err
+	}
+	if err := s.opts.Store.DeleteJob(ctx, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.live, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// work runs queued jobs as worker until ctx is cancelled.
+func (s *Server) work(ctx context.Context, worker string) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		j, err := s.opts.Store.ClaimJob(ctx, worker, store.DefaultLease)
+		switch {
+		case err == nil:
+			s.run(ctx, j, worker)
+			continue
+		case ctx.Err() != nil:
+			return
+		case !errors.Is(err, store.ErrNoJobs):
+			s.opts.Logger.Warnf("Failed to claim a job: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// run downloads one job claimed by worker and records its outcome. When
+// the server stops mid-download the job goes back to the queue.
+func (s *Server) run(ctx context.Context, j *store.Job, worker string) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	live := &liveJob{cancel: cancel}
+	s.mu.Lock()
+	s.live[j.ID] = live
+	s.mu.Unlock()
+	s.opts.Logger.Infof("Job %s started: %s", j.ID, j.URL)
+
+	stopHeartbeat := store.Heartbeat(jobCtx, s.opts.Store, j.ID, worker, store.DefaultLease, func(err error) {
+		s.opts.Logger.Warnf("Job %s: %v", j.ID, err)
+		cancel()
+	})
+	files, err := s.opts.Run(jobCtx, requestOf(j), func(p Progress) {
+		s.mu.Lock()
+		live.progress = p
+		s.mu.Unlock()
+	})
+	stopHeartbeat()
+
+	s.mu.Lock()
+	live.files, live.cancel = files, nil
+	paused := live.paused
+	s.mu.Unlock()
+
+	var status store.JobStatus
+	var errMsg string
+	switch {
+	case ctx.Err() != nil:
+		status = store.JobPending
+		s.opts.Logger.Infof("Job %s interrupted; it runs again on the next start", j.ID)
+	case jobCtx.Err() != nil && paused:
+		status = store.JobPaused
+		s.opts.Logger.Infof("Job %s paused", j.ID)
+	case jobCtx.Err() != nil:
+		status = store.JobCanceled
+		s.opts.Logger.Infof("Job %s canceled", j.ID)
+	case err != nil:
+		status, errMsg = store.JobFailed, err.Error()
+		s.opts.Logger.Warnf("Job %s failed: %v", j.ID, err)
+	default:
+		status = store.JobCompleted
+		s.opts.Logger.Infof("Job %s completed", j.ID)
+	}
+	// The lease is gone when the job was canceled, paused or removed
+	// through another server.
+	err = s.opts.Store.FinishJob(context.Background(), j.ID, worker, status, errMsg)
+	if err != nil && !errors.Is(err, store.ErrLeaseLost) && !errors.Is(err, store.ErrNotFound) {
+		s.opts.Logger.Warnf("Failed to record the outcome of job %s: %v", j.ID, err)
+	}
+	if status == store.JobCompleted || status == store.JobFailed {
+		s.reportQueue(context.Background())
+	}
+}
+
+// reportQueue logs when the rest of the queue should be done.
+func (s *Server) reportQueue(ctx context.Context) {
+	jobs, err := s.opts.Store.ListJobs(ctx)
+	if err != nil {
+		s.opts.Logger.Debugf("Failed to list the queue: %v", err)
+		return
+	}
+	est := s.estimate(ctx, jobs)
+	if est.Remaining == 0 {
+		s.opts.Logger.Infof("Queue done")
+		return
+	}
+	s.opts.Logger.Infof("Queue: %s", est)
+}
+
+// view combines a stored job with what this server knows about it.
+func (s *Server) view(j *store.Job) *Job {
+	v := &Job{Job: j}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if live := s.live[j.ID]; live != nil {
+		if j.Status == store.JobRunning {
+			p := live.progress
+			v.Progress = &p
+		}
+		v.Files = append([]string(nil), live.files...)
+	}
+	return v
+}
+
+// tokenCookie is the cookie the web UI keeps the token in, so plain links
+// to downloaded files work.
+const tokenCookie = "gobili_token"
+
+// Handler returns the web UI at / and the HTTP API below /api/. API
+// requests must carry the server's token, if it has one, as
+// "Authorization: Bearer <token>"; downloads of finished files below
+// /files/ may send it in the gobili_token cookie instead.
+func (s *Server) Handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("/api/jobs", s.handleJobs)
+	api.HandleFunc("/api/jobs/", s.handleJob)
+	api.HandleFunc("/api/queue", s.handleQueue)
+	api.HandleFunc("/api/login", s.handleLogin)
+	api.HandleFunc("/api/login/", s.handleLoginPoll)
+	api.HandleFunc("/api/files", s.handleFiles)
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", s.authorize(api, false))
+	mux.Handle("/files/", s.authorize(s.fileServer(), true))
+	mux.Handle("/", webHandler())
+	return mux
+}
+
+// authorize rejects requests without the server's token. With
+// allowCookie, GET and HEAD requests may send it in the token cookie; the
+// cookie is never enough to change anything.
+func (s *Server) authorize(next http.Handler, allowCookie bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.opts.Token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok && allowCookie && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				if c, err := r.Cookie(tokenCookie); err == nil {
+					token, err = url.QueryUnescape(c.Value)
+					ok = err == nil
+				}
+			}
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="goBili"`)
+				writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleJobs lists the jobs with a queue estimate (GET) or submits one
+// (POST).
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		jobs, err := s.Jobs(r.Context())
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs, "queue": s.EstimateQueue(r.Context(), jobs)})
+	case http.MethodPost:
+		var req Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		j, err := s.Submit(r.Context(), req)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, j)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleJob serves /api/jobs/{id} (GET, DELETE) and
+// /api/jobs/{id}/{cancel,pause,resume} (POST).
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		j, err := s.Get(r.Context(), id)
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	case action == "" && r.Method == http.MethodDelete:
+		if err := s.Remove(r.Context(), id); err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case action == "":
+		methodNotAllowed(w, http.MethodGet, http.MethodDelete)
+	case action == "cancel" || action == "pause" || action == "resume":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		var j *Job
+		var err error
+		switch action {
+		case "cancel":
+			j, err = s.Cancel(r.Context(), id)
+		case "pause":
+			j, err = s.Pause(r.Context(), id)
+		default:
+			j, err = s.Resume(r.Context(), id)
+		}
+		if err != nil {
+			writeError(w, statusFor(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown endpoint %s", r.URL.Path))
+	}
+}
+
+// handleQueue reports the queue estimate alone (GET), for status bars
+// and notifications that do not need the jobs.
+func (s *Server) handleQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	jobs, err := s.Jobs(r.Context())
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.EstimateQueue(r.Context(), jobs))
+}
+
+// EstimateQueue estimates when the unfinished jobs are done, from the
+// throughput of past downloads and the number of workers.
+func (s *Server) EstimateQueue(ctx context.Context, jobs []*Job) QueueStatus {
+	stored := make([]*store.Job, 0, len(jobs))
+	for _, j := range jobs {
+		stored = append(stored, j.Job)
+	}
+	est := s.estimate(ctx, stored)
+	status := QueueStatus{Remaining: est.Remaining, Summary: est.String()}
+	if est.Known() && est.Remaining > 0 {
+		status.ETA = int64(est.Duration / time.Second)
+		status.FinishAt = &est.FinishAt
+	}
+	return status
+}
+
+// estimate runs store.EstimateQueue over jobs with the download history.
+func (s *Server) estimate(ctx context.Context, jobs []*store.Job) store.QueueETA {
+	history, err := s.opts.Store.ListHistory(ctx)
+	if err != nil {
+		s.opts.Logger.Debugf("Failed to read the download history: %v", err)
+	}
+	return store.EstimateQueue(jobs, history, s.opts.Workers, time.Now())
+}
@@ -0,0 +1,516 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dengmengmian/goBili/store"
+)
+
+const testToken = "secret"
+
+// newTestServer returns a server with a fresh JSON store that runs jobs
+// with run, and an HTTP test server for its API.
+func newTestServer(t *testing.T, run RunFunc) (*Server, *httptest.Server) {
+	t.Helper()
+	st, err := store.OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("OpenJSON: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+	s := New(Options{Store: st, Worker: "test", Token: testToken, Run: run, Login: &fakeLogin{}})
+	api := httptest.NewServer(s.Handler())
+	t.Cleanup(api.Close)
+	return s, api
+}
+
+// startWorker runs the worker of s until the test ends.
+func startWorker(t *testing.T, s *Server) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Work(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// call sends an authorized request and decodes the JSON response into out.
+func call(t *testing.T, api *httptest.Server, method, path string, body, out interface{}) int {
+	t.Helper()
+	var r bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&r).Encode(body)
+	}
+	req, _ := http.NewRequest(method, api.URL+path, &r)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp.StatusCode
+}
+
+// waitForStatus polls the job until it reaches status.
+func waitForStatus(t *testing.T, api *httptest.Server, id string, status store.JobStatus) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var j Job
+		call(t, api, "GET", "/api/jobs/"+id, nil, &j)
+		if j.Job != nil && j.Status == status {
+			return &j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not reach %s (last %+v)", id, status, j.Job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	_, api := newTestServer(t, nil)
+
+	for _, header := range []string{"", "Bearer wrong", testToken} {
+		req, _ := http.NewRequest("GET", api.URL+"/api/jobs", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", header, resp.StatusCode)
+		}
+	}
+	if status := call(t, api, "GET", "/api/jobs", nil, nil); status != http.StatusOK {
+		t.Errorf("with token: status %d, want 200", status)
+	}
+}
+
+func TestJobLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	var got Request
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		got = req
+		update(Progress{Video: 1, Videos: 2, Downloaded: 50, Total: 100, Percent: 50})
+		<-release
+		return []string{"/downloads/a.mp4"}, nil
+	})
+
+	var j Job
+	if status := call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/x", Quality: "720p"}, &j); status != http.StatusCreated {
+		t.Fatalf("submit: status %d", status)
+	}
+	if j.Status != store.JobPending {
+		t.Errorf("new job status = %s, want pending", j.Status)
+	}
+	startWorker(t, s)
+
+	running := waitForStatus(t, api, j.ID, store.JobRunning)
+	for running.Progress == nil || running.Progress.Percent != 50 {
+		running = waitForStatus(t, api, j.ID, store.JobRunning)
+	}
+	if got.URL != "https://b23.tv/x" || got.Quality != "720p" {
+		t.Errorf("run got %+v", got)
+	}
+	if status := call(t, api, "DELETE", "/api/jobs/"+j.ID, nil, nil); status != http.StatusConflict {
+		t.Errorf("deleting a running job: status %d, want 409", status)
+	}
+
+	close(release)
+	done := waitForStatus(t, api, j.ID, store.JobCompleted)
+	if len(done.Files) != 1 || done.Files[0] != "/downloads/a.mp4" {
+		t.Errorf("files = %v", done.Files)
+	}
+	if done.Progress != nil {
+		t.Error("finished job still reports progress")
+	}
+
+	var list struct {
+		Jobs  []*Job      `json:"jobs"`
+		Queue QueueStatus `json:"queue"`
+	}
+	call(t, api, "GET", "/api/jobs", nil, &list)
+	if len(list.Jobs) != 1 || list.Queue.Remaining != 0 {
+		t.Errorf("list = %d jobs, %d remaining", len(list.Jobs), list.Queue.Remaining)
+	}
+	var queue QueueStatus
+	call(t, api, "GET", "/api/queue", nil, &queue)
+	if queue.Remaining != 0 || queue.Summary != "queue empty" {
+		t.Errorf("queue = %+v, want it empty", queue)
+	}
+
+	if status := call(t, api, "DELETE", "/api/jobs/"+j.ID, nil, nil); status != http.StatusNoContent {
+		t.Errorf("delete: status %d, want 204", status)
+	}
+	if status := call(t, api, "GET", "/api/jobs/"+j.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted job: status %d, want 404", status)
+	}
+}
+
+func TestJobFailure(t *testing.T) {
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		return nil, errors.New("video not found")
+	})
+	startWorker(t, s)
+
+	var j Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/x"}, &j)
+	failed := waitForStatus(t, api, j.ID, store.JobFailed)
+	if failed.Error != "video not found" {
+		t.Errorf("error = %q", failed.Error)
+	}
+}
+
+func TestSubmitRequiresURL(t *testing.T) {
+	_, api := newTestServer(t, nil)
+	if status := call(t, api, "POST", "/api/jobs", Request{}, nil); status != http.StatusBadRequest {
+		t.Errorf("status %d, want 400", status)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	// Canceled while queued: the worker never runs it.
+	var queued Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/queued"}, &queued)
+	if status := call(t, api, "POST", "/api/jobs/"+queued.ID+"/cancel", nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel queued: status %d", status)
+	}
+	waitForStatus(t, api, queued.ID, store.JobCanceled)
+
+	// Canceled while running.
+	startWorker(t, s)
+	var running Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/running"}, &running)
+	waitForStatus(t, api, running.ID, store.JobRunning)
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/cancel", nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel running: status %d", status)
+	}
+	waitForStatus(t, api, running.ID, store.JobCanceled)
+
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/cancel", nil, nil); status != http.StatusConflict {
+		t.Errorf("cancel finished: status %d, want 409", status)
+	}
+	if status := call(t, api, "POST", "/api/jobs/nope/cancel", nil, nil); status != http.StatusNotFound {
+		t.Errorf("cancel unknown: status %d, want 404", status)
+	}
+}
+
+func TestCancel_JobOfAnotherWorker(t *testing.T) {
+	s, api := newTestServer(t, nil)
+	ctx := context.Background()
+
+	var j Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/elsewhere"}, &j)
+	if _, err := s.opts.Store.ClaimJob(ctx, "other", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.opts.Store.FinishJob(ctx, j.ID, "other", store.JobCompleted, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"cancel", "pause"} {
+		if status := call(t, api, "POST", "/api/jobs/"+j.ID+"/"+action, nil, nil); status != http.StatusConflict {
+			t.Errorf("%s completed elsewhere: status %d, want 409", action, status)
+		}
+	}
+	if got, _ := s.opts.Store.GetJob(ctx, j.ID); got.Status != store.JobCompleted {
+		t.Errorf("status = %s, want the other worker's result kept", got.Status)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	var runs atomic.Int32
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		if runs.Add(1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return []string{"/downloads/a.mp4"}, nil
+	})
+
+	// Paused while queued: the worker leaves it alone.
+	var queued Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/queued"}, &queued)
+	if status := call(t, api, "POST", "/api/jobs/"+queued.ID+"/pause", nil, nil); status != http.StatusOK {
+		t.Fatalf("pause queued: status %d", status)
+	}
+	waitForStatus(t, api, queued.ID, store.JobPaused)
+
+	// Paused while running, then resumed: it runs again from the start.
+	startWorker(t, s)
+	var running Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/running"}, &running)
+	waitForStatus(t, api, running.ID, store.JobRunning)
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/pause", nil, nil); status != http.StatusOK {
+		t.Fatalf("pause running: status %d", status)
+	}
+	waitForStatus(t, api, running.ID, store.JobPaused)
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/resume", nil, nil); status != http.StatusOK {
+		t.Fatalf("resume: status %d", status)
+	}
+	waitForStatus(t, api, running.ID, store.JobCompleted)
+
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/resume", nil, nil); status != http.StatusConflict {
+		t.Errorf("resume completed: status %d, want 409", status)
+	}
+	if status := call(t, api, "POST", "/api/jobs/"+running.ID+"/pause", nil, nil); status != http.StatusConflict {
+		t.Errorf("pause completed: status %d, want 409", status)
+	}
+	if j := waitForStatus(t, api, queued.ID, store.JobPaused); j.Attempts != 0 {
+		t.Errorf("paused job was claimed %d times", j.Attempts)
+	}
+}
+
+func TestPauseThenCancel(t *testing.T) {
+	release := make(chan struct{})
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		<-ctx.Done()
+		// Still stopping when the cancel arrives.
+		<-release
+		return nil, ctx.Err()
+	})
+	startWorker(t, s)
+
+	var j Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/running"}, &j)
+	waitForStatus(t, api, j.ID, store.JobRunning)
+	if status := call(t, api, "POST", "/api/jobs/"+j.ID+"/pause", nil, nil); status != http.StatusOK {
+		t.Fatalf("pause: status %d", status)
+	}
+	if status := call(t, api, "POST", "/api/jobs/"+j.ID+"/cancel", nil, nil); status != http.StatusOK {
+		t.Fatalf("cancel: status %d", status)
+	}
+	close(release)
+	waitForStatus(t, api, j.ID, store.JobCanceled)
+}
+
+func TestDiscard(t *testing.T) {
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	startWorker(t, s)
+
+	var running Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/running"}, &running)
+	waitForStatus(t, api, running.ID, store.JobRunning)
+	if err := s.Discard(context.Background(), running.ID); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if status := call(t, api, "GET", "/api/jobs/"+running.ID, nil, nil); status != http.StatusNotFound {
+		t.Errorf("discarded job: status %d, want 404", status)
+	}
+	if err := s.Discard(context.Background(), running.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Discard twice = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWork_RunsJobsConcurrently(t *testing.T) {
+	st, err := store.OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("OpenJSON: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	// Every job blocks until released, so all three must run at once.
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	s := New(Options{Store: st, Worker: "test", Workers: 3, Run: func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		started <- struct{}{}
+		select {
+		case <-release:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}})
+	var ids []string
+	for i := 0; i < 3; i++ {
+		j, err := s.Submit(context.Background(), Request{URL: "https://b23.tv/x"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.ID)
+	}
+	startWorker(t, s)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of 3 jobs running at once", i)
+		}
+	}
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range ids {
+		for {
+			j, err := s.Get(context.Background(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if j.Status == store.JobCompleted {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s is %s, want completed", id, j.Status)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestServe_ShutdownRequeuesRunningJob(t *testing.T) {
+	started := make(chan struct{})
+	s, api := newTestServer(t, func(ctx context.Context, req Request, update func(Progress)) ([]string, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ctx, l) }()
+
+	var j Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/x"}, &j)
+	<-started
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+
+	stored, err := s.opts.Store.GetJob(context.Background(), j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != store.JobPending {
+		t.Errorf("status after shutdown = %s, want pending", stored.Status)
+	}
+}
+
+// fakeLogin confirms its QR code on the second poll.
+type fakeLogin struct {
+	polls     int
+	loggedIn  bool
+	lastStart string
+}
+
+func (f *fakeLogin) Account() (*Account, error) {
+	if !f.loggedIn {
+		return nil, nil
+	}
+	return &Account{Name: "tester", MID: 42}, nil
+}
+
+func (f *fakeLogin) StartQRCode() (*QRCode, error) {
+	f.lastStart = "k1"
+	return &QRCode{Key: "k1", URL: "https://passport.bilibili.com/qr?k1", Image: []byte("png")}, nil
+}
+
+func (f *fakeLogin) PollQRCode(key string) (QRState, error) {
+	if key != f.lastStart {
+		return "", errors.New("unknown key")
+	}
+	f.polls++
+	if f.polls < 2 {
+		return QRWaiting, nil
+	}
+	f.loggedIn = true
+	return QRDone, nil
+}
+
+func TestLogin(t *testing.T) {
+	_, api := newTestServer(t, nil)
+
+	var status struct {
+		LoggedIn bool     `json:"logged_in"`
+		Account  *Account `json:"account"`
+	}
+	call(t, api, "GET", "/api/login", nil, &status)
+	if status.LoggedIn {
+		t.Fatal("logged in before the QR code was scanned")
+	}
+
+	var qr QRCode
+	if code := call(t, api, "POST", "/api/login", nil, &qr); code != http.StatusCreated {
+		t.Fatalf("start: status %d", code)
+	}
+	if qr.Key != "k1" || string(qr.Image) != "png" {
+		t.Errorf("QR code = %+v", qr)
+	}
+
+	for _, want := range []QRState{QRWaiting, QRDone} {
+		var poll struct {
+			State QRState `json:"state"`
+		}
+		call(t, api, "GET", "/api/login/k1", nil, &poll)
+		if poll.State != want {
+			t.Errorf("state = %q, want %q", poll.State, want)
+		}
+	}
+
+	call(t, api, "GET", "/api/login", nil, &status)
+	if !status.LoggedIn || status.Account == nil || status.Account.MID != 42 {
+		t.Errorf("after login: %+v", status)
+	}
+}
+
+func TestQueueEstimate(t *testing.T) {
+	s, api := newTestServer(t, nil)
+	ctx := context.Background()
+	// 10 MB in 10 s of past downloads: 1 MB/s.
+	if err := s.opts.Store.AddHistory(ctx, &store.HistoryEntry{BVID: "BV1", Size: 10 << 20, Duration: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+
+	var j Job
+	call(t, api, "POST", "/api/jobs", Request{URL: "https://b23.tv/x"}, &j)
+
+	var queue QueueStatus
+	if status := call(t, api, "GET", "/api/queue", nil, &queue); status != http.StatusOK {
+		t.Fatalf("queue: status %d", status)
+	}
+	if queue.Remaining != 1 || queue.ETA != 10 || queue.FinishAt == nil {
+		t.Errorf("queue = %+v, want 1 job done in 10s", queue)
+	}
+	if !strings.HasPrefix(queue.Summary, "1 jobs, finishing around ") {
+		t.Errorf("summary = %q", queue.Summary)
+	}
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles is the browser UI: a page that submits URLs, shows the queue
+// with progress bars, logs in with a QR code and browses finished files,
+// all through the API.
+//
+//go:embed web
+var webFiles embed.FS
+
+// webHandler serves the browser UI. It holds no data, so it needs no token;
+// the page asks for one and sends it with its API calls.
+func webHandler() http.Handler {
+	root, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err) // the directory is embedded at build time
+	}
+	return http.FileServer(http.FS(root))
+}
@@ -0,0 +1,361 @@
+// goBili web UI: a thin client of the serve API. The token is kept in
+// localStorage and sent as a bearer token; it is also stored in the
+// gobili_token cookie so that links to downloaded files work.
+(function () {
+  "use strict";
+
+  const POLL_MS = 1500;
+  const STATUS_TEXT = {
+    pending: "排队中",
+    running: "下载中",
+    paused: "已暂停",
+    completed: "已完成",
+    failed: "失败",
+    canceled: "已取消",
+  };
+
+  const $ = (id) => document.getElementById(id);
+  let token = localStorage.getItem("gobiliToken") || "";
+  let filesPath = "";
+  let pollTimer = null;
+  let loginTimer = null;
+
+  class Unauthorized extends Error {}
+
+  async function api(method, path, body) {
+    const opts = { method, headers: { Authorization: "Bearer " + token } };
+    if (body !== undefined) {
+      opts.headers["Content-Type"] = "application/json";
+      opts.body = JSON.stringify(body);
+    }
+    const resp = await fetch(path, opts);
+    if (resp.status === 401) {
+      throw new Unauthorized();
+    }
+    if (resp.status === 204) {
+      return null;
+    }
+    const data = await resp.json().catch(() => ({}));
+    if (!resp.ok) {
+      throw new Error(data.error || resp.statusText);
+    }
+    return data;
+  }
+
+  function saveToken(value) {
+    token = value;
+    localStorage.setItem("gobiliToken", value);
+    document.cookie = "gobili_token=" + encodeURIComponent(value) + "; path=/; SameSite=Strict";
+  }
+
+  function showTokenPanel() {
+    clearTimeout(pollTimer);
+    $("app").hidden = true;
+    $("login-button").hidden = true;
+    $("account-name").textContent = "";
+    $("token-panel").hidden = false;
+  }
+
+  function formatSize(bytes) {
+    const units = ["B", "KB", "MB", "GB", "TB"];
+    let i = 0;
+    while (bytes >= 1024 && i < units.length - 1) {
+      bytes /= 1024;
+      i++;
+    }
+    return (i === 0 ? bytes : bytes.toFixed(1)) + " " + units[i];
+  }
+
+  function formatDuration(seconds) {
+    const h = Math.floor(seconds / 3600);
+    const m = Math.floor((seconds % 3600) / 60);
+    const s = seconds % 60;
+    const pad = (n) => String(n).padStart(2, "0");
+    return h > 0 ? h + ":" + pad(m) + ":" + pad(s) : m + ":" + pad(s);
+  }
+
+  function baseName(path) {
+    return path.split(/[\\/]/).pop();
+  }
+
+  // Jobs
+
+  function renderJob(job) {
+    const li = $("job-template").content.firstElementChild.cloneNode(true);
+    const p = job.progress;
+    li.querySelector(".job-title").textContent = (p && p.title) || job.url;
+    const status = li.querySelector(".status");
+    status.textContent = STATUS_TEXT[job.status] || job.status;
+    status.classList.add(job.status);
+
+    const detail = [];
+    if (job.options && job.options.quality) {
+      detail.push(job.options.quality);
+    }
+    if (job.status === "running" && p) {
+      li.querySelector(".progress").hidden = false;
+      li.querySelector(".bar").style.width = (p.percent || 0).toFixed(1) + "%";
+      if (p.videos > 1) {
+        detail.push("第 " + p.video + "/" + p.videos + " 个");
+      }
+      if (p.total > 0) {
+        detail.push(formatSize(p.downloaded) + " / " + formatSize(p.total));
+      }
+      if (p.speed > 0) {
+        detail.push(formatSize(p.speed) + "/s");
+      }
+      if (p.eta > 0) {
+        detail.push("剩余 " + formatDuration(p.eta));
+      }
+    }
+    if (job.error) {
+      detail.push(job.error);
+    }
+    if (job.files && job.files.length) {
+      detail.push(job.files.map(baseName).join("、"));
+    }
+    li.querySelector(".job-detail").textContent = detail.join(" · ");
+
+    const finished = ["completed", "failed", "canceled"].includes(job.status);
+    const pause = li.querySelector(".pause");
+    pause.hidden = job.status !== "pending" && job.status !== "running";
+    pause.onclick = () => act("POST", "/api/jobs/" + job.id + "/pause");
+    const resume = li.querySelector(".resume");
+    resume.hidden = job.status !== "paused";
+    resume.onclick = () => act("POST", "/api/jobs/" + job.id + "/resume");
+    const cancel = li.querySelector(".cancel");
+    cancel.hidden = finished;
+    cancel.onclick = () => act("POST", "/api/jobs/" + job.id + "/cancel");
+    const remove = li.querySelector(".remove");
+    remove.hidden = !finished;
+    remove.onclick = () => act("DELETE", "/api/jobs/" + job.id);
+    return li;
+  }
+
+  async function act(method, path) {
+    try {
+      await api(method, path);
+      refreshJobs();
+    } catch (e) {
+      handleError(e);
+    }
+  }
+
+  let lastCompleted = -1;
+
+  async function refreshJobs() {
+    clearTimeout(pollTimer);
+    try {
+      const data = await api("GET", "/api/jobs");
+      const jobs = data.jobs.slice().reverse(); // newest first
+      $("jobs").replaceChildren(...jobs.map(renderJob));
+      $("jobs-empty").hidden = jobs.length > 0;
+
+      const q = data.queue;
+      let summary = "";
+      if (q.remaining > 0) {
+        summary = q.remaining + " 个未完成";
+        if (q.finish_at) {
+          summary += "，预计 " + new Date(q.finish_at).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" }) + " 完成";
+        }
+      }
+      $("queue-summary").textContent = summary;
+
+      // New files appear when a job completes.
+      const completed = jobs.filter((j) => j.status === "completed").length;
+      if (completed !== lastCompleted) {
+        lastCompleted = completed;
+        refreshFiles();
+      }
+    } catch (e) {
+      if (handleError(e)) {
+        return;
+      }
+    }
+    pollTimer = setTimeout(refreshJobs, POLL_MS);
+  }
+
+  async function submitJob(event) {
+    event.preventDefault();
+    const request = { url: $("url-input").value.trim() };
+    const quality = $("quality-select").value;
+    const pages = $("pages-input").value.trim();
+    if (quality) {
+      request.quality = quality;
+    }
+    if (pages) {
+      request.pages = pages;
+    }
+    $("submit-error").hidden = true;
+    try {
+      await api("POST", "/api/jobs", request);
+      $("url-input").value = "";
+      $("pages-input").value = "";
+      refreshJobs();
+    } catch (e) {
+      if (!handleError(e)) {
+        $("submit-error").textContent = "提交失败：" + e.message;
+        $("submit-error").hidden = false;
+      }
+    }
+  }
+
+  // Files
+
+  function renderBreadcrumb() {
+    const nav = $("breadcrumb");
+    nav.replaceChildren();
+    const parts = filesPath ? filesPath.split("/") : [];
+    const link = (label, path) => {
+      const a = document.createElement("a");
+      a.href = "#";
+      a.textContent = label;
+      a.onclick = (e) => {
+        e.preventDefault();
+        openDir(path);
+      };
+      return a;
+    };
+    nav.append(link("下载目录", ""));
+    parts.forEach((part, i) => {
+      nav.append(" / ", link(part, parts.slice(0, i + 1).join("/")));
+    });
+  }
+
+  function renderFile(f) {
+    const li = document.createElement("li");
+    li.className = "file";
+    const a = document.createElement("a");
+    a.textContent = f.dir ? f.name + "/" : f.name;
+    const info = document.createElement("span");
+    info.className = "muted";
+    if (f.dir) {
+      a.href = "#";
+      a.onclick = (e) => {
+        e.preventDefault();
+        openDir(f.path);
+      };
+    } else {
+      a.href = "/files/" + f.path.split("/").map(encodeURIComponent).join("/");
+      a.target = "_blank";
+      info.textContent = formatSize(f.size) + " · " + new Date(f.mod_time).toLocaleString();
+    }
+    li.append(a, info);
+    return li;
+  }
+
+  async function refreshFiles() {
+    try {
+      const data = await api("GET", "/api/files?path=" + encodeURIComponent(filesPath));
+      $("files").replaceChildren(...data.files.map(renderFile));
+      $("files-empty").hidden = data.files.length > 0;
+    } catch (e) {
+      if (e.message && !handleError(e)) {
+        $("files").replaceChildren();
+        $("files-empty").textContent = e.message;
+        $("files-empty").hidden = false;
+      }
+    }
+    renderBreadcrumb();
+  }
+
+  function openDir(path) {
+    filesPath = path;
+    refreshFiles();
+  }
+
+  // Login
+
+  async function refreshAccount() {
+    try {
+      const data = await api("GET", "/api/login");
+      $("account-name").textContent = data.logged_in ? data.account.name + (data.account.vip ? " (大会员)" : "") : "未登录";
+      $("login-button").hidden = data.logged_in;
+    } catch (e) {
+      if (!handleError(e)) {
+        $("account-name").textContent = "";
+      }
+    }
+  }
+
+  async function startLogin() {
+    clearTimeout(loginTimer);
+    try {
+      const qr = await api("POST", "/api/login");
+      $("login-qr").src = "data:image/png;base64," + qr.image;
+      $("login-hint").textContent = "请使用哔哩哔哩手机客户端扫描二维码。";
+      $("login-panel").hidden = false;
+      pollLogin(qr.key);
+    } catch (e) {
+      if (!handleError(e)) {
+        alert("无法生成二维码：" + e.message);
+      }
+    }
+  }
+
+  async function pollLogin(key) {
+    try {
+      const data = await api("GET", "/api/login/" + encodeURIComponent(key));
+      switch (data.state) {
+        case "done":
+          $("login-panel").hidden = true;
+          refreshAccount();
+          return;
+        case "expired":
+          startLogin();
+          return;
+        case "scanned":
+          $("login-hint").textContent = "已扫描，请在手机上确认登录。";
+          break;
+      }
+    } catch (e) {
+      if (handleError(e)) {
+        return;
+      }
+      $("login-hint").textContent = "登录失败：" + e.message;
+      return;
+    }
+    loginTimer = setTimeout(() => pollLogin(key), 2000);
+  }
+
+  function closeLogin() {
+    clearTimeout(loginTimer);
+    $("login-panel").hidden = true;
+  }
+
+  // handleError shows the token form when the token was rejected and
+  // reports whether it did.
+  function handleError(e) {
+    if (e instanceof Unauthorized) {
+      showTokenPanel();
+      return true;
+    }
+    console.error(e);
+    return false;
+  }
+
+  function start() {
+    $("token-panel").hidden = true;
+    $("app").hidden = false;
+    refreshAccount();
+    refreshJobs();
+  }
+
+  $("token-form").addEventListener("submit", (e) => {
+    e.preventDefault();
+    saveToken($("token-input").value.trim());
+    $("token-input").value = "";
+    start();
+  });
+  $("submit-form").addEventListener("submit", submitJob);
+  $("login-button").addEventListener("click", startLogin);
+  $("login-close").addEventListener("click", closeLogin);
+
+  if (token) {
+    // Renew the cookie, which may have expired with the browser session.
+    saveToken(token);
+    start();
+  } else {
+    showTokenPanel();
+  }
+})();
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BatchEntry is one video of a batch run.
+type BatchEntry struct {
+	Key    string    `json:"key"`    // unique within the batch, e.g. "BVID/CID"
+	Source string    `json:"source"` // the URL the entry was expanded from
+	Title  string    `json:"title"`
+	Status JobStatus `json:"status"` // JobPending, JobCompleted or JobFailed
+	Error  string    `json:"error,omitempty"`
+	Path   string    `json:"path,omitempty"`
+
+	// Video is the parsed description needed to download the entry
+	// again without re-parsing Source; the store does not interpret it.
+	Video json.RawMessage `json:"video,omitempty"`
+}
+
+// Batch is the progress of one batch run. It is saved as its own JSON file
+// after every entry, so an interrupted run can be resumed.
+type Batch struct {
+	ID        string            `json:"id"`
+	Sources   []string          `json:"sources"`
+	Options   map[string]string `json:"options,omitempty"`
+	Entries   []*BatchEntry     `json:"entries"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	dir string
+}
+
+// NewBatch returns an empty batch whose state file will live in dir.
+func NewBatch(dir string, sources []string) *Batch {
+	now := time.Now()
+	return &Batch{
+		ID:        newID()[:8],
+		Sources:   sources,
+		CreatedAt: now,
+		UpdatedAt: now,
+		dir:       dir,
+	}
+}
+
+// batchPath returns the state file of batch id in dir.
+func batchPath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+// LoadBatch reads the state of batch id from dir. It returns ErrNotFound
+// when no such batch exists.
+func LoadBatch(dir, id string) (*Batch, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid batch id %q", id)
+	}
+	data, err := os.ReadFile(batchPath(dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("batch %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch state: %w", err)
+	}
+	b := &Batch{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to parse batch state: %w", err)
+	}
+	b.dir = dir
+	return b, nil
+}
+
+// ListBatches returns the batches saved in dir, newest first.
+func ListBatches(dir string) ([]*Batch, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var batches []*Batch
+	for _, path := range paths {
+		b, err := LoadBatch(dir, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err != nil {
+			continue // Not a batch file, or a damaged one.
+		}
+		batches = append(batches, b)
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	return batches, nil
+}
+
+// Save writes the batch state atomically.
+func (b *Batch) Save() error {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create batch directory: %w", err)
+	}
+	b.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch state: %w", err)
+	}
+	return writeFileAtomic(batchPath(b.dir, b.ID), data)
+}
+
+// Add appends an entry unless one with the same key exists, and reports
+// whether it was added.
+func (b *Batch) Add(e *BatchEntry) bool {
+	for _, existing := range b.Entries {
+		if existing.Key == e.Key {
+			return false
+		}
+	}
+	if e.Status == "" {
+		e.Status = JobPending
+	}
+	b.Entries = append(b.Entries, e)
+	return true
+}
+
+// Remaining returns the entries that have not completed: pending ones and
+// those that failed in an earlier run.
+func (b *Batch) Remaining() []*BatchEntry {
+	var remaining []*BatchEntry
+	for _, e := range b.Entries {
+		if e.Status != JobCompleted {
+			remaining = append(remaining, e)
+		}
+	}
+	return remaining
+}
+
+// Counts returns the number of completed, failed and pending entries.
+func (b *Batch) Counts() (completed, failed, pending int) {
+	for _, e := range b.Entries {
+		switch e.Status {
+		case JobCompleted:
+			completed++
+		case JobFailed:
+			failed++
+		default:
+			pending++
+		}
+	}
+	return completed, failed, pending
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestBatch_SaveAndResume(t *testing.T) {
+	dir := t.TempDir()
+	b := NewBatch(dir, []string{"https://www.bilibili.com/video/BV1"})
+	b.Add(&BatchEntry{Key: "BV1/1", Title: "p1", Video: json.RawMessage(`{"bvid":"BV1"}`)})
+	b.Add(&BatchEntry{Key: "BV1/2", Title: "p2"})
+	b.Add(&BatchEntry{Key: "BV1/3", Title: "p3"})
+	if b.Add(&BatchEntry{Key: "BV1/1"}) {
+		t.Error("Add should ignore a duplicate key")
+	}
+	b.Entries[0].Status = JobCompleted
+	b.Entries[1].Status = JobFailed
+	b.Entries[1].Error = "HTTP 403"
+	if err := b.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadBatch(dir, b.ID)
+	if err != nil {
+		t.Fatalf("LoadBatch: %v", err)
+	}
+	if c, f, p := loaded.Counts(); c != 1 || f != 1 || p != 1 {
+		t.Errorf("Counts = %d, %d, %d; want 1, 1, 1", c, f, p)
+	}
+	remaining := loaded.Remaining()
+	if len(remaining) != 2 || remaining[0].Key != "BV1/2" || remaining[1].Key != "BV1/3" {
+		t.Errorf("Remaining = %+v, want the failed and the pending entry", remaining)
+	}
+	var video struct{ BVID string }
+	if err := json.Unmarshal(loaded.Entries[0].Video, &video); err != nil || video.BVID != "BV1" {
+		t.Errorf("Video = %s, %v", loaded.Entries[0].Video, err)
+	}
+
+	// The loaded batch saves back to the same file.
+	loaded.Entries[2].Status = JobCompleted
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	batches, err := ListBatches(dir)
+	if err != nil || len(batches) != 1 {
+		t.Fatalf("ListBatches = %v, %v; want one batch", batches, err)
+	}
+	if _, _, p := batches[0].Counts(); p != 0 {
+		t.Errorf("pending after resume = %d, want 0", p)
+	}
+}
+
+func TestLoadBatch_Missing(t *testing.T) {
+	if _, err := LoadBatch(t.TempDir(), "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("error = %v, want ErrNotFound", err)
+	}
+	if _, err := LoadBatch(t.TempDir(), "../x"); err == nil {
+		t.Error("ids with path separators should be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// etaSampleSize is how many recent history entries feed the throughput
+// estimate, so it follows changes in bandwidth without being too noisy.
+const etaSampleSize = 20
+
+// QueueETA is a queue-wide completion estimate.
+type QueueETA struct {
+	Remaining  int           // jobs not yet finished
+	Bytes      int64         // estimated bytes left to download
+	Throughput float64       // bytes per second across all workers
+	Duration   time.Duration // estimated time until the queue drains
+	FinishAt   time.Time     // Duration added to the estimate time
+}
+
+// Known reports whether enough history existed to produce an estimate.
+func (e QueueETA) Known() bool {
+	return e.Throughput > 0
+}
+
+// String formats the estimate for humans, e.g. "3 jobs, finishing around 06:40".
+func (e QueueETA) String() string {
+	switch {
+	case e.Remaining == 0:
+		return "queue empty"
+	case !e.Known():
+		return fmt.Sprintf("%d jobs, finish time unknown", e.Remaining)
+	}
+
+	layout := "15:04"
+	if e.Duration >= 24*time.Hour {
+		layout = "Jan 2 15:04"
+	}
+	return fmt.Sprintf("%d jobs, finishing around %s", e.Remaining, e.FinishAt.Format(layout))
+}
+
+// Throughput returns the average download speed in bytes per second over the
+// most recent history entries that recorded a duration, or 0 if none did.
+func Throughput(history []*HistoryEntry) float64 {
+	var bytes int64
+	var elapsed time.Duration
+	samples := 0
+
+	for i := len(history) - 1; i >= 0 && samples < etaSampleSize; i-- {
+		e := history[i]
+		if e.Size <= 0 || e.Duration <= 0 {
+			continue
+		}
+		bytes += e.Size
+		elapsed += e.Duration
+		samples++
+	}
+
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds()
+}
+
+// averageSize returns the mean size of recent downloads, used for jobs whose
+// size is not known before they start.
+func averageSize(history []*HistoryEntry) int64 {
+	var total int64
+	samples := 0
+	for i := len(history) - 1; i >= 0 && samples < etaSampleSize; i-- {
+		if history[i].Size > 0 {
+			total += history[i].Size
+			samples++
+		}
+	}
+	if samples == 0 {
+		return 0
+	}
+	return total / int64(samples)
+}
+
+// EstimateQueue estimates when the pending, running, and paused jobs will be
+// done, given historical throughput and the number of parallel workers.
+// History is expected oldest first, as returned by ListHistory.
+func EstimateQueue(jobs []*Job, history []*HistoryEntry, workers int, now time.Time) QueueETA {
+	if workers < 1 {
+		workers = 1
+	}
+
+	est := QueueETA{FinishAt: now}
+	fallback := averageSize(history)
+	for _, j := range jobs {
+		if j.Status == JobCompleted || j.Status == JobFailed || j.Status == JobCanceled {
+			continue
+		}
+		est.Remaining++
+		if j.Size > 0 {
+			est.Bytes += j.Size
+		} else {
+			est.Bytes += fallback
+		}
+	}
+
+	est.Throughput = Throughput(history) * float64(workers)
+	if est.Remaining == 0 || !est.Known() {
+		return est
+	}
+
+	est.Duration = time.Duration(float64(est.Bytes) / est.Throughput * float64(time.Second)).Round(time.Second)
+	est.FinishAt = now.Add(est.Duration)
+	return est
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThroughput(t *testing.T) {
+	history := []*HistoryEntry{
+		{Size: 100, Duration: 0}, // no timing; ignored
+		{Size: 1000, Duration: time.Second},
+		{Size: 3000, Duration: time.Second},
+	}
+	if got := Throughput(history); got != 2000 {
+		t.Errorf("Throughput = %v, want 2000", got)
+	}
+	if got := Throughput(nil); got != 0 {
+		t.Errorf("Throughput(nil) = %v, want 0", got)
+	}
+}
+
+func TestEstimateQueue(t *testing.T) {
+	now := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+	history := []*HistoryEntry{
+		{Size: 1000, Duration: time.Second},
+		{Size: 3000, Duration: 3 * time.Second},
+	}
+	jobs := []*Job{
+		{Status: JobPending, Size: 6000},
+		{Status: JobRunning},              // unknown size: average of history (2000)
+		{Status: JobCompleted, Size: 1e9}, // done; ignored
+		{Status: JobCanceled, Size: 1e9},  // canceled; ignored
+	}
+
+	est := EstimateQueue(jobs, history, 2, now)
+	if est.Remaining != 2 {
+		t.Errorf("Remaining = %d, want 2", est.Remaining)
+	}
+	if est.Bytes != 8000 {
+		t.Errorf("Bytes = %d, want 8000", est.Bytes)
+	}
+	// 1000 B/s per worker, two workers: 8000 bytes take 4 seconds.
+	if est.Duration != 4*time.Second {
+		t.Errorf("Duration = %v, want 4s", est.Duration)
+	}
+	if !est.FinishAt.Equal(now.Add(4 * time.Second)) {
+		t.Errorf("FinishAt = %v, want %v", est.FinishAt, now.Add(4*time.Second))
+	}
+	if s := est.String(); !strings.Contains(s, "06:00") {
+		t.Errorf("String() = %q, want finish time 06:00", s)
+	}
+}
+
+func TestEstimateQueue_NoHistory(t *testing.T) {
+	est := EstimateQueue([]*Job{{Status: JobPending}}, nil, 1, time.Now())
+	if est.Known() {
+		t.Fatalf("estimate without history should be unknown: %+v", est)
+	}
+	if s := est.String(); !strings.Contains(s, "unknown") {
+		t.Errorf("String() = %q, want unknown", s)
+	}
+}
@@ -0,0 +1,37 @@
+package store
+
+import "strings"
+
+// SearchHistory returns the entries of history whose title, BVID or path
+// contain every word of query, ignoring case. An empty query matches all.
+func SearchHistory(history []*HistoryEntry, query string) []*HistoryEntry {
+	words := strings.Fields(strings.ToLower(query))
+	var out []*HistoryEntry
+	for _, e := range history {
+		text := strings.ToLower(e.Title + "\n" + e.BVID + "\n" + e.Path)
+		matched := true
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// FindDownloads returns the entries of history for the part cid of the
+// video bvid, newest first. A zero cid matches every part of the video.
+func FindDownloads(history []*HistoryEntry, bvid string, cid int64) []*HistoryEntry {
+	var out []*HistoryEntry
+	for i := len(history) - 1; i >= 0; i-- {
+		e := history[i]
+		if e.BVID == bvid && (cid == 0 || e.CID == cid) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
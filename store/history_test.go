@@ -0,0 +1,59 @@
+package store
+
+import "testing"
+
+func TestSearchHistory(t *testing.T) {
+	history := []*HistoryEntry{
+		{ID: "a", BVID: "BV1xx411c7mu", Title: "Go 语言入门 第一集", Path: "/videos/go-1.mp4"},
+		{ID: "b", BVID: "BV1yy411c7mu", Title: "Rust Tutorial", Path: "/videos/rust.mp4"},
+		{ID: "c", BVID: "BV1zz411c7mu", Title: "Go 语言入门 第二集", Path: "/archive/go-2.mkv"},
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c"}},
+		{"go", []string{"a", "c"}},
+		{"GO 第二集", []string{"c"}},
+		{"bv1yy", []string{"b"}},
+		{"archive", []string{"c"}},
+		{"python", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range SearchHistory(history, tt.query) {
+			got = append(got, e.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("SearchHistory(%q) = %v, want %v", tt.query, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("SearchHistory(%q) = %v, want %v", tt.query, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestFindDownloads(t *testing.T) {
+	history := []*HistoryEntry{
+		{ID: "old", BVID: "BV1", CID: 10},
+		{ID: "other", BVID: "BV1", CID: 11},
+		{ID: "new", BVID: "BV1", CID: 10},
+		{ID: "elsewhere", BVID: "BV2", CID: 10},
+	}
+
+	got := FindDownloads(history, "BV1", 10)
+	if len(got) != 2 || got[0].ID != "new" || got[1].ID != "old" {
+		t.Errorf("FindDownloads(BV1, 10) = %v, want [new old]", got)
+	}
+	if got := FindDownloads(history, "BV1", 0); len(got) != 3 {
+		t.Errorf("FindDownloads(BV1, 0) returned %d entries, want 3", len(got))
+	}
+	if got := FindDownloads(history, "BV3", 10); got != nil {
+		t.Errorf("FindDownloads(BV3, 10) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,386 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// lockTimeout bounds how long an operation waits for another process to
+// release the state file.
+const lockTimeout = 5 * time.Second
+
+// errLocked is returned by tryLock while another process holds the lock.
+var errLocked = errors.New("state file is locked")
+
+// jsonState is the on-disk layout of the JSON backend.
+type jsonState struct {
+	History       []*HistoryEntry `json:"history"`
+	Jobs          []*Job          `json:"jobs"`
+	Subscriptions []*Subscription `json:"subscriptions"`
+}
+
+// JSONStore keeps all state in a single JSON file. Every operation re-reads
+// the file, so separate goBili processes on the same machine (e.g. the
+// daemon and 'queue add') see each other's changes; a lock file serializes
+// writers and updates are written atomically via rename.
+type JSONStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// OpenJSON opens (or lazily creates) a JSON state file at path.
+func OpenJSON(path string) (*JSONStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return &JSONStore{path: path}, nil
+}
+
+// view runs fn against a freshly loaded copy of the state.
+func (s *JSONStore) view(fn func(*jsonState) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	return fn(st)
+}
+
+// update runs fn under the file lock and persists the result.
+func (s *JSONStore) update(fn func(*jsonState) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(st); err != nil {
+		return err
+	}
+	return s.save(st)
+}
+
+func (s *JSONStore) load() (*jsonState, error) {
+	st := &jsonState{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	if len(data) == 0 {
+		return st, nil
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	return st, nil
+}
+
+func (s *JSONStore) save(st *jsonState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces path with data via a temporary file and a
+// rename, so readers never see a partly written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
+
+// lock acquires an exclusive lock on a lock file next to the state file.
+// The lock belongs to the open file, so the OS releases it when a process
+// dies and an abandoned lock never has to be detected and removed.
+func (s *JSONStore) lock() (func(), error) {
+	lockPath := s.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		unlock, err := tryLock(lockPath)
+		if err == nil {
+			return unlock, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("failed to lock state file: %w", err)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for state lock %s", lockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// AddHistory implements Store.
+func (s *JSONStore) AddHistory(_ context.Context, e *HistoryEntry) error {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	return s.update(func(st *jsonState) error {
+		for i, existing := range st.History {
+			if existing.ID == e.ID {
+				st.History[i] = e
+				return nil
+			}
+		}
+		st.History = append(st.History, e)
+		return nil
+	})
+}
+
+// ListHistory implements Store.
+func (s *JSONStore) ListHistory(_ context.Context) ([]*HistoryEntry, error) {
+	var out []*HistoryEntry
+	err := s.view(func(st *jsonState) error {
+		out = st.History
+		return nil
+	})
+	return out, err
+}
+
+// DeleteHistory implements Store.
+func (s *JSONStore) DeleteHistory(_ context.Context, id string) error {
+	return s.update(func(st *jsonState) error {
+		for i, e := range st.History {
+			if e.ID == id {
+				st.History = append(st.History[:i], st.History[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// PutJob implements Store.
+func (s *JSONStore) PutJob(_ context.Context, j *Job) error {
+	if j.ID == "" {
+		j.ID = newID()
+	}
+	return s.update(func(st *jsonState) error {
+		for i, existing := range st.Jobs {
+			if existing.ID == j.ID {
+				st.Jobs[i] = j
+				return nil
+			}
+		}
+		st.Jobs = append(st.Jobs, j)
+		return nil
+	})
+}
+
+// GetJob implements Store.
+func (s *JSONStore) GetJob(_ context.Context, id string) (*Job, error) {
+	var out *Job
+	err := s.view(func(st *jsonState) error {
+		for _, j := range st.Jobs {
+			if j.ID == id {
+				out = j
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return out, err
+}
+
+// ListJobs implements Store.
+func (s *JSONStore) ListJobs(_ context.Context) ([]*Job, error) {
+	var out []*Job
+	err := s.view(func(st *jsonState) error {
+		out = st.Jobs
+		return nil
+	})
+	return out, err
+}
+
+// DeleteJob implements Store.
+func (s *JSONStore) DeleteJob(_ context.Context, id string) error {
+	return s.update(func(st *jsonState) error {
+		for i, j := range st.Jobs {
+			if j.ID == id {
+				st.Jobs = append(st.Jobs[:i], st.Jobs[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// ClaimJob implements Store.
+func (s *JSONStore) ClaimJob(_ context.Context, worker string, lease time.Duration) (*Job, error) {
+	var claimed *Job
+	err := s.update(func(st *jsonState) error {
+		now := time.Now()
+		for _, j := range st.Jobs {
+			if j.claimable(now) {
+				j.lease(worker, now, lease)
+				claimed = j
+				return nil
+			}
+		}
+		return ErrNoJobs
+	})
+	return claimed, err
+}
+
+// RenewLease implements Store.
+func (s *JSONStore) RenewLease(_ context.Context, id, worker string, lease time.Duration) error {
+	return s.update(func(st *jsonState) error {
+		for _, j := range st.Jobs {
+			if j.ID != id {
+				continue
+			}
+			if j.Status != JobRunning || j.Worker != worker {
+				return ErrLeaseLost
+			}
+			j.LeaseExpires = time.Now().Add(lease)
+			return nil
+		}
+		return ErrNotFound
+	})
+}
+
+// FinishJob implements Store.
+func (s *JSONStore) FinishJob(_ context.Context, id, worker string, status JobStatus, errMsg string) error {
+	return s.update(func(st *jsonState) error {
+		for _, j := range st.Jobs {
+			if j.ID != id {
+				continue
+			}
+			if j.Worker != worker {
+				return ErrLeaseLost
+			}
+			j.finish(status, errMsg, time.Now())
+			return nil
+		}
+		return ErrNotFound
+	})
+}
+
+// StopJob implements Store.
+func (s *JSONStore) StopJob(_ context.Context, id string, status JobStatus) (*Job, error) {
+	var stopped *Job
+	err := s.update(func(st *jsonState) error {
+		for _, j := range st.Jobs {
+			if j.ID != id {
+				continue
+			}
+			if j.Status.Finished() {
+				return ErrJobFinished
+			}
+			if j.Status != status {
+				j.finish(status, "", time.Now())
+			}
+			stopped = j
+			return nil
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stopped, nil
+}
+
+// ResumeJob implements Store.
+func (s *JSONStore) ResumeJob(_ context.Context, id string) (*Job, error) {
+	var resumed *Job
+	err := s.update(func(st *jsonState) error {
+		for _, j := range st.Jobs {
+			if j.ID != id {
+				continue
+			}
+			if j.Status != JobPaused {
+				return ErrJobNotPaused
+			}
+			j.Status, j.UpdatedAt = JobPending, time.Now()
+			resumed = j
+			return nil
+		}
+		return ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resumed, nil
+}
+
+// PutSubscription implements Store.
+func (s *JSONStore) PutSubscription(_ context.Context, sub *Subscription) error {
+	if sub.ID == "" {
+		sub.ID = newID()
+	}
+	return s.update(func(st *jsonState) error {
+		for i, existing := range st.Subscriptions {
+			if existing.ID == sub.ID {
+				st.Subscriptions[i] = sub
+				return nil
+			}
+		}
+		st.Subscriptions = append(st.Subscriptions, sub)
+		return nil
+	})
+}
+
+// ListSubscriptions implements Store.
+func (s *JSONStore) ListSubscriptions(_ context.Context) ([]*Subscription, error) {
+	var out []*Subscription
+	err := s.view(func(st *jsonState) error {
+		out = st.Subscriptions
+		return nil
+	})
+	return out, err
+}
+
+// DeleteSubscription implements Store.
+func (s *JSONStore) DeleteSubscription(_ context.Context, id string) error {
+	return s.update(func(st *jsonState) error {
+		for i, sub := range st.Subscriptions {
+			if sub.ID == id {
+				st.Subscriptions = append(st.Subscriptions[:i], st.Subscriptions[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+// Close implements Store.
+func (s *JSONStore) Close() error {
+	return nil
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONStore_History(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &HistoryEntry{BVID: "BV1", Title: "first", CompletedAt: time.Now()}
+	if err := s.AddHistory(ctx, e); err != nil {
+		t.Fatalf("AddHistory: %v", err)
+	}
+	if e.ID == "" {
+		t.Error("AddHistory should assign an ID")
+	}
+	if err := s.AddHistory(ctx, &HistoryEntry{BVID: "BV2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].BVID != "BV1" {
+		t.Fatalf("ListHistory = %+v, want BV1 then BV2", list)
+	}
+
+	if err := s.DeleteHistory(ctx, e.ID); err != nil {
+		t.Fatalf("DeleteHistory: %v", err)
+	}
+	if err := s.DeleteHistory(ctx, e.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteHistory error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestJSONStore_JobsSharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	a, err := OpenJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := &Job{URL: "https://www.bilibili.com/video/BV1", Status: JobPending}
+	if err := a.PutJob(ctx, job); err != nil {
+		t.Fatalf("PutJob: %v", err)
+	}
+
+	got, err := b.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetJob from second instance: %v", err)
+	}
+	if got.URL != job.URL {
+		t.Errorf("URL = %q, want %q", got.URL, job.URL)
+	}
+
+	got.Status = JobCompleted
+	if err := b.PutJob(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := a.ListJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Status != JobCompleted {
+		t.Errorf("ListJobs = %+v, want one completed job", jobs)
+	}
+
+	if _, err := a.GetJob(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetJob(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := a.DeleteJob(ctx, job.ID); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+}
+
+func TestJSONStore_ConcurrentWritersKeepEveryUpdate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	// A lock file left behind by a crashed process must not block writers.
+	if err := os.WriteFile(path+".lock", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers, perWriter = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		s, err := OpenJSON(path) // one instance per writer, like separate processes
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := s.AddHistory(ctx, &HistoryEntry{BVID: "BV1"}); err != nil {
+					t.Errorf("AddHistory: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	s, err := OpenJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ListHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != writers*perWriter {
+		t.Errorf("history has %d entries, want %d", len(list), writers*perWriter)
+	}
+}
+
+func TestJSONStore_Subscriptions(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "nested", "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &Subscription{Kind: "uploader", Target: "123"}
+	if err := s.PutSubscription(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	sub.Name = "renamed"
+	if err := s.PutSubscription(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := s.ListSubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Name != "renamed" {
+		t.Errorf("ListSubscriptions = %+v, want one renamed entry", subs)
+	}
+	if err := s.DeleteSubscription(ctx, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultLease is the lease duration used by workers unless configured.
+const DefaultLease = 2 * time.Minute
+
+// WorkerID returns a default worker identity of the form "host-pid", which
+// is unique across the machines sharing a queue.
+func WorkerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Heartbeat renews the lease on a job every lease/3 until ctx is done or the
+// returned stop function is called. If the lease is lost (another worker
+// reclaimed the job after a missed heartbeat), onLost is called once so the
+// caller can abort its work.
+func Heartbeat(ctx context.Context, s Store, jobID, worker string, lease time.Duration, onLost func(error)) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := s.RenewLease(ctx, jobID, worker, lease)
+				if err == nil || errors.Is(err, context.Canceled) {
+					continue
+				}
+				if errors.Is(err, ErrLeaseLost) || errors.Is(err, ErrNotFound) {
+					if onLost != nil {
+						onLost(err)
+					}
+					return
+				}
+				// Transient storage errors: keep trying until the lease runs out.
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONStore_ClaimJob(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ClaimJob(ctx, "w1", time.Minute); !errors.Is(err, ErrNoJobs) {
+		t.Fatalf("ClaimJob on empty queue error = %v, want ErrNoJobs", err)
+	}
+
+	for _, u := range []string{"a", "b"} {
+		if err := s.PutJob(ctx, &Job{URL: u, Status: JobPending}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j1, err := s.ClaimJob(ctx, "w1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := s.ClaimJob(ctx, "w2", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j1.URL != "a" || j2.URL != "b" {
+		t.Errorf("claimed %q and %q, want a then b", j1.URL, j2.URL)
+	}
+	if j1.Status != JobRunning || j1.Worker != "w1" || j1.Attempts != 1 {
+		t.Errorf("claimed job = %+v, want running by w1 with 1 attempt", j1)
+	}
+	if _, err := s.ClaimJob(ctx, "w3", time.Minute); !errors.Is(err, ErrNoJobs) {
+		t.Errorf("ClaimJob with all jobs leased error = %v, want ErrNoJobs", err)
+	}
+
+	if err := s.RenewLease(ctx, j1.ID, "w2", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease by wrong worker error = %v, want ErrLeaseLost", err)
+	}
+	if err := s.RenewLease(ctx, j1.ID, "w1", time.Minute); err != nil {
+		t.Errorf("RenewLease: %v", err)
+	}
+
+	if err := s.FinishJob(ctx, j1.ID, "w1", JobCompleted, ""); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+	got, err := s.GetJob(ctx, j1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobCompleted || got.Worker != "" || !got.LeaseExpires.IsZero() {
+		t.Errorf("finished job = %+v, want completed with lease cleared", got)
+	}
+}
+
+func TestJSONStore_ExpiredLeaseIsReclaimed(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutJob(ctx, &Job{URL: "a", Status: JobPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	dead, err := s.ClaimJob(ctx, "dead", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	alive, err := s.ClaimJob(ctx, "alive", time.Minute)
+	if err != nil {
+		t.Fatalf("expired lease was not reclaimed: %v", err)
+	}
+	if alive.ID != dead.ID || alive.Attempts != 2 {
+		t.Errorf("reclaimed job = %+v, want same job on its second attempt", alive)
+	}
+	if err := s.FinishJob(ctx, dead.ID, "dead", JobCompleted, ""); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("FinishJob by old worker error = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestJSONStore_ConcurrentClaims(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := OpenJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const jobs = 10
+	for i := 0; i < jobs; i++ {
+		if err := s.PutJob(ctx, &Job{URL: "u", Status: JobPending}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Separate store instances mimic separate processes sharing the file.
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			ws, err := OpenJSON(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for {
+				j, err := ws.ClaimJob(ctx, worker, time.Minute)
+				if errors.Is(err, ErrNoJobs) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[j.ID] {
+					t.Errorf("job %s claimed twice", j.ID)
+				}
+				seen[j.ID] = true
+				mu.Unlock()
+			}
+		}(string(rune('a' + w)))
+	}
+	wg.Wait()
+
+	if len(seen) != jobs {
+		t.Errorf("claimed %d distinct jobs, want %d", len(seen), jobs)
+	}
+}
+
+func TestHeartbeat_ReportsLostLease(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PutJob(ctx, &Job{URL: "a", Status: JobPending}); err != nil {
+		t.Fatal(err)
+	}
+	j, err := s.ClaimJob(ctx, "w1", 30*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lost := make(chan error, 1)
+	stop := Heartbeat(ctx, s, j.ID, "w1", 30*time.Millisecond, func(err error) { lost <- err })
+	defer stop()
+
+	// Simulate another worker taking the job over.
+	j.Worker = "w2"
+	if err := s.PutJob(ctx, j); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("onLost error = %v, want ErrLeaseLost", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("heartbeat did not report the lost lease")
+	}
+}
+
+func TestStopJob(t *testing.T) {
+	stores := map[string]Store{}
+	js, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores["json"] = js
+	stores["sqlite"] = openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+
+	for name, s := range stores {
+		ctx := context.Background()
+		for _, u := range []string{"a", "b"} {
+			if err := s.PutJob(ctx, &Job{URL: u, Status: JobPending, CreatedAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		running, err := s.ClaimJob(ctx, "w1", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		paused, err := s.StopJob(ctx, running.ID, JobPaused)
+		if err != nil {
+			t.Fatalf("%s: StopJob(paused): %v", name, err)
+		}
+		if paused.Status != JobPaused || paused.Worker != "" || !paused.LeaseExpires.IsZero() {
+			t.Errorf("%s: paused job = %+v, want paused with lease cleared", name, paused)
+		}
+		if err := s.FinishJob(ctx, running.ID, "w1", JobCompleted, ""); !errors.Is(err, ErrLeaseLost) {
+			t.Errorf("%s: FinishJob after pause error = %v, want ErrLeaseLost", name, err)
+		}
+
+		// A job that finished must never be rewritten as canceled.
+		other, err := s.ClaimJob(ctx, "w2", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.FinishJob(ctx, other.ID, "w2", JobCompleted, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.StopJob(ctx, other.ID, JobCanceled); !errors.Is(err, ErrJobFinished) {
+			t.Errorf("%s: StopJob on completed job error = %v, want ErrJobFinished", name, err)
+		}
+		if got, _ := s.GetJob(ctx, other.ID); got == nil || got.Status != JobCompleted {
+			t.Errorf("%s: completed job = %+v, want it left completed", name, got)
+		}
+		if _, err := s.StopJob(ctx, "missing", JobCanceled); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: StopJob on missing job error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestResumeJob(t *testing.T) {
+	stores := map[string]Store{}
+	js, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores["json"] = js
+	stores["sqlite"] = openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+
+	for name, s := range stores {
+		ctx := context.Background()
+		j := &Job{URL: "a", Status: JobPending, CreatedAt: time.Now()}
+		if err := s.PutJob(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.ResumeJob(ctx, j.ID); !errors.Is(err, ErrJobNotPaused) {
+			t.Errorf("%s: ResumeJob on pending job error = %v, want ErrJobNotPaused", name, err)
+		}
+		if _, err := s.StopJob(ctx, j.ID, JobPaused); err != nil {
+			t.Fatal(err)
+		}
+		resumed, err := s.ResumeJob(ctx, j.ID)
+		if err != nil {
+			t.Fatalf("%s: ResumeJob: %v", name, err)
+		}
+		if resumed.Status != JobPending {
+			t.Errorf("%s: resumed status = %s, want pending", name, resumed.Status)
+		}
+		// A second resume, e.g. from another client, must not succeed too.
+		if _, err := s.ResumeJob(ctx, j.ID); !errors.Is(err, ErrJobNotPaused) {
+			t.Errorf("%s: second ResumeJob error = %v, want ErrJobNotPaused", name, err)
+		}
+		if _, err := s.ResumeJob(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: ResumeJob on missing job error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
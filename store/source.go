@@ -0,0 +1,47 @@
+package store
+
+import "time"
+
+// SourceStatus is the state of an archived video on Bilibili.
+type SourceStatus string
+
+// Source states recorded by mirror checks.
+const (
+	// SourceAvailable means the video is online and unchanged.
+	SourceAvailable SourceStatus = "available"
+	// SourceModified means the video is online but its title changed or the
+	// archived part was re-uploaded.
+	SourceModified SourceStatus = "modified"
+	// SourceGone means the video was deleted or hidden; the archived copy may
+	// be the only one left.
+	SourceGone SourceStatus = "gone"
+)
+
+// SourceCheck is the outcome of checking an archived video against Bilibili.
+type SourceCheck struct {
+	Status    SourceStatus `json:"status"`
+	Title     string       `json:"title,omitempty"`  // title seen on Bilibili
+	Detail    string       `json:"detail,omitempty"` // what changed, or why it is gone
+	CheckedAt time.Time    `json:"checked_at"`
+}
+
+// RecordSourceCheck stores c as the latest check of e and reports whether the
+// status changed since the previous check. Changes are appended to SourceLog;
+// the first check is logged only when the video is not available.
+func (e *HistoryEntry) RecordSourceCheck(c SourceCheck) bool {
+	prev := e.Source
+	e.Source = &c
+
+	changed := prev == nil && c.Status != SourceAvailable ||
+		prev != nil && (prev.Status != c.Status || prev.Detail != c.Detail)
+	if changed {
+		e.SourceLog = append(e.SourceLog, c)
+	}
+	return changed
+}
+
+// OnlyCopy reports whether the last check found the video gone from
+// Bilibili, leaving the archived file as the only known copy.
+func (e *HistoryEntry) OnlyCopy() bool {
+	return e.Source != nil && e.Source.Status == SourceGone
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordSourceCheck(t *testing.T) {
+	now := time.Now()
+	e := &HistoryEntry{}
+
+	if e.RecordSourceCheck(SourceCheck{Status: SourceAvailable, Title: "a", CheckedAt: now}) {
+		t.Error("first available check should not count as a change")
+	}
+	if e.RecordSourceCheck(SourceCheck{Status: SourceAvailable, Title: "a", CheckedAt: now.Add(time.Hour)}) {
+		t.Error("unchanged status reported as a change")
+	}
+	if !e.RecordSourceCheck(SourceCheck{Status: SourceGone, Detail: "deleted", CheckedAt: now.Add(2 * time.Hour)}) {
+		t.Error("available -> gone not reported as a change")
+	}
+	if !e.OnlyCopy() {
+		t.Error("OnlyCopy should be true once the source is gone")
+	}
+	if len(e.SourceLog) != 1 || e.SourceLog[0].Status != SourceGone {
+		t.Errorf("SourceLog = %+v, want one gone entry", e.SourceLog)
+	}
+}
+
+func TestJSONStore_AddHistoryReplacesByID(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenJSON(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	e := &HistoryEntry{BVID: "BV1", Title: "old"}
+	if err := s.AddHistory(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+	e.RecordSourceCheck(SourceCheck{Status: SourceGone, CheckedAt: time.Now()})
+	if err := s.AddHistory(ctx, e); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].OnlyCopy() {
+		t.Fatalf("history = %+v, want a single updated entry", list)
+	}
+}
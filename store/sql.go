@@ -0,0 +1,472 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect identifies the SQL flavor used by the SQL backend.
+type Dialect string
+
+// Supported SQL dialects.
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// driverNames lists the database/sql driver names accepted for each dialect,
+// in order of preference.
+var driverNames = map[Dialect][]string{
+	DialectSQLite:   {"sqlite", "sqlite3"},
+	DialectPostgres: {"pgx", "postgres"},
+	DialectMySQL:    {"mysql"},
+}
+
+// Table names. Every table stores the record as JSON in the data column so
+// the schema stays identical across dialects and record types can grow
+// without migrations.
+const (
+	tableHistory       = "gobili_history"
+	tableJobs          = "gobili_jobs"
+	tableSubscriptions = "gobili_subscriptions"
+)
+
+// SQLStore persists state in a SQL database through database/sql.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// OpenSQL connects to a database of the given dialect and creates the
+// schema if needed. A driver for the dialect must already be registered.
+func OpenSQL(dialect Dialect, dsn string) (*SQLStore, error) {
+	driver, err := findDriver(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", dialect, err)
+	}
+
+	s := &SQLStore{db: db, dialect: dialect}
+	if dialect == DialectSQLite {
+		if err := s.waitForLocks(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// findDriver returns the first registered driver name for dialect.
+func findDriver(dialect Dialect) (string, error) {
+	candidates, ok := driverNames[dialect]
+	if !ok {
+		return "", fmt.Errorf("unknown SQL dialect: %s", dialect)
+	}
+
+	registered := sql.Drivers()
+	for _, name := range candidates {
+		for _, r := range registered {
+			if r == name {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no database/sql driver registered for %s (tried %s); build goBili with a %s driver or use the JSON backend",
+		dialect, strings.Join(candidates, ", "), dialect)
+}
+
+// sqliteBusyTimeout is how long a SQLite statement waits for another
+// worker's write lock before failing with SQLITE_BUSY.
+const sqliteBusyTimeout = 10 * time.Second
+
+// waitForLocks makes SQLite statements wait for the locks of other workers
+// sharing the file instead of failing at once. The pragma is per
+// connection, so the pool is kept to a single one.
+func (s *SQLStore) waitForLocks() error {
+	s.db.SetMaxOpenConns(1)
+	if _, err := s.db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d", sqliteBusyTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set the SQLite busy timeout: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) migrate(ctx context.Context) error {
+	for _, table := range []string{tableHistory, tableJobs, tableSubscriptions} {
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(64) PRIMARY KEY,
+	data TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+)`, table)
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to create table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// rebind rewrites "?" placeholders for dialects that use numbered ones.
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// put replaces the record with the given id inside a transaction.
+func (s *SQLStore) put(ctx context.Context, table, id string, createdAt time.Time, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op.
+
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM "+table+" WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("failed to replace record: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind("INSERT INTO "+table+" (id, data, created_at) VALUES (?, ?, ?)"),
+		id, string(data), createdAt.UnixNano()); err != nil {
+		return fmt.Errorf("failed to insert record: %w", err)
+	}
+	return tx.Commit()
+}
+
+// list decodes every row of table, oldest first, using decode.
+func (s *SQLStore) list(ctx context.Context, table string, decode func([]byte) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT data FROM "+table+" ORDER BY created_at, id")
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if err := decode([]byte(data)); err != nil {
+			return fmt.Errorf("failed to decode record: %w", err)
+		}
+	}
+	return rows.Err()
+}
+
+// get decodes a single row of table into v.
+func (s *SQLStore) get(ctx context.Context, table, id string, v interface{}) error {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT data FROM "+table+" WHERE id = ?"), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// remove deletes a row, returning ErrNotFound if nothing matched.
+func (s *SQLStore) remove(ctx context.Context, table, id string) error {
+	res, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM "+table+" WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddHistory implements Store.
+func (s *SQLStore) AddHistory(ctx context.Context, e *HistoryEntry) error {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	return s.put(ctx, tableHistory, e.ID, e.CompletedAt, e)
+}
+
+// ListHistory implements Store.
+func (s *SQLStore) ListHistory(ctx context.Context) ([]*HistoryEntry, error) {
+	var out []*HistoryEntry
+	err := s.list(ctx, tableHistory, func(data []byte) error {
+		e := &HistoryEntry{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return err
+		}
+		out = append(out, e)
+		return nil
+	})
+	return out, err
+}
+
+// DeleteHistory implements Store.
+func (s *SQLStore) DeleteHistory(ctx context.Context, id string) error {
+	return s.remove(ctx, tableHistory, id)
+}
+
+// PutJob implements Store.
+func (s *SQLStore) PutJob(ctx context.Context, j *Job) error {
+	if j.ID == "" {
+		j.ID = newID()
+	}
+	return s.put(ctx, tableJobs, j.ID, j.CreatedAt, j)
+}
+
+// GetJob implements Store.
+func (s *SQLStore) GetJob(ctx context.Context, id string) (*Job, error) {
+	j := &Job{}
+	if err := s.get(ctx, tableJobs, id, j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// ListJobs implements Store.
+func (s *SQLStore) ListJobs(ctx context.Context) ([]*Job, error) {
+	var out []*Job
+	err := s.list(ctx, tableJobs, func(data []byte) error {
+		j := &Job{}
+		if err := json.Unmarshal(data, j); err != nil {
+			return err
+		}
+		out = append(out, j)
+		return nil
+	})
+	return out, err
+}
+
+// DeleteJob implements Store.
+func (s *SQLStore) DeleteJob(ctx context.Context, id string) error {
+	return s.remove(ctx, tableJobs, id)
+}
+
+// casJob replaces the stored job only if its data still equals old, which
+// makes read-modify-write cycles safe between machines without relying on
+// dialect-specific locking. It reports whether the swap happened.
+func (s *SQLStore) casJob(ctx context.Context, old string, j *Job) (bool, error) {
+	data, err := json.Marshal(j)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal job: %w", err)
+	}
+	res, err := s.db.ExecContext(ctx,
+		s.rebind("UPDATE "+tableJobs+" SET data = ? WHERE id = ? AND data = ?"),
+		string(data), j.ID, old)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// jobRow is a job together with the raw data it was decoded from.
+type jobRow struct {
+	job *Job
+	raw string
+}
+
+// listJobRows returns all jobs with their raw data, oldest first.
+func (s *SQLStore) listJobRows(ctx context.Context) ([]jobRow, error) {
+	var rows []jobRow
+	err := s.list(ctx, tableJobs, func(data []byte) error {
+		j := &Job{}
+		if err := json.Unmarshal(data, j); err != nil {
+			return err
+		}
+		rows = append(rows, jobRow{job: j, raw: string(data)})
+		return nil
+	})
+	return rows, err
+}
+
+// getJobRow returns a single job with its raw data.
+func (s *SQLStore) getJobRow(ctx context.Context, id string) (jobRow, error) {
+	var raw string
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT data FROM "+tableJobs+" WHERE id = ?"), id).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return jobRow{}, ErrNotFound
+	}
+	if err != nil {
+		return jobRow{}, err
+	}
+	j := &Job{}
+	if err := json.Unmarshal([]byte(raw), j); err != nil {
+		return jobRow{}, err
+	}
+	return jobRow{job: j, raw: raw}, nil
+}
+
+// ClaimJob implements Store.
+func (s *SQLStore) ClaimJob(ctx context.Context, worker string, lease time.Duration) (*Job, error) {
+	rows, err := s.listJobRows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, row := range rows {
+		if !row.job.claimable(now) {
+			continue
+		}
+		row.job.lease(worker, now, lease)
+		ok, err := s.casJob(ctx, row.raw, row.job)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return row.job, nil
+		}
+		// Another worker claimed it first; try the next candidate.
+	}
+	return nil, ErrNoJobs
+}
+
+// RenewLease implements Store.
+func (s *SQLStore) RenewLease(ctx context.Context, id, worker string, lease time.Duration) error {
+	row, err := s.getJobRow(ctx, id)
+	if err != nil {
+		return err
+	}
+	if row.job.Status != JobRunning || row.job.Worker != worker {
+		return ErrLeaseLost
+	}
+	row.job.LeaseExpires = time.Now().Add(lease)
+	ok, err := s.casJob(ctx, row.raw, row.job)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// FinishJob implements Store.
+func (s *SQLStore) FinishJob(ctx context.Context, id, worker string, status JobStatus, errMsg string) error {
+	row, err := s.getJobRow(ctx, id)
+	if err != nil {
+		return err
+	}
+	if row.job.Worker != worker {
+		return ErrLeaseLost
+	}
+	row.job.finish(status, errMsg, time.Now())
+	ok, err := s.casJob(ctx, row.raw, row.job)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// StopJob implements Store.
+func (s *SQLStore) StopJob(ctx context.Context, id string, status JobStatus) (*Job, error) {
+	for {
+		row, err := s.getJobRow(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if row.job.Status.Finished() {
+			return nil, ErrJobFinished
+		}
+		if row.job.Status == status {
+			return row.job, nil
+		}
+		row.job.finish(status, "", time.Now())
+		ok, err := s.casJob(ctx, row.raw, row.job)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return row.job, nil
+		}
+		// A worker claimed, renewed or finished the job since it was read;
+		// look at it again.
+	}
+}
+
+// ResumeJob implements Store.
+func (s *SQLStore) ResumeJob(ctx context.Context, id string) (*Job, error) {
+	for {
+		row, err := s.getJobRow(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if row.job.Status != JobPaused {
+			return nil, ErrJobNotPaused
+		}
+		row.job.Status, row.job.UpdatedAt = JobPending, time.Now()
+		ok, err := s.casJob(ctx, row.raw, row.job)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return row.job, nil
+		}
+		// Resumed, canceled or removed since it was read; look at it again.
+	}
+}
+
+// PutSubscription implements Store.
+func (s *SQLStore) PutSubscription(ctx context.Context, sub *Subscription) error {
+	if sub.ID == "" {
+		sub.ID = newID()
+	}
+	return s.put(ctx, tableSubscriptions, sub.ID, sub.CreatedAt, sub)
+}
+
+// ListSubscriptions implements Store.
+func (s *SQLStore) ListSubscriptions(ctx context.Context) ([]*Subscription, error) {
+	var out []*Subscription
+	err := s.list(ctx, tableSubscriptions, func(data []byte) error {
+		sub := &Subscription{}
+		if err := json.Unmarshal(data, sub); err != nil {
+			return err
+		}
+		out = append(out, sub)
+		return nil
+	})
+	return out, err
+}
+
+// DeleteSubscription implements Store.
+func (s *SQLStore) DeleteSubscription(ctx context.Context, id string) error {
+	return s.remove(ctx, tableSubscriptions, id)
+}
+
+// Close implements Store.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,379 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpen_Routing(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		dsn      string
+		wantPath string
+	}{
+		{"json://" + filepath.Join(dir, "a.json"), filepath.Join(dir, "a.json")},
+		{filepath.Join(dir, "b.json"), filepath.Join(dir, "b.json")},
+	}
+	for _, tt := range tests {
+		s, err := Open(tt.dsn, dir)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", tt.dsn, err)
+		}
+		js, ok := s.(*JSONStore)
+		if !ok {
+			t.Fatalf("Open(%q) returned %T, want *JSONStore", tt.dsn, s)
+		}
+		if js.path != tt.wantPath {
+			t.Errorf("Open(%q) path = %q, want %q", tt.dsn, js.path, tt.wantPath)
+		}
+	}
+
+	for _, dsn := range []string{"", "sqlite://" + filepath.Join(dir, "state.db")} {
+		s, err := Open(dsn, dir)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", dsn, err)
+		}
+		if _, ok := s.(*SQLStore); !ok {
+			t.Errorf("Open(%q) returned %T, want *SQLStore", dsn, s)
+		}
+		s.Close()
+	}
+	if _, err := os.Stat(filepath.Join(dir, "history.db")); err != nil {
+		t.Errorf("default store: %v", err)
+	}
+
+	if _, err := Open("redis://localhost", dir); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestOpen_MissingDriver(t *testing.T) {
+	// Only the SQLite driver is linked into the test binary.
+	for _, dsn := range []string{"postgres://u@h/db", "mysql://u@tcp(h)/db"} {
+		s, err := Open(dsn, t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), "no database/sql driver") {
+			t.Errorf("Open(%q) error = %v, want missing driver error", dsn, err)
+		}
+		if s != nil {
+			t.Errorf("Open(%q) returned a non-nil store on error", dsn)
+		}
+	}
+}
+
+func TestOpen_DefaultImportsStateJSON(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	js, err := OpenJSON(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := js.AddHistory(ctx, &HistoryEntry{BVID: "BV1", CompletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.PutJob(ctx, &Job{URL: "u", Status: JobPending, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.PutSubscription(ctx, &Subscription{Kind: "uploader", Target: "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ { // the second open must not import again
+		s, err := Open("", dir)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		history, _ := s.ListHistory(ctx)
+		jobs, _ := s.ListJobs(ctx)
+		subs, _ := s.ListSubscriptions(ctx)
+		if len(history) != 1 || history[0].BVID != "BV1" || len(jobs) != 1 || len(subs) != 1 {
+			t.Errorf("open %d: got %d history, %d jobs, %d subscriptions, want 1 of each", i, len(history), len(jobs), len(subs))
+		}
+		s.Close()
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state.json.imported")); err != nil {
+		t.Errorf("state.json was not renamed: %v", err)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	q := "INSERT INTO t (a, b) VALUES (?, ?)"
+	pg := &SQLStore{dialect: DialectPostgres}
+	if got, want := pg.rebind(q), "INSERT INTO t (a, b) VALUES ($1, $2)"; got != want {
+		t.Errorf("postgres rebind = %q, want %q", got, want)
+	}
+	my := &SQLStore{dialect: DialectMySQL}
+	if got := my.rebind(q); got != q {
+		t.Errorf("mysql rebind = %q, want unchanged", got)
+	}
+}
+
+// openSQLite opens a SQLite store at path, closing it when the test ends.
+func openSQLite(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	s, err := OpenSQL(DialectSQLite, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLStore_History(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+
+	e := &HistoryEntry{BVID: "BV1", Title: "first", CompletedAt: time.Now()}
+	if err := s.AddHistory(ctx, e); err != nil {
+		t.Fatalf("AddHistory: %v", err)
+	}
+	if e.ID == "" {
+		t.Error("AddHistory should assign an ID")
+	}
+	if err := s.AddHistory(ctx, &HistoryEntry{BVID: "BV2", CompletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.ListHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].BVID != "BV1" {
+		t.Fatalf("ListHistory = %+v, want BV1 then BV2", list)
+	}
+
+	if err := s.DeleteHistory(ctx, e.ID); err != nil {
+		t.Fatalf("DeleteHistory: %v", err)
+	}
+	if err := s.DeleteHistory(ctx, e.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second DeleteHistory error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSQLStore_JobsSharedAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+	a := openSQLite(t, path)
+	b := openSQLite(t, path)
+
+	job := &Job{URL: "https://www.bilibili.com/video/BV1", Status: JobPending, CreatedAt: time.Now()}
+	if err := a.PutJob(ctx, job); err != nil {
+		t.Fatalf("PutJob: %v", err)
+	}
+
+	got, err := b.GetJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("GetJob from second instance: %v", err)
+	}
+	if got.URL != job.URL {
+		t.Errorf("URL = %q, want %q", got.URL, job.URL)
+	}
+
+	got.Status = JobCompleted
+	if err := b.PutJob(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := a.ListJobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Status != JobCompleted {
+		t.Errorf("ListJobs = %+v, want one completed job", jobs)
+	}
+
+	if _, err := a.GetJob(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetJob(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := a.DeleteJob(ctx, job.ID); err != nil {
+		t.Fatalf("DeleteJob: %v", err)
+	}
+}
+
+func TestSQLStore_Subscriptions(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+
+	sub := &Subscription{Kind: "uploader", Target: "123"}
+	if err := s.PutSubscription(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	sub.Name = "renamed"
+	if err := s.PutSubscription(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	subs, err := s.ListSubscriptions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Name != "renamed" {
+		t.Errorf("ListSubscriptions = %+v, want one renamed entry", subs)
+	}
+	if err := s.DeleteSubscription(ctx, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLStore_ClaimJob(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+
+	if _, err := s.ClaimJob(ctx, "w1", time.Minute); !errors.Is(err, ErrNoJobs) {
+		t.Fatalf("ClaimJob on empty queue error = %v, want ErrNoJobs", err)
+	}
+
+	for _, u := range []string{"a", "b"} {
+		if err := s.PutJob(ctx, &Job{URL: u, Status: JobPending, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j1, err := s.ClaimJob(ctx, "w1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j2, err := s.ClaimJob(ctx, "w2", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j1.URL != "a" || j2.URL != "b" {
+		t.Errorf("claimed %q and %q, want a then b", j1.URL, j2.URL)
+	}
+	if j1.Status != JobRunning || j1.Worker != "w1" || j1.Attempts != 1 {
+		t.Errorf("claimed job = %+v, want running by w1 with 1 attempt", j1)
+	}
+	if _, err := s.ClaimJob(ctx, "w3", time.Minute); !errors.Is(err, ErrNoJobs) {
+		t.Errorf("ClaimJob with all jobs leased error = %v, want ErrNoJobs", err)
+	}
+
+	if err := s.RenewLease(ctx, j1.ID, "w2", time.Minute); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease by wrong worker error = %v, want ErrLeaseLost", err)
+	}
+	if err := s.RenewLease(ctx, j1.ID, "w1", time.Minute); err != nil {
+		t.Errorf("RenewLease: %v", err)
+	}
+
+	if err := s.FinishJob(ctx, j1.ID, "w1", JobCompleted, ""); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+	got, err := s.GetJob(ctx, j1.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != JobCompleted || got.Worker != "" || !got.LeaseExpires.IsZero() {
+		t.Errorf("finished job = %+v, want completed with lease cleared", got)
+	}
+}
+
+func TestSQLStore_ExpiredLeaseIsReclaimed(t *testing.T) {
+	ctx := context.Background()
+	s := openSQLite(t, filepath.Join(t.TempDir(), "state.db"))
+	if err := s.PutJob(ctx, &Job{URL: "a", Status: JobPending, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	dead, err := s.ClaimJob(ctx, "dead", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	alive, err := s.ClaimJob(ctx, "alive", time.Minute)
+	if err != nil {
+		t.Fatalf("expired lease was not reclaimed: %v", err)
+	}
+	if alive.ID != dead.ID || alive.Attempts != 2 {
+		t.Errorf("reclaimed job = %+v, want same job on its second attempt", alive)
+	}
+	if err := s.FinishJob(ctx, dead.ID, "dead", JobCompleted, ""); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("FinishJob by old worker error = %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestSQLStore_ConcurrentClaims(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+	s := openSQLite(t, path)
+	const jobs = 10
+	for i := 0; i < jobs; i++ {
+		if err := s.PutJob(ctx, &Job{URL: "u", Status: JobPending, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Two store instances mimic two workers sharing the database.
+	var mu sync.Mutex
+	seen := map[string]string{}
+	var wg sync.WaitGroup
+	for _, worker := range []string{"w1", "w2"} {
+		ws := openSQLite(t, path)
+		wg.Add(1)
+		go func(worker string) {
+			defer wg.Done()
+			for {
+				j, err := ws.ClaimJob(ctx, worker, time.Minute)
+				if errors.Is(err, ErrNoJobs) {
+					return
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if other, ok := seen[j.ID]; ok {
+					t.Errorf("job %s claimed by %s and %s", j.ID, other, worker)
+				}
+				seen[j.ID] = worker
+				mu.Unlock()
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	if len(seen) != jobs {
+		t.Errorf("claimed %d distinct jobs, want %d", len(seen), jobs)
+	}
+}
+
+func TestSQLStore_ReclaimAcrossWorkers(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.db")
+	a := openSQLite(t, path)
+	b := openSQLite(t, path)
+	if err := a.PutJob(ctx, &Job{URL: "a", Status: JobPending, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	lease := 50 * time.Millisecond
+	dead, err := a.ClaimJob(ctx, "dead", lease)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.ClaimJob(ctx, "alive", time.Minute); !errors.Is(err, ErrNoJobs) {
+		t.Fatalf("ClaimJob of a leased job error = %v, want ErrNoJobs", err)
+	}
+	time.Sleep(2 * lease)
+
+	alive, err := b.ClaimJob(ctx, "alive", time.Minute)
+	if err != nil {
+		t.Fatalf("expired lease was not reclaimed by the other worker: %v", err)
+	}
+	if alive.ID != dead.ID || alive.Attempts != 2 {
+		t.Errorf("reclaimed job = %+v, want same job on its second attempt", alive)
+	}
+	if err := a.RenewLease(ctx, dead.ID, "dead", lease); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("RenewLease by old worker error = %v, want ErrLeaseLost", err)
+	}
+	if err := a.FinishJob(ctx, dead.ID, "dead", JobFailed, "late"); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("FinishJob by old worker error = %v, want ErrLeaseLost", err)
+	}
+	if err := b.FinishJob(ctx, alive.ID, "alive", JobCompleted, ""); err != nil {
+		t.Fatalf("FinishJob by new worker: %v", err)
+	}
+}
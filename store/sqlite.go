@@ -0,0 +1,6 @@
+package store
+
+// The SQLite driver is pure Go, so it is linked into every build: sqlite://
+// DSNs work for the goBili binary and for library users without a cgo
+// toolchain or an import of their own.
+import _ "modernc.org/sqlite"
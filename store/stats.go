@@ -0,0 +1,120 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HistoryStats summarizes the download history.
+type HistoryStats struct {
+	Videos int       `json:"videos"` // distinct videos (BVIDs)
+	Parts  int       `json:"parts"`  // history entries: pages and episodes
+	Bytes  int64     `json:"bytes"`
+	First  time.Time `json:"first,omitempty"` // oldest download
+	Last   time.Time `json:"last,omitempty"`  // newest download
+
+	// Uploaders are ordered by videos, most first; Months by month, oldest
+	// first.
+	Uploaders []*StatsGroup `json:"uploaders"`
+	Months    []*StatsGroup `json:"months"`
+}
+
+// StatsGroup is the share of the history of one uploader or month.
+type StatsGroup struct {
+	Name   string `json:"name"`          // uploader name, or month as "2006-01"
+	MID    int64  `json:"mid,omitempty"` // uploader's mid, when known
+	Videos int    `json:"videos"`
+	Parts  int    `json:"parts"`
+	Bytes  int64  `json:"bytes"`
+
+	videos map[string]bool
+	named  time.Time // download the name was taken from
+}
+
+// add counts entry in the group.
+func (g *StatsGroup) add(e *HistoryEntry) {
+	if key := videoKey(e); !g.videos[key] {
+		g.videos[key] = true
+		g.Videos++
+	}
+	g.Parts++
+	g.Bytes += e.Size
+}
+
+// videoKey identifies the video of an entry: its BVID, or the entry itself
+// when the BVID is not known.
+func videoKey(e *HistoryEntry) string {
+	if e.BVID != "" {
+		return e.BVID
+	}
+	return "id:" + e.ID
+}
+
+// Stats summarizes history. Months are calendar months in loc. Entries
+// recorded without an uploader are grouped under an empty name.
+func Stats(history []*HistoryEntry, loc *time.Location) *HistoryStats {
+	stats := &HistoryStats{Uploaders: []*StatsGroup{}, Months: []*StatsGroup{}}
+	videos := make(map[string]bool)
+	uploaders := make(map[string]*StatsGroup)
+	months := make(map[string]*StatsGroup)
+
+	group := func(groups map[string]*StatsGroup, key, name string) *StatsGroup {
+		g := groups[key]
+		if g == nil {
+			g = &StatsGroup{Name: name, videos: make(map[string]bool)}
+			groups[key] = g
+		}
+		return g
+	}
+
+	for _, e := range history {
+		videos[videoKey(e)] = true
+		stats.Parts++
+		stats.Bytes += e.Size
+		if stats.First.IsZero() || e.CompletedAt.Before(stats.First) {
+			stats.First = e.CompletedAt
+		}
+		if e.CompletedAt.After(stats.Last) {
+			stats.Last = e.CompletedAt
+		}
+
+		// Uploaders may be renamed; the mid stays, and the latest name
+		// is shown.
+		key := "name:" + e.Uploader
+		if e.OwnerMID != 0 {
+			key = strconv.FormatInt(e.OwnerMID, 10)
+		}
+		u := group(uploaders, key, e.Uploader)
+		u.MID = e.OwnerMID
+		if e.Uploader != "" && !e.CompletedAt.Before(u.named) {
+			u.Name, u.named = e.Uploader, e.CompletedAt
+		}
+		u.add(e)
+
+		month := e.CompletedAt.In(loc).Format("2006-01")
+		group(months, month, month).add(e)
+	}
+	stats.Videos = len(videos)
+
+	for _, g := range uploaders {
+		stats.Uploaders = append(stats.Uploaders, g)
+	}
+	sort.Slice(stats.Uploaders, func(i, j int) bool {
+		a, b := stats.Uploaders[i], stats.Uploaders[j]
+		if a.Videos != b.Videos {
+			return a.Videos > b.Videos
+		}
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+	for _, g := range months {
+		stats.Months = append(stats.Months, g)
+	}
+	sort.Slice(stats.Months, func(i, j int) bool {
+		return stats.Months[i].Name < stats.Months[j].Name
+	})
+	return stats
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 12, 0, 0, 0, time.UTC)
+	}
+	history := []*HistoryEntry{
+		{ID: "3", BVID: "BV1b", CID: 3, Uploader: "Up", OwnerMID: 7, Size: 300, CompletedAt: day(2, 1)},
+		{ID: "1", BVID: "BV1a", CID: 1, Uploader: "Old name", OwnerMID: 7, Size: 100, CompletedAt: day(1, 5)},
+		{ID: "2", BVID: "BV1a", CID: 2, Uploader: "Up", OwnerMID: 7, Size: 200, CompletedAt: day(1, 6)},
+		{ID: "4", BVID: "BV1c", CID: 4, Uploader: "Other", OwnerMID: 8, Size: 1000, CompletedAt: day(3, 1)},
+		{ID: "5", BVID: "BV1d", CID: 5, Size: 50, CompletedAt: day(2, 2)}, // recorded without an uploader
+	}
+
+	stats := Stats(history, time.UTC)
+	if stats.Videos != 4 || stats.Parts != 5 || stats.Bytes != 1650 {
+		t.Errorf("totals = %d videos, %d parts, %d bytes; want 4, 5, 1650", stats.Videos, stats.Parts, stats.Bytes)
+	}
+	if !stats.First.Equal(day(1, 5)) || !stats.Last.Equal(day(3, 1)) {
+		t.Errorf("range = %v to %v", stats.First, stats.Last)
+	}
+
+	if len(stats.Uploaders) != 3 {
+		t.Fatalf("uploaders = %d, want 3", len(stats.Uploaders))
+	}
+	up := stats.Uploaders[0]
+	if up.Name != "Up" || up.MID != 7 || up.Videos != 2 || up.Parts != 3 || up.Bytes != 600 {
+		t.Errorf("first uploader = %+v", up)
+	}
+	if stats.Uploaders[1].Name != "Other" || stats.Uploaders[2].Name != "" {
+		t.Errorf("uploader order = %q, %q", stats.Uploaders[1].Name, stats.Uploaders[2].Name)
+	}
+
+	var months []string
+	for _, m := range stats.Months {
+		months = append(months, m.Name)
+	}
+	if len(months) != 3 || months[0] != "2024-01" || months[1] != "2024-02" || months[2] != "2024-03" {
+		t.Errorf("months = %v", months)
+	}
+	if feb := stats.Months[1]; feb.Videos != 2 || feb.Bytes != 350 {
+		t.Errorf("2024-02 = %+v", feb)
+	}
+}
+
+func TestStats_Empty(t *testing.T) {
+	stats := Stats(nil, time.UTC)
+	if stats.Videos != 0 || len(stats.Uploaders) != 0 || len(stats.Months) != 0 || !stats.First.IsZero() {
+		t.Errorf("Stats(nil) = %+v", stats)
+	}
+}
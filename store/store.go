@@ -0,0 +1,200 @@
+// Package store persists goBili state — download history, the job queue,
+// and subscriptions — behind a backend-agnostic Store interface.
+//
+// Two backends are provided: a JSON file (the default, suitable for a single
+// machine) and a database/sql backend for SQLite, PostgreSQL, or MySQL that
+// lets several goBili instances share state. The package registers a pure-Go
+// SQLite driver itself; for PostgreSQL or MySQL embedders register a driver
+// (e.g. by importing it for side effects) and select it with a DSN passed
+// to Open. The goBili binary registers all three.
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Sentinel errors returned by Store implementations.
+var (
+	// ErrNotFound is returned when a record with the requested ID does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrNoJobs is returned by ClaimJob when no job is available to lease.
+	ErrNoJobs = errors.New("no claimable jobs")
+	// ErrLeaseLost is returned when a worker no longer holds a job's lease.
+	ErrLeaseLost = errors.New("job lease lost to another worker")
+	// ErrJobFinished is returned by StopJob when the job has already finished.
+	ErrJobFinished = errors.New("job has already finished")
+	// ErrJobNotPaused is returned by ResumeJob when the job is not paused.
+	ErrJobNotPaused = errors.New("job is not paused")
+)
+
+// HistoryEntry records a completed download.
+type HistoryEntry struct {
+	ID          string    `json:"id"`
+	BVID        string    `json:"bvid"`
+	CID         int64     `json:"cid"`
+	Title       string    `json:"title"`
+	Uploader    string    `json:"uploader,omitempty"`
+	OwnerMID    int64     `json:"owner_mid,omitempty"` // uploader's mid
+	Quality     int       `json:"quality"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	CompletedAt time.Time `json:"completed_at"`
+
+	// Duration is the wall-clock download time; with Size it gives the
+	// throughput used for queue ETAs.
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Length is the length of the part on Bilibili in seconds, and NoAudio
+	// is set when it was saved without an audio track; verify --probe
+	// checks the file against both.
+	Length  int  `json:"length,omitempty"`
+	NoAudio bool `json:"no_audio,omitempty"`
+
+	// Source is the latest mirror check of the video on Bilibili, and
+	// SourceLog every change of its status over time.
+	Source    *SourceCheck  `json:"source,omitempty"`
+	SourceLog []SourceCheck `json:"source_log,omitempty"`
+}
+
+// JobStatus is the lifecycle state of a queued job.
+type JobStatus string
+
+// Job states.
+const (
+	JobPending   JobStatus = "pending"
+	JobRunning   JobStatus = "running"
+	JobPaused    JobStatus = "paused"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+	JobCanceled  JobStatus = "canceled"
+)
+
+// Finished reports whether a job in status s has stopped for good.
+func (s JobStatus) Finished() bool {
+	switch s {
+	case JobCompleted, JobFailed, JobCanceled:
+		return true
+	}
+	return false
+}
+
+// Job is a queued download request.
+type Job struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Options   map[string]string `json:"options,omitempty"`
+	Size      int64             `json:"size,omitempty"` // expected bytes, if known
+	Status    JobStatus         `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+
+	// Lease fields, set while a worker is processing the job.
+	Worker       string    `json:"worker,omitempty"`
+	LeaseExpires time.Time `json:"lease_expires,omitempty"`
+	Attempts     int       `json:"attempts,omitempty"`
+}
+
+// claimable reports whether j may be leased at time now: it is pending, or
+// it is running under a lease that has expired (its worker died).
+func (j *Job) claimable(now time.Time) bool {
+	switch j.Status {
+	case JobPending:
+		return true
+	case JobRunning:
+		return !j.LeaseExpires.IsZero() && now.After(j.LeaseExpires)
+	default:
+		return false
+	}
+}
+
+// lease marks j as running under worker until now+d.
+func (j *Job) lease(worker string, now time.Time, d time.Duration) {
+	j.Status = JobRunning
+	j.Worker = worker
+	j.LeaseExpires = now.Add(d)
+	j.Attempts++
+	j.UpdatedAt = now
+}
+
+// finish records the final status of j and clears its lease.
+func (j *Job) finish(status JobStatus, errMsg string, now time.Time) {
+	j.Status = status
+	j.Error = errMsg
+	j.Worker = ""
+	j.LeaseExpires = time.Time{}
+	j.UpdatedAt = now
+}
+
+// Subscription tracks a source that is polled for new content.
+type Subscription struct {
+	ID          string            `json:"id"`
+	Kind        string            `json:"kind"`   // e.g. "uploader" or "season"
+	Target      string            `json:"target"` // mid or season_id
+	Name        string            `json:"name,omitempty"`
+	Options     map[string]string `json:"options,omitempty"`
+	LastChecked time.Time         `json:"last_checked,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// Store is implemented by every persistence backend.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// AddHistory records a completed download, assigning an ID if empty.
+	// An entry with the same ID is replaced.
+	AddHistory(ctx context.Context, e *HistoryEntry) error
+	// ListHistory returns all history entries, oldest first.
+	ListHistory(ctx context.Context) ([]*HistoryEntry, error)
+	// DeleteHistory removes a history entry.
+	DeleteHistory(ctx context.Context, id string) error
+
+	// PutJob inserts or replaces a job, assigning an ID if empty.
+	PutJob(ctx context.Context, j *Job) error
+	// GetJob returns a single job.
+	GetJob(ctx context.Context, id string) (*Job, error)
+	// ListJobs returns all jobs, oldest first.
+	ListJobs(ctx context.Context) ([]*Job, error)
+	// DeleteJob removes a job.
+	DeleteJob(ctx context.Context, id string) error
+
+	// ClaimJob atomically leases the oldest claimable job to worker for the
+	// given duration. Jobs whose lease expired are reclaimed, so work held by
+	// a crashed worker is picked up by another. Returns ErrNoJobs when idle.
+	ClaimJob(ctx context.Context, worker string, lease time.Duration) (*Job, error)
+	// RenewLease extends the lease on a job held by worker (a heartbeat).
+	// Returns ErrLeaseLost if another worker has taken the job over.
+	RenewLease(ctx context.Context, id, worker string, lease time.Duration) error
+	// FinishJob records the outcome of a job held by worker and releases it.
+	FinishJob(ctx context.Context, id, worker string, status JobStatus, errMsg string) error
+	// StopJob moves a job that has not finished to status (JobPaused or
+	// JobCanceled) and releases its lease in one atomic step, so a worker
+	// finishing the job at the same moment is never overwritten. Returns
+	// ErrJobFinished if the job completed, failed or was canceled first.
+	StopJob(ctx context.Context, id string, status JobStatus) (*Job, error)
+	// ResumeJob moves a paused job back to JobPending in one atomic step.
+	// Returns ErrJobNotPaused if the job is in any other status.
+	ResumeJob(ctx context.Context, id string) (*Job, error)
+
+	// PutSubscription inserts or replaces a subscription, assigning an ID if empty.
+	PutSubscription(ctx context.Context, s *Subscription) error
+	// ListSubscriptions returns all subscriptions, oldest first.
+	ListSubscriptions(ctx context.Context) ([]*Subscription, error)
+	// DeleteSubscription removes a subscription.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// Close releases resources held by the backend.
+	Close() error
+}
+
+// newID returns a random 16-character hexadecimal identifier.
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000")))[:16]
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeFile creates a local file to upload.
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "v.mp4")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpen_Targets(t *testing.T) {
+	for _, target := range []string{"ftp://host/x", "webdav+https:///x", "s3:///prefix", "::"} {
+		if _, err := Open(target, Options{AccessKey: "a", SecretKey: "s"}); err == nil {
+			t.Errorf("Open(%q) should fail", target)
+		}
+	}
+	if _, err := Open("s3://bucket", Options{}); err == nil {
+		t.Error("S3 without credentials should fail")
+	}
+}
+
+func TestWebDAVUpload(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	stored := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		user, pass, _ := r.BasicAuth()
+		if user != "me" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "MKCOL":
+			if r.URL.Path == "/dav/bili/" {
+				w.WriteHeader(http.StatusMethodNotAllowed) // already exists
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		case "PUT":
+			body, _ := io.ReadAll(r.Body)
+			stored[r.URL.Path] = string(body)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	target := strings.Replace(server.URL, "http://", "webdav+http://me:secret@", 1) + "/dav"
+	up, err := Open(target, Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	loc, err := up.Upload(context.Background(), writeFile(t, "video"), "bili/UP 主/v.mp4")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	want := []string{"MKCOL /dav/bili/", "MKCOL /dav/bili/UP 主/", "PUT /dav/bili/UP 主/v.mp4"}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+	if stored["/dav/bili/UP 主/v.mp4"] != "video" {
+		t.Errorf("stored = %v", stored)
+	}
+	if !strings.HasSuffix(loc, "/dav/bili/UP%20%E4%B8%BB/v.mp4") || strings.Contains(loc, "secret") {
+		t.Errorf("location = %q", loc)
+	}
+}
+
+func TestS3Upload(t *testing.T) {
+	var got *http.Request
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	up, err := Open("s3://archive/bili", Options{
+		Endpoint:  server.URL,
+		Region:    "auto",
+		AccessKey: "AKID",
+		SecretKey: "SECRET",
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	up.(*s3).now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	loc, err := up.Upload(context.Background(), writeFile(t, "video"), "Title [1080P].mp4")
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if loc != "s3://archive/bili/Title [1080P].mp4" {
+		t.Errorf("location = %q", loc)
+	}
+	if got.Method != "PUT" || got.URL.EscapedPath() != "/archive/bili/Title%20%5B1080P%5D.mp4" || body != "video" {
+		t.Errorf("request = %s %s %q", got.Method, got.URL.EscapedPath(), body)
+	}
+	if got.Header.Get("X-Amz-Date") != "20240501T120000Z" || got.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	auth := regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKID/20240501/auto/s3/aws4_request, ` +
+		`SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`)
+	if !auth.MatchString(got.Header.Get("Authorization")) {
+		t.Errorf("Authorization = %q", got.Header.Get("Authorization"))
+	}
+}
+
+func TestS3Upload_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+	}))
+	defer server.Close()
+
+	up, err := Open("s3://archive", Options{Endpoint: server.URL, AccessKey: "a", SecretKey: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = up.Upload(context.Background(), writeFile(t, "x"), "v.mp4")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("Upload error = %v", err)
+	}
+}
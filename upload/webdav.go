@@ -0,0 +1,104 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// webDAV uploads with PUT, creating missing collections with MKCOL.
+type webDAV struct {
+	base     *url.URL // scheme, host and base path; no user info
+	username string
+	password string
+	client   *http.Client
+}
+
+func newWebDAV(u *url.URL, opts Options) (*webDAV, error) {
+	base := *u
+	base.Scheme = strings.TrimPrefix(u.Scheme, "webdav+")
+	base.User = nil
+	if base.Host == "" {
+		return nil, fmt.Errorf("invalid WebDAV target: missing host")
+	}
+
+	w := &webDAV{base: &base, username: opts.Username, password: opts.Password, client: opts.Client}
+	if u.User != nil {
+		w.username = u.User.Username()
+		w.password, _ = u.User.Password()
+	}
+	return w, nil
+}
+
+// url returns the URL of the remote path p.
+func (w *webDAV) url(p string) string {
+	u := *w.base
+	u.Path = "/" + joinPath(w.base.Path, p)
+	return u.String()
+}
+
+// Upload implements Uploader.
+func (w *webDAV) Upload(ctx context.Context, localPath, name string) (string, error) {
+	if err := w.mkdirAll(ctx, name); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	target := w.url(name)
+	req, err := http.NewRequestWithContext(ctx, "PUT", target, file)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = info.Size()
+	if err := w.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK); err != nil {
+		return "", fmt.Errorf("WebDAV upload of %s failed: %w", name, err)
+	}
+	return target, nil
+}
+
+// mkdirAll creates the parent collections of name. Existing collections
+// answer 405 Method Not Allowed, which is fine.
+func (w *webDAV) mkdirAll(ctx context.Context, name string) error {
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/") + "/"
+		req, err := http.NewRequestWithContext(ctx, "MKCOL", w.url(dir), nil)
+		if err != nil {
+			return err
+		}
+		if err := w.do(req, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return fmt.Errorf("WebDAV MKCOL %s failed: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// do sends req with the configured credentials and checks the status.
+func (w *webDAV) do(req *http.Request, ok ...int) error {
+	if w.username != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("HTTP %d", resp.StatusCode)
+}